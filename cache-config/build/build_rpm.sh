@@ -115,6 +115,12 @@ initBuildArea() {
 		buildManpage 't3c-diff';
 	)
 
+	(
+		cd t3c-rollback;
+		go build -v -gcflags "$gcflags" -ldflags "${ldflags} -X main.GitRevision=$(git rev-parse HEAD) -X main.BuildTimestamp=$(date +'%Y-%M-%dT%H:%M:%s') -X main.Version=${TC_VERSION}";
+		buildManpage 't3c-rollback';
+	)
+
 	(
 		cd t3c-tail;
 		go build -v -gcflags "$gcflags" -ldflags "${ldflags} -X main.GitRevision=$(git rev-parse HEAD) -X main.BuildTimestamp=$(date +'%Y-%M-%dT%H:%M:%s') -X main.Version=${TC_VERSION}";
@@ -28,6 +28,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -114,6 +115,9 @@ type Cfg struct {
 	NoConfirmServiceAction bool
 
 	ReportOnly        bool
+	Plan              bool   // output a JSON plan of what would change, implies ReportOnly
+	RevisionDir       string // directory to store applied config revisions in, empty to not store revisions
+	MaxRevisions      int    // number of config revisions to keep, 0 to keep all
 	GoDirect          string
 	Files             t3cutil.ApplyFilesFlag
 	InstallPackages   bool
@@ -317,7 +321,7 @@ func GetCfg(appVersion string, gitRevision string) (Cfg, error) {
 	defaultClientTLSVersions := getopt.StringLong("default-client-tls-versions", 'V', "", "Comma-delimited list of default TLS versions for Delivery Services with no Parameter, e.g. --default-tls-versions='1.1,1.2,1.3'. If omitted, all versions are enabled.")
 	maxmindLocationPtr := getopt.StringLong("maxmind-location", 'M', "", "URL of a maxmind gzipped database file, to be installed into the trafficserver etc directory.")
 	verbosePtr := getopt.CounterLong("verbose", 'v', `Log verbosity. Logging is output to stderr. By default, errors are logged. To log warnings, pass '-v'. To log info, pass '-vv'. To omit error logging, see '-s'`)
-	cache := getopt.StringLong("cache", 'T', "ats", "Cache server type. Generate configuration files for specific cache server type, e.g. 'ats', 'varnish'.")
+	cache := getopt.StringLong("cache", 'T', "ats", "Cache server type. Generate configuration files for specific cache server type, e.g. 'ats', 'varnish', 'nginx'.")
 	const silentFlagName = "silent"
 	silentPtr := getopt.BoolLong(silentFlagName, 's', `Silent. Errors are not logged, and the 'verbose' flag is ignored. If a fatal error occurs, the return code will be non-zero but no text will be output to stderr`)
 
@@ -337,6 +341,13 @@ func GetCfg(appVersion string, gitRevision string) (Cfg, error) {
 	const reportOnlyFlagName = "report-only"
 	reportOnlyPtr := getopt.BoolLong(reportOnlyFlagName, 'o', "Log information about necessary files and actions, but take no action. Default is false")
 
+	const planFlagName = "plan"
+	planPtr := getopt.BoolLong(planFlagName, 0, "Output a JSON plan of the file diffs, service reload or restart, package changes, and Traffic Ops update flag changes this run would make to stdout, but take no action. Implies --report-only. Default is false")
+
+	const revisionDirFlagName = "revision-dir"
+	revisionDirPtr := getopt.StringLong(revisionDirFlagName, 0, t3cutil.DefaultRevisionDir, "Directory to store a revision of the config files in after every run which changes them, for t3c-rollback. If empty, revisions are not stored. Default is "+t3cutil.DefaultRevisionDir)
+	maxRevisionsPtr := getopt.IntLong("max-revisions", 0, t3cutil.DefaultMaxRevisions, "Number of config revisions to keep. Older revisions are removed. If 0, all revisions are kept. Default is "+strconv.Itoa(t3cutil.DefaultMaxRevisions))
+
 	const filesFlagName = "files"
 	const defaultFiles = t3cutil.ApplyFilesFlagAll
 	filesPtr := getopt.EnumLong(filesFlagName, 'f', []string{string(t3cutil.ApplyFilesFlagAll), string(t3cutil.ApplyFilesFlagReval), ""}, "", "[all | reval] Which files to generate. If reval, the Traffic Ops server reval_pending flag is used instead of the upd_pending flag. Default is 'all'")
@@ -440,6 +451,11 @@ If any of the related flags are also set, they override the mode's default behav
 		}
 	}
 
+	if *planPtr && !*reportOnlyPtr {
+		modeLogStrs = append(modeLogStrs, planFlagName+" setting --"+reportOnlyFlagName+"="+"true")
+		*reportOnlyPtr = true
+	}
+
 	switch *goDirectPtr {
 	case "false", "true", "old":
 		if !getopt.IsSet(goDirectFlagName) {
@@ -597,6 +613,8 @@ If any of the related flags are also set, they override the mode's default behav
 		tsConfigDir = tsHome + "/etc/trafficserver"
 		if cache != nil && *cache == "varnish" {
 			tsConfigDir = tsHome + "/etc/varnish"
+		} else if cache != nil && *cache == "nginx" {
+			tsConfigDir = tsHome + "/etc/nginx"
 		}
 		toInfoLog = append(toInfoLog, fmt.Sprintf("TSHome: %s, TSConfigDir: %s\n", TSHome, tsConfigDir))
 	}
@@ -670,6 +688,9 @@ If any of the related flags are also set, they override the mode's default behav
 		ServiceAction:               t3cutil.ApplyServiceActionFlag(*serviceActionPtr),
 		NoConfirmServiceAction:      *noConfirmServiceAction,
 		ReportOnly:                  *reportOnlyPtr,
+		Plan:                        *planPtr,
+		RevisionDir:                 *revisionDirPtr,
+		MaxRevisions:                *maxRevisionsPtr,
 		Files:                       t3cutil.ApplyFilesFlag(*filesPtr),
 		InstallPackages:             *installPackagesPtr,
 		IgnoreUpdateFlag:            *ignoreUpdateFlagPtr,
@@ -787,6 +808,10 @@ func printConfig(cfg Cfg) {
 	log.Debugf("WaitForParents: %v\n", cfg.WaitForParents)
 	log.Debugf("ServiceAction: %v\n", cfg.ServiceAction)
 	log.Debugf("NoConfirmServiceAction: %v\n", cfg.NoConfirmServiceAction)
+	log.Debugf("ReportOnly: %v\n", cfg.ReportOnly)
+	log.Debugf("Plan: %v\n", cfg.Plan)
+	log.Debugf("RevisionDir: %s\n", cfg.RevisionDir)
+	log.Debugf("MaxRevisions: %d\n", cfg.MaxRevisions)
 	log.Debugf("YumOptions: %s\n", cfg.YumOptions)
 	log.Debugf("MaxmindLocation: %s\n", cfg.MaxMindLocation)
 }
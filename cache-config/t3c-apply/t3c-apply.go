@@ -180,6 +180,12 @@ func Main() int {
 
 	trops := torequest.NewTrafficOpsReq(cfg)
 
+	if cfg.Plan {
+		// The plan is written on every exit, so a failed run still shows what it found.
+		// A non-zero exit code means the plan is incomplete.
+		defer WritePlan(trops)
+	}
+
 	// if doing os checks, insure there is a 'systemctl' or 'service' and 'chkconfig' commands.
 	if !cfg.SkipOSCheck && cfg.SvcManagement == config.Unknown {
 		log.Errorln("OS checks are enabled and unable to find any know service management tools.")
@@ -301,7 +307,7 @@ func Main() int {
 		trops.RemapConfigReload = true
 	}
 
-	if trops.RemapConfigReload == true {
+	if trops.RemapConfigReload == true && !cfg.ReportOnly {
 		cfg, ok := trops.GetConfigFile("remap.config")
 		_, rc, err := util.ExecCommand("/usr/bin/touch", cfg.Path)
 		if err != nil {
@@ -318,7 +324,7 @@ func Main() int {
 	}
 
 	// start 'teakd' if installed.
-	if trops.IsPackageInstalled("teakd") {
+	if trops.IsPackageInstalled("teakd") && !cfg.ReportOnly {
 		svcStatus, pid, err := util.GetServiceStatus("teakd")
 		if err != nil {
 			log.Errorf("not starting 'teakd', error getting 'teakd' run status: %s\n", err)
@@ -334,7 +340,7 @@ func Main() int {
 		}
 	}
 
-	if trops.HitchReload {
+	if trops.HitchReload && !cfg.ReportOnly {
 		svcStatus, _, err := util.GetServiceStatus("hitch")
 		cmd := "start"
 		running := false
@@ -479,6 +485,20 @@ func WriteMetaData(cfg config.Cfg, metaData *t3cutil.ApplyMetaData) {
 	}
 }
 
+// WritePlan writes the plan of everything this run would change to stdout, as JSON.
+//
+// On error, an error is written to the log, but no error is returned.
+func WritePlan(trops *torequest.TrafficOpsReq) {
+	bts, err := trops.Plan().Format()
+	if err != nil {
+		log.Errorln("formatting plan: " + err.Error())
+		return
+	}
+	if _, err := os.Stdout.Write(bts); err != nil {
+		log.Errorln("writing plan: " + err.Error())
+	}
+}
+
 func LoadMetaData(cfg config.Cfg) (*t3cutil.ApplyMetaData, error) {
 	metaDataFilePath := GetMetaDataFilePath(cfg)
 
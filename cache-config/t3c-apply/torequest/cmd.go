@@ -333,10 +333,11 @@ func doTail(cfg config.Cfg, file string, logMatch string, endMatch string, timeo
 	return nil
 }
 
-// diff calls t3c-diff to diff the given new file and the file on disk. Returns whether they're different.
-// Logs the difference.
+// diff calls t3c-diff to diff the given new file and the file on disk. Returns whether they're different,
+// and the difference text from t3c-diff.
+// Logs the difference, to stdout if reportOnly, unless running with --plan, which reserves stdout for the plan.
 // If the file on disk doesn't exist, returns true and logs the entire file as a diff.
-func diff(cfg config.Cfg, newFile []byte, fileLocation string, reportOnly bool, perm os.FileMode, uid int, gid int) (bool, error) {
+func diff(cfg config.Cfg, newFile []byte, fileLocation string, reportOnly bool, perm os.FileMode, uid int, gid int) (bool, string, error) {
 	diffMsg := ""
 	args := []string{
 		"--file-a=stdin",
@@ -351,13 +352,13 @@ func diff(cfg config.Cfg, newFile []byte, fileLocation string, reportOnly bool,
 
 	stdOut, stdErr, code := t3cutil.DoInput(newFile, diffpath, args...)
 	if code > 1 {
-		return false, fmt.Errorf("%s returned error code %v stdout '%v' stderr '%v'", t3cdiff, code, string(stdOut), string(stdErr))
+		return false, "", fmt.Errorf("%s returned error code %v stdout '%v' stderr '%v'", t3cdiff, code, string(stdOut), string(stdErr))
 	}
 	logSubApp(t3cdiff, stdErr)
 
 	if code == 0 {
 		diffMsg += fmt.Sprintf("All lines and file permissions match TrOps for config file: %s\n", fileLocation)
-		return false, nil // 0 is only returned if there's no diff
+		return false, "", nil // 0 is only returned if there's no diff
 	}
 	// code 1 means a diff, difference text will be on stdout
 
@@ -369,7 +370,7 @@ func diff(cfg config.Cfg, newFile []byte, fileLocation string, reportOnly bool,
 	}
 	diffMsg += "file '" + fileLocation + "' changes end" // no trailing newline, becuase we're using log*ln, the last line will get a newline appropriately
 
-	if reportOnly {
+	if reportOnly && !cfg.Plan {
 		// Create our own info logger, to log the diff.
 		// We can't use the logger initialized in the config package because
 		// we don't want to log all the other Info logs.
@@ -385,7 +386,7 @@ func diff(cfg config.Cfg, newFile []byte, fileLocation string, reportOnly bool,
 		}
 	}
 
-	return true, nil
+	return true, string(stdOut), nil
 }
 
 // checkRefs calls t3c-check-refs to verify the given cfgFile.
@@ -443,8 +444,10 @@ func checkCert(c []byte) (error, bool) {
 	return err, fatal
 }
 
-// checkReload is a helper for the sub-command t3c-check-reload.
-func checkReload(changedConfigFiles []string) (t3cutil.ServiceNeeds, error) {
+// CheckReload is a helper for the sub-command t3c-check-reload.
+// It returns whether the caching proxy service must be reloaded or restarted
+// after the given config files changed.
+func CheckReload(changedConfigFiles []string) (t3cutil.ServiceNeeds, error) {
 	log.Infof("%s calling with changedConfigFiles '%v'\n", t3cchkreload, changedConfigFiles)
 
 	changedFiles := []byte(strings.Join(changedConfigFiles, ","))
@@ -0,0 +1,113 @@
+package torequest
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"sort"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-apply/config"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-apply/util"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+)
+
+// newPlan returns a new, empty plan for the server in cfg.
+func newPlan(cfg config.Cfg) *t3cutil.ApplyPlan {
+	plan := t3cutil.NewApplyPlan()
+	plan.ServerHostName = cfg.CacheHostName
+	plan.UpdateStatus.Flag = "update"
+	if cfg.Files == t3cutil.ApplyFilesFlagReval {
+		plan.UpdateStatus.Flag = t3cutil.ApplyFilesFlagReval.String()
+	}
+	return plan
+}
+
+// Plan returns the plan of everything this run would change.
+// This is only populated when t3c-apply is run with --plan, and
+// should be called after all processing, immediately before exiting.
+func (r *TrafficOpsReq) Plan() *t3cutil.ApplyPlan {
+	changed := map[string]struct{}{}
+	for _, path := range r.changedFiles {
+		changed[path] = struct{}{}
+	}
+
+	r.plan.Files = []t3cutil.ApplyPlanFile{}
+	for _, cfg := range r.configFiles {
+		_, apply := changed[cfg.Path]
+		r.plan.Files = append(r.plan.Files, t3cutil.ApplyPlanFile{
+			Name:         cfg.Name,
+			Path:         cfg.Path,
+			Service:      cfg.Service,
+			ChangeNeeded: cfg.ChangeNeeded,
+			Apply:        apply,
+			SkipReason:   cfg.SkipReason,
+			Diff:         cfg.Diff,
+			AuditError:   cfg.AuditError,
+			Warnings:     r.configFileWarnings[cfg.Name],
+		})
+	}
+	sort.Slice(r.plan.Files, func(i, j int) bool { return r.plan.Files[i].Path < r.plan.Files[j].Path })
+
+	r.plan.Service.HitchReload = r.HitchReload
+	r.plan.Service.NtpdRestart = r.NtpdRestart
+	r.plan.Service.SysCtlReload = r.SysCtlReload
+	return r.plan
+}
+
+// planServices records the service action StartServices would take, given what the changed files need.
+// Like a real run, it marks a needed update successful if the service action would be taken,
+// so the plan for the Traffic Ops update flags matches what a real run would do.
+func (r *TrafficOpsReq) planServices(serviceNeeds t3cutil.ServiceNeeds, packageName string, svcStatus util.ServiceStatus, syncdsUpdate *UpdateStatus) {
+	r.plan.Service.Name = packageName
+	r.plan.Service.Needs = "none"
+	if serviceNeeds != t3cutil.ServiceNeedsNothing {
+		r.plan.Service.Needs = serviceNeeds.String()
+	}
+
+	r.plan.Service.Action = "none"
+	switch r.Cfg.ServiceAction {
+	case t3cutil.ApplyServiceActionFlagRestart:
+		r.plan.Service.Action = "restart"
+		if svcStatus != util.SvcRunning {
+			r.plan.Service.Action = "start"
+		}
+	case t3cutil.ApplyServiceActionFlagReload:
+		// a restart is never done when reloading; the new config is picked up the next time the service starts.
+		if serviceNeeds == t3cutil.ServiceNeedsReload {
+			r.plan.Service.Action = "reload"
+		}
+	default:
+		return
+	}
+
+	if *syncdsUpdate == UpdateTropsNeeded {
+		*syncdsUpdate = UpdateTropsSuccessful
+	}
+}
+
+// planUpdateStatus records the change UpdateTrafficOps would make to the server's update flags.
+func (r *TrafficOpsReq) planUpdateStatus(serverStatus *atscfg.ServerUpdateStatus, syncdsUpdate UpdateStatus) {
+	r.plan.UpdateStatus.UpdatePending = serverStatus.UpdatePending
+	r.plan.UpdateStatus.RevalPending = serverStatus.RevalPending
+
+	performUpdate := syncdsUpdate == UpdateTropsSuccessful ||
+		(syncdsUpdate == UpdateTropsNotNeeded && (serverStatus.UpdatePending || serverStatus.RevalPending))
+	r.plan.UpdateStatus.Unset = performUpdate && !r.Cfg.NoUnsetUpdateFlag
+}
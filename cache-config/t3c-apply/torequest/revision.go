@@ -0,0 +1,137 @@
+package torequest
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"syscall"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+)
+
+// revisionStore returns the store config revisions are recorded in, and
+// whether revisions should be recorded by this run.
+func (r *TrafficOpsReq) revisionStore() (t3cutil.RevisionStore, bool) {
+	store := t3cutil.RevisionStore{Dir: r.Cfg.RevisionDir}
+	return store, r.Cfg.RevisionDir != "" && !r.Cfg.ReportOnly
+}
+
+// recordBaselineRevision records the config files currently on disk as the
+// first revision, if no revisions have been recorded yet. This makes the state
+// before t3c-apply first changes anything available to roll back to.
+//
+// On error, an error is written to the log, and no revision is recorded.
+func (r *TrafficOpsReq) recordBaselineRevision() {
+	store, ok := r.revisionStore()
+	if !ok {
+		return
+	}
+	if latest, err := store.Latest(); err != nil {
+		log.Errorln("getting latest config revision, not recording baseline revision: " + err.Error())
+		return
+	} else if latest != nil {
+		return
+	}
+	rev, err := r.diskRevision()
+	if err != nil {
+		log.Errorln("reading config files, not recording baseline revision: " + err.Error())
+		return
+	}
+	if err := store.Add(rev); err != nil {
+		log.Errorln("recording baseline config revision: " + err.Error())
+		return
+	}
+	log.Infof("recorded baseline config revision %d\n", rev.Revision)
+}
+
+// recordRevision records the config files on disk as a new revision, if this
+// run changed any, along with the Traffic Ops update the run applied and the
+// service action it performed.
+//
+// On error, an error is written to the log, and no revision is recorded.
+func (r *TrafficOpsReq) recordRevision(metaData *t3cutil.ApplyMetaData) {
+	store, ok := r.revisionStore()
+	if !ok || len(r.changedFiles) == 0 {
+		return
+	}
+	rev, err := r.diskRevision()
+	if err != nil {
+		log.Errorln("reading config files, not recording config revision: " + err.Error())
+		return
+	}
+	rev.ChangedFiles = append(rev.ChangedFiles, r.changedFiles...)
+	if r.updateStatus != nil {
+		if r.Cfg.Files == t3cutil.ApplyFilesFlagReval {
+			rev.TORevalUpdateTime = r.updateStatus.RevalidateUpdateTime
+		} else {
+			rev.TOConfigUpdateTime = r.updateStatus.ConfigUpdateTime
+		}
+	}
+	if metaData != nil {
+		if metaData.RestartedATS {
+			rev.ServiceAction = t3cutil.ServiceNeedsRestart
+		} else if metaData.ReloadedATS {
+			rev.ServiceAction = t3cutil.ServiceNeedsReload
+		}
+	}
+	if err := store.Add(rev); err != nil {
+		log.Errorln("recording config revision: " + err.Error())
+		return
+	}
+	log.Infof("recorded config revision %d (%s)\n", rev.Revision, rev.ID)
+	if err := store.Prune(r.Cfg.MaxRevisions); err != nil {
+		log.Errorln("removing old config revisions: " + err.Error())
+	}
+}
+
+// diskRevision returns a revision of the config files of this run, as they
+// currently are on disk. Config files which don't exist on disk are omitted.
+func (r *TrafficOpsReq) diskRevision() (*t3cutil.ConfigRevision, error) {
+	rev := t3cutil.NewConfigRevision()
+	rev.ServerHostName = r.Cfg.CacheHostName
+
+	paths := make([]string, 0, len(r.configFiles))
+	for _, cfg := range r.configFiles {
+		paths = append(paths, cfg.Path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.New("reading '" + path + "': " + err.Error())
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errors.New("getting info for '" + path + "': " + err.Error())
+		}
+		uid, gid := 0, 0
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(stat.Uid), int(stat.Gid)
+		}
+		rev.AddFile(path, body, info.Mode(), uid, gid)
+	}
+	return rev, nil
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/apache/trafficcontrol/v8/cache-config/t3c-apply/config"
 	"github.com/apache/trafficcontrol/v8/cache-config/t3c-apply/util"
 	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 )
 
@@ -66,11 +67,15 @@ type TrafficOpsReq struct {
 	plugins map[string]bool // map of verified plugins
 
 	installedPkgs map[string]struct{} // map of packages which were installed by us.
-	changedFiles  []string            // list of config files which were changed
+	changedFiles  []string            // list of config files which were changed, or with --plan, would be changed
 
 	configFiles        map[string]*ConfigFile
 	configFileWarnings map[string][]string
 
+	plan *t3cutil.ApplyPlan // what this run would change, only populated with --plan
+
+	updateStatus *atscfg.ServerUpdateStatus // the server's update status in Traffic Ops, when it was checked for an update
+
 	RestartData
 }
 
@@ -92,6 +97,7 @@ type RestartData struct {
 	RemapConfigReload    bool // remap.config should be reloaded
 	HitchReload          bool // hitch should be reloaded
 	VarnishReload        bool // varnish should be reloaded
+	NginxReload          bool // nginx should be reloaded
 }
 
 type ConfigFile struct {
@@ -108,6 +114,8 @@ type ConfigFile struct {
 	ChangeNeeded      bool   // change required
 	PreReqFailed      bool   // failed plugin prerequiste check
 	RemapPluginConfig bool   // file is a remap plugin config file
+	Diff              string // unified diff from t3c-diff, if ChangeNeeded
+	SkipReason        string // why a needed change was not applied
 	Body              []byte
 	Perm              os.FileMode // default file permissions
 	Uid               int         // owner uid, default is 0
@@ -198,6 +206,7 @@ func NewTrafficOpsReq(cfg config.Cfg) *TrafficOpsReq {
 		plugins:       map[string]bool{},
 		configFiles:   map[string]*ConfigFile{},
 		installedPkgs: map[string]struct{}{},
+		plan:          newPlan(cfg),
 	}
 }
 
@@ -262,13 +271,14 @@ func (r *TrafficOpsReq) checkConfigFile(cfg *ConfigFile, filesAdding []string) e
 		}
 	}
 
-	changeNeeded, err := diff(r.Cfg, cfg.Body, cfg.Path, r.Cfg.ReportOnly, cfg.Perm, cfg.Uid, cfg.Gid)
+	changeNeeded, diffText, err := diff(r.Cfg, cfg.Body, cfg.Path, r.Cfg.ReportOnly, cfg.Perm, cfg.Uid, cfg.Gid)
 
 	if err != nil {
 		cfg.AuditFailed = true
 		return errors.New("getting diff: " + err.Error())
 	}
 	cfg.ChangeNeeded = changeNeeded
+	cfg.Diff = diffText
 	cfg.AuditComplete = true
 
 	log.Infof("======== End processing config file: %s for service: %s ========\n", cfg.Name, cfg.Service)
@@ -476,6 +486,13 @@ const configFileTempSuffix = `.tmp`
 
 // replaceCfgFile replaces an ATS configuration file with one from Traffic Ops.
 func (r *TrafficOpsReq) replaceCfgFile(cfg *ConfigFile) (*FileRestartData, error) {
+	if r.Cfg.Plan {
+		// Nothing is written, but the file is treated as changed, so the plan
+		// reflects the reload or restart a real run would perform.
+		log.Infof("Planning to replace %s with the version from Traffic Ops.\n", cfg.Name)
+		r.changedFiles = append(r.changedFiles, cfg.Path)
+		return cfgFileRestartData(cfg), nil
+	}
 	if r.Cfg.ReportOnly ||
 		(r.Cfg.Files != t3cutil.ApplyFilesFlagAll && r.Cfg.Files != t3cutil.ApplyFilesFlagReval) {
 		log.Infof("You elected not to replace %s with the version from Traffic Ops.\n", cfg.Name)
@@ -502,6 +519,12 @@ func (r *TrafficOpsReq) replaceCfgFile(cfg *ConfigFile) (*FileRestartData, error
 	cfg.ChangeApplied = true
 	r.changedFiles = append(r.changedFiles, cfg.Path)
 
+	log.Debugf("Setting change applied for '%s'\n", cfg.Name)
+	return cfgFileRestartData(cfg), nil
+}
+
+// cfgFileRestartData returns the services which must be reloaded or restarted when cfg is changed.
+func cfgFileRestartData(cfg *ConfigFile) *FileRestartData {
 	remapConfigReload := cfg.RemapPluginConfig ||
 		cfg.Name == "remap.config" ||
 		strings.HasPrefix(cfg.Name, "bg_fetch") ||
@@ -524,10 +547,10 @@ func (r *TrafficOpsReq) replaceCfgFile(cfg *ConfigFile) (*FileRestartData, error
 	sysCtlReload := cfg.Name == "sysctl.conf"
 	hitchReload := cfg.Name == "hitch.conf"
 	varnishReload := cfg.Name == "default.vcl"
+	nginxReload := cfg.Name == "nginx.conf"
 
 	log.Debugf("Reload state after %s: remap.config: %t reload: %t restart: %t ntpd: %t sysctl: %t", cfg.Name, remapConfigReload, trafficCtlReload, trafficServerRestart, ntpdRestart, sysCtlReload)
 
-	log.Debugf("Setting change applied for '%s'\n", cfg.Name)
 	return &FileRestartData{
 		Name: cfg.Name,
 		RestartData: RestartData{
@@ -538,8 +561,9 @@ func (r *TrafficOpsReq) replaceCfgFile(cfg *ConfigFile) (*FileRestartData, error
 			RemapConfigReload:    remapConfigReload,
 			HitchReload:          hitchReload,
 			VarnishReload:        varnishReload,
+			NginxReload:          nginxReload,
 		},
-	}, nil
+	}
 }
 
 // CheckSystemServices is used to verify that packages installed
@@ -718,6 +742,7 @@ func (r *TrafficOpsReq) CheckRevalidateState(sleepOverride bool) (UpdateStatus,
 		return UpdateTropsNotNeeded, errors.New("getting update status: " + err.Error())
 	}
 	log.Infof("my status: %s\n", serverStatus.Status)
+	r.updateStatus = serverStatus
 	if serverStatus.UseRevalPending == false {
 		log.Errorln("Update URL: Instant invalidate is not enabled.  Separated revalidation requires upgrading to Traffic Ops version 2.2 and enabling this feature.")
 		return UpdateTropsNotNeeded, nil
@@ -765,6 +790,7 @@ func (r *TrafficOpsReq) CheckSyncDSState(metaData *t3cutil.ApplyMetaData, cfg co
 			log.Errorln("getting '" + r.Cfg.CacheHostName + "' update status: " + err.Error())
 			return updateStatus, err
 		}
+		r.updateStatus = serverStatus
 
 		if serverStatus.UpdatePending {
 			updateStatus = UpdateTropsNeeded
@@ -818,6 +844,7 @@ func (r *TrafficOpsReq) CheckReloadRestart(data []FileRestartData) RestartData {
 		rd.RemapConfigReload = rd.RemapConfigReload || changedFile.RemapConfigReload
 		rd.HitchReload = rd.HitchReload || changedFile.HitchReload
 		rd.VarnishReload = rd.VarnishReload || changedFile.VarnishReload
+		rd.NginxReload = rd.NginxReload || changedFile.NginxReload
 	}
 	return rd
 }
@@ -860,6 +887,8 @@ func (r *TrafficOpsReq) ProcessConfigFiles(metaData *t3cutil.ApplyMetaData) (Upd
 		}
 	}
 
+	r.recordBaselineRevision()
+
 	changesRequired := 0
 	shouldRestartReload := ShouldReloadRestart{[]FileRestartData{}}
 
@@ -875,13 +904,16 @@ func (r *TrafficOpsReq) ProcessConfigFiles(metaData *t3cutil.ApplyMetaData) (Upd
 			changesRequired++
 			if cfg.Name == "plugin.config" && r.configFiles["remap.config"].PreReqFailed {
 				updateStatus = UpdateTropsFailed
+				cfg.SkipReason = "prereqs failed for remap.config"
 				log.Errorln("plugin.config changed however, prereqs failed for remap.config so I am skipping updates for plugin.config")
 				continue
 			} else if cfg.Name == "remap.config" && r.configFiles["plugin.config"].PreReqFailed {
 				updateStatus = UpdateTropsFailed
+				cfg.SkipReason = "prereqs failed for plugin.config"
 				log.Errorln("remap.config changed however, prereqs failed for plugin.config so I am skipping updates for remap.config")
 				continue
 			} else if cfg.Name == "ip_allow.config" && !r.Cfg.UpdateIPAllow {
+				cfg.SkipReason = "ip_allow.config is only updated with --update-ipallow"
 				log.Warnln("ip_allow.config changed, not updating! Run with --mode=badass or --syncds-updates-ipallow=true to update!")
 				continue
 			} else {
@@ -1002,6 +1034,12 @@ func (r *TrafficOpsReq) ProcessPackages() error {
 		log.Errorf("number of packages requiring removal: %d\n", len(uninstall))
 	}
 
+	if r.Cfg.Plan {
+		r.plan.Packages.Install = append(r.plan.Packages.Install, install...)
+		r.plan.Packages.Remove = append(r.plan.Packages.Remove, uninstall...)
+		return nil
+	}
+
 	if r.Cfg.InstallPackages {
 		log.Debugf("number of packages requiring installation: %d\n", len(install))
 		if r.Cfg.ReportOnly {
@@ -1141,12 +1179,16 @@ func (r *TrafficOpsReq) RevalidateWhileSleeping(metaData *t3cutil.ApplyMetaData,
 // according to the changed config files and run mode.
 // Returns nil on success or any error.
 func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cutil.ApplyMetaData, cfg config.Cfg) error {
+	// The files written and the service action taken are recorded however this returns,
+	// because files changed on disk must always be in the revision history.
+	defer r.recordRevision(metaData)
+
 	serviceNeeds := t3cutil.ServiceNeedsNothing
 	if r.Cfg.ServiceAction == t3cutil.ApplyServiceActionFlagRestart {
 		serviceNeeds = t3cutil.ServiceNeedsRestart
 	} else {
 		err := error(nil)
-		if serviceNeeds, err = checkReload(r.changedFiles); err != nil {
+		if serviceNeeds, err = CheckReload(r.changedFiles); err != nil {
 			return errors.New("determining if service needs restarted - not reloading or restarting! : " + err.Error())
 		}
 	}
@@ -1157,7 +1199,7 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 	// If check-reload does not know about these and we do, then we should initiate
 	// a reload as well
 	if serviceNeeds != t3cutil.ServiceNeedsRestart && serviceNeeds != t3cutil.ServiceNeedsReload {
-		if r.TrafficCtlReload || r.RemapConfigReload || r.VarnishReload {
+		if r.TrafficCtlReload || r.RemapConfigReload || r.VarnishReload || r.NginxReload {
 			log.Infof("ATS config files unchanged, we updated files via t3c-apply, ATS needs reload")
 			serviceNeeds = t3cutil.ServiceNeedsReload
 		}
@@ -1165,6 +1207,8 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 	packageName := "trafficserver"
 	if cfg.CacheType == "varnish" {
 		packageName = "varnish"
+	} else if cfg.CacheType == "nginx" {
+		packageName = "nginx"
 	}
 
 	if (serviceNeeds == t3cutil.ServiceNeedsRestart || serviceNeeds == t3cutil.ServiceNeedsReload) && !r.IsPackageInstalled(packageName) {
@@ -1177,6 +1221,10 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 		return errors.New("getting trafficserver service status: " + err.Error())
 	}
 
+	if r.Cfg.Plan {
+		r.planServices(serviceNeeds, packageName, svcStatus, syncdsUpdate)
+	}
+
 	if r.Cfg.ReportOnly {
 		if serviceNeeds == t3cutil.ServiceNeedsRestart {
 			log.Errorln("ATS configuration has changed.  The new config will be picked up the next time ATS is started.")
@@ -1194,6 +1242,7 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 			return errors.New("failed to restart trafficserver")
 		}
 		t3cutil.WriteActionLog(t3cutil.ActionLogActionATSRestart, t3cutil.ActionLogStatusSuccess, metaData)
+		metaData.RestartedATS = true
 		log.Infoln("trafficserver has been " + startStr + "ed")
 
 		if !r.Cfg.NoConfirmServiceAction {
@@ -1221,6 +1270,9 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 			if cfg.CacheType == "varnish" {
 				reloadCommand = "/usr/sbin/varnishreload"
 				reloadArgs = []string{}
+			} else if cfg.CacheType == "nginx" {
+				reloadCommand = "/usr/sbin/nginx"
+				reloadArgs = []string{"-s", "reload"}
 			}
 			if _, _, err := util.ExecCommand(reloadCommand, reloadArgs...); err != nil {
 				t3cutil.WriteActionLog(t3cutil.ActionLogActionATSReload, t3cutil.ActionLogStatusFailure, metaData)
@@ -1231,6 +1283,7 @@ func (r *TrafficOpsReq) StartServices(syncdsUpdate *UpdateStatus, metaData *t3cu
 				return errors.New("ATS configuration has changed and 'traffic_ctl config reload' failed, check ATS logs: " + err.Error())
 			}
 			t3cutil.WriteActionLog(t3cutil.ActionLogActionATSReload, t3cutil.ActionLogStatusSuccess, metaData)
+			metaData.ReloadedATS = true
 
 			if *syncdsUpdate == UpdateTropsNeeded {
 				*syncdsUpdate = UpdateTropsSuccessful
@@ -1268,6 +1321,10 @@ func (r *TrafficOpsReq) UpdateTrafficOps(syncdsUpdate *UpdateStatus) error {
 		return errors.New("failed to update Traffic Ops: " + err.Error())
 	}
 
+	if r.Cfg.Plan {
+		r.planUpdateStatus(serverStatus, *syncdsUpdate)
+	}
+
 	if *syncdsUpdate == UpdateTropsNotNeeded && (serverStatus.UpdatePending == true || serverStatus.RevalPending == true) {
 		performUpdate = true
 		log.Errorln("Traffic Ops is signaling that an update is ready to be applied but, none was found! Clearing update state in Traffic Ops anyway.")
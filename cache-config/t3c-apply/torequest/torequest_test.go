@@ -20,10 +20,14 @@ package torequest
  */
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/apache/trafficcontrol/v8/cache-config/t3c-apply/config"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-apply/util"
 	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
 )
 
 var testCfg config.Cfg = config.Cfg{
@@ -138,3 +142,166 @@ func TestGetConfigFile(t *testing.T) {
 		t.Errorf("GetConfigFile('remap.config') failed, expected 'remap.config' got '" + cfg.Name + "'.")
 	}
 }
+
+func TestReplaceCfgFilePlan(t *testing.T) {
+	planCfg := testCfg
+	planCfg.ReportOnly = true
+	planCfg.Plan = true
+	trops := NewTrafficOpsReq(planCfg)
+
+	dir := t.TempDir()
+	cfgFile := &ConfigFile{
+		Name: "remap.config",
+		Dir:  dir,
+		Path: filepath.Join(dir, "remap.config"),
+		Body: []byte("map http://foo/ http://bar/\n"),
+		Perm: 0644,
+	}
+
+	rd, err := trops.replaceCfgFile(cfgFile)
+	if err != nil {
+		t.Fatalf("replaceCfgFile() expected: nil error, actual: %v", err)
+	}
+	if _, err := os.Stat(cfgFile.Path); !os.IsNotExist(err) {
+		t.Errorf("replaceCfgFile() with --plan expected: file not written, actual: stat error %v", err)
+	}
+	if cfgFile.ChangeApplied {
+		t.Errorf("replaceCfgFile() with --plan expected: ChangeApplied false, actual: true")
+	}
+	if !rd.RemapConfigReload || !rd.TrafficCtlReload {
+		t.Errorf("replaceCfgFile() with --plan expected: remap.config reload, actual: %+v", rd.RestartData)
+	}
+	if len(trops.changedFiles) != 1 || trops.changedFiles[0] != cfgFile.Path {
+		t.Errorf("replaceCfgFile() with --plan expected: changed files [%s], actual: %v", cfgFile.Path, trops.changedFiles)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	planCfg := testCfg
+	planCfg.ReportOnly = true
+	planCfg.Plan = true
+	trops := NewTrafficOpsReq(planCfg)
+
+	trops.configFiles["remap.config"] = &ConfigFile{
+		Name:         "remap.config",
+		Path:         "/opt/trafficserver/etc/trafficserver/remap.config",
+		Service:      "trafficserver",
+		ChangeNeeded: true,
+		Diff:         "-map http://foo/ http://bar/\n+map http://foo/ http://baz/",
+	}
+	trops.configFiles["ip_allow.config"] = &ConfigFile{
+		Name:         "ip_allow.config",
+		Path:         "/opt/trafficserver/etc/trafficserver/ip_allow.config",
+		Service:      "trafficserver",
+		ChangeNeeded: true,
+		SkipReason:   "ip_allow.config is only updated with --update-ipallow",
+	}
+	trops.configFiles["hosting.config"] = &ConfigFile{
+		Name:    "hosting.config",
+		Path:    "/opt/trafficserver/etc/trafficserver/hosting.config",
+		Service: "trafficserver",
+	}
+	trops.configFileWarnings = map[string][]string{"hosting.config": {"a warning"}}
+	trops.changedFiles = []string{"/opt/trafficserver/etc/trafficserver/remap.config"}
+	trops.SysCtlReload = true
+
+	plan := trops.Plan()
+	if plan.Version != t3cutil.ApplyPlanVersion {
+		t.Errorf("Plan() expected: version '%s', actual: '%s'", t3cutil.ApplyPlanVersion, plan.Version)
+	}
+	if plan.ServerHostName != testCfg.CacheHostName {
+		t.Errorf("Plan() expected: server host name '%s', actual: '%s'", testCfg.CacheHostName, plan.ServerHostName)
+	}
+	if plan.UpdateStatus.Flag != t3cutil.ApplyFilesFlagReval.String() {
+		t.Errorf("Plan() expected: update status flag 'reval', actual: '%s'", plan.UpdateStatus.Flag)
+	}
+	if !plan.Service.SysCtlReload {
+		t.Errorf("Plan() expected: sysctl reload, actual: none")
+	}
+	if len(plan.Files) != 3 {
+		t.Fatalf("Plan() expected: 3 files, actual: %d", len(plan.Files))
+	}
+
+	expectedNames := []string{"hosting.config", "ip_allow.config", "remap.config"}
+	for i, name := range expectedNames {
+		if plan.Files[i].Name != name {
+			t.Errorf("Plan() expected: file %d to be '%s', actual: '%s'", i, name, plan.Files[i].Name)
+		}
+	}
+
+	if hosting := plan.Files[0]; hosting.ChangeNeeded || hosting.Apply || len(hosting.Warnings) != 1 {
+		t.Errorf("Plan() expected: hosting.config unchanged with 1 warning, actual: %+v", hosting)
+	}
+	if ipAllow := plan.Files[1]; !ipAllow.ChangeNeeded || ipAllow.Apply || ipAllow.SkipReason == "" {
+		t.Errorf("Plan() expected: ip_allow.config changed but skipped, actual: %+v", ipAllow)
+	}
+	if remap := plan.Files[2]; !remap.ChangeNeeded || !remap.Apply || remap.Diff == "" {
+		t.Errorf("Plan() expected: remap.config changed and applied with a diff, actual: %+v", remap)
+	}
+}
+
+func TestPlanServices(t *testing.T) {
+	type testCase struct {
+		serviceAction  t3cutil.ApplyServiceActionFlag
+		needs          t3cutil.ServiceNeeds
+		svcStatus      util.ServiceStatus
+		expectedNeeds  string
+		expectedAction string
+		expectedUpdate UpdateStatus
+	}
+	testCases := []testCase{
+		{t3cutil.ApplyServiceActionFlagReload, t3cutil.ServiceNeedsNothing, util.SvcRunning, "none", "none", UpdateTropsSuccessful},
+		{t3cutil.ApplyServiceActionFlagReload, t3cutil.ServiceNeedsReload, util.SvcRunning, "reload", "reload", UpdateTropsSuccessful},
+		{t3cutil.ApplyServiceActionFlagReload, t3cutil.ServiceNeedsRestart, util.SvcRunning, "restart", "none", UpdateTropsSuccessful},
+		{t3cutil.ApplyServiceActionFlagRestart, t3cutil.ServiceNeedsRestart, util.SvcRunning, "restart", "restart", UpdateTropsSuccessful},
+		{t3cutil.ApplyServiceActionFlagRestart, t3cutil.ServiceNeedsRestart, util.SvcNotRunning, "restart", "start", UpdateTropsSuccessful},
+		{t3cutil.ApplyServiceActionFlagNone, t3cutil.ServiceNeedsReload, util.SvcRunning, "reload", "none", UpdateTropsNeeded},
+	}
+
+	for _, tc := range testCases {
+		planCfg := testCfg
+		planCfg.Plan = true
+		planCfg.ServiceAction = tc.serviceAction
+		trops := NewTrafficOpsReq(planCfg)
+
+		syncdsUpdate := UpdateTropsNeeded
+		trops.planServices(tc.needs, "trafficserver", tc.svcStatus, &syncdsUpdate)
+
+		if trops.plan.Service.Needs != tc.expectedNeeds {
+			t.Errorf("planServices(%s, %s) expected: needs '%s', actual: '%s'", tc.serviceAction, tc.needs, tc.expectedNeeds, trops.plan.Service.Needs)
+		}
+		if trops.plan.Service.Action != tc.expectedAction {
+			t.Errorf("planServices(%s, %s) expected: action '%s', actual: '%s'", tc.serviceAction, tc.needs, tc.expectedAction, trops.plan.Service.Action)
+		}
+		if syncdsUpdate != tc.expectedUpdate {
+			t.Errorf("planServices(%s, %s) expected: update status '%s', actual: '%s'", tc.serviceAction, tc.needs, tc.expectedUpdate, syncdsUpdate)
+		}
+	}
+}
+
+func TestPlanUpdateStatus(t *testing.T) {
+	planCfg := testCfg
+	planCfg.Plan = true
+	trops := NewTrafficOpsReq(planCfg)
+
+	trops.planUpdateStatus(&atscfg.ServerUpdateStatus{RevalPending: true}, UpdateTropsSuccessful)
+	if !trops.plan.UpdateStatus.RevalPending || !trops.plan.UpdateStatus.Unset {
+		t.Errorf("planUpdateStatus() expected: reval pending and unset, actual: %+v", trops.plan.UpdateStatus)
+	}
+
+	trops.planUpdateStatus(&atscfg.ServerUpdateStatus{RevalPending: true}, UpdateTropsFailed)
+	if trops.plan.UpdateStatus.Unset {
+		t.Errorf("planUpdateStatus() after failure expected: not unset, actual: unset")
+	}
+
+	trops.planUpdateStatus(&atscfg.ServerUpdateStatus{UpdatePending: true}, UpdateTropsNotNeeded)
+	if !trops.plan.UpdateStatus.Unset {
+		t.Errorf("planUpdateStatus() with a pending update and no changes expected: unset, actual: not unset")
+	}
+
+	trops.Cfg.NoUnsetUpdateFlag = true
+	trops.planUpdateStatus(&atscfg.ServerUpdateStatus{RevalPending: true}, UpdateTropsSuccessful)
+	if trops.plan.UpdateStatus.Unset {
+		t.Errorf("planUpdateStatus() with --no-unset-update-flag expected: not unset, actual: unset")
+	}
+}
@@ -0,0 +1,62 @@
+package cfgfile
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-generate/config"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/nginxcfg"
+)
+
+// GetNginxConfigs returns nginx configuration files
+func GetNginxConfigs(toData *t3cutil.ConfigData, cfg config.Cfg) ([]t3cutil.ATSConfigFile, error) {
+	sslDir := filepath.Join(cfg.Dir, "ssl/")
+	nginxBuilder := nginxcfg.NewNginxBuilder(toData, sslDir)
+	txt, warnings, err := nginxBuilder.BuildNginxConfig()
+	logWarnings("Generating nginx configuration files: ", warnings)
+	if err != nil {
+		return nil, errors.New("building nginx.conf: " + err.Error())
+	}
+
+	configs := make([]t3cutil.ATSConfigFile, 0)
+	configs = append(configs, t3cutil.ATSConfigFile{
+		Name:        "nginx.conf",
+		Text:        txt,
+		Path:        cfg.Dir,
+		ContentType: "text/plain; charset=us-ascii",
+		LineComment: "#",
+		Secure:      false,
+	})
+
+	sslConfigs, err := GetSSLCertsAndKeyFiles(toData)
+	if err != nil {
+		return nil, errors.New("getting ssl key and cert config files: " + err.Error())
+	}
+	for i := range sslConfigs {
+		// path changed manually because GetSSLCertsAndKeyFiles hardcodes the directory certs and keys are written to.
+		sslConfigs[i].Path = sslDir
+	}
+	configs = append(configs, sslConfigs...)
+
+	return configs, nil
+}
@@ -43,7 +43,8 @@ func GetVarnishConfigs(toData *t3cutil.ConfigData, cfg config.Cfg) ([]t3cutil.AT
 		Path:        cfg.Dir,
 		ContentType: "text/plain; charset=us-ascii",
 		LineComment: "//",
-		Secure:      false,
+		// may contain URL signing keys
+		Secure: true,
 	})
 	txt, hitchWarnings := varnishcfg.GetHitchConfig(toData.DeliveryServices, filepath.Join(cfg.Dir, "ssl/"))
 	warnings = append(warnings, hitchWarnings...)
@@ -195,6 +195,7 @@ func MakeParentDotConfig(toData *t3cutil.ConfigData, fileName string, hdrComment
 		toData.ServerCapabilities,
 		toData.DSRequiredCapabilities,
 		toData.CacheGroups,
+		toData.CacheGroupLatencies,
 		toData.DeliveryServiceServers,
 		toData.CDN,
 		&atscfg.ParentConfigOpts{
@@ -326,6 +327,7 @@ func MakeStrategiesDotYAML(toData *t3cutil.ConfigData, fileName string, hdrComme
 		toData.ServerCapabilities,
 		toData.DSRequiredCapabilities,
 		toData.CacheGroups,
+		toData.CacheGroupLatencies,
 		toData.DeliveryServiceServers,
 		toData.CDN,
 		&atscfg.StrategiesYAMLOpts{
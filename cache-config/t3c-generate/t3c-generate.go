@@ -99,6 +99,20 @@ func main() {
 		os.Exit(config.ExitCodeSuccess)
 	}
 
+	if cfg.Cache == "nginx" {
+		configs, err := cfgfile.GetNginxConfigs(toData, cfg)
+		if err != nil {
+			log.Errorln("Generating nginx config for'" + toData.Server.HostName + "': " + err.Error())
+			os.Exit(config.ExitCodeErrGeneric)
+		}
+		err = cfgfile.WriteConfigs(configs, os.Stdout)
+		if err != nil {
+			log.Errorln("Writing configs for '" + toData.Server.HostName + "': " + err.Error())
+			os.Exit(config.ExitCodeErrGeneric)
+		}
+		os.Exit(config.ExitCodeSuccess)
+	}
+
 	configs, err := cfgfile.GetAllConfigs(toData, cfg)
 	if err != nil {
 		log.Errorln("Getting config for'" + toData.Server.HostName + "': " + err.Error())
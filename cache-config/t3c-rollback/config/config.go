@@ -0,0 +1,146 @@
+package config
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/pborman/getopt/v2"
+)
+
+const AppName = "t3c-rollback"
+
+const DefaultTSHome = "/opt/trafficserver"
+
+type Cfg struct {
+	LogLocationDebug string
+	LogLocationError string
+	LogLocationInfo  string
+	LogLocationWarn  string
+	RevisionDir      string
+	To               int  // revision to roll back to
+	List             bool // list revisions instead of rolling back
+	ReportOnly       bool
+	ServiceAction    t3cutil.ApplyServiceActionFlag
+	CacheType        string
+	TsHome           string
+	Version          string
+	GitRevision      string
+}
+
+func (cfg Cfg) AppVersion() string { return t3cutil.VersionStr(AppName, cfg.Version, cfg.GitRevision) }
+
+func (cfg Cfg) DebugLog() log.LogLocation   { return log.LogLocation(cfg.LogLocationDebug) }
+func (cfg Cfg) ErrorLog() log.LogLocation   { return log.LogLocation(cfg.LogLocationError) }
+func (cfg Cfg) InfoLog() log.LogLocation    { return log.LogLocation(cfg.LogLocationInfo) }
+func (cfg Cfg) WarningLog() log.LogLocation { return log.LogLocation(cfg.LogLocationWarn) }
+func (cfg Cfg) EventLog() log.LogLocation   { return log.LogLocation(log.LogLocationNull) } // event logging is not used.
+
+// Usage() writes command line options and usage to 'stderr'
+func Usage() {
+	getopt.PrintUsage(os.Stderr)
+	os.Exit(0)
+}
+
+// InitConfig() intializes the configuration variables and loggers.
+func InitConfig(appVersion string, gitRevision string) (Cfg, error) {
+	const toFlagName = "to"
+	toPtr := getopt.IntLong(toFlagName, 'r', 0, "[revision] The number of the config revision to roll back to. Required unless --list is given")
+	listPtr := getopt.BoolLong("list", 'l', "List the stored config revisions and exit")
+	revisionDirPtr := getopt.StringLong("revision-dir", 'd', t3cutil.DefaultRevisionDir, "Directory t3c-apply stores config revisions in. Default is "+t3cutil.DefaultRevisionDir)
+	reportOnlyPtr := getopt.BoolLong("report-only", 'o', "Log the files which would be restored and the service action which would be performed, but take no action. Default is false")
+	serviceActionPtr := getopt.EnumLong("service-action", 'a', []string{string(t3cutil.ApplyServiceActionFlagReload), string(t3cutil.ApplyServiceActionFlagRestart), string(t3cutil.ApplyServiceActionFlagNone)}, string(t3cutil.ApplyServiceActionFlagReload), "[reload | restart | none] action to perform on the caching proxy service after restoring files. Only reloads if necessary, but always restarts. Default is 'reload'")
+	cachePtr := getopt.StringLong("cache", 'T', "ats", "Cache server type, e.g. 'ats', 'varnish'. Default is 'ats'")
+	tsHomePtr := getopt.StringLong("trafficserver-home", 'R', "", "Trafficserver Package directory. May also be set with the environment variable TS_HOME")
+	helpPtr := getopt.BoolLong("help", 'h', "Print usage information and exit")
+	versionPtr := getopt.BoolLong("version", 'V', "Print the version")
+	verbosePtr := getopt.CounterLong("verbose", 'v', `Log verbosity. Logging is output to stderr. By default, errors are logged. To log warnings, pass '-v'. To log info, pass '-vv'. To omit error logging, see '-s'`)
+	silentPtr := getopt.BoolLong("silent", 's', `Silent. Errors are not logged, and the 'verbose' flag is ignored. If a fatal error occurs, the return code will be non-zero but no text will be output to stderr`)
+
+	getopt.Parse()
+
+	if *helpPtr == true {
+		Usage()
+	} else if *versionPtr {
+		cfg := &Cfg{Version: appVersion, GitRevision: gitRevision}
+		fmt.Println(cfg.AppVersion())
+		os.Exit(0)
+	}
+
+	if !*listPtr && *toPtr <= 0 {
+		return Cfg{}, errors.New("--" + toFlagName + " must be a revision number, see --list for the stored revisions")
+	}
+	if *revisionDirPtr == "" {
+		return Cfg{}, errors.New("--revision-dir must not be empty")
+	}
+
+	logLocationError := log.LogLocationStderr
+	logLocationWarn := log.LogLocationNull
+	logLocationInfo := log.LogLocationNull
+	logLocationDebug := log.LogLocationNull
+	if *silentPtr {
+		logLocationError = log.LogLocationNull
+	} else {
+		if *verbosePtr >= 1 {
+			logLocationWarn = log.LogLocationStderr
+		}
+		if *verbosePtr >= 2 {
+			logLocationInfo = log.LogLocationStderr
+			logLocationDebug = log.LogLocationStderr // t3c only has 3 verbosity options: none (-s), error (default or --verbose=0), warning (-v), and info (-vv). Any code calling log.Debug is treated as Info.
+		}
+	}
+
+	if *verbosePtr > 2 {
+		return Cfg{}, errors.New("Too many verbose options. The maximum log verbosity level is 2 (-vv or --verbose=2) for errors (0), warnings (1), and info (2)")
+	}
+
+	tsHome := *tsHomePtr
+	if tsHome == "" {
+		tsHome = os.Getenv("TS_HOME")
+	}
+	if tsHome == "" {
+		tsHome = DefaultTSHome
+	}
+
+	cfg := Cfg{
+		LogLocationDebug: logLocationDebug,
+		LogLocationError: logLocationError,
+		LogLocationInfo:  logLocationInfo,
+		LogLocationWarn:  logLocationWarn,
+		RevisionDir:      *revisionDirPtr,
+		To:               *toPtr,
+		List:             *listPtr,
+		ReportOnly:       *reportOnlyPtr,
+		ServiceAction:    t3cutil.ApplyServiceActionFlag(*serviceActionPtr),
+		CacheType:        *cachePtr,
+		TsHome:           tsHome,
+		Version:          appVersion,
+		GitRevision:      gitRevision,
+	}
+
+	if err := log.InitCfg(cfg); err != nil {
+		return Cfg{}, errors.New("initializing loggers: " + err.Error())
+	}
+	return cfg, nil
+}
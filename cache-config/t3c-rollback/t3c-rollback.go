@@ -0,0 +1,307 @@
+package main
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-apply/torequest"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-apply/util"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3c-rollback/config"
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+)
+
+// Version is the application version.
+// This is overwritten by the build with the current project version.
+var Version = "0.4"
+
+// GitRevision is the git revision the application was built from.
+// This is overwritten by the build with the current project version.
+var GitRevision = "nogit"
+
+const (
+	ExitCodeSuccess        = 0
+	ExitCodeConfigError    = 1
+	ExitCodeAlreadyRunning = 2
+	ExitCodeRevisionError  = 3
+	ExitCodeRestoreError   = 4
+	ExitCodeServicesError  = 5
+)
+
+// LockFilePath is the lock held by t3c-apply, so a rollback never runs at the
+// same time as an apply.
+const LockFilePath = "/var/run/t3c.lock"
+
+const trafficCtl = "/bin/traffic_ctl"
+const varnishReload = "/usr/sbin/varnishreload"
+const nginx = "/usr/sbin/nginx"
+
+func main() {
+	os.Exit(Main())
+}
+
+// Main is the main function of t3c-rollback.
+// This is a separate function so defer statements behave as-expected.
+// DO NOT call os.Exit within this function; return the code instead.
+// Returns the application exit code.
+func Main() int {
+	cfg, err := config.InitConfig(Version, GitRevision)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
+		return ExitCodeConfigError
+	}
+	store := t3cutil.RevisionStore{Dir: cfg.RevisionDir}
+
+	if cfg.List {
+		revs, err := store.List()
+		if err != nil {
+			log.Errorln("listing revisions: " + err.Error())
+			return ExitCodeRevisionError
+		}
+		if err := WriteRevisionList(os.Stdout, revs); err != nil {
+			log.Errorln("writing revision list: " + err.Error())
+			return ExitCodeRevisionError
+		}
+		return ExitCodeSuccess
+	}
+
+	lock := util.FileLock{}
+	if !lock.GetLock(LockFilePath) {
+		log.Errorln("Failed to get app lock, t3c-apply is running, exiting without rolling back")
+		return ExitCodeAlreadyRunning
+	}
+	defer lock.Unlock()
+
+	target, err := store.Get(cfg.To)
+	if err != nil {
+		log.Errorln("getting revision to roll back to: " + err.Error())
+		return ExitCodeRevisionError
+	}
+	latest, err := store.Latest()
+	if err != nil {
+		log.Errorln("getting latest revision: " + err.Error())
+		return ExitCodeRevisionError
+	}
+	if latest != nil {
+		for _, file := range latest.Files {
+			if _, ok := target.File(file.Path); !ok {
+				log.Warnf("'%s' is not in revision %d, and will not be removed\n", file.Path, target.Revision)
+			}
+		}
+	}
+
+	changed, err := RestoreFiles(store, target, cfg.ReportOnly)
+	if err != nil {
+		log.Errorf("restoring revision %d: %s\n", target.Revision, err.Error())
+		if len(changed) == 0 {
+			return ExitCodeRestoreError
+		}
+		// Some files were already restored, so the service must still be reloaded
+		// to apply them, and the partial rollback recorded.
+	}
+	if len(changed) == 0 {
+		log.Infof("config files already match revision %d, nothing to roll back\n", target.Revision)
+		return ExitCodeSuccess
+	}
+
+	exitCode := ExitCodeSuccess
+	if err != nil {
+		exitCode = ExitCodeRestoreError
+	}
+	action, err := DoServiceActions(cfg, changed)
+	if err != nil {
+		log.Errorln(err.Error())
+		exitCode = ExitCodeServicesError
+	}
+	if cfg.ReportOnly {
+		return exitCode
+	}
+
+	rev := t3cutil.NewRollbackRevision(target)
+	rev.ChangedFiles = append(rev.ChangedFiles, changed...)
+	rev.ServiceAction = action
+	if err := store.Add(rev); err != nil {
+		log.Errorln("recording rollback revision: " + err.Error())
+	} else {
+		log.Infof("rolled back to revision %d, recorded as revision %d\n", target.Revision, rev.Revision)
+	}
+	return exitCode
+}
+
+// RestoreFiles writes the files of the given revision which differ from the
+// files on disk, and returns the paths of the files written.
+//
+// If reportOnly is true, nothing is written, but the paths of the files which
+// would be are returned.
+func RestoreFiles(store t3cutil.RevisionStore, rev *t3cutil.ConfigRevision, reportOnly bool) ([]string, error) {
+	changed := []string{}
+	for _, file := range rev.Files {
+		current, err := os.ReadFile(file.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return changed, errors.New("reading '" + file.Path + "': " + err.Error())
+		}
+		if err == nil && t3cutil.RevisionHash(current) == file.Hash {
+			if info, err := os.Stat(file.Path); err == nil && info.Mode().Perm() == file.Mode.Perm() {
+				continue
+			}
+		}
+
+		if reportOnly {
+			log.Infof("would restore '%s' from revision %d\n", file.Path, rev.Revision)
+			changed = append(changed, file.Path)
+			continue
+		}
+		body, err := store.ReadFile(file)
+		if err != nil {
+			return changed, err
+		}
+		// write a new file, then move to the real location, because moving is atomic but writing is not.
+		tmpPath := file.Path + ".tmp"
+		uid, gid := file.UID, file.GID
+		if _, err := util.WriteFileWithOwner(tmpPath, body, &uid, &gid, file.Mode.Perm()); err != nil {
+			return changed, errors.New("writing '" + tmpPath + "': " + err.Error())
+		}
+		if err := os.Rename(tmpPath, file.Path); err != nil {
+			return changed, errors.New("moving '" + tmpPath + "' to '" + file.Path + "': " + err.Error())
+		}
+		log.Infof("restored '%s' from revision %d\n", file.Path, rev.Revision)
+		changed = append(changed, file.Path)
+	}
+	return changed, nil
+}
+
+// DoServiceActions reloads or restarts the services which need it after the
+// given files were restored, the same way t3c-apply does after writing them,
+// and returns the action performed on the caching proxy service.
+func DoServiceActions(cfg config.Cfg, changed []string) (t3cutil.ServiceNeeds, error) {
+	serviceNeeds := t3cutil.ServiceNeedsNothing
+	if cfg.ServiceAction == t3cutil.ApplyServiceActionFlagRestart {
+		serviceNeeds = t3cutil.ServiceNeedsRestart
+	} else {
+		err := error(nil)
+		if serviceNeeds, err = torequest.CheckReload(changed); err != nil {
+			return t3cutil.ServiceNeedsNothing, errors.New("determining if service needs restarted - not reloading or restarting! : " + err.Error())
+		}
+	}
+
+	packageName := "trafficserver"
+	reloadCommand := filepath.Join(cfg.TsHome, trafficCtl)
+	reloadArgs := []string{"config", "reload"}
+	if cfg.CacheType == "varnish" {
+		packageName = "varnish"
+		reloadCommand = varnishReload
+		reloadArgs = []string{}
+		if serviceNeeds == t3cutil.ServiceNeedsNothing && changedFile(changed, "default.vcl") {
+			serviceNeeds = t3cutil.ServiceNeedsReload
+		}
+	} else if cfg.CacheType == "nginx" {
+		packageName = "nginx"
+		reloadCommand = nginx
+		reloadArgs = []string{"-s", "reload"}
+		if serviceNeeds == t3cutil.ServiceNeedsNothing && changedFile(changed, "nginx.conf") {
+			serviceNeeds = t3cutil.ServiceNeedsReload
+		}
+	}
+
+	hitchReload := changedFile(changed, "hitch.conf")
+	if cfg.ReportOnly || cfg.ServiceAction == t3cutil.ApplyServiceActionFlagNone {
+		if serviceNeeds != t3cutil.ServiceNeedsNothing {
+			log.Errorf("%s needs %s to pick up the restored config\n", packageName, serviceNeeds)
+		}
+		if hitchReload {
+			log.Errorln("hitch needs reload to pick up the restored config")
+		}
+		return t3cutil.ServiceNeedsNothing, nil
+	}
+
+	action := t3cutil.ServiceNeedsNothing
+	switch {
+	case cfg.ServiceAction == t3cutil.ApplyServiceActionFlagRestart:
+		if _, err := util.ServiceStart(packageName, "restart"); err != nil {
+			return action, errors.New("failed to restart " + packageName + ": " + err.Error())
+		}
+		action = t3cutil.ServiceNeedsRestart
+		log.Infoln(packageName + " has been restarted")
+	case serviceNeeds == t3cutil.ServiceNeedsRestart:
+		log.Errorln(packageName + " configuration has changed. The restored config will be picked up the next time it is started, or run with --service-action=restart")
+	case serviceNeeds == t3cutil.ServiceNeedsReload:
+		if _, _, err := util.ExecCommand(reloadCommand, reloadArgs...); err != nil {
+			return action, errors.New("reloading " + packageName + " failed, check its logs: " + err.Error())
+		}
+		action = t3cutil.ServiceNeedsReload
+		log.Infoln(packageName + " has been reloaded")
+	}
+
+	if hitchReload {
+		if _, err := util.ServiceStart("hitch", "reload"); err != nil {
+			return action, errors.New("failed to reload hitch: " + err.Error())
+		}
+		log.Infoln("hitch has been reloaded")
+	}
+	return action, nil
+}
+
+// changedFile returns whether any of the changed paths is a file with the given name.
+func changedFile(changed []string, name string) bool {
+	for _, path := range changed {
+		if filepath.Base(path) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteRevisionList writes a table of the given revisions to w.
+func WriteRevisionList(w io.Writer, revs []*t3cutil.ConfigRevision) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "REVISION\tID\tTIMESTAMP\tTO UPDATE TIME\tSERVICE ACTION\tCHANGED FILES\tNOTE")
+	for _, rev := range revs {
+		id := rev.ID
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		toTime := "-"
+		if rev.TOConfigUpdateTime != nil {
+			toTime = rev.TOConfigUpdateTime.Format(time.RFC3339)
+		} else if rev.TORevalUpdateTime != nil {
+			toTime = rev.TORevalUpdateTime.Format(time.RFC3339) + " (reval)"
+		}
+		action := string(rev.ServiceAction)
+		if action == "" {
+			action = "-"
+		}
+		note := ""
+		if rev.RollbackOf != nil {
+			note = "rollback to " + strconv.Itoa(*rev.RollbackOf)
+		} else if len(rev.ChangedFiles) == 0 {
+			note = "baseline"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n", rev.Revision, id, rev.Timestamp.Format(time.RFC3339), toTime, action, len(rev.ChangedFiles), note)
+	}
+	return tw.Flush()
+}
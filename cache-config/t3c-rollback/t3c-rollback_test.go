@@ -0,0 +1,112 @@
+package main
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+)
+
+func TestRestoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := t3cutil.RevisionStore{Dir: filepath.Join(dir, "revisions")}
+	remap := filepath.Join(dir, "remap.config")
+	records := filepath.Join(dir, "records.config")
+	uid, gid := os.Getuid(), os.Getgid()
+
+	rev := t3cutil.NewConfigRevision()
+	rev.AddFile(remap, []byte("map good\n"), 0644, uid, gid)
+	rev.AddFile(records, []byte("CONFIG foo INT 1\n"), 0644, uid, gid)
+	if err := store.Add(rev); err != nil {
+		t.Fatalf("adding revision: %v", err)
+	}
+
+	if err := os.WriteFile(remap, []byte("map bad\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(records, []byte("CONFIG foo INT 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := RestoreFiles(store, rev, true)
+	if err != nil {
+		t.Fatalf("restoring report-only: %v", err)
+	}
+	if len(changed) != 1 || changed[0] != remap {
+		t.Errorf("expected only remap.config to need restoring, actual %v", changed)
+	}
+	if body, _ := os.ReadFile(remap); string(body) != "map bad\n" {
+		t.Errorf("expected report-only to not change files, actual remap.config '%s'", body)
+	}
+
+	changed, err = RestoreFiles(store, rev, false)
+	if err != nil {
+		t.Fatalf("restoring: %v", err)
+	}
+	if len(changed) != 1 || changed[0] != remap {
+		t.Errorf("expected only remap.config to be restored, actual %v", changed)
+	}
+	if body, _ := os.ReadFile(remap); string(body) != "map good\n" {
+		t.Errorf("expected remap.config to be restored, actual '%s'", body)
+	}
+
+	if changed, err = RestoreFiles(store, rev, false); err != nil || len(changed) != 0 {
+		t.Errorf("expected nothing to restore when files match, actual %v error %v", changed, err)
+	}
+}
+
+func TestWriteRevisionList(t *testing.T) {
+	store := t3cutil.RevisionStore{Dir: t.TempDir()}
+	baseline := t3cutil.NewConfigRevision()
+	baseline.AddFile("/etc/remap.config", []byte("a"), 0644, 0, 0)
+	if err := store.Add(baseline); err != nil {
+		t.Fatal(err)
+	}
+	rollback := t3cutil.NewRollbackRevision(baseline)
+	rollback.ChangedFiles = []string{"/etc/remap.config"}
+	rollback.ServiceAction = t3cutil.ServiceNeedsReload
+	if err := store.Add(rollback); err != nil {
+		t.Fatal(err)
+	}
+	revs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteRevisionList(buf, revs); err != nil {
+		t.Fatalf("writing revision list: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and 2 revisions, actual:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[1], "baseline") {
+		t.Errorf("expected revision 1 to be noted as the baseline, actual '%s'", lines[1])
+	}
+	if !strings.Contains(lines[2], "reload") || !strings.Contains(lines[2], "rollback to 1") {
+		t.Errorf("expected revision 2 to be a reloaded rollback to 1, actual '%s'", lines[2])
+	}
+}
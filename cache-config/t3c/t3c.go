@@ -48,6 +48,7 @@ var commands = map[string]struct{}{
 	"generate":   struct{}{},
 	"preprocess": struct{}{},
 	"request":    struct{}{},
+	"rollback":   struct{}{},
 	"tail":       struct{}{},
 	"update":     struct{}{},
 }
@@ -117,6 +118,7 @@ These are the available commands:
   generate   generate configuration from Traffic Ops data
   preprocess preprocess generated config files
   request    request Traffic Ops data
+  rollback   roll back to a previously applied config revision
   tail       tail a log file
   update     update a cache's queue and reval status in Traffic Ops
 `
@@ -49,6 +49,10 @@ type ConfigData struct {
 	// CacheGroups must be all cachegroups in Traffic Ops with Servers on the current server's cdn. May also include CacheGroups without servers on the current cdn.
 	CacheGroups []tc.CacheGroupNullableV5 `json:"cache_groups,omitempty"`
 
+	// CacheGroupLatencies are the latencies between cachegroups and their parent cachegroups, used by latency-aware parent preference.
+	// May be empty, if Traffic Ops doesn't have any latencies or doesn't support them.
+	CacheGroupLatencies []tc.CacheGroupLatency `json:"cache_group_latencies,omitempty"`
+
 	// GlobalParams must be all Parameters in Traffic Ops on the tc.GlobalProfileName Profile. Must not include other parameters.
 	GlobalParams []tc.ParameterV5 `json:"global_parameters,omitempty"`
 
@@ -759,6 +763,22 @@ func GetConfigData(toClient *toreq.TOClient, disableProxy bool, cacheHostName st
 		return nil
 	}
 
+	cgLatenciesF := func() error {
+		defer func(start time.Time) { log.Infof("cgLatenciesF took %v\n", time.Since(start)) }(time.Now())
+		{
+			latencies, reqInf, err := toClient.GetCacheGroupLatencies()
+			log.Infoln(toreq.RequestInfoStr(reqInf, "GetCacheGroupLatencies"))
+			if err != nil {
+				// latencies are optional, and older Traffic Ops don't have them, so don't fail
+				log.Warnln("getting cachegroup latencies, parents will use the configured order and weights: " + err.Error())
+				return nil
+			}
+			toData.CacheGroupLatencies = latencies
+			toIPs.Store(reqInf.RemoteAddr, nil)
+		}
+		return nil
+	}
+
 	topologiesF := func() error {
 		defer func(start time.Time) { log.Infof("topologiesF took %v\n", time.Since(start)) }(time.Now())
 		{
@@ -787,7 +807,7 @@ func GetConfigData(toClient *toreq.TOClient, disableProxy bool, cacheHostName st
 	fs := []func() error{serversF, cgF}
 	if !revalOnly {
 		// skip data not needed for reval, if we're reval-only
-		fs = append([]func() error{dsrF, cacheKeyConfigParamsF, remapConfigParamsF, parentConfigParamsF, capsF, dsCapsF, topologiesF}, fs...)
+		fs = append([]func() error{dsrF, cacheKeyConfigParamsF, remapConfigParamsF, parentConfigParamsF, capsF, dsCapsF, topologiesF, cgLatenciesF}, fs...)
 	}
 	errs := runParallel(fs)
 
@@ -0,0 +1,354 @@
+package t3cutil
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRevisionDir is the default directory t3c-apply stores config revisions in.
+const DefaultRevisionDir = `/var/lib/trafficcontrol-cache-config/revisions`
+
+// DefaultMaxRevisions is the default number of config revisions kept.
+const DefaultMaxRevisions = 100
+
+// RevisionVersion is the version of the revision manifest format.
+const RevisionVersion = "1.0"
+
+const revisionObjectDir = `objects`
+const revisionManifestSuffix = `.json`
+const revisionDirMode = 0700
+const revisionFileMode = 0600 // config files may include private keys
+
+// ConfigRevision is a set of config files applied by t3c-apply, as stored in
+// a RevisionStore.
+//
+// File contents are stored by their SHA-256 hash, so files unchanged between
+// revisions are only stored once, and two revisions with the same ID have
+// exactly the same files.
+type ConfigRevision struct {
+	// Version is the manifest format version. See RevisionVersion.
+	Version string `json:"version"`
+
+	// Revision is the number of this revision. Revisions are numbered
+	// sequentially from 1 as they are added to their store.
+	Revision int `json:"revision"`
+
+	// ID is the hex SHA-256 hash of the paths and content hashes of Files.
+	ID string `json:"id"`
+
+	// Timestamp is when the revision was applied.
+	Timestamp time.Time `json:"timestamp"`
+
+	ServerHostName string `json:"server-hostname"`
+
+	// TOConfigUpdateTime is the config update time of the server in Traffic Ops
+	// which this revision applied, if any. This is the Traffic Ops revision
+	// the files were generated from.
+	TOConfigUpdateTime *time.Time `json:"to-config-update-time"`
+
+	// TORevalUpdateTime is the revalidate update time of the server in Traffic
+	// Ops which this revision applied, if any.
+	TORevalUpdateTime *time.Time `json:"to-reval-update-time"`
+
+	// RollbackOf is the revision this revision restored, if it was created by
+	// a rollback rather than by applying config from Traffic Ops.
+	RollbackOf *int `json:"rollback-of"`
+
+	// ServiceAction is the reload or restart of the caching proxy service
+	// performed after the files were written, or empty if neither was.
+	ServiceAction ServiceNeeds `json:"service-action"`
+
+	// ChangedFiles is the paths of the files changed on disk from the
+	// previous state.
+	ChangedFiles []string `json:"changed-files"`
+
+	// Files is every config file of the revision.
+	Files []ConfigRevisionFile `json:"files"`
+
+	bodies map[string][]byte // file contents to store, by hash
+}
+
+// ConfigRevisionFile is a config file of a ConfigRevision.
+type ConfigRevisionFile struct {
+	Path string      `json:"path"`
+	Hash string      `json:"sha256"`
+	Mode os.FileMode `json:"mode"`
+	UID  int         `json:"uid"`
+	GID  int         `json:"gid"`
+}
+
+// NewConfigRevision creates a new, empty ConfigRevision.
+// Always use NewConfigRevision, don't use a literal to construct a new object.
+func NewConfigRevision() *ConfigRevision {
+	return &ConfigRevision{
+		Version:      RevisionVersion,
+		Timestamp:    time.Now(),
+		ChangedFiles: []string{}, // construct a slice, so JSON serializes '[]' not 'null'.
+		Files:        []ConfigRevisionFile{},
+		bodies:       map[string][]byte{},
+	}
+}
+
+// NewRollbackRevision creates a new revision with the files of the given
+// revision, for recording a rollback to it.
+func NewRollbackRevision(target *ConfigRevision) *ConfigRevision {
+	rev := NewConfigRevision()
+	rev.ServerHostName = target.ServerHostName
+	rev.TOConfigUpdateTime = target.TOConfigUpdateTime
+	rev.TORevalUpdateTime = target.TORevalUpdateTime
+	rollbackOf := target.Revision
+	rev.RollbackOf = &rollbackOf
+	rev.Files = append(rev.Files, target.Files...)
+	return rev
+}
+
+// AddFile adds a file with the given path, contents, and ownership to the revision.
+func (rev *ConfigRevision) AddFile(path string, body []byte, mode os.FileMode, uid int, gid int) {
+	hash := RevisionHash(body)
+	rev.bodies[hash] = body
+	rev.Files = append(rev.Files, ConfigRevisionFile{Path: path, Hash: hash, Mode: mode.Perm(), UID: uid, GID: gid})
+}
+
+// File returns the file of the revision with the given path, and whether it exists.
+func (rev *ConfigRevision) File(path string) (ConfigRevisionFile, bool) {
+	for _, file := range rev.Files {
+		if file.Path == path {
+			return file, true
+		}
+	}
+	return ConfigRevisionFile{}, false
+}
+
+// RevisionHash returns the hex SHA-256 hash of the given file contents, which
+// is the address they are stored at.
+func RevisionHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// revisionID returns the ID of a revision with the given files, which is the
+// same for any two revisions with the same file paths and contents.
+func revisionID(files []ConfigRevisionFile) string {
+	lines := make([]string, 0, len(files))
+	for _, file := range files {
+		lines = append(lines, file.Path+"\x00"+file.Hash+"\n")
+	}
+	sort.Strings(lines)
+	return RevisionHash([]byte(strings.Join(lines, "")))
+}
+
+// RevisionStore is a directory of config revisions.
+//
+// Each revision's manifest is stored as '<revision>.json' in the directory,
+// and file contents are stored in 'objects/<sha256>'.
+type RevisionStore struct {
+	Dir string
+}
+
+// Add stores the given revision, with the next revision number.
+// The revision's Revision and ID are set.
+func (st RevisionStore) Add(rev *ConfigRevision) error {
+	objDir := filepath.Join(st.Dir, revisionObjectDir)
+	if err := os.MkdirAll(objDir, revisionDirMode); err != nil {
+		return errors.New("creating revision directory: " + err.Error())
+	}
+	for _, file := range rev.Files {
+		objPath := filepath.Join(objDir, file.Hash)
+		if _, err := os.Stat(objPath); err == nil {
+			continue // content-addressed, so an existing object already has this content
+		}
+		body, ok := rev.bodies[file.Hash]
+		if !ok {
+			return errors.New("no stored contents for revision file '" + file.Path + "'")
+		}
+		if err := writeFileAtomic(objPath, body); err != nil {
+			return errors.New("writing revision object: " + err.Error())
+		}
+	}
+
+	revs, err := st.revisionNumbers()
+	if err != nil {
+		return err
+	}
+	rev.Revision = 1
+	if len(revs) > 0 {
+		rev.Revision = revs[len(revs)-1] + 1
+	}
+	sort.Slice(rev.Files, func(i, j int) bool { return rev.Files[i].Path < rev.Files[j].Path })
+	sort.Strings(rev.ChangedFiles)
+	rev.ID = revisionID(rev.Files)
+
+	bts, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return errors.New("marshalling revision: " + err.Error())
+	}
+	bts = append(bts, '\n')
+	if err := writeFileAtomic(st.manifestPath(rev.Revision), bts); err != nil {
+		return errors.New("writing revision manifest: " + err.Error())
+	}
+	return nil
+}
+
+// Get returns the revision with the given number.
+func (st RevisionStore) Get(revision int) (*ConfigRevision, error) {
+	bts, err := os.ReadFile(st.manifestPath(revision))
+	if err != nil {
+		return nil, fmt.Errorf("reading revision %d: %w", revision, err)
+	}
+	rev := &ConfigRevision{}
+	if err := json.Unmarshal(bts, rev); err != nil {
+		return nil, fmt.Errorf("unmarshalling revision %d: %w", revision, err)
+	}
+	return rev, nil
+}
+
+// Latest returns the newest revision, or nil if there are no revisions.
+func (st RevisionStore) Latest() (*ConfigRevision, error) {
+	revs, err := st.revisionNumbers()
+	if err != nil || len(revs) == 0 {
+		return nil, err
+	}
+	return st.Get(revs[len(revs)-1])
+}
+
+// List returns all revisions, oldest first.
+func (st RevisionStore) List() ([]*ConfigRevision, error) {
+	revs, err := st.revisionNumbers()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*ConfigRevision, 0, len(revs))
+	for _, num := range revs {
+		rev, err := st.Get(num)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, rev)
+	}
+	return list, nil
+}
+
+// ReadFile returns the stored contents of the given revision file.
+func (st RevisionStore) ReadFile(file ConfigRevisionFile) ([]byte, error) {
+	body, err := os.ReadFile(filepath.Join(st.Dir, revisionObjectDir, file.Hash))
+	if err != nil {
+		return nil, errors.New("reading revision object for '" + file.Path + "': " + err.Error())
+	}
+	if RevisionHash(body) != file.Hash {
+		return nil, errors.New("revision object for '" + file.Path + "' is corrupt: content does not match its hash")
+	}
+	return body, nil
+}
+
+// Prune removes the oldest revisions, until at most max remain, and then
+// removes any stored file contents no longer used by a revision.
+// If max is not positive, nothing is removed.
+func (st RevisionStore) Prune(max int) error {
+	if max <= 0 {
+		return nil
+	}
+	revs, err := st.revisionNumbers()
+	if err != nil {
+		return err
+	}
+	if len(revs) <= max {
+		return nil
+	}
+	for _, num := range revs[:len(revs)-max] {
+		if err := os.Remove(st.manifestPath(num)); err != nil {
+			return fmt.Errorf("removing revision %d: %w", num, err)
+		}
+	}
+
+	used := map[string]struct{}{}
+	for _, num := range revs[len(revs)-max:] {
+		rev, err := st.Get(num)
+		if err != nil {
+			return err
+		}
+		for _, file := range rev.Files {
+			used[file.Hash] = struct{}{}
+		}
+	}
+	objDir := filepath.Join(st.Dir, revisionObjectDir)
+	objs, err := os.ReadDir(objDir)
+	if err != nil {
+		return errors.New("reading revision objects: " + err.Error())
+	}
+	for _, obj := range objs {
+		if _, ok := used[obj.Name()]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(objDir, obj.Name())); err != nil {
+			return errors.New("removing unused revision object: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func (st RevisionStore) manifestPath(revision int) string {
+	return filepath.Join(st.Dir, strconv.Itoa(revision)+revisionManifestSuffix)
+}
+
+// revisionNumbers returns the numbers of all stored revisions, in ascending order.
+func (st RevisionStore) revisionNumbers() ([]int, error) {
+	entries, err := os.ReadDir(st.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.New("reading revision directory: " + err.Error())
+	}
+	revs := []int{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, revisionManifestSuffix) {
+			continue
+		}
+		num, err := strconv.Atoi(strings.TrimSuffix(name, revisionManifestSuffix))
+		if err != nil {
+			continue
+		}
+		revs = append(revs, num)
+	}
+	sort.Ints(revs)
+	return revs, nil
+}
+
+// writeFileAtomic writes a file to a temp file and then moves it to path,
+// because moving is atomic but writing is not.
+func writeFileAtomic(path string, body []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, body, revisionFileMode); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
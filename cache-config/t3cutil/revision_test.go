@@ -0,0 +1,122 @@
+package t3cutil
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRevisionStore(t *testing.T) {
+	store := RevisionStore{Dir: t.TempDir()}
+
+	if latest, err := store.Latest(); err != nil || latest != nil {
+		t.Fatalf("expected no latest revision in an empty store, actual %+v error %v", latest, err)
+	}
+
+	first := NewConfigRevision()
+	first.AddFile("/etc/trafficserver/remap.config", []byte("map a b\n"), 0644, 1, 2)
+	first.AddFile("/etc/trafficserver/records.config", []byte("CONFIG foo INT 1\n"), 0600, 1, 2)
+	if err := store.Add(first); err != nil {
+		t.Fatalf("adding first revision: %v", err)
+	}
+
+	second := NewConfigRevision()
+	second.AddFile("/etc/trafficserver/remap.config", []byte("map a c\n"), 0644, 1, 2)
+	second.AddFile("/etc/trafficserver/records.config", []byte("CONFIG foo INT 1\n"), 0600, 1, 2)
+	second.ChangedFiles = []string{"/etc/trafficserver/remap.config"}
+	second.ServiceAction = ServiceNeedsReload
+	if err := store.Add(second); err != nil {
+		t.Fatalf("adding second revision: %v", err)
+	}
+
+	if first.Revision != 1 || second.Revision != 2 {
+		t.Errorf("expected revisions 1 and 2, actual %d and %d", first.Revision, second.Revision)
+	}
+	if first.ID == second.ID {
+		t.Errorf("expected revisions with different files to have different IDs, both were %s", first.ID)
+	}
+
+	objs, err := os.ReadDir(filepath.Join(store.Dir, revisionObjectDir))
+	if err != nil {
+		t.Fatalf("reading objects: %v", err)
+	}
+	if len(objs) != 3 {
+		t.Errorf("expected the unchanged file to be stored once for 3 objects, actual %d", len(objs))
+	}
+
+	latest, err := store.Latest()
+	if err != nil {
+		t.Fatalf("getting latest revision: %v", err)
+	}
+	if latest.Revision != 2 || latest.ServiceAction != ServiceNeedsReload || len(latest.ChangedFiles) != 1 {
+		t.Errorf("expected latest revision 2 with a reload and 1 changed file, actual %+v", latest)
+	}
+	file, ok := latest.File("/etc/trafficserver/remap.config")
+	if !ok {
+		t.Fatal("expected latest revision to have remap.config")
+	}
+	if body, err := store.ReadFile(file); err != nil || string(body) != "map a c\n" {
+		t.Errorf("expected remap.config of the latest revision to be 'map a c', actual '%s' error %v", body, err)
+	}
+
+	rollback := NewRollbackRevision(first)
+	if err := store.Add(rollback); err != nil {
+		t.Fatalf("adding rollback revision: %v", err)
+	}
+	if rollback.Revision != 3 || rollback.ID != first.ID || rollback.RollbackOf == nil || *rollback.RollbackOf != 1 {
+		t.Errorf("expected rollback revision 3 with the same ID as revision 1, actual %+v", rollback)
+	}
+
+	if err := store.Prune(1); err != nil {
+		t.Fatalf("pruning: %v", err)
+	}
+	revs, err := store.List()
+	if err != nil {
+		t.Fatalf("listing revisions: %v", err)
+	}
+	if len(revs) != 1 || revs[0].Revision != 3 {
+		t.Fatalf("expected only revision 3 after pruning, actual %d revisions", len(revs))
+	}
+	objs, err = os.ReadDir(filepath.Join(store.Dir, revisionObjectDir))
+	if err != nil {
+		t.Fatalf("reading objects: %v", err)
+	}
+	if len(objs) != 2 {
+		t.Errorf("expected objects unused after pruning to be removed leaving 2, actual %d", len(objs))
+	}
+}
+
+func TestRevisionStoreReadFileCorrupt(t *testing.T) {
+	store := RevisionStore{Dir: t.TempDir()}
+	rev := NewConfigRevision()
+	rev.AddFile("/etc/trafficserver/remap.config", []byte("map a b\n"), 0644, 0, 0)
+	if err := store.Add(rev); err != nil {
+		t.Fatalf("adding revision: %v", err)
+	}
+	file := rev.Files[0]
+	if err := os.WriteFile(filepath.Join(store.Dir, revisionObjectDir, file.Hash), []byte("map a evil\n"), 0600); err != nil {
+		t.Fatalf("corrupting object: %v", err)
+	}
+	if _, err := store.ReadFile(file); err == nil {
+		t.Error("expected an error reading a corrupt object, actual nil")
+	}
+}
@@ -375,6 +375,118 @@ func PackagesToMetaData(pkg map[string]bool) []string {
 	return pkgs
 }
 
+// ApplyPlanVersion is the version of the plan document produced by t3c-apply --plan.
+// This should update the major version with breaking changes, so tools consuming
+// plans can reject documents they don't understand.
+const ApplyPlanVersion = "1.0"
+
+// ApplyPlan is a description of everything a t3c-apply run would change,
+// produced when t3c-apply is run with --plan instead of making any changes.
+// Always use NewApplyPlan, don't use a literal to construct a new object.
+type ApplyPlan struct {
+	// Version is the version of this plan document. See ApplyPlanVersion.
+	Version string `json:"version"`
+
+	// ServerHostName is the host name of the server this plan is for.
+	ServerHostName string `json:"server-hostname"`
+
+	// Files is every config file t3c-apply generated, whether or not it would be changed on disk.
+	Files []ApplyPlanFile `json:"files"`
+
+	// Service is what t3c-apply would do to the cache service and other system services.
+	Service ApplyPlanService `json:"service"`
+
+	// Packages is which packages t3c-apply would install and remove.
+	// Note packages are only installed or removed with --install-packages.
+	Packages ApplyPlanPackages `json:"packages"`
+
+	// UpdateStatus is the change t3c-apply would make to the server's update flags in Traffic Ops.
+	UpdateStatus ApplyPlanUpdateStatus `json:"update-status"`
+}
+
+// ApplyPlanFile is the plan for a single config file.
+type ApplyPlanFile struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Service string `json:"service"`
+
+	// ChangeNeeded is whether the file on disk differs from the generated file.
+	ChangeNeeded bool `json:"change-needed"`
+
+	// Apply is whether t3c-apply would write the file.
+	// A file may need changed but not be applied, in which case SkipReason says why.
+	Apply bool `json:"apply"`
+
+	SkipReason string `json:"skip-reason,omitempty"`
+
+	// Diff is the unified diff produced by t3c-diff, between the file on disk and the generated file.
+	Diff string `json:"diff,omitempty"`
+
+	// AuditError is the error verifying the file, if any. Files which fail their audit are never applied.
+	AuditError string `json:"audit-error,omitempty"`
+
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ApplyPlanService is the plan for the cache service and other system services.
+type ApplyPlanService struct {
+	// Name is the cache service, e.g. trafficserver or varnish.
+	Name string `json:"name"`
+
+	// Needs is whether t3c-check-reload says the changed files need a reload or a restart,
+	// or "none" if they need neither.
+	Needs string `json:"needs"`
+
+	// Action is what t3c-apply would do to the cache service given Needs and --service-action:
+	// one of "none", "reload", "restart", or "start".
+	Action string `json:"action"`
+
+	HitchReload  bool `json:"hitch-reload"`
+	NtpdRestart  bool `json:"ntpd-restart"`
+	SysCtlReload bool `json:"sysctl-reload"`
+}
+
+// ApplyPlanPackages is the plan for packages.
+type ApplyPlanPackages struct {
+	Install []string `json:"install"`
+	Remove  []string `json:"remove"`
+}
+
+// ApplyPlanUpdateStatus is the plan for the server's update flags in Traffic Ops.
+type ApplyPlanUpdateStatus struct {
+	// Flag is the flag this run is concerned with: "update" or "reval".
+	Flag string `json:"flag"`
+
+	// UpdatePending and RevalPending are the current values of the flags in Traffic Ops.
+	UpdatePending bool `json:"update-pending"`
+	RevalPending  bool `json:"reval-pending"`
+
+	// Unset is whether t3c-apply would unset Flag in Traffic Ops.
+	Unset bool `json:"unset"`
+}
+
+// NewApplyPlan creates a new, empty ApplyPlan object.
+func NewApplyPlan() *ApplyPlan {
+	return &ApplyPlan{
+		Version: ApplyPlanVersion,
+		Files:   []ApplyPlanFile{}, // construct a slice, so JSON serializes '[]' not 'null'.
+		Service: ApplyPlanService{Needs: "none", Action: "none"},
+		Packages: ApplyPlanPackages{
+			Install: []string{}, // construct a slice, so JSON serializes '[]' not 'null'.
+			Remove:  []string{}, // construct a slice, so JSON serializes '[]' not 'null'.
+		},
+	}
+}
+
+// Format prints the ApplyPlan as pretty-printed JSON, with a trailing newline.
+func (pl *ApplyPlan) Format() ([]byte, error) {
+	bts, err := json.MarshalIndent(pl, "", "  ")
+	if err != nil {
+		return nil, errors.New("marshalling plan: " + err.Error())
+	}
+	return append(bts, '\n'), nil
+}
+
 // CombineOwnedFilePaths combines the owned file paths of two metadata objects.
 //
 // This is primarily useful when a config run, such as revalidate, adds owned files, but not
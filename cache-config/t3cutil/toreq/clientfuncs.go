@@ -399,6 +399,31 @@ func (cl *TOClient) GetTopologies(reqHdr http.Header) ([]tc.TopologyV5, toclient
 	return topologies, reqInf, nil
 }
 
+// GetCacheGroupLatencies returns the latencies between cachegroups and their parent cachegroups.
+// Older Traffic Ops don't support them, and an error is returned.
+func (cl *TOClient) GetCacheGroupLatencies() ([]tc.CacheGroupLatency, toclientlib.ReqInf, error) {
+	if cl.c == nil {
+		return nil, toclientlib.ReqInf{}, errors.New("getting cachegroup latencies: not supported by Traffic Ops API versions before 5")
+	}
+
+	latencies := []tc.CacheGroupLatency{}
+	reqInf := toclientlib.ReqInf{}
+	err := torequtil.GetRetry(cl.NumRetries, "cachegroup_latencies", &latencies, func(obj interface{}) error {
+		toLatencies, toReqInf, err := cl.c.GetCacheGroupLatencies(*ReqOpts(nil))
+		if err != nil {
+			return errors.New("getting cachegroup latencies from Traffic Ops '" + torequtil.MaybeIPStr(reqInf.RemoteAddr) + "': " + err.Error())
+		}
+		latencies := obj.(*[]tc.CacheGroupLatency)
+		*latencies = toLatencies.Response
+		reqInf = toReqInf
+		return nil
+	})
+	if err != nil {
+		return nil, reqInf, errors.New("getting cachegroup latencies: " + err.Error())
+	}
+	return latencies, reqInf, nil
+}
+
 func (cl *TOClient) GetConfigFileParameters(configFile string, reqHdr http.Header) ([]tc.ParameterV5, toclientlib.ReqInf, error) {
 	if cl.c == nil {
 		return cl.old.GetConfigFileParameters(configFile, reqHdr)
@@ -33,10 +33,12 @@ import (
 
 type Cfg struct {
 	Port                  uint     `json:"port"`
+	DNSPort               uint     `json:"dns_port"` // if 0, DNS is not served
 	Monitors              []*URL   `json:"monitors"`
 	ReqTimeout            Duration `json:"request_timeout_ms"`
 	CRConfigInterval      Duration `json:"crconfig_poll_interval_ms"`
 	CRStatesInterval      Duration `json:"crstates_poll_interval_ms"`
+	SteeringInterval      Duration `json:"steering_poll_interval_ms"` // if 0, DefaultSteeringInterval
 	CDN                   string   `json:"cdn"`
 	TrafficOpsURI         *URL     `json:"traffic_ops_uri"`
 	TrafficOpsUser        string   `json:"traffic_ops_user"`
@@ -45,9 +47,14 @@ type Cfg struct {
 	TrafficOpsClientCache bool     `json:"traffic_ops_client_cache"`
 	TrafficOpsTimeout     Duration `json:"traffic_ops_timeout_ms"`
 	CoverageZoneFile      string   `json:"coverage_zone_file"`
+	// GeolocationFile is the MaxMind GeoIP2 or GeoLite2 database used to locate clients which aren't in the coverage zone. If empty, clients not in the coverage zone are routed to the default location.
+	GeolocationFile string `json:"geolocation_file"`
 	LogLocations
 }
 
+// DefaultSteeringInterval is the interval to poll Traffic Ops for steering, if none is configured.
+const DefaultSteeringInterval = Duration(time.Minute)
+
 func Load(filename string) (Cfg, error) {
 	b, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -58,6 +65,9 @@ func Load(filename string) (Cfg, error) {
 	if err := json.Unmarshal(b, &cfg); err != nil {
 		return Cfg{}, errors.New("parsing " + filename + ": " + err.Error())
 	}
+	if cfg.SteeringInterval == 0 {
+		cfg.SteeringInterval = DefaultSteeringInterval
+	}
 	return cfg, nil
 }
 
@@ -0,0 +1,75 @@
+package consistenthash
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+// Package consistenthash orders objects by weighted rendezvous hashing.
+//
+// Every object's score for a key is computed from the hash of the object's identifier and the key, and objects are ordered by score. Thus the same key consistently gets the same order, and removing an object only changes the order of keys which had it first. Weighted objects are chosen first in proportion to their weights.
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// Order returns the given objects ordered by their weighted rendezvous hash with the given key. The id func returns the identifier to hash each object with, and the weight func returns each object's weight. Objects with a weight of 0 or less are ordered after all others, by their unweighted hash.
+func Order[T any](objs []T, key string, id func(T) string, weight func(T) float64) []T {
+	type scored struct {
+		score float64
+		obj   T
+	}
+	scoredObjs := make([]scored, 0, len(objs))
+	for _, obj := range objs {
+		scoredObjs = append(scoredObjs, scored{score: Score(id(obj), key, weight(obj)), obj: obj})
+	}
+	sort.SliceStable(scoredObjs, func(i, j int) bool { return scoredObjs[i].score > scoredObjs[j].score })
+	ordered := make([]T, 0, len(scoredObjs))
+	for _, so := range scoredObjs {
+		ordered = append(ordered, so.obj)
+	}
+	return ordered
+}
+
+// Unweighted is a weight func for Order which weights all objects equally.
+func Unweighted[T any](T) float64 { return 1 }
+
+// Score returns the weighted rendezvous hash score of the object with the given identifier and weight, for the given key. Higher scores are preferred.
+func Score(id string, key string, weight float64) float64 {
+	// map the hash to (0,1), so -weight/ln(u) is in (0,inf)
+	u := (float64(Hash(id, key)>>11) + 0.5) / (1 << 53)
+	if weight <= 0 {
+		return -1 / (1 - u) // in (-inf,-1), after all weighted objects
+	}
+	return -weight / math.Log(u)
+}
+
+// Hash returns the rendezvous hash of the given object identifier and key.
+func Hash(id string, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	// FNV alone distributes similar inputs poorly, so mix it with the SplitMix64 finalizer.
+	x := h.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
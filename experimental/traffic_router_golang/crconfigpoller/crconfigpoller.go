@@ -31,7 +31,9 @@ import (
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/cgsrch"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/crconfig"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/crconfigregex"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/dnszone"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/fetch"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/geolimit"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/nextcache"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-tc"
@@ -83,8 +85,10 @@ func createNextCacher(crc *tc.CRConfig) nextcache.NextCacher {
 }
 
 // TODO implement HTTP poller
-func Start(fetcher fetch.Fetcher, interval time.Duration) (crconfig.Ths, crconfigregex.Ths, cgsrch.Ths, nextcache.Ths, error) {
+func Start(fetcher fetch.Fetcher, interval time.Duration) (crconfig.Ths, crconfigregex.Ths, cgsrch.Ths, nextcache.Ths, dnszone.Ths, geolimit.Ths, error) {
 	thsCrcRgx := crconfigregex.NewThs()
+	thsZone := dnszone.NewThs()
+	thsGeoLimits := geolimit.NewThs()
 	thsCrc := crconfig.NewThs()
 	thsCGSearcher := cgsrch.NewThs()
 	thsNextCacher := nextcache.NewThs()
@@ -125,7 +129,15 @@ func Start(fetcher fetch.Fetcher, interval time.Duration) (crconfig.Ths, crconfi
 			fmt.Println("ERROR not using invalid new CRConfig: failed to create Cachegroup searcher: " + err.Error())
 		}
 		nextCacher := createNextCacher(crc)
+		zone, err := dnszone.Get(crc)
+		if err != nil {
+			fmt.Println("ERROR not using invalid new CRConfig: failed to create DNS zone: " + err.Error())
+			return
+		}
+		geoLimits := geolimit.Get(crc)
 
+		thsGeoLimits.Set(&geoLimits)
+		thsZone.Set(zone)
 		thsNextCacher.Set(nextCacher)
 		thsCGSearcher.Set(cgSearcher)
 		thsCrc.Set(crc)
@@ -143,5 +155,5 @@ func Start(fetcher fetch.Fetcher, interval time.Duration) (crconfig.Ths, crconfi
 			get()
 		}
 	}()
-	return thsCrc, thsCrcRgx, thsCGSearcher, thsNextCacher, nil
+	return thsCrc, thsCrcRgx, thsCGSearcher, thsNextCacher, thsZone, thsGeoLimits, nil
 }
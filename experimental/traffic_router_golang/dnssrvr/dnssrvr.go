@@ -0,0 +1,295 @@
+package dnssrvr
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+import (
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/availableservers"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/cgsrch"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/consistenthash"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/dnszone"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/geolimit"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/geolocation"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/httpsrvr"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+
+	"github.com/miekg/dns"
+)
+
+// EDNSUDPSize is the maximum UDP response size advertised to EDNS clients.
+const EDNSUDPSize = 1232
+
+type handler struct {
+	zones      dnszone.Ths
+	availSrvrs availableservers.AvailableServers
+	cgSrchThs  cgsrch.Ths
+	locator    geolocation.Locator
+	geoLimits  geolimit.Ths
+}
+
+func (h handler) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	resp := h.answer(req, clientIP(w.RemoteAddr()))
+	if _, isUDP := w.RemoteAddr().(*net.UDPAddr); isUDP {
+		maxSize := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil {
+			maxSize = int(opt.UDPSize())
+			if maxSize > EDNSUDPSize {
+				maxSize = EDNSUDPSize
+			}
+		}
+		resp.Truncate(maxSize)
+	}
+	if err := w.WriteMsg(resp); err != nil {
+		log.Errorln("DNS writing response to " + w.RemoteAddr().String() + ": " + err.Error())
+	}
+}
+
+// answer returns the response to the given request from the given client.
+func (h handler) answer(req *dns.Msg, client net.IP) *dns.Msg {
+	resp := &dns.Msg{}
+	resp.SetReply(req)
+
+	reqOpt := req.IsEdns0()
+	if reqOpt != nil {
+		if reqOpt.Version() != 0 {
+			resp.SetEdns0(EDNSUDPSize, false)
+			resp.Rcode = dns.RcodeBadVers // packed into the OPT extended RCODE
+			return resp
+		}
+		resp.SetEdns0(EDNSUDPSize, false)
+	}
+
+	if req.Opcode != dns.OpcodeQuery {
+		resp.Rcode = dns.RcodeNotImplemented
+		return resp
+	}
+	if len(req.Question) != 1 {
+		resp.Rcode = dns.RcodeFormatError
+		return resp
+	}
+	q := req.Question[0]
+	name := dnszone.Name(q.Name)
+
+	zone := (*dnszone.Zone)(h.zones.Get())
+	if zone == nil {
+		log.Errorln("DNS request for '" + name + "' from " + client.String() + " before any CRConfig was loaded, returning SERVFAIL")
+		resp.Rcode = dns.RcodeServerFailure
+		return resp
+	}
+	if q.Qclass != dns.ClassINET || !dns.IsSubDomain(zone.Domain, name) {
+		resp.Rcode = dns.RcodeRefused
+		return resp
+	}
+	resp.Authoritative = true
+
+	if ds, ok := zone.DeliveryServices[name]; ok {
+		routeIP := client
+		if subnet := clientSubnet(reqOpt); ds.ECS && subnet != nil {
+			routeIP = subnet.Address
+			subnet.SourceScope = subnet.SourceNetmask
+			resp.IsEdns0().Option = append(resp.IsEdns0().Option, subnet)
+		}
+		answers, err := h.route(zone, ds, q, routeIP)
+		if err == errGeoLimited {
+			log.Infoln("DNS request for '" + name + "' from " + routeIP.String() + " denied by ds '" + string(ds.Name) + "' geo limit, returning NXDOMAIN")
+			resp.Rcode = dns.RcodeNameError
+			resp.Ns = []dns.RR{negativeSOA(zone)}
+			return resp
+		}
+		if err != nil {
+			log.Errorln("DNS request for '" + name + "' from " + client.String() + " ds '" + string(ds.Name) + "': " + err.Error() + ", returning SERVFAIL")
+			resp.Rcode = dns.RcodeServerFailure
+			return resp
+		}
+		log.Debugf("DNS request for '%v' type %v from %v ds '%v' routed to %v\n", name, dns.TypeToString[q.Qtype], routeIP, ds.Name, answers)
+		resp.Answer = answers
+		if len(answers) == 0 {
+			resp.Ns = []dns.RR{zone.SOA}
+		}
+		return resp
+	}
+
+	if name == zone.Domain {
+		switch q.Qtype {
+		case dns.TypeSOA:
+			resp.Answer = []dns.RR{zone.SOA}
+			resp.Ns = zone.NS
+			return resp
+		case dns.TypeNS:
+			resp.Answer = zone.NS
+			for _, ns := range zone.NS {
+				resp.Extra = append(resp.Extra, zone.Glue[ns.(*dns.NS).Ns]...)
+			}
+			return resp
+		}
+	}
+
+	if records, ok := zone.Records[name]; ok {
+		if cname, ok := records[dns.TypeCNAME]; ok && q.Qtype != dns.TypeCNAME {
+			resp.Answer = cname // the CNAME target may not be in the zone, so resolvers must chase it
+			return resp
+		}
+		resp.Answer = records[q.Qtype]
+	}
+	if len(resp.Answer) == 0 {
+		if !zone.Exists(name) {
+			resp.Rcode = dns.RcodeNameError
+		}
+		resp.Ns = []dns.RR{negativeSOA(zone)}
+	}
+	return resp
+}
+
+// negativeSOA returns the SOA record for the authority section of negative responses.
+func negativeSOA(zone *dnszone.Zone) dns.RR {
+	soa := dns.Copy(zone.SOA)
+	soa.Header().Ttl = zone.NegativeTTL()
+	return soa
+}
+
+// errGeoLimited is returned by route when the client may not be routed, per the Delivery Service geo limit.
+var errGeoLimited = errors.New("denied by geo limit")
+
+// route returns the addresses of the caches to send the client at the given IP to, for the given DNS-routed Delivery Service.
+// This returns no records and no error if the request isn't for addresses the Delivery Service can be routed to, for which a NODATA response must be returned.
+func (h handler) route(zone *dnszone.Zone, ds dnszone.DeliveryService, q dns.Question, ip net.IP) ([]dns.RR, error) {
+	if q.Qtype != dns.TypeA && !(q.Qtype == dns.TypeAAAA && ds.IP6) {
+		return nil, nil
+	}
+
+	limit := geolimit.Limit{}
+	if geoLimits := h.geoLimits.Get(); geoLimits != nil {
+		limit = (*geoLimits)[ds.Name]
+	}
+	loc := geolocation.Location{}
+	if ip != nil {
+		loc = h.locator.Locate(ip)
+	}
+	if !limit.Allowed(loc) {
+		return nil, errGeoLimited
+	}
+	if !loc.Found {
+		log.Infoln("DNS client " + ip.String() + " not located, using default location")
+	}
+	pos := limit.Pos(loc, httpsrvr.DefaultPos)
+
+	cgSrch := h.cgSrchThs.Get()
+	if cgSrch == nil {
+		return nil, errors.New("no cachegroup searcher, CRConfig may have had no cachegroups")
+	}
+	cgDat, ok := cgSrch.Nearest(pos.Lat, pos.Lon)
+	if !ok {
+		return nil, errors.New("no nearest cachegroup (should only happen if there are no cachegroups)")
+	}
+	cg := tc.CacheGroupName(cgDat.Obj)
+
+	srvrs, err := h.availSrvrs.Get(ds.Name, cg)
+	if err != nil {
+		return nil, errors.New("getting available servers in cachegroup '" + string(cg) + "': " + err.Error())
+	}
+
+	answers := []dns.RR{}
+	for _, srvr := range consistentHash(srvrs, zone.Servers, q.Name) {
+		if ds.MaxIPs > 0 && len(answers) >= ds.MaxIPs {
+			break
+		}
+		if q.Qtype == dns.TypeA && srvr.IP != nil && srvr.IP.To4() != nil {
+			answers = append(answers, &dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ds.ATTL}, A: srvr.IP.To4()})
+		} else if q.Qtype == dns.TypeAAAA && srvr.IP6 != nil && srvr.IP6.To4() == nil {
+			answers = append(answers, &dns.AAAA{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: ds.AAAATTL}, AAAA: srvr.IP6})
+		}
+	}
+	if len(answers) == 0 {
+		return nil, errors.New("no available servers in cachegroup '" + string(cg) + "' with " + dns.TypeToString[q.Qtype] + " addresses")
+	}
+	return answers, nil
+}
+
+// consistentHash returns the servers with the given names ordered by their rendezvous hash with the given key. Thus, the same key consistently gets the same servers, and when a server becomes unavailable, only the keys which had it first move.
+func consistentHash(names []tc.CacheName, servers map[tc.CacheName]dnszone.Server, key string) []dnszone.Server {
+	srvrs := make([]dnszone.Server, 0, len(names))
+	for _, name := range names {
+		srvr, ok := servers[name]
+		if !ok {
+			continue // available, but not in the CRConfig yet
+		}
+		srvrs = append(srvrs, srvr)
+	}
+	return consistenthash.Order(srvrs, dnszone.Name(key), func(s dnszone.Server) string { return s.HashID }, consistenthash.Unweighted[dnszone.Server])
+}
+
+// clientSubnet returns a copy of the EDNS Client Subnet option of the given request OPT record, or nil if it has none.
+func clientSubnet(opt *dns.OPT) *dns.EDNS0_SUBNET {
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		if subnet, ok := o.(*dns.EDNS0_SUBNET); ok && subnet.Address != nil {
+			cp := *subnet
+			return &cp
+		}
+	}
+	return nil
+}
+
+func clientIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// Start starts serving DNS on the given port, over UDP and TCP, and returns the servers.
+func Start(
+	zones dnszone.Ths,
+	availableServers availableservers.AvailableServers,
+	cgSrch cgsrch.Ths,
+	locator geolocation.Locator,
+	geoLimits geolimit.Ths,
+	port uint,
+) []*dns.Server {
+	h := handler{zones: zones, availSrvrs: availableServers, cgSrchThs: cgSrch, locator: locator, geoLimits: geoLimits}
+	addr := ":" + strconv.Itoa(int(port))
+	srvrs := []*dns.Server{
+		{Addr: addr, Net: "udp", Handler: h},
+		{Addr: addr, Net: "tcp", Handler: h},
+	}
+	for _, srvr := range srvrs {
+		go func(srvr *dns.Server) {
+			if err := srvr.ListenAndServe(); err != nil {
+				log.Errorln("Serving DNS " + srvr.Net + ": " + err.Error())
+			}
+		}(srvr)
+	}
+	return srvrs
+}
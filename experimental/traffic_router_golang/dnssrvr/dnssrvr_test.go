@@ -0,0 +1,307 @@
+package dnssrvr
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+import (
+	"net"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/availableservers"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/cgsrch"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/coveragezone"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/dnszone"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/geolimit"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/geolocation"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+
+	"github.com/miekg/dns"
+)
+
+// fakeGeolocator locates 203.0.113.0/24 in the US near cg0, and 198.18.0.0/15 in Canada near cg1.
+type fakeGeolocator struct{}
+
+func (fakeGeolocator) Geolocate(ip net.IP) (tc.CRConfigLatitudeLongitude, string, bool) {
+	if _, us, _ := net.ParseCIDR("203.0.113.0/24"); us.Contains(ip) {
+		return tc.CRConfigLatitudeLongitude{Lat: 11, Lon: 11}, "US", true
+	}
+	if _, ca, _ := net.ParseCIDR("198.18.0.0/15"); ca.Contains(ip) {
+		return tc.CRConfigLatitudeLongitude{Lat: 49, Lon: 49}, "CA", true
+	}
+	return tc.CRConfigLatitudeLongitude{}, "", false
+}
+
+func testCRConfig() *tc.CRConfig {
+	online := tc.CRConfigRouterStatus(tc.CacheStatusOnline)
+	date := int64(1500000000)
+	protocolDNS := []*tc.MatchSet{{Protocol: "DNS", MatchList: []tc.MatchList{{Regex: `.*\.dns-ds\..*`, MatchType: "HOST"}}}}
+	protocolHTTP := []*tc.MatchSet{{Protocol: "HTTP", MatchList: []tc.MatchList{{Regex: `.*\.http-ds\..*`, MatchType: "HOST"}}}}
+	return &tc.CRConfig{
+		Config: map[string]interface{}{
+			"domain_name": "cdn.example.net",
+			"soa":         map[string]interface{}{"admin": "traffic_ops", "minimum": "30"},
+			"ttls":        map[string]interface{}{"SOA": "86400"},
+		},
+		ContentRouters: map[string]tc.CRConfigRouter{
+			"tr0": {FQDN: util.StrPtr("tr0.cdn.example.net"), IP: util.StrPtr("192.0.2.53"), IP6: util.StrPtr("2001:db8::53/64"), ServerStatus: &online},
+		},
+		ContentServers: map[string]tc.CRConfigTrafficOpsServer{
+			"edge0": {CacheGroup: util.StrPtr("cg0"), Ip: util.StrPtr("192.0.2.1"), Ip6: util.StrPtr("2001:db8::1/64"), DeliveryServices: map[string][]string{"dns-ds": nil}},
+			"edge1": {CacheGroup: util.StrPtr("cg0"), Ip: util.StrPtr("192.0.2.2"), Ip6: util.StrPtr("2001:db8::2/64"), DeliveryServices: map[string][]string{"dns-ds": nil}},
+			"edge2": {CacheGroup: util.StrPtr("cg1"), Ip: util.StrPtr("198.51.100.1"), DeliveryServices: map[string][]string{"dns-ds": nil}},
+		},
+		DeliveryServices: map[string]tc.CRConfigDeliveryService{
+			"dns-ds": {
+				Domains:              []string{"dns-ds.cdn.example.net"},
+				MatchSets:            protocolDNS,
+				RoutingName:          util.StrPtr("edge"),
+				MaxDNSIPsForLocation: util.IntPtr(1),
+				IP6RoutingEnabled:    util.BoolPtr(true),
+				EcsEnabled:           util.BoolPtr(true),
+				TTLs:                 &tc.CRConfigTTL{ASeconds: util.StrPtr("45"), AAAASeconds: util.StrPtr("")},
+				TTL:                  util.IntPtr(90),
+				MissLocation:         &tc.CRConfigLatitudeLongitudeShort{Lat: 50, Lon: 50},
+			},
+			"czf-ds": {
+				Domains:          []string{"czf-ds.cdn.example.net"},
+				MatchSets:        []*tc.MatchSet{{Protocol: "DNS", MatchList: []tc.MatchList{{Regex: `.*\.czf-ds\..*`, MatchType: "HOST"}}}},
+				CoverageZoneOnly: true,
+			},
+			"geo-ds": {
+				Domains:    []string{"geo-ds.cdn.example.net"},
+				MatchSets:  []*tc.MatchSet{{Protocol: "DNS", MatchList: []tc.MatchList{{Regex: `.*\.geo-ds\..*`, MatchType: "HOST"}}}},
+				GeoEnabled: []tc.CRConfigGeoEnabled{{CountryCode: "us"}},
+			},
+			"http-ds": {
+				Domains:   []string{"http-ds.cdn.example.net"},
+				MatchSets: protocolHTTP,
+				StaticDNSEntries: []tc.CRConfigStaticDNSEntry{
+					{Name: "static", TTL: 300, Type: "A_RECORD", Value: "203.0.113.1"},
+					{Name: "alias", TTL: 300, Type: "CNAME_RECORD", Value: "origin.example.com."},
+				},
+			},
+		},
+		EdgeLocations: map[string]tc.CRConfigLatitudeLongitude{
+			"cg0": {Lat: 10, Lon: 10},
+			"cg1": {Lat: 50, Lon: 50},
+		},
+		Stats: tc.CRConfigStats{DateUnixSeconds: &date},
+	}
+}
+
+func testHandler(t *testing.T) handler {
+	crc := testCRConfig()
+	zone, err := dnszone.Get(crc)
+	if err != nil {
+		t.Fatalf("creating zone: %v", err)
+	}
+	zones := dnszone.NewThs()
+	zones.Set(zone)
+
+	cgSrch, err := cgsrch.Create(crc)
+	if err != nil {
+		t.Fatalf("creating cachegroup searcher: %v", err)
+	}
+	cgSrchThs := cgsrch.NewThs()
+	cgSrchThs.Set(cgSrch)
+
+	cz, err := coveragezone.New(coveragezone.JSONCoverageZones{CoverageZones: map[tc.CacheGroupName]coveragezone.JSONCoverageZoneCacheGroup{
+		"cg0": {Coordinates: tc.CRConfigLatitudeLongitude{Lat: 10, Lon: 10}, Network: []string{"10.0.0.0/8"}},
+		"cg1": {Coordinates: tc.CRConfigLatitudeLongitude{Lat: 50, Lon: 50}, Network: []string{"172.16.0.0/12"}},
+	}})
+	if err != nil {
+		t.Fatalf("creating coverage zone: %v", err)
+	}
+
+	availSrvrs := availableservers.New()
+	availSrvrs.Set(availableservers.AvailableServersMap{
+		"dns-ds": {
+			"cg0": {"edge0", "edge1"},
+			"cg1": {"edge2"},
+		},
+		"czf-ds": {"cg0": {"edge0"}, "cg1": {"edge2"}},
+		"geo-ds": {"cg0": {"edge0"}, "cg1": {"edge2"}},
+	})
+
+	geoLimits := geolimit.Get(crc)
+	geoLimitsThs := geolimit.NewThs()
+	geoLimitsThs.Set(&geoLimits)
+
+	locator := geolocation.Locator{CZ: cz, Geo: fakeGeolocator{}}
+	return handler{zones: zones, availSrvrs: availSrvrs, cgSrchThs: cgSrchThs, locator: locator, geoLimits: geoLimitsThs}
+}
+
+func query(h handler, name string, qtype uint16, client string) *dns.Msg {
+	req := &dns.Msg{}
+	req.SetQuestion(name, qtype)
+	return h.answer(req, net.ParseIP(client))
+}
+
+func TestRouteDeliveryService(t *testing.T) {
+	h := testHandler(t)
+
+	resp := query(h, "edge.dns-ds.cdn.example.net.", dns.TypeA, "10.1.2.3")
+	if resp.Rcode != dns.RcodeSuccess || !resp.Authoritative {
+		t.Fatalf("expected authoritative success, actual rcode %v authoritative %v", dns.RcodeToString[resp.Rcode], resp.Authoritative)
+	}
+	if len(resp.Answer) != 1 {
+		t.Fatalf("expected maxDnsIpsForLocation 1 answer, actual %v", resp.Answer)
+	}
+	a, ok := resp.Answer[0].(*dns.A)
+	if !ok {
+		t.Fatalf("expected A record, actual %T", resp.Answer[0])
+	}
+	if !a.A.Equal(net.ParseIP("192.0.2.1")) && !a.A.Equal(net.ParseIP("192.0.2.2")) {
+		t.Errorf("expected a cache in the client's cachegroup cg0, actual %v", a.A)
+	}
+	if a.Hdr.Ttl != 45 {
+		t.Errorf("expected delivery service A TTL 45, actual %v", a.Hdr.Ttl)
+	}
+	for i := 0; i < 10; i++ {
+		again := query(h, "edge.dns-ds.cdn.example.net.", dns.TypeA, "10.4.5.6")
+		if len(again.Answer) != 1 || !again.Answer[0].(*dns.A).A.Equal(a.A) {
+			t.Fatalf("expected the same name to consistently hash to %v, actual %v", a.A, again.Answer)
+		}
+	}
+
+	resp = query(h, "EDGE.dns-ds.cdn.example.net.", dns.TypeA, "172.16.0.1")
+	if len(resp.Answer) != 1 || !resp.Answer[0].(*dns.A).A.Equal(net.ParseIP("198.51.100.1")) {
+		t.Errorf("expected a cache in the client's cachegroup cg1, actual %v", resp.Answer)
+	}
+
+	resp = query(h, "edge.dns-ds.cdn.example.net.", dns.TypeAAAA, "10.1.2.3")
+	if len(resp.Answer) != 1 {
+		t.Fatalf("expected 1 AAAA answer, actual %v", resp.Answer)
+	}
+	if aaaa := resp.Answer[0].(*dns.AAAA); aaaa.Hdr.Ttl != 90 || aaaa.AAAA.To4() != nil {
+		t.Errorf("expected an IPv6 answer with the delivery service TTL 90, actual %v", aaaa)
+	}
+
+	resp = query(h, "edge.dns-ds.cdn.example.net.", dns.TypeMX, "10.1.2.3")
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 || len(resp.Ns) != 1 {
+		t.Errorf("expected NODATA with the SOA, actual rcode %v answer %v authority %v", dns.RcodeToString[resp.Rcode], resp.Answer, resp.Ns)
+	}
+}
+
+func TestRouteClientSubnet(t *testing.T) {
+	h := testHandler(t)
+
+	req := &dns.Msg{}
+	req.SetQuestion("edge.dns-ds.cdn.example.net.", dns.TypeA)
+	req.SetEdns0(4096, false)
+	subnet := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("172.16.0.0").To4()}
+	req.IsEdns0().Option = append(req.IsEdns0().Option, subnet)
+
+	resp := h.answer(req, net.ParseIP("10.1.2.3"))
+	if len(resp.Answer) != 1 || !resp.Answer[0].(*dns.A).A.Equal(net.ParseIP("198.51.100.1")) {
+		t.Errorf("expected the client subnet's cachegroup cg1, actual %v", resp.Answer)
+	}
+	respSubnet := clientSubnet(resp.IsEdns0())
+	if respSubnet == nil || respSubnet.SourceScope != 24 {
+		t.Errorf("expected the response to have the client subnet option with scope 24, actual %+v", respSubnet)
+	}
+}
+
+func TestZoneRecords(t *testing.T) {
+	h := testHandler(t)
+
+	resp := query(h, "cdn.example.net.", dns.TypeSOA, "10.1.2.3")
+	if len(resp.Answer) != 1 {
+		t.Fatalf("expected 1 SOA, actual %v", resp.Answer)
+	}
+	soa := resp.Answer[0].(*dns.SOA)
+	if soa.Ns != "tr0.cdn.example.net." || soa.Mbox != "traffic_ops.cdn.example.net." || soa.Serial != 1500000000 || soa.Minttl != 30 || soa.Hdr.Ttl != 86400 {
+		t.Errorf("expected SOA from the CRConfig config, actual %v", soa)
+	}
+
+	resp = query(h, "cdn.example.net.", dns.TypeNS, "10.1.2.3")
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.NS).Ns != "tr0.cdn.example.net." {
+		t.Errorf("expected NS of the router, actual %v", resp.Answer)
+	}
+	if len(resp.Extra) != 2 {
+		t.Errorf("expected A and AAAA glue for the router, actual %v", resp.Extra)
+	}
+
+	resp = query(h, "static.http-ds.cdn.example.net.", dns.TypeA, "10.1.2.3")
+	if len(resp.Answer) != 1 || !resp.Answer[0].(*dns.A).A.Equal(net.ParseIP("203.0.113.1")) || resp.Answer[0].Header().Ttl != 300 {
+		t.Errorf("expected the static DNS entry, actual %v", resp.Answer)
+	}
+
+	resp = query(h, "alias.http-ds.cdn.example.net.", dns.TypeA, "10.1.2.3")
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.CNAME).Target != "origin.example.com." {
+		t.Errorf("expected the static CNAME entry, actual %v", resp.Answer)
+	}
+
+	resp = query(h, "http-ds.cdn.example.net.", dns.TypeA, "10.1.2.3")
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Errorf("expected NODATA for a name with names under it, actual rcode %v answer %v", dns.RcodeToString[resp.Rcode], resp.Answer)
+	}
+
+	resp = query(h, "nonexistent.cdn.example.net.", dns.TypeA, "10.1.2.3")
+	if resp.Rcode != dns.RcodeNameError || len(resp.Ns) != 1 || resp.Ns[0].Header().Ttl != 30 {
+		t.Errorf("expected NXDOMAIN with the SOA at the negative TTL, actual rcode %v authority %v", dns.RcodeToString[resp.Rcode], resp.Ns)
+	}
+
+	resp = query(h, "edge.http-ds.cdn.example.net.", dns.TypeA, "10.1.2.3")
+	if resp.Rcode != dns.RcodeNameError {
+		t.Errorf("expected NXDOMAIN for an HTTP delivery service, actual rcode %v answer %v", dns.RcodeToString[resp.Rcode], resp.Answer)
+	}
+
+	resp = query(h, "www.example.com.", dns.TypeA, "10.1.2.3")
+	if resp.Rcode != dns.RcodeRefused || resp.Authoritative {
+		t.Errorf("expected REFUSED for a name outside the zone, actual rcode %v", dns.RcodeToString[resp.Rcode])
+	}
+}
+
+func expectRoutedTo(t *testing.T, h handler, name string, client string, expected string) {
+	t.Helper()
+	resp := query(h, name, dns.TypeA, client)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 || !resp.Answer[0].(*dns.A).A.Equal(net.ParseIP(expected)) {
+		t.Errorf("expected %v from %v to be routed to %v, actual rcode %v answer %v", name, client, expected, dns.RcodeToString[resp.Rcode], resp.Answer)
+	}
+}
+
+func expectGeoLimited(t *testing.T, h handler, name string, client string) {
+	t.Helper()
+	resp := query(h, name, dns.TypeA, client)
+	if resp.Rcode != dns.RcodeNameError || len(resp.Answer) != 0 {
+		t.Errorf("expected %v from %v to be denied with NXDOMAIN, actual rcode %v answer %v", name, client, dns.RcodeToString[resp.Rcode], resp.Answer)
+	}
+}
+
+func TestRouteGeolocation(t *testing.T) {
+	h := testHandler(t)
+
+	// not in the coverage zone, so geolocated
+	expectRoutedTo(t, h, "edge.dns-ds.cdn.example.net.", "198.18.0.1", "198.51.100.1")
+	// neither in the coverage zone nor geolocated, so routed from the delivery service miss location
+	expectRoutedTo(t, h, "edge.dns-ds.cdn.example.net.", "192.0.2.200", "198.51.100.1")
+
+	expectRoutedTo(t, h, "edge.czf-ds.cdn.example.net.", "10.1.2.3", "192.0.2.1")
+	expectGeoLimited(t, h, "edge.czf-ds.cdn.example.net.", "203.0.113.1")
+	expectGeoLimited(t, h, "edge.czf-ds.cdn.example.net.", "192.0.2.200")
+
+	expectRoutedTo(t, h, "edge.geo-ds.cdn.example.net.", "172.16.0.1", "198.51.100.1")
+	expectRoutedTo(t, h, "edge.geo-ds.cdn.example.net.", "203.0.113.1", "192.0.2.1")
+	expectGeoLimited(t, h, "edge.geo-ds.cdn.example.net.", "198.18.0.1")
+	expectGeoLimited(t, h, "edge.geo-ds.cdn.example.net.", "192.0.2.200")
+}
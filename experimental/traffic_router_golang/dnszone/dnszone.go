@@ -0,0 +1,348 @@
+package dnszone
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultTTL is the TTL, in seconds, of records whose TTL isn't in the CRConfig.
+const DefaultTTL = 60
+
+// DefaultRoutingName is the routing name of DNS Delivery Services which have none in the CRConfig.
+const DefaultRoutingName = "edge"
+
+// Zone is the DNS data of a CDN, built from its CRConfig. The router is authoritative for the CDN domain, and answers requests for DNS-routed Delivery Services, along with the domain's SOA and NS records and the static DNS entries of Delivery Services.
+type Zone struct {
+	// Domain is the fully-qualified lowercase CDN domain.
+	Domain string
+	SOA    *dns.SOA
+	NS     []dns.RR
+	// Glue are the address records of routers whose names are in the Domain, by name.
+	Glue map[string][]dns.RR
+	// Records are the static records in the zone, by name and type. This includes Delivery Service static DNS entries and Glue.
+	Records map[string]map[uint16][]dns.RR
+	// DeliveryServices are the DNS-routed Delivery Services, by the name clients request, which is the routing name followed by the Delivery Service domain.
+	DeliveryServices map[string]DeliveryService
+	// Servers are the addresses of cache servers, for answering requests for DNS-routed Delivery Services.
+	Servers map[tc.CacheName]Server
+	// nonTerminals are names in the zone which have no records, but have names with records under them, for which NODATA rather than NXDOMAIN must be returned.
+	nonTerminals map[string]struct{}
+}
+
+// DeliveryService is the DNS routing data of a DNS-routed Delivery Service.
+type DeliveryService struct {
+	Name tc.DeliveryServiceName
+	// MaxIPs is the maximum number of cache addresses to return. If 0, all the available caches in the Cache Group are returned.
+	MaxIPs int
+	// IP6 is whether to answer AAAA requests with the IPv6 addresses of caches.
+	IP6 bool
+	// ECS is whether to route with the EDNS Client Subnet of requests, if present, rather than the address of the resolver.
+	ECS     bool
+	ATTL    uint32
+	AAAATTL uint32
+}
+
+// Server is the DNS routing data of a cache server.
+type Server struct {
+	IP  net.IP
+	IP6 net.IP
+	// HashID is the identifier used to consistently hash requests to the server.
+	HashID string
+}
+
+// Name returns the fully-qualified lowercase DNS name of s, as used for lookups in a Zone.
+func Name(s string) string {
+	return strings.ToLower(dns.Fqdn(s))
+}
+
+// Exists returns whether the given name has any records in the zone, or names with records under it. The name must be fully-qualified and lowercase.
+func (z *Zone) Exists(name string) bool {
+	if name == z.Domain {
+		return true
+	}
+	if _, ok := z.Records[name]; ok {
+		return true
+	}
+	if _, ok := z.DeliveryServices[name]; ok {
+		return true
+	}
+	_, ok := z.nonTerminals[name]
+	return ok
+}
+
+// NegativeTTL returns the TTL of negative responses, which per RFC2308 is the lesser of the SOA TTL and minimum.
+func (z *Zone) NegativeTTL() uint32 {
+	if z.SOA.Minttl < z.SOA.Hdr.Ttl {
+		return z.SOA.Minttl
+	}
+	return z.SOA.Hdr.Ttl
+}
+
+// Get creates the Zone of the given CRConfig.
+func Get(crc *tc.CRConfig) (*Zone, error) {
+	domainStr, ok := crc.Config["domain_name"].(string)
+	if !ok || domainStr == "" {
+		return nil, errors.New("CRConfig missing config domain_name")
+	}
+	z := &Zone{
+		Domain:           Name(domainStr),
+		Glue:             map[string][]dns.RR{},
+		Records:          map[string]map[uint16][]dns.RR{},
+		DeliveryServices: map[string]DeliveryService{},
+		Servers:          map[tc.CacheName]Server{},
+		nonTerminals:     map[string]struct{}{},
+	}
+	ttls := configStrs(crc.Config, "ttls")
+
+	routerNames := make([]string, 0, len(crc.ContentRouters))
+	for name := range crc.ContentRouters {
+		routerNames = append(routerNames, name)
+	}
+	sort.Strings(routerNames) // sorted, so NS records and the SOA MNAME don't change with map order
+	for _, name := range routerNames {
+		router := crc.ContentRouters[name]
+		if router.ServerStatus != nil && *router.ServerStatus != tc.CRConfigRouterStatus(tc.CacheStatusOnline) {
+			continue
+		}
+		if router.FQDN == nil || *router.FQDN == "" {
+			log.Warnln("CRConfig router '" + name + "' has no FQDN, not adding NS record")
+			continue
+		}
+		fqdn := Name(*router.FQDN)
+		z.NS = append(z.NS, &dns.NS{Hdr: header(z.Domain, dns.TypeNS, ttl(ttls["NS"], nil, DefaultTTL)), Ns: fqdn})
+		if !dns.IsSubDomain(z.Domain, fqdn) {
+			continue
+		}
+		if ip := parseIP(router.IP); ip != nil && ip.To4() != nil {
+			z.Glue[fqdn] = append(z.Glue[fqdn], &dns.A{Hdr: header(fqdn, dns.TypeA, ttl(ttls["A"], nil, DefaultTTL)), A: ip.To4()})
+		}
+		if ip := parseIP(router.IP6); ip != nil && ip.To4() == nil {
+			z.Glue[fqdn] = append(z.Glue[fqdn], &dns.AAAA{Hdr: header(fqdn, dns.TypeAAAA, ttl(ttls["AAAA"], nil, DefaultTTL)), AAAA: ip})
+		}
+		for _, rr := range z.Glue[fqdn] {
+			z.addRecord(rr)
+		}
+	}
+	if len(z.NS) == 0 {
+		return nil, errors.New("CRConfig has no online routers with FQDNs to serve as nameservers")
+	}
+	z.SOA = makeSOA(z.Domain, z.NS[0].(*dns.NS).Ns, configStrs(crc.Config, "soa"), ttl(ttls["SOA"], nil, DefaultTTL), crc.Stats.DateUnixSeconds)
+
+	for name, server := range crc.ContentServers {
+		srv := Server{IP: parseIP(server.Ip), IP6: parseIP(server.Ip6), HashID: name}
+		if server.HashId != nil && *server.HashId != "" {
+			srv.HashID = *server.HashId
+		}
+		z.Servers[tc.CacheName(name)] = srv
+	}
+
+	for dsNameStr, crcDS := range crc.DeliveryServices {
+		if len(crcDS.Domains) == 0 {
+			continue // Delivery Services with no HOST regex have no DNS name
+		}
+		dsDomain := Name(crcDS.Domains[0])
+		for _, entry := range crcDS.StaticDNSEntries {
+			rr, err := makeStaticRecord(entry, dsDomain)
+			if err != nil {
+				log.Warnln("CRConfig delivery service '" + dsNameStr + "' static DNS entry '" + entry.Name + "': " + err.Error() + ", skipping")
+				continue
+			}
+			z.addRecord(rr)
+		}
+
+		if !isDNSRouted(crcDS) {
+			continue
+		}
+		routingName := DefaultRoutingName
+		if crcDS.RoutingName != nil && *crcDS.RoutingName != "" {
+			routingName = *crcDS.RoutingName
+		}
+		ds := DeliveryService{
+			Name:    tc.DeliveryServiceName(dsNameStr),
+			IP6:     crcDS.IP6RoutingEnabled != nil && *crcDS.IP6RoutingEnabled,
+			ECS:     crcDS.EcsEnabled != nil && *crcDS.EcsEnabled,
+			ATTL:    ttl(dsTTL(crcDS.TTLs, "A"), crcDS.TTL, ttl(ttls["A"], nil, DefaultTTL)),
+			AAAATTL: ttl(dsTTL(crcDS.TTLs, "AAAA"), crcDS.TTL, ttl(ttls["AAAA"], nil, DefaultTTL)),
+		}
+		if crcDS.MaxDNSIPsForLocation != nil && *crcDS.MaxDNSIPsForLocation > 0 {
+			ds.MaxIPs = *crcDS.MaxDNSIPsForLocation
+		}
+		name := Name(routingName + "." + dsDomain)
+		z.DeliveryServices[name] = ds
+		z.addNonTerminals(name)
+	}
+	return z, nil
+}
+
+// isDNSRouted returns whether the given Delivery Service is DNS-routed, which is determined by the protocol of its first match set.
+func isDNSRouted(ds tc.CRConfigDeliveryService) bool {
+	return len(ds.MatchSets) > 0 && ds.MatchSets[0] != nil && ds.MatchSets[0].Protocol == "DNS"
+}
+
+// addRecord adds the given record to the zone's Records, and its parents to the zone's non-terminals.
+func (z *Zone) addRecord(rr dns.RR) {
+	name := rr.Header().Name
+	if z.Records[name] == nil {
+		z.Records[name] = map[uint16][]dns.RR{}
+	}
+	z.Records[name][rr.Header().Rrtype] = append(z.Records[name][rr.Header().Rrtype], rr)
+	z.addNonTerminals(name)
+}
+
+// addNonTerminals adds the parents of the given name, below the zone's Domain, as non-terminals.
+func (z *Zone) addNonTerminals(name string) {
+	for off, end := dns.NextLabel(name, 0); !end; off, end = dns.NextLabel(name, off) {
+		parent := name[off:]
+		if parent == z.Domain || !dns.IsSubDomain(z.Domain, parent) {
+			return
+		}
+		z.nonTerminals[parent] = struct{}{}
+	}
+}
+
+// makeStaticRecord creates the record of a CRConfig static DNS entry, whose name is relative to the given Delivery Service domain.
+func makeStaticRecord(entry tc.CRConfigStaticDNSEntry, dsDomain string) (dns.RR, error) {
+	name := dsDomain
+	if entry.Name != "" && entry.Name != "@" {
+		name = Name(entry.Name + "." + dsDomain)
+	}
+	if entry.TTL < 0 {
+		return nil, errors.New("negative TTL")
+	}
+	ttl := uint32(entry.TTL)
+	switch strings.TrimSuffix(entry.Type, "_RECORD") {
+	case "A":
+		ip := net.ParseIP(entry.Value)
+		if ip == nil || ip.To4() == nil {
+			return nil, errors.New("malformed IPv4 address '" + entry.Value + "'")
+		}
+		return &dns.A{Hdr: header(name, dns.TypeA, ttl), A: ip.To4()}, nil
+	case "AAAA":
+		ip := net.ParseIP(entry.Value)
+		if ip == nil || ip.To4() != nil {
+			return nil, errors.New("malformed IPv6 address '" + entry.Value + "'")
+		}
+		return &dns.AAAA{Hdr: header(name, dns.TypeAAAA, ttl), AAAA: ip}, nil
+	case "CNAME":
+		if _, ok := dns.IsDomainName(entry.Value); !ok {
+			return nil, errors.New("malformed CNAME target '" + entry.Value + "'")
+		}
+		return &dns.CNAME{Hdr: header(name, dns.TypeCNAME, ttl), Target: Name(entry.Value)}, nil
+	case "TXT":
+		return &dns.TXT{Hdr: header(name, dns.TypeTXT, ttl), Txt: []string{entry.Value}}, nil
+	}
+	return nil, errors.New("unsupported type '" + entry.Type + "'")
+}
+
+// makeSOA creates the zone SOA record from the CRConfig config soa values, using the CRConfig date as the serial, because it increases with every snapshot.
+func makeSOA(domain string, mname string, soa map[string]string, soaTTL uint32, date *int64) *dns.SOA {
+	admin := soa["admin"]
+	if admin == "" {
+		admin = "twelve_monkeys"
+	}
+	mbox := Name(admin)
+	if !strings.HasSuffix(admin, ".") {
+		mbox = Name(admin + "." + domain)
+	}
+	serial := uint32(0)
+	if date != nil {
+		serial = uint32(*date)
+	}
+	return &dns.SOA{
+		Hdr:     header(domain, dns.TypeSOA, soaTTL),
+		Ns:      mname,
+		Mbox:    mbox,
+		Serial:  serial,
+		Refresh: ttl(soa["refresh"], nil, 28800),
+		Retry:   ttl(soa["retry"], nil, 7200),
+		Expire:  ttl(soa["expire"], nil, 604800),
+		Minttl:  ttl(soa["minimum"], nil, DefaultTTL),
+	}
+}
+
+func header(name string, rrtype uint16, ttl uint32) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+}
+
+// ttl returns the TTL in the given string, or the given int if the string is empty or malformed, or def if both are.
+func ttl(s string, i *int, def uint32) uint32 {
+	if v, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(v)
+	}
+	if i != nil && *i >= 0 {
+		return uint32(*i)
+	}
+	return def
+}
+
+// dsTTL returns the given Delivery Service TTL for the given record type, or the empty string if it doesn't exist.
+func dsTTL(ttls *tc.CRConfigTTL, rrType string) string {
+	if ttls == nil {
+		return ""
+	}
+	s := (*string)(nil)
+	switch rrType {
+	case "A":
+		s = ttls.ASeconds
+	case "AAAA":
+		s = ttls.AAAASeconds
+	}
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// configStrs returns the CRConfig config object with the given key as a map of strings. If the key doesn't exist or isn't an object, an empty map is returned.
+func configStrs(config map[string]interface{}, key string) map[string]string {
+	strs := map[string]string{}
+	obj, ok := config[key].(map[string]interface{})
+	if !ok {
+		return strs
+	}
+	for k, v := range obj {
+		if s, ok := v.(string); ok {
+			strs[k] = s
+		}
+	}
+	return strs
+}
+
+// parseIP parses the given CRConfig IP, which may have a CIDR prefix length, as IPv6 addresses usually do.
+func parseIP(s *string) net.IP {
+	if s == nil {
+		return nil
+	}
+	if i := strings.Index(*s, "/"); i != -1 {
+		return net.ParseIP((*s)[:i])
+	}
+	return net.ParseIP(*s)
+}
@@ -0,0 +1,92 @@
+package geolimit
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+import (
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/geolocation"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// Limit is the geolocation settings of a Delivery Service: which clients may be routed, and where to route clients who can't be located.
+type Limit struct {
+	// CoverageZoneOnly is whether only clients in the coverage zone may be routed.
+	CoverageZoneOnly bool
+	// Countries are the ISO 3166-1 country codes of clients outside the coverage zone which may be routed. If empty, all clients may be routed, unless CoverageZoneOnly.
+	Countries map[string]struct{}
+	// RedirectURL is where to redirect HTTP clients who may not be routed. If empty, they are refused.
+	RedirectURL string
+	// MissLocation is the location to route clients who can't be located to, or nil to use the router default.
+	MissLocation *tc.CRConfigLatitudeLongitude
+}
+
+// DeliveryServices are the geolocation limits of each Delivery Service.
+type DeliveryServices map[tc.DeliveryServiceName]Limit
+
+// Get returns the geolocation limits of the Delivery Services in the given CRConfig.
+func Get(crc *tc.CRConfig) DeliveryServices {
+	limits := make(DeliveryServices, len(crc.DeliveryServices))
+	for dsName, ds := range crc.DeliveryServices {
+		limit := Limit{CoverageZoneOnly: ds.CoverageZoneOnly}
+		for _, geo := range ds.GeoEnabled {
+			if limit.Countries == nil {
+				limit.Countries = map[string]struct{}{}
+			}
+			limit.Countries[strings.ToUpper(geo.CountryCode)] = struct{}{}
+		}
+		if ds.GeoLimitRedirectURL != nil {
+			limit.RedirectURL = *ds.GeoLimitRedirectURL
+		}
+		if ds.MissLocation != nil {
+			limit.MissLocation = &tc.CRConfigLatitudeLongitude{Lat: ds.MissLocation.Lat, Lon: ds.MissLocation.Lon}
+		}
+		limits[tc.DeliveryServiceName(dsName)] = limit
+	}
+	return limits
+}
+
+// Allowed returns whether a client at the given location may be routed.
+func (l Limit) Allowed(loc geolocation.Location) bool {
+	if loc.InCoverageZone {
+		return true
+	}
+	if l.CoverageZoneOnly {
+		return false
+	}
+	if len(l.Countries) == 0 {
+		return true
+	}
+	_, ok := l.Countries[strings.ToUpper(loc.CountryCode)]
+	return ok
+}
+
+// Pos returns the position to route a client at the given location from. If the client wasn't located, this is the MissLocation, or the given default if there is none.
+func (l Limit) Pos(loc geolocation.Location, defaultPos tc.CRConfigLatitudeLongitude) tc.CRConfigLatitudeLongitude {
+	if loc.Found {
+		return loc.Pos
+	}
+	if l.MissLocation != nil {
+		return *l.MissLocation
+	}
+	return defaultPos
+}
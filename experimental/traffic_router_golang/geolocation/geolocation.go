@@ -0,0 +1,105 @@
+package geolocation
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+import (
+	"errors"
+	"net"
+
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/coveragezone"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Geolocator is the interface that wraps the Geolocate method.
+//
+// Geolocate returns the location of the given IP, and its ISO 3166-1 country code if known. Returns false if the IP can't be located.
+type Geolocator interface {
+	Geolocate(ip net.IP) (tc.CRConfigLatitudeLongitude, string, bool)
+}
+
+// Location is where a client was located.
+type Location struct {
+	Pos tc.CRConfigLatitudeLongitude
+	// Found is whether the client was located. If false, Pos is meaningless, and the client should be routed to a default location.
+	Found bool
+	// InCoverageZone is whether the client was located by the coverage zone, rather than by geolocation.
+	InCoverageZone bool
+	// CountryCode is the ISO 3166-1 country code of the client, if it was geolocated and the database has countries.
+	CountryCode string
+}
+
+// Locator locates clients with the coverage zone, and falls back to geolocation for clients not in the coverage zone.
+type Locator struct {
+	CZ coveragezone.CoverageZone
+	// Geo is the fallback geolocator. If nil, only the coverage zone is used.
+	Geo Geolocator
+}
+
+// Locate returns the location of the client with the given IP.
+func (l Locator) Locate(ip net.IP) Location {
+	if pos, ok := l.CZ.Get(ip); ok {
+		return Location{Pos: pos, Found: true, InCoverageZone: true}
+	}
+	if l.Geo == nil {
+		return Location{}
+	}
+	pos, country, ok := l.Geo.Geolocate(ip)
+	return Location{Pos: pos, Found: ok, CountryCode: country}
+}
+
+// maxMindRecord is the data used from MaxMind database records. Country and City databases, both GeoIP2 and GeoLite2, have the same structure for these fields.
+type maxMindRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+type maxMind struct {
+	db *maxminddb.Reader
+}
+
+// LoadMaxMind loads the given MaxMind GeoIP2 or GeoLite2 database file. The returned Geolocator is safe for use by multiple goroutines.
+func LoadMaxMind(filename string) (Geolocator, error) {
+	db, err := maxminddb.Open(filename)
+	if err != nil {
+		return nil, errors.New("opening MaxMind database '" + filename + "': " + err.Error())
+	}
+	return &maxMind{db: db}, nil
+}
+
+// Geolocate returns the location of the given IP in the MaxMind database. Records with a country but no location, as in Country databases, return the country code but false, so geo-limits can be applied to them but they're routed to the default location.
+func (m *maxMind) Geolocate(ip net.IP) (tc.CRConfigLatitudeLongitude, string, bool) {
+	rec := maxMindRecord{}
+	if err := m.db.Lookup(ip, &rec); err != nil {
+		return tc.CRConfigLatitudeLongitude{}, "", false
+	}
+	if rec.Location.Latitude == nil || rec.Location.Longitude == nil {
+		return tc.CRConfigLatitudeLongitude{}, rec.Country.ISOCode, false
+	}
+	return tc.CRConfigLatitudeLongitude{Lat: *rec.Location.Latitude, Lon: *rec.Location.Longitude}, rec.Country.ISOCode, true
+}
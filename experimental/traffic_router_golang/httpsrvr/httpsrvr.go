@@ -29,9 +29,12 @@ import (
 
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/availableservers"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/cgsrch"
-	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/coveragezone"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/crconfig"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/crconfigregex"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/geolimit"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/geolocation"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/nextcache"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/steering"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
@@ -50,7 +53,10 @@ func getHandler(
 	availSrvrs availableservers.AvailableServers,
 	cgSrchThs cgsrch.Ths,
 	nextCacherThs nextcache.Ths,
-	cz coveragezone.CoverageZone,
+	locator geolocation.Locator,
+	geoLimitsThs geolimit.Ths,
+	crcThs crconfig.Ths,
+	steeringThs steering.Ths,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// host := r.Header.Get("Host")
@@ -100,11 +106,32 @@ func getHandler(
 			return
 		}
 
-		pos, ok := cz.Get(ip)
-		if !ok {
-			pos = DefaultPos
+		path := r.URL.Path
+		limit := geolimit.Limit{}
+		if geoLimits := geoLimitsThs.Get(); geoLimits != nil {
+			limit = (*geoLimits)[dsName]
+		}
+		loc := locator.Locate(ip)
+		if !limit.Allowed(loc) {
+			if limit.RedirectURL == "" {
+				fmt.Println("EVENT request '" + r.Host + "' from " + ip.String() + " country '" + loc.CountryCode + "' denied by ds '" + string(dsName) + "' geo limit, returning 503")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if !strings.HasPrefix(limit.RedirectURL, "/") {
+				fmt.Println("EVENT request '" + r.Host + "' from " + ip.String() + " country '" + loc.CountryCode + "' denied by ds '" + string(dsName) + "' geo limit, redirecting to '" + limit.RedirectURL + "'")
+				w.Header().Add(rfc.Location, limit.RedirectURL)
+				w.WriteHeader(http.StatusFound)
+				return
+			}
+			// a relative redirect URL is a path on the delivery service, which the client is routed to regardless of the geo limit
+			fmt.Println("EVENT request '" + r.Host + "' from " + ip.String() + " country '" + loc.CountryCode + "' denied by ds '" + string(dsName) + "' geo limit, routing to '" + limit.RedirectURL + "'")
+			path = limit.RedirectURL
+		}
+		if !loc.Found {
 			log.Warnln("request from" + r.RemoteAddr + " IP " + ip.String() + " not found, using default")
 		}
+		pos := limit.Pos(loc, DefaultPos)
 		log.Infof("LATLON: Request from"+r.RemoteAddr+" IP "+ip.String()+" got %+v\n", pos)
 
 		cgSrch := cgSrchThs.Get()
@@ -116,6 +143,17 @@ func getHandler(
 		}
 		cg := tc.CacheGroupName(cgDat.Obj)
 
+		if steerings := steeringThs.Get(); steerings != nil {
+			if st, ok := (*steerings)[dsName]; ok {
+				clientPos := (*tc.CRConfigLatitudeLongitude)(nil)
+				if loc.Found {
+					clientPos = &loc.Pos
+				}
+				routeSteering(w, r, st, crcThs.Get(), availSrvrs, cg, clientPos, path)
+				return
+			}
+		}
+
 		srvrs, err := availSrvrs.Get(dsName, cg)
 		if err != nil {
 			fmt.Println("EVENT request '" + r.Host + "' with cg '" + string(cg) + "' ds '" + string(dsName) + "' failed to get available servers, returning 404: " + err.Error())
@@ -142,8 +180,8 @@ func getHandler(
 
 		srvr := srvrs[nextSrvrI%uint64(len(srvrs))]
 
-		newURL := string(srvr) + "." + subdomain + "." + domain + r.URL.Path
-		if r.URL.RawQuery != "" {
+		newURL := string(srvr) + "." + subdomain + "." + domain + path
+		if r.URL.RawQuery != "" && path == r.URL.Path {
 			newURL += "?" + r.URL.RawQuery
 		}
 
@@ -157,12 +195,15 @@ func Start(
 	availableServers availableservers.AvailableServers,
 	cgSrch cgsrch.Ths,
 	nextCacher nextcache.Ths,
-	cz coveragezone.CoverageZone,
+	locator geolocation.Locator,
+	geoLimits geolimit.Ths,
+	crc crconfig.Ths,
+	steerings steering.Ths,
 	port uint,
 ) *http.Server {
 	srvr := http.Server{}
 	srvr.Addr = ":" + strconv.Itoa(int(port))
-	srvr.Handler = getHandler(regexes, availableServers, cgSrch, nextCacher, cz)
+	srvr.Handler = getHandler(regexes, availableServers, cgSrch, nextCacher, locator, geoLimits, crc, steerings)
 	go func() {
 		err := srvr.ListenAndServe()
 		if err != nil {
@@ -0,0 +1,246 @@
+package httpsrvr
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/availableservers"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/consistenthash"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/steering"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// SteeringOptionHeader is the request header a client may send to be routed to a particular target of a STEERING Delivery Service.
+const SteeringOptionHeader = "X-TC-Steering-Option"
+
+// RedirectParam is the query parameter which, if "false", makes client steering respond 200 with the locations, rather than redirecting to the first.
+const RedirectParam = "trred"
+
+// ClientSteeringResponse is the body of a CLIENT_STEERING response: the URLs of each target, in order of preference.
+type ClientSteeringResponse struct {
+	Locations []string `json:"locations"`
+}
+
+// routeSteering routes a request for a STEERING or CLIENT_STEERING Delivery Service, after the client has been located in the given cachegroup.
+func routeSteering(
+	w http.ResponseWriter,
+	r *http.Request,
+	st *steering.Steering,
+	crc *tc.CRConfig,
+	availSrvrs availableservers.AvailableServers,
+	cg tc.CacheGroupName,
+	clientPos *tc.CRConfigLatitudeLongitude,
+	path string,
+) {
+	if crc == nil {
+		fmt.Println("ERROR request '" + r.Host + "' steering ds '" + string(st.DS) + "' has no CRConfig, returning 503")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	steeringDS, ok := crc.DeliveryServices[string(st.DS)]
+	if !ok {
+		fmt.Println("EVENT request '" + r.Host + "' steering ds '" + string(st.DS) + "' not in CRConfig, returning 404")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := hashKey(steeringDS, path, r.URL.Query())
+
+	if st.ClientSteering {
+		routeClientSteering(w, r, st, crc, availSrvrs, cg, clientPos, path, key)
+		return
+	}
+
+	targets := []steering.Target{}
+	if option := r.Header.Get(SteeringOptionHeader); option != "" {
+		if !st.HasTarget(tc.DeliveryServiceName(option)) {
+			fmt.Println("EVENT request '" + r.Host + "' steering option '" + option + "' is not a target of ds '" + string(st.DS) + "', returning 404")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		targets = append(targets, steering.Target{DS: tc.DeliveryServiceName(option)})
+	} else if bypass, ok := st.Bypass(path); ok {
+		targets = append(targets, steering.Target{DS: bypass})
+	} else {
+		targets = st.Order(key)
+	}
+
+	// targets are tried in order, so a target with no available caches falls through to the next
+	for _, target := range targets {
+		targetURL, ok := steeringTargetURL(r, target, crc, availSrvrs, cg, path, key, nil)
+		if !ok {
+			continue
+		}
+		fmt.Println("EVENT request '" + r.Host + "' steering ds '" + string(st.DS) + "' routed to target '" + string(target.DS) + "' " + targetURL)
+		w.Header().Add(rfc.Location, targetURL)
+		w.WriteHeader(http.StatusFound)
+		return
+	}
+	fmt.Println("EVENT request '" + r.Host + "' steering ds '" + string(st.DS) + "' with cg '" + string(cg) + "' has no target with available servers, returning 503")
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// routeClientSteering responds with the URLs of all targets with available caches, in order of preference, redirecting to the first unless the client asked not to be.
+func routeClientSteering(
+	w http.ResponseWriter,
+	r *http.Request,
+	st *steering.Steering,
+	crc *tc.CRConfig,
+	availSrvrs availableservers.AvailableServers,
+	cg tc.CacheGroupName,
+	clientPos *tc.CRConfigLatitudeLongitude,
+	path string,
+	key string,
+) {
+	routes := []steering.Route{}
+	selected := map[tc.CacheName]struct{}{}
+	for _, target := range st.Order(key) {
+		targetURL, ok := steeringTargetURL(r, target, crc, availSrvrs, cg, path, key, selected)
+		if !ok {
+			continue
+		}
+		routes = append(routes, steering.Route{Target: target, URL: targetURL, CachePos: crc.EdgeLocations[string(cg)]})
+	}
+	if len(routes) == 0 {
+		fmt.Println("EVENT request '" + r.Host + "' client steering ds '" + string(st.DS) + "' with cg '" + string(cg) + "' has no target with available servers, returning 503")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	steering.SortRoutes(routes, clientPos)
+
+	resp := ClientSteeringResponse{}
+	for _, route := range routes {
+		resp.Locations = append(resp.Locations, route.URL)
+	}
+	bts, err := json.Marshal(resp)
+	if err != nil {
+		fmt.Println("ERROR request '" + r.Host + "' client steering ds '" + string(st.DS) + "' marshalling response: " + err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Println("EVENT request '" + r.Host + "' client steering ds '" + string(st.DS) + "' routed to " + strings.Join(resp.Locations, ", "))
+	w.Header().Set(rfc.ContentType, rfc.ApplicationJSON)
+	if r.URL.Query().Get(RedirectParam) == "false" {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.Header().Add(rfc.Location, resp.Locations[0])
+		w.WriteHeader(http.StatusFound)
+	}
+	w.Write(bts)
+}
+
+// steeringTargetURL returns the URL of a cache in the given cachegroup for the given steering target, or false if the target has no available caches.
+// The cache is chosen by consistent hash of the steering key and the target's own significant query parameters. If selected is not nil, caches in it are only chosen if the target has no other caches, and the chosen cache is added to it.
+func steeringTargetURL(
+	r *http.Request,
+	target steering.Target,
+	crc *tc.CRConfig,
+	availSrvrs availableservers.AvailableServers,
+	cg tc.CacheGroupName,
+	path string,
+	key string,
+	selected map[tc.CacheName]struct{},
+) (string, bool) {
+	targetDS, ok := crc.DeliveryServices[string(target.DS)]
+	if !ok || len(targetDS.Domains) == 0 {
+		fmt.Println("EVENT steering target '" + string(target.DS) + "' not in CRConfig, skipping")
+		return "", false
+	}
+	srvrs, err := availSrvrs.Get(target.DS, cg)
+	if err != nil || len(srvrs) == 0 {
+		fmt.Println("EVENT steering target '" + string(target.DS) + "' has no available servers in cg '" + string(cg) + "', skipping")
+		return "", false
+	}
+	srvrs = consistenthash.Order(srvrs, key+significantQueryParams(targetDS, r.URL.Query()), func(c tc.CacheName) string { return string(c) }, consistenthash.Unweighted[tc.CacheName])
+	srvr := srvrs[0]
+	for _, candidate := range srvrs {
+		if _, ok := selected[candidate]; !ok {
+			srvr = candidate
+			break
+		}
+	}
+	if selected != nil {
+		selected[srvr] = struct{}{}
+	}
+
+	targetURL := steeringTargetScheme(r, targetDS) + "://" + string(srvr) + "." + targetDS.Domains[0] + path
+	if r.URL.RawQuery != "" {
+		targetURL += "?" + r.URL.RawQuery
+	}
+	return targetURL, true
+}
+
+// steeringTargetScheme returns the scheme of the redirect to a steering target: the scheme of the request, unless the target doesn't accept it, or redirects HTTP to HTTPS.
+func steeringTargetScheme(r *http.Request, targetDS tc.CRConfigDeliveryService) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	protocol := targetDS.Protocol
+	if protocol == nil {
+		return scheme
+	}
+	acceptHTTP := protocol.AcceptHTTP == nil || *protocol.AcceptHTTP
+	switch {
+	case scheme == "http" && protocol.AcceptHTTPS && (!acceptHTTP || protocol.RedirectOnHTTPS):
+		return "https"
+	case scheme == "https" && !protocol.AcceptHTTPS && acceptHTTP:
+		return "http"
+	}
+	return scheme
+}
+
+// hashKey returns the consistent hash key of a request for the given Delivery Service: the groups matched by its consistent hash regex, or the path if it has none or it doesn't match, followed by its significant query parameters.
+func hashKey(ds tc.CRConfigDeliveryService, path string, query url.Values) string {
+	key := path
+	if ds.ConsistentHashRegex != nil && *ds.ConsistentHashRegex != "" {
+		if re, err := regexp.Compile(*ds.ConsistentHashRegex); err != nil {
+			fmt.Println("ERROR consistent hash regex '" + *ds.ConsistentHashRegex + "' failed to compile, hashing the path: " + err.Error())
+		} else if matches := re.FindStringSubmatch(path); len(matches) > 1 {
+			key = strings.Join(matches[1:], "")
+		}
+	}
+	return key + significantQueryParams(ds, query)
+}
+
+// significantQueryParams returns the Delivery Service's consistent hash query parameters in the given query, sorted by name, so the same parameters hash the same regardless of order.
+func significantQueryParams(ds tc.CRConfigDeliveryService, query url.Values) string {
+	params := []string{}
+	for _, name := range ds.ConsistentHashQueryParams {
+		for _, val := range query[name] {
+			params = append(params, name+"="+val)
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	sort.Strings(params)
+	return "?" + strings.Join(params, "&")
+}
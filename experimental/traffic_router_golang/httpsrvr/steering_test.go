@@ -0,0 +1,203 @@
+package httpsrvr
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/availableservers"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/steering"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func testSteeringCRConfig() *tc.CRConfig {
+	return &tc.CRConfig{
+		EdgeLocations: map[string]tc.CRConfigLatitudeLongitude{"cg0": {Lat: 0, Lon: 0}},
+		DeliveryServices: map[string]tc.CRConfigDeliveryService{
+			"steer": {Domains: []string{"steer.cdn.example.net"}, ConsistentHashRegex: util.StrPtr(`^/([^/]+)/`), ConsistentHashQueryParams: []string{"profile"}},
+			"a":     {Domains: []string{"a.cdn.example.net"}},
+			"b":     {Domains: []string{"b.cdn.example.net"}},
+			"c":     {Domains: []string{"c.cdn.example.net"}},
+		},
+	}
+}
+
+func testSteeringAvailableServers() availableservers.AvailableServers {
+	availSrvrs := availableservers.New()
+	availSrvrs.Set(availableservers.AvailableServersMap{
+		"a": {"cg0": {"edge0", "edge1"}},
+		"b": {"cg0": {"edge0", "edge1"}},
+		"c": {"cg1": {"edge2"}},
+	})
+	return availSrvrs
+}
+
+func testSteering(clientSteering bool) *steering.Steering {
+	return &steering.Steering{
+		DS:             "steer",
+		ClientSteering: clientSteering,
+		Targets: []steering.Target{
+			{DS: "a", Weight: 1, Order: 1},
+			{DS: "b", Weight: 1, Order: 0},
+			{DS: "c", Weight: 1000},
+		},
+		Filters: []steering.Filter{},
+	}
+}
+
+func TestRouteSteering(t *testing.T) {
+	crc := testSteeringCRConfig()
+	availSrvrs := testSteeringAvailableServers()
+	st := testSteering(false)
+
+	for _, option := range []string{"a", "b"} {
+		r := httptest.NewRequest(http.MethodGet, "/movie/manifest.m3u8?x=y", nil)
+		r.Header.Set(SteeringOptionHeader, option)
+		w := httptest.NewRecorder()
+		routeSteering(w, r, st, crc, availSrvrs, "cg0", nil, r.URL.Path)
+		if w.Code != http.StatusFound {
+			t.Fatalf("routeSteering option '%v' expected %v, actual: %v", option, http.StatusFound, w.Code)
+		}
+		loc := w.Header().Get(rfc.Location)
+		if !strings.HasPrefix(loc, "http://edge") || !strings.HasSuffix(loc, "."+option+".cdn.example.net/movie/manifest.m3u8?x=y") {
+			t.Errorf("routeSteering option '%v' expected redirect to the option's cache, actual: '%v'", option, loc)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/movie/manifest.m3u8", nil)
+	r.Header.Set(SteeringOptionHeader, "notatarget")
+	w := httptest.NewRecorder()
+	routeSteering(w, r, st, crc, availSrvrs, "cg0", nil, r.URL.Path)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("routeSteering option not a target expected %v, actual: %v", http.StatusNotFound, w.Code)
+	}
+
+	// c has nearly all the weight, but no servers in cg0, so every request must fall through to a or b
+	for _, path := range []string{"/one/x", "/two/x", "/three/x", "/four/x"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		routeSteering(w, r, st, crc, availSrvrs, "cg0", nil, r.URL.Path)
+		if loc := w.Header().Get(rfc.Location); w.Code != http.StatusFound || strings.Contains(loc, ".c.cdn.example.net") {
+			t.Errorf("routeSteering '%v' expected redirect to a target with servers, actual: %v '%v'", path, w.Code, loc)
+		}
+	}
+
+	st.Filters = append(st.Filters, steering.Filter{Pattern: regexp.MustCompile(`^/live/`), DS: "a"})
+	r = httptest.NewRequest(http.MethodGet, "/live/channel.m3u8", nil)
+	w = httptest.NewRecorder()
+	routeSteering(w, r, st, crc, availSrvrs, "cg0", nil, r.URL.Path)
+	if loc := w.Header().Get(rfc.Location); !strings.Contains(loc, ".a.cdn.example.net/live/") {
+		t.Errorf("routeSteering filter expected bypass to target a, actual: '%v'", loc)
+	}
+}
+
+func TestRouteClientSteering(t *testing.T) {
+	crc := testSteeringCRConfig()
+	availSrvrs := testSteeringAvailableServers()
+	st := testSteering(true)
+
+	r := httptest.NewRequest(http.MethodGet, "/movie/manifest.m3u8", nil)
+	w := httptest.NewRecorder()
+	routeSteering(w, r, st, crc, availSrvrs, "cg0", nil, r.URL.Path)
+	if w.Code != http.StatusFound {
+		t.Fatalf("routeSteering client steering expected %v, actual: %v", http.StatusFound, w.Code)
+	}
+	if ct := w.Header().Get(rfc.ContentType); ct != rfc.ApplicationJSON {
+		t.Errorf("routeSteering client steering expected content type '%v', actual: '%v'", rfc.ApplicationJSON, ct)
+	}
+	resp := ClientSteeringResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("routeSteering client steering response unmarshalling: %v", err)
+	}
+	if len(resp.Locations) != 2 {
+		t.Fatalf("routeSteering client steering expected the 2 targets with servers, actual: %+v", resp.Locations)
+	}
+	if !strings.Contains(resp.Locations[0], ".b.cdn.example.net/") || !strings.Contains(resp.Locations[1], ".a.cdn.example.net/") {
+		t.Errorf("routeSteering client steering expected targets by order, actual: %+v", resp.Locations)
+	}
+	if loc := w.Header().Get(rfc.Location); loc != resp.Locations[0] {
+		t.Errorf("routeSteering client steering expected redirect to the first location '%v', actual: '%v'", resp.Locations[0], loc)
+	}
+	firstCache := strings.SplitN(strings.TrimPrefix(resp.Locations[0], "http://"), ".", 2)[0]
+	secondCache := strings.SplitN(strings.TrimPrefix(resp.Locations[1], "http://"), ".", 2)[0]
+	if firstCache == secondCache {
+		t.Errorf("routeSteering client steering expected different caches for each target, actual: %+v", resp.Locations)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/movie/manifest.m3u8?"+RedirectParam+"=false", nil)
+	w = httptest.NewRecorder()
+	routeSteering(w, r, st, crc, availSrvrs, "cg0", nil, r.URL.Path)
+	if w.Code != http.StatusOK || w.Header().Get(rfc.Location) != "" {
+		t.Errorf("routeSteering client steering %v=false expected %v without a location, actual: %v '%v'", RedirectParam, http.StatusOK, w.Code, w.Header().Get(rfc.Location))
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	ds := testSteeringCRConfig().DeliveryServices["steer"]
+	keyA := hashKey(ds, "/movie/manifest.m3u8", url.Values{"profile": {"hd"}, "session": {"1"}})
+	keyB := hashKey(ds, "/movie/segment1.ts", url.Values{"session": {"2"}, "profile": {"hd"}})
+	if keyA != keyB {
+		t.Errorf("hashKey expected the same key for the same regex groups and significant query params, actual: '%v' '%v'", keyA, keyB)
+	}
+	if keyC := hashKey(ds, "/movie/manifest.m3u8", url.Values{"profile": {"sd"}}); keyC == keyA {
+		t.Errorf("hashKey expected a different key for different significant query params, actual: '%v'", keyC)
+	}
+	if key := hashKey(tc.CRConfigDeliveryService{}, "/movie/manifest.m3u8", url.Values{"profile": {"hd"}}); key != "/movie/manifest.m3u8" {
+		t.Errorf("hashKey without regex or query params expected the path, actual: '%v'", key)
+	}
+}
+
+func TestSteeringTargetScheme(t *testing.T) {
+	httpsOnly := tc.CRConfigDeliveryService{Protocol: &tc.CRConfigDeliveryServiceProtocol{AcceptHTTP: util.BoolPtr(false), AcceptHTTPS: true}}
+	httpOnly := tc.CRConfigDeliveryService{Protocol: &tc.CRConfigDeliveryServiceProtocol{AcceptHTTP: util.BoolPtr(true)}}
+	both := tc.CRConfigDeliveryService{Protocol: &tc.CRConfigDeliveryServiceProtocol{AcceptHTTPS: true}}
+	redirect := tc.CRConfigDeliveryService{Protocol: &tc.CRConfigDeliveryServiceProtocol{AcceptHTTPS: true, RedirectOnHTTPS: true}}
+
+	httpReq := httptest.NewRequest(http.MethodGet, "http://steer.example.invalid/movie", nil)
+	httpsReq := httptest.NewRequest(http.MethodGet, "https://steer.example.invalid/movie", nil)
+	for _, tt := range []struct {
+		name     string
+		r        *http.Request
+		ds       tc.CRConfigDeliveryService
+		expected string
+	}{
+		{"http no protocol", httpReq, tc.CRConfigDeliveryService{}, "http"},
+		{"https no protocol", httpsReq, tc.CRConfigDeliveryService{}, "https"},
+		{"http to https only", httpReq, httpsOnly, "https"},
+		{"https to http only", httpsReq, httpOnly, "http"},
+		{"http to both", httpReq, both, "http"},
+		{"https to both", httpsReq, both, "https"},
+		{"http to redirect", httpReq, redirect, "https"},
+	} {
+		if actual := steeringTargetScheme(tt.r, tt.ds); actual != tt.expected {
+			t.Errorf("steeringTargetScheme %s expected '%s', actual '%s'", tt.name, tt.expected, actual)
+		}
+	}
+}
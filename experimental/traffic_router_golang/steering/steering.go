@@ -0,0 +1,173 @@
+package steering
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"sort"
+
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/consistenthash"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// Steering is the steering configuration of a STEERING or CLIENT_STEERING Delivery Service, from the Traffic Ops /steering endpoint.
+type Steering struct {
+	DS tc.DeliveryServiceName
+	// ClientSteering is whether clients are given all targets, in order of preference, rather than redirected to one.
+	ClientSteering bool
+	Targets        []Target
+	Filters        []Filter
+}
+
+// Target is a Delivery Service which a steering Delivery Service may route clients to.
+type Target struct {
+	DS tc.DeliveryServiceName
+	// Order is the target's preference, from STEERING_ORDER targets. Lower orders are preferred by client steering.
+	Order int32
+	// Weight is the target's relative weight, from STEERING_WEIGHT and STEERING_GEO_WEIGHT targets.
+	Weight int32
+	// GeoOrder is the target's preference among targets at the same distance, from STEERING_GEO_ORDER and STEERING_GEO_WEIGHT targets.
+	GeoOrder int
+	// Pos is the location of the target's origin, for STEERING_GEO_ORDER and STEERING_GEO_WEIGHT targets, or nil.
+	Pos *tc.CRConfigLatitudeLongitude
+}
+
+// Filter routes requests whose path matches Pattern to the target DS, bypassing the steering targets' weights.
+type Filter struct {
+	Pattern *regexp.Regexp
+	DS      tc.DeliveryServiceName
+}
+
+// DeliveryServices are the steering configurations of each steering Delivery Service.
+type DeliveryServices map[tc.DeliveryServiceName]*Steering
+
+// Get returns the steering configurations of the given Traffic Ops steering objects.
+func Get(steerings []tc.Steering) (DeliveryServices, error) {
+	dses := make(DeliveryServices, len(steerings))
+	for _, tcSteering := range steerings {
+		st := &Steering{DS: tcSteering.DeliveryService, ClientSteering: tcSteering.ClientSteering}
+		for _, tcTarget := range tcSteering.Targets {
+			target := Target{DS: tcTarget.DeliveryService, Order: tcTarget.Order, Weight: tcTarget.Weight}
+			if tcTarget.GeoOrder != nil {
+				target.GeoOrder = *tcTarget.GeoOrder
+			}
+			if tcTarget.Latitude != nil && tcTarget.Longitude != nil {
+				target.Pos = &tc.CRConfigLatitudeLongitude{Lat: *tcTarget.Latitude, Lon: *tcTarget.Longitude}
+			}
+			st.Targets = append(st.Targets, target)
+		}
+		for _, tcFilter := range tcSteering.Filters {
+			pattern, err := regexp.Compile(tcFilter.Pattern)
+			if err != nil {
+				return nil, errors.New("steering delivery service '" + string(st.DS) + "' filter pattern '" + tcFilter.Pattern + "' failed to compile: " + err.Error())
+			}
+			st.Filters = append(st.Filters, Filter{Pattern: pattern, DS: tcFilter.DeliveryService})
+		}
+		dses[st.DS] = st
+	}
+	return dses, nil
+}
+
+// HasTarget returns whether the given Delivery Service is a target of the steering Delivery Service.
+func (s *Steering) HasTarget(ds tc.DeliveryServiceName) bool {
+	for _, target := range s.Targets {
+		if target.DS == ds {
+			return true
+		}
+	}
+	return false
+}
+
+// Bypass returns the target of the first filter matching the given request path, or false if no filter matches.
+func (s *Steering) Bypass(path string) (tc.DeliveryServiceName, bool) {
+	for _, filter := range s.Filters {
+		if filter.Pattern.MatchString(path) && s.HasTarget(filter.DS) {
+			return filter.DS, true
+		}
+	}
+	return "", false
+}
+
+// Order returns the targets ordered by their weighted consistent hash with the given key, so targets are preferred in proportion to their weights, and the same key consistently gets the same order.
+func (s *Steering) Order(key string) []Target {
+	return consistenthash.Order(s.Targets, key, func(t Target) string { return string(t.DS) }, func(t Target) float64 { return float64(t.Weight) })
+}
+
+// Route is a target a client may be sent to, with the URL and location of the cache the client would be sent to.
+type Route struct {
+	Target   Target
+	URL      string
+	CachePos tc.CRConfigLatitudeLongitude
+}
+
+// SortRoutes sorts client steering routes, which must be in consistent hash order, by proximity and then by target order.
+//
+// If the client location is known and any target has a location, routes are first sorted by the distance from the client to the cache plus the distance from the cache to the target origin, with targets without locations last, and targets at the same distance sorted by their geo order. Then, routes are stably sorted by target order.
+func SortRoutes(routes []Route, clientPos *tc.CRConfigLatitudeLongitude) {
+	if clientPos != nil && anyRouteHasPos(routes) {
+		sort.SliceStable(routes, func(i, j int) bool {
+			ri, rj := routes[i], routes[j]
+			if ri.Target.Pos == nil || rj.Target.Pos == nil {
+				return ri.Target.Pos != nil && rj.Target.Pos == nil
+			}
+			if samePos(ri.CachePos, rj.CachePos) && samePos(*ri.Target.Pos, *rj.Target.Pos) {
+				return ri.Target.GeoOrder < rj.Target.GeoOrder
+			}
+			clientToCacheI := Distance(*clientPos, ri.CachePos)
+			clientToCacheJ := Distance(*clientPos, rj.CachePos)
+			totalI := clientToCacheI + Distance(ri.CachePos, *ri.Target.Pos)
+			totalJ := clientToCacheJ + Distance(rj.CachePos, *rj.Target.Pos)
+			if totalI != totalJ {
+				return totalI < totalJ
+			}
+			return clientToCacheI < clientToCacheJ
+		})
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Target.Order < routes[j].Target.Order })
+}
+
+func anyRouteHasPos(routes []Route) bool {
+	for _, route := range routes {
+		if route.Target.Pos != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// EarthRadiusKM is the mean radius of the Earth, in kilometers.
+const EarthRadiusKM = 6371.0
+
+// Distance returns the great-circle distance between the given points, in kilometers.
+func Distance(a tc.CRConfigLatitudeLongitude, b tc.CRConfigLatitudeLongitude) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(b.Lat - a.Lat)
+	dLon := toRad(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(a.Lat))*math.Cos(toRad(b.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKM * math.Asin(math.Sqrt(h))
+}
+
+func samePos(a tc.CRConfigLatitudeLongitude, b tc.CRConfigLatitudeLongitude) bool {
+	return a.Lat == b.Lat && a.Lon == b.Lon
+}
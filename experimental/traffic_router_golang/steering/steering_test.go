@@ -0,0 +1,144 @@
+package steering
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestGet(t *testing.T) {
+	dses, err := Get([]tc.Steering{{
+		DeliveryService: "steer",
+		ClientSteering:  true,
+		Targets: []tc.SteeringSteeringTarget{
+			{DeliveryService: "a", Weight: 100},
+			{DeliveryService: "b", Order: 1, GeoOrder: util.IntPtr(2), Latitude: util.FloatPtr(10), Longitude: util.FloatPtr(20)},
+		},
+		Filters: []tc.SteeringFilter{{DeliveryService: "b", Pattern: `^/live/.*`}},
+	}})
+	if err != nil {
+		t.Fatalf("Get expected nil error, actual: %v", err)
+	}
+	st, ok := dses["steer"]
+	if !ok {
+		t.Fatalf("Get expected steering ds 'steer', actual: %+v", dses)
+	}
+	if !st.ClientSteering || len(st.Targets) != 2 || len(st.Filters) != 1 {
+		t.Fatalf("Get expected client steering with 2 targets and 1 filter, actual: %+v", st)
+	}
+	if b := st.Targets[1]; b.Order != 1 || b.GeoOrder != 2 || b.Pos == nil || b.Pos.Lat != 10 || b.Pos.Lon != 20 {
+		t.Errorf("Get expected target b with order 1, geo order 2, and location 10,20, actual: %+v", b)
+	}
+	if st.Targets[0].Pos != nil {
+		t.Errorf("Get expected target a without location, actual: %+v", st.Targets[0].Pos)
+	}
+
+	if _, err := Get([]tc.Steering{{DeliveryService: "steer", Filters: []tc.SteeringFilter{{DeliveryService: "b", Pattern: `(`}}}}); err == nil {
+		t.Errorf("Get invalid filter pattern expected error, actual: nil")
+	}
+}
+
+func TestBypass(t *testing.T) {
+	dses, err := Get([]tc.Steering{{
+		DeliveryService: "steer",
+		Targets:         []tc.SteeringSteeringTarget{{DeliveryService: "a", Weight: 1}, {DeliveryService: "b", Weight: 1}},
+		Filters: []tc.SteeringFilter{
+			{DeliveryService: "notatarget", Pattern: `^/live/.*`},
+			{DeliveryService: "b", Pattern: `^/live/.*`},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Get expected nil error, actual: %v", err)
+	}
+	st := dses["steer"]
+	if ds, ok := st.Bypass("/live/channel1.m3u8"); !ok || ds != "b" {
+		t.Errorf("Bypass expected target 'b', actual: '%v' %v", ds, ok)
+	}
+	if ds, ok := st.Bypass("/vod/movie.m3u8"); ok {
+		t.Errorf("Bypass expected no match, actual: '%v'", ds)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	st := &Steering{DS: "steer", Targets: []Target{{DS: "heavy", Weight: 900}, {DS: "light", Weight: 100}, {DS: "none", Weight: 0}}}
+
+	counts := map[tc.DeliveryServiceName]int{}
+	const keys = 10000
+	for i := 0; i < keys; i++ {
+		key := "/path/" + strconv.Itoa(i)
+		ordered := st.Order(key)
+		if len(ordered) != len(st.Targets) {
+			t.Fatalf("Order expected %v targets, actual: %+v", len(st.Targets), ordered)
+		}
+		if again := st.Order(key); again[0].DS != ordered[0].DS {
+			t.Fatalf("Order expected the same key to consistently get '%v' first, actual: '%v'", ordered[0].DS, again[0].DS)
+		}
+		if ordered[2].DS != "none" {
+			t.Fatalf("Order expected zero-weight target last, actual: %+v", ordered)
+		}
+		counts[ordered[0].DS]++
+	}
+	if heavy := counts["heavy"]; heavy < keys*85/100 || heavy > keys*95/100 {
+		t.Errorf("Order expected target with 90%% of the weight first for about 90%% of keys, actual: %v of %v", heavy, keys)
+	}
+}
+
+func TestSortRoutes(t *testing.T) {
+	cache := tc.CRConfigLatitudeLongitude{Lat: 0, Lon: 0}
+	near := &tc.CRConfigLatitudeLongitude{Lat: 0, Lon: 1}
+	far := &tc.CRConfigLatitudeLongitude{Lat: 0, Lon: 10}
+
+	routes := []Route{
+		{Target: Target{DS: "nopos"}, CachePos: cache},
+		{Target: Target{DS: "far", Pos: far}, CachePos: cache},
+		{Target: Target{DS: "near-geoorder-2", Pos: near, GeoOrder: 2}, CachePos: cache},
+		{Target: Target{DS: "near-geoorder-1", Pos: near, GeoOrder: 1}, CachePos: cache},
+	}
+	SortRoutes(routes, &tc.CRConfigLatitudeLongitude{Lat: 0, Lon: 0})
+	expected := []tc.DeliveryServiceName{"near-geoorder-1", "near-geoorder-2", "far", "nopos"}
+	for i, ds := range expected {
+		if routes[i].Target.DS != ds {
+			t.Fatalf("SortRoutes expected %v, actual: %+v", expected, routes)
+		}
+	}
+
+	routes = []Route{
+		{Target: Target{DS: "order-1-near", Pos: near, Order: 1}, CachePos: cache},
+		{Target: Target{DS: "order-0-far", Pos: far, Order: 0}, CachePos: cache},
+	}
+	SortRoutes(routes, &tc.CRConfigLatitudeLongitude{Lat: 0, Lon: 0})
+	if routes[0].Target.DS != "order-0-far" {
+		t.Errorf("SortRoutes expected lower order first regardless of distance, actual: %+v", routes)
+	}
+
+	routes = []Route{
+		{Target: Target{DS: "far", Pos: far}, CachePos: cache},
+		{Target: Target{DS: "near", Pos: near}, CachePos: cache},
+	}
+	SortRoutes(routes, nil)
+	if routes[0].Target.DS != "far" {
+		t.Errorf("SortRoutes without client location expected consistent hash order, actual: %+v", routes)
+	}
+}
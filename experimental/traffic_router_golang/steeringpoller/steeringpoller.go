@@ -0,0 +1,79 @@
+package steeringpoller
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/fetch"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/steering"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// Start polls the given fetcher for the JSON array of Traffic Ops steering objects, and returns the threadsafe steering configurations of Delivery Services, which are updated whenever they change.
+func Start(fetcher fetch.Fetcher, interval time.Duration) (steering.Ths, error) {
+	thsSteering := steering.NewThs()
+	prevBts := []byte{}
+
+	get := func() {
+		newBts, err := fetcher.Fetch()
+		if err != nil {
+			fmt.Println("ERROR Steering read error: " + err.Error())
+			return
+		}
+
+		if bytes.Equal(newBts, prevBts) {
+			fmt.Println("INFO Steering unchanged.")
+			return
+		}
+
+		fmt.Println("INFO Steering changed.")
+		tcSteerings := []tc.Steering{}
+		if err := json.Unmarshal(newBts, &tcSteerings); err != nil {
+			fmt.Println("ERROR Steering unmarshalling: " + err.Error())
+			return
+		}
+
+		steerings, err := steering.Get(tcSteerings)
+		if err != nil {
+			fmt.Println("ERROR not using invalid new Steering: " + err.Error())
+			return
+		}
+
+		thsSteering.Set(&steerings)
+		prevBts = newBts
+		fmt.Println("INFO Steering set new")
+	}
+
+	get()
+
+	go func() {
+		for {
+			time.Sleep(interval)
+			get()
+		}
+	}()
+	return thsSteering, nil
+}
@@ -21,10 +21,13 @@ package toutil
  */
 
 import (
+	"encoding/json"
 	"errors"
 	"net/url"
 	"strconv"
 
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/fetch"
+
 	"github.com/apache/trafficcontrol/v8/lib/go-tc"
 	client "github.com/apache/trafficcontrol/v8/traffic_ops/v3-client"
 )
@@ -68,3 +71,28 @@ func GetMonitorURIs(toc *client.Session, cdn string) ([]string, error) {
 	}
 	return monitors, nil
 }
+
+type steeringFetcher struct {
+	toc *client.Session
+}
+
+// NewSteeringFetcher returns a Fetcher of the JSON array of steering Delivery Services from the Traffic Ops /steering endpoint.
+func NewSteeringFetcher(toc *client.Session) fetch.Fetcher {
+	return steeringFetcher{toc: toc}
+}
+
+func (f steeringFetcher) Fetch() ([]byte, error) {
+	steerings, reqInf, err := f.toc.SteeringWithHdr(nil)
+	if err != nil {
+		addr := "unknown"
+		if reqInf.RemoteAddr != nil {
+			addr = reqInf.RemoteAddr.String()
+		}
+		return nil, errors.New("getting steering from '" + addr + "': " + err.Error())
+	}
+	b, err := json.Marshal(steerings)
+	if err != nil {
+		return nil, errors.New("marshalling steering: " + err.Error())
+	}
+	return b, nil
+}
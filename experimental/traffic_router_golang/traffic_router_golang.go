@@ -32,8 +32,11 @@ import (
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/coveragezone"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/crconfigpoller"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/crstatespoller"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/dnssrvr"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/fetch"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/geolocation"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/httpsrvr"
+	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/steeringpoller"
 	"github.com/apache/trafficcontrol/v8/experimental/traffic_router_golang/toutil"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
@@ -80,6 +83,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	locator := geolocation.Locator{CZ: cz}
+	if cfg.GeolocationFile != "" {
+		geo, err := geolocation.LoadMaxMind(cfg.GeolocationFile)
+		if err != nil {
+			fmt.Println("Error loading geolocation file '" + cfg.GeolocationFile + "': " + err.Error())
+			os.Exit(1)
+		}
+		locator.Geo = geo
+	}
+
 	toURIStr := (*url.URL)(cfg.TrafficOpsURI).String()
 	log.Infof("TO URI Str: " + toURIStr + "\n")
 
@@ -106,7 +119,7 @@ func main() {
 	// crconfigFetcher := fetch.NewFile("./crconfig.json")
 	// crstatesFetcher := fetch.NewFile("./crstates.json")
 
-	thsCRConfig, thsCRConfigRegexes, thsCGSearcher, thsNextCacher, err := crconfigpoller.Start(crconfigFetcher, time.Duration(cfg.CRConfigInterval))
+	thsCRConfig, thsCRConfigRegexes, thsCGSearcher, thsNextCacher, thsDNSZone, thsGeoLimits, err := crconfigpoller.Start(crconfigFetcher, time.Duration(cfg.CRConfigInterval))
 	if err != nil {
 		fmt.Println("Could not get initial CRConfig: ", err)
 	}
@@ -116,7 +129,15 @@ func main() {
 		fmt.Println("Could not get initial CRStates from: ", err)
 	}
 
-	httpsrvr.Start(thsCRConfigRegexes, availableServers, thsCGSearcher, thsNextCacher, cz, cfg.Port)
+	thsSteering, err := steeringpoller.Start(toutil.NewSteeringFetcher(toClient), time.Duration(cfg.SteeringInterval))
+	if err != nil {
+		fmt.Println("Could not get initial Steering: ", err)
+	}
+
+	httpsrvr.Start(thsCRConfigRegexes, availableServers, thsCGSearcher, thsNextCacher, locator, thsGeoLimits, thsCRConfig, thsSteering, cfg.Port)
+	if cfg.DNSPort != 0 {
+		dnssrvr.Start(thsDNSZone, availableServers, thsCGSearcher, locator, thsGeoLimits, cfg.DNSPort)
+	}
 
 	// debug
 	for {
@@ -15,6 +15,7 @@ package cache
 */
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"strconv"
@@ -23,6 +24,7 @@ import (
 	"unsafe"
 
 	"github.com/apache/trafficcontrol/v8/grove/cachedata"
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
 	"github.com/apache/trafficcontrol/v8/grove/plugin"
 
 	"github.com/apache/trafficcontrol/v8/grove/remap"
@@ -161,6 +163,21 @@ func copyPluginContext(context map[string]*interface{}) map[string]*interface{}
 	return new
 }
 
+// revalidateInBackground revalidates the stale cacheObj with the parent in a new goroutine, and caches the result, per RFC5861 stale-while-revalidate. Revalidations of the same object are deduplicated by the Handler's getter, so concurrent stale requests make a single parent request. The remappingProducer MUST NOT be used by the caller to make requests after this is called.
+func (h *Handler) revalidateInBackground(r *http.Request, reqTime time.Time, reqCacheControl rfc.CacheControlMap, remappingProducer *remap.RemappingProducer, cacheObj *cacheobj.CacheObj, reqID uint64) {
+	// the client request is done when the stale response is sent, so the revalidation needs its own copy
+	bgReq := r.Clone(context.Background())
+	go func() {
+		retrier := NewRetrier(h, bgReq.Header, reqTime, reqCacheControl, remappingProducer, reqID)
+		newObj, _, err := retrier.Get(bgReq, cacheObj)
+		if err != nil {
+			log.Errorf("revalidating in background: %v (reqid %v)\n", err, reqID)
+			return
+		}
+		log.Debugf("revalidated '%v' in background: %v (reqid %v)\n", remappingProducer.CacheKey(), newObj.Code, reqID)
+	}()
+}
+
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	reqTime := time.Now()
 	reqID := atomic.AddUint64(&h.requestID, 1)
@@ -173,7 +190,9 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	conn := (*web.InterceptConn)(nil)
-	if realConn, ok := h.conns.Get(r.RemoteAddr); !ok {
+	if r.ProtoMajor >= 3 {
+		// HTTP/3 is served over QUIC, which has no InterceptConn
+	} else if realConn, ok := h.conns.Get(r.RemoteAddr); !ok {
 		log.Infof("RemoteAddr '%v' not in Conns (reqid %v)\n", r.RemoteAddr, reqID)
 	} else {
 		if conn, ok = realConn.(*web.InterceptConn); !ok {
@@ -251,14 +270,14 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		responder.OriginCode = cacheObj.OriginCode
 		// create new pointers, so plugins don't modify the cacheObj
-		codePtr, hdrsPtr, bodyPtr := cacheObj.Code, cacheObj.RespHeaders, cacheObj.Body
-		responder.SetResponse(&codePtr, &hdrsPtr, &bodyPtr, connectionClose)
+		codePtr, hdrsPtr, bodyPtr, openBodyPtr := cacheObj.Code, cacheObj.RespHeaders, cacheObj.Body, cacheObj.OpenBody
+		responder.SetStreamingResponse(&codePtr, &hdrsPtr, &bodyPtr, &openBodyPtr, connectionClose)
 		responder.OriginReqSuccess = true
 		responder.ProxyStr = cacheObj.ProxyURL
 		if reqHost != nil {
 			responder.ToFQDN = *reqHost
 		}
-		beforeRespData := plugin.BeforeRespondData{Req: r, CacheObj: cacheObj, Code: &codePtr, Hdr: &hdrsPtr, Body: &bodyPtr, RemapRule: remappingProducer.Name()}
+		beforeRespData := plugin.BeforeRespondData{Req: r, CacheObj: cacheObj, Code: &codePtr, Hdr: &hdrsPtr, Body: &bodyPtr, OpenBody: &openBodyPtr, RemapRule: remappingProducer.Name()}
 		h.plugins.OnBeforeRespond(remappingProducer.PluginCfg(), pluginContext, beforeRespData)
 		responder.Do()
 		return
@@ -292,19 +311,29 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	case rfc.ReuseMustRevalidateCanStale:
+		staleFor := -rfc.FreshFor(cacheObj.RespHeaders, cacheObj.RespCacheControl, cacheObj.ReqRespTime, cacheObj.RespRespTime)
+		if swr, ok := remappingProducer.StaleWhileRevalidate(cacheObj.RespCacheControl); ok && staleFor <= swr {
+			log.Debugf("cache.Handler.ServeHTTP: '%v' stale for %v, serving stale while revalidating (reqid %v)\n", cacheKey, staleFor, reqID)
+			h.revalidateInBackground(r, reqTime, reqCacheControl, remappingProducer, cacheObj, reqID)
+			canReuseStored = rfc.ReuseCan // served from the cache, without waiting for the parent
+			break
+		}
 		log.Debugf("cache.Handler.ServeHTTP: '%v' must revalidate (but allowed stale) (reqid %v)\n", cacheKey, reqID)
 		oldCacheObj := cacheObj
 		cacheObj, reqHost, err = retrier.Get(r, cacheObj)
 		if err != nil {
 			log.Errorf("retrying get error - serving stale as allowed: %v (reqid %v)\n", err, reqID)
 			cacheObj = oldCacheObj
+		} else if sie, ok := remappingProducer.StaleIfError(oldCacheObj.RespCacheControl); ok && cacheObj.Code >= http.StatusInternalServerError && staleFor <= sie {
+			log.Errorf("revalidating '%v' returned %v - serving stale for %v within stale-if-error %v (reqid %v)\n", cacheKey, cacheObj.Code, staleFor, sie, reqID)
+			cacheObj = oldCacheObj
 		}
 	}
 	log.Debugf("cache.Handler.ServeHTTP: '%v' responding with %v (reqid %v)\n", cacheKey, cacheObj.Code, reqID)
 
 	// create new pointers, so plugins don't modify the cacheObj
-	codePtr, hdrsPtr, bodyPtr := cacheObj.Code, cacheObj.RespHeaders, cacheObj.Body
-	responder.SetResponse(&codePtr, &hdrsPtr, &bodyPtr, connectionClose)
+	codePtr, hdrsPtr, bodyPtr, openBodyPtr := cacheObj.Code, cacheObj.RespHeaders, cacheObj.Body, cacheObj.OpenBody
+	responder.SetStreamingResponse(&codePtr, &hdrsPtr, &bodyPtr, &openBodyPtr, connectionClose)
 	responder.OriginReqSuccess = true
 	responder.Reuse = canReuseStored
 	responder.OriginCode = cacheObj.OriginCode
@@ -313,7 +342,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if reqHost != nil {
 		responder.ToFQDN = *reqHost
 	}
-	beforeRespData := plugin.BeforeRespondData{Req: r, CacheObj: cacheObj, Code: &codePtr, Hdr: &hdrsPtr, Body: &bodyPtr, RemapRule: remappingProducer.Name()}
+	beforeRespData := plugin.BeforeRespondData{Req: r, CacheObj: cacheObj, Code: &codePtr, Hdr: &hdrsPtr, Body: &bodyPtr, OpenBody: &openBodyPtr, RemapRule: remappingProducer.Name()}
 	h.plugins.OnBeforeRespond(remappingProducer.PluginCfg(), pluginContext, beforeRespData)
 	responder.Do()
 }
@@ -0,0 +1,158 @@
+package cache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/icache"
+	"github.com/apache/trafficcontrol/v8/grove/memcache"
+	"github.com/apache/trafficcontrol/v8/grove/plugin"
+	"github.com/apache/trafficcontrol/v8/grove/remap"
+	"github.com/apache/trafficcontrol/v8/grove/stat"
+	"github.com/apache/trafficcontrol/v8/grove/web"
+)
+
+// newTestHandler creates a Handler with a single rule from http://grove.test to the given origin. The ruleFields are added to the rule JSON, and must end in a comma.
+func newTestHandler(t *testing.T, originURL string, ruleFields string) *Handler {
+	t.Helper()
+	rulesJSON := `{"parent_selection": "consistent-hash", "retry_num": 0, "retry_codes": [], "timeout_ms": 5000, "rules": [{"name": "test", "from": "http://grove.test", ` + ruleFields + ` "to": [{"url": "` + originURL + `"}]}]}`
+	rulesPath := filepath.Join(t.TempDir(), "remap.json")
+	if err := ioutil.WriteFile(rulesPath, []byte(rulesJSON), 0600); err != nil {
+		t.Fatalf("writing remap rules: %v", err)
+	}
+
+	caches := map[string]icache.Cache{"": memcache.New(1024 * 1024)}
+	plugins := plugin.Get(nil)
+	remapper, err := remap.LoadRemapper(rulesPath, plugins.LoadFuncs(), caches, remap.NewRemappingTransport(time.Second, time.Second, 10, time.Second))
+	if err != nil {
+		t.Fatalf("loading remap rules: %v", err)
+	}
+	httpConns := web.NewConnMap()
+	httpsConns := web.NewConnMap()
+	stats := stat.New(remapper.Rules(), caches, 1024*1024, httpConns, httpsConns, "test")
+	return NewHandler(remapper, 100, stats, "http", "80", httpConns, false, false, plugins, map[string]*interface{}{}, httpConns, httpsConns, "")
+}
+
+func testGet(h http.Handler) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/manifest", nil)
+	r.Host = "grove.test"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for start := time.Now(); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("timed out waiting")
+		}
+	}
+}
+
+// newTestOrigin returns an origin server which responds with the given Cache-Control and a body of the request number, e.g. "v1", "v2".
+func newTestOrigin(cacheControl string, beforeRespond func(reqNum int64)) (*httptest.Server, *int64) {
+	reqs := int64(0)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&reqs, 1)
+		if beforeRespond != nil {
+			beforeRespond(n)
+		}
+		w.Header().Set("Date", time.Now().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Write([]byte("v" + strconv.FormatInt(n, 10)))
+	}))
+	return origin, &reqs
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	releaseOrigin := make(chan struct{})
+	origin, originReqs := newTestOrigin("max-age=0, stale-while-revalidate=60", func(n int64) {
+		if n == 2 {
+			<-releaseOrigin
+		}
+	})
+	defer origin.Close()
+
+	h := newTestHandler(t, origin.URL, "")
+
+	if body := testGet(h).Body.String(); body != "v1" {
+		t.Fatalf("expected the first request to be fetched from the origin, actual body '%s'", body)
+	}
+
+	done := make(chan string, 1)
+	go func() { done <- testGet(h).Body.String() }()
+	select {
+	case body := <-done:
+		if body != "v1" {
+			t.Errorf("expected the stale object to be served, actual body '%s'", body)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected the stale object to be served without waiting for revalidation")
+	}
+
+	close(releaseOrigin)
+	waitFor(t, func() bool { return atomic.LoadInt64(originReqs) >= 2 })
+	waitFor(t, func() bool { return testGet(h).Body.String() != "v1" })
+}
+
+func TestStaleWhileRevalidateRuleOverride(t *testing.T) {
+	origin, _ := newTestOrigin("max-age=0, stale-while-revalidate=60", nil)
+	defer origin.Close()
+
+	h := newTestHandler(t, origin.URL, `"stale_while_revalidate_ms": 0,`)
+	testGet(h)
+	if body := testGet(h).Body.String(); body != "v2" {
+		t.Errorf("expected a rule stale_while_revalidate_ms of 0 to revalidate synchronously, actual body '%s'", body)
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	failing := int32(0)
+	origin, _ := newTestOrigin("max-age=0, stale-if-error=60", nil)
+	origin.Config.Handler = func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&failing) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}(origin.Config.Handler)
+	defer origin.Close()
+
+	h := newTestHandler(t, origin.URL, "")
+	testGet(h)
+	atomic.StoreInt32(&failing, 1)
+	if w := testGet(h); w.Code != http.StatusOK || w.Body.String() != "v1" {
+		t.Errorf("expected the stale object to be served when revalidation fails within stale-if-error, actual %v '%s'", w.Code, w.Body.String())
+	}
+
+	atomic.StoreInt32(&failing, 0)
+	h = newTestHandler(t, origin.URL, `"stale_if_error_ms": 0,`)
+	testGet(h)
+	atomic.StoreInt32(&failing, 1)
+	if w := testGet(h); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the error to be served when the rule disables stale-if-error, actual %v '%s'", w.Code, w.Body.String())
+	}
+}
@@ -15,6 +15,7 @@ package cache
 */
 
 import (
+	"io"
 	"net/http"
 
 	"github.com/apache/trafficcontrol/v8/grove/cachedata"
@@ -36,6 +37,8 @@ type Responder struct {
 	Stats         stat.Stats
 	F             RespondFunc
 	ResponseCode  *int
+	// abort is whether the connection must be aborted after the response, because a streamed body failed after the headers were sent.
+	abort bool
 	cachedata.ParentRespData
 	cachedata.SrvrData
 	cachedata.ReqData
@@ -79,12 +82,32 @@ func NewResponder(w http.ResponseWriter, pluginCfg map[string]interface{}, plugi
 
 // SetResponse is a helper which sets the RespondFunc of r to `web.Respond` with the given code, headers, body, and connectionClose. Note it takes a pointer to the headers and body, which may be modified after calling this but before the Do() sends the response.
 func (r *Responder) SetResponse(code *int, hdrs *http.Header, body *[]byte, connectionClose bool) {
+	r.SetStreamingResponse(code, hdrs, body, nil, connectionClose)
+}
+
+// SetStreamingResponse is like SetResponse, but if the body is nil when the response is sent and openBody points to a non-nil func, the body is streamed from it with `web.RespondStream`. This is used for cache objects whose bodies aren't in memory. If the stream fails after the headers were sent, the connection is aborted, so the client can't mistake the partial body for a complete one.
+func (r *Responder) SetStreamingResponse(code *int, hdrs *http.Header, body *[]byte, openBody *func() (io.ReadCloser, error), connectionClose bool) {
 	r.ResponseCode = code
 	r.F = func() (uint64, error) {
 		if r.Req.Method == http.MethodHead {
 			*body = nil
+			openBody = nil
+		}
+		if *body != nil || openBody == nil || *openBody == nil || *code == http.StatusNotModified || *code == http.StatusNoContent {
+			return web.Respond(r.W, *code, *hdrs, *body, connectionClose)
+		}
+		bodyReader, err := (*openBody)()
+		if err != nil {
+			*code = http.StatusInternalServerError
+			log.Errorf("%s %s %s : opening cached body: %v", r.Req.RemoteAddr, r.Req.Method, r.Req.RequestURI, err.Error())
+			return web.ServeErr(r.W, *code)
 		}
-		return web.Respond(r.W, *code, *hdrs, *body, connectionClose)
+		defer bodyReader.Close()
+		bytesWritten, err := web.RespondStream(r.W, *code, *hdrs, bodyReader, connectionClose)
+		if err != nil {
+			r.abort = true
+		}
+		return bytesWritten, err
 	}
 }
 
@@ -103,6 +126,10 @@ func (r *Responder) Do() {
 	respData := cachedata.RespData{RespCode: *r.ResponseCode, BytesWritten: bytesSent, RespSuccess: respSuccess, CacheHit: isCacheHit(r.Reuse, r.OriginCode)}
 	arData := plugin.AfterRespondData{W: r.W, Stats: r.Stats, ReqData: r.ReqData, SrvrData: r.SrvrData, ParentRespData: r.ParentRespData, RespData: respData, RequestID: r.RequestID}
 	r.Plugins.OnAfterRespond(r.PluginCfg, r.PluginContext, arData)
+
+	if r.abort {
+		panic(http.ErrAbortHandler) // the http.Server closes the connection without logging
+	}
 }
 
 func isCacheHit(reuse rfc.Reuse, originCode int) bool {
@@ -16,6 +16,7 @@ package cache
 
 import (
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"time"
@@ -119,7 +120,7 @@ func GetAndCache(
 	cacheFailure bool,
 	retryNum int,
 	retryCodes map[int]struct{},
-	transport *http.Transport,
+	transport http.RoundTripper,
 	reqID uint64,
 ) *cacheobj.CacheObj {
 	// TODO this is awkward, with 'revalidateObj' indicating whether the request is a Revalidate. Should Getting and Caching be split up? How?
@@ -136,17 +137,34 @@ func GetAndCache(
 		} else {
 			req.Header.Del(ModifiedSinceHdr)
 		}
-		respCode, respHeader, respBody, reqTime, reqRespTime, err := web.Request(transport, req)
-		log.Debugf("GetAndCache web.Request URI %v %v %v cacheKey %v rule %v parent %v error %v reval %v code %v len(body) %v (reqid %v)\n", req.URL.Scheme, req.URL.Host, req.URL.EscapedPath(), cacheKey, remapName, proxyURLStr, err, revalidateObj != nil, respCode, len(respBody), reqID)
+		respCode, respHeader, respBody, reqTime, reqRespTime, err := web.RequestStream(transport, req)
+		log.Debugf("GetAndCache web.Request URI %v %v %v cacheKey %v rule %v parent %v error %v reval %v code %v (reqid %v)\n", req.URL.Scheme, req.URL.Host, req.URL.EscapedPath(), cacheKey, remapName, proxyURLStr, err, revalidateObj != nil, respCode, reqID)
 
-		if err != nil {
-			log.Errorf("Parent error for URI %v %v %v cacheKey %v rule %v parent %v error %v (reqid %v)\n", req.URL.Scheme, req.URL.Host, req.URL.EscapedPath(), cacheKey, remapName, proxyURLStr, err, reqID)
+		connectFailure := func() *cacheobj.CacheObj {
 			code := CodeConnectFailure
 			body := []byte(http.StatusText(code))
 			return cacheobj.New(reqHeader, body, code, code, proxyURLStr, respHeader, reqTime, reqRespTime, reqRespTime, time.Time{})
 		}
+		if err != nil {
+			log.Errorf("Parent error for URI %v %v %v cacheKey %v rule %v parent %v error %v (reqid %v)\n", req.URL.Scheme, req.URL.Host, req.URL.EscapedPath(), cacheKey, remapName, proxyURLStr, err, reqID)
+			return connectFailure()
+		}
+		defer respBody.Close()
+		readBody := func() ([]byte, bool) {
+			body, err := ioutil.ReadAll(respBody)
+			if err != nil {
+				log.Errorf("Parent error reading body for URI %v %v %v cacheKey %v rule %v parent %v error %v (reqid %v)\n", req.URL.Scheme, req.URL.Host, req.URL.EscapedPath(), cacheKey, remapName, proxyURLStr, err, reqID)
+				return nil, false
+			}
+			return body, true
+		}
+
 		if _, ok := retryCodes[respCode]; ok && !cacheFailure {
-			return cacheobj.New(reqHeader, respBody, respCode, respCode, proxyURLStr, respHeader, reqTime, reqRespTime, reqRespTime, time.Time{})
+			body, ok := readBody()
+			if !ok {
+				return connectFailure()
+			}
+			return cacheobj.New(reqHeader, body, respCode, respCode, proxyURLStr, respHeader, reqTime, reqRespTime, reqRespTime, time.Time{})
 		}
 
 		log.Debugf("GetAndCache request returned %v headers %+v (reqid %v)\n", respCode, respHeader, reqID)
@@ -166,10 +184,23 @@ func GetAndCache(
 		log.Debugf("GetAndCache respCode %v (reqid %v)\n", respCode, reqID)
 		if revalidateObj == nil || respCode != http.StatusNotModified {
 			log.Debugf("GetAndCache new %v (reqid %v)\n", cacheKey, reqID)
-			obj = cacheobj.New(reqHeader, respBody, respCode, respCode, proxyURLStr, respHeader, reqTime, reqRespTime, respRespTime, lastModified)
+			obj = cacheobj.New(reqHeader, nil, respCode, respCode, proxyURLStr, respHeader, reqTime, reqRespTime, respRespTime, lastModified)
 			if !rfc.CanCache(req.Method, reqHeader, respCode, respHeader, strictRFC) {
+				body, ok := readBody()
+				if !ok {
+					return connectFailure()
+				}
+				obj.Body = body
+				obj.Size = obj.ComputeSize()
 				return obj // return without caching
 			}
+			// the body is streamed into the cache as it's read, so caches which store bodies in files never hold it in memory
+			stored, err := icache.AddStream(cache, cacheKey, obj, respBody)
+			if err != nil {
+				log.Errorf("GetAndCache caching body for URI %v %v %v cacheKey %v rule %v parent %v error %v (reqid %v)\n", req.URL.Scheme, req.URL.Host, req.URL.EscapedPath(), cacheKey, remapName, proxyURLStr, err, reqID)
+				return connectFailure()
+			}
+			return stored
 		} else {
 			log.Debugf("GetAndCache revalidating %v len(revalidateObj.Body) %v (reqid %v)\n", cacheKey, len(revalidateObj.Body), reqID)
 			// must copy, because this cache object may be concurrently read by other goroutines
@@ -189,6 +220,8 @@ func GetAndCache(
 				LastModified:     revalidateObj.LastModified,
 				Size:             revalidateObj.Size,
 				HitCount:         revalidateObj.HitCount, // no need to +1 here, the cache Get did that
+				OpenBody:         revalidateObj.OpenBody,
+				OpenBodyFile:     revalidateObj.OpenBodyFile,
 			}
 		}
 		cache.Add(cacheKey, obj) // TODO store pointer?
@@ -15,6 +15,7 @@ package cacheobj
 */
 
 import (
+	"io"
 	"net/http"
 	"time"
 
@@ -35,6 +36,10 @@ type CacheObj struct {
 	LastModified     time.Time // the origin LastModified if it exists, or Date if it doesn't
 	Size             uint64
 	HitCount         uint64 // the number of times this object was hit
+	// OpenBody, if not nil, opens the body of an object whose Body is nil, so large objects in disk caches can be streamed rather than held in memory. It isn't serialized.
+	OpenBody func() (io.ReadCloser, error)
+	// OpenBodyFile, if not nil, opens the body of an object whose Body is nil for reading at any offset, so ranges can be served without reading the whole body. It isn't serialized.
+	OpenBodyFile func() (BodyFile, error)
 }
 
 // ComputeSize computes the size of the given CacheObj. This computation is expensive, as the headers must be iterated over. Thus, the size should be computed once and stored, not computed on-the-fly for every new request for the cached object.
@@ -43,6 +48,12 @@ func (c CacheObj) ComputeSize() uint64 {
 	return uint64(len(c.Body))
 }
 
+// BodyFile is a cached body which can be read at any offset, such as a body file in a disk cache.
+type BodyFile interface {
+	io.ReaderAt
+	io.Closer
+}
+
 func New(reqHeader http.Header, bytes []byte, code int, originCode int, proxyURL string, respHeader http.Header, reqTime time.Time, reqRespTime time.Time, respRespTime time.Time, lastModified time.Time) *CacheObj {
 	obj := &CacheObj{
 		Body:             bytes,
@@ -45,7 +45,7 @@ type ATSConsistentHashNode struct {
 	Available bool
 	Name      string
 	ProxyURL  *url.URL
-	Transport *http.Transport
+	Transport http.RoundTripper
 	// pRecord fields (ParentSelection.h)
 	Hostname  string
 	Port      int
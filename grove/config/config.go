@@ -31,6 +31,8 @@ type Config struct {
 	Port         int  `json:"port"`
 	HTTPSPort    int  `json:"https_port"`
 	DisableHTTP2 bool `json:"disable_http2"`
+	// HTTP3Port is the UDP port to serve HTTP/3 (QUIC) on, with the same certificates as HTTPS. HTTP/3 is advertised to HTTPS clients with an Alt-Svc header. If 0, or HTTPS is not configured, HTTP/3 is not served.
+	HTTP3Port int `json:"http3_port"`
 	// CacheSizeBytes is the size of the memory cache, in bytes.
 	CacheSizeBytes int    `json:"cache_size_bytes"`
 	RemapRulesFile string `json:"remap_rules_file"`
@@ -66,6 +68,8 @@ type Config struct {
 type CacheFile struct {
 	Path  string `json:"path"`
 	Bytes uint64 `json:"size_bytes"`
+	// BodyDir, if set, is a directory to store each object body in its own file, streamed to and from disk, with Path holding an index of the objects and their access times. If unset, whole objects are stored in the database at Path.
+	BodyDir string `json:"body_dir"`
 }
 
 func (c Config) ErrorLog() log.LogLocation {
@@ -0,0 +1,693 @@
+package diskcache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+	"github.com/apache/trafficcontrol/v8/grove/lru"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// FileCacheMetaBucket is the bucket of the FileCache index holding the serialized objects, without their bodies.
+	FileCacheMetaBucket = "meta"
+	// FileCacheATimeBucket is the bucket of the FileCache index holding the last access time of each object, used to rebuild the LRU after a restart.
+	FileCacheATimeBucket = "atime"
+
+	// FileCacheWriteQueueLen is the number of objects which may be waiting to be written. Objects added when the queue is full aren't cached.
+	FileCacheWriteQueueLen = 64
+	// FileCacheATimeSyncInterval is how often access times are written to the index.
+	FileCacheATimeSyncInterval = 10 * time.Second
+	// FileCacheInlineMaxBytes is the largest body which is read into memory by Get. Larger bodies are streamed from their files when the object is served.
+	FileCacheInlineMaxBytes = 1024 * 1024
+)
+
+const fileCacheTmpSuffix = ".tmp"
+
+// ErrChecksum is returned when reading a cached body whose checksum doesn't match the one it was stored with.
+var ErrChecksum = errors.New("cached body checksum mismatch")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FileCache is a disk cache which stores each object body in its own file, and the rest of each object in an index database. Bodies are streamed to and from their files, so large objects don't have to be held in memory, and are verified against a checksum when they're read. AddStream stores an object as its body is read, such as from an origin, so its body is never held in memory at all.
+//
+// Objects are written asynchronously, by a single writer goroutine which also does garbage collection. Thus, an object isn't returned by Get until shortly after it's added.
+//
+// The last access time of each object is persisted to the index, so the LRU is rebuilt in the same order after a restart.
+type FileCache struct {
+	db             *bolt.DB
+	bodyDir        string
+	sizeBytes      uint64 // atomic: MUST NOT access without sync.atomic
+	maxSizeBytes   uint64 // constant: MUST NOT be modified after creation
+	inlineMaxBytes uint64
+	bodyFileSeq    uint64   // atomic: MUST NOT access without sync.atomic
+	lru            *lru.LRU // threadsafe.
+
+	writes     chan fileCacheWrite // MUST NOT send without read-locking closedM
+	writerDone chan struct{}
+	closed     bool
+	closedM    sync.RWMutex
+
+	atimes   map[string]int64 // mutexed: access times not yet written to the index. MUST NOT access without locking atimesM.
+	atimesM  sync.Mutex
+	stopSync chan struct{}
+	syncDone chan struct{}
+}
+
+// fileCacheMeta is the index entry for an object.
+type fileCacheMeta struct {
+	Obj     cacheobj.CacheObj // Obj.Body is always nil
+	File    string            // the body file, relative to the body directory
+	BodyCRC uint32
+}
+
+// fileCacheWrite is a job for the writer goroutine. If remove is true, the object is removed if its body file is still file. Otherwise, if file is set, its body was already written to file by AddStream, and obj is committed to the index; else obj is written.
+type fileCacheWrite struct {
+	key      string
+	obj      *cacheobj.CacheObj
+	remove   bool
+	file     string
+	bodySize uint64
+	bodyCRC  uint32
+}
+
+// NewFileCache creates a FileCache with its index database at indexPath and body files in bodyDir, which is created if it doesn't exist. Existing objects are loaded into the LRU in the order they were last accessed, and body files which aren't in the index are removed.
+func NewFileCache(indexPath string, bodyDir string, cacheSizeBytes uint64) (*FileCache, error) {
+	if err := os.MkdirAll(bodyDir, 0700); err != nil {
+		return nil, errors.New("creating body directory '" + bodyDir + "': " + err.Error())
+	}
+
+	db, err := bolt.Open(indexPath, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.New("opening database '" + indexPath + "': " + err.Error())
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{FileCacheMetaBucket, FileCacheATimeBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return errors.New("creating bucket '" + bucket + "': " + err.Error())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, errors.New("creating buckets for database '" + indexPath + "': " + err.Error())
+	}
+
+	c := &FileCache{
+		db:             db,
+		bodyDir:        bodyDir,
+		maxSizeBytes:   cacheSizeBytes,
+		inlineMaxBytes: FileCacheInlineMaxBytes,
+		lru:            lru.NewLRU(),
+		writes:         make(chan fileCacheWrite, FileCacheWriteQueueLen),
+		writerDone:     make(chan struct{}),
+		atimes:         map[string]int64{},
+		stopSync:       make(chan struct{}),
+		syncDone:       make(chan struct{}),
+	}
+
+	files, err := c.load()
+	if err != nil {
+		db.Close()
+		return nil, errors.New("loading index '" + indexPath + "': " + err.Error())
+	}
+	c.removeOrphanFiles(files)
+	c.gc()
+
+	go c.writer()
+	go c.syncATimes()
+	return c, nil
+}
+
+// load rebuilds the LRU and size from the index, in order of last access, and returns the set of body files in the index.
+func (c *FileCache) load() (map[string]struct{}, error) {
+	type entry struct {
+		key   string
+		size  uint64
+		atime int64
+	}
+	entries := []entry{}
+	files := map[string]struct{}{}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		metas := tx.Bucket([]byte(FileCacheMetaBucket))
+		atimes := tx.Bucket([]byte(FileCacheATimeBucket))
+		return metas.ForEach(func(k, v []byte) error {
+			meta := fileCacheMeta{}
+			if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&meta); err != nil {
+				log.Errorln("FileCache.load decoding '" + string(k) + "', ignoring: " + err.Error())
+				return nil
+			}
+			atime := int64(0)
+			if atimeBytes := atimes.Get(k); len(atimeBytes) == 8 {
+				atime = int64(binary.BigEndian.Uint64(atimeBytes))
+			}
+			entries = append(entries, entry{key: string(k), size: uint64(len(v)) + meta.Obj.Size, atime: atime})
+			files[meta.File] = struct{}{}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].atime < entries[j].atime })
+	size := uint64(0)
+	for _, e := range entries {
+		c.lru.Add(e.key, e.size) // oldest first, so the most recently accessed ends up at the front
+		size += e.size
+	}
+	atomic.StoreUint64(&c.sizeBytes, size)
+	log.Infof("FileCache loaded %d objects (%d bytes) from %s\n", len(entries), size, c.db.Path())
+	return files, nil
+}
+
+// removeOrphanFiles removes files in the body directory which aren't in the given set, such as bodies whose index entries were never committed, and partially written temporary files.
+func (c *FileCache) removeOrphanFiles(files map[string]struct{}) {
+	err := filepath.Walk(c.bodyDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(c.bodyDir, path)
+		if err != nil {
+			return nil
+		}
+		if _, ok := files[filepath.ToSlash(rel)]; ok {
+			return nil
+		}
+		log.Infoln("FileCache removing orphaned body file '" + path + "'")
+		if err := os.Remove(path); err != nil {
+			log.Errorln("FileCache removing orphaned body file '" + path + "': " + err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorln("FileCache removing orphaned body files from '" + c.bodyDir + "': " + err.Error())
+	}
+}
+
+// Add queues the object to be written, and returns immediately. If the write queue is full, the object isn't cached.
+//
+// Note the FileCache does garbage collection in its writer goroutine, so it isn't possible to determine eviction. This always returns false.
+func (c *FileCache) Add(key string, val *cacheobj.CacheObj) bool {
+	log.Debugf("FileCache.Add key '%+v' size '%+v'\n", key, val.Size)
+	if !c.queue(fileCacheWrite{key: key, obj: val}) {
+		log.Warnln("FileCache.Add write queue full, not caching '" + key + "'")
+	}
+	return false
+}
+
+// AddStream streams the body to a new body file, computing its checksum as it's written, and queues the object to be committed to the index. It returns the stored object, whose body is opened from its file, so the body is never held in memory. Unlike Add, this waits for room in the write queue, since the body has already been read.
+func (c *FileCache) AddStream(key string, val *cacheobj.CacheObj, body io.Reader) (*cacheobj.CacheObj, error) {
+	file := c.newBodyFile(key)
+	path := filepath.Join(c.bodyDir, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.New("creating body directory: " + err.Error())
+	}
+	bodySize, bodyCRC, err := writeBodyFile(path, body)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("FileCache.AddStream key '%+v' size '%+v'\n", key, bodySize)
+
+	obj := *val
+	obj.Body = nil
+	obj.Size = bodySize
+	if !c.queueWait(fileCacheWrite{key: key, obj: &obj, file: file, bodySize: bodySize, bodyCRC: bodyCRC}) {
+		os.Remove(path)
+		return nil, errors.New("cache is closed")
+	}
+	streamed := obj
+	c.setBodyOpeners(&streamed, key, file, bodyCRC)
+	return &streamed, nil
+}
+
+// queue sends the write to the writer goroutine without blocking. Returns false if the queue was full or the cache is closed.
+func (c *FileCache) queue(w fileCacheWrite) bool {
+	c.closedM.RLock()
+	defer c.closedM.RUnlock()
+	if c.closed {
+		return false
+	}
+	select {
+	case c.writes <- w:
+		return true
+	default:
+		return false
+	}
+}
+
+// queueWait sends the write to the writer goroutine, waiting for room in the queue. Returns false if the cache is closed.
+func (c *FileCache) queueWait(w fileCacheWrite) bool {
+	c.closedM.RLock()
+	defer c.closedM.RUnlock()
+	if c.closed {
+		return false
+	}
+	c.writes <- w
+	return true
+}
+
+// writer is the single goroutine which writes and removes objects, and does garbage collection. Returns when the writes channel is closed.
+func (c *FileCache) writer() {
+	defer close(c.writerDone)
+	for w := range c.writes {
+		if w.remove {
+			c.removeIfFile(w.key, w.file)
+			continue
+		}
+		if err := c.write(w); err != nil {
+			log.Errorln("FileCache writing '" + w.key + "': " + err.Error())
+			continue
+		}
+		c.gc()
+	}
+}
+
+// write streams the object body to a new file, unless AddStream already did, then commits the object to the index and removes its old body file, if any.
+func (c *FileCache) write(w fileCacheWrite) error {
+	key, obj := w.key, w.obj
+	if w.file != "" {
+		return c.commit(key, obj, w.file, w.bodySize, w.bodyCRC)
+	}
+
+	var body io.Reader
+	if obj.Body == nil && obj.OpenBody != nil {
+		bodyReader, err := obj.OpenBody()
+		if err != nil {
+			return errors.New("opening body: " + err.Error())
+		}
+		defer bodyReader.Close()
+		body = bodyReader
+	} else {
+		body = bytes.NewReader(obj.Body)
+	}
+
+	file := c.newBodyFile(key)
+	path := filepath.Join(c.bodyDir, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.New("creating body directory: " + err.Error())
+	}
+	bodySize, bodyCRC, err := writeBodyFile(path, body)
+	if err != nil {
+		return err
+	}
+	return c.commit(key, obj, file, bodySize, bodyCRC)
+}
+
+// commit writes the object, whose body has been written to file, to the index, and removes its old body file, if any. If the object can't be committed, file is removed.
+func (c *FileCache) commit(key string, obj *cacheobj.CacheObj, file string, bodySize uint64, bodyCRC uint32) error {
+	path := filepath.Join(c.bodyDir, filepath.FromSlash(file))
+	meta := fileCacheMeta{Obj: *obj, File: file, BodyCRC: bodyCRC}
+	meta.Obj.Body = nil
+	meta.Obj.Size = bodySize
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(&meta); err != nil {
+		os.Remove(path)
+		return errors.New("encoding cache object: " + err.Error())
+	}
+	metaBytes := buf.Bytes()
+
+	oldFile := ""
+	now := time.Now().UnixNano()
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		metas := tx.Bucket([]byte(FileCacheMetaBucket))
+		if oldMeta, err := decodeMeta(metas.Get([]byte(key))); err == nil {
+			oldFile = oldMeta.File
+		}
+		if err := metas.Put([]byte(key), metaBytes); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(FileCacheATimeBucket)).Put([]byte(key), encodeATime(now))
+	})
+	if err != nil {
+		os.Remove(path)
+		return errors.New("inserting in database: " + err.Error())
+	}
+	if oldFile != "" {
+		c.removeBodyFile(oldFile)
+	}
+
+	size := uint64(len(metaBytes)) + bodySize
+	oldSize := c.lru.Add(key, size)
+	atomic.AddUint64(&c.sizeBytes, size)
+	atomic.AddUint64(&c.sizeBytes, ^uint64(oldSize-1)) // subtract oldSize. Note ^uint64(0-1) is 0, so this is a no-op for new keys.
+	log.Debugf("FileCache.write key '%+v' body bytes '%+v' c.sizeBytes '%+v'\n", key, bodySize, c.Size())
+	return nil
+}
+
+// writeBodyFile streams the body to a temporary file, and renames it to path once it's completely written. Returns the body size and checksum.
+func writeBodyFile(path string, body io.Reader) (uint64, uint32, error) {
+	tmpPath := path + fileCacheTmpSuffix
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, 0, errors.New("creating body file: " + err.Error())
+	}
+	crc := crc32.New(crcTable)
+	size, err := io.Copy(io.MultiWriter(f, crc), body)
+	if err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return 0, 0, errors.New("writing body file: " + err.Error())
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, errors.New("closing body file: " + err.Error())
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, errors.New("renaming body file: " + err.Error())
+	}
+	return uint64(size), crc.Sum32(), nil
+}
+
+// newBodyFile returns a new body file name for the key, relative to the body directory. Each write gets a new file, so readers of the old body are never given a partially written one. The sequence number keeps names unique when AddStream writes the same key concurrently.
+func (c *FileCache) newBodyFile(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	seq := atomic.AddUint64(&c.bodyFileSeq, 1)
+	return name[:2] + "/" + name + "." + strconv.FormatInt(time.Now().UnixNano(), 36) + "." + strconv.FormatUint(seq, 36)
+}
+
+func (c *FileCache) removeBodyFile(file string) {
+	if err := os.Remove(filepath.Join(c.bodyDir, filepath.FromSlash(file))); err != nil && !os.IsNotExist(err) {
+		log.Errorln("FileCache removing body file '" + file + "': " + err.Error())
+	}
+}
+
+// gc deletes the least recently used objects until the FileCache's size is less than maxSizeBytes. This MUST only be called by the writer goroutine, or before it's started.
+func (c *FileCache) gc() {
+	for cacheSizeBytes := c.Size(); cacheSizeBytes > c.maxSizeBytes; {
+		log.Debugf("FileCache.gc cacheSizeBytes %+v > c.maxSizeBytes %+v\n", cacheSizeBytes, c.maxSizeBytes)
+		key, sizeBytes, exists := c.lru.RemoveOldest()
+		if !exists {
+			// should never happen
+			log.Errorf("FileCache.gc sizeBytes %v > %v maxSizeBytes, but LRU is empty!? Setting cache size to 0!\n", cacheSizeBytes, c.maxSizeBytes)
+			atomic.StoreUint64(&c.sizeBytes, 0)
+			return
+		}
+		log.Debugf("FileCache.gc deleting key '" + key + "'")
+		c.delete(key, "")
+		cacheSizeBytes = atomic.AddUint64(&c.sizeBytes, ^uint64(sizeBytes-1)) // subtract sizeBytes
+	}
+}
+
+// removeIfFile removes the object from the index and LRU if its body file is still file. This is used to remove corrupt or missing bodies, without removing a newer object written since they were read.
+func (c *FileCache) removeIfFile(key string, file string) {
+	if !c.delete(key, file) {
+		return
+	}
+	if sizeBytes, ok := c.lru.Remove(key); ok {
+		atomic.AddUint64(&c.sizeBytes, ^uint64(sizeBytes-1)) // subtract sizeBytes
+	}
+}
+
+// delete deletes the object from the index, and its body file. If onlyFile is not empty, the object is only deleted if its body file is onlyFile. Returns whether the object was deleted. This MUST only be called by the writer goroutine, or before it's started.
+func (c *FileCache) delete(key string, onlyFile string) bool {
+	file := ""
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		metas := tx.Bucket([]byte(FileCacheMetaBucket))
+		meta, err := decodeMeta(metas.Get([]byte(key)))
+		if err != nil {
+			return err
+		}
+		if onlyFile != "" && meta.File != onlyFile {
+			return nil
+		}
+		file = meta.File
+		if err := metas.Delete([]byte(key)); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(FileCacheATimeBucket)).Delete([]byte(key))
+	})
+	if err != nil {
+		log.Errorln("FileCache removing '" + key + "' from database: " + err.Error())
+		return false
+	}
+	if file == "" {
+		return false
+	}
+	c.removeBodyFile(file)
+	return true
+}
+
+// Get takes a key, and returns its value, and whether it was found, and updates the lru-ness, access time, and hitcount.
+func (c *FileCache) Get(key string) (*cacheobj.CacheObj, bool) {
+	val, found := c.Peek(key)
+	if !found {
+		return nil, false
+	}
+	if c.lru.Touch(key) {
+		c.atimesM.Lock()
+		c.atimes[key] = time.Now().UnixNano()
+		c.atimesM.Unlock()
+	}
+	atomic.AddUint64(&val.HitCount, 1)
+	return val, true
+}
+
+// Peek takes a key, and returns its value, and whether it was found, without changing the lru-ness or hitcount.
+// Bodies no larger than FileCacheInlineMaxBytes are read and verified immediately, and a corrupt or missing body is a miss. Larger bodies are returned as the object's OpenBody, which verifies the body size before returning and the checksum before returning the last of the body, and returns ErrChecksum instead of the end of a corrupt body. They're also returned as the object's OpenBodyFile, for ranges, which only verifies the size, since the checksum is of the whole body. A corrupt or missing body is removed from the cache.
+func (c *FileCache) Peek(key string) (*cacheobj.CacheObj, bool) {
+	meta := fileCacheMeta{}
+	err := c.db.View(func(tx *bolt.Tx) error {
+		metaBytes := tx.Bucket([]byte(FileCacheMetaBucket)).Get([]byte(key))
+		if metaBytes == nil {
+			return nil
+		}
+		// decoding copies the bytes, which are only valid during the transaction
+		return gob.NewDecoder(bytes.NewReader(metaBytes)).Decode(&meta)
+	})
+	if err != nil {
+		log.Errorln("FileCache.Peek getting '" + key + "' from cache: " + err.Error())
+		return nil, false
+	}
+	if meta.File == "" {
+		log.Debugln("FileCache.Peek key '" + key + "' CACHE MISS")
+		return nil, false
+	}
+
+	obj := meta.Obj
+	path := filepath.Join(c.bodyDir, filepath.FromSlash(meta.File))
+	if obj.Size <= c.inlineMaxBytes {
+		body, err := readBodyFile(path, obj.Size, meta.BodyCRC)
+		if err != nil {
+			log.Errorln("FileCache.Peek reading '" + key + "' body, removing: " + err.Error())
+			c.queue(fileCacheWrite{key: key, remove: true, file: meta.File})
+			return nil, false
+		}
+		obj.Body = body
+	} else {
+		c.setBodyOpeners(&obj, key, meta.File, meta.BodyCRC)
+	}
+
+	log.Debugln("FileCache.Peek key '" + key + "' CACHE HIT")
+	return &obj, true
+}
+
+// setBodyOpeners sets the object's OpenBody and OpenBodyFile to open its body from file. A corrupt or missing body is removed from the cache, if file is still the object's body file.
+func (c *FileCache) setBodyOpeners(obj *cacheobj.CacheObj, key string, file string, bodyCRC uint32) {
+	path := filepath.Join(c.bodyDir, filepath.FromSlash(file))
+	size := obj.Size
+	evict := func() { c.queue(fileCacheWrite{key: key, remove: true, file: file}) }
+	obj.OpenBody = func() (io.ReadCloser, error) {
+		f, err := openBodyFile(path, size, evict)
+		if err != nil {
+			return nil, err
+		}
+		return &verifyingReader{f: f, crc: crc32.New(crcTable), size: size, crcWant: bodyCRC, onCorrupt: evict}, nil
+	}
+	obj.OpenBodyFile = func() (cacheobj.BodyFile, error) {
+		return openBodyFile(path, size, evict)
+	}
+}
+
+// readBodyFile reads the body file at path, and verifies its size and checksum.
+func readBodyFile(path string, size uint64, crcWant uint32) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("opening body file: " + err.Error())
+	}
+	defer f.Close()
+	body := make([]byte, size)
+	if _, err := io.ReadFull(f, body); err != nil {
+		return nil, errors.New("reading body file: " + err.Error())
+	}
+	if n, _ := f.Read(make([]byte, 1)); n != 0 {
+		return nil, errors.New("body file larger than " + strconv.FormatUint(size, 10) + " bytes")
+	}
+	if crc32.Checksum(body, crcTable) != crcWant {
+		return nil, ErrChecksum
+	}
+	return body, nil
+}
+
+// openBodyFile opens the body file at path, and verifies its size, so a truncated or missing body is an error before any of it is sent. If the file is missing or the wrong size, onCorrupt is called.
+func openBodyFile(path string, size uint64, onCorrupt func()) (*os.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		onCorrupt()
+		return nil, errors.New("opening body file: " + err.Error())
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errors.New("checking body file: " + err.Error())
+	}
+	if uint64(info.Size()) != size {
+		f.Close()
+		onCorrupt()
+		return nil, errors.New("body file is " + strconv.FormatInt(info.Size(), 10) + " bytes, expected " + strconv.FormatUint(size, 10))
+	}
+	return f, nil
+}
+
+// verifyingReader reads a body file, and verifies its checksum before returning the last of it. If the body is corrupt, the last read returns none of its data and ErrChecksum, so the body is never completely sent, and onCorrupt is called.
+type verifyingReader struct {
+	f         *os.File
+	crc       hash.Hash32
+	read      uint64
+	size      uint64
+	crcWant   uint32
+	onCorrupt func()
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	if r.read == r.size {
+		return 0, io.EOF
+	}
+	n, err := r.f.Read(p)
+	r.crc.Write(p[:n])
+	r.read += uint64(n)
+	if r.read > r.size || (err == io.EOF && r.read < r.size) || (r.read == r.size && r.crc.Sum32() != r.crcWant) {
+		r.onCorrupt()
+		return 0, ErrChecksum
+	}
+	return n, err
+}
+
+func (r *verifyingReader) Close() error {
+	return r.f.Close()
+}
+
+// syncATimes periodically writes access times to the index, until stopSync is closed.
+func (c *FileCache) syncATimes() {
+	defer close(c.syncDone)
+	ticker := time.NewTicker(FileCacheATimeSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopSync:
+			c.writeATimes()
+			return
+		case <-ticker.C:
+			c.writeATimes()
+		}
+	}
+}
+
+// writeATimes writes the access times since the last write to the index. Access times of objects no longer in the index are discarded.
+func (c *FileCache) writeATimes() {
+	c.atimesM.Lock()
+	atimes := c.atimes
+	c.atimes = map[string]int64{}
+	c.atimesM.Unlock()
+	if len(atimes) == 0 {
+		return
+	}
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		metas := tx.Bucket([]byte(FileCacheMetaBucket))
+		atimeBucket := tx.Bucket([]byte(FileCacheATimeBucket))
+		for key, atime := range atimes {
+			if metas.Get([]byte(key)) == nil {
+				continue
+			}
+			if err := atimeBucket.Put([]byte(key), encodeATime(atime)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorln("FileCache writing access times to '" + c.db.Path() + "': " + err.Error())
+	}
+}
+
+func encodeATime(atime int64) []byte {
+	bts := make([]byte, 8)
+	binary.BigEndian.PutUint64(bts, uint64(atime))
+	return bts
+}
+
+func decodeMeta(metaBytes []byte) (fileCacheMeta, error) {
+	meta := fileCacheMeta{}
+	if metaBytes == nil {
+		return meta, nil
+	}
+	if err := gob.NewDecoder(bytes.NewReader(metaBytes)).Decode(&meta); err != nil {
+		return meta, errors.New("decoding cache object: " + err.Error())
+	}
+	return meta, nil
+}
+
+func (c *FileCache) Size() uint64 {
+	return atomic.LoadUint64(&c.sizeBytes)
+}
+
+// Close waits for queued writes, writes access times to the index, and closes the index. Objects added after Close are not cached.
+func (c *FileCache) Close() {
+	c.closedM.Lock()
+	if c.closed {
+		c.closedM.Unlock()
+		return
+	}
+	c.closed = true
+	close(c.writes)
+	c.closedM.Unlock()
+
+	<-c.writerDone
+	close(c.stopSync)
+	<-c.syncDone
+	c.db.Close()
+}
+
+func (c *FileCache) Keys() []string {
+	return c.lru.Keys()
+}
+
+func (c *FileCache) Capacity() uint64 {
+	return c.maxSizeBytes
+}
@@ -0,0 +1,275 @@
+package diskcache
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+)
+
+func newTestObj(body string) *cacheobj.CacheObj {
+	return &cacheobj.CacheObj{
+		Body:        []byte(body),
+		RespHeaders: http.Header{"Content-Type": []string{"text/plain"}},
+		Code:        http.StatusOK,
+		Size:        uint64(len(body)),
+	}
+}
+
+func openTestFileCache(t *testing.T, dir string, sizeBytes uint64) *FileCache {
+	t.Helper()
+	c, err := NewFileCache(filepath.Join(dir, "index.db"), filepath.Join(dir, "bodies"), sizeBytes)
+	if err != nil {
+		t.Fatalf("creating file cache: %v", err)
+	}
+	return c
+}
+
+func bodyFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files := []string{}
+	err := filepath.Walk(filepath.Join(dir, "bodies"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("listing body files: %v", err)
+	}
+	return files
+}
+
+func TestFileCacheAddGet(t *testing.T) {
+	dir := t.TempDir()
+	c := openTestFileCache(t, dir, 1024*1024)
+	c.Add("a", newTestObj("body a"))
+	c.Add("b", newTestObj("body b"))
+	c.Add("a", newTestObj("new body a"))
+	c.Close() // waits for the queued writes
+
+	c = openTestFileCache(t, dir, 1024*1024)
+	defer c.Close()
+	for key, expected := range map[string]string{"a": "new body a", "b": "body b"} {
+		obj, ok := c.Get(key)
+		if !ok {
+			t.Fatalf("expected '%s' to be cached after restart", key)
+		}
+		if string(obj.Body) != expected {
+			t.Errorf("expected '%s' body '%s', actual '%s'", key, expected, string(obj.Body))
+		}
+		if obj.Code != http.StatusOK || obj.RespHeaders.Get("Content-Type") != "text/plain" {
+			t.Errorf("expected '%s' code and headers to be cached, actual %v %+v", key, obj.Code, obj.RespHeaders)
+		}
+	}
+	if files := bodyFiles(t, dir); len(files) != 2 {
+		t.Errorf("expected the old body of an overwritten object to be removed, actual files: %v", files)
+	}
+	if _, ok := c.Get("c"); ok {
+		t.Error("expected uncached key to miss")
+	}
+}
+
+func TestFileCacheLRUAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	c := openTestFileCache(t, dir, 1024*1024)
+	for _, key := range []string{"a", "b", "c"} {
+		c.Add(key, newTestObj("body "+key))
+	}
+	c.Close()
+
+	c = openTestFileCache(t, dir, 1024*1024)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected 'a' to be cached")
+	}
+	size := c.Size()
+	c.Close() // writes the access time of 'a'
+
+	// shrinking the cache by a byte must evict the least recently used object, which is 'b', not 'a' which was added first but accessed last.
+	c = openTestFileCache(t, dir, size-1)
+	defer c.Close()
+	if _, ok := c.Peek("b"); ok {
+		t.Error("expected least recently used 'b' to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Peek(key); !ok {
+			t.Errorf("expected '%s' not to be evicted", key)
+		}
+	}
+	if files := bodyFiles(t, dir); len(files) != 2 {
+		t.Errorf("expected evicted body file to be removed, actual files: %v", files)
+	}
+}
+
+func TestFileCacheCorruptBody(t *testing.T) {
+	dir := t.TempDir()
+	c := openTestFileCache(t, dir, 1024*1024)
+	c.Add("a", newTestObj("body a"))
+	c.Close()
+
+	files := bodyFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 body file, actual: %v", files)
+	}
+	if err := ioutil.WriteFile(files[0], []byte("body b"), 0600); err != nil {
+		t.Fatalf("corrupting body file: %v", err)
+	}
+
+	c = openTestFileCache(t, dir, 1024*1024)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected corrupt body to miss")
+	}
+	c.Close() // waits for the removal
+
+	c = openTestFileCache(t, dir, 1024*1024)
+	defer c.Close()
+	if keys := c.Keys(); len(keys) != 0 {
+		t.Errorf("expected corrupt object to be removed, actual keys: %v", keys)
+	}
+	if c.Size() != 0 {
+		t.Errorf("expected size 0 after removing corrupt object, actual %v", c.Size())
+	}
+	if files := bodyFiles(t, dir); len(files) != 0 {
+		t.Errorf("expected corrupt body file to be removed, actual files: %v", files)
+	}
+}
+
+func TestFileCacheStreamedBody(t *testing.T) {
+	dir := t.TempDir()
+	body := string(bytes.Repeat([]byte("0123456789"), 1000))
+	c := openTestFileCache(t, dir, 1024*1024)
+	c.Add("a", newTestObj(body))
+	c.Close()
+
+	c = openTestFileCache(t, dir, 1024*1024)
+	defer c.Close()
+	c.inlineMaxBytes = 100
+
+	obj, ok := c.Get("a")
+	if !ok {
+		t.Fatal("expected 'a' to be cached")
+	}
+	if obj.Body != nil || obj.OpenBody == nil {
+		t.Fatal("expected body larger than the inline maximum to be streamed")
+	}
+	if obj.Size != uint64(len(body)) {
+		t.Errorf("expected streamed object size %v, actual %v", len(body), obj.Size)
+	}
+	streamed, err := readStreamedBody(obj)
+	if err != nil {
+		t.Fatalf("reading streamed body: %v", err)
+	}
+	if string(streamed) != body {
+		t.Error("expected streamed body to match the added body")
+	}
+
+	bodyFile, err := obj.OpenBodyFile()
+	if err != nil {
+		t.Fatalf("opening body file: %v", err)
+	}
+	part := make([]byte, 5)
+	if _, err := bodyFile.ReadAt(part, 1003); err != nil || string(part) != "34567" {
+		t.Errorf("expected reading at an offset to return '34567', actual '%s', error %v", part, err)
+	}
+	bodyFile.Close()
+
+	files := bodyFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 body file, actual: %v", files)
+	}
+	if err := ioutil.WriteFile(files[0], []byte(body[:len(body)-1]+"x"), 0600); err != nil {
+		t.Fatalf("corrupting body file: %v", err)
+	}
+	streamed, err = readStreamedBody(obj)
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected reading corrupt streamed body to return a checksum error, actual: %v", err)
+	}
+	if len(streamed) >= len(body) {
+		t.Errorf("expected the end of a corrupt streamed body to be withheld, actual %v of %v bytes read", len(streamed), len(body))
+	}
+
+	if err := ioutil.WriteFile(files[0], []byte(body[:len(body)-1]), 0600); err != nil {
+		t.Fatalf("truncating body file: %v", err)
+	}
+	if _, err := obj.OpenBody(); err == nil {
+		t.Error("expected opening a truncated streamed body to fail before reading it")
+	}
+	if _, err := obj.OpenBodyFile(); err == nil {
+		t.Error("expected opening a truncated body file to fail")
+	}
+}
+
+func TestFileCacheAddStream(t *testing.T) {
+	dir := t.TempDir()
+	body := string(bytes.Repeat([]byte("0123456789"), 1000))
+	c := openTestFileCache(t, dir, 1024*1024)
+	obj := newTestObj("")
+	obj.Body = nil
+	stored, err := c.AddStream("a", obj, bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatalf("adding streamed object: %v", err)
+	}
+	if stored.Body != nil || stored.OpenBody == nil {
+		t.Fatal("expected the stored object's body to be opened from its file")
+	}
+	if stored.Size != uint64(len(body)) {
+		t.Errorf("expected stored object size %v, actual %v", len(body), stored.Size)
+	}
+	streamed, err := readStreamedBody(stored)
+	if err != nil {
+		t.Fatalf("reading stored body: %v", err)
+	}
+	if string(streamed) != body {
+		t.Error("expected stored body to match the streamed body")
+	}
+	c.Close() // waits for the queued commit
+
+	c = openTestFileCache(t, dir, 1024*1024)
+	defer c.Close()
+	cached, ok := c.Get("a")
+	if !ok {
+		t.Fatal("expected streamed object to be cached after restart")
+	}
+	if string(cached.Body) != body || cached.Code != http.StatusOK {
+		t.Errorf("expected cached object to have the streamed body and code %v, actual code %v", http.StatusOK, cached.Code)
+	}
+	if files := bodyFiles(t, dir); len(files) != 1 {
+		t.Errorf("expected 1 body file and no temporary files, actual: %v", files)
+	}
+
+	if _, err := c.AddStream("b", newTestObj(""), iotest.ErrReader(errors.New("origin closed"))); err == nil {
+		t.Error("expected an error adding an object whose body can't be read")
+	}
+	if files := bodyFiles(t, dir); len(files) != 1 {
+		t.Errorf("expected a failed streamed body to be removed, actual: %v", files)
+	}
+}
+
+func readStreamedBody(obj *cacheobj.CacheObj) ([]byte, error) {
+	body, err := obj.OpenBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
@@ -16,9 +16,11 @@ package diskcache
 
 import (
 	"errors"
+	"io"
 
 	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
 	"github.com/apache/trafficcontrol/v8/grove/config"
+	"github.com/apache/trafficcontrol/v8/grove/icache"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 
@@ -26,11 +28,20 @@ import (
 )
 
 // MultiDiskCache is a disk cache using multiple files. It exists primarily to allow caching across multiple physical disks, but may be used for other purposes. For example, it may be more performant to use multiple files, or it may be advantageous to keep each remap rule in its own file. Keys are evenly distributed across the given files via consistent hashing.
-type MultiDiskCache []*DiskCache
+type MultiDiskCache []icache.Cache
 
+// NewMulti creates a MultiDiskCache of the given files. Files with a BodyDir are FileCaches, and files without are DiskCaches.
 func NewMulti(files []config.CacheFile) (*MultiDiskCache, error) {
-	caches := make([]*DiskCache, len(files), len(files))
+	caches := make([]icache.Cache, len(files), len(files))
 	for i, file := range files {
+		if file.BodyDir != "" {
+			cache, err := NewFileCache(file.Path, file.BodyDir, file.Bytes)
+			if err != nil {
+				return nil, errors.New("creating file cache '" + file.Path + "': " + err.Error())
+			}
+			caches[i] = cache
+			continue
+		}
 		cache, err := New(file.Path, file.Bytes)
 		if err != nil {
 			return nil, errors.New("creating disk cache '" + file.Path + "': " + err.Error())
@@ -54,6 +65,13 @@ func (c *MultiDiskCache) Add(key string, val *cacheobj.CacheObj) bool {
 	return (*c)[i].Add(key, val)
 }
 
+// AddStream streams the body into the cache the key is mapped to, if it's a StreamCache. Otherwise, the body is read into memory and added.
+func (c *MultiDiskCache) AddStream(key string, val *cacheobj.CacheObj, body io.Reader) (*cacheobj.CacheObj, error) {
+	i := c.keyIdx(key)
+	log.Debugf("MultiDiskCache.AddStream key '%+v' mapped to %+v\n", key, i)
+	return icache.AddStream((*c)[i], key, val, body)
+}
+
 func (c *MultiDiskCache) Get(key string) (*cacheobj.CacheObj, bool) {
 	i := c.keyIdx(key)
 	log.Debugf("MultiDiskCache.Get key '%+v' mapped to %+v\n", key, i)
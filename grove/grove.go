@@ -29,8 +29,11 @@ import (
 	"runtime/pprof"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"golang.org/x/net/http2"
 	"golang.org/x/sys/unix"
 
@@ -155,6 +158,12 @@ func main() {
 
 	httpHandler := buildHandler("http", strconv.Itoa(cfg.Port), httpConns, stats, pluginContext)
 	httpsHandler := buildHandler("https", strconv.Itoa(cfg.HTTPSPort), httpsConns, stats, pluginContext)
+	// HTTP/3 has no intercepted connections, so it gets an empty ConnMap, and its bytes are counted from the response writes.
+	http3Handler := buildHandler("https", strconv.Itoa(cfg.HTTP3Port), web.NewConnMap(), stats, pluginContext)
+
+	// http3Port is the port HTTP/3 is advertised on to HTTPS clients, or 0 if it isn't served. It's atomic, because it changes on reload.
+	http3Port := int64(0)
+	httpsAltSvcHandler := altSvcHandler(httpsHandler, &http3Port)
 
 	idleTimeout := time.Duration(cfg.ServerIdleTimeoutMS) * time.Millisecond
 	readTimeout := time.Duration(cfg.ServerReadTimeoutMS) * time.Millisecond
@@ -166,7 +175,13 @@ func main() {
 	httpServer := startServer(httpHandler, httpListener, httpConnStateCallback, nil, cfg.Port, idleTimeout, readTimeout, writeTimeout, cfg.DisableHTTP2, "http")
 
 	if cfg.CertFile != "" && cfg.KeyFile != "" {
-		httpsServer = startServer(httpsHandler, httpsListener, httpsConnStateCallback, tlsConfig, cfg.HTTPSPort, idleTimeout, readTimeout, writeTimeout, cfg.DisableHTTP2, "https")
+		httpsServer = startServer(httpsAltSvcHandler, httpsListener, httpsConnStateCallback, tlsConfig, cfg.HTTPSPort, idleTimeout, readTimeout, writeTimeout, cfg.DisableHTTP2, "https")
+	}
+
+	http3Server := (*http3.Server)(nil)
+	if tlsConfig != nil && cfg.HTTP3Port != 0 {
+		http3Server = startHTTP3Server(http3Handler, tlsConfig, cfg.HTTP3Port, idleTimeout)
+		atomic.StoreInt64(&http3Port, int64(cfg.HTTP3Port))
 	}
 
 	reloadConfig := func() {
@@ -255,6 +270,23 @@ func main() {
 		)
 		httpsHandler.Set(httpsCacheHandler)
 
+		http3CacheHandler := cache.NewHandler(
+			remapper,
+			uint64(cfg.ConcurrentRuleRequests),
+			stats,
+			"https",
+			strconv.Itoa(cfg.HTTP3Port),
+			web.NewConnMap(),
+			cfg.RFCCompliant,
+			cfg.ConnectionClose,
+			plugins,
+			pluginContext,
+			httpConns,
+			httpsConns,
+			cfg.InterfaceName,
+		)
+		http3Handler.Set(http3CacheHandler)
+
 		plugins.OnStartup(remapper.PluginCfg(), pluginContext, plugin.StartupData{Config: cfg, Shared: remapper.PluginSharedCfg()})
 
 		if cfg.Port != oldCfg.Port {
@@ -286,7 +318,22 @@ func main() {
 				}
 			}
 
-			httpsServer = startServer(httpsHandler, httpsListener, httpsConnStateCallback, tlsConfig, cfg.HTTPSPort, idleTimeout, readTimeout, writeTimeout, cfg.DisableHTTP2, "https")
+			httpsServer = startServer(httpsAltSvcHandler, httpsListener, httpsConnStateCallback, tlsConfig, cfg.HTTPSPort, idleTimeout, readTimeout, writeTimeout, cfg.DisableHTTP2, "https")
+		}
+
+		// HTTP/3 uses the HTTPS certificates, so it's restarted if the HTTPS listener was
+		if cfg.HTTP3Port != oldCfg.HTTP3Port || cfg.HTTPSPort != oldCfg.HTTPSPort {
+			atomic.StoreInt64(&http3Port, 0)
+			if http3Server != nil {
+				if err := http3Server.Close(); err != nil {
+					log.Errorf("closing http3 server: %v\n", err)
+				}
+				http3Server = nil
+			}
+			if tlsConfig != nil && cfg.HTTP3Port != 0 {
+				http3Server = startHTTP3Server(http3Handler, tlsConfig, cfg.HTTP3Port, idleTimeout)
+				atomic.StoreInt64(&http3Port, int64(cfg.HTTP3Port))
+			}
 		}
 	}
 
@@ -358,6 +405,34 @@ func startServer(handler http.Handler, listener net.Listener, connState func(net
 	return server
 }
 
+// startHTTP3Server starts an HTTP/3 server on the given UDP port, with the certificates in the given HTTPS tls.Config, and returns it.
+func startHTTP3Server(handler http.Handler, tlsConfig *tls.Config, port int, idleTimeout time.Duration) *http3.Server {
+	server := &http3.Server{
+		Handler:    handler,
+		Addr:       fmt.Sprintf(":%d", port),
+		TLSConfig:  http3.ConfigureTLSConfig(tlsConfig),
+		QUICConfig: &quic.Config{MaxIdleTimeout: idleTimeout},
+	}
+
+	go func() {
+		log.Infof("listening on http3://%d\n", port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("serving HTTP/3 port %v: %v\n", port, err)
+		}
+	}()
+	return server
+}
+
+// altSvcHandler returns a handler which advertises HTTP/3 on the port in http3Port with an Alt-Svc header, if it isn't 0, and then calls the given handler. The http3Port must be accessed atomically.
+func altSvcHandler(handler http.Handler, http3Port *int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if port := atomic.LoadInt64(http3Port); port != 0 {
+			w.Header().Set("Alt-Svc", `h3=":`+strconv.FormatInt(port, 10)+`"; ma=86400`)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
 func loadCerts(rules []remapdata.RemapRule) ([]tls.Certificate, error) {
 	certs := []tls.Certificate{}
 	for _, rule := range rules {
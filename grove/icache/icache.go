@@ -15,6 +15,9 @@ package icache
 */
 
 import (
+	"errors"
+	"io"
+
 	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
 )
 
@@ -29,3 +32,26 @@ type Cache interface {
 	Size() uint64
 	Close()
 }
+
+// StreamCache is a Cache which can store an object whose body is read from a stream, so the body doesn't have to be held in memory.
+type StreamCache interface {
+	Cache
+	// AddStream stores the object, with its body read from body, and returns the stored object, whose body is opened from the cache. If an error is returned, body may have been partially read.
+	AddStream(key string, val *cacheobj.CacheObj, body io.Reader) (*cacheobj.CacheObj, error)
+}
+
+// AddStream stores the object, with its body read from body, in the given cache. If the cache is a StreamCache, the body is streamed into it. Otherwise, the body is read into the object's Body, which is added. Returns the stored object.
+func AddStream(cache Cache, key string, val *cacheobj.CacheObj, body io.Reader) (*cacheobj.CacheObj, error) {
+	if sc, ok := cache.(StreamCache); ok {
+		return sc.AddStream(key, val, body)
+	}
+	bts, err := io.ReadAll(body)
+	if err != nil {
+		return nil, errors.New("reading body: " + err.Error())
+	}
+	obj := *val
+	obj.Body = bts
+	obj.Size = obj.ComputeSize()
+	cache.Add(key, &obj)
+	return &obj, nil
+}
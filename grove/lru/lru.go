@@ -48,6 +48,31 @@ func (c *LRU) Add(key string, size uint64) uint64 {
 	return 0
 }
 
+// Touch moves the key to the front of the LRU, without changing its size. Returns whether the key existed.
+func (c *LRU) Touch(key string) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	elem, ok := c.lElems[key]
+	if !ok {
+		return false
+	}
+	c.l.MoveToFront(elem)
+	return true
+}
+
+// Remove removes the key from the LRU. Returns the size of the removed key, and whether it existed.
+func (c *LRU) Remove(key string) (uint64, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	elem, ok := c.lElems[key]
+	if !ok {
+		return 0, false
+	}
+	c.l.Remove(elem)
+	delete(c.lElems, key)
+	return elem.Value.(*listObj).size, true
+}
+
 // RemoveOldest returns the key, size, and true if the LRU is nonempty; else false.
 func (c *LRU) RemoveOldest() (string, uint64, bool) {
 	c.m.Lock()
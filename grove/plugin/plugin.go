@@ -17,6 +17,7 @@ package plugin
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path"
@@ -84,10 +85,12 @@ type BeforeParentRequestData struct {
 type BeforeRespondData struct {
 	Req *http.Request
 	// CacheObj is the object to be cached, containing information about the origin request. The code, headers, and body should not be considered authoritative. Look at Code, Hdr, and Body instead, as the actual values about to be sent. Note CacheObj may be nil, if an error occurred (e.g. the Origin failed to respond).
-	CacheObj  *cacheobj.CacheObj
-	Code      *int
-	Hdr       *http.Header
-	Body      *[]byte
+	CacheObj *cacheobj.CacheObj
+	Code     *int
+	Hdr      *http.Header
+	Body     *[]byte
+	// OpenBody, if it points to a non-nil func, opens the body to stream when Body is nil, such as the body of a large object in a disk cache. Like Body, the func pointed to may be changed.
+	OpenBody  *func() (io.ReadCloser, error)
 	RemapRule string
 	Context   *interface{}
 }
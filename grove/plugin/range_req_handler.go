@@ -15,9 +15,11 @@ package plugin
 */
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"sort"
 	"strconv"
@@ -164,7 +166,7 @@ func rangeReqHandleBeforeRespond(icfg interface{}, d BeforeRespondData) {
 	if err != nil {
 		log.Errorf("Invalid Content-Length header: %v\n", d.Hdr.Get("Content-Length"))
 	}
-	body := make([]byte, 0)
+	parts := make([]rangePart, 0, len(ctx))
 	for _, thisRange := range ctx {
 		if thisRange.End == MAXINT64 || thisRange.End >= totalContentLength { // if the end range is "", or too large serve until the end
 			thisRange.End = totalContentLength - 1
@@ -176,25 +178,69 @@ func rangeReqHandleBeforeRespond(icfg interface{}, d BeforeRespondData) {
 
 		rangeString := "bytes " + strconv.FormatInt(thisRange.Start, 10) + "-" + strconv.FormatInt(thisRange.End, 10)
 		log.Debugf("range:%d-%d\n", thisRange.Start, thisRange.End)
+		part := rangePart{byteRange: thisRange}
 		if multipart {
-			body = append(body, []byte("\r\n--"+multipartBoundaryString+"\r\n")...)
-			body = append(body, []byte("Content-type: "+originalContentType+"\r\n")...)
-			body = append(body, []byte("Content-range: "+rangeString+"/"+strconv.FormatInt(totalContentLength, 10)+"\r\n\r\n")...)
+			part.header = []byte("\r\n--" + multipartBoundaryString + "\r\n")
+			part.header = append(part.header, []byte("Content-type: "+originalContentType+"\r\n")...)
+			part.header = append(part.header, []byte("Content-range: "+rangeString+"/"+strconv.FormatInt(totalContentLength, 10)+"\r\n\r\n")...)
 		} else {
 			d.Hdr.Add("Content-Range", rangeString+"/"+strconv.FormatInt(totalContentLength, 10))
 		}
-		bSlice := (*d.Body)[thisRange.Start : thisRange.End+1]
-		body = append(body, bSlice...)
+		parts = append(parts, part)
 	}
+	trailer := []byte{}
 	if multipart {
-		body = append(body, []byte("\r\n--"+multipartBoundaryString+"--\r\n")...)
+		trailer = []byte("\r\n--" + multipartBoundaryString + "--\r\n")
 	}
+
+	if *d.Body == nil && d.CacheObj != nil && d.CacheObj.OpenBodyFile != nil && d.OpenBody != nil {
+		// the object is streamed from a disk cache, so stream the ranges from its body file, rather than reading the whole body into memory
+		contentLength := int64(len(trailer))
+		for _, part := range parts {
+			contentLength += int64(len(part.header)) + part.End - part.Start + 1
+		}
+		openBodyFile := d.CacheObj.OpenBodyFile
+		*d.OpenBody = func() (io.ReadCloser, error) {
+			bodyFile, err := openBodyFile()
+			if err != nil {
+				return nil, err
+			}
+			readers := make([]io.Reader, 0, len(parts)*2+1)
+			for _, part := range parts {
+				readers = append(readers, bytes.NewReader(part.header), io.NewSectionReader(bodyFile, part.Start, part.End-part.Start+1))
+			}
+			readers = append(readers, bytes.NewReader(trailer))
+			return rangeBody{Reader: io.MultiReader(readers...), Closer: bodyFile}, nil
+		}
+		d.Hdr.Set("Content-Length", strconv.FormatInt(contentLength, 10))
+		*d.Code = http.StatusPartialContent
+		return
+	}
+
+	body := make([]byte, 0)
+	for _, part := range parts {
+		body = append(body, part.header...)
+		body = append(body, (*d.Body)[part.Start:part.End+1]...)
+	}
+	body = append(body, trailer...)
 	d.Hdr.Set("Content-Length", strconv.Itoa(len(body)))
 	*d.Body = body
 	*d.Code = http.StatusPartialContent
 	return
 }
 
+// rangePart is one range of a range response, and the multipart headers which precede it, if any.
+type rangePart struct {
+	byteRange
+	header []byte
+}
+
+// rangeBody is the body of a range response streamed from a cached body file, which closes the file when it's closed.
+type rangeBody struct {
+	io.Reader
+	io.Closer
+}
+
 func parseRange(rangeString string) (byteRange, error) {
 	parts := strings.Split(rangeString, "-")
 
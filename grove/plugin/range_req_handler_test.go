@@ -0,0 +1,88 @@
+package plugin
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
+)
+
+type testBodyFile struct {
+	*bytes.Reader
+	closed bool
+}
+
+func (f *testBodyFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestRangeReqHandleBeforeRespondStreamed(t *testing.T) {
+	body := []byte("0123456789abcdefghij")
+	bodyFile := &testBodyFile{Reader: bytes.NewReader(body)}
+	obj := &cacheobj.CacheObj{
+		OpenBody:     func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(body)), nil },
+		OpenBodyFile: func() (cacheobj.BodyFile, error) { return bodyFile, nil },
+	}
+
+	for _, test := range []struct {
+		name     string
+		ranges   []byteRange
+		expected string
+	}{
+		{name: "single", ranges: []byteRange{{Start: 2, End: 5}}, expected: "2345"},
+		{name: "suffix", ranges: []byteRange{{Start: -1, End: 3}}, expected: "hij"},
+		{name: "open", ranges: []byteRange{{Start: 15, End: MAXINT64}}, expected: "fghij"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			code := http.StatusOK
+			hdr := http.Header{"Content-Length": []string{strconv.Itoa(len(body))}}
+			respBody := []byte(nil)
+			openBody := obj.OpenBody
+			ctx := interface{}(test.ranges)
+			rangeReqHandleBeforeRespond(&rangeRequestConfig{Mode: "get_full_serve_range"}, BeforeRespondData{CacheObj: obj, Code: &code, Hdr: &hdr, Body: &respBody, OpenBody: &openBody, Context: &ctx})
+
+			if code != http.StatusPartialContent {
+				t.Errorf("expected code %v, actual %v", http.StatusPartialContent, code)
+			}
+			if respBody != nil {
+				t.Error("expected the range of a streamed body to be streamed, not read into memory")
+			}
+			reader, err := openBody()
+			if err != nil {
+				t.Fatalf("opening range body: %v", err)
+			}
+			actual, err := ioutil.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("reading range body: %v", err)
+			}
+			if err := reader.Close(); err != nil || !bodyFile.closed {
+				t.Errorf("expected closing the range body to close the body file, error %v", err)
+			}
+			if string(actual) != test.expected {
+				t.Errorf("expected range body '%s', actual '%s'", test.expected, actual)
+			}
+			if hdr.Get("Content-Length") != strconv.Itoa(len(test.expected)) {
+				t.Errorf("expected Content-Length %v, actual %v", len(test.expected), hdr.Get("Content-Length"))
+			}
+		})
+	}
+}
@@ -15,6 +15,8 @@ package remap
 */
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -33,6 +35,8 @@ import (
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+
+	"golang.org/x/net/http2"
 )
 
 type HTTPRequestRemapper interface {
@@ -107,7 +111,7 @@ type Remapping struct {
 	RetryNum        int
 	RetryCodes      map[int]struct{}
 	Cache           icache.Cache
-	Transport       *http.Transport
+	Transport       http.RoundTripper
 }
 
 // RemappingProducer takes an HTTP Request and returns a Remapping to be used for that request.
@@ -126,6 +130,23 @@ func (p *RemappingProducer) Name() string                      { return p.rule.N
 func (p *RemappingProducer) DSCP() int                         { return p.rule.DSCP }
 func (p *RemappingProducer) PluginCfg() map[string]interface{} { return p.rule.Plugins }
 func (p *RemappingProducer) Cache() icache.Cache               { return p.rule.Cache }
+
+// StaleWhileRevalidate returns the RFC5861 stale-while-revalidate window for a cached response with the given Cache-Control, and whether it has one. The rule's window overrides the response's.
+func (p *RemappingProducer) StaleWhileRevalidate(respCC rfc.CacheControlMap) (time.Duration, bool) {
+	if p.rule.StaleWhileRevalidate != nil {
+		return *p.rule.StaleWhileRevalidate, *p.rule.StaleWhileRevalidate > 0
+	}
+	return rfc.StaleWhileRevalidate(respCC)
+}
+
+// StaleIfError returns the RFC5861 stale-if-error window for a cached response with the given Cache-Control, and whether it has one. The rule's window overrides the response's.
+func (p *RemappingProducer) StaleIfError(respCC rfc.CacheControlMap) (time.Duration, bool) {
+	if p.rule.StaleIfError != nil {
+		return *p.rule.StaleIfError, *p.rule.StaleIfError > 0
+	}
+	return rfc.StaleIfError(respCC)
+}
+
 func (p *RemappingProducer) FirstFQDN() string {
 	// TODO verify To is not allowed to be constructed with < 1 element
 	return strings.TrimPrefix(strings.TrimPrefix(p.rule.To[0].URL, "http://"), "https://")
@@ -276,23 +297,30 @@ type RemapRulesBase struct {
 
 type RemapRulesJSON struct {
 	RemapRulesBase
-	Rules           []RemapRuleJSON            `json:"rules"`
-	RetryCodes      *[]int                     `json:"retry_codes"`
-	TimeoutMS       *int                       `json:"timeout_ms"`
-	ParentSelection *string                    `json:"parent_selection"`
-	Stats           RemapRulesStatsJSON        `json:"stats"`
-	Plugins         map[string]json.RawMessage `json:"plugins"`
+	Rules           []RemapRuleJSON     `json:"rules"`
+	RetryCodes      *[]int              `json:"retry_codes"`
+	TimeoutMS       *int                `json:"timeout_ms"`
+	ParentSelection *string             `json:"parent_selection"`
+	ParentProtocol  *string             `json:"parent_protocol"`
+	Stats           RemapRulesStatsJSON `json:"stats"`
+	// StaleWhileRevalidateMS and StaleIfErrorMS override the RFC5861 stale-while-revalidate and stale-if-error windows of cached responses. 0 disables them.
+	StaleWhileRevalidateMS *int                       `json:"stale_while_revalidate_ms"`
+	StaleIfErrorMS         *int                       `json:"stale_if_error_ms"`
+	Plugins                map[string]json.RawMessage `json:"plugins"`
 }
 
 type RemapRules struct {
 	RemapRulesBase
-	Rules           []remapdata.RemapRule
-	RetryCodes      map[int]struct{}
-	Timeout         *time.Duration
-	ParentSelection *remapdata.ParentSelectionType
-	Stats           remapdata.RemapRulesStats
-	Plugins         map[string]interface{}
-	Cache           icache.Cache
+	Rules                []remapdata.RemapRule
+	RetryCodes           map[int]struct{}
+	Timeout              *time.Duration
+	ParentSelection      *remapdata.ParentSelectionType
+	ParentProtocol       remapdata.ParentProtocol
+	Stats                remapdata.RemapRulesStats
+	StaleWhileRevalidate *time.Duration
+	StaleIfError         *time.Duration
+	Plugins              map[string]interface{}
+	Cache                icache.Cache
 }
 
 type RemapRuleToJSON struct {
@@ -308,14 +336,17 @@ type HdrModder interface {
 
 type RemapRuleJSON struct {
 	remapdata.RemapRuleBase
-	TimeoutMS       *int                       `json:"timeout_ms"`
-	ParentSelection *string                    `json:"parent_selection"`
-	To              []RemapRuleToJSON          `json:"to"`
-	Allow           []string                   `json:"allow"`
-	Deny            []string                   `json:"deny"`
-	RetryCodes      *[]int                     `json:"retry_codes"`
-	CacheName       *string                    `json:"cache_name"`
-	Plugins         map[string]json.RawMessage `json:"plugins"`
+	TimeoutMS              *int                       `json:"timeout_ms"`
+	ParentSelection        *string                    `json:"parent_selection"`
+	ParentProtocol         *string                    `json:"parent_protocol"`
+	To                     []RemapRuleToJSON          `json:"to"`
+	StaleWhileRevalidateMS *int                       `json:"stale_while_revalidate_ms"`
+	StaleIfErrorMS         *int                       `json:"stale_if_error_ms"`
+	Allow                  []string                   `json:"allow"`
+	Deny                   []string                   `json:"deny"`
+	RetryCodes             *[]int                     `json:"retry_codes"`
+	CacheName              *string                    `json:"cache_name"`
+	Plugins                map[string]json.RawMessage `json:"plugins"`
 }
 
 // LoadRemapRules returns the loaded rules, the global plugins, the Stats remap rules, and any error
@@ -358,6 +389,18 @@ func LoadRemapRules(path string, pluginConfigLoaders map[string]plugin.LoadFunc,
 			return nil, nil, nil, fmt.Errorf("error parsing rules: parent selection invalid: '%v'", remapRulesJSON.ParentSelection)
 		}
 	}
+	remapRules.ParentProtocol = remapdata.ParentProtocolHTTP1
+	if remapRulesJSON.ParentProtocol != nil {
+		if remapRules.ParentProtocol = remapdata.ParentProtocolFromString(*remapRulesJSON.ParentProtocol); remapRules.ParentProtocol == remapdata.ParentProtocolInvalid {
+			return nil, nil, nil, fmt.Errorf("error parsing rules: parent protocol invalid: '%v'", *remapRulesJSON.ParentProtocol)
+		}
+	}
+	if remapRules.StaleWhileRevalidate, err = makeStaleWindow(remapRulesJSON.StaleWhileRevalidateMS); err != nil {
+		return nil, nil, nil, fmt.Errorf("error parsing rules: stale_while_revalidate_ms %v", err)
+	}
+	if remapRules.StaleIfError, err = makeStaleWindow(remapRulesJSON.StaleIfErrorMS); err != nil {
+		return nil, nil, nil, fmt.Errorf("error parsing rules: stale_if_error_ms %v", err)
+	}
 	if remapRulesJSON.Stats.Allow != nil {
 		if remapRules.Stats.Allow, err = makeIPNets(remapRulesJSON.Stats.Allow); err != nil {
 			return nil, nil, nil, fmt.Errorf("error parsing rules allows: %v", err)
@@ -418,6 +461,17 @@ func LoadRemapRules(path string, pluginConfigLoaders map[string]plugin.LoadFunc,
 			rule.RetryNum = remapRules.RetryNum
 		}
 
+		if rule.StaleWhileRevalidate, err = makeStaleWindow(jsonRule.StaleWhileRevalidateMS); err != nil {
+			return nil, nil, nil, fmt.Errorf("error parsing rule %v stale_while_revalidate_ms %v", rule.Name, err)
+		} else if rule.StaleWhileRevalidate == nil {
+			rule.StaleWhileRevalidate = remapRules.StaleWhileRevalidate
+		}
+		if rule.StaleIfError, err = makeStaleWindow(jsonRule.StaleIfErrorMS); err != nil {
+			return nil, nil, nil, fmt.Errorf("error parsing rule %v stale_if_error_ms %v", rule.Name, err)
+		} else if rule.StaleIfError == nil {
+			rule.StaleIfError = remapRules.StaleIfError
+		}
+
 		if rule.PluginsShared == nil {
 			rule.PluginsShared = remapRules.PluginsShared
 		}
@@ -437,6 +491,13 @@ func LoadRemapRules(path string, pluginConfigLoaders map[string]plugin.LoadFunc,
 		if rule.Deny, err = makeIPNets(jsonRule.Deny); err != nil {
 			return nil, nil, nil, fmt.Errorf("error parsing rule %v denys: %v", rule.Name, err)
 		}
+		if jsonRule.ParentProtocol != nil {
+			if rule.ParentProtocol = remapdata.ParentProtocolFromString(*jsonRule.ParentProtocol); rule.ParentProtocol == remapdata.ParentProtocolInvalid {
+				return nil, nil, nil, fmt.Errorf("error parsing rule %v parent protocol invalid: '%v'", rule.Name, *jsonRule.ParentProtocol)
+			}
+		} else {
+			rule.ParentProtocol = remapRules.ParentProtocol
+		}
 		if rule.To, err = makeTo(jsonRule.To, rule, baseTransport); err != nil {
 			return nil, nil, nil, fmt.Errorf("error parsing rule %v to: %v", rule.Name, err)
 		}
@@ -467,6 +528,18 @@ func LoadRemapRules(path string, pluginConfigLoaders map[string]plugin.LoadFunc,
 	return rules, remapRules.Plugins, &remapRules.Stats, nil
 }
 
+// makeStaleWindow returns the duration of the given stale window in milliseconds, or nil if it's nil.
+func makeStaleWindow(ms *int) (*time.Duration, error) {
+	if ms == nil {
+		return nil, nil
+	}
+	if *ms < 0 {
+		return nil, fmt.Errorf("must not be negative: %v", *ms)
+	}
+	d := time.Duration(*ms) * time.Millisecond
+	return &d, nil
+}
+
 const DefaultReplicas = 1024
 
 func makeRuleHash(rule remapdata.RemapRule) chash.ATSConsistentHash {
@@ -481,8 +554,47 @@ func makeRuleHash(rule remapdata.RemapRule) chash.ATSConsistentHash {
 	return h
 }
 
+// makeRuleTransport returns the transport for requests to the parents of a rule with the given parent protocol. HTTP/1.1 rules share the base transport, while HTTP/2 rules each get their own transport, and thus their own pool of connections to their parents.
+func makeRuleTransport(protocol remapdata.ParentProtocol, baseTransport *http.Transport) http.RoundTripper {
+	switch protocol {
+	case remapdata.ParentProtocolH2:
+		transport := baseTransport.Clone()
+		transport.ForceAttemptHTTP2 = true // the custom dialer otherwise disables HTTP/2
+		return transport
+	case remapdata.ParentProtocolH2C:
+		dialContext := baseTransport.DialContext
+		return &http2.Transport{
+			AllowHTTP: true,
+			// h2c is HTTP/2 over a plain TCP connection, which the http2 Transport only supports by dialing it in place of TLS
+			DialTLSContext: func(ctx context.Context, network string, addr string, _ *tls.Config) (net.Conn, error) {
+				return dialContext(ctx, network, addr)
+			},
+			IdleConnTimeout: baseTransport.IdleConnTimeout,
+		}
+	default:
+		return baseTransport
+	}
+}
+
+// validateParentProtocol returns an error if the parent URL's scheme can't be used with the protocol.
+func validateParentProtocol(protocol remapdata.ParentProtocol, parentURL string) error {
+	u, err := url.Parse(parentURL)
+	if err != nil {
+		return errors.New("parsing url: " + err.Error())
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if protocol == remapdata.ParentProtocolH2 && scheme != "https" {
+		return errors.New("parent protocol h2 requires an https parent, use h2c for http parents")
+	}
+	if protocol == remapdata.ParentProtocolH2C && scheme != "http" {
+		return errors.New("parent protocol h2c requires an http parent, use h2 for https parents")
+	}
+	return nil
+}
+
 func makeTo(tosJSON []RemapRuleToJSON, rule remapdata.RemapRule, baseTransport *http.Transport) ([]remapdata.RemapRuleTo, error) {
 	tos := make([]remapdata.RemapRuleTo, len(tosJSON))
+	ruleTransport := makeRuleTransport(rule.ParentProtocol, baseTransport)
 	for i, toJSON := range tosJSON {
 		if toJSON.Weight == nil {
 			w := 1.0
@@ -490,18 +602,26 @@ func makeTo(tosJSON []RemapRuleToJSON, rule remapdata.RemapRule, baseTransport *
 		}
 		to := remapdata.RemapRuleTo{RemapRuleToBase: toJSON.RemapRuleToBase}
 
-		to.Transport = baseTransport
+		if err := validateParentProtocol(rule.ParentProtocol, to.URL); err != nil {
+			return nil, fmt.Errorf("error parsing to %v: %v", to.URL, err)
+		}
+
+		to.Transport = ruleTransport
 		if toJSON.ProxyURL != nil {
 			proxyURL, err := url.Parse(*toJSON.ProxyURL)
 			if err != nil {
 				return nil, fmt.Errorf("error parsing to %v proxy_url: %v", to.URL, toJSON.ProxyURL)
 			}
+			if rule.ParentProtocol == remapdata.ParentProtocolH2C {
+				return nil, fmt.Errorf("error parsing to %v: proxy_url can't be used with parent protocol h2c", to.URL)
+			}
 			to.ProxyURL = proxyURL
 			// See b7953cc239 for the reasoning behind copying the Transport.
 			newTransport := *baseTransport.Clone()
 			if proxyURL != nil && proxyURL.Host != "" {
 				newTransport.Proxy = http.ProxyURL(proxyURL)
 			}
+			newTransport.ForceAttemptHTTP2 = rule.ParentProtocol == remapdata.ParentProtocolH2
 			to.Transport = &newTransport
 		}
 
@@ -0,0 +1,79 @@
+package remap
+
+/*
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/grove/remapdata"
+
+	"golang.org/x/net/http2"
+)
+
+func TestMakeToParentProtocol(t *testing.T) {
+	baseTransport := NewRemappingTransport(time.Second, time.Second, 10, time.Second)
+	tos := func(urls ...string) []RemapRuleToJSON {
+		tosJSON := []RemapRuleToJSON{}
+		for _, u := range urls {
+			tosJSON = append(tosJSON, RemapRuleToJSON{RemapRuleToBase: remapdata.RemapRuleToBase{URL: u}})
+		}
+		return tosJSON
+	}
+
+	retryNum := 1
+	timeout := time.Second
+	rule := remapdata.RemapRule{RemapRuleBase: remapdata.RemapRuleBase{RetryNum: &retryNum}, Timeout: &timeout, RetryCodes: map[int]struct{}{}, ParentProtocol: remapdata.ParentProtocolHTTP1}
+	to, err := makeTo(tos("http://a.example", "https://b.example"), rule, baseTransport)
+	if err != nil {
+		t.Fatalf("expected http/1.1 parents to be valid, actual error: %v", err)
+	}
+	if to[0].Transport != baseTransport || to[1].Transport != baseTransport {
+		t.Error("expected http/1.1 parents to use the base transport")
+	}
+
+	rule.ParentProtocol = remapdata.ParentProtocolH2
+	to, err = makeTo(tos("https://a.example", "https://b.example"), rule, baseTransport)
+	if err != nil {
+		t.Fatalf("expected h2 https parents to be valid, actual error: %v", err)
+	}
+	h2Transport, ok := to[0].Transport.(*http.Transport)
+	if !ok || h2Transport == baseTransport || !h2Transport.ForceAttemptHTTP2 {
+		t.Errorf("expected h2 parents to use a new HTTP/2 transport, actual %T %+v", to[0].Transport, to[0].Transport)
+	}
+	if to[1].Transport != to[0].Transport {
+		t.Error("expected the parents of a rule to share a transport")
+	}
+	if _, err := makeTo(tos("http://a.example"), rule, baseTransport); err == nil {
+		t.Error("expected h2 http parent to be invalid")
+	}
+
+	rule.ParentProtocol = remapdata.ParentProtocolH2C
+	to, err = makeTo(tos("http://a.example"), rule, baseTransport)
+	if err != nil {
+		t.Fatalf("expected h2c http parent to be valid, actual error: %v", err)
+	}
+	if h2cTransport, ok := to[0].Transport.(*http2.Transport); !ok || !h2cTransport.AllowHTTP {
+		t.Errorf("expected h2c parent to use an http2 transport allowing HTTP, actual %T", to[0].Transport)
+	}
+	if _, err := makeTo(tos("https://a.example"), rule, baseTransport); err == nil {
+		t.Error("expected h2c https parent to be invalid")
+	}
+	proxyURL := "http://proxy.example"
+	if _, err := makeTo([]RemapRuleToJSON{{RemapRuleToBase: remapdata.RemapRuleToBase{URL: "http://a.example"}, ProxyURL: &proxyURL}}, rule, baseTransport); err == nil {
+		t.Error("expected h2c parent with a proxy to be invalid")
+	}
+}
@@ -61,6 +61,42 @@ func ParentSelectionTypeFromString(s string) ParentSelectionType {
 	return ParentSelectionTypeInvalid
 }
 
+// ParentProtocol is the protocol to use for requests to parents.
+type ParentProtocol string
+
+const (
+	ParentProtocolHTTP1   = ParentProtocol("http/1.1")
+	ParentProtocolH2      = ParentProtocol("h2")  // HTTP/2 over TLS, negotiated with ALPN
+	ParentProtocolH2C     = ParentProtocol("h2c") // HTTP/2 over cleartext TCP, with prior knowledge
+	ParentProtocolInvalid = ParentProtocol("")
+)
+
+func (p ParentProtocol) String() string {
+	switch p {
+	case ParentProtocolHTTP1:
+		return "http/1.1"
+	case ParentProtocolH2:
+		return "h2"
+	case ParentProtocolH2C:
+		return "h2c"
+	default:
+		return "invalid"
+	}
+}
+
+func ParentProtocolFromString(s string) ParentProtocol {
+	s = strings.ToLower(s)
+	switch s {
+	case "http/1.1":
+		return ParentProtocolHTTP1
+	case "h2":
+		return ParentProtocolH2
+	case "h2c":
+		return ParentProtocolH2C
+	}
+	return ParentProtocolInvalid
+}
+
 type RemapRulesStats struct {
 	Allow []*net.IPNet
 	Deny  []*net.IPNet
@@ -104,13 +140,17 @@ type RemapRule struct {
 	RemapRuleBase
 	Timeout         *time.Duration
 	ParentSelection *ParentSelectionType
-	To              []RemapRuleTo
-	Allow           []*net.IPNet
-	Deny            []*net.IPNet
-	RetryCodes      map[int]struct{}
-	ConsistentHash  chash.ATSConsistentHash
-	Cache           icache.Cache
-	Plugins         map[string]interface{}
+	ParentProtocol  ParentProtocol
+	// StaleWhileRevalidate and StaleIfError, if not nil, override the RFC5861 windows of cached responses.
+	StaleWhileRevalidate *time.Duration
+	StaleIfError         *time.Duration
+	To                   []RemapRuleTo
+	Allow                []*net.IPNet
+	Deny                 []*net.IPNet
+	RetryCodes           map[int]struct{}
+	ConsistentHash       chash.ATSConsistentHash
+	Cache                icache.Cache
+	Plugins              map[string]interface{}
 }
 
 func (r *RemapRule) Allowed(ip net.IP) bool {
@@ -134,7 +174,7 @@ func (r *RemapRule) Allowed(ip net.IP) bool {
 }
 
 // URI takes a request URI and maps it to the real URI to proxy-and-cache. The `failures` parameter indicates how many parents have tried and failed, indicating to skip to the nth hashed parent. Returns the URI to request, and the proxy URL (if any)
-func (r RemapRule) URI(fromURI string, path string, query string, failures int) (string, *url.URL, *http.Transport) {
+func (r RemapRule) URI(fromURI string, path string, query string, failures int) (string, *url.URL, http.RoundTripper) {
 	fromHash := path
 	if r.QueryString.Remap && query != "" {
 		fromHash += "?" + query
@@ -152,7 +192,7 @@ func (r RemapRule) URI(fromURI string, path string, query string, failures int)
 }
 
 // uriGetTo is a helper func for URI. It returns the To URL, based on the Parent Selection type. In the event of failure, it logs the error and returns the first parent. Also returns the URL's Proxy URI (if any).
-func (r RemapRule) uriGetTo(fromURI string, failures int) (string, *url.URL, *http.Transport) {
+func (r RemapRule) uriGetTo(fromURI string, failures int) (string, *url.URL, http.RoundTripper) {
 	switch *r.ParentSelection {
 	case ParentSelectionTypeConsistentHash:
 		return r.uriGetToConsistentHash(fromURI, failures)
@@ -163,7 +203,7 @@ func (r RemapRule) uriGetTo(fromURI string, failures int) (string, *url.URL, *ht
 }
 
 // uriGetToConsistentHash is a helper func for URI, uriGetTo. It returns the To URL using Consistent Hashing. In the event of failure, it logs the error and returns the first parent. Also returns the Proxy URI (if any).
-func (r RemapRule) uriGetToConsistentHash(fromURI string, failures int) (string, *url.URL, *http.Transport) {
+func (r RemapRule) uriGetToConsistentHash(fromURI string, failures int) (string, *url.URL, http.RoundTripper) {
 	// fmt.Printf("DEBUGL uriGetToConsistentHash RemapRule %+v\n", r)
 	if r.ConsistentHash == nil {
 		log.Errorf("RemapRule.URI: Rule '%v': Parent Selection Type ConsistentHash, but rule.ConsistentHash is nil! Using first parent\n", r.Name)
@@ -216,7 +256,7 @@ type RemapRuleTo struct {
 	ProxyURL   *url.URL
 	Timeout    *time.Duration
 	RetryCodes map[int]struct{}
-	Transport  *http.Transport
+	Transport  http.RoundTripper
 }
 
 type QueryStringRule struct {
@@ -15,6 +15,8 @@ package tiercache
 */
 
 import (
+	"io"
+
 	"github.com/apache/trafficcontrol/v8/grove/cacheobj"
 	"github.com/apache/trafficcontrol/v8/grove/icache"
 
@@ -43,7 +45,7 @@ func (c *TierCache) Get(key string) (*cacheobj.CacheObj, bool) {
 	log.Debugf("TierCache.Get '"+key+"' FOUND FIRST: %+v\n", ok)
 	if !ok {
 		v, ok = c.second.Get(key)
-		if ok {
+		if ok && !isStreamed(v) {
 			// if it was in second but not first, add back to first (LRU behavior)
 			c.first.Add(key, v)
 		}
@@ -63,12 +65,34 @@ func (c *TierCache) Peek(key string) (*cacheobj.CacheObj, bool) {
 }
 
 // Add adds to both internal caches. Returns whether either reported an eviction.
+// Objects whose bodies are streamed from the second cache, rather than held in memory, are only added to the second.
 func (c *TierCache) Add(key string, val *cacheobj.CacheObj) bool {
-	aevict := c.first.Add(key, val)
+	aevict := false
+	if !isStreamed(val) {
+		aevict = c.first.Add(key, val)
+	}
 	bevict := c.second.Add(key, val)
 	return aevict || bevict
 }
 
+// AddStream streams the body into the second cache, if it's a StreamCache, and doesn't add the object to the first, since its body isn't held in memory. Small objects are added to the first when they're next gotten from the second. If the second isn't a StreamCache, the body is read into memory and added to both.
+func (c *TierCache) AddStream(key string, val *cacheobj.CacheObj, body io.Reader) (*cacheobj.CacheObj, error) {
+	if sc, ok := c.second.(icache.StreamCache); ok {
+		return sc.AddStream(key, val, body)
+	}
+	obj, err := icache.AddStream(c.second, key, val, body) // reads the body into memory, since the second isn't a StreamCache
+	if err != nil {
+		return nil, err
+	}
+	c.first.Add(key, obj)
+	return obj, nil
+}
+
+// isStreamed returns whether the object's body is opened on demand, rather than held in memory. Such objects are too large for the first cache, and their bodies may be removed from the second.
+func isStreamed(obj *cacheobj.CacheObj) bool {
+	return obj.Body == nil && obj.OpenBody != nil
+}
+
 // Size returns the size of the second cache. This is because, since all objects are added to both, they are presumed to have the same content, and the second is presumed to be larger.
 //
 // For example, if the first is a memory cache and the second is a disk cache, it's most useful to report the size used on disk.
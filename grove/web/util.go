@@ -16,6 +16,7 @@ package web
 
 import (
 	"errors"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -108,26 +109,35 @@ func TryFlush(w http.ResponseWriter) {
 }
 
 // request makes the given request and returns its response code, headers, body, the request time, response time, and any error.
-func Request(transport *http.Transport, r *http.Request) (int, http.Header, []byte, time.Time, time.Time, error) {
-	log.Debugf("request requesting %v headers %v\n", r.RequestURI, r.Header)
-	rr := r
-
-	reqTime := time.Now()
-	resp, err := transport.RoundTrip(rr)
-	respTime := time.Now()
+func Request(transport http.RoundTripper, r *http.Request) (int, http.Header, []byte, time.Time, time.Time, error) {
+	code, header, respBody, reqTime, respTime, err := RequestStream(transport, r)
 	if err != nil {
-		return 0, nil, nil, reqTime, respTime, errors.New("request error: " + err.Error())
+		return 0, nil, nil, reqTime, respTime, err
 	}
-	defer resp.Body.Close()
+	defer respBody.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(respBody)
 	// TODO determine if respTime should go here
 
 	if err != nil {
 		return 0, nil, nil, reqTime, respTime, errors.New("reading response body: " + err.Error())
 	}
 
-	return resp.StatusCode, resp.Header, body, reqTime, respTime, nil
+	return code, header, body, reqTime, respTime, nil
+}
+
+// RequestStream is like Request, but returns the response body unread, so it doesn't have to be held in memory. If no error is returned, the caller MUST close the body.
+func RequestStream(transport http.RoundTripper, r *http.Request) (int, http.Header, io.ReadCloser, time.Time, time.Time, error) {
+	log.Debugf("request requesting %v headers %v\n", r.RequestURI, r.Header)
+	rr := r
+
+	reqTime := time.Now()
+	resp, err := transport.RoundTrip(rr)
+	respTime := time.Now()
+	if err != nil {
+		return 0, nil, nil, reqTime, respTime, errors.New("request error: " + err.Error())
+	}
+	return resp.StatusCode, resp.Header, resp.Body, reqTime, respTime, nil
 }
 
 // Respond writes the given code, header, and body to the ResponseWriter. If connectionClose, a Connection: Close header is also written. Returns the bytes written, and any error.
@@ -145,6 +155,18 @@ func Respond(w http.ResponseWriter, code int, header http.Header, body []byte, c
 	return uint64(bytesWritten), err
 }
 
+// RespondStream is like Respond, but copies the body from the given reader, so it doesn't have to be held in memory.
+func RespondStream(w http.ResponseWriter, code int, header http.Header, body io.Reader, connectionClose bool) (uint64, error) {
+	dH := w.Header()
+	CopyHeaderTo(header, &dH)
+	if connectionClose {
+		dH.Add("Connection", "close")
+	}
+	w.WriteHeader(code)
+	bytesWritten, err := io.Copy(w, body)
+	return uint64(bytesWritten), err
+}
+
 // ServeReqErr writes the appropriate response to the client, via given writer, for a generic request error. Returns the code sent, the body bytes written, and any write error.
 func ServeReqErr(w http.ResponseWriter) (int, uint64, error) {
 	code := http.StatusBadRequest
@@ -20,25 +20,118 @@ package main
  */
 
 import (
+	"bufio"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type vstats struct {
-	ProcLoadavg  string `json:"proc.loadavg"`
-	ProcNetDev   string `json:"proc.net.dev"`
-	InfSpeed     int64  `json:"inf_speed"`
-	NotAvailable bool   `json:"not_available"`
-	// TODO: stats
+	ProcLoadavg  string                `json:"proc.loadavg"`
+	ProcNetDev   string                `json:"proc.net.dev"`
+	InfSpeed     int64                 `json:"inf_speed"`
+	NotAvailable bool                  `json:"not_available"`
+	Stats        json.RawMessage       `json:"stats,omitempty"`
+	RemapStats   map[string]remapStats `json:"remap_stats"`
 }
 
+type remapStats struct {
+	InBytes   uint64 `json:"in_bytes"`
+	OutBytes  uint64 `json:"out_bytes"`
+	Status2xx uint64 `json:"status_2xx"`
+	Status3xx uint64 `json:"status_3xx"`
+	Status4xx uint64 `json:"status_4xx"`
+	Status5xx uint64 `json:"status_5xx"`
+}
+
+// hostStats counts the requests Varnish has served for each host, from its
+// logs, because Varnish has no counters per host.
+type hostStats struct {
+	m     sync.Mutex
+	stats map[string]remapStats
+}
+
+func (h *hostStats) add(line string) {
+	fields := strings.Fields(line)
+	if len(fields) != 4 {
+		return
+	}
+	host := strings.ToLower(fields[0])
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	if host == "" || host == "-" {
+		return
+	}
+	status, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return
+	}
+	inBytes, _ := strconv.ParseUint(fields[2], 10, 64)
+	outBytes, _ := strconv.ParseUint(fields[3], 10, 64)
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	stats := h.stats[host]
+	stats.InBytes += inBytes
+	stats.OutBytes += outBytes
+	switch status / 100 {
+	case 2:
+		stats.Status2xx++
+	case 3:
+		stats.Status3xx++
+	case 4:
+		stats.Status4xx++
+	case 5:
+		stats.Status5xx++
+	}
+	h.stats[host] = stats
+}
+
+func (h *hostStats) get() map[string]remapStats {
+	h.m.Lock()
+	defer h.m.Unlock()
+	stats := make(map[string]remapStats, len(h.stats))
+	for host, s := range h.stats {
+		stats[host] = s
+	}
+	return stats
+}
+
+// follow counts the requests logged by varnishncsa, restarting it if it
+// exits, e.g. because Varnish was restarted.
+func (h *hostStats) follow() {
+	for {
+		cmd := exec.Command("varnishncsa", "-F", "%{Host}i %s %I %O")
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			log.Printf("failed to get varnishncsa output: %s\n", err)
+		} else if err := cmd.Start(); err != nil {
+			log.Printf("failed to start varnishncsa: %s\n", err)
+		} else {
+			scanner := bufio.NewScanner(stdout)
+			for scanner.Scan() {
+				h.add(scanner.Text())
+			}
+			if err := cmd.Wait(); err != nil {
+				log.Printf("varnishncsa stopped: %s\n", err)
+			}
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+var requests = hostStats{stats: map[string]remapStats{}}
+
 func getSystemData(inf string) vstats {
 	var vstats vstats
 	loadavg, err := os.ReadFile("/proc/loadavg")
@@ -79,6 +172,14 @@ func getSystemData(inf string) vstats {
 	if cmd.ProcessState.ExitCode() != 0 {
 		vstats.NotAvailable = true
 	}
+
+	varnishstat, err := exec.Command("varnishstat", "-j").Output()
+	if err != nil {
+		log.Printf("failed to run varnishstat: %s\n", err)
+	} else if json.Valid(varnishstat) {
+		vstats.Stats = varnishstat
+	}
+	vstats.RemapStats = requests.get()
 	return vstats
 }
 
@@ -103,6 +204,7 @@ func main() {
 	flag.IntVar(&port, "port", 2000, "port to run vstats on")
 
 	flag.Parse()
+	go requests.follow()
 	http.HandleFunc("/", getStats)
 
 	listenAddress := fmt.Sprintf(":%d", port)
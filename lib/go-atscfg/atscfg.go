@@ -542,6 +542,26 @@ nodeFor:
 	}, nil
 }
 
+// GetServerTopologyPlacement returns information about the server's placement
+// in the Topology of the given Delivery Service, and any error.
+//
+// Returns an error if the Delivery Service has no Topology, or its Topology
+// isn't in topologies.
+func GetServerTopologyPlacement(server *Server, ds *DeliveryService, topologies []tc.TopologyV5, cacheGroupArr []tc.CacheGroupNullableV5) (TopologyPlacement, error) {
+	if ds.Topology == nil || *ds.Topology == "" {
+		return TopologyPlacement{}, errors.New("delivery service '" + ds.XMLID + "' has no topology")
+	}
+	topology, ok := makeTopologyNameMap(topologies)[TopologyName(*ds.Topology)]
+	if !ok {
+		return TopologyPlacement{}, errors.New("delivery service '" + ds.XMLID + "' topology '" + *ds.Topology + "' not found")
+	}
+	cacheGroups, err := makeCGMap(cacheGroupArr)
+	if err != nil {
+		return TopologyPlacement{}, errors.New("making cachegroup map: " + err.Error())
+	}
+	return getTopologyPlacement(tc.CacheGroupName(server.CacheGroup), topology, cacheGroups, ds)
+}
+
 func makeTopologyNameMap(topologies []tc.TopologyV5) map[TopologyName]tc.TopologyV5 {
 	topoNames := map[TopologyName]tc.TopologyV5{}
 	for _, to := range topologies {
@@ -111,6 +111,53 @@ func TestGetATSMajorVersionFromATSVersion(t *testing.T) {
 	}
 }
 
+func TestGetServerTopologyPlacement(t *testing.T) {
+	cacheGroups := []tc.CacheGroupNullableV5{
+		{Name: util.StrPtr("edgeCG"), Type: util.StrPtr(tc.CacheGroupEdgeTypeName)},
+		{Name: util.StrPtr("midCG"), Type: util.StrPtr(tc.CacheGroupMidTypeName)},
+		{Name: util.StrPtr("originCG"), Type: util.StrPtr(tc.CacheGroupOriginTypeName)},
+	}
+	topologies := []tc.TopologyV5{{
+		Name: "t0",
+		Nodes: []tc.TopologyNodeV5{
+			{Cachegroup: "edgeCG", Parents: []int{1}},
+			{Cachegroup: "midCG", Parents: []int{2}},
+			{Cachegroup: "originCG"},
+		},
+	}}
+	ds := &DeliveryService{}
+	ds.XMLID = "ds0"
+
+	server := &Server{}
+	server.CacheGroup = "edgeCG"
+	if _, err := GetServerTopologyPlacement(server, ds, topologies, cacheGroups); err == nil {
+		t.Error("expected an error for a delivery service without a topology")
+	}
+
+	ds.Topology = util.StrPtr("t0")
+	placement, err := GetServerTopologyPlacement(server, ds, topologies, cacheGroups)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !placement.InTopology || !placement.IsFirstCacheTier || placement.IsLastCacheTier {
+		t.Errorf("expected edge to be the first and not the last cache tier, got %+v", placement)
+	}
+
+	server.CacheGroup = "midCG"
+	placement, err = GetServerTopologyPlacement(server, ds, topologies, cacheGroups)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !placement.InTopology || placement.IsFirstCacheTier || !placement.IsLastCacheTier {
+		t.Errorf("expected mid to be the last and not the first cache tier, got %+v", placement)
+	}
+
+	ds.Topology = util.StrPtr("missing")
+	if _, err := GetServerTopologyPlacement(server, ds, topologies, cacheGroups); err == nil {
+		t.Error("expected an error for a missing topology")
+	}
+}
+
 func TestLayerProfiles(t *testing.T) {
 	profileNames := []string{
 		"FOO",
@@ -288,6 +288,8 @@ type ParentConfigOpts struct {
 // tcServerParams shsould be ALL of the server's Profile's Parameters, and
 // tcParentConfigParams must be pre-filtered as the Parameters in the server's
 // Profile that have the ConfigFile value "parent.config".
+// cacheGroupLatencies are only used if the server's Profile has the
+// ParentConfigParamLatencyPreference Parameter, and may be nil.
 func MakeParentDotConfig(
 	dses []DeliveryService,
 	server *Server,
@@ -298,6 +300,7 @@ func MakeParentDotConfig(
 	serverCapabilities map[int]map[ServerCapability]struct{},
 	dsRequiredCapabilities map[int]map[ServerCapability]struct{},
 	cacheGroupArr []tc.CacheGroupNullableV5,
+	cacheGroupLatencies []tc.CacheGroupLatency,
 	dss []DeliveryServiceServer,
 	cdn *tc.CDNV5,
 	opt *ParentConfigOpts,
@@ -315,6 +318,7 @@ func MakeParentDotConfig(
 		serverCapabilities,
 		dsRequiredCapabilities,
 		cacheGroupArr,
+		cacheGroupLatencies,
 		dss,
 		cdn,
 		opt,
@@ -466,6 +470,7 @@ func MakeParentDotConfigData(
 	serverCapabilities map[int]map[ServerCapability]struct{},
 	dsRequiredCapabilities map[int]map[ServerCapability]struct{},
 	cacheGroupArr []tc.CacheGroupNullableV5,
+	cacheGroupLatencies []tc.CacheGroupLatency,
 	dss []DeliveryServiceServer,
 	cdn *tc.CDNV5,
 	opt *ParentConfigOpts,
@@ -527,6 +532,9 @@ func MakeParentDotConfigData(
 	// serverParams are the parent.config params for this particular server
 	serverParams := getServerParentConfigParams(server, parentConfigParams)
 
+	latencyPref, latencyWarns := makeParentLatencyPreference(server, serverParams, cacheGroupLatencies)
+	warnings = append(warnings, latencyWarns...)
+
 	parentCGs := map[string]struct{}{}
 	if cacheIsTopLevel {
 		for _, cg := range cacheGroups {
@@ -728,6 +736,7 @@ func MakeParentDotConfigData(
 			opt.AddComments,
 			opt.GoDirect,
 			opt.ParentIsProxy,
+			latencyPref,
 		)
 		warnings = append(warnings, topoWarnings...)
 		if err != nil {
@@ -750,7 +759,7 @@ func MakeParentDotConfigData(
 		invalidDS := &DeliveryService{}
 		invalidDS.ID = util.IntPtr(-1)
 		tryAllPrimariesBeforeSecondary := false
-		parents, secondaryParents, secondaryMode, parentWarns := getParentStrs(invalidDS, dsRequiredCapabilities, parentInfos[deliveryServicesAllParentsKey], atsMajorVersion, tryAllPrimariesBeforeSecondary)
+		parents, secondaryParents, secondaryMode, parentWarns := getParentStrs(invalidDS, dsRequiredCapabilities, parentInfos[deliveryServicesAllParentsKey], atsMajorVersion, tryAllPrimariesBeforeSecondary, latencyPref)
 		warnings = append(warnings, parentWarns...)
 
 		defaultDestText.DestDomain = `.`
@@ -1181,6 +1190,7 @@ func getTopologyParentConfigLine(
 	addComments bool,
 	goDirect string,
 	parentIsProxy bool,
+	latencyPref parentLatencyPreference,
 ) (*ParentAbstractionService, []string, error) {
 	warnings := []string{}
 
@@ -1234,7 +1244,7 @@ func getTopologyParentConfigLine(
 	}
 	// txt += "dest_domain=" + orgURI.Hostname() + " port=" + orgURI.Port()
 
-	parents, secondaryParents, parentWarnings, err := getTopologyParents(server, ds, serversWithParams, parentConfigParams, topology, serverPlacement.IsLastTier, serverCapabilities, dsRequiredCapabilities, dsOrigins, dsParams.MergeGroups)
+	parents, secondaryParents, parentWarnings, err := getTopologyParents(server, ds, serversWithParams, parentConfigParams, topology, serverPlacement.IsLastTier, serverCapabilities, dsRequiredCapabilities, dsOrigins, dsParams.MergeGroups, latencyPref)
 	warnings = append(warnings, parentWarnings...)
 
 	if err != nil {
@@ -1590,6 +1600,7 @@ func getTopologyParents(
 	dsRequiredCapabilities map[int]map[ServerCapability]struct{},
 	dsOrigins map[ServerID]struct{}, // for Topology DSes, MSO still needs DeliveryServiceServer assignments.
 	dsMergeGroups []string, // sorted parent merge groups for this ds
+	latencyPref parentLatencyPreference,
 ) ([]*ParentAbstractionServiceParent, []*ParentAbstractionServiceParent, []string, error) {
 	warnings := []string{}
 
@@ -1674,11 +1685,24 @@ func getTopologyParents(
 			return nil, nil, warnings, errors.New("Server '" + server.HostName + "' DS " + ds.XMLID + " topology '" + *ds.Topology + "' cachegroup '" + server.CacheGroup + "' topology node parent " + strconv.Itoa(svNode.Parents[0]) + " is not in the topology!")
 		}
 
+		if secondaryCG != "" && latencyPref.preferSecondary(parentCG, secondaryCG) {
+			parentCG, secondaryCG = secondaryCG, parentCG
+		}
+
 		primaryCGs[parentCG] = struct{}{}
 	}
 
+	allParentCGs := []string{}
+	for cg := range primaryCGs {
+		allParentCGs = append(allParentCGs, cg)
+	}
+	if secondaryCG != "" {
+		allParentCGs = append(allParentCGs, secondaryCG)
+	}
+
 	parentStrs := []*ParentAbstractionServiceParent{}
 	secondaryParentStrs := []*ParentAbstractionServiceParent{}
+	parentStrCGs := map[*ParentAbstractionServiceParent]string{} // map[parent]cachegroup, for latency preference
 
 	for _, sv := range serversWithParams {
 		if sv.ID == 0 {
@@ -1717,6 +1741,8 @@ func getTopologyParents(
 				return nil, nil, warnings, errors.New("getting server parent string: " + err.Error())
 			}
 			if parentStr != nil { // will be nil if server is not_a_parent (possibly other reasons)
+				parentStr.Weight *= latencyPref.weightFactor(sv.CacheGroup, allParentCGs)
+				parentStrCGs[parentStr] = sv.CacheGroup
 				parentStrs = append(parentStrs, parentStr)
 			}
 		} else if sv.CacheGroup == secondaryCG {
@@ -1724,6 +1750,10 @@ func getTopologyParents(
 			if err != nil {
 				return nil, nil, warnings, errors.New("getting server parent string: " + err.Error())
 			}
+			if parentStr != nil {
+				parentStr.Weight *= latencyPref.weightFactor(sv.CacheGroup, allParentCGs)
+				parentStrCGs[parentStr] = sv.CacheGroup
+			}
 			secondaryParentStrs = append(secondaryParentStrs, parentStr)
 		}
 	}
@@ -1740,6 +1770,9 @@ func getTopologyParents(
 		}
 	}
 
+	latencyPref.sortParentsByLatency(parentStrs, parentStrCGs)
+	latencyPref.sortParentsByLatency(secondaryParentStrs, parentStrCGs)
+
 	return parentStrs, secondaryParentStrs, warnings, nil
 }
 
@@ -1770,6 +1803,7 @@ func getParentStrs(
 	parentInfos []parentInfo,
 	atsMajorVersion uint,
 	tryAllPrimariesBeforeSecondary bool,
+	latencyPref parentLatencyPreference,
 ) ([]*ParentAbstractionServiceParent, []*ParentAbstractionServiceParent, ParentAbstractionServiceParentSecondaryMode, []string) {
 	warnings := []string{}
 	parentInfo := []*ParentAbstractionServiceParent{}
@@ -1777,12 +1811,24 @@ func getParentStrs(
 
 	sort.Sort(parentInfoSortByRank(parentInfos))
 
+	primaryCG, secondaryCG := "", ""
+	for _, parent := range parentInfos {
+		if parent.PrimaryParent {
+			primaryCG = parent.Cachegroup
+		} else if parent.SecondaryParent {
+			secondaryCG = parent.Cachegroup
+		}
+	}
+	parentCGs := map[*ParentAbstractionServiceParent]string{} // map[parent]cachegroup, for latency preference
+
 	for _, parent := range parentInfos { // TODO fix magic key
 		if !hasRequiredCapabilities(parent.Capabilities, dsRequiredCapabilities[*ds.ID]) {
 			continue
 		}
 
 		pTxt := parent.ToAbstract()
+		pTxt.Weight *= latencyPref.weightFactor(parent.Cachegroup, []string{primaryCG, secondaryCG})
+		parentCGs[pTxt] = parent.Cachegroup
 		if parent.PrimaryParent {
 			parentInfo = append(parentInfo, pTxt)
 		} else if parent.SecondaryParent {
@@ -1790,6 +1836,12 @@ func getParentStrs(
 		}
 	}
 
+	if len(secondaryParentInfo) > 0 && latencyPref.preferSecondary(primaryCG, secondaryCG) {
+		parentInfo, secondaryParentInfo = secondaryParentInfo, parentInfo
+	}
+	latencyPref.sortParentsByLatency(parentInfo, parentCGs)
+	latencyPref.sortParentsByLatency(secondaryParentInfo, parentCGs)
+
 	if len(parentInfo) == 0 {
 		parentInfo = secondaryParentInfo
 		secondaryParentInfo = []*ParentAbstractionServiceParent{}
@@ -2175,7 +2227,8 @@ func getServerParentConfigParams(server *Server, allParentConfigParams []paramet
 		val := pa.Value
 		if name == ParentConfigParamQStringHandling ||
 			name == ParentConfigRetryKeysDefault.Algorithm ||
-			name == ParentConfigParamQString {
+			name == ParentConfigParamQString ||
+			name == ParentConfigParamLatencyPreference {
 			serverParams[name] = val
 		}
 	}
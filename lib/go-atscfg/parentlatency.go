@@ -0,0 +1,161 @@
+package atscfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"math"
+	"sort"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// ParentConfigParamLatencyPreference is the Name of a Parameter on cache server
+// Profiles with the ConfigFile "parent.config" which enables latency-aware
+// parent preference, using the latencies from the server's Cache Group to its
+// parent Cache Groups measured by cache servers and smoothed by Traffic Ops.
+//
+// The value must be ParentLatencyPreferenceOrder or ParentLatencyPreferenceWeight.
+// Parents whose Cache Groups have no latency keep their Topology or
+// primary/secondary order and their weight Parameter.
+const ParentConfigParamLatencyPreference = "latency_preference"
+
+// ParentLatencyPreferenceOrder orders parents by latency: the secondary
+// parent Cache Group becomes primary if it's sufficiently faster (see
+// ParentLatencyPreferenceMinImprovement), and parents in each list are
+// ordered by their Cache Group's latency, which matters to the "first" and
+// "latched" retry policies.
+const ParentLatencyPreferenceOrder = "order"
+
+// ParentLatencyPreferenceWeight scales the weight of each parent by how much
+// slower its Cache Group is than the fastest parent Cache Group, so consistent
+// hash parent selection sends proportionally less traffic to slower parents.
+const ParentLatencyPreferenceWeight = "weight"
+
+// ParentLatencyPreferenceMinImprovement is the fraction by which the secondary
+// parent Cache Group must be faster than the primary for
+// ParentLatencyPreferenceOrder to swap them. This prevents parents from
+// flapping between primary and secondary with small latency changes.
+const ParentLatencyPreferenceMinImprovement = 0.2
+
+// parentLatencyPreference is the latency-aware parent preference of a server.
+type parentLatencyPreference struct {
+	// Mode is ParentLatencyPreferenceOrder or ParentLatencyPreferenceWeight,
+	// or empty if latency-aware parent preference is disabled.
+	Mode string
+	// LatenciesMS are the latencies from the server's Cache Group, keyed on the parent Cache Group.
+	LatenciesMS map[string]float64
+}
+
+// makeParentLatencyPreference returns the latency-aware parent preference for
+// the server from its parent.config Parameters and the Cache Group latencies,
+// and any warnings.
+func makeParentLatencyPreference(server *Server, serverParams map[string]string, cacheGroupLatencies []tc.CacheGroupLatency) (parentLatencyPreference, []string) {
+	warnings := []string{}
+	pref := parentLatencyPreference{}
+
+	mode, ok := serverParams[ParentConfigParamLatencyPreference]
+	if !ok {
+		return pref, warnings
+	}
+	if mode != ParentLatencyPreferenceOrder && mode != ParentLatencyPreferenceWeight {
+		warnings = append(warnings, "server parameter '"+ParentConfigParamLatencyPreference+"' value '"+mode+"' is not '"+ParentLatencyPreferenceOrder+"' or '"+ParentLatencyPreferenceWeight+"', not using latency preference!")
+		return pref, warnings
+	}
+
+	pref.LatenciesMS = map[string]float64{}
+	for _, latency := range cacheGroupLatencies {
+		if latency.CacheGroup != server.CacheGroup {
+			continue
+		}
+		if math.IsNaN(latency.LatencyMS) || latency.LatencyMS < 0 {
+			warnings = append(warnings, "cache group '"+latency.CacheGroup+"' latency to '"+latency.ParentCacheGroup+"' is invalid, ignoring!")
+			continue
+		}
+		pref.LatenciesMS[latency.ParentCacheGroup] = latency.LatencyMS
+	}
+	if len(pref.LatenciesMS) == 0 {
+		warnings = append(warnings, "server parameter '"+ParentConfigParamLatencyPreference+"' is set, but cache group '"+server.CacheGroup+"' has no latencies to its parents, using the configured parent order and weights")
+	}
+	pref.Mode = mode
+	return pref, warnings
+}
+
+// preferSecondary returns whether the secondary parent Cache Group should be
+// used as the primary, because it's sufficiently faster.
+func (pref parentLatencyPreference) preferSecondary(primaryCG string, secondaryCG string) bool {
+	if pref.Mode != ParentLatencyPreferenceOrder {
+		return false
+	}
+	primaryLatency, ok := pref.LatenciesMS[primaryCG]
+	if !ok {
+		return false
+	}
+	secondaryLatency, ok := pref.LatenciesMS[secondaryCG]
+	if !ok {
+		return false
+	}
+	return secondaryLatency < primaryLatency*(1-ParentLatencyPreferenceMinImprovement)
+}
+
+// weightFactor returns the factor to scale the weight of parents in the given
+// Cache Group by, which is the latency of the fastest of the given parent
+// Cache Groups divided by the latency of the parent's Cache Group.
+//
+// Returns 1 if the mode isn't ParentLatencyPreferenceWeight, or if the
+// latency of the parent's Cache Group isn't known.
+func (pref parentLatencyPreference) weightFactor(parentCG string, parentCGs []string) float64 {
+	if pref.Mode != ParentLatencyPreferenceWeight {
+		return 1
+	}
+	latency, ok := pref.LatenciesMS[parentCG]
+	if !ok {
+		return 1
+	}
+	minLatency := latency
+	for _, cg := range parentCGs {
+		if cgLatency, ok := pref.LatenciesMS[cg]; ok && cgLatency < minLatency {
+			minLatency = cgLatency
+		}
+	}
+	if latency <= 0 {
+		return 1 // latency is the fastest possible
+	}
+	return minLatency / latency
+}
+
+// sortParentsByLatency stably sorts parents by the latency of their Cache
+// Groups, fastest first. Parents whose Cache Group latency isn't known are
+// sorted last, in their existing order.
+//
+// Does nothing if the mode isn't ParentLatencyPreferenceOrder.
+func (pref parentLatencyPreference) sortParentsByLatency(parents []*ParentAbstractionServiceParent, parentCGs map[*ParentAbstractionServiceParent]string) {
+	if pref.Mode != ParentLatencyPreferenceOrder {
+		return
+	}
+	latency := func(parent *ParentAbstractionServiceParent) float64 {
+		if latency, ok := pref.LatenciesMS[parentCGs[parent]]; ok {
+			return latency
+		}
+		return math.Inf(1)
+	}
+	sort.SliceStable(parents, func(i, j int) bool {
+		return latency(parents[i]) < latency(parents[j])
+	})
+}
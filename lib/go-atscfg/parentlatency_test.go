@@ -0,0 +1,262 @@
+package atscfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+// makeLatencyPreferenceTestData returns an edge in edgeCG with a Topology
+// whose primary parent is midACG and secondary parent is midBCG, each with
+// one mid, and a Delivery Service using that Topology.
+func makeLatencyPreferenceTestData() ([]DeliveryService, *Server, []Server, []tc.TopologyV5, []tc.CacheGroupNullableV5) {
+	ds := makeParentDS()
+	ds.Topology = util.Ptr("t0")
+
+	edge := makeTestParentServer()
+	edge.CacheGroup = "edgeCG"
+	edge.CacheGroupID = 400
+
+	midA := makeTestParentServer()
+	midA.HostName = "mida"
+	midA.ID = 45
+	midA.CacheGroup = "midACG"
+	midA.CacheGroupID = 500
+	midA.Type = tc.MidTypePrefix
+	setIP(midA, "192.168.2.2")
+
+	midB := makeTestParentServer()
+	midB.HostName = "midb"
+	midB.ID = 46
+	midB.CacheGroup = "midBCG"
+	midB.CacheGroupID = 501
+	midB.Type = tc.MidTypePrefix
+	setIP(midB, "192.168.2.3")
+
+	topologies := []tc.TopologyV5{
+		{
+			Name: "t0",
+			Nodes: []tc.TopologyNodeV5{
+				{Cachegroup: "edgeCG", Parents: []int{1, 2}},
+				{Cachegroup: "midACG"},
+				{Cachegroup: "midBCG"},
+			},
+		},
+	}
+
+	makeCG := func(name string, id int, cgType string) tc.CacheGroupNullableV5 {
+		cg := tc.CacheGroupNullableV5{}
+		cg.Name = util.Ptr(name)
+		cg.ID = util.Ptr(id)
+		cg.Type = util.Ptr(cgType)
+		return cg
+	}
+	cgs := []tc.CacheGroupNullableV5{
+		makeCG("edgeCG", 400, tc.CacheGroupEdgeTypeName),
+		makeCG("midACG", 500, tc.CacheGroupMidTypeName),
+		makeCG("midBCG", 501, tc.CacheGroupMidTypeName),
+	}
+
+	return []DeliveryService{*ds}, edge, []Server{*edge, *midA, *midB}, topologies, cgs
+}
+
+func TestMakeParentDotConfigDataLatencyPreference(t *testing.T) {
+	latencies := func(midA float64, midB float64) []tc.CacheGroupLatency {
+		return []tc.CacheGroupLatency{
+			{CacheGroup: "edgeCG", ParentCacheGroup: "midACG", LatencyMS: midA},
+			{CacheGroup: "edgeCG", ParentCacheGroup: "midBCG", LatencyMS: midB},
+			// latencies of other cachegroups must be ignored
+			{CacheGroup: "otherCG", ParentCacheGroup: "midACG", LatencyMS: 1},
+		}
+	}
+
+	testCases := []struct {
+		name              string
+		mode              string
+		latencies         []tc.CacheGroupLatency
+		expectedPrimary   string
+		expectedSecondary string
+		expectedWeights   map[string]float64 // map[fqdn]weight
+		expectWarning     string
+	}{
+		{
+			name:              "disabled",
+			latencies:         latencies(50, 10),
+			expectedPrimary:   "mida.mydomain.example.net",
+			expectedSecondary: "midb.mydomain.example.net",
+		},
+		{
+			name:              "order swaps a faster secondary",
+			mode:              ParentLatencyPreferenceOrder,
+			latencies:         latencies(50, 10),
+			expectedPrimary:   "midb.mydomain.example.net",
+			expectedSecondary: "mida.mydomain.example.net",
+		},
+		{
+			name:              "order doesn't swap a slightly faster secondary",
+			mode:              ParentLatencyPreferenceOrder,
+			latencies:         latencies(50, 45),
+			expectedPrimary:   "mida.mydomain.example.net",
+			expectedSecondary: "midb.mydomain.example.net",
+		},
+		{
+			name:              "order without latencies",
+			mode:              ParentLatencyPreferenceOrder,
+			expectedPrimary:   "mida.mydomain.example.net",
+			expectedSecondary: "midb.mydomain.example.net",
+			expectWarning:     "has no latencies",
+		},
+		{
+			name:              "weight",
+			mode:              ParentLatencyPreferenceWeight,
+			latencies:         latencies(50, 10),
+			expectedPrimary:   "mida.mydomain.example.net",
+			expectedSecondary: "midb.mydomain.example.net",
+			expectedWeights: map[string]float64{
+				"mida.mydomain.example.net": DefaultParentWeight * 10 / 50,
+				"midb.mydomain.example.net": DefaultParentWeight,
+			},
+		},
+		{
+			name:              "invalid mode",
+			mode:              "fastest",
+			latencies:         latencies(50, 10),
+			expectedPrimary:   "mida.mydomain.example.net",
+			expectedSecondary: "midb.mydomain.example.net",
+			expectWarning:     "not using latency preference",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dses, edge, servers, topologies, cgs := makeLatencyPreferenceTestData()
+			parentConfigParams := []tc.ParameterV5{}
+			if testCase.mode != "" {
+				parentConfigParams = append(parentConfigParams, tc.ParameterV5{
+					Name:       ParentConfigParamLatencyPreference,
+					ConfigFile: "parent.config",
+					Value:      testCase.mode,
+					Profiles:   []byte(`["serverprofile"]`),
+				})
+			}
+
+			pa, warnings, err := MakeParentDotConfigData(dses, edge, servers, topologies, nil, parentConfigParams, nil, nil, cgs, testCase.latencies, nil, &tc.CDNV5{DomainName: "cdndomain.example", Name: "my-cdn-name"}, nil, 9)
+			if err != nil {
+				t.Fatalf("making parent data: %v", err)
+			}
+			if testCase.expectWarning != "" && !strings.Contains(strings.Join(warnings, "\n"), testCase.expectWarning) {
+				t.Errorf("expected warning containing '%s', got %v", testCase.expectWarning, warnings)
+			}
+
+			var svc *ParentAbstractionService
+			for _, s := range pa.Services {
+				if s.Name == "ds1" {
+					svc = s
+				}
+			}
+			if svc == nil {
+				t.Fatalf("expected service for ds1, got %+v, warnings %v", pa.Services, warnings)
+			}
+			if len(svc.Parents) != 1 || svc.Parents[0].FQDN != testCase.expectedPrimary {
+				t.Errorf("expected primary parent %s, got %+v", testCase.expectedPrimary, svc.Parents)
+			}
+			if len(svc.SecondaryParents) != 1 || svc.SecondaryParents[0].FQDN != testCase.expectedSecondary {
+				t.Errorf("expected secondary parent %s, got %+v", testCase.expectedSecondary, svc.SecondaryParents)
+			}
+			for _, parent := range append(svc.Parents, svc.SecondaryParents...) {
+				expectedWeight, ok := testCase.expectedWeights[parent.FQDN]
+				if !ok {
+					expectedWeight = DefaultParentWeight
+				}
+				if parent.Weight != expectedWeight {
+					t.Errorf("expected parent %s weight %v, got %v", parent.FQDN, expectedWeight, parent.Weight)
+				}
+			}
+		})
+	}
+}
+
+func TestMakeParentDotConfigDataLatencyPreferenceNonTopology(t *testing.T) {
+	dses, edge, servers, _, cgs := makeLatencyPreferenceTestData()
+	dses[0].Topology = nil
+	cgs[0].ParentName = util.Ptr("midACG")
+	cgs[0].ParentCachegroupID = util.Ptr(500)
+	cgs[0].SecondaryParentName = util.Ptr("midBCG")
+	cgs[0].SecondaryParentCachegroupID = util.Ptr(501)
+	dss := []DeliveryServiceServer{{Server: edge.ID, DeliveryService: *dses[0].ID}}
+	latencies := []tc.CacheGroupLatency{
+		{CacheGroup: "edgeCG", ParentCacheGroup: "midACG", LatencyMS: 50},
+		{CacheGroup: "edgeCG", ParentCacheGroup: "midBCG", LatencyMS: 10},
+	}
+	parentConfigParams := []tc.ParameterV5{
+		{
+			Name:       ParentConfigParamLatencyPreference,
+			ConfigFile: "parent.config",
+			Value:      ParentLatencyPreferenceOrder,
+			Profiles:   []byte(`["serverprofile"]`),
+		},
+	}
+
+	pa, warnings, err := MakeParentDotConfigData(dses, edge, servers, nil, nil, parentConfigParams, nil, nil, cgs, latencies, dss, &tc.CDNV5{DomainName: "cdndomain.example", Name: "my-cdn-name"}, nil, 9)
+	if err != nil {
+		t.Fatalf("making parent data: %v", err)
+	}
+
+	services := map[string]*ParentAbstractionService{}
+	for _, svc := range pa.Services {
+		services[svc.Name] = svc
+	}
+	for _, name := range []string{"ds1", "default-destination-c3854be4-a859-41d6-815d-7b36297e48c6"} {
+		svc, ok := services[name]
+		if !ok {
+			t.Fatalf("expected service %s, got %+v, warnings %v", name, pa.Services, warnings)
+		}
+		if len(svc.Parents) != 1 || svc.Parents[0].FQDN != "midb.mydomain.example.net" {
+			t.Errorf("expected %s primary parent from the faster secondary cache group, got %+v", name, svc.Parents)
+		}
+	}
+}
+
+func TestParentLatencyPreferenceSortParents(t *testing.T) {
+	pref := parentLatencyPreference{
+		Mode:        ParentLatencyPreferenceOrder,
+		LatenciesMS: map[string]float64{"fastCG": 5, "slowCG": 40},
+	}
+	slow := &ParentAbstractionServiceParent{FQDN: "slow"}
+	unknown := &ParentAbstractionServiceParent{FQDN: "unknown"}
+	fast0 := &ParentAbstractionServiceParent{FQDN: "fast0"}
+	fast1 := &ParentAbstractionServiceParent{FQDN: "fast1"}
+	parents := []*ParentAbstractionServiceParent{unknown, slow, fast0, fast1}
+	cgs := map[*ParentAbstractionServiceParent]string{slow: "slowCG", unknown: "unknownCG", fast0: "fastCG", fast1: "fastCG"}
+
+	pref.sortParentsByLatency(parents, cgs)
+
+	actual := []string{}
+	for _, parent := range parents {
+		actual = append(actual, parent.FQDN)
+	}
+	if expected := "fast0,fast1,slow,unknown"; strings.Join(actual, ",") != expected {
+		t.Errorf("expected parents ordered %s, got %s", expected, strings.Join(actual, ","))
+	}
+}
@@ -75,6 +75,7 @@ func MakeStrategiesDotYAML(
 	serverCapabilities map[int]map[ServerCapability]struct{},
 	dsRequiredCapabilities map[int]map[ServerCapability]struct{},
 	cacheGroupArr []tc.CacheGroupNullableV5,
+	cacheGroupLatencies []tc.CacheGroupLatency,
 	dss []DeliveryServiceServer,
 	cdn *tc.CDNV5,
 	opt *StrategiesYAMLOpts,
@@ -96,6 +97,7 @@ func MakeStrategiesDotYAML(
 		serverCapabilities,
 		dsRequiredCapabilities,
 		cacheGroupArr,
+		cacheGroupLatencies,
 		dss,
 		cdn,
 		&ParentConfigOpts{
@@ -128,7 +128,7 @@ func TestMakeStrategiesDotConfig(t *testing.T) {
 		Name:       "my-cdn-name",
 	}
 
-	cfg, err := MakeStrategiesDotYAML(dses, server, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+	cfg, err := MakeStrategiesDotYAML(dses, server, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -300,7 +300,7 @@ func TestMakeStrategiesTopologiesParams(t *testing.T) {
 		Name:       "my-cdn-name",
 	}
 
-	cfg, err := MakeStrategiesDotYAML(dses, server, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+	cfg, err := MakeStrategiesDotYAML(dses, server, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -426,7 +426,7 @@ func TestMakeStrategiesHTTPSOrigin(t *testing.T) {
 		Name:       "my-cdn-name",
 	}
 
-	cfg, err := MakeStrategiesDotYAML(dses, server, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+	cfg, err := MakeStrategiesDotYAML(dses, server, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -615,7 +615,7 @@ func TestMakeStrategiesPeeringRing(t *testing.T) {
 			Profiles:   []byte(`["ds1Profile"]`),
 		})
 
-		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -652,7 +652,7 @@ func TestMakeStrategiesPeeringRing(t *testing.T) {
 			Profiles:   []byte(`["ds1Profile"]`),
 		})
 
-		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -680,7 +680,7 @@ func TestMakeStrategiesPeeringRing(t *testing.T) {
 	})
 
 	t.Run("peering ring nonexistent", func(t *testing.T) {
-		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -871,7 +871,7 @@ func TestMakeStrategiesPeeringRingMSO(t *testing.T) {
 			Profiles:   []byte(`["ds1Profile"]`),
 		})
 
-		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -908,7 +908,7 @@ func TestMakeStrategiesPeeringRingMSO(t *testing.T) {
 			Profiles:   []byte(`["ds1Profile"]`),
 		})
 
-		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -936,7 +936,7 @@ func TestMakeStrategiesPeeringRingMSO(t *testing.T) {
 	})
 
 	t.Run("peering ring nonexistent", func(t *testing.T) {
-		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -1094,7 +1094,7 @@ func TestMakeStrategiesPeeringRingNonTopology(t *testing.T) {
 			Profiles:   []byte(`["ds1Profile"]`),
 		})
 
-		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -1131,7 +1131,7 @@ func TestMakeStrategiesPeeringRingNonTopology(t *testing.T) {
 			Profiles:   []byte(`["ds1Profile"]`),
 		})
 
-		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParamsPR, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -1159,7 +1159,7 @@ func TestMakeStrategiesPeeringRingNonTopology(t *testing.T) {
 		}
 	})
 	t.Run("peering ring nonexistent", func(t *testing.T) {
-		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, edge0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -1436,7 +1436,7 @@ func TestMakeStrategiesDotYAMLFirstLastNoTopoParams(t *testing.T) {
 	/*
 		for _, ds := range dsesall {
 			dses := []DeliveryService{ds}
-			cfg, err := MakeStrategiesDotYAML(dses, edge, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+			cfg, err := MakeStrategiesDotYAML(dses, edge, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -1464,7 +1464,7 @@ func TestMakeStrategiesDotYAMLFirstLastNoTopoParams(t *testing.T) {
 	{
 		ds := dsesall[1]
 		dses := []DeliveryService{ds}
-		cfg, err := MakeStrategiesDotYAML(dses, mid0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, mid0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -1568,7 +1568,7 @@ func TestMakeStrategiesDotYamlMSONoTopologyNoMid(t *testing.T) {
 		Name:       "my-cdn-name",
 	}
 
-	cfg, err := MakeStrategiesDotYAML(dses, edge, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+	cfg, err := MakeStrategiesDotYAML(dses, edge, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1695,7 +1695,7 @@ func TestMakeStrategiesDotYamlMSONoTopoMultiCG(t *testing.T) {
 		DomainName: "cdndomain.example",
 		Name:       "my-cdn-name",
 	}
-	cfg, err := MakeStrategiesDotYAML(dses, mid, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+	cfg, err := MakeStrategiesDotYAML(dses, mid, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -2002,7 +2002,7 @@ func TestMakeStrategiesDotYAMLFirstInnerLastParams(t *testing.T) {
 	// edge config
 	for _, ds := range dsesall {
 		dses := []DeliveryService{ds}
-		cfg, err := MakeStrategiesDotYAML(dses, edge, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, edge, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -2029,7 +2029,7 @@ func TestMakeStrategiesDotYAMLFirstInnerLastParams(t *testing.T) {
 	// test mid config
 	for _, ds := range dsesall {
 		dses := []DeliveryService{ds}
-		cfg, err := MakeStrategiesDotYAML(dses, mid0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, mid0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -2056,7 +2056,7 @@ func TestMakeStrategiesDotYAMLFirstInnerLastParams(t *testing.T) {
 	// test opl config
 	for _, ds := range dsesall {
 		dses := []DeliveryService{ds}
-		cfg, err := MakeStrategiesDotYAML(dses, opl0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, dss, cdn, opt)
+		cfg, err := MakeStrategiesDotYAML(dses, opl0, servers, topologies, serverParams, parentConfigParams, serverCapabilities, dsRequiredCapabilities, cgs, nil, dss, cdn, opt)
 		if err != nil {
 			t.Fatal(err)
 		}
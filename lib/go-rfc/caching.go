@@ -395,6 +395,22 @@ func FreshFor(respHeaders http.Header, respCC CacheControlMap, reqTime, respTime
 	return freshnessLifetime - currentAge
 }
 
+// StaleWhileRevalidate returns the stale-while-revalidate window of the
+// given response Cache-Control, per RFC5861§3, and whether it exists. A
+// stale response within the window may be served, while it's revalidated in
+// the background.
+func StaleWhileRevalidate(respCC CacheControlMap) (time.Duration, bool) {
+	return getHTTPDeltaSecondsCacheControl(respCC, "stale-while-revalidate")
+}
+
+// StaleIfError returns the stale-if-error window of the given response
+// Cache-Control, per RFC5861§4, and whether it exists. A stale response
+// within the window may be served, if revalidating it fails with an error or
+// a 5xx response.
+func StaleIfError(respCC CacheControlMap) (time.Duration, bool) {
+	return getHTTPDeltaSecondsCacheControl(respCC, "stale-if-error")
+}
+
 // Reuse is an "enumerated" type describing the necessary behavior of a cache
 // with regard to its cached objects.
 type Reuse int
@@ -922,6 +922,24 @@ func TestCanReuseStored(t *testing.T) {
 	})
 }
 
+func TestStaleExtensions(t *testing.T) {
+	respCC := ParseCacheControl(http.Header{"Cache-Control": {"max-age=10, stale-while-revalidate=30, stale-if-error=86400"}})
+	if swr, ok := StaleWhileRevalidate(respCC); !ok || swr != 30*time.Second {
+		t.Errorf("StaleWhileRevalidate expected 30s true, actual %v %v", swr, ok)
+	}
+	if sie, ok := StaleIfError(respCC); !ok || sie != 86400*time.Second {
+		t.Errorf("StaleIfError expected 24h true, actual %v %v", sie, ok)
+	}
+
+	respCC = ParseCacheControl(http.Header{"Cache-Control": {"max-age=10, stale-while-revalidate=soon"}})
+	if swr, ok := StaleWhileRevalidate(respCC); ok {
+		t.Errorf("StaleWhileRevalidate with invalid delta-seconds expected false, actual %v %v", swr, ok)
+	}
+	if sie, ok := StaleIfError(respCC); ok {
+		t.Errorf("StaleIfError without directive expected false, actual %v %v", sie, ok)
+	}
+}
+
 func BenchmarkCanReuseStored(b *testing.B) {
 	tenMinutesAgo := time.Now().Add(time.Minute * -10)
 	reqHdr := http.Header{
@@ -0,0 +1,57 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"time"
+)
+
+// CacheGroupLatencyMeasurement is the latency measured by a cache server to
+// the servers of one of its parent Cache Groups.
+type CacheGroupLatencyMeasurement struct {
+	ParentCacheGroup string  `json:"parentCachegroup"`
+	LatencyMS        float64 `json:"latencyMs"`
+}
+
+// CacheGroupLatenciesRequest is a report of the latencies measured by a cache
+// server in CacheGroup to its parent Cache Groups.
+type CacheGroupLatenciesRequest struct {
+	CacheGroup   string                         `json:"cachegroup"`
+	Measurements []CacheGroupLatencyMeasurement `json:"measurements"`
+}
+
+// CacheGroupLatency is the smoothed latency from the servers of a Cache Group
+// to the servers of one of its parent Cache Groups, computed by Traffic Ops
+// from the measurements reported by cache servers.
+type CacheGroupLatency struct {
+	CacheGroup       string  `json:"cachegroup"`
+	ParentCacheGroup string  `json:"parentCachegroup"`
+	LatencyMS        float64 `json:"latencyMs"`
+	// Samples is the number of measurements the latency was computed from.
+	Samples     int       `json:"samples"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+// CacheGroupLatenciesResponse is the type of a response from Traffic Ops to a
+// request made to its /cachegroup_latencies API endpoint.
+type CacheGroupLatenciesResponse struct {
+	Response []CacheGroupLatency `json:"response"`
+	Alerts
+}
@@ -312,6 +312,28 @@ type TrafficVaultPingResponse struct {
 	Alerts
 }
 
+// TrafficVaultSecretVersion describes one of the versions of a secret that
+// Traffic Vault retains so that it can be promoted or rolled back to.
+type TrafficVaultSecretVersion struct {
+	Version     int64     `json:"version"`
+	Current     bool      `json:"current"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+// TrafficVaultSecretVersionsResponse represents the JSON HTTP response returned
+// by the /vault/secrets/{{type}}/{{name}}/versions route.
+type TrafficVaultSecretVersionsResponse struct {
+	Response []TrafficVaultSecretVersion `json:"response"`
+	Alerts
+}
+
+// TrafficVaultSecretRollbackResponse represents the JSON HTTP response returned
+// by the /vault/secrets/{{type}}/{{name}}/rollback route.
+type TrafficVaultSecretRollbackResponse struct {
+	Response TrafficVaultSecretVersion `json:"response"`
+	Alerts
+}
+
 // URLSigKeys is the type of the `response` property of responses from Traffic
 // Ops to GET requests made to the /deliverservices/xmlId/{{XML ID}}/urlkeys
 // endpoint of its API.
@@ -0,0 +1,127 @@
+package tc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"time"
+)
+
+// RolloutStatus is the state of a staged rollout of cache server configuration.
+type RolloutStatus string
+
+const (
+	// RolloutStatusRunning means waves are being released as their health checks pass.
+	RolloutStatusRunning RolloutStatus = "running"
+	// RolloutStatusPaused means no more waves will be released until the rollout is resumed.
+	RolloutStatusPaused RolloutStatus = "paused"
+	// RolloutStatusCompleted means every wave has been released and passed its health checks.
+	RolloutStatusCompleted RolloutStatus = "completed"
+	// RolloutStatusHalted means the rollout was stopped, and pending updates of its servers dequeued.
+	// Servers which already applied their update keep it: a halt is not a rollback.
+	RolloutStatusHalted RolloutStatus = "halted"
+)
+
+// Valid values of RolloutRequest's OnRegression, which is what Traffic Ops
+// does when the health checks of a released wave fail.
+const (
+	RolloutOnRegressionPause = "pause"
+	RolloutOnRegressionHalt  = "halt"
+)
+
+// Valid values of RolloutActionRequest's Action.
+const (
+	RolloutActionPause   = "pause"
+	RolloutActionResume  = "resume"
+	RolloutActionAdvance = "advance"
+	RolloutActionHalt    = "halt"
+)
+
+// RolloutRequest is a request to start a staged rollout of queued updates
+// across the servers of a Topology in a CDN.
+type RolloutRequest struct {
+	Topology TopologyName `json:"topology"`
+	CDNID    int          `json:"cdnId"`
+	// CanaryServerIDs are the servers whose updates are queued first, before any percentage step.
+	CanaryServerIDs []int `json:"canaryServerIds"`
+	// Steps are the cumulative percentages of each Cache Group's servers updated by each wave
+	// after the canary wave, e.g. [10, 50, 100]. The last step must be 100.
+	Steps []int `json:"steps"`
+	// BakeTimeSeconds is how long a wave must be healthy after being released before the next is released.
+	BakeTimeSeconds *int `json:"bakeTimeSeconds"`
+	// MinAvailability is the minimum fraction of released servers which must be available in Traffic Monitor.
+	MinAvailability *float64 `json:"minAvailability"`
+	// MaxErrorRate is the maximum fraction of responses from released servers which may be 5xx errors.
+	MaxErrorRate *float64 `json:"maxErrorRate"`
+	// OnRegression is what to do when a wave fails its health checks: "pause" or "halt".
+	OnRegression *string `json:"onRegression"`
+}
+
+// Rollout is a staged rollout of cache server configuration updates.
+type Rollout struct {
+	ID              int             `json:"id"`
+	Topology        TopologyName    `json:"topology"`
+	CDNID           int             `json:"cdnId"`
+	CDNName         CDNName         `json:"cdnName"`
+	Status          RolloutStatus   `json:"status"`
+	StatusReason    *string         `json:"statusReason"`
+	Steps           []int           `json:"steps"`
+	Wave            int             `json:"wave"`
+	WaveStartedAt   time.Time       `json:"waveStartedAt"`
+	BakeTimeSeconds int             `json:"bakeTimeSeconds"`
+	MinAvailability float64         `json:"minAvailability"`
+	MaxErrorRate    float64         `json:"maxErrorRate"`
+	OnRegression    string          `json:"onRegression"`
+	Username        string          `json:"username"`
+	LastUpdated     time.Time       `json:"lastUpdated"`
+	Servers         []RolloutServer `json:"servers"`
+}
+
+// RolloutServer is a server included in a Rollout, and the wave it is updated in.
+type RolloutServer struct {
+	ID         int        `json:"id"`
+	HostName   string     `json:"hostName"`
+	CacheGroup string     `json:"cachegroup"`
+	Wave       int        `json:"wave"`
+	ReleasedAt *time.Time `json:"releasedAt"`
+	// UpdatePending is whether the server has not yet applied its queued update.
+	UpdatePending bool `json:"updatePending"`
+	// UpdateFailed is whether the server reported that applying its last update failed.
+	UpdateFailed bool `json:"updateFailed"`
+}
+
+// RolloutActionRequest is a request to change the state of a Rollout.
+type RolloutActionRequest struct {
+	// Action is one of "pause", "resume", "advance", or "halt".
+	Action string `json:"action"`
+}
+
+// RolloutsResponse is the type of a response from Traffic Ops to a GET
+// request made to its /rollouts API endpoint.
+type RolloutsResponse struct {
+	Response []Rollout `json:"response"`
+	Alerts
+}
+
+// RolloutResponse is the type of a response from Traffic Ops to a request
+// which creates or changes a single Rollout.
+type RolloutResponse struct {
+	Response Rollout `json:"response"`
+	Alerts
+}
@@ -34,6 +34,18 @@ import (
 // monitoring thresholds.
 const ThresholdPrefix = "health.threshold."
 
+// RulePrefix is the prefix of all Names of Parameters used to define
+// monitoring health rules.
+const RulePrefix = "health.rule."
+
+// These are the suffixes of the Names of Parameters which define parts of a
+// health rule other than its expression. For example, the Parameter
+// "health.rule.errors.clear" is the clear expression of the rule "errors".
+const (
+	RuleClearSuffix  = ".clear"
+	RuleReasonSuffix = ".reason"
+)
+
 // These are the names of statistics that can be used in thresholds for server
 // health.
 const (
@@ -507,6 +519,9 @@ type TMParameters struct {
 	// a JSON object.
 	Thresholds map[string]HealthThreshold `json:"health_threshold,omitempty"`
 	HealthThresholdJSONParameters
+	// Rules are the health rules of the Profile, by name, from the
+	// Parameters prefixed with RulePrefix.
+	Rules map[string]HealthRule `json:"health_rule,omitempty"`
 }
 
 // HealthThresholdJSONParameters contains Parameters whose Thresholds must be met in order for
@@ -538,6 +553,24 @@ type HealthThreshold struct {
 	Comparator string // TODO change to enum?
 }
 
+// HealthRule is a rule which marks cache servers unhealthy when an expression
+// over their polled stats is true. Unlike a HealthThreshold, it may use any
+// stat, functions of the stat history such as rates and moving averages, and
+// arithmetic and logical operators.
+type HealthRule struct {
+	// Expr is the expression which, when true, marks the cache server
+	// unhealthy.
+	Expr string `json:"expr"`
+	// Clear is the expression which must be true for a cache server marked
+	// unhealthy by the rule to be marked healthy again. If empty, the cache
+	// server is marked healthy again as soon as Expr is false. Setting it
+	// gives the rule hysteresis, so cache servers near the limit don't flap.
+	Clear string `json:"clear,omitempty"`
+	// Reason is the explanation given for cache servers marked unhealthy by
+	// the rule. If empty, the expression is given.
+	Reason string `json:"reason,omitempty"`
+}
+
 // String implements the fmt.Stringer interface.
 func (t HealthThreshold) String() string {
 	return fmt.Sprintf("%s%f", t.Comparator, t.Val)
@@ -627,6 +660,36 @@ func (params *TMParameters) UnmarshalJSON(bytes []byte) (err error) {
 			}
 		}
 	}
+
+	params.Rules = map[string]HealthRule{}
+	for k, v := range raw {
+		if !strings.HasPrefix(k, RulePrefix) {
+			continue
+		}
+		name := k[len(RulePrefix):]
+		vStr := fmt.Sprintf("%v", v) // Traffic Ops sends integer values as JSON numbers, and an expression may be a lone number.
+		switch {
+		case strings.HasSuffix(name, RuleClearSuffix):
+			name = name[:len(name)-len(RuleClearSuffix)]
+			rule := params.Rules[name]
+			rule.Clear = vStr
+			params.Rules[name] = rule
+		case strings.HasSuffix(name, RuleReasonSuffix):
+			name = name[:len(name)-len(RuleReasonSuffix)]
+			rule := params.Rules[name]
+			rule.Reason = vStr
+			params.Rules[name] = rule
+		default:
+			rule := params.Rules[name]
+			rule.Expr = vStr
+			params.Rules[name] = rule
+		}
+	}
+	for name, rule := range params.Rules {
+		if rule.Expr == "" {
+			return fmt.Errorf("Unmarshalling TMParameters `%s` rule '%s' has a clear or reason parameter, but no expression", RulePrefix, name)
+		}
+	}
 	return nil
 }
 
@@ -22,6 +22,7 @@ package tc
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -216,6 +217,34 @@ func ExampleTMParameters_UnmarshalJSON() {
 	// # of Thresholds: 2 - foo: <=500.000000, bandwidth: >50.000000
 }
 
+func TestTMParametersUnmarshalJSONRules(t *testing.T) {
+	const data = `{
+		"health.rule.errors": "rate(proxy.process.http.5xx_responses) / rate(proxy.process.http.completed_requests) > 0.05",
+		"health.rule.errors.clear": "rate(proxy.process.http.5xx_responses) / rate(proxy.process.http.completed_requests) < 0.01",
+		"health.rule.errors.reason": "too many 5xx responses",
+		"health.rule.down": 1
+	}`
+
+	var params TMParameters
+	if err := json.Unmarshal([]byte(data), &params); err != nil {
+		t.Fatalf("unmarshalling rules expected nil error, actual: %v", err)
+	}
+	if len(params.Rules) != 2 {
+		t.Fatalf("unmarshalling rules expected 2 rules, actual: %+v", params.Rules)
+	}
+	errRule := params.Rules["errors"]
+	if !strings.HasSuffix(errRule.Expr, "> 0.05") || !strings.HasSuffix(errRule.Clear, "< 0.01") || errRule.Reason != "too many 5xx responses" {
+		t.Errorf("unmarshalling rules expected expression, clear and reason of rule 'errors', actual: %+v", errRule)
+	}
+	if downRule := params.Rules["down"]; downRule.Expr != "1" {
+		t.Errorf("unmarshalling rules expected numeric expression '1' of rule 'down', actual: %+v", downRule)
+	}
+
+	if err := json.Unmarshal([]byte(`{"health.rule.errors.clear": "0"}`), &params); err == nil {
+		t.Errorf("unmarshalling rule without expression expected error, actual: nil")
+	}
+}
+
 func ExampleTrafficMonitorConfigMap_Valid() {
 	mc := &TrafficMonitorConfigMap{
 		CacheGroup: map[string]TMCacheGroup{"a": {}},
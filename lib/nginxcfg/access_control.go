@@ -0,0 +1,142 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+const (
+	aclAll               = "all"
+	aclAllButPushPurge   = "all_but_push_purge"
+	aclNone              = "none"
+	accessControlGeo     = "geo $tc_acl"
+	restrictedMethodsMap = "map $request_method $tc_restricted_method"
+	denyMap              = `map "$tc_restricted_method:$tc_acl" $tc_deny`
+	purgeMap             = `map "$request_method:$tc_deny" $tc_purge`
+	upstreamMethodMap    = "map $tc_purge $tc_upstream_method"
+)
+
+// accessControlServerDirectives are added to every server, to reject requests the ACLs deny.
+var accessControlServerDirectives = []string{
+	`if ($tc_deny) {`,
+	`	return 405;`,
+	`}`,
+}
+
+// accessControlLocationDirectives are added to every proxied location.
+// Open source nginx can't purge a cache entry, so allowed PURGE requests bypass
+// the cache and refetch the object from upstream with a GET, which replaces it.
+var accessControlLocationDirectives = []string{
+	`proxy_cache_bypass $tc_purge;`,
+	`proxy_method $tc_upstream_method;`,
+}
+
+// configureAccessControl adds the ip_allow.config equivalent ACLs. Clients are
+// classified by the $tc_acl geo variable, and $tc_deny is set for requests
+// the client isn't allowed to make.
+func (nb NginxBuilder) configureAccessControl(n *nginxConfig) ([]string, error) {
+	warnings := make([]string, 0)
+	acl := []string{
+		`default ` + aclNone,
+		`127.0.0.1 ` + aclAll,
+		`::1 ` + aclAll,
+	}
+	for _, ip := range atscfg.GetPurgeIPs(nb.toData.ServerParams) {
+		acl = append(acl, ip+" "+aclAll)
+	}
+
+	// every server block uses $tc_deny, so anything that isn't a mid gets the edge rules.
+	if nb.toData.Server.Type != tc.CacheTypeMid.String() {
+		n.maps[accessControlGeo] = acl
+		configureAccessControlForEdge(n.maps)
+		return warnings, nil
+	}
+
+	coalesceMaskLenV4, coalesceNumberV4, coalesceMaskLenV6, coalesceNumberV6, ws := atscfg.GetCoalesceMaskAndNumber(nb.toData.ServerParams)
+	warnings = append(warnings, ws...)
+	cidrs, cidr6s, ws, err := atscfg.GetAllowedCIDRsForMid(
+		nb.toData.Server,
+		nb.toData.Servers,
+		nb.toData.CacheGroups,
+		nb.toData.Topologies,
+		coalesceNumberV4,
+		coalesceMaskLenV4,
+		coalesceNumberV6,
+		coalesceMaskLenV6,
+	)
+	warnings = append(warnings, ws...)
+	if err != nil {
+		return warnings, err
+	}
+	for _, cidr := range append(cidrs, cidr6s...) {
+		acl = append(acl, cidr.String()+" "+aclAllButPushPurge)
+	}
+	acl = append(acl,
+		`10.0.0.0/8 `+aclAllButPushPurge,
+		`172.16.0.0/12 `+aclAllButPushPurge,
+		`192.168.0.0/16 `+aclAllButPushPurge,
+	)
+	n.maps[accessControlGeo] = acl
+	configureAccessControlForMid(n.maps)
+	return warnings, nil
+}
+
+func configureAccessControlForEdge(maps map[string][]string) {
+	maps[restrictedMethodsMap] = []string{
+		`default 0`,
+		`PUSH 1`,
+		`PURGE 1`,
+		`DELETE 1`,
+	}
+	maps[denyMap] = []string{
+		`default 0`,
+		`"1:` + aclNone + `" 1`,
+	}
+	addPurgeMaps(maps)
+}
+
+func configureAccessControlForMid(maps map[string][]string) {
+	maps[restrictedMethodsMap] = []string{
+		`default 0`,
+		`PUSH 1`,
+		`PURGE 1`,
+	}
+	maps[denyMap] = []string{
+		`default 0`,
+		// push and purge are not allowed except for the all acl
+		`"1:` + aclAllButPushPurge + `" 1`,
+		// mid caches only accept requests from allowed IPs
+		`"~:` + aclNone + `$" 1`,
+	}
+	addPurgeMaps(maps)
+}
+
+func addPurgeMaps(maps map[string][]string) {
+	maps[purgeMap] = []string{
+		`default 0`,
+		`"PURGE:0" 1`,
+	}
+	maps[upstreamMethodMap] = []string{
+		`default $request_method`,
+		`1 GET`,
+	}
+}
@@ -0,0 +1,114 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestConfigureAccessControl(t *testing.T) {
+	t.Run("edge server", func(t *testing.T) {
+		nb := NewNginxBuilder(&t3cutil.ConfigData{
+			Server: &atscfg.Server{Type: "EDGE"},
+			ServerParams: []tc.ParameterV5{
+				{
+					ConfigFile: "ip_allow.config",
+					Name:       "purge_allow_ip",
+					Value:      "1.1.1.1,3.3.3.3/16",
+				},
+			},
+		}, "")
+		n := newNginxConfig()
+		if _, err := nb.configureAccessControl(&n); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectedMaps := map[string][]string{
+			accessControlGeo: {
+				`default none`,
+				`127.0.0.1 all`,
+				`::1 all`,
+				`1.1.1.1 all`,
+				`3.3.3.3/16 all`,
+			},
+			restrictedMethodsMap: {`default 0`, `PUSH 1`, `PURGE 1`, `DELETE 1`},
+			denyMap:              {`default 0`, `"1:none" 1`},
+			purgeMap:             {`default 0`, `"PURGE:0" 1`},
+			upstreamMethodMap:    {`default $request_method`, `1 GET`},
+		}
+		if !reflect.DeepEqual(expectedMaps, n.maps) {
+			t.Errorf("expected %v got %v", expectedMaps, n.maps)
+		}
+	})
+	t.Run("mid server", func(t *testing.T) {
+		nb := NewNginxBuilder(&t3cutil.ConfigData{
+			Server: &atscfg.Server{
+				Type:       "MID",
+				HostName:   "server0",
+				CacheGroup: "cg0",
+			},
+			CacheGroups: []tc.CacheGroupNullableV5{
+				{Name: util.Ptr("cg0")},
+			},
+			Servers: []atscfg.Server{
+				{
+					HostName:   "monitor0",
+					CacheGroup: "monitorcg",
+					Type:       tc.MonitorTypeName,
+					Interfaces: []tc.ServerInterfaceInfoV40{
+						{
+							ServerInterfaceInfo: tc.ServerInterfaceInfo{
+								Name:        "eth0",
+								IPAddresses: []tc.ServerIPAddress{{Address: "1.2.3.4"}},
+							},
+						},
+					},
+				},
+			},
+		}, "")
+		n := newNginxConfig()
+		if _, err := nb.configureAccessControl(&n); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		expectedMaps := map[string][]string{
+			accessControlGeo: {
+				`default none`,
+				`127.0.0.1 all`,
+				`::1 all`,
+				`1.2.3.4/32 all_but_push_purge`,
+				`10.0.0.0/8 all_but_push_purge`,
+				`172.16.0.0/12 all_but_push_purge`,
+				`192.168.0.0/16 all_but_push_purge`,
+			},
+			restrictedMethodsMap: {`default 0`, `PUSH 1`, `PURGE 1`},
+			denyMap:              {`default 0`, `"1:all_but_push_purge" 1`, `"~:none$" 1`},
+			purgeMap:             {`default 0`, `"PURGE:0" 1`},
+			upstreamMethodMap:    {`default $request_method`, `1 GET`},
+		}
+		if !reflect.DeepEqual(expectedMaps, n.maps) {
+			t.Errorf("expected %v got %v", expectedMaps, n.maps)
+		}
+	})
+}
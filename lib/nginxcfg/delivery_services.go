@@ -0,0 +1,210 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+const cacheZone = "tc_cache"
+
+// configureDeliveryServices adds the upstreams and a server block for every delivery service
+// the server has parent data for, followed by the default server that rejects unknown hosts.
+func (nb *NginxBuilder) configureDeliveryServices(n *nginxConfig, parents *atscfg.ParentAbstraction) []string {
+	warnings := make([]string, 0)
+	isEdge := nb.toData.Server.Type == tc.CacheTypeEdge.String()
+	httpPort, httpsPort := nb.getPorts()
+
+	certs, certWarnings := nb.getCertificates()
+	warnings = append(warnings, certWarnings...)
+
+	anyHTTPS := false
+	serverNameDSes := make(map[string]string)
+	for _, svc := range parents.Services {
+		// the default destination of non-top-level caches isn't a delivery service.
+		if svc.DS.XMLID == "" {
+			continue
+		}
+
+		requestFQDNs, err := nb.getRequestFQDNs(svc)
+		if err != nil {
+			warnings = append(warnings, "error getting ds '"+svc.DS.XMLID+"' request fqdns, skipping! Error: "+err.Error())
+			continue
+		}
+		serverNames := make([]string, 0, len(requestFQDNs))
+		for _, fqdn := range requestFQDNs {
+			if xmlID, ok := serverNameDSes[fqdn]; ok {
+				warnings = append(warnings, fmt.Sprintf("ds '%s' request fqdn '%s' is already used by ds '%s', skipping fqdn!", svc.DS.XMLID, fqdn, xmlID))
+				continue
+			}
+			serverNameDSes[fqdn] = svc.DS.XMLID
+			serverNames = append(serverNames, fqdn)
+		}
+		if len(serverNames) == 0 {
+			continue
+		}
+
+		srv := server{
+			listen:      []string{strconv.Itoa(httpPort)},
+			serverNames: serverNames,
+			directives:  append([]string{}, accessControlServerDirectives...),
+		}
+
+		protocol := tc.DSProtocolHTTP
+		if svc.DS.Protocol != nil {
+			protocol = *svc.DS.Protocol
+		}
+		if isEdge && protocol != tc.DSProtocolHTTP {
+			cert, ok := certs[tc.DeliveryServiceName(svc.DS.XMLID)]
+			if !ok {
+				warnings = append(warnings, "ds '"+svc.DS.XMLID+"' uses https but has no certificate, serving http only!")
+			} else {
+				anyHTTPS = true
+				srv.listen = append(srv.listen, strconv.Itoa(httpsPort)+" ssl")
+				srv.directives = append(srv.directives,
+					"ssl_certificate "+cert[0]+";",
+					"ssl_certificate_key "+cert[1]+";",
+				)
+				if protocol == tc.DSProtocolHTTPToHTTPS {
+					srv.directives = append(srv.directives,
+						`if ($scheme = http) {`,
+						`	return 301 https://$host$request_uri;`,
+						`}`,
+					)
+				}
+			}
+		}
+
+		targets := configureUpstreams(n.upstreams, svc)
+		for i, target := range targets {
+			loc := location{path: "/"}
+			if i > 0 {
+				loc.path = "@" + target.name
+			}
+			fallback := ""
+			if i+1 < len(targets) {
+				fallback = targets[i+1].name
+			}
+			loc.directives = nb.getLocationDirectives(svc, target, fallback)
+			srv.locations = append(srv.locations, loc)
+		}
+
+		n.servers = append(n.servers, srv)
+	}
+
+	n.servers = append(n.servers, getDefaultServer(httpPort, httpsPort, anyHTTPS))
+	return warnings
+}
+
+// getLocationDirectives returns the directives to proxy and cache the service's requests through the target.
+func (nb *NginxBuilder) getLocationDirectives(svc *atscfg.ParentAbstractionService, target upstreamTarget, fallback string) []string {
+	lines := make([]string, 0)
+
+	qstringIgnore := tc.QueryStringIgnoreUseInCacheKeyAndPassUp
+	if svc.DS.QStringIgnore != nil {
+		qstringIgnore = *svc.DS.QStringIgnore
+	}
+	if qstringIgnore == tc.QueryStringIgnoreDropAtEdge && nb.toData.Server.Type == tc.CacheTypeEdge.String() {
+		lines = append(lines, `set $args "";`)
+	}
+
+	lines = append(lines, fmt.Sprintf("proxy_pass %s://%s;", target.scheme, target.name))
+	lines = append(lines, "proxy_set_header Host "+svc.DestDomain+";")
+	if target.scheme == "https" {
+		lines = append(lines, "proxy_ssl_server_name on;", "proxy_ssl_name "+svc.DestDomain+";")
+	}
+
+	if svc.DS.Type != nil && tc.DSType(*svc.DS.Type) == tc.DSTypeHTTPNoCache {
+		lines = append(lines, "proxy_cache off;")
+	} else {
+		lines = append(lines, "proxy_cache "+cacheZone+";")
+		// like ATS, the cache key is the remapped URL, so it's the same on every upstream
+		if qstringIgnore == tc.QueryStringIgnoreUseInCacheKeyAndPassUp {
+			lines = append(lines, "proxy_cache_key "+svc.DestDomain+"$request_uri;")
+		} else {
+			lines = append(lines, "proxy_cache_key "+svc.DestDomain+"$uri;")
+		}
+	}
+	lines = append(lines, accessControlLocationDirectives...)
+	lines = append(lines, getNextUpstreamDirectives(svc, fallback)...)
+	return lines
+}
+
+// getDefaultServer returns the server for requests that don't match any delivery service,
+// which are rejected like ATS does with remap required.
+func getDefaultServer(httpPort, httpsPort int, https bool) server {
+	srv := server{
+		listen:     []string{strconv.Itoa(httpPort) + " default_server"},
+		directives: []string{"return 404;"},
+	}
+	if https {
+		srv.listen = append(srv.listen, strconv.Itoa(httpsPort)+" ssl default_server")
+		srv.directives = append([]string{"ssl_reject_handshake on;"}, srv.directives...)
+	}
+	return srv
+}
+
+// getPorts returns the server's HTTP and HTTPS ports.
+func (nb *NginxBuilder) getPorts() (int, int) {
+	httpPort, httpsPort := 80, 443
+	if nb.toData.Server.TCPPort != nil && *nb.toData.Server.TCPPort > 0 {
+		httpPort = *nb.toData.Server.TCPPort
+	}
+	if nb.toData.Server.HTTPSPort != nil && *nb.toData.Server.HTTPSPort > 0 {
+		httpsPort = *nb.toData.Server.HTTPSPort
+	}
+	return httpPort, httpsPort
+}
+
+// getCertificates returns the certificate and key paths of the delivery services with certificates.
+func (nb *NginxBuilder) getCertificates() (map[tc.DeliveryServiceName][2]string, []string) {
+	dses, warnings := atscfg.DeliveryServicesToSSLMultiCertDSes(nb.toData.DeliveryServices)
+	dses = atscfg.GetSSLMultiCertDotConfigDeliveryServices(dses)
+
+	certs := make(map[tc.DeliveryServiceName][2]string, len(dses))
+	for dsName, ds := range dses {
+		cerName, keyName := atscfg.GetSSLMultiCertDotConfigCertAndKeyName(dsName, ds)
+		certs[dsName] = [2]string{filepath.Join(nb.sslDir, cerName), filepath.Join(nb.sslDir, keyName)}
+	}
+	return certs, warnings
+}
+
+// getRequestFQDNs returns the FQDNs of the requests the server receives for the
+// service's delivery service. Edges receive the delivery service's request FQDNs,
+// while other caches receive the origin FQDN, which edges set as the host.
+func (nb *NginxBuilder) getRequestFQDNs(svc *atscfg.ParentAbstractionService) ([]string, error) {
+	if nb.toData.Server.Type != tc.CacheTypeEdge.String() {
+		return []string{svc.DestDomain}, nil
+	}
+	dsRegexes := atscfg.MakeDSRegexMap(nb.toData.DeliveryServiceRegexes)
+	anyCastPartners := atscfg.GetAnyCastPartners(nb.toData.Server, nb.toData.Servers)
+	return atscfg.GetDSRequestFQDNs(
+		&svc.DS,
+		dsRegexes[tc.DeliveryServiceName(svc.DS.XMLID)],
+		nb.toData.Server,
+		anyCastPartners,
+		nb.toData.CDN.DomainName,
+	)
+}
@@ -0,0 +1,135 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestConfigureDeliveryServices(t *testing.T) {
+	nb := NewNginxBuilder(&t3cutil.ConfigData{
+		Server: &atscfg.Server{Type: "MID", TCPPort: util.IntPtr(8080)},
+	}, "/etc/nginx/ssl")
+	parents := &atscfg.ParentAbstraction{
+		Services: []*atscfg.ParentAbstractionService{
+			{
+				Name:       "demo1",
+				DestDomain: "origin.example.com",
+				Port:       80,
+				DS:         atscfg.DeliveryService{XMLID: "demo1", Type: util.StrPtr(string(tc.DSTypeHTTPNoCache))},
+			},
+			{
+				Name:       "demo2",
+				DestDomain: "origin.example.com",
+				Port:       80,
+				DS:         atscfg.DeliveryService{XMLID: "demo2"},
+			},
+			{
+				Name:       "default-destination",
+				DestDomain: ".",
+			},
+		},
+	}
+	n := newNginxConfig()
+	warnings := nb.configureDeliveryServices(&n, parents)
+
+	expectedWarnings := []string{"ds 'demo2' request fqdn 'origin.example.com' is already used by ds 'demo1', skipping fqdn!"}
+	if !reflect.DeepEqual(expectedWarnings, warnings) {
+		t.Errorf("expected warnings %v got %v", expectedWarnings, warnings)
+	}
+	expectedServers := []server{
+		{
+			listen:      []string{"8080"},
+			serverNames: []string{"origin.example.com"},
+			directives:  accessControlServerDirectives,
+			locations: []location{
+				{
+					path: "/",
+					directives: []string{
+						"proxy_pass http://demo1_origin;",
+						"proxy_set_header Host origin.example.com;",
+						"proxy_cache off;",
+						"proxy_cache_bypass $tc_purge;",
+						"proxy_method $tc_upstream_method;",
+						"proxy_next_upstream error timeout;",
+					},
+				},
+			},
+		},
+		{
+			listen:     []string{"8080 default_server"},
+			directives: []string{"return 404;"},
+		},
+	}
+	if !reflect.DeepEqual(expectedServers, n.servers) {
+		t.Errorf("expected servers %v got %v", expectedServers, n.servers)
+	}
+	if _, ok := n.upstreams["demo2_origin"]; ok {
+		t.Errorf("expected no upstream for skipped ds demo2")
+	}
+}
+
+func TestGetLocationDirectives(t *testing.T) {
+	nb := NewNginxBuilder(&t3cutil.ConfigData{
+		Server: &atscfg.Server{Type: "EDGE"},
+	}, "")
+	svc := &atscfg.ParentAbstractionService{
+		Name:       "demo1",
+		DestDomain: "origin.example.com",
+		Port:       443,
+		DS: atscfg.DeliveryService{
+			XMLID:         "demo1",
+			Type:          util.StrPtr(string(tc.DSTypeHTTP)),
+			QStringIgnore: util.IntPtr(tc.QueryStringIgnoreDropAtEdge),
+		},
+	}
+	expected := []string{
+		`set $args "";`,
+		"proxy_pass https://demo1_origin;",
+		"proxy_set_header Host origin.example.com;",
+		"proxy_ssl_server_name on;",
+		"proxy_ssl_name origin.example.com;",
+		"proxy_cache tc_cache;",
+		"proxy_cache_key origin.example.com$uri;",
+		"proxy_cache_bypass $tc_purge;",
+		"proxy_method $tc_upstream_method;",
+		"proxy_next_upstream error timeout;",
+	}
+	lines := nb.getLocationDirectives(svc, upstreamTarget{name: "demo1_origin", scheme: "https"}, "")
+	if !reflect.DeepEqual(expected, lines) {
+		t.Errorf("expected %v got %v", expected, lines)
+	}
+}
+
+func TestGetDefaultServer(t *testing.T) {
+	expected := server{
+		listen:     []string{"80 default_server", "443 ssl default_server"},
+		directives: []string{"ssl_reject_handshake on;", "return 404;"},
+	}
+	if srv := getDefaultServer(80, 443, true); !reflect.DeepEqual(expected, srv) {
+		t.Errorf("expected %v got %v", expected, srv)
+	}
+}
@@ -0,0 +1,156 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// nginxConfig contains all nginx.conf components
+type nginxConfig struct {
+	// http contains the directives of the http context, other than blocks.
+	http []string
+	// maps contains the map and geo blocks, keyed by their header, e.g. `geo $tc_acl`.
+	maps      map[string][]string
+	upstreams map[string]upstream
+	servers   []server
+}
+
+func newNginxConfig() nginxConfig {
+	return nginxConfig{
+		http:      make([]string, 0),
+		maps:      make(map[string][]string),
+		upstreams: make(map[string]upstream),
+		servers:   make([]server, 0),
+	}
+}
+
+func (n nginxConfig) String() string {
+	txt := "worker_processes auto;\n"
+	txt += "events {\n"
+	txt += "\tworker_connections 4096;\n"
+	txt += "}\n"
+	txt += "http {\n"
+	for _, directive := range n.http {
+		txt += fmt.Sprintf("\t%s\n", directive)
+	}
+
+	// maps and upstreams are sorted so the file doesn't change between runs with the same data
+	mapHeaders := make([]string, 0, len(n.maps))
+	for header := range n.maps {
+		mapHeaders = append(mapHeaders, header)
+	}
+	sort.Strings(mapHeaders)
+	for _, header := range mapHeaders {
+		txt += fmt.Sprintf("\t%s {\n", header)
+		for _, entry := range n.maps[header] {
+			txt += fmt.Sprintf("\t\t%s;\n", entry)
+		}
+		txt += "\t}\n"
+	}
+
+	upstreamNames := make([]string, 0, len(n.upstreams))
+	for name := range n.upstreams {
+		upstreamNames = append(upstreamNames, name)
+	}
+	sort.Strings(upstreamNames)
+	for _, name := range upstreamNames {
+		txt += fmt.Sprintf("\tupstream %s {\n", name)
+		txt += fmt.Sprint(n.upstreams[name])
+		txt += "\t}\n"
+	}
+
+	for _, srv := range n.servers {
+		txt += fmt.Sprint(srv)
+	}
+	txt += "}\n"
+	return txt
+}
+
+type upstream struct {
+	// balance is the load balancing directive, e.g. `hash $request_uri consistent`.
+	// Empty means nginx's default weighted round robin.
+	balance string
+	servers []upstreamServer
+}
+
+func (u upstream) String() string {
+	txt := ""
+	if u.balance != "" {
+		txt += fmt.Sprintf("\t\t%s;\n", u.balance)
+	}
+	for _, srv := range u.servers {
+		txt += fmt.Sprintf("\t\t%s;\n", srv)
+	}
+	return txt
+}
+
+type upstreamServer struct {
+	host   string
+	port   int
+	backup bool
+}
+
+func (s upstreamServer) String() string {
+	txt := "server " + net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	if s.backup {
+		txt += " backup"
+	}
+	return txt
+}
+
+type server struct {
+	listen      []string
+	serverNames []string
+	directives  []string
+	locations   []location
+}
+
+func (s server) String() string {
+	txt := "\tserver {\n"
+	for _, listen := range s.listen {
+		txt += fmt.Sprintf("\t\tlisten %s;\n", listen)
+	}
+	if len(s.serverNames) != 0 {
+		txt += fmt.Sprintf("\t\tserver_name %s;\n", strings.Join(s.serverNames, " "))
+	}
+	for _, directive := range s.directives {
+		txt += fmt.Sprintf("\t\t%s\n", directive)
+	}
+	for _, loc := range s.locations {
+		txt += fmt.Sprintf("\t\tlocation %s {\n", loc.path)
+		for _, directive := range loc.directives {
+			txt += fmt.Sprintf("\t\t\t%s\n", directive)
+		}
+		txt += "\t\t}\n"
+	}
+	txt += "\t}\n"
+	return txt
+}
+
+type location struct {
+	// path is the location match, e.g. `/` or `@name` for named locations.
+	path       string
+	directives []string
+}
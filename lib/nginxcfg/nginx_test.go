@@ -0,0 +1,71 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+)
+
+func TestNginxConfigString(t *testing.T) {
+	n := newNginxConfig()
+	n.http = append(n.http, "proxy_http_version 1.1;")
+	n.maps["map $a $b"] = []string{"default 0", "x 1"}
+	n.upstreams["demo1_primary"] = upstream{
+		balance: "hash $request_uri consistent",
+		servers: []upstreamServer{
+			{host: "mid1.example.com", port: 80},
+			{host: "2001:db8::1", port: 80, backup: true},
+		},
+	}
+	n.servers = append(n.servers, server{
+		listen:      []string{"80"},
+		serverNames: []string{"demo1.example.com", "demo1.cdn.example.com"},
+		directives:  []string{"return 404;"},
+		locations:   []location{{path: "/", directives: []string{"proxy_pass http://demo1_primary;"}}},
+	})
+	expected := `worker_processes auto;
+events {
+	worker_connections 4096;
+}
+http {
+	proxy_http_version 1.1;
+	map $a $b {
+		default 0;
+		x 1;
+	}
+	upstream demo1_primary {
+		hash $request_uri consistent;
+		server mid1.example.com:80;
+		server [2001:db8::1]:80 backup;
+	}
+	server {
+		listen 80;
+		server_name demo1.example.com demo1.cdn.example.com;
+		return 404;
+		location / {
+			proxy_pass http://demo1_primary;
+		}
+	}
+}
+`
+	if txt := n.String(); txt != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, txt)
+	}
+}
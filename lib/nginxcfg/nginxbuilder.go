@@ -0,0 +1,99 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/cache-config/t3cutil"
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+)
+
+// logFormat is the nginx equivalent of the custom_ats_2 ATS log format, so existing
+// log processing works with nginx caches. Note ttms is in seconds, not milliseconds.
+const logFormat = `'$msec chi=$remote_addr phn=$hostname php=$server_port shn=$upstream_addr ` +
+	`url=$scheme://$host$request_uri cqhm=$request_method cqhv=$server_protocol pssc=$status ` +
+	`ttms=$request_time b=$bytes_sent sssc=$upstream_status sscl=$upstream_response_length ` +
+	`cfsc=$upstream_cache_status uas="$http_user_agent" xmt="$http_x_moneytrace"'`
+
+// NginxBuilder builds the nginx config file using TO data.
+type NginxBuilder struct {
+	toData *t3cutil.ConfigData
+	// sslDir is the directory the delivery service certificates and keys are written to.
+	sslDir string
+}
+
+// NewNginxBuilder returns a new NginxBuilder object.
+func NewNginxBuilder(toData *t3cutil.ConfigData, sslDir string) NginxBuilder {
+	return NginxBuilder{
+		toData: toData,
+		sslDir: sslDir,
+	}
+}
+
+// BuildNginxConfig builds the nginx config file.
+func (nb *NginxBuilder) BuildNginxConfig() (string, []string, error) {
+	warnings := make([]string, 0)
+	n := newNginxConfig()
+	n.http = append(n.http,
+		"log_format tc_log "+logFormat+";",
+		"access_log /var/log/nginx/access.log tc_log;",
+		"proxy_cache_path /var/cache/nginx levels=1:2 keys_zone="+cacheZone+":100m inactive=7d use_temp_path=off;",
+		"proxy_http_version 1.1;",
+		`proxy_set_header Connection "";`,
+		// fallback from parents to secondary parents and the origin chains error pages
+		"recursive_error_pages on;",
+	)
+
+	aclWarnings, err := nb.configureAccessControl(&n)
+	warnings = append(warnings, aclWarnings...)
+	if err != nil {
+		return "", nil, fmt.Errorf("(warnings: %s) %w", strings.Join(warnings, ", "), err)
+	}
+
+	atsMajorVersion := uint(9)
+
+	parents, dataWarns, err := atscfg.MakeParentDotConfigData(
+		nb.toData.DeliveryServices,
+		nb.toData.Server,
+		nb.toData.Servers,
+		nb.toData.Topologies,
+		nb.toData.ServerParams,
+		nb.toData.ParentConfigParams,
+		nb.toData.ServerCapabilities,
+		nb.toData.DSRequiredCapabilities,
+		nb.toData.CacheGroups,
+		nb.toData.CacheGroupLatencies,
+		nb.toData.DeliveryServiceServers,
+		nb.toData.CDN,
+		&atscfg.ParentConfigOpts{},
+		atsMajorVersion,
+	)
+	warnings = append(warnings, dataWarns...)
+	if err != nil {
+		return "", nil, fmt.Errorf("(warnings: %s) %w", strings.Join(warnings, ", "), err)
+	}
+
+	dsWarnings := nb.configureDeliveryServices(&n, parents)
+	warnings = append(warnings, dsWarnings...)
+
+	return fmt.Sprint(n), warnings, nil
+}
@@ -0,0 +1,136 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+)
+
+// upstreamTarget is an upstream a delivery service location proxies to.
+type upstreamTarget struct {
+	name   string
+	scheme string
+}
+
+// configureUpstreams adds the upstreams of the service and returns them in the order they
+// should be tried: primary parents, secondary parents, then the origin.
+func configureUpstreams(upstreams map[string]upstream, svc *atscfg.ParentAbstractionService) []upstreamTarget {
+	targets := make([]upstreamTarget, 0)
+	originScheme := getOriginScheme(svc)
+
+	// parents that aren't proxies are the origin itself, e.g. on mids.
+	parentScheme := "http"
+	if !svc.ParentIsProxy {
+		parentScheme = originScheme
+	}
+	if len(svc.Parents) != 0 {
+		name := svc.Name + "_primary"
+		upstreams[name] = newParentUpstream(svc, svc.Parents)
+		targets = append(targets, upstreamTarget{name: name, scheme: parentScheme})
+	}
+	if len(svc.SecondaryParents) != 0 {
+		name := svc.Name + "_secondary"
+		upstreams[name] = newParentUpstream(svc, svc.SecondaryParents)
+		targets = append(targets, upstreamTarget{name: name, scheme: parentScheme})
+	}
+
+	// like parent.config go_direct, only go to the origin if parents fail when allowed to.
+	if len(targets) != 0 && !(svc.GoDirect && svc.ParentIsProxy) {
+		return targets
+	}
+	name := svc.Name + "_origin"
+	upstreams[name] = upstream{
+		servers: []upstreamServer{{host: svc.DestDomain, port: svc.Port}},
+	}
+	return append(targets, upstreamTarget{name: name, scheme: originScheme})
+}
+
+func newParentUpstream(svc *atscfg.ParentAbstractionService, parents []*atscfg.ParentAbstractionServiceParent) upstream {
+	balance, firstOnly := getBalance(svc.RetryPolicy, svc.IgnoreQueryStringInParentSelection)
+	u := upstream{balance: balance}
+	for i, parent := range parents {
+		u.servers = append(u.servers, upstreamServer{
+			host:   parent.FQDN,
+			port:   parent.Port,
+			backup: firstOnly && i > 0,
+		})
+	}
+	return u
+}
+
+// getBalance returns the upstream load balancing directive for the retry policy,
+// and whether only the first parent should be used while it's available.
+// nginx has no latching, so latched is treated like first.
+func getBalance(retryPolicy atscfg.ParentAbstractionServiceRetryPolicy, ignoreQueryString bool) (balance string, firstOnly bool) {
+	switch retryPolicy {
+	case atscfg.ParentAbstractionServiceRetryPolicyRoundRobinIP:
+		return "ip_hash", false
+	case atscfg.ParentAbstractionServiceRetryPolicyRoundRobinStrict:
+		return "", false
+	case atscfg.ParentAbstractionServiceRetryPolicyFirst, atscfg.ParentAbstractionServiceRetryPolicyLatched:
+		return "", true
+	}
+	if ignoreQueryString {
+		return "hash $uri consistent", false
+	}
+	return "hash $request_uri consistent", false
+}
+
+// getOriginScheme returns the scheme used to reach the service's origin.
+func getOriginScheme(svc *atscfg.ParentAbstractionService) string {
+	if svc.DS.OrgServerFQDN != nil {
+		if u, err := url.Parse(*svc.DS.OrgServerFQDN); err == nil && u.Scheme == "https" {
+			return "https"
+		}
+	}
+	if svc.Port == 443 {
+		return "https"
+	}
+	return "http"
+}
+
+// getNextUpstreamDirectives returns the directives that retry requests on other
+// servers of the same upstream, and fall back to the next upstream when all fail.
+func getNextUpstreamDirectives(svc *atscfg.ParentAbstractionService, fallback string) []string {
+	conditions := []string{"error", "timeout"}
+	codes := make([]int, 0, len(svc.MarkdownResponseCodes))
+	codes = append(codes, svc.MarkdownResponseCodes...)
+	sort.Ints(codes)
+	for _, code := range codes {
+		switch code {
+		case 500, 502, 503, 504, 403, 404, 429:
+			conditions = append(conditions, fmt.Sprintf("http_%d", code))
+		}
+	}
+	lines := []string{fmt.Sprintf("proxy_next_upstream %s;", strings.Join(conditions, " "))}
+	if svc.MaxMarkdownRetries > 0 {
+		// tries includes the first attempt
+		lines = append(lines, fmt.Sprintf("proxy_next_upstream_tries %d;", svc.MaxMarkdownRetries+1))
+	}
+	if fallback != "" {
+		lines = append(lines, fmt.Sprintf("error_page 502 504 = @%s;", fallback))
+	}
+	return lines
+}
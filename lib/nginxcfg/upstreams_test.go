@@ -0,0 +1,180 @@
+package nginxcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestConfigureUpstreams(t *testing.T) {
+	parents := []*atscfg.ParentAbstractionServiceParent{
+		{FQDN: "mid1.example.com", Port: 80},
+		{FQDN: "mid2.example.com", Port: 80},
+	}
+	secondaryParents := []*atscfg.ParentAbstractionServiceParent{
+		{FQDN: "mid3.example.com", Port: 8080},
+	}
+	testCases := []struct {
+		name              string
+		svc               *atscfg.ParentAbstractionService
+		expectedTargets   []upstreamTarget
+		expectedUpstreams map[string]upstream
+	}{
+		{
+			name: "no parents",
+			svc: &atscfg.ParentAbstractionService{
+				Name:       "demo1",
+				DestDomain: "origin.example.com",
+				Port:       443,
+			},
+			expectedTargets: []upstreamTarget{{name: "demo1_origin", scheme: "https"}},
+			expectedUpstreams: map[string]upstream{
+				"demo1_origin": {servers: []upstreamServer{{host: "origin.example.com", port: 443}}},
+			},
+		},
+		{
+			name: "primary and secondary parents without go direct",
+			svc: &atscfg.ParentAbstractionService{
+				Name:             "demo1",
+				DestDomain:       "origin.example.com",
+				Port:             80,
+				Parents:          parents,
+				SecondaryParents: secondaryParents,
+				ParentIsProxy:    true,
+				RetryPolicy:      atscfg.ParentAbstractionServiceRetryPolicyConsistentHash,
+			},
+			expectedTargets: []upstreamTarget{
+				{name: "demo1_primary", scheme: "http"},
+				{name: "demo1_secondary", scheme: "http"},
+			},
+			expectedUpstreams: map[string]upstream{
+				"demo1_primary": {
+					balance: "hash $request_uri consistent",
+					servers: []upstreamServer{
+						{host: "mid1.example.com", port: 80},
+						{host: "mid2.example.com", port: 80},
+					},
+				},
+				"demo1_secondary": {
+					balance: "hash $request_uri consistent",
+					servers: []upstreamServer{{host: "mid3.example.com", port: 8080}},
+				},
+			},
+		},
+		{
+			name: "first parents with go direct",
+			svc: &atscfg.ParentAbstractionService{
+				Name:          "demo1",
+				DestDomain:    "origin.example.com",
+				Port:          80,
+				Parents:       parents,
+				ParentIsProxy: true,
+				GoDirect:      true,
+				RetryPolicy:   atscfg.ParentAbstractionServiceRetryPolicyFirst,
+			},
+			expectedTargets: []upstreamTarget{
+				{name: "demo1_primary", scheme: "http"},
+				{name: "demo1_origin", scheme: "http"},
+			},
+			expectedUpstreams: map[string]upstream{
+				"demo1_primary": {
+					servers: []upstreamServer{
+						{host: "mid1.example.com", port: 80},
+						{host: "mid2.example.com", port: 80, backup: true},
+					},
+				},
+				"demo1_origin": {servers: []upstreamServer{{host: "origin.example.com", port: 80}}},
+			},
+		},
+		{
+			name: "origin parents",
+			svc: &atscfg.ParentAbstractionService{
+				Name:        "demo1",
+				DestDomain:  "origin.example.com",
+				Port:        443,
+				Parents:     []*atscfg.ParentAbstractionServiceParent{{FQDN: "origin.example.com", Port: 443}},
+				GoDirect:    true,
+				RetryPolicy: atscfg.ParentAbstractionServiceRetryPolicyRoundRobinStrict,
+				DS:          atscfg.DeliveryService{OrgServerFQDN: util.StrPtr("https://origin.example.com")},
+			},
+			expectedTargets: []upstreamTarget{{name: "demo1_primary", scheme: "https"}},
+			expectedUpstreams: map[string]upstream{
+				"demo1_primary": {servers: []upstreamServer{{host: "origin.example.com", port: 443}}},
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			upstreams := make(map[string]upstream)
+			targets := configureUpstreams(upstreams, tC.svc)
+			if !reflect.DeepEqual(tC.expectedTargets, targets) {
+				t.Errorf("expected targets %v got %v", tC.expectedTargets, targets)
+			}
+			if !reflect.DeepEqual(tC.expectedUpstreams, upstreams) {
+				t.Errorf("expected upstreams %v got %v", tC.expectedUpstreams, upstreams)
+			}
+		})
+	}
+}
+
+func TestGetBalance(t *testing.T) {
+	testCases := []struct {
+		retryPolicy       atscfg.ParentAbstractionServiceRetryPolicy
+		ignoreQueryString bool
+		expectedBalance   string
+		expectedFirstOnly bool
+	}{
+		{retryPolicy: atscfg.ParentAbstractionServiceRetryPolicyRoundRobinIP, expectedBalance: "ip_hash"},
+		{retryPolicy: atscfg.ParentAbstractionServiceRetryPolicyRoundRobinStrict, expectedBalance: ""},
+		{retryPolicy: atscfg.ParentAbstractionServiceRetryPolicyFirst, expectedFirstOnly: true},
+		{retryPolicy: atscfg.ParentAbstractionServiceRetryPolicyLatched, expectedFirstOnly: true},
+		{retryPolicy: atscfg.ParentAbstractionServiceRetryPolicyConsistentHash, expectedBalance: "hash $request_uri consistent"},
+		{retryPolicy: atscfg.ParentAbstractionServiceRetryPolicyConsistentHash, ignoreQueryString: true, expectedBalance: "hash $uri consistent"},
+		{retryPolicy: atscfg.ParentAbstractionServiceRetryPolicyInvalid, expectedBalance: "hash $request_uri consistent"},
+	}
+	for _, tC := range testCases {
+		t.Run(string(tC.retryPolicy), func(t *testing.T) {
+			balance, firstOnly := getBalance(tC.retryPolicy, tC.ignoreQueryString)
+			if balance != tC.expectedBalance || firstOnly != tC.expectedFirstOnly {
+				t.Errorf("expected %q %v got %q %v", tC.expectedBalance, tC.expectedFirstOnly, balance, firstOnly)
+			}
+		})
+	}
+}
+
+func TestGetNextUpstreamDirectives(t *testing.T) {
+	svc := &atscfg.ParentAbstractionService{
+		MarkdownResponseCodes: []int{503, 502, 599},
+		MaxMarkdownRetries:    2,
+	}
+	expected := []string{
+		"proxy_next_upstream error timeout http_502 http_503;",
+		"proxy_next_upstream_tries 3;",
+		"error_page 502 504 = @demo1_secondary;",
+	}
+	lines := getNextUpstreamDirectives(svc, "demo1_secondary")
+	if !reflect.DeepEqual(expected, lines) {
+		t.Errorf("expected %v got %v", expected, lines)
+	}
+}
@@ -31,37 +31,73 @@ func (v *VCLBuilder) configureDirectors(vclFile *vclFile, parents *atscfg.Parent
 	warnings := []string{}
 
 	vclFile.imports = append(vclFile.imports, "directors")
-	var err error
-	requestFQDNs := make([]string, 0)
 
+	// hosts are matched exactly, so they're normalized first, so e.g. "DS.example.com:8080" is served as "ds.example.com".
+	vclFile.addImport("std")
+	vclFile.subroutines["vcl_recv"] = append(vclFile.subroutines["vcl_recv"], normalizeHostLine)
+
+	hostFQDNs := []string{}
 	for _, svc := range parents.Services {
 		addBackends(vclFile.backends, append(svc.Parents, svc.SecondaryParents...), svc.DestDomain, svc.Port)
 		addDirectors(vclFile.subroutines, svc)
 
-		requestFQDNs = []string{svc.DestDomain}
-
-		if v.toData.Server.Type == tc.CacheTypeEdge.String() {
-			dsRegexes := atscfg.MakeDSRegexMap(v.toData.DeliveryServiceRegexes)
-			anyCastPartners := atscfg.GetAnyCastPartners(v.toData.Server, v.toData.Servers)
-			requestFQDNs, err = atscfg.GetDSRequestFQDNs(
-				&svc.DS,
-				dsRegexes[tc.DeliveryServiceName(svc.DS.XMLID)],
-				v.toData.Server,
-				anyCastPartners,
-				v.toData.CDN.DomainName,
-			)
-			if err != nil {
-				warnings = append(warnings, "error getting ds '"+svc.DS.XMLID+"' request fqdns, skipping! Error: "+err.Error())
-				continue
-			}
+		requestFQDNs, err := v.getRequestFQDNs(svc)
+		if err != nil {
+			warnings = append(warnings, "error getting ds '"+svc.DS.XMLID+"' request fqdns, skipping! Error: "+err.Error())
+			continue
 		}
 
 		assignBackends(vclFile.subroutines, svc, requestFQDNs)
+		hostFQDNs = append(hostFQDNs, requestFQDNs...)
 	}
+	rejectUnknownHosts(vclFile.subroutines, hostFQDNs)
 
 	return warnings, nil
 }
 
+// normalizeHostLine lowercases the request host and strips its port.
+const normalizeHostLine = `set req.http.host = std.tolower(regsub(req.http.host, ":[0-9]+$", ""));`
+
+// rejectUnknownHosts responds 404 to requests for hosts which aren't one of
+// the delivery services' request FQDNs, so they aren't served without the
+// delivery service's features, like URL signing.
+func rejectUnknownHosts(subroutines map[string][]string, fqdns []string) {
+	conditions := make([]string, 0, len(fqdns))
+	for _, fqdn := range fqdns {
+		cond := fmt.Sprintf(`req.http.host == "%s"`, strings.ToLower(fqdn))
+		if !containsString(conditions, cond) {
+			conditions = append(conditions, cond)
+		}
+	}
+	if len(conditions) == 0 {
+		subroutines["vcl_recv"] = append(subroutines["vcl_recv"], "return (synth(404));")
+		return
+	}
+	subroutines["vcl_recv"] = append(subroutines["vcl_recv"],
+		fmt.Sprintf("if (!(%s)) {", strings.Join(conditions, " || ")),
+		"	return (synth(404));",
+		"}",
+	)
+}
+
+// getRequestFQDNs returns the FQDNs of the requests the server receives for the
+// service's delivery service. Edges receive the delivery service's request FQDNs,
+// while other caches receive the origin FQDN, which edges set as the host.
+func (v *VCLBuilder) getRequestFQDNs(svc *atscfg.ParentAbstractionService) ([]string, error) {
+	if v.toData.Server.Type != tc.CacheTypeEdge.String() {
+		return []string{svc.DestDomain}, nil
+	}
+	dsRegexes := atscfg.MakeDSRegexMap(v.toData.DeliveryServiceRegexes)
+	anyCastPartners := atscfg.GetAnyCastPartners(v.toData.Server, v.toData.Servers)
+	return atscfg.GetDSRequestFQDNs(
+		&svc.DS,
+		dsRegexes[tc.DeliveryServiceName(svc.DS.XMLID)],
+		v.toData.Server,
+		anyCastPartners,
+		v.toData.CDN.DomainName,
+	)
+}
+
 func assignBackends(subroutines map[string][]string, svc *atscfg.ParentAbstractionService, requestFQDNs []string) {
 	lines := make([]string, 0)
 	hostHeaderLines := make([]string, 0)
@@ -69,8 +105,8 @@ func assignBackends(subroutines map[string][]string, svc *atscfg.ParentAbstracti
 	conditions := make([]string, 0)
 	backendConditions := make([]string, 0)
 	for _, fqdn := range requestFQDNs {
-		conditions = append(conditions, fmt.Sprintf(`req.http.host == "%s"`, fqdn))
-		backendConditions = append(backendConditions, fmt.Sprintf(`bereq.http.host == "%s"`, fqdn))
+		conditions = append(conditions, fmt.Sprintf(`req.http.host == "%s"`, strings.ToLower(fqdn)))
+		backendConditions = append(backendConditions, fmt.Sprintf(`bereq.http.host == "%s"`, strings.ToLower(fqdn)))
 	}
 
 	lines = append(lines, fmt.Sprintf("if (%s) {", strings.Join(conditions, " || ")))
@@ -326,6 +326,27 @@ func TestAssignBackends(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:        "edge with a mixed case request FQDN",
+			subroutines: make(map[string][]string),
+			svc: &atscfg.ParentAbstractionService{
+				Name:       "demo",
+				DestDomain: "origin.example.com",
+			},
+			requestFQDNs: []string{"DS.Example.com"},
+			expectedSubroutines: map[string][]string{
+				"vcl_recv": {
+					`if (req.http.host == "ds.example.com") {`,
+					`	set req.backend_hint = demo.backend();`,
+					`}`,
+				},
+				"vcl_backend_fetch": {
+					`if (bereq.http.host == "ds.example.com") {`,
+					`	set bereq.http.host = "origin.example.com";`,
+					`}`,
+				},
+			},
+		},
 		{
 			name:        "mid",
 			subroutines: make(map[string][]string),
@@ -352,3 +373,25 @@ func TestAssignBackends(t *testing.T) {
 		})
 	}
 }
+
+func TestRejectUnknownHosts(t *testing.T) {
+	subroutines := make(map[string][]string)
+	rejectUnknownHosts(subroutines, []string{"ds1.example.com", "DS2.example.com", "ds1.example.com"})
+	expected := map[string][]string{
+		"vcl_recv": {
+			`if (!(req.http.host == "ds1.example.com" || req.http.host == "ds2.example.com")) {`,
+			`	return (synth(404));`,
+			`}`,
+		},
+	}
+	if !reflect.DeepEqual(expected, subroutines) {
+		t.Errorf("expected %v got %v", expected, subroutines)
+	}
+
+	subroutines = make(map[string][]string)
+	rejectUnknownHosts(subroutines, nil)
+	expected = map[string][]string{"vcl_recv": {`return (synth(404));`}}
+	if !reflect.DeepEqual(expected, subroutines) {
+		t.Errorf("expected %v got %v", expected, subroutines)
+	}
+}
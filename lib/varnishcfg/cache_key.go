@@ -0,0 +1,197 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// cacheKey is the cache key of a delivery service, per the arguments of the
+// ATS cachekey plugin.
+type cacheKey struct {
+	removePrefix    bool
+	staticPrefix    string
+	removePath      bool
+	removeAllParams bool
+	sortParams      bool
+	includeParams   []string
+	excludeParams   []string
+	includeHeaders  []string
+	includeCookies  []string
+}
+
+// cacheKeyToVCL adds a vcl_hash for the delivery service's cache key, if its
+// query string handling or cachekey parameters change it from the default.
+func cacheKeyToVCL(vclFile *vclFile, dsLines map[string][]string, ds *atscfg.DeliveryService, dsParams []tc.ParameterV5) []string {
+	key, warnings := getCacheKey(ds, dsParams)
+	if key == nil {
+		return warnings
+	}
+	if key.sortParams {
+		vclFile.addImport("std")
+	}
+	dsLines["vcl_hash"] = append(dsLines["vcl_hash"], key.vclLines()...)
+	return warnings
+}
+
+// getCacheKey returns the delivery service's cache key, or nil if it uses
+// the default cache key.
+func getCacheKey(ds *atscfg.DeliveryService, dsParams []tc.ParameterV5) (*cacheKey, []string) {
+	warnings := make([]string, 0)
+	key := cacheKey{}
+	changed := false
+
+	if ds.QStringIgnore != nil && *ds.QStringIgnore == tc.QueryStringIgnoreIgnoreInCacheKeyAndPassUp {
+		key.removeAllParams = true
+		changed = true
+	}
+
+	for _, param := range dsParams {
+		arg, val := "", ""
+		switch {
+		case param.ConfigFile == "remap.config" && param.Name == "cachekey.pparam":
+			arg, val, _ = strings.Cut(strings.TrimPrefix(strings.TrimSpace(param.Value), "--"), "=")
+		case param.ConfigFile == "cachekey.config":
+			arg, val = param.Name, param.Value
+		default:
+			continue
+		}
+		changed = true
+
+		switch arg {
+		case "remove-prefix":
+			key.removePrefix = val == "true"
+		case "static-prefix":
+			key.staticPrefix = val
+		case "remove-path":
+			key.removePath = val == "true"
+		case "remove-all-params":
+			key.removeAllParams = val == "true"
+		case "sort-params":
+			key.sortParams = val == "true"
+		case "include-params":
+			key.includeParams = append(key.includeParams, splitCacheKeyList(val)...)
+		case "exclude-params":
+			key.excludeParams = append(key.excludeParams, splitCacheKeyList(val)...)
+		case "include-headers":
+			key.includeHeaders = append(key.includeHeaders, splitCacheKeyList(val)...)
+		case "include-cookies":
+			key.includeCookies = append(key.includeCookies, splitCacheKeyList(val)...)
+		case "separator":
+			// only changes how the key is joined, which doesn't apply to hash_data.
+		default:
+			warnings = append(warnings, fmt.Sprintf("ds '%s' cachekey parameter '%s' is not supported by Varnish, ignoring", ds.XMLID, arg))
+		}
+	}
+
+	for _, header := range key.includeHeaders {
+		if !vclHeaderNameRe.MatchString(header) {
+			warnings = append(warnings, fmt.Sprintf("ds '%s' cachekey header '%s' is not supported by Varnish, ignoring", ds.XMLID, header))
+		}
+	}
+	if !changed {
+		return nil, warnings
+	}
+	return &key, warnings
+}
+
+func splitCacheKeyList(val string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// vclLines returns the vcl_hash lines of the cache key. Like the default
+// vcl_hash, the host is part of the key, followed by the path and query.
+func (k cacheKey) vclLines() []string {
+	lines := make([]string, 0)
+	if !k.removePrefix {
+		lines = append(lines,
+			"if (req.http.host) {",
+			"\thash_data(req.http.host);",
+			"} else {",
+			"\thash_data(server.ip);",
+			"}",
+		)
+	}
+	if k.staticPrefix != "" {
+		lines = append(lines, fmt.Sprintf("hash_data(%s);", vclString(k.staticPrefix)))
+	}
+	if !k.removePath {
+		lines = append(lines, `hash_data(regsub(req.url, "\?.*$", ""));`)
+	}
+
+	url := "req.url"
+	if k.sortParams {
+		url = "std.querysort(req.url)"
+	}
+	switch {
+	case k.removeAllParams:
+	case len(k.includeParams) > 0:
+		for _, param := range k.includeParams {
+			if containsString(k.excludeParams, param) {
+				continue
+			}
+			quoted := regexp.QuoteMeta(param)
+			lines = append(lines,
+				fmt.Sprintf(`if (req.url ~ "[?&]%s(=|&|$)") {`, quoted),
+				fmt.Sprintf(`	hash_data(regsub(req.url, "^.*[?&](%s(=[^&]*)?)(&.*)?$", "\1"));`, quoted),
+				"}",
+			)
+		}
+	case len(k.excludeParams) > 0:
+		quoted := make([]string, 0, len(k.excludeParams))
+		for _, param := range k.excludeParams {
+			quoted = append(quoted, regexp.QuoteMeta(param))
+		}
+		lines = append(lines, fmt.Sprintf(`hash_data(regsuball(regsub(%s, "^[^?]*", ""), "[?&](%s)(=[^&]*)?", ""));`, url, strings.Join(quoted, "|")))
+	default:
+		lines = append(lines, fmt.Sprintf(`hash_data(regsub(%s, "^[^?]*", ""));`, url))
+	}
+
+	for _, header := range k.includeHeaders {
+		if !vclHeaderNameRe.MatchString(header) {
+			continue
+		}
+		lines = append(lines,
+			fmt.Sprintf("if (req.http.%s) {", header),
+			fmt.Sprintf("\thash_data(req.http.%s);", header),
+			"}",
+		)
+	}
+	for _, cookie := range k.includeCookies {
+		quoted := regexp.QuoteMeta(cookie)
+		lines = append(lines,
+			fmt.Sprintf(`if (req.http.Cookie ~ "(^|;\s*)%s=") {`, quoted),
+			fmt.Sprintf(`	hash_data(regsub(req.http.Cookie, "^(.*;\s*)?(%s=[^;]*).*$", "\2"));`, quoted),
+			"}",
+		)
+	}
+	return append(lines, "return (lookup);")
+}
@@ -0,0 +1,124 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestCacheKeyToVCL(t *testing.T) {
+	hostLines := []string{
+		`if (req.http.host) {`,
+		`	hash_data(req.http.host);`,
+		`} else {`,
+		`	hash_data(server.ip);`,
+		`}`,
+	}
+	testCases := []struct {
+		name             string
+		qstringIgnore    *int
+		params           []tc.ParameterV5
+		expectedLines    map[string][]string
+		expectedWarnings int
+	}{
+		{
+			name:          "default cache key",
+			qstringIgnore: util.IntPtr(tc.QueryStringIgnoreUseInCacheKeyAndPassUp),
+			expectedLines: map[string][]string{},
+		},
+		{
+			name:          "ignore query string",
+			qstringIgnore: util.IntPtr(tc.QueryStringIgnoreIgnoreInCacheKeyAndPassUp),
+			expectedLines: map[string][]string{
+				"vcl_hash": append(append([]string{}, hostLines...),
+					`hash_data(regsub(req.url, "\?.*$", ""));`,
+					`return (lookup);`,
+				),
+			},
+		},
+		{
+			name: "cachekey parameters",
+			params: []tc.ParameterV5{
+				{ConfigFile: "remap.config", Name: "cachekey.pparam", Value: "--include-params=a,b"},
+				{ConfigFile: "remap.config", Name: "cachekey.pparam", Value: "--include-headers=X-Foo"},
+				{ConfigFile: "cachekey.config", Name: "static-prefix", Value: "prefix"},
+				{ConfigFile: "cachekey.config", Name: "include-cookies", Value: "session"},
+				{ConfigFile: "remap.config", Name: "cachekey.pparam", Value: "--capture-path=/a/b/"},
+				{ConfigFile: "remap.config", Name: "background_fetch.pparam", Value: "--log=bg.log"},
+			},
+			expectedLines: map[string][]string{
+				"vcl_hash": append(append([]string{}, hostLines...),
+					`hash_data("prefix");`,
+					`hash_data(regsub(req.url, "\?.*$", ""));`,
+					`if (req.url ~ "[?&]a(=|&|$)") {`,
+					`	hash_data(regsub(req.url, "^.*[?&](a(=[^&]*)?)(&.*)?$", "\1"));`,
+					`}`,
+					`if (req.url ~ "[?&]b(=|&|$)") {`,
+					`	hash_data(regsub(req.url, "^.*[?&](b(=[^&]*)?)(&.*)?$", "\1"));`,
+					`}`,
+					`if (req.http.X-Foo) {`,
+					`	hash_data(req.http.X-Foo);`,
+					`}`,
+					`if (req.http.Cookie ~ "(^|;\s*)session=") {`,
+					`	hash_data(regsub(req.http.Cookie, "^(.*;\s*)?(session=[^;]*).*$", "\2"));`,
+					`}`,
+					`return (lookup);`,
+				),
+			},
+			expectedWarnings: 1,
+		},
+		{
+			name: "sorted and excluded parameters",
+			params: []tc.ParameterV5{
+				{ConfigFile: "remap.config", Name: "cachekey.pparam", Value: "--exclude-params=utm_source,utm_medium"},
+				{ConfigFile: "remap.config", Name: "cachekey.pparam", Value: "--sort-params=true"},
+				{ConfigFile: "remap.config", Name: "cachekey.pparam", Value: "--remove-prefix=true"},
+			},
+			expectedLines: map[string][]string{
+				"vcl_hash": {
+					`hash_data(regsub(req.url, "\?.*$", ""));`,
+					`hash_data(regsuball(regsub(std.querysort(req.url), "^[^?]*", ""), "[?&](utm_source|utm_medium)(=[^&]*)?", ""));`,
+					`return (lookup);`,
+				},
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			vclFile := newVCLFile(defaultVCLVersion)
+			dsLines := make(map[string][]string)
+			ds := &atscfg.DeliveryService{}
+			ds.XMLID = "ds1"
+			ds.QStringIgnore = tC.qstringIgnore
+			warnings := cacheKeyToVCL(&vclFile, dsLines, ds, tC.params)
+			if len(warnings) != tC.expectedWarnings {
+				t.Errorf("expected %d warnings got %v", tC.expectedWarnings, warnings)
+			}
+			if !reflect.DeepEqual(tC.expectedLines, dsLines) {
+				t.Errorf("expected %v got %v", tC.expectedLines, dsLines)
+			}
+		})
+	}
+}
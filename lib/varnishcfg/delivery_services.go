@@ -0,0 +1,179 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// redirectSynthStatusOffset is added to redirect status codes, e.g. 301, to
+// make the status of the synthetic response vcl_synth turns into the redirect.
+const redirectSynthStatusOffset = 400
+
+// configureDeliveryServices adds the VCL for the delivery service features ATS
+// gets from remap.config plugins: header rewrites, regex remap, query string
+// handling, URL signing, range request handling and cache key parameters.
+func (v VCLBuilder) configureDeliveryServices(vclFile *vclFile, parents *atscfg.ParentAbstraction) []string {
+	warnings := make([]string, 0)
+	isEdge := v.toData.Server.Type == tc.CacheTypeEdge.String()
+	remapParams := append(append([]tc.ParameterV5{}, v.toData.RemapConfigParams...), v.toData.CacheKeyConfigParams...)
+
+	configured := make(map[string]struct{})
+	for _, svc := range parents.Services {
+		ds := svc.DS
+		if _, ok := configured[ds.XMLID]; ok {
+			continue
+		}
+		configured[ds.XMLID] = struct{}{}
+
+		requestFQDNs, err := v.getRequestFQDNs(svc)
+		if err != nil {
+			warnings = append(warnings, "error getting ds '"+ds.XMLID+"' request fqdns, skipping! Error: "+err.Error())
+			continue
+		}
+		dsParams, err := getDSRemapParams(&ds, remapParams)
+		if err != nil {
+			warnings = append(warnings, "error getting ds '"+ds.XMLID+"' parameters, cache key parameters will be missing! Error: "+err.Error())
+		}
+
+		// lines are added in the order ATS remap.config runs the plugins.
+		dsLines := make(map[string][]string)
+		headerRewrites, err := v.getHeaderRewrites(&ds)
+		if err != nil {
+			warnings = append(warnings, "error getting ds '"+ds.XMLID+"' header rewrites, skipping! Error: "+err.Error())
+		}
+		for _, txt := range headerRewrites {
+			warnings = append(warnings, headerRewriteToVCL(vclFile, dsLines, ds.XMLID, txt)...)
+		}
+		if isEdge {
+			if ds.DSCP != 0 {
+				warnings = append(warnings, fmt.Sprintf("ds '%s' DSCP %d can't be set by Varnish, ignoring", ds.XMLID, ds.DSCP))
+			}
+			if ds.QStringIgnore != nil && *ds.QStringIgnore == tc.QueryStringIgnoreDropAtEdge {
+				dsLines["vcl_recv"] = append(dsLines["vcl_recv"], `set req.url = regsub(req.url, "\?.*$", "");`)
+			}
+			warnings = append(warnings, signingToVCL(vclFile, dsLines, &ds, v.toData.URLSigKeys)...)
+			warnings = append(warnings, regexRemapToVCL(vclFile, dsLines, &ds)...)
+		}
+		warnings = append(warnings, rangeRequestHandlingToVCL(dsLines, &ds, isEdge)...)
+		warnings = append(warnings, cacheKeyToVCL(vclFile, dsLines, &ds, dsParams)...)
+
+		addDSSubroutines(vclFile.subroutines, dsLines, requestFQDNs, svc.DestDomain)
+	}
+	return warnings
+}
+
+// addDSSubroutines adds the delivery service's lines to the subroutines, in a
+// condition on the host so they only apply to the delivery service's requests.
+// Backend requests may already have the origin FQDN as their host.
+func addDSSubroutines(subroutines map[string][]string, dsLines map[string][]string, requestFQDNs []string, originFQDN string) {
+	for name, lines := range dsLines {
+		if len(lines) == 0 {
+			continue
+		}
+		// request hosts are lowercased by normalizeHostLine, but the origin
+		// FQDN is set as the backend request host as it is.
+		hostVar := "req.http.host"
+		fqdns := make([]string, 0, len(requestFQDNs)+1)
+		for _, fqdn := range requestFQDNs {
+			fqdns = append(fqdns, strings.ToLower(fqdn))
+		}
+		if strings.HasPrefix(name, "vcl_backend_") {
+			hostVar = "bereq.http.host"
+			if !containsString(fqdns, originFQDN) {
+				fqdns = append(fqdns, originFQDN)
+			}
+		}
+		conditions := make([]string, 0, len(fqdns))
+		for _, fqdn := range fqdns {
+			conditions = append(conditions, fmt.Sprintf(`%s == "%s"`, hostVar, fqdn))
+		}
+		subroutines[name] = append(subroutines[name], fmt.Sprintf("if (%s) {", strings.Join(conditions, " || ")))
+		for _, line := range lines {
+			subroutines[name] = append(subroutines[name], "\t"+line)
+		}
+		subroutines[name] = append(subroutines[name], "}")
+	}
+}
+
+// addRedirectSynth adds the vcl_synth lines which turn a synthetic response
+// into a redirect with the given status, if they don't already exist, and
+// returns the status to pass to synth along with the redirect location.
+func addRedirectSynth(vclFile *vclFile, status int) (int, error) {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return 0, fmt.Errorf("unsupported redirect status %d", status)
+	}
+	synthStatus := status + redirectSynthStatusOffset
+	cond := fmt.Sprintf("if (resp.status == %d) {", synthStatus)
+	if containsString(vclFile.subroutines["vcl_synth"], cond) {
+		return synthStatus, nil
+	}
+	vclFile.subroutines["vcl_synth"] = append(vclFile.subroutines["vcl_synth"],
+		cond,
+		"\tset resp.http.Location = resp.reason;",
+		fmt.Sprintf("\tset resp.status = %d;", status),
+		fmt.Sprintf("\tset resp.reason = %s;", vclString(http.StatusText(status))),
+		"\treturn (deliver);",
+		"}",
+	)
+	return synthStatus, nil
+}
+
+// getDSRemapParams returns the remap.config and cachekey.config parameters on
+// the delivery service's profile.
+//
+// Unlike atscfg.GetDSParameters, parameters with the same name aren't layered,
+// because plugin parameters like cachekey.pparam may be repeated.
+func getDSRemapParams(ds *atscfg.DeliveryService, params []tc.ParameterV5) ([]tc.ParameterV5, error) {
+	dsParams := make([]tc.ParameterV5, 0)
+	if ds.ProfileName == nil || *ds.ProfileName == "" {
+		return dsParams, nil
+	}
+	var errs []error
+	for _, param := range params {
+		profiles := []string{}
+		if err := json.Unmarshal(param.Profiles, &profiles); err != nil {
+			errs = append(errs, fmt.Errorf("parameter %d profiles: %w", param.ID, err))
+			continue
+		}
+		if containsString(profiles, *ds.ProfileName) {
+			dsParams = append(dsParams, param)
+		}
+	}
+	return dsParams, errors.Join(errs...)
+}
+
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
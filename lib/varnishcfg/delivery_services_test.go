@@ -0,0 +1,101 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestAddDSSubroutines(t *testing.T) {
+	subroutines := map[string][]string{
+		"vcl_recv": {`set req.http.X-Existing = "1";`},
+	}
+	dsLines := map[string][]string{
+		"vcl_recv":          {`set req.http.X-Foo = "bar";`},
+		"vcl_backend_fetch": {`unset bereq.http.X-Foo;`},
+		"vcl_deliver":       {},
+	}
+	addDSSubroutines(subroutines, dsLines, []string{"ds1.example.com", "ds1.cdn.example.com"}, "origin.example.com")
+	expected := map[string][]string{
+		"vcl_recv": {
+			`set req.http.X-Existing = "1";`,
+			`if (req.http.host == "ds1.example.com" || req.http.host == "ds1.cdn.example.com") {`,
+			`	set req.http.X-Foo = "bar";`,
+			`}`,
+		},
+		"vcl_backend_fetch": {
+			`if (bereq.http.host == "ds1.example.com" || bereq.http.host == "ds1.cdn.example.com" || bereq.http.host == "origin.example.com") {`,
+			`	unset bereq.http.X-Foo;`,
+			`}`,
+		},
+	}
+	if !reflect.DeepEqual(expected, subroutines) {
+		t.Errorf("expected %v got %v", expected, subroutines)
+	}
+}
+
+func TestAddRedirectSynth(t *testing.T) {
+	vclFile := newVCLFile(defaultVCLVersion)
+	for i := 0; i < 2; i++ {
+		status, err := addRedirectSynth(&vclFile, 302)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if status != 702 {
+			t.Errorf("expected synth status 702 got %d", status)
+		}
+	}
+	expected := []string{
+		`if (resp.status == 702) {`,
+		`	set resp.http.Location = resp.reason;`,
+		`	set resp.status = 302;`,
+		`	set resp.reason = "Found";`,
+		`	return (deliver);`,
+		`}`,
+	}
+	if !reflect.DeepEqual(expected, vclFile.subroutines["vcl_synth"]) {
+		t.Errorf("expected %v got %v", expected, vclFile.subroutines["vcl_synth"])
+	}
+	if _, err := addRedirectSynth(&vclFile, 200); err == nil {
+		t.Error("expected an error for a non-redirect status")
+	}
+}
+
+func TestGetDSRemapParams(t *testing.T) {
+	params := []tc.ParameterV5{
+		{ID: 1, ConfigFile: "remap.config", Name: "cachekey.pparam", Value: "--sort-params=true", Profiles: []byte(`["DS_PROFILE"]`)},
+		{ID: 2, ConfigFile: "remap.config", Name: "cachekey.pparam", Value: "--remove-path=true", Profiles: []byte(`["DS_PROFILE","OTHER"]`)},
+		{ID: 3, ConfigFile: "remap.config", Name: "cachekey.pparam", Value: "--static-prefix=x", Profiles: []byte(`["OTHER"]`)},
+	}
+	ds := &atscfg.DeliveryService{}
+	ds.ProfileName = util.StrPtr("DS_PROFILE")
+	dsParams, err := getDSRemapParams(ds, params)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(dsParams) != 2 || dsParams[0].ID != 1 || dsParams[1].ID != 2 {
+		t.Errorf("expected parameters 1 and 2 got %v", dsParams)
+	}
+}
@@ -0,0 +1,482 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// headerRewriteHooks maps the hook conditions of ATS header_rewrite rules
+// to the VCL subroutines which run at the same point of the transaction.
+var headerRewriteHooks = map[string]string{
+	"%{REMAP_PSEUDO_HOOK}":           "vcl_recv",
+	"%{READ_REQUEST_HDR_HOOK}":       "vcl_recv",
+	"%{READ_REQUEST_PRE_REMAP_HOOK}": "vcl_recv",
+	"%{SEND_REQUEST_HDR_HOOK}":       "vcl_backend_fetch",
+	"%{READ_RESPONSE_HDR_HOOK}":      "vcl_backend_response",
+	"%{SEND_RESPONSE_HDR_HOOK}":      "vcl_deliver",
+}
+
+// headerRewriteObjects maps VCL subroutines to the object %{HEADER} and the
+// header operators act on in them.
+var headerRewriteObjects = map[string]string{
+	"vcl_recv":             "req",
+	"vcl_backend_fetch":    "bereq",
+	"vcl_backend_response": "beresp",
+	"vcl_deliver":          "resp",
+}
+
+// headerRewriteClientObjects maps VCL subroutines to the object holding the
+// client request in them, which %{CLIENT-HEADER} and %{METHOD} refer to.
+var headerRewriteClientObjects = map[string]string{
+	"vcl_recv":             "req",
+	"vcl_backend_fetch":    "bereq",
+	"vcl_backend_response": "bereq",
+	"vcl_deliver":          "req",
+}
+
+var headerRewriteReturnRe = regexp.MustCompile(`\s*__RETURN__\s*`)
+var headerRewriteVariableRe = regexp.MustCompile(`%\{([^}]*)\}`)
+var vclHeaderNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// headerRewriteRule is a header_rewrite rule: conditions, followed by the
+// operators performed if they match.
+type headerRewriteRule struct {
+	subroutine string
+	condition  string
+	nextOp     string
+	joinedWith string
+	lines      []string
+	last       bool
+	invalid    bool
+}
+
+// getHeaderRewrites returns the header rewrite texts of the delivery service
+// which apply to the server, per its tier, like remap.config.
+func (v VCLBuilder) getHeaderRewrites(ds *atscfg.DeliveryService) ([]string, error) {
+	texts := []string{}
+	add := func(txt *string) {
+		if txt != nil && *txt != "" {
+			texts = append(texts, *txt)
+		}
+	}
+	if ds.Topology == nil || *ds.Topology == "" {
+		if v.toData.Server.Type == tc.CacheTypeEdge.String() {
+			add(ds.EdgeHeaderRewrite)
+		} else {
+			add(ds.MidHeaderRewrite)
+		}
+		return texts, nil
+	}
+	placement, err := atscfg.GetServerTopologyPlacement(v.toData.Server, ds, v.toData.Topologies, v.toData.CacheGroups)
+	if err != nil {
+		return nil, err
+	}
+	if placement.IsFirstCacheTier {
+		add(ds.FirstHeaderRewrite)
+	}
+	if placement.IsInnerCacheTier {
+		add(ds.InnerHeaderRewrite)
+	}
+	if placement.IsLastCacheTier {
+		add(ds.LastHeaderRewrite)
+	}
+	return texts, nil
+}
+
+// headerRewriteToVCL translates the ATS header_rewrite rules in txt to VCL,
+// and adds the lines to the subroutines they run in. Rules with conditions or
+// operators which can't be translated are skipped, with a warning.
+func headerRewriteToVCL(vclFile *vclFile, dsLines map[string][]string, dsName string, txt string) []string {
+	warnings := make([]string, 0)
+	rules := make([]*headerRewriteRule, 0)
+	var rule *headerRewriteRule
+	inOperators := false
+
+	for _, line := range strings.Split(headerRewriteReturnRe.ReplaceAllString(txt, "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields, flags := splitHeaderRewriteLine(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if rule == nil || (fields[0] == "cond" && inOperators) {
+			rule = &headerRewriteRule{subroutine: "vcl_recv"}
+			rules = append(rules, rule)
+			inOperators = false
+		}
+		if fields[0] != "cond" {
+			inOperators = true
+		}
+		if rule.invalid {
+			continue
+		}
+
+		var err error
+		if fields[0] == "cond" {
+			err = rule.addCondition(fields[1:], flags)
+		} else {
+			err = rule.addOperator(vclFile, fields, flags)
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("ds '%s' header rewrite line '%s' can't be used with Varnish, skipping rule! Error: %s", dsName, line, err.Error()))
+			rule.invalid = true
+		}
+	}
+
+	for i, rule := range rules {
+		if rule.invalid || len(rule.lines) == 0 {
+			continue
+		}
+		if rule.last && i < len(rules)-1 {
+			warnings = append(warnings, fmt.Sprintf("ds '%s' header rewrite rule %d has the [L] flag, which is ignored by Varnish", dsName, i+1))
+		}
+		if rule.condition == "" {
+			dsLines[rule.subroutine] = append(dsLines[rule.subroutine], rule.lines...)
+			continue
+		}
+		dsLines[rule.subroutine] = append(dsLines[rule.subroutine], fmt.Sprintf("if (%s) {", rule.condition))
+		for _, l := range rule.lines {
+			dsLines[rule.subroutine] = append(dsLines[rule.subroutine], "\t"+l)
+		}
+		dsLines[rule.subroutine] = append(dsLines[rule.subroutine], "}")
+	}
+	return warnings
+}
+
+// splitHeaderRewriteLine splits a header_rewrite line into its fields, which
+// may be quoted, and the flags of its trailing [FLAG,...] field.
+func splitHeaderRewriteLine(line string) ([]string, []string) {
+	fields := make([]string, 0)
+	field := strings.Builder{}
+	inField := false
+	quoted := false
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			field.WriteRune(c)
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+			inField = true
+		case (c == ' ' || c == '\t') && !quoted:
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+
+	flags := make([]string, 0)
+	if len(fields) > 1 {
+		last := fields[len(fields)-1]
+		if strings.HasPrefix(last, "[") && strings.HasSuffix(last, "]") {
+			for _, flag := range strings.Split(strings.Trim(last, "[]"), ",") {
+				flags = append(flags, strings.ToUpper(strings.TrimSpace(flag)))
+			}
+			fields = fields[:len(fields)-1]
+		}
+	}
+	return fields, flags
+}
+
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// addCondition adds a cond line, without the cond field, to the rule.
+func (r *headerRewriteRule) addCondition(fields []string, flags []string) error {
+	if len(fields) == 0 {
+		return errors.New("missing condition")
+	}
+	if subroutine, ok := headerRewriteHooks[fields[0]]; ok {
+		r.subroutine = subroutine
+		return nil
+	}
+
+	expr, err := headerRewriteConditionToVCL(fields, flags, r.subroutine)
+	if err != nil {
+		return err
+	}
+	if hasFlag(flags, "NOT") {
+		expr = "!(" + expr + ")"
+	}
+	op := " && "
+	if hasFlag(flags, "OR") {
+		op = " || "
+	}
+
+	if r.condition == "" {
+		r.condition = expr
+	} else {
+		// the previous condition's flags join it to this one
+		if r.joinedWith != "" && r.joinedWith != r.nextOp {
+			r.condition = "(" + r.condition + ")"
+		}
+		r.condition += r.nextOp + expr
+		r.joinedWith = r.nextOp
+	}
+	r.nextOp = op
+	return nil
+}
+
+// headerRewriteConditionToVCL returns the VCL expression of a header_rewrite
+// condition and its operand.
+func headerRewriteConditionToVCL(fields []string, flags []string, subroutine string) (string, error) {
+	cond := fields[0]
+	switch cond {
+	case "%{TRUE}":
+		return "true", nil
+	case "%{FALSE}":
+		return "false", nil
+	}
+	if !headerRewriteVariableRe.MatchString(cond) {
+		return "", errors.New("malformed condition '" + cond + "'")
+	}
+	name := strings.Trim(cond, "%{}")
+	lhs, err := headerRewriteVariable(name, subroutine)
+	if err != nil {
+		return "", err
+	}
+	isNumeric := name == "STATUS"
+	if lhs == "client.ip" || lhs == "server.ip" {
+		lhs = `"" + ` + lhs
+	}
+
+	if len(fields) < 2 {
+		if strings.HasPrefix(name, "HEADER:") || strings.HasPrefix(name, "CLIENT-HEADER:") {
+			return lhs, nil
+		}
+		return "", errors.New("condition '" + cond + "' has no operand")
+	}
+	operand := strings.Join(fields[1:], " ")
+	noCase := ""
+	if hasFlag(flags, "NOCASE") {
+		noCase = "(?i)"
+	}
+
+	switch {
+	case strings.HasPrefix(operand, "/") && strings.HasSuffix(operand, "/") && len(operand) > 1:
+		if isNumeric {
+			return "", errors.New("condition '" + cond + "' can't match a regular expression")
+		}
+		return lhs + " ~ " + vclString(noCase+operand[1:len(operand)-1]), nil
+	case strings.HasPrefix(operand, "="), strings.HasPrefix(operand, ">"), strings.HasPrefix(operand, "<"):
+		op := operand[:1]
+		val := operand[1:]
+		if isNumeric {
+			if _, err := strconv.Atoi(val); err != nil {
+				return "", errors.New("condition '" + cond + "' operand '" + val + "' is not a number")
+			}
+			if op == "=" {
+				op = "=="
+			}
+			return lhs + " " + op + " " + val, nil
+		}
+		if op != "=" {
+			return "", errors.New("condition '" + cond + "' can't be compared with '" + op + "'")
+		}
+		if noCase != "" {
+			return lhs + " ~ " + vclString(noCase+"^"+regexp.QuoteMeta(val)+"$"), nil
+		}
+		return lhs + " == " + vclString(val), nil
+	}
+	return "", errors.New("unsupported operand '" + operand + "'")
+}
+
+// headerRewriteVariable returns the VCL variable of a header_rewrite
+// %{NAME} variable in the given subroutine.
+func headerRewriteVariable(name string, subroutine string) (string, error) {
+	switch {
+	case name == "CLIENT-IP" || name == "INBOUND:REMOTE-ADDR":
+		return "client.ip", nil
+	case name == "INBOUND:LOCAL-ADDR":
+		return "server.ip", nil
+	case name == "METHOD":
+		return headerRewriteClientObjects[subroutine] + ".method", nil
+	case name == "STATUS":
+		if subroutine != "vcl_backend_response" && subroutine != "vcl_deliver" {
+			return "", errors.New("%{STATUS} is only available in response hooks")
+		}
+		return headerRewriteObjects[subroutine] + ".status", nil
+	case strings.HasPrefix(name, "HEADER:"):
+		return headerVariable(headerRewriteObjects[subroutine], strings.TrimPrefix(name, "HEADER:"))
+	case strings.HasPrefix(name, "CLIENT-HEADER:"):
+		return headerVariable(headerRewriteClientObjects[subroutine], strings.TrimPrefix(name, "CLIENT-HEADER:"))
+	}
+	return "", errors.New("unsupported variable '%{" + name + "}'")
+}
+
+// headerVariable returns the VCL variable of the named header of the object.
+func headerVariable(object string, header string) (string, error) {
+	if !vclHeaderNameRe.MatchString(header) {
+		return "", errors.New("unsupported header name '" + header + "'")
+	}
+	return object + ".http." + header, nil
+}
+
+// headerRewriteValueToVCL returns the VCL string expression of a header_rewrite
+// value, which may contain %{NAME} variables.
+func headerRewriteValueToVCL(value string, subroutine string) (string, error) {
+	if strings.Contains(value, "%<") {
+		return "", errors.New("log field variables are not supported")
+	}
+	parts := make([]string, 0)
+	literalStart := 0
+	for _, loc := range headerRewriteVariableRe.FindAllStringSubmatchIndex(value, -1) {
+		if loc[0] > literalStart {
+			parts = append(parts, vclString(value[literalStart:loc[0]]))
+		}
+		variable, err := headerRewriteVariable(value[loc[2]:loc[3]], subroutine)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, variable)
+		literalStart = loc[1]
+	}
+	if literalStart < len(value) || len(parts) == 0 {
+		parts = append(parts, vclString(value[literalStart:]))
+	}
+	if len(parts) > 1 && !strings.HasPrefix(parts[0], `"`) && !strings.HasPrefix(parts[0], `{"`) {
+		parts = append([]string{`""`}, parts...)
+	}
+	return strings.Join(parts, " + "), nil
+}
+
+// addOperator adds an operator line to the rule.
+func (r *headerRewriteRule) addOperator(vclFile *vclFile, fields []string, flags []string) error {
+	if hasFlag(flags, "L") {
+		r.last = true
+	}
+	object := headerRewriteObjects[r.subroutine]
+	args := fields[1:]
+
+	switch fields[0] {
+	case "no-op":
+		return nil
+	case "rm-header":
+		if len(args) != 1 {
+			return errors.New("rm-header needs a header name")
+		}
+		header, err := headerVariable(object, args[0])
+		if err != nil {
+			return err
+		}
+		r.lines = append(r.lines, "unset "+header+";")
+		return nil
+	case "set-header", "add-header":
+		if len(args) < 2 {
+			return errors.New(fields[0] + " needs a header name and value")
+		}
+		header, err := headerVariable(object, args[0])
+		if err != nil {
+			return err
+		}
+		value, err := headerRewriteValueToVCL(strings.Join(args[1:], " "), r.subroutine)
+		if err != nil {
+			return err
+		}
+		if fields[0] == "set-header" {
+			r.lines = append(r.lines, "set "+header+" = "+value+";")
+			return nil
+		}
+		// Varnish can't add a second field with the same name, so values are appended to the existing field.
+		r.lines = append(r.lines,
+			"if ("+header+") {",
+			"\tset "+header+" = "+header+` + ", " + `+value+";",
+			"} else {",
+			"\tset "+header+" = "+value+";",
+			"}",
+		)
+		return nil
+	case "set-status":
+		if len(args) != 1 {
+			return errors.New("set-status needs a status code")
+		}
+		status, err := strconv.Atoi(args[0])
+		if err != nil || status < 100 || status > 599 {
+			return errors.New("invalid status code '" + args[0] + "'")
+		}
+		switch r.subroutine {
+		case "vcl_recv":
+			r.lines = append(r.lines, fmt.Sprintf("return (synth(%d));", status))
+		case "vcl_backend_response", "vcl_deliver":
+			r.lines = append(r.lines, fmt.Sprintf("set %s.status = %d;", object, status))
+		default:
+			return errors.New("set-status is not supported in " + r.subroutine)
+		}
+		return nil
+	case "set-status-reason":
+		if len(args) == 0 {
+			return errors.New("set-status-reason needs a reason")
+		}
+		if r.subroutine != "vcl_backend_response" && r.subroutine != "vcl_deliver" {
+			return errors.New("set-status-reason is not supported in " + r.subroutine)
+		}
+		r.lines = append(r.lines, "set "+object+".reason = "+vclString(strings.Join(args, " "))+";")
+		return nil
+	case "set-redirect":
+		if len(args) != 2 {
+			return errors.New("set-redirect needs a status code and URL")
+		}
+		if r.subroutine != "vcl_recv" && r.subroutine != "vcl_deliver" {
+			return errors.New("set-redirect is not supported in " + r.subroutine)
+		}
+		status, err := strconv.Atoi(args[0])
+		if err != nil {
+			return errors.New("invalid status code '" + args[0] + "'")
+		}
+		synthStatus, err := addRedirectSynth(vclFile, status)
+		if err != nil {
+			return err
+		}
+		location, err := headerRewriteValueToVCL(args[1], r.subroutine)
+		if err != nil {
+			return err
+		}
+		r.lines = append(r.lines, fmt.Sprintf("return (synth(%d, %s));", synthStatus, location))
+		return nil
+	}
+	return errors.New("unsupported operator '" + fields[0] + "'")
+}
@@ -0,0 +1,151 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeaderRewriteToVCL(t *testing.T) {
+	testCases := []struct {
+		name             string
+		txt              string
+		expectedLines    map[string][]string
+		expectedWarnings int
+	}{
+		{
+			name: "operators without conditions",
+			txt:  `set-header X-Foo "bar baz"__RETURN__rm-header Via`,
+			expectedLines: map[string][]string{
+				"vcl_recv": {
+					`set req.http.X-Foo = "bar baz";`,
+					`unset req.http.Via;`,
+				},
+			},
+		},
+		{
+			name: "hooks",
+			txt: `cond %{SEND_RESPONSE_HDR_HOOK}
+add-header Cache-Control "max-age=30"
+cond %{READ_RESPONSE_HDR_HOOK}
+set-header X-Cache-Server %{INBOUND:LOCAL-ADDR}
+cond %{SEND_REQUEST_HDR_HOOK}
+set-header X-Client "ip-%{CLIENT-IP}"`,
+			expectedLines: map[string][]string{
+				"vcl_deliver": {
+					`if (resp.http.Cache-Control) {`,
+					`	set resp.http.Cache-Control = resp.http.Cache-Control + ", " + "max-age=30";`,
+					`} else {`,
+					`	set resp.http.Cache-Control = "max-age=30";`,
+					`}`,
+				},
+				"vcl_backend_response": {
+					`set beresp.http.X-Cache-Server = server.ip;`,
+				},
+				"vcl_backend_fetch": {
+					`set bereq.http.X-Client = "ip-" + client.ip;`,
+				},
+			},
+		},
+		{
+			name: "conditions",
+			txt: `cond %{READ_RESPONSE_HDR_HOOK} [AND]
+cond %{STATUS} >399 [OR]
+cond %{HEADER:X-Error} [AND]
+cond %{CLIENT-HEADER:User-Agent} /bot/ [NOT,NOCASE]
+set-header Cache-Control "no-store" [L]`,
+			expectedLines: map[string][]string{
+				"vcl_backend_response": {
+					`if ((beresp.status > 399 || beresp.http.X-Error) && !(bereq.http.User-Agent ~ "(?i)bot")) {`,
+					`	set beresp.http.Cache-Control = "no-store";`,
+					`}`,
+				},
+			},
+		},
+		{
+			name: "status and redirect",
+			txt: `cond %{CLIENT-HEADER:Host} =old.example.com
+set-redirect 301 "https://new.example.com/"
+cond %{SEND_RESPONSE_HDR_HOOK}
+cond %{STATUS} =404
+set-status 410
+set-status-reason Gone`,
+			expectedLines: map[string][]string{
+				"vcl_recv": {
+					`if (req.http.Host == "old.example.com") {`,
+					`	return (synth(701, "https://new.example.com/"));`,
+					`}`,
+				},
+				"vcl_deliver": {
+					`if (resp.status == 404) {`,
+					`	set resp.status = 410;`,
+					`	set resp.reason = "Gone";`,
+					`}`,
+				},
+			},
+		},
+		{
+			name: "unsupported rules are skipped",
+			txt: `cond %{SEND_RESPONSE_HDR_HOOK}
+set-conn-dscp 8
+set-header X-Skipped "true"
+cond %{SEND_RESPONSE_HDR_HOOK}
+cond %{PATH} /foo/
+set-header X-Skipped "true"
+cond %{REMAP_PSEUDO_HOOK}
+cond %{STATUS} =200
+set-header X-Skipped "true"
+cond %{SEND_RESPONSE_HDR_HOOK}
+set-header X-Used "true"`,
+			expectedLines: map[string][]string{
+				"vcl_deliver": {
+					`set resp.http.X-Used = "true";`,
+				},
+			},
+			expectedWarnings: 3,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			vclFile := newVCLFile(defaultVCLVersion)
+			dsLines := make(map[string][]string)
+			warnings := headerRewriteToVCL(&vclFile, dsLines, "ds1", tC.txt)
+			if len(warnings) != tC.expectedWarnings {
+				t.Errorf("expected %d warnings got %v", tC.expectedWarnings, warnings)
+			}
+			if !reflect.DeepEqual(tC.expectedLines, dsLines) {
+				t.Errorf("expected %v got %v", tC.expectedLines, dsLines)
+			}
+		})
+	}
+}
+
+func TestSplitHeaderRewriteLine(t *testing.T) {
+	fields, flags := splitHeaderRewriteLine(`set-header X-Foo "a \"quoted\" value" [L,QSA]`)
+	expectedFields := []string{"set-header", "X-Foo", `a "quoted" value`}
+	if !reflect.DeepEqual(expectedFields, fields) {
+		t.Errorf("expected fields %v got %v", expectedFields, fields)
+	}
+	expectedFlags := []string{"L", "QSA"}
+	if !reflect.DeepEqual(expectedFlags, flags) {
+		t.Errorf("expected flags %v got %v", expectedFlags, flags)
+	}
+}
@@ -0,0 +1,81 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// rangeRequestHandlingToVCL adds the VCL for the delivery service's range
+// request handling.
+//
+// Varnish's default, fetching the whole object and serving ranges from it,
+// is the same as background fetch. Caching range requests keeps the Range in
+// the backend request and the cache key, so each range is cached separately.
+// Varnish has no slice plugin, so slice caches range requests instead.
+func rangeRequestHandlingToVCL(dsLines map[string][]string, ds *atscfg.DeliveryService, isEdge bool) []string {
+	warnings := make([]string, 0)
+	if ds.RangeRequestHandling == nil {
+		return warnings
+	}
+
+	switch *ds.RangeRequestHandling {
+	case tc.RangeRequestHandlingDontCache:
+		if isEdge {
+			dsLines["vcl_recv"] = append(dsLines["vcl_recv"],
+				"if (req.http.Range) {",
+				"\treturn (pass);",
+				"}",
+			)
+		}
+		return warnings
+	case tc.RangeRequestHandlingBackgroundFetch:
+		return warnings
+	case tc.RangeRequestHandlingSlice:
+		warnings = append(warnings, fmt.Sprintf("ds '%s' range request handling slice is not supported by Varnish, caching range requests instead", ds.XMLID))
+	case tc.RangeRequestHandlingCacheRangeRequest:
+	default:
+		warnings = append(warnings, fmt.Sprintf("ds '%s' has unknown range request handling %d, ignoring", ds.XMLID, *ds.RangeRequestHandling))
+		return warnings
+	}
+
+	// Varnish removes the Range from backend requests for cache misses, so it's kept in another header.
+	dsLines["vcl_recv"] = append(dsLines["vcl_recv"],
+		"unset req.http.X-TC-Range;",
+		"if (req.http.Range) {",
+		"\tset req.http.X-TC-Range = req.http.Range;",
+		"}",
+	)
+	dsLines["vcl_hash"] = append(dsLines["vcl_hash"],
+		"if (req.http.Range) {",
+		"\thash_data(req.http.Range);",
+		"}",
+	)
+	dsLines["vcl_backend_fetch"] = append(dsLines["vcl_backend_fetch"],
+		"if (bereq.http.X-TC-Range) {",
+		"\tset bereq.http.Range = bereq.http.X-TC-Range;",
+		"\tunset bereq.http.X-TC-Range;",
+		"}",
+	)
+	return warnings
+}
@@ -0,0 +1,114 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestRangeRequestHandlingToVCL(t *testing.T) {
+	cacheRangeRequestLines := map[string][]string{
+		"vcl_recv": {
+			`unset req.http.X-TC-Range;`,
+			`if (req.http.Range) {`,
+			`	set req.http.X-TC-Range = req.http.Range;`,
+			`}`,
+		},
+		"vcl_hash": {
+			`if (req.http.Range) {`,
+			`	hash_data(req.http.Range);`,
+			`}`,
+		},
+		"vcl_backend_fetch": {
+			`if (bereq.http.X-TC-Range) {`,
+			`	set bereq.http.Range = bereq.http.X-TC-Range;`,
+			`	unset bereq.http.X-TC-Range;`,
+			`}`,
+		},
+	}
+	testCases := []struct {
+		name             string
+		handling         *int
+		isEdge           bool
+		expectedLines    map[string][]string
+		expectedWarnings int
+	}{
+		{
+			name:          "no range request handling",
+			isEdge:        true,
+			expectedLines: map[string][]string{},
+		},
+		{
+			name:     "don't cache on edge",
+			handling: util.IntPtr(tc.RangeRequestHandlingDontCache),
+			isEdge:   true,
+			expectedLines: map[string][]string{
+				"vcl_recv": {
+					`if (req.http.Range) {`,
+					`	return (pass);`,
+					`}`,
+				},
+			},
+		},
+		{
+			name:          "don't cache on mid",
+			handling:      util.IntPtr(tc.RangeRequestHandlingDontCache),
+			expectedLines: map[string][]string{},
+		},
+		{
+			name:          "background fetch",
+			handling:      util.IntPtr(tc.RangeRequestHandlingBackgroundFetch),
+			isEdge:        true,
+			expectedLines: map[string][]string{},
+		},
+		{
+			name:          "cache range requests",
+			handling:      util.IntPtr(tc.RangeRequestHandlingCacheRangeRequest),
+			expectedLines: cacheRangeRequestLines,
+		},
+		{
+			name:             "slice",
+			handling:         util.IntPtr(tc.RangeRequestHandlingSlice),
+			isEdge:           true,
+			expectedLines:    cacheRangeRequestLines,
+			expectedWarnings: 1,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			dsLines := make(map[string][]string)
+			ds := &atscfg.DeliveryService{}
+			ds.XMLID = "ds1"
+			ds.RangeRequestHandling = tC.handling
+			warnings := rangeRequestHandlingToVCL(dsLines, ds, tC.isEdge)
+			if len(warnings) != tC.expectedWarnings {
+				t.Errorf("expected %d warnings got %v", tC.expectedWarnings, warnings)
+			}
+			if !reflect.DeepEqual(tC.expectedLines, dsLines) {
+				t.Errorf("expected %v got %v", tC.expectedLines, dsLines)
+			}
+		})
+	}
+}
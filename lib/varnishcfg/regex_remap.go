@@ -0,0 +1,135 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+)
+
+var regexRemapGroupRe = regexp.MustCompile(`\$([0-9])`)
+
+// regexRemapToVCL translates the ATS regex_remap rules of the delivery service
+// to VCL which rewrites the URL of, or redirects, requests matching them.
+//
+// Like regex_remap, the first matching rule is applied. Rewrites only change
+// the path and query of the request, the host of the substitution is ignored
+// because the request is always sent to the delivery service's origin.
+func regexRemapToVCL(vclFile *vclFile, dsLines map[string][]string, ds *atscfg.DeliveryService) []string {
+	warnings := make([]string, 0)
+	if ds.RegexRemap == nil || *ds.RegexRemap == "" {
+		return warnings
+	}
+	originHost := ""
+	if ds.OrgServerFQDN != nil {
+		originHost, _ = atscfg.GetHostPortFromURI(*ds.OrgServerFQDN)
+	}
+
+	rules := make([][2]string, 0) // pattern, action
+	for _, line := range strings.Split(strings.Replace(*ds.RegexRemap, "__RETURN__", "\n", -1), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			warnings = append(warnings, fmt.Sprintf("ds '%s' regex remap line '%s' has no substitution, skipping!", ds.XMLID, line))
+			continue
+		}
+
+		caseless := ""
+		status := 0
+		var err error
+		for _, opt := range fields[2:] {
+			switch {
+			case opt == "@caseless":
+				caseless = "(?i)"
+			case strings.HasPrefix(opt, "@status="):
+				status, err = strconv.Atoi(strings.TrimPrefix(opt, "@status="))
+			default:
+				warnings = append(warnings, fmt.Sprintf("ds '%s' regex remap option '%s' is not supported by Varnish, ignoring", ds.XMLID, opt))
+			}
+		}
+		if err != nil || (status != 0 && (status < 100 || status > 599)) {
+			warnings = append(warnings, fmt.Sprintf("ds '%s' regex remap line '%s' has an invalid status, skipping!", ds.XMLID, line))
+			continue
+		}
+
+		substitution := regexRemapGroupRe.ReplaceAllString(fields[1], `\${1}`)
+		if strings.Contains(substitution, "$") {
+			warnings = append(warnings, fmt.Sprintf("ds '%s' regex remap line '%s' uses substitution variables other than $0-$9, which are not supported by Varnish, skipping!", ds.XMLID, line))
+			continue
+		}
+
+		// regex_remap replaces the whole URL with the substitution, not just the part that matched.
+		pattern := vclString(caseless + "^.*?(?:" + fields[0] + ").*$")
+
+		action := ""
+		switch {
+		case status >= 300 && status < 400:
+			synthStatus, err := addRedirectSynth(vclFile, status)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("ds '%s' regex remap line '%s' can't be used with Varnish, skipping! Error: %s", ds.XMLID, line, err.Error()))
+				continue
+			}
+			action = fmt.Sprintf("return (synth(%d, regsub(req.url, %s, %s)));", synthStatus, pattern, vclString(substitution))
+		case status != 0:
+			action = fmt.Sprintf("return (synth(%d));", status)
+		default:
+			host, path := splitRegexRemapSubstitution(substitution)
+			if host != "" && host != originHost {
+				warnings = append(warnings, fmt.Sprintf("ds '%s' regex remap substitution host '%s' is ignored by Varnish, requests go to origin '%s'", ds.XMLID, host, originHost))
+			}
+			action = fmt.Sprintf("set req.url = regsub(req.url, %s, %s);", pattern, vclString(path))
+		}
+		rules = append(rules, [2]string{pattern, action})
+	}
+
+	for i, rule := range rules {
+		cond := "} elsif"
+		if i == 0 {
+			cond = "if"
+		}
+		dsLines["vcl_recv"] = append(dsLines["vcl_recv"], fmt.Sprintf("%s (req.url ~ %s) {", cond, rule[0]), "\t"+rule[1])
+	}
+	if len(rules) > 0 {
+		dsLines["vcl_recv"] = append(dsLines["vcl_recv"], "}")
+	}
+	return warnings
+}
+
+// splitRegexRemapSubstitution returns the host and path of a regex_remap
+// substitution, which is either a URL or a path.
+func splitRegexRemapSubstitution(substitution string) (string, string) {
+	i := strings.Index(substitution, "://")
+	if i < 0 {
+		return "", substitution
+	}
+	rest := substitution[i+len("://"):]
+	slash := strings.Index(rest, "/")
+	if slash < 0 {
+		return rest, "/"
+	}
+	return rest[:slash], rest[slash:]
+}
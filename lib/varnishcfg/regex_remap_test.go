@@ -0,0 +1,110 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestRegexRemapToVCL(t *testing.T) {
+	testCases := []struct {
+		name             string
+		regexRemap       *string
+		expectedLines    map[string][]string
+		expectedSynth    []string
+		expectedWarnings int
+	}{
+		{
+			name:          "no regex remap",
+			regexRemap:    nil,
+			expectedLines: map[string][]string{},
+		},
+		{
+			name:       "rewrites",
+			regexRemap: util.StrPtr(`^/old/(.*)$ http://origin.example.com/new/$1__RETURN__^/CASE/ /case/ @caseless`),
+			expectedLines: map[string][]string{
+				"vcl_recv": {
+					`if (req.url ~ "^.*?(?:^/old/(.*)$).*$") {`,
+					`	set req.url = regsub(req.url, "^.*?(?:^/old/(.*)$).*$", "/new/\1");`,
+					`} elsif (req.url ~ "(?i)^.*?(?:^/CASE/).*$") {`,
+					`	set req.url = regsub(req.url, "(?i)^.*?(?:^/CASE/).*$", "/case/");`,
+					`}`,
+				},
+			},
+		},
+		{
+			name:       "redirects and status",
+			regexRemap: util.StrPtr("^/moved/(.*) https://other.example.com/$1 @status=301\n^/forbidden/ http://origin.example.com/ @status=403"),
+			expectedLines: map[string][]string{
+				"vcl_recv": {
+					`if (req.url ~ "^.*?(?:^/moved/(.*)).*$") {`,
+					`	return (synth(701, regsub(req.url, "^.*?(?:^/moved/(.*)).*$", "https://other.example.com/\1")));`,
+					`} elsif (req.url ~ "^.*?(?:^/forbidden/).*$") {`,
+					`	return (synth(403));`,
+					`}`,
+				},
+			},
+			expectedSynth: []string{
+				`if (resp.status == 701) {`,
+				`	set resp.http.Location = resp.reason;`,
+				`	set resp.status = 301;`,
+				`	set resp.reason = "Moved Permanently";`,
+				`	return (deliver);`,
+				`}`,
+			},
+		},
+		{
+			name:       "unsupported lines",
+			regexRemap: util.StrPtr("^/host/ http://$h/\n^/nosubstitution/\n^/other/(.*) http://other.example.com/$1"),
+			expectedLines: map[string][]string{
+				"vcl_recv": {
+					`if (req.url ~ "^.*?(?:^/other/(.*)).*$") {`,
+					`	set req.url = regsub(req.url, "^.*?(?:^/other/(.*)).*$", "/\1");`,
+					`}`,
+				},
+			},
+			expectedWarnings: 3,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			vclFile := newVCLFile(defaultVCLVersion)
+			dsLines := make(map[string][]string)
+			ds := &atscfg.DeliveryService{}
+			ds.XMLID = "ds1"
+			ds.OrgServerFQDN = util.StrPtr("http://origin.example.com")
+			ds.RegexRemap = tC.regexRemap
+			warnings := regexRemapToVCL(&vclFile, dsLines, ds)
+			if len(warnings) != tC.expectedWarnings {
+				t.Errorf("expected %d warnings got %v", tC.expectedWarnings, warnings)
+			}
+			if !reflect.DeepEqual(tC.expectedLines, dsLines) {
+				t.Errorf("expected %v got %v", tC.expectedLines, dsLines)
+			}
+			if !reflect.DeepEqual(tC.expectedSynth, vclFile.subroutines["vcl_synth"]) {
+				t.Errorf("expected vcl_synth %v got %v", tC.expectedSynth, vclFile.subroutines["vcl_synth"])
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// urlSigParamsRe matches the query parameters the ATS url_sig plugin signs
+// URLs with. Only signatures of all parts of the URL, P=1, are supported.
+const urlSigParamsRe = `[?&](C=[^&]+&)?E=[0-9]+&A=[12]&K=[0-9]+&P=1&S=[0-9a-f]+$`
+
+// urlSigAlgorithms maps the url_sig A parameter to the vmod digest HMAC function.
+var urlSigAlgorithms = []struct {
+	param    string
+	function string
+}{
+	{"1", "digest.hmac_sha1"},
+	{"2", "digest.hmac_md5"},
+}
+
+// signingToVCL adds the VCL which verifies the URL signatures of the delivery
+// service's requests.
+//
+// URL Sig signatures are verified like the ATS url_sig plugin, with the
+// digest vmod, and the signature parameters are removed from the request.
+// URI Signing is not supported by Varnish, so all requests are denied, rather
+// than serving content meant to be signed to anyone.
+func signingToVCL(vclFile *vclFile, dsLines map[string][]string, ds *atscfg.DeliveryService, allURLSigKeys map[tc.DeliveryServiceName]tc.URLSigKeys) []string {
+	warnings := make([]string, 0)
+	if ds.SigningAlgorithm == nil {
+		return warnings
+	}
+
+	switch *ds.SigningAlgorithm {
+	case tc.SigningAlgorithmURLSig:
+	case tc.SigningAlgorithmURISigning:
+		warnings = append(warnings, fmt.Sprintf("ds '%s' URI signing is not supported by Varnish, denying all requests!", ds.XMLID))
+		dsLines["vcl_recv"] = append(dsLines["vcl_recv"], "return (synth(403));")
+		return warnings
+	default:
+		return warnings
+	}
+
+	keys := getURLSigKeys(allURLSigKeys[tc.DeliveryServiceName(ds.XMLID)])
+	if len(keys) == 0 {
+		warnings = append(warnings, fmt.Sprintf("ds '%s' has no URL sig keys, denying all requests!", ds.XMLID))
+		dsLines["vcl_recv"] = append(dsLines["vcl_recv"], "return (synth(403));")
+		return warnings
+	}
+	vclFile.addImport("std")
+	vclFile.addImport("digest")
+
+	lines := []string{
+		fmt.Sprintf(`if (req.url !~ "%s") {`, urlSigParamsRe),
+		"\treturn (synth(403));",
+		"}",
+		`if (std.time(regsub(req.url, "^.*[?&]E=([0-9]+)&.*$", "\1"), now) < now) {`,
+		"\treturn (synth(403));",
+		"}",
+		`if (req.url ~ "[?&]C=[^&]+&E=" && regsub(req.url, "^.*[?&]C=([^&]+)&E=.*$", "\1") != "" + client.ip) {`,
+		"\treturn (synth(403));",
+		"}",
+		// the signature is of the URL without its scheme, up to and including S=
+		`set req.http.X-TC-URL-Sig-String = req.http.host + regsub(req.url, "[0-9a-f]+$", "");`,
+		`set req.http.X-TC-URL-Sig-Key = regsub(req.url, "^.*&A=([12])&K=([0-9]+)&P=1&S=[0-9a-f]+$", "\1:\2");`,
+		`set req.http.X-TC-URL-Sig = "0x" + regsub(req.url, "^.*&S=([0-9a-f]+)$", "\1");`,
+		`unset req.http.X-TC-URL-Sig-Valid;`,
+	}
+	cond := "if"
+	for _, key := range keys {
+		for _, alg := range urlSigAlgorithms {
+			lines = append(lines,
+				fmt.Sprintf(`%s (req.http.X-TC-URL-Sig-Key == "%s:%d") {`, cond, alg.param, key.index),
+				fmt.Sprintf(`	set req.http.X-TC-URL-Sig-Valid = %s(%s, req.http.X-TC-URL-Sig-String);`, alg.function, vclString(key.value)),
+			)
+			cond = "} elsif"
+		}
+	}
+	lines = append(lines,
+		"}",
+		"if (req.http.X-TC-URL-Sig-Valid != req.http.X-TC-URL-Sig) {",
+		"\treturn (synth(403));",
+		"}",
+		"unset req.http.X-TC-URL-Sig-String;",
+		"unset req.http.X-TC-URL-Sig-Key;",
+		"unset req.http.X-TC-URL-Sig;",
+		"unset req.http.X-TC-URL-Sig-Valid;",
+		fmt.Sprintf(`set req.url = regsub(req.url, "%s", "");`, urlSigParamsRe),
+	)
+	dsLines["vcl_recv"] = append(dsLines["vcl_recv"], lines...)
+	return warnings
+}
+
+type urlSigKey struct {
+	index int
+	value string
+}
+
+// getURLSigKeys returns the keys, named key0 to key15, sorted by their index.
+func getURLSigKeys(keys tc.URLSigKeys) []urlSigKey {
+	sorted := make([]urlSigKey, 0, len(keys))
+	for name, value := range keys {
+		index, err := strconv.Atoi(strings.TrimPrefix(name, "key"))
+		if err != nil || !strings.HasPrefix(name, "key") || value == "" {
+			continue
+		}
+		sorted = append(sorted, urlSigKey{index: index, value: value})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].index < sorted[j].index })
+	return sorted
+}
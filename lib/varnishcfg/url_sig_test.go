@@ -0,0 +1,107 @@
+package varnishcfg
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-atscfg"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestSigningToVCL(t *testing.T) {
+	keys := map[tc.DeliveryServiceName]tc.URLSigKeys{
+		"ds1": {"key1": "secret1", "key0": "secret0", "notakey": "x"},
+	}
+	testCases := []struct {
+		name             string
+		algorithm        *string
+		xmlID            string
+		expectedImports  []string
+		expectedContains []string
+		expectedWarnings int
+	}{
+		{
+			name:            "no signing",
+			xmlID:           "ds1",
+			expectedImports: []string{},
+		},
+		{
+			name:            "url sig",
+			algorithm:       util.StrPtr(tc.SigningAlgorithmURLSig),
+			xmlID:           "ds1",
+			expectedImports: []string{"std", "digest"},
+			expectedContains: []string{
+				`if (req.url !~ "[?&](C=[^&]+&)?E=[0-9]+&A=[12]&K=[0-9]+&P=1&S=[0-9a-f]+$") {`,
+				`if (req.http.X-TC-URL-Sig-Key == "1:0") {`,
+				`	set req.http.X-TC-URL-Sig-Valid = digest.hmac_sha1("secret0", req.http.X-TC-URL-Sig-String);`,
+				`} elsif (req.http.X-TC-URL-Sig-Key == "2:0") {`,
+				`	set req.http.X-TC-URL-Sig-Valid = digest.hmac_md5("secret0", req.http.X-TC-URL-Sig-String);`,
+				`} elsif (req.http.X-TC-URL-Sig-Key == "1:1") {`,
+				`	set req.http.X-TC-URL-Sig-Valid = digest.hmac_sha1("secret1", req.http.X-TC-URL-Sig-String);`,
+				`set req.url = regsub(req.url, "[?&](C=[^&]+&)?E=[0-9]+&A=[12]&K=[0-9]+&P=1&S=[0-9a-f]+$", "");`,
+			},
+		},
+		{
+			name:             "url sig without keys",
+			algorithm:        util.StrPtr(tc.SigningAlgorithmURLSig),
+			xmlID:            "ds2",
+			expectedImports:  []string{},
+			expectedContains: []string{`return (synth(403));`},
+			expectedWarnings: 1,
+		},
+		{
+			name:             "uri signing",
+			algorithm:        util.StrPtr(tc.SigningAlgorithmURISigning),
+			xmlID:            "ds1",
+			expectedImports:  []string{},
+			expectedContains: []string{`return (synth(403));`},
+			expectedWarnings: 1,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.name, func(t *testing.T) {
+			vclFile := newVCLFile(defaultVCLVersion)
+			dsLines := make(map[string][]string)
+			ds := &atscfg.DeliveryService{}
+			ds.XMLID = tC.xmlID
+			ds.SigningAlgorithm = tC.algorithm
+			warnings := signingToVCL(&vclFile, dsLines, ds, keys)
+			if len(warnings) != tC.expectedWarnings {
+				t.Errorf("expected %d warnings got %v", tC.expectedWarnings, warnings)
+			}
+			if !reflect.DeepEqual(tC.expectedImports, vclFile.imports) {
+				t.Errorf("expected imports %v got %v", tC.expectedImports, vclFile.imports)
+			}
+			txt := strings.Join(dsLines["vcl_recv"], "\n")
+			for _, line := range tC.expectedContains {
+				if !strings.Contains(txt, line) {
+					t.Errorf("expected vcl_recv to contain '%s' got %s", line, txt)
+				}
+			}
+			if len(tC.expectedContains) == 0 && len(dsLines) != 0 {
+				t.Errorf("expected no lines got %v", dsLines)
+			}
+		})
+	}
+}
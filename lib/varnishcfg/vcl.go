@@ -19,7 +19,10 @@ package varnishcfg
  * under the License.
  */
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 const defaultVCLVersion = "4.1"
 
@@ -42,6 +45,16 @@ func newVCLFile(version string) vclFile {
 	}
 }
 
+// addImport adds the vmod to the imports of the VCL file, if it isn't already imported.
+func (v *vclFile) addImport(vmod string) {
+	for _, i := range v.imports {
+		if i == vmod {
+			return
+		}
+	}
+	v.imports = append(v.imports, vmod)
+}
+
 func (v vclFile) String() string {
 	txt := fmt.Sprintf("vcl %s;\n", v.version)
 	for _, i := range v.imports {
@@ -99,3 +112,12 @@ func (b backend) String() string {
 	txt += fmt.Sprintf("\t.port = \"%d\";\n", b.port)
 	return txt
 }
+
+// vclString returns s as a VCL string literal, using the long string syntax
+// if s contains characters a short string can't.
+func vclString(s string) string {
+	if strings.ContainsAny(s, "\"\n") {
+		return `{"` + s + `"}`
+	}
+	return `"` + s + `"`
+}
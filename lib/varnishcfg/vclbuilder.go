@@ -66,6 +66,7 @@ func (vb *VCLBuilder) BuildVCLFile() (string, []string, error) {
 		vb.toData.ServerCapabilities,
 		vb.toData.DSRequiredCapabilities,
 		vb.toData.CacheGroups,
+		vb.toData.CacheGroupLatencies,
 		vb.toData.DeliveryServiceServers,
 		vb.toData.CDN,
 		&atscfg.ParentConfigOpts{},
@@ -87,6 +88,13 @@ func (vb *VCLBuilder) BuildVCLFile() (string, []string, error) {
 
 	dirWarnings, err := vb.configureDirectors(&v, parents)
 	warnings = append(warnings, dirWarnings...)
+	if err != nil {
+		return "", nil, fmt.Errorf("(warnings: %s) %w", strings.Join(warnings, ", "), err)
+	}
+
+	// delivery service features come after directors, so requests have a backend before being rewritten.
+	featureWarnings := vb.configureDeliveryServices(&v, parents)
+	warnings = append(warnings, featureWarnings...)
 
-	return fmt.Sprint(v), warnings, err
+	return fmt.Sprint(v), warnings, nil
 }
@@ -104,9 +104,16 @@ type Cfg struct {
 	ParentHealthL7PollMS      uint64  `json:"parent-health-l7-poll-ms"`
 	ParentHealthServicePollMS uint64  `json:"parent-health-service-poll-ms"`
 
+	// ParentHealthL7 configures the requests made to parents by the parent-l7 health method.
+	ParentHealthL7 ParentHealthL7Cfg `json:"parent-health-l7"`
+
 	// ParentHealthServicePort is the port to serve parent health on. To disable serving parent health, set to 0.
 	ParentHealthServicePort int `json:"parent-health-service-port"`
 
+	// LatencyReportIntervalMS is the interval to report the probe latencies to parent Cache Groups
+	// to Traffic Ops in milliseconds. To disable reporting latencies, set to 0.
+	LatencyReportIntervalMS uint64 `json:"latency-report-interval-ms"`
+
 	// ParentHealthLogLocation may be a file path, stdout, stderr, or null (the empty string is equivalent to null)
 	ParentHealthLogLocation string `json:"parent-health-log-location"`
 
@@ -351,6 +358,8 @@ func LoadConfig(cfg *Cfg) (bool, error) {
 	if err != nil {
 		return false, errors.New(err.Error())
 	}
+	// unmarshalling into the existing probe maps would keep probes removed from the file.
+	cfg.ParentHealthL7 = ParentHealthL7Cfg{}
 	err = json.Unmarshal(content, cfg)
 	if err != nil {
 		return false, fmt.Errorf("config parsing failed: %w", err)
@@ -441,6 +450,9 @@ func LoadConfig(cfg *Cfg) (bool, error) {
 	if cfg.ParentHealthServicePollMS <= 0 {
 		cfg.ParentHealthServicePollMS = ParentHealthServicePollMS
 	}
+	if err := cfg.ParentHealthL7.Init(); err != nil {
+		return false, errors.New("parsing parent-health-l7: " + err.Error())
+	}
 
 	return true, nil
 }
@@ -23,6 +23,7 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/apache/trafficcontrol/v8/tc-health-client/util"
 )
@@ -119,6 +120,56 @@ func TestLoadConfig(t *testing.T) {
 	if expect != strconv.FormatBool(monitorStrategisPeers) {
 		t.Fatalf("expected '%s', got %v\n", expect, monitorStrategisPeers)
 	}
+
+	l7 := cfg.ParentHealthL7
+	if l7.Default.Path != DefaultL7ProbePath || l7.Default.TimeoutMS != DefaultL7ProbeTimeoutMS {
+		t.Fatalf("expected default l7 probe path '%s' timeout %v, got '%s' %v\n", DefaultL7ProbePath, DefaultL7ProbeTimeoutMS, l7.Default.Path, l7.Default.TimeoutMS)
+	}
+	midProbe, ok := l7.Parents["mid-01.foo.com"]
+	if !ok {
+		t.Fatalf("expected l7 probe for parent 'mid-01.foo.com', got none\n")
+	}
+	if midProbe.Path != "/_astats" || midProbe.Scheme != "https" || midProbe.MaxLatency() != 500*time.Millisecond {
+		t.Fatalf("expected parent l7 probe path '/_astats' scheme 'https' max latency 500ms, got %+v\n", midProbe)
+	}
+	if !midProbe.StatusHealthy(204) || midProbe.StatusHealthy(302) {
+		t.Fatalf("expected parent l7 probe to use the default expected status codes, got %+v\n", midProbe.ExpectedStatusCodes)
+	}
+	dsProbe, ok := l7.DeliveryServices["demo1"]
+	if !ok {
+		t.Fatalf("expected l7 probe for delivery service 'demo1', got none\n")
+	}
+	if dsProbe.BodyRegexp() == nil || !dsProbe.BodyRegexp().MatchString("OK\n") {
+		t.Fatalf("expected delivery service l7 probe body regex to match 'OK', got %v\n", dsProbe.BodyRegexp())
+	}
+	if re := dsProbe.HeaderRegexps()["Content-Type"]; re == nil || !re.MatchString("text/plain") {
+		t.Fatalf("expected delivery service l7 probe Content-Type regex to match 'text/plain', got %v\n", re)
+	}
+}
+
+func TestParentHealthL7CfgInit(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cfg    ParentHealthL7Cfg
+		errors bool
+	}{
+		{name: "empty", cfg: ParentHealthL7Cfg{}, errors: false},
+		{name: "invalid scheme", cfg: ParentHealthL7Cfg{Default: L7Probe{Scheme: "ftp"}}, errors: true},
+		{name: "invalid path", cfg: ParentHealthL7Cfg{Parents: map[string]L7Probe{"mid": {Path: "health"}}}, errors: true},
+		{name: "invalid port", cfg: ParentHealthL7Cfg{Parents: map[string]L7Probe{"mid": {Port: 70000}}}, errors: true},
+		{name: "invalid body regex", cfg: ParentHealthL7Cfg{DeliveryServices: map[string]L7Probe{"ds": {BodyRegex: "("}}}, errors: true},
+		{name: "invalid header regex", cfg: ParentHealthL7Cfg{DeliveryServices: map[string]L7Probe{"ds": {HeaderRegexes: map[string]string{"Server": "["}}}}, errors: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Init()
+			if tc.errors && err == nil {
+				t.Errorf("expected error, got nil")
+			} else if !tc.errors && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
 }
 
 func TestGetCredentialsFromFile(t *testing.T) {
@@ -0,0 +1,216 @@
+package config
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	DefaultL7ProbePath      = "/"
+	DefaultL7ProbeTimeoutMS = 2000
+)
+
+// ParentHealthL7Cfg is the configuration of the parent L7 health probes.
+//
+// Every parent is probed with its parent probe if it has one. Otherwise, it's probed with the
+// probe of each delivery service it serves that has one, or the default probe if none do.
+type ParentHealthL7Cfg struct {
+	// Default is the probe used for parents without a parent or delivery service probe.
+	Default L7Probe `json:"default"`
+
+	// Parents are probes for specific parents, keyed on the parent FQDN.
+	Parents map[string]L7Probe `json:"parents"`
+
+	// DeliveryServices are probes for the parents of delivery services, keyed on the delivery service XMLID.
+	// Delivery services are known from strategies.yaml strategy names and parent.config comments.
+	DeliveryServices map[string]L7Probe `json:"delivery-services"`
+}
+
+// L7Probe is an HTTP request to make to a parent, and the response it must return to be healthy.
+//
+// Any field omitted from a parent or delivery service probe is taken from the default probe.
+type L7Probe struct {
+	// Path is the request path, including any query string. Default is '/'.
+	Path string `json:"path"`
+
+	// Host is the Host header to send. Default is the parent FQDN.
+	Host string `json:"host"`
+
+	// Scheme is 'http' or 'https'. Default is the scheme ATS uses for the parent.
+	Scheme string `json:"scheme"`
+
+	// Port is the port to request. Default is the port ATS uses for the parent.
+	Port int `json:"port"`
+
+	// SNI is the TLS server name for https probes. Default is the Host, if set, else the parent FQDN.
+	SNI string `json:"sni"`
+
+	// InsecureSkipVerify is whether to skip verifying the parent's certificate.
+	InsecureSkipVerify bool `json:"insecure-skip-verify"`
+
+	// ExpectedStatusCodes are the healthy response codes. Default is any code below 500.
+	ExpectedStatusCodes []int `json:"expected-status-codes"`
+
+	// BodyRegex is a regular expression the response body must match.
+	BodyRegex string `json:"body-regex"`
+
+	// HeaderRegexes are regular expressions response headers must match, keyed on header name.
+	HeaderRegexes map[string]string `json:"header-regexes"`
+
+	// MaxLatencyMS is the longest the entire response may take to be healthy. Default is no maximum.
+	MaxLatencyMS uint64 `json:"max-latency-ms"`
+
+	// TimeoutMS is the request timeout. Default is 2 seconds.
+	TimeoutMS uint64 `json:"timeout-ms"`
+
+	bodyRegexp     *regexp.Regexp
+	headerRegexps  map[string]*regexp.Regexp
+	expectedStatus map[int]struct{}
+}
+
+// BodyRegexp returns the compiled BodyRegex, or nil if there is none.
+func (pr *L7Probe) BodyRegexp() *regexp.Regexp { return pr.bodyRegexp }
+
+// HeaderRegexps returns the compiled HeaderRegexes.
+func (pr *L7Probe) HeaderRegexps() map[string]*regexp.Regexp { return pr.headerRegexps }
+
+// Timeout returns the request timeout.
+func (pr *L7Probe) Timeout() time.Duration { return time.Duration(pr.TimeoutMS) * time.Millisecond }
+
+// MaxLatency returns the maximum healthy latency, or 0 if there is no maximum.
+func (pr *L7Probe) MaxLatency() time.Duration {
+	return time.Duration(pr.MaxLatencyMS) * time.Millisecond
+}
+
+// StatusHealthy returns whether the response code is healthy.
+func (pr *L7Probe) StatusHealthy(code int) bool {
+	if len(pr.expectedStatus) == 0 {
+		return code < 500
+	}
+	_, ok := pr.expectedStatus[code]
+	return ok
+}
+
+// Init validates the probes, applies the default probe to the parent and delivery service probes,
+// and compiles their regular expressions.
+func (pc *ParentHealthL7Cfg) Init() error {
+	if pc.Default.Path == "" {
+		pc.Default.Path = DefaultL7ProbePath
+	}
+	if pc.Default.TimeoutMS == 0 {
+		pc.Default.TimeoutMS = DefaultL7ProbeTimeoutMS
+	}
+	if err := pc.Default.init(); err != nil {
+		return errors.New("default: " + err.Error())
+	}
+	for fqdn, probe := range pc.Parents {
+		probe = probe.withDefaults(pc.Default)
+		if err := probe.init(); err != nil {
+			return fmt.Errorf("parent '%s': %w", fqdn, err)
+		}
+		pc.Parents[fqdn] = probe
+	}
+	for xmlID, probe := range pc.DeliveryServices {
+		probe = probe.withDefaults(pc.Default)
+		if err := probe.init(); err != nil {
+			return fmt.Errorf("delivery service '%s': %w", xmlID, err)
+		}
+		pc.DeliveryServices[xmlID] = probe
+	}
+	return nil
+}
+
+// withDefaults returns the probe with its omitted fields set from def.
+func (pr L7Probe) withDefaults(def L7Probe) L7Probe {
+	if pr.Path == "" {
+		pr.Path = def.Path
+	}
+	if pr.Host == "" {
+		pr.Host = def.Host
+	}
+	if pr.Scheme == "" {
+		pr.Scheme = def.Scheme
+	}
+	if pr.Port == 0 {
+		pr.Port = def.Port
+	}
+	if pr.SNI == "" {
+		pr.SNI = def.SNI
+	}
+	if !pr.InsecureSkipVerify {
+		pr.InsecureSkipVerify = def.InsecureSkipVerify
+	}
+	if len(pr.ExpectedStatusCodes) == 0 {
+		pr.ExpectedStatusCodes = def.ExpectedStatusCodes
+	}
+	if pr.BodyRegex == "" {
+		pr.BodyRegex = def.BodyRegex
+	}
+	if len(pr.HeaderRegexes) == 0 {
+		pr.HeaderRegexes = def.HeaderRegexes
+	}
+	if pr.MaxLatencyMS == 0 {
+		pr.MaxLatencyMS = def.MaxLatencyMS
+	}
+	if pr.TimeoutMS == 0 {
+		pr.TimeoutMS = def.TimeoutMS
+	}
+	return pr
+}
+
+func (pr *L7Probe) init() error {
+	if pr.Scheme != "" && pr.Scheme != "http" && pr.Scheme != "https" {
+		return errors.New("invalid scheme '" + pr.Scheme + "', valid schemes are 'http' or 'https'")
+	}
+	if pr.Port < 0 || pr.Port > 65535 {
+		return fmt.Errorf("invalid port %d", pr.Port)
+	}
+	if pr.Path != "" && pr.Path[0] != '/' {
+		return errors.New("invalid path '" + pr.Path + "', must start with '/'")
+	}
+
+	pr.expectedStatus = make(map[int]struct{}, len(pr.ExpectedStatusCodes))
+	for _, code := range pr.ExpectedStatusCodes {
+		pr.expectedStatus[code] = struct{}{}
+	}
+
+	pr.bodyRegexp = nil
+	if pr.BodyRegex != "" {
+		re, err := regexp.Compile(pr.BodyRegex)
+		if err != nil {
+			return errors.New("compiling body-regex: " + err.Error())
+		}
+		pr.bodyRegexp = re
+	}
+
+	pr.headerRegexps = make(map[string]*regexp.Regexp, len(pr.HeaderRegexes))
+	for name, reStr := range pr.HeaderRegexes {
+		re, err := regexp.Compile(reStr)
+		if err != nil {
+			return fmt.Errorf("compiling header-regexes '%s': %w", name, err)
+		}
+		pr.headerRegexps[name] = re
+	}
+	return nil
+}
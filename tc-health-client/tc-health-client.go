@@ -120,6 +120,7 @@ func main() {
 	log.Infof("Parent Health L4 Poll MS: %+v\n", cfg.ParentHealthL4PollMS)
 	log.Infof("Parent Health L7 Poll MS: %+v\n", cfg.ParentHealthL7PollMS)
 	log.Infof("Parent Health Sv Poll MS: %+v\n", cfg.ParentHealthServicePollMS)
+	log.Infof("Latency Report Interval MS: %+v\n", cfg.LatencyReportIntervalMS)
 
 	parentHealthL4PollInterval := time.Duration(cfg.ParentHealthL4PollMS) * time.Millisecond
 	parentHealthL7PollInterval := time.Duration(cfg.ParentHealthL7PollMS) * time.Millisecond
@@ -156,6 +157,11 @@ func main() {
 		parentServiceHealthDoneChan = tmagent.StartParentServiceHealthPoller(tmInfo, cfg.NumHealthWorkers, parentHealthServicePollInterval, markdownSvc.UpdateHealth)
 	}
 
+	latencyReporterDoneChan := make(chan<- struct{}, 1)
+	if cfg.LatencyReportIntervalMS > 0 {
+		latencyReporterDoneChan = tmagent.StartLatencyReporter(tmInfo, time.Duration(cfg.LatencyReportIntervalMS)*time.Millisecond)
+	}
+
 	const parentHealthServiceTimeout = time.Second * 30 // TODO make configurable
 
 	parentHealthSvc := &tmagent.ParentHealthServer{}
@@ -170,6 +176,7 @@ func main() {
 		parentHealthL7DoneChan <- struct{}{}
 		parentServiceHealthDoneChan <- struct{}{}
 		tmHealthDoneChan <- struct{}{}
+		latencyReporterDoneChan <- struct{}{}
 		markdownSvc.Shutdown <- struct{}{}
 		if err := tmInfo.ParentHealthLog.Close(); err != nil {
 			log.Errorln("Closing Parent Health Log: " + err.Error())
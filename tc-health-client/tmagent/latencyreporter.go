@@ -0,0 +1,135 @@
+package tmagent
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	toclientv5 "github.com/apache/trafficcontrol/v8/traffic_ops/v5-client"
+)
+
+// StartLatencyReporter starts reporting the latencies of this host's parent health probes
+// to Traffic Ops every reportInterval, averaged by parent Cache Group, which Traffic Ops
+// uses to prefer the lowest latency parent Cache Groups for Delivery Services with a
+// latency preference.
+//
+// Latencies are only measured by the parent-l4 and parent-l7 health methods, so at least
+// one of them must be enabled for anything to be reported.
+func StartLatencyReporter(pi *ParentInfo, reportInterval time.Duration) chan<- struct{} {
+	log.Infoln("Latency Reporter started")
+	doneChan := make(chan struct{})
+	go loopReportLatencies(pi, reportInterval, doneChan)
+	return doneChan
+}
+
+func loopReportLatencies(pi *ParentInfo, reportInterval time.Duration, doneChan <-chan struct{}) {
+	session := (*toclientv5.Session)(nil)
+	for {
+		select {
+		case <-doneChan:
+			return
+		case <-time.After(reportInterval):
+		}
+
+		start := time.Now()
+		if err := reportLatencies(pi, &session); err != nil {
+			log.Errorf("service-status service=latency-reporter event=\"could not report latencies\": %s\n", err.Error())
+		}
+		log.Infof("poll-status poll=latency-reporter ms=%v\n", int(time.Since(start)/time.Millisecond))
+	}
+}
+
+// reportLatencies reports the current parent Cache Group latencies to Traffic Ops.
+// The session is created if it's nil, and set to nil on error, so the next report logs in again.
+func reportLatencies(pi *ParentInfo, session **toclientv5.Session) error {
+	cfg := pi.Cfg.Get()
+	toData := pi.TOData.Get()
+	if toData.CacheGroup == "" {
+		return errors.New("this host '" + cfg.HostName + "' was not found in Traffic Ops, unable to report its Cache Group's latencies")
+	}
+
+	measurements := cacheGroupLatencies(pi.GetParentHistories(), toData.CacheGroups, toData.CacheGroup)
+	if len(measurements) == 0 {
+		log.Debugln("latency-reporter no parent cachegroup latencies to report")
+		return nil
+	}
+
+	if *session == nil {
+		newSession, _, err := toclientv5.LoginWithAgent(cfg.TOUrl, cfg.TOUser, cfg.TOPass, true, toData.UserAgent, false, cfg.TORequestTimeout)
+		if err != nil {
+			return fmt.Errorf("could not establish a TrafficOps session: %w", err)
+		}
+		*session = newSession
+	}
+
+	req := tc.CacheGroupLatenciesRequest{CacheGroup: toData.CacheGroup, Measurements: measurements}
+	if _, _, err := (*session).ReportCacheGroupLatencies(req, toclientv5.NewRequestOptions()); err != nil {
+		*session = nil
+		return errors.New("posting cachegroup latencies: " + err.Error())
+	}
+	log.Debugf("latency-reporter reported latencies for cachegroup '%s': %+v\n", toData.CacheGroup, measurements)
+	return nil
+}
+
+// cacheGroupLatencies returns the mean probe latency of each parent Cache Group, sorted by Cache Group.
+//
+// The latency of each parent is the mean of its recent L4 probes, which measure the network alone;
+// or its recent L7 probes if it has no L4 probes. Parents which aren't Traffic Control caches, and
+// parents in this host's own Cache Group, are skipped.
+func cacheGroupLatencies(hists map[string]ParentHistory, cacheGroups map[string]string, cacheGroup string) []tc.CacheGroupLatencyMeasurement {
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	for fqdn, hist := range hists {
+		parentCacheGroup, ok := cacheGroups[fqdn]
+		if !ok || parentCacheGroup == cacheGroup {
+			continue
+		}
+		latencies := hist.ProbeLatenciesMS[ParentHealthPollTypeL4]
+		if len(latencies) == 0 {
+			latencies = hist.ProbeLatenciesMS[ParentHealthPollTypeL7]
+		}
+		if len(latencies) == 0 {
+			continue
+		}
+		sum := 0.0
+		for _, latency := range latencies {
+			sum += latency
+		}
+		sums[parentCacheGroup] += sum / float64(len(latencies))
+		counts[parentCacheGroup]++
+	}
+
+	measurements := make([]tc.CacheGroupLatencyMeasurement, 0, len(sums))
+	for parentCacheGroup, sum := range sums {
+		measurements = append(measurements, tc.CacheGroupLatencyMeasurement{
+			ParentCacheGroup: parentCacheGroup,
+			LatencyMS:        sum / float64(counts[parentCacheGroup]),
+		})
+	}
+	sort.Slice(measurements, func(i, j int) bool {
+		return measurements[i].ParentCacheGroup < measurements[j].ParentCacheGroup
+	})
+	return measurements
+}
@@ -0,0 +1,65 @@
+package tmagent
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+func TestCacheGroupLatencies(t *testing.T) {
+	hists := map[string]ParentHistory{
+		"mid-01.example.net": {ProbeLatenciesMS: map[ParentHealthPollType][]float64{
+			ParentHealthPollTypeL4: {10, 20},
+			ParentHealthPollTypeL7: {100},
+		}},
+		"mid-02.example.net": {ProbeLatenciesMS: map[ParentHealthPollType][]float64{
+			ParentHealthPollTypeL7: {30},
+		}},
+		"mid-03.example.net": {ProbeLatenciesMS: map[ParentHealthPollType][]float64{
+			ParentHealthPollTypeL4: {5},
+		}},
+		"edge-02.example.net": {ProbeLatenciesMS: map[ParentHealthPollType][]float64{
+			ParentHealthPollTypeL4: {1},
+		}},
+		"origin.example.net": {ProbeLatenciesMS: map[ParentHealthPollType][]float64{
+			ParentHealthPollTypeL4: {50},
+		}},
+		"mid-04.example.net": {},
+	}
+	cacheGroups := map[string]string{
+		"mid-01.example.net":  "mid-east",
+		"mid-02.example.net":  "mid-east",
+		"mid-03.example.net":  "mid-west",
+		"mid-04.example.net":  "mid-north",
+		"edge-02.example.net": "edge-east",
+	}
+
+	expected := []tc.CacheGroupLatencyMeasurement{
+		{ParentCacheGroup: "mid-east", LatencyMS: 22.5},
+		{ParentCacheGroup: "mid-west", LatencyMS: 5},
+	}
+	actual := cacheGroupLatencies(hists, cacheGroups, "edge-east")
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected latencies %+v, actual %+v", expected, actual)
+	}
+}
@@ -145,7 +145,8 @@ func markdownServicePoll(pi *ParentInfo, pollInterval time.Duration, doneChan <-
 }
 
 // decideHealthy is the algorithm for deciding whether a cache is healthy.
-// It takes the cache hostname, and all health results, and returns a single boolean decision.
+// It takes the cache hostname, and all health results, and returns a single boolean decision,
+// and the reasons of each health method that considers the cache unhealthy.
 func decideHealthy(
 	markdownMethods map[config.HealthMethod]struct{},
 	parentFQDN string,
@@ -153,7 +154,7 @@ func decideHealthy(
 	parentHealthL4 *ParentHealth,
 	parentHealthL7 *ParentHealth,
 	parentServiceHealth *ParentServiceHealth,
-) bool {
+) (bool, []string) {
 	// TODO use hostname:port? Parents can be healthy on one port/service but not another.
 	// The ATS markdown command can't mark down per-port as of this writing, but
 	// we can at least calculate it, to log that data, and be able to easily
@@ -206,8 +207,23 @@ func decideHealthy(
 		return bp == nil || *bp
 	}
 
+	reasons := []string{}
+	if !nilOrTrue(tmHealthy) {
+		parentHostName := parseFqdn(parentFQDN)
+		reasons = append(reasons, "tm: "+tmHealth.CacheStatuses[tc.CacheName(parentHostName)].Status)
+	}
+	if !nilOrTrue(l4Healthy) {
+		reasons = append(reasons, "l4: "+l4Health.UnhealthyReason)
+	}
+	if !nilOrTrue(l7Healthy) {
+		reasons = append(reasons, "l7: "+l7Health.UnhealthyReason)
+	}
+	if !nilOrTrue(recursiveHealthy) {
+		reasons = append(reasons, "svc: too many of the parent's parents are unhealthy")
+	}
+
 	// pessimistic: if any health mechanism is unhealthy, consider the host unhealthy
-	return nilOrTrue(tmHealthy) && nilOrTrue(l4Healthy) && nilOrTrue(l7Healthy) && nilOrTrue(recursiveHealthy)
+	return len(reasons) == 0, reasons
 }
 
 // decideRecursiveHealthy is the algorithm for deciding whether a parent is healthy,
@@ -322,13 +338,15 @@ func doMarkdown(pi *ParentInfo) {
 
 	parentFQDNs := getParentFQDNs(pi, tmHealth, parentHealthL4, parentHealthL7, parentServiceHealth)
 	unhealthyNum := 0
-	newCacheHealth := map[string]bool{} // map[fqdn]healthy
+	newCacheHealth := map[string]bool{}               // map[fqdn]healthy
+	newCacheUnhealthyReasons := map[string][]string{} // map[fqdn]reasons
 	for _, fqdn := range parentFQDNs {
-		newAvailable := decideHealthy(pi.MarkdownMethods, fqdn, tmHealth, parentHealthL4, parentHealthL7, parentServiceHealth)
+		newAvailable, reasons := decideHealthy(pi.MarkdownMethods, fqdn, tmHealth, parentHealthL4, parentHealthL7, parentServiceHealth)
 		if !newAvailable {
 			unhealthyNum++
 		}
 		newCacheHealth[fqdn] = newAvailable
+		newCacheUnhealthyReasons[fqdn] = reasons
 	}
 
 	unhealthyParentsExceedSafetyRatio := (float64(unhealthyNum) / float64(len(newCacheHealth))) > HealthSafetyRatio
@@ -367,6 +385,10 @@ func doMarkdown(pi *ParentInfo) {
 				} else {
 					log.Infoln("TM reports that '" + parentStatus.Fqdn + "' is not available so marked DOWN")
 					parentStatus = newParentStatus
+					// markParent doesn't mark until the poll threshold is reached
+					if markedAvailable := parentStatus.available(cfg.ReasonCode); markedAvailable != oldAvailable {
+						pi.recordTransition(fqdn, markedAvailable, newCacheUnhealthyReasons[fqdn])
+					}
 				}
 			}
 		}
@@ -385,6 +407,8 @@ func doMarkdown(pi *ParentInfo) {
 			pi.ParentHostFQDNs.Store(parentHostName, parentStatus.Fqdn)
 		}
 
+		pi.parentHistories.setStatus(fqdn, parentStatus.available(cfg.ReasonCode), newCacheUnhealthyReasons[fqdn])
+
 		// even if the status wasn't updated, we always need to update the poll time on the ParentStatus
 		pi.StoreParentStatus(parentStatus.Fqdn, parentStatus)
 	}
@@ -0,0 +1,165 @@
+package tmagent
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProbeLatencyBuckets are the upper bounds in seconds of the parent probe latency histogram buckets.
+var ProbeLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// metrics are the counters and histograms served in the Prometheus text format by the Parent Health Service.
+// The zero value is ready to use. It is safe for multiple goroutines.
+type metrics struct {
+	mtx            sync.Mutex
+	markdowns      map[string]uint64 // map[fqdn]count
+	markups        map[string]uint64 // map[fqdn]count
+	tmPollFailures uint64
+	probeLatencies map[probeLatencyKey]*histogram
+}
+
+type probeLatencyKey struct {
+	fqdn     string
+	pollType ParentHealthPollType
+}
+
+type histogram struct {
+	bucketCounts []uint64 // cumulative counts, one per ProbeLatencyBuckets
+	count        uint64
+	sum          float64
+}
+
+func (m *metrics) incTransition(fqdn string, available bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if available {
+		if m.markups == nil {
+			m.markups = map[string]uint64{}
+		}
+		m.markups[fqdn]++
+		return
+	}
+	if m.markdowns == nil {
+		m.markdowns = map[string]uint64{}
+	}
+	m.markdowns[fqdn]++
+}
+
+func (m *metrics) incTMPollFailures() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.tmPollFailures++
+}
+
+func (m *metrics) observeProbeLatency(fqdn string, pollType ParentHealthPollType, latency time.Duration) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.probeLatencies == nil {
+		m.probeLatencies = map[probeLatencyKey]*histogram{}
+	}
+	key := probeLatencyKey{fqdn: fqdn, pollType: pollType}
+	hist, ok := m.probeLatencies[key]
+	if !ok {
+		hist = &histogram{bucketCounts: make([]uint64, len(ProbeLatencyBuckets))}
+		m.probeLatencies[key] = hist
+	}
+	seconds := latency.Seconds()
+	for i, upperBound := range ProbeLatencyBuckets {
+		if seconds <= upperBound {
+			hist.bucketCounts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += seconds
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+// Series are sorted, so the output is deterministic.
+func (m *metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	sb := strings.Builder{}
+
+	writeCounterHeader(&sb, "tc_health_client_parent_markdowns_total", "Number of times the parent was marked down.")
+	for _, fqdn := range sortedKeys(m.markdowns) {
+		sb.WriteString(`tc_health_client_parent_markdowns_total{parent="` + escapeLabelValue(fqdn) + `"} ` + strconv.FormatUint(m.markdowns[fqdn], 10) + "\n")
+	}
+
+	writeCounterHeader(&sb, "tc_health_client_parent_markups_total", "Number of times the parent was marked up.")
+	for _, fqdn := range sortedKeys(m.markups) {
+		sb.WriteString(`tc_health_client_parent_markups_total{parent="` + escapeLabelValue(fqdn) + `"} ` + strconv.FormatUint(m.markups[fqdn], 10) + "\n")
+	}
+
+	writeCounterHeader(&sb, "tc_health_client_tm_poll_failures_total", "Number of failed Traffic Monitor health polls.")
+	sb.WriteString("tc_health_client_tm_poll_failures_total " + strconv.FormatUint(m.tmPollFailures, 10) + "\n")
+
+	const latencyName = "tc_health_client_parent_probe_latency_seconds"
+	sb.WriteString("# HELP " + latencyName + " Latency of successful parent health probes.\n")
+	sb.WriteString("# TYPE " + latencyName + " histogram\n")
+	keys := make([]probeLatencyKey, 0, len(m.probeLatencies))
+	for key := range m.probeLatencies {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].fqdn != keys[j].fqdn {
+			return keys[i].fqdn < keys[j].fqdn
+		}
+		return keys[i].pollType < keys[j].pollType
+	})
+	for _, key := range keys {
+		hist := m.probeLatencies[key]
+		labels := `parent="` + escapeLabelValue(key.fqdn) + `",type="` + escapeLabelValue(key.pollType.String()) + `"`
+		for i, upperBound := range ProbeLatencyBuckets {
+			sb.WriteString(latencyName + `_bucket{` + labels + `,le="` + strconv.FormatFloat(upperBound, 'g', -1, 64) + `"} ` + strconv.FormatUint(hist.bucketCounts[i], 10) + "\n")
+		}
+		sb.WriteString(latencyName + `_bucket{` + labels + `,le="+Inf"} ` + strconv.FormatUint(hist.count, 10) + "\n")
+		sb.WriteString(latencyName + `_sum{` + labels + `} ` + strconv.FormatFloat(hist.sum, 'g', -1, 64) + "\n")
+		sb.WriteString(latencyName + `_count{` + labels + `} ` + strconv.FormatUint(hist.count, 10) + "\n")
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+func writeCounterHeader(sb *strings.Builder, name string, help string) {
+	sb.WriteString("# HELP " + name + " " + help + "\n")
+	sb.WriteString("# TYPE " + name + " counter\n")
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escapeLabelValue escapes a Prometheus label value, per the text exposition format.
+func escapeLabelValue(val string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(val)
+}
@@ -23,11 +23,12 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/tc-health-client/config"
 	"github.com/apache/trafficcontrol/v8/tc-health-client/sar"
 	"github.com/apache/trafficcontrol/v8/tc-health-client/util"
 )
@@ -168,7 +169,7 @@ func parentHealthPoll(pi *ParentInfo, pollInterval time.Duration, pollType Paren
 
 func doPollParentHealth(workCh chan func(), pi *ParentInfo, pollInterval time.Duration, pollType ParentHealthPollType) {
 	parentFQDNs := pi.GetParents()
-	newParentHealth := pollParents(workCh, parentFQDNs, pollType)
+	newParentHealth := pollParents(workCh, pi, parentFQDNs, pollType)
 	parentHealthPtr := pi.ParentHealthL4
 	if pollType == ParentHealthPollTypeL7 {
 		parentHealthPtr = pi.ParentHealthL7
@@ -208,21 +209,20 @@ type ParentHealthPollResultAndFQDN struct {
 	ParentFQDN string
 }
 
-func pollParents(workCh chan func(), parentFQDNs []string, pollType ParentHealthPollType) *ParentHealth {
+func pollParents(workCh chan func(), pi *ParentInfo, parentFQDNs []string, pollType ParentHealthPollType) *ParentHealth {
 	if pollType == ParentHealthPollTypeL4 {
-		return pollParentsL4(workCh, parentFQDNs, pollType)
+		return pollParentsL4(workCh, pi, parentFQDNs, pollType)
 	}
 	if pollType == ParentHealthPollTypeL7 {
-		return pollParentsL7(workCh, parentFQDNs, pollType)
+		return pollParentsL7(workCh, pi, parentFQDNs, pollType)
 	}
 	// should never happen, the poll start function should validate the poll type
 	log.Errorf("pollParent got unknown poll type '%v', defaulting to L7!\n", pollType)
-	return pollParentsL7(workCh, parentFQDNs, pollType)
+	return pollParentsL7(workCh, pi, parentFQDNs, pollType)
 }
 
-func pollParentsL7(workCh chan func(), parentFQDNs []string, pollType ParentHealthPollType) *ParentHealth {
-	var timeout = 2 * time.Second // TODO make configurable.
-	const parentPort = 80         // TODO make configurable
+func pollParentsL7(workCh chan func(), pi *ParentInfo, parentFQDNs []string, pollType ParentHealthPollType) *ParentHealth {
+	l7Cfg := &pi.Cfg.Get().ParentHealthL7
 
 	// Note this could be made parallel with a pool of goroutine workers
 	// if it mattered for performance
@@ -236,8 +236,9 @@ func pollParentsL7(workCh chan func(), parentFQDNs []string, pollType ParentHeal
 	// So, we need this writing goroutine to happen while we concurrently read work as it finishes in this thread.
 	go func() {
 		for _, parentFQDN := range parentFQDNs {
+			parentFQDN := parentFQDN
 			workCh <- func() {
-				resultCh <- pollParentL7(parentFQDN, parentPort, timeout)
+				resultCh <- pi.pollParentL7(l7Cfg, parentFQDN)
 			}
 		}
 	}()
@@ -250,65 +251,40 @@ func pollParentsL7(workCh chan func(), parentFQDNs []string, pollType ParentHeal
 	return &ParentHealth{ParentHealthPollResults: results}
 }
 
-func pollParentL7(parentFQDN string, parentPort int, timeout time.Duration) ParentHealthPollResultAndFQDN {
-	// TODO add service port, instead of assuming 80/443
-	// TODO reuse http.Client
-
-	httpClient := http.Client{
-		Timeout: timeout,
-		Transport: &http.Transport{
-			// TODO more granular timeout options?
-			// ConnectTimeout:        ParentPollTimeout,
-			// RequestTimeout:        ParentPollTimeout,
-			ResponseHeaderTimeout: timeout,
-		},
-	}
-
-	req, err := http.NewRequest(http.MethodGet, "http://"+parentFQDN+":"+strconv.Itoa(parentPort), nil)
-	if err != nil {
-		// TODO log?
-		return ParentHealthPollResultAndFQDN{
-			ParentHealthPollResult: ParentHealthPollResult{
-				Healthy:         false,
-				UnhealthyReason: "error making request: " + err.Error(),
-			},
-			ParentFQDN: parentFQDN,
-		}
-	}
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return ParentHealthPollResultAndFQDN{
-			ParentHealthPollResult: ParentHealthPollResult{
-				Healthy:         false,
-				UnhealthyReason: "error requesting: " + err.Error(),
-			},
-			ParentFQDN: parentFQDN,
+// pollParentL7 makes the parent's L7 probes, see getL7ProbeRequests.
+//
+// The parent is healthy if any of its probes succeed. A parent used by many delivery services
+// is only unhealthy if all of their probes fail, so a single broken origin doesn't mark down
+// every parent in front of it.
+func (pi *ParentInfo) pollParentL7(l7Cfg *config.ParentHealthL7Cfg, parentFQDN string) ParentHealthPollResultAndFQDN {
+	svcs, _ := pi.GetParentServices(parentFQDN)
+	reasons := []string{}
+	for _, req := range getL7ProbeRequests(l7Cfg, parentFQDN, svcs) {
+		latency, err := doL7Probe(pi.getL7Client(req), req)
+		if latency > 0 {
+			pi.recordProbeLatency(parentFQDN, ParentHealthPollTypeL7, latency)
 		}
-	}
-	// Immediately close the body, we don't care what was in it.
-	// But we do care that we're able to successfully stream the entire HTTP response.
-	if err := resp.Body.Close(); err != nil {
-		return ParentHealthPollResultAndFQDN{
-			ParentHealthPollResult: ParentHealthPollResult{
-				Healthy:         false,
-				UnhealthyReason: "error reading body: " + err.Error(),
-			},
-			ParentFQDN: parentFQDN,
+		if err == nil {
+			return ParentHealthPollResultAndFQDN{
+				ParentHealthPollResult: ParentHealthPollResult{
+					Healthy: true,
+				},
+				ParentFQDN: parentFQDN,
+			}
 		}
+		reasons = append(reasons, req.name+" probe "+err.Error())
 	}
-
 	return ParentHealthPollResultAndFQDN{
 		ParentHealthPollResult: ParentHealthPollResult{
-			Healthy: true,
+			Healthy:         false,
+			UnhealthyReason: strings.Join(reasons, "; "),
 		},
 		ParentFQDN: parentFQDN,
 	}
 }
 
-func pollParentsL4(workCh chan func(), parentFQDNs []string, pollType ParentHealthPollType) *ParentHealth {
+func pollParentsL4(workCh chan func(), pi *ParentInfo, parentFQDNs []string, pollType ParentHealthPollType) *ParentHealth {
 	timeout := 2 * time.Second // TODO make configurable.
-	parentPort := 80           // TODO make configurable
 
 	// Note this could be made parallel with a pool of goroutine workers
 	// if it mattered for performance
@@ -316,6 +292,11 @@ func pollParentsL4(workCh chan func(), parentFQDNs []string, pollType ParentHeal
 
 	hosts := []sar.HostPort{}
 	for _, parentFQDN := range parentFQDNs {
+		// use the port Traffic Server uses, if known
+		parentPort := 80
+		if svcs, ok := pi.GetParentServices(parentFQDN); ok && len(svcs.Endpoints) > 0 {
+			parentPort = svcs.Endpoints[0].Port
+		}
 		hosts = append(hosts, sar.HostPort{Host: parentFQDN, Port: parentPort})
 	}
 
@@ -340,6 +321,7 @@ func pollParentsL4(workCh chan func(), parentFQDNs []string, pollType ParentHeal
 			}
 			continue
 		}
+		pi.recordProbeLatency(rs.Host, ParentHealthPollTypeL4, rs.RTT)
 		results[rs.Host] = ParentHealthPollResult{
 			Healthy: true,
 		}
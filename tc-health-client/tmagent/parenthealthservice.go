@@ -21,6 +21,7 @@ package tmagent
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
@@ -73,7 +74,51 @@ func (sv *ParentHealthServer) Shutdown(ctx context.Context) error {
 	return sv.httpServer.Shutdown(ctx)
 }
 
+// ParentHealthServicePathParents is the path serving the recent health history of each parent as JSON.
+const ParentHealthServicePathParents = "/parents"
+
+// ParentHealthServicePathMetrics is the path serving metrics in the Prometheus text format.
+const ParentHealthServicePathMetrics = "/metrics"
+
+// prometheusContentType is the Content-Type of the Prometheus text exposition format.
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ServeHTTP serves the parent history on ParentHealthServicePathParents, metrics on
+// ParentHealthServicePathMetrics, and the combined parent health on all other paths,
+// which is what other tc-health-clients poll for parent service health.
 func (sv *ParentHealthServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case ParentHealthServicePathParents:
+		sv.serveParentHistories(w)
+	case ParentHealthServicePathMetrics:
+		sv.serveMetrics(w)
+	default:
+		sv.serveParentHealth(w)
+	}
+}
+
+func (sv *ParentHealthServer) serveParentHistories(w http.ResponseWriter) {
+	bts, err := json.Marshal(sv.parentInfo.GetParentHistories())
+	if err != nil {
+		log.Errorln("serializing parent histories: " + err.Error())
+		const code = http.StatusInternalServerError
+		w.WriteHeader(code)
+		w.Write([]byte(http.StatusText(code)))
+		return
+	}
+	w.Header().Set(rfc.ContentType, rfc.ApplicationJSON)
+	w.Write(bts)
+	w.Write([]byte("\n"))
+}
+
+func (sv *ParentHealthServer) serveMetrics(w http.ResponseWriter) {
+	w.Header().Set(rfc.ContentType, prometheusContentType)
+	if _, err := sv.parentInfo.metrics.WriteTo(w); err != nil {
+		log.Errorln("writing metrics: " + err.Error())
+	}
+}
+
+func (sv *ParentHealthServer) serveParentHealth(w http.ResponseWriter) {
 	// TODO implement io.Reader/+io.WriterTo?
 	// TODO add 1s cache
 
@@ -0,0 +1,146 @@
+package tmagent
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/tc-health-client/config"
+)
+
+func TestParentHistories(t *testing.T) {
+	pi := &ParentInfo{}
+	for i := 1; i <= MaxParentProbeLatencies+2; i++ {
+		pi.recordProbeLatency("mid-01", ParentHealthPollTypeL7, time.Duration(i)*time.Millisecond)
+	}
+	pi.parentHistories.setStatus("mid-01", true, []string{"l7: unexpected status 502"})
+	for i := 0; i < MaxParentTransitions+1; i++ {
+		pi.recordTransition("mid-01", i%2 == 1, []string{"l4: refused"})
+	}
+
+	hists := pi.GetParentHistories()
+	hist, ok := hists["mid-01"]
+	if !ok {
+		t.Fatalf("expected history for mid-01, got %+v", hists)
+	}
+	latencies := hist.ProbeLatenciesMS[ParentHealthPollTypeL7]
+	if len(latencies) != MaxParentProbeLatencies {
+		t.Fatalf("expected %d latencies, got %d", MaxParentProbeLatencies, len(latencies))
+	}
+	if latencies[0] != 3 || latencies[len(latencies)-1] != float64(MaxParentProbeLatencies+2) {
+		t.Errorf("expected the oldest latencies to be dropped, got %v", latencies)
+	}
+	if len(hist.Transitions) != MaxParentTransitions {
+		t.Errorf("expected %d transitions, got %d", MaxParentTransitions, len(hist.Transitions))
+	}
+	if hist.LastTransition == nil || !hist.LastTransition.Equal(hist.Transitions[len(hist.Transitions)-1].Time) {
+		t.Errorf("expected last transition to be the time of the latest transition, got %v", hist.LastTransition)
+	}
+	if hist.Available {
+		t.Errorf("expected the latest transition to be a markdown")
+	}
+
+	// the returned history must be a copy
+	hist.ProbeLatenciesMS[ParentHealthPollTypeL7][0] = -1
+	if pi.GetParentHistories()["mid-01"].ProbeLatenciesMS[ParentHealthPollTypeL7][0] == -1 {
+		t.Errorf("expected GetParentHistories to return a copy")
+	}
+}
+
+func TestMetricsWriteTo(t *testing.T) {
+	m := &metrics{}
+	m.incTransition("mid-01", false)
+	m.incTransition("mid-01", false)
+	m.incTransition("mid-01", true)
+	m.incTMPollFailures()
+	m.observeProbeLatency("mid-01", ParentHealthPollTypeL4, 3*time.Millisecond)
+	m.observeProbeLatency("mid-01", ParentHealthPollTypeL4, 300*time.Millisecond)
+
+	sb := &strings.Builder{}
+	if _, err := m.WriteTo(sb); err != nil {
+		t.Fatalf("writing metrics: %v", err)
+	}
+	out := sb.String()
+
+	expected := []string{
+		"# TYPE tc_health_client_parent_markdowns_total counter\n",
+		`tc_health_client_parent_markdowns_total{parent="mid-01"} 2` + "\n",
+		`tc_health_client_parent_markups_total{parent="mid-01"} 1` + "\n",
+		"tc_health_client_tm_poll_failures_total 1\n",
+		"# TYPE tc_health_client_parent_probe_latency_seconds histogram\n",
+		`tc_health_client_parent_probe_latency_seconds_bucket{parent="mid-01",type="l4",le="0.005"} 1` + "\n",
+		`tc_health_client_parent_probe_latency_seconds_bucket{parent="mid-01",type="l4",le="0.25"} 1` + "\n",
+		`tc_health_client_parent_probe_latency_seconds_bucket{parent="mid-01",type="l4",le="0.5"} 2` + "\n",
+		`tc_health_client_parent_probe_latency_seconds_bucket{parent="mid-01",type="l4",le="+Inf"} 2` + "\n",
+		`tc_health_client_parent_probe_latency_seconds_count{parent="mid-01",type="l4"} 2` + "\n",
+	}
+	for _, ex := range expected {
+		if !strings.Contains(out, ex) {
+			t.Errorf("expected metrics to contain '%s', got:\n%s", ex, out)
+		}
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if actual, expected := escapeLabelValue("a\"b\\c\nd"), `a\"b\\c\nd`; actual != expected {
+		t.Errorf("expected '%s', got '%s'", expected, actual)
+	}
+}
+
+func TestParentHealthServerPaths(t *testing.T) {
+	pi := &ParentInfo{
+		Cfg:                 config.NewCfgPtr(&config.Cfg{}),
+		ParentHealthL4:      NewParentHealthPtr(),
+		ParentHealthL7:      NewParentHealthPtr(),
+		ParentServiceHealth: NewParentServiceHealthPtr(),
+	}
+	pi.recordTransition("mid-01", false, []string{"l4: refused"})
+	sv := NewParentHealthServer(pi, ":0", time.Second)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		sv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("path %s: expected status 200, got %d", path, w.Code)
+		}
+		return w
+	}
+
+	hists := map[string]ParentHistory{}
+	if err := json.Unmarshal(serve(ParentHealthServicePathParents).Body.Bytes(), &hists); err != nil {
+		t.Fatalf("unmarshalling parent histories: %v", err)
+	}
+	if hist := hists["mid-01"]; len(hist.Transitions) != 1 || hist.Transitions[0].Reasons[0] != "l4: refused" {
+		t.Errorf("expected mid-01 markdown with reason, got %+v", hists)
+	}
+
+	if body := serve(ParentHealthServicePathMetrics).Body.String(); !strings.Contains(body, `tc_health_client_parent_markdowns_total{parent="mid-01"} 1`) {
+		t.Errorf("expected markdown metric, got:\n%s", body)
+	}
+
+	if _, err := DeserializeParentServiceHealth(serve("/").Body.Bytes()); err != nil {
+		t.Errorf("expected the root path to serve parent service health, got error: %v", err)
+	}
+}
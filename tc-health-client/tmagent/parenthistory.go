@@ -0,0 +1,154 @@
+package tmagent
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"sync"
+	"time"
+)
+
+// MaxParentProbeLatencies is the number of recent probe latencies kept for each parent and poll type.
+const MaxParentProbeLatencies = 10
+
+// MaxParentTransitions is the number of recent markdowns and markups kept for each parent.
+const MaxParentTransitions = 10
+
+// ParentHistory is the recent health history of a parent, served by the Parent Health Service.
+type ParentHistory struct {
+	// Available is whether the parent is currently marked available in Traffic Server,
+	// as of the last markdown poll.
+	Available bool `json:"available"`
+
+	// MarkdownReasons are the reasons the health methods consider the parent unhealthy.
+	// This will be empty if all health methods consider the parent healthy.
+	// Note the parent may be unhealthy and still available, if the markdown threshold
+	// hasn't been reached, or markdowns are disabled.
+	MarkdownReasons []string `json:"markdown_reasons,omitempty"`
+
+	// LastTransition is the time the parent was last marked down or up.
+	// This will be nil if the parent hasn't been marked since the service started.
+	LastTransition *time.Time `json:"last_transition,omitempty"`
+
+	// Transitions are the most recent markdowns and markups, oldest first.
+	Transitions []ParentTransition `json:"transitions,omitempty"`
+
+	// ProbeLatenciesMS are the most recent probe latencies in milliseconds, oldest first,
+	// keyed on the poll type.
+	ProbeLatenciesMS map[ParentHealthPollType][]float64 `json:"probe_latencies_ms,omitempty"`
+}
+
+// ParentTransition is a parent being marked down or up.
+type ParentTransition struct {
+	Time      time.Time `json:"time"`
+	Available bool      `json:"available"`
+	Reasons   []string  `json:"reasons,omitempty"`
+}
+
+// parentHistories is the ParentHistory of all parents.
+// The zero value is ready to use. It is safe for multiple goroutines.
+type parentHistories struct {
+	mtx     sync.Mutex
+	parents map[string]*ParentHistory
+}
+
+// getOrCreate returns the parent's history, creating it if it doesn't exist.
+// The mutex must be held by the caller.
+func (ph *parentHistories) getOrCreate(fqdn string) *ParentHistory {
+	if ph.parents == nil {
+		ph.parents = map[string]*ParentHistory{}
+	}
+	hist, ok := ph.parents[fqdn]
+	if !ok {
+		hist = &ParentHistory{ProbeLatenciesMS: map[ParentHealthPollType][]float64{}}
+		ph.parents[fqdn] = hist
+	}
+	return hist
+}
+
+// addProbeLatency records a probe latency, dropping the oldest if there are more than MaxParentProbeLatencies.
+func (ph *parentHistories) addProbeLatency(fqdn string, pollType ParentHealthPollType, latency time.Duration) {
+	ph.mtx.Lock()
+	defer ph.mtx.Unlock()
+	hist := ph.getOrCreate(fqdn)
+	latencies := append(hist.ProbeLatenciesMS[pollType], float64(latency)/float64(time.Millisecond))
+	if len(latencies) > MaxParentProbeLatencies {
+		latencies = latencies[len(latencies)-MaxParentProbeLatencies:]
+	}
+	hist.ProbeLatenciesMS[pollType] = latencies
+}
+
+// setStatus sets the parent's current availability and markdown reasons.
+func (ph *parentHistories) setStatus(fqdn string, available bool, reasons []string) {
+	ph.mtx.Lock()
+	defer ph.mtx.Unlock()
+	hist := ph.getOrCreate(fqdn)
+	hist.Available = available
+	hist.MarkdownReasons = reasons
+}
+
+// addTransition records the parent being marked down or up,
+// dropping the oldest if there are more than MaxParentTransitions.
+func (ph *parentHistories) addTransition(fqdn string, tm time.Time, available bool, reasons []string) {
+	ph.mtx.Lock()
+	defer ph.mtx.Unlock()
+	hist := ph.getOrCreate(fqdn)
+	hist.Available = available
+	hist.LastTransition = &tm
+	hist.Transitions = append(hist.Transitions, ParentTransition{Time: tm, Available: available, Reasons: reasons})
+	if len(hist.Transitions) > MaxParentTransitions {
+		hist.Transitions = hist.Transitions[len(hist.Transitions)-MaxParentTransitions:]
+	}
+}
+
+// get returns a copy of the history of all parents.
+func (ph *parentHistories) get() map[string]ParentHistory {
+	ph.mtx.Lock()
+	defer ph.mtx.Unlock()
+	hists := make(map[string]ParentHistory, len(ph.parents))
+	for fqdn, hist := range ph.parents {
+		histCopy := *hist
+		histCopy.MarkdownReasons = append([]string(nil), hist.MarkdownReasons...)
+		histCopy.Transitions = append([]ParentTransition(nil), hist.Transitions...)
+		histCopy.ProbeLatenciesMS = make(map[ParentHealthPollType][]float64, len(hist.ProbeLatenciesMS))
+		for pollType, latencies := range hist.ProbeLatenciesMS {
+			histCopy.ProbeLatenciesMS[pollType] = append([]float64(nil), latencies...)
+		}
+		hists[fqdn] = histCopy
+	}
+	return hists
+}
+
+// GetParentHistories returns the recent health history of all parents, keyed on the parent FQDN.
+// It is safe for multiple goroutines.
+func (pi *ParentInfo) GetParentHistories() map[string]ParentHistory {
+	return pi.parentHistories.get()
+}
+
+// recordProbeLatency records the latency of a successful parent health probe in the parent's history and metrics.
+func (pi *ParentInfo) recordProbeLatency(fqdn string, pollType ParentHealthPollType, latency time.Duration) {
+	pi.parentHistories.addProbeLatency(fqdn, pollType, latency)
+	pi.metrics.observeProbeLatency(fqdn, pollType, latency)
+}
+
+// recordTransition records the parent being marked down or up in the parent's history and metrics.
+func (pi *ParentInfo) recordTransition(fqdn string, available bool, reasons []string) {
+	pi.parentHistories.addTransition(fqdn, time.Now(), available, reasons)
+	pi.metrics.incTransition(fqdn, available)
+}
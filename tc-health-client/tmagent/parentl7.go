@@ -0,0 +1,278 @@
+package tmagent
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/tc-health-client/config"
+)
+
+// MaxL7ProbeBodyBytes is the most of a response body an L7 probe will read.
+// Larger bodies are considered unhealthy if the probe has a body-regex.
+const MaxL7ProbeBodyBytes = 1024 * 1024
+
+// ParentServices is how Traffic Server uses a parent, from parent.config and strategies.yaml.
+type ParentServices struct {
+	// Endpoints are the schemes and ports Traffic Server requests from the parent,
+	// in the order they were found.
+	Endpoints []ParentEndpoint
+	// DeliveryServices are the XMLIDs of the delivery services the parent is used for, where known.
+	DeliveryServices []string
+}
+
+// ParentEndpoint is a scheme and port Traffic Server requests from a parent.
+type ParentEndpoint struct {
+	Scheme string
+	Port   int
+}
+
+// GetParentServices returns how Traffic Server uses the parent, and whether that's known.
+// It is safe for multiple goroutines.
+func (pi *ParentInfo) GetParentServices(fqdn string) (ParentServices, bool) {
+	return pi.parentServices.Load(fqdn)
+}
+
+// addParentService adds the endpoint and delivery service, which may be empty if unknown,
+// to the parent's services in the given map, which must not have been published to readers yet.
+func addParentService(services map[string]ParentServices, fqdn string, endpoint ParentEndpoint, ds string) {
+	svcs := services[fqdn]
+	if endpoint.Port > 0 && !containsEndpoint(svcs.Endpoints, endpoint) {
+		svcs.Endpoints = append(svcs.Endpoints, endpoint)
+	}
+	if ds != "" && !containsStr(svcs.DeliveryServices, ds) {
+		svcs.DeliveryServices = append(svcs.DeliveryServices, ds)
+		sort.Strings(svcs.DeliveryServices)
+	}
+	services[fqdn] = svcs
+}
+
+// setParentServices replaces the parent services read from the given config file,
+// and rebuilds the parent services from every file, so parents and ports removed
+// from a file are no longer used.
+//
+// ParentServices are replaced, never modified, so they're safe for concurrent readers.
+// But this must only be called by a single writer.
+func (pi *ParentInfo) setParentServices(file string, services map[string]ParentServices) {
+	if pi.parentServicesByFile == nil {
+		pi.parentServicesByFile = map[string]map[string]ParentServices{}
+	}
+	pi.parentServicesByFile[file] = services
+
+	files := make([]string, 0, len(pi.parentServicesByFile))
+	for file := range pi.parentServicesByFile {
+		files = append(files, file)
+	}
+	sort.Strings(files) // so each parent's endpoints are always in the same order
+	merged := map[string]ParentServices{}
+	for _, file := range files {
+		for fqdn, svcs := range pi.parentServicesByFile[file] {
+			for _, endpoint := range svcs.Endpoints {
+				addParentService(merged, fqdn, endpoint, "")
+			}
+			for _, ds := range svcs.DeliveryServices {
+				addParentService(merged, fqdn, ParentEndpoint{}, ds)
+			}
+		}
+	}
+
+	for fqdn, svcs := range merged {
+		pi.parentServices.Store(fqdn, svcs)
+	}
+	pi.parentServices.Range(func(fqdn string, _ ParentServices) bool {
+		if _, ok := merged[fqdn]; !ok {
+			pi.parentServices.Delete(fqdn)
+		}
+		return true
+	})
+}
+
+func containsEndpoint(endpoints []ParentEndpoint, endpoint ParentEndpoint) bool {
+	for _, ep := range endpoints {
+		if ep == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+func containsStr(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// l7ProbeRequest is an L7 probe resolved for a specific parent.
+type l7ProbeRequest struct {
+	// name identifies the probe in unhealthy reasons, e.g. "ds 'demo1'".
+	name  string
+	url   string
+	host  string
+	sni   string
+	probe *config.L7Probe
+}
+
+// getL7ProbeRequests returns the probes to make to the parent.
+// A parent probe is used if the parent has one. Otherwise, the probes of the parent's delivery
+// services are used, or the default probe if none of them have one.
+func getL7ProbeRequests(cfg *config.ParentHealthL7Cfg, parentFQDN string, svcs ParentServices) []l7ProbeRequest {
+	if probe, ok := cfg.Parents[parentFQDN]; ok {
+		return []l7ProbeRequest{newL7ProbeRequest("parent", &probe, parentFQDN, svcs.Endpoints)}
+	}
+	reqs := []l7ProbeRequest{}
+	for _, ds := range svcs.DeliveryServices {
+		probe, ok := cfg.DeliveryServices[ds]
+		if !ok {
+			continue
+		}
+		reqs = append(reqs, newL7ProbeRequest("ds '"+ds+"'", &probe, parentFQDN, svcs.Endpoints))
+	}
+	if len(reqs) == 0 {
+		probe := cfg.Default
+		reqs = append(reqs, newL7ProbeRequest("default", &probe, parentFQDN, svcs.Endpoints))
+	}
+	return reqs
+}
+
+// newL7ProbeRequest resolves the probe for the parent. The scheme and port the probe doesn't
+// set are taken from the endpoints Traffic Server uses, falling back to http on port 80.
+func newL7ProbeRequest(name string, probe *config.L7Probe, parentFQDN string, endpoints []ParentEndpoint) l7ProbeRequest {
+	scheme := probe.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if len(endpoints) > 0 {
+			scheme = endpoints[0].Scheme
+		}
+	}
+	port := probe.Port
+	if port == 0 {
+		for _, ep := range endpoints {
+			if ep.Scheme == scheme {
+				port = ep.Port
+				break
+			}
+		}
+	}
+	if port == 0 {
+		port = 80
+		if scheme == "https" {
+			port = 443
+		}
+	}
+
+	sni := probe.SNI
+	if sni == "" {
+		sni = probe.Host
+	}
+	if sni == "" {
+		sni = parentFQDN
+	}
+
+	return l7ProbeRequest{
+		name:  name,
+		url:   scheme + "://" + net.JoinHostPort(parentFQDN, strconv.Itoa(port)) + probe.Path,
+		host:  probe.Host,
+		sni:   sni,
+		probe: probe,
+	}
+}
+
+// getL7Client returns the HTTP client for the probe request.
+// Clients are reused across polls, so connections to parents persist.
+func (pi *ParentInfo) getL7Client(req l7ProbeRequest) *http.Client {
+	key := req.sni + "|" + strconv.FormatBool(req.probe.InsecureSkipVerify) + "|" + req.probe.Timeout().String()
+	if client, ok := pi.l7Clients.Load(key); ok {
+		return client
+	}
+	client := &http.Client{
+		Timeout: req.probe.Timeout(),
+		Transport: &http.Transport{
+			ResponseHeaderTimeout: req.probe.Timeout(),
+			TLSClientConfig: &tls.Config{
+				ServerName:         req.sni,
+				InsecureSkipVerify: req.probe.InsecureSkipVerify,
+			},
+		},
+		// the parent's own response is what's being checked, so redirects aren't followed.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	client, _ = pi.l7Clients.LoadOrStore(key, client)
+	return client
+}
+
+// doL7Probe requests the probe and returns nil if the parent is healthy,
+// or an error describing why it isn't.
+// The latency is returned if the parent responded, even if it's unhealthy; otherwise it's 0.
+func doL7Probe(client *http.Client, req l7ProbeRequest) (time.Duration, error) {
+	httpReq, err := http.NewRequest(http.MethodGet, req.url, nil)
+	if err != nil {
+		return 0, errors.New("error making request: " + err.Error())
+	}
+	if req.host != "" {
+		httpReq.Host = req.host
+	}
+
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return 0, errors.New("error requesting: " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	// The body is always read, so we know the entire response can be streamed,
+	// and so the connection can be reused.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxL7ProbeBodyBytes+1))
+	if err != nil {
+		return 0, errors.New("error reading body: " + err.Error())
+	}
+	latency := time.Since(start)
+
+	if !req.probe.StatusHealthy(resp.StatusCode) {
+		return latency, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if maxLatency := req.probe.MaxLatency(); maxLatency > 0 && latency > maxLatency {
+		return latency, fmt.Errorf("latency %dms exceeded %dms", latency.Milliseconds(), maxLatency.Milliseconds())
+	}
+	for name, re := range req.probe.HeaderRegexps() {
+		if val := resp.Header.Get(name); !re.MatchString(val) {
+			return latency, fmt.Errorf("header %s '%s' doesn't match '%s'", name, val, re.String())
+		}
+	}
+	if re := req.probe.BodyRegexp(); re != nil {
+		if len(body) > MaxL7ProbeBodyBytes {
+			return latency, fmt.Errorf("body larger than %d bytes", MaxL7ProbeBodyBytes)
+		}
+		if !re.Match(body) {
+			return latency, fmt.Errorf("body doesn't match '%s'", re.String())
+		}
+	}
+	return latency, nil
+}
@@ -0,0 +1,199 @@
+package tmagent
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/tc-health-client/config"
+	"github.com/apache/trafficcontrol/v8/tc-health-client/util"
+)
+
+// loadL7Cfg loads the parent-health-l7 config the same way the config file is loaded,
+// so defaults are applied and regexes are compiled.
+func loadL7Cfg(t *testing.T, cfgJSON string) *config.ParentHealthL7Cfg {
+	t.Helper()
+	cfg := &config.Cfg{}
+	if err := json.Unmarshal([]byte(`{"parent-health-l7":`+cfgJSON+`}`), cfg); err != nil {
+		t.Fatalf("unmarshalling config: %v", err)
+	}
+	if err := cfg.ParentHealthL7.Init(); err != nil {
+		t.Fatalf("initializing config: %v", err)
+	}
+	return &cfg.ParentHealthL7
+}
+
+func TestGetL7ProbeRequests(t *testing.T) {
+	l7Cfg := loadL7Cfg(t, `{
+		"default": {"path": "/default"},
+		"parents": {"mid-01": {"path": "/parent", "scheme": "https"}},
+		"delivery-services": {
+			"demo1": {"path": "/demo1", "host": "origin.demo1.test"},
+			"demo2": {"path": "/demo2", "port": 8443, "scheme": "https", "sni": "sni.demo2.test"}
+		}
+	}`)
+	endpoints := []ParentEndpoint{{Scheme: "http", Port: 8080}, {Scheme: "https", Port: 4443}}
+
+	type expectedReq struct{ name, url, host, sni string }
+	testCases := []struct {
+		name     string
+		parent   string
+		svcs     ParentServices
+		expected []expectedReq
+	}{
+		{
+			name:     "parent probe takes precedence",
+			parent:   "mid-01",
+			svcs:     ParentServices{Endpoints: endpoints, DeliveryServices: []string{"demo1"}},
+			expected: []expectedReq{{"parent", "https://mid-01:4443/parent", "", "mid-01"}},
+		},
+		{
+			name:   "delivery service probes",
+			parent: "mid-02",
+			svcs:   ParentServices{Endpoints: endpoints, DeliveryServices: []string{"demo1", "demo2", "demo3"}},
+			expected: []expectedReq{
+				{"ds 'demo1'", "http://mid-02:8080/demo1", "origin.demo1.test", "origin.demo1.test"},
+				{"ds 'demo2'", "https://mid-02:8443/demo2", "", "sni.demo2.test"},
+			},
+		},
+		{
+			name:     "default probe",
+			parent:   "mid-03",
+			svcs:     ParentServices{DeliveryServices: []string{"demo3"}},
+			expected: []expectedReq{{"default", "http://mid-03:80/default", "", "mid-03"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reqs := getL7ProbeRequests(l7Cfg, tc.parent, tc.svcs)
+			if len(reqs) != len(tc.expected) {
+				t.Fatalf("expected %d requests, got %d: %+v", len(tc.expected), len(reqs), reqs)
+			}
+			for i, req := range reqs {
+				actual := expectedReq{req.name, req.url, req.host, req.sni}
+				if actual != tc.expected[i] {
+					t.Errorf("expected request %+v, got %+v", tc.expected[i], actual)
+				}
+			}
+		})
+	}
+}
+
+func TestPollParentL7(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("OK"))
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte("OK"))
+		case "/host":
+			w.Write([]byte(r.Host))
+		case "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("parsing test server url: %v", err)
+	}
+	port, err := strconv.Atoi(srvURL.Port())
+	if err != nil {
+		t.Fatalf("parsing test server port: %v", err)
+	}
+	parent := srvURL.Hostname()
+
+	testCases := []struct {
+		name          string
+		cfgJSON       string
+		healthy       bool
+		reasonContain string
+	}{
+		{name: "5xx is unhealthy", cfgJSON: `{"default": {"path": "/fail"}}`, healthy: false, reasonContain: "unexpected status 502"},
+		{name: "2xx is healthy", cfgJSON: `{"default": {"path": "/ok"}}`, healthy: true},
+		{name: "expected status codes", cfgJSON: `{"default": {"path": "/redirect", "expected-status-codes": [200]}}`, healthy: false, reasonContain: "unexpected status 302"},
+		{name: "redirects aren't followed", cfgJSON: `{"default": {"path": "/redirect", "expected-status-codes": [302]}}`, healthy: true},
+		{name: "body matches", cfgJSON: `{"default": {"path": "/ok", "body-regex": "^OK$"}}`, healthy: true},
+		{name: "body doesn't match", cfgJSON: `{"default": {"path": "/ok", "body-regex": "^FAIL$"}}`, healthy: false, reasonContain: "body doesn't match"},
+		{name: "header matches", cfgJSON: `{"default": {"path": "/ok", "header-regexes": {"Content-Type": "^text/"}}}`, healthy: true},
+		{name: "header doesn't match", cfgJSON: `{"default": {"path": "/ok", "header-regexes": {"Content-Type": "^application/"}}}`, healthy: false, reasonContain: "header Content-Type"},
+		{name: "host header", cfgJSON: `{"default": {"path": "/host", "host": "origin.test", "body-regex": "^origin.test$"}}`, healthy: true},
+		{name: "latency exceeded", cfgJSON: `{"default": {"path": "/slow", "max-latency-ms": 1}}`, healthy: false, reasonContain: "latency"},
+		{
+			name:    "healthy if any delivery service probe succeeds",
+			cfgJSON: `{"delivery-services": {"demo1": {"path": "/fail"}, "demo2": {"path": "/ok"}}}`,
+			healthy: true,
+		},
+		{
+			name:          "unhealthy if all delivery service probes fail",
+			cfgJSON:       `{"delivery-services": {"demo1": {"path": "/fail"}, "demo2": {"path": "/slow", "max-latency-ms": 1}}}`,
+			healthy:       false,
+			reasonContain: "ds 'demo1' probe unexpected status 502; ds 'demo2' probe latency",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Cfg{ParentHealthL7: *loadL7Cfg(t, tc.cfgJSON)}
+			pi := &ParentInfo{Cfg: config.NewCfgPtr(cfg)}
+			services := map[string]ParentServices{}
+			addParentService(services, parent, ParentEndpoint{Scheme: "http", Port: port}, "demo1")
+			addParentService(services, parent, ParentEndpoint{Scheme: "http", Port: port}, "demo2")
+			pi.setParentServices(ParentsFile, services)
+
+			result := pi.pollParentL7(&pi.Cfg.Get().ParentHealthL7, parent)
+			if result.Healthy != tc.healthy {
+				t.Fatalf("expected healthy %v, got %v reason '%s'", tc.healthy, result.Healthy, result.UnhealthyReason)
+			}
+			if !strings.Contains(result.UnhealthyReason, tc.reasonContain) {
+				t.Errorf("expected reason containing '%s', got '%s'", tc.reasonContain, result.UnhealthyReason)
+			}
+			// every probe gets a response, so every probe latency should be recorded
+			if latencies := pi.GetParentHistories()[parent].ProbeLatenciesMS[ParentHealthPollTypeL7]; len(latencies) == 0 {
+				t.Errorf("expected probe latencies to be recorded")
+			}
+		})
+	}
+}
+
+func TestGetL7ClientReuse(t *testing.T) {
+	l7Cfg := loadL7Cfg(t, `{"parents": {"mid-02": {"insecure-skip-verify": true}}}`)
+	pi := &ParentInfo{Cfg: util.NewAtomicPtr(&config.Cfg{})}
+
+	first := getL7ProbeRequests(l7Cfg, "mid-01", ParentServices{})[0]
+	if pi.getL7Client(first) != pi.getL7Client(first) {
+		t.Errorf("expected the same client for the same probe")
+	}
+	second := getL7ProbeRequests(l7Cfg, "mid-02", ParentServices{})[0]
+	if pi.getL7Client(first) == pi.getL7Client(second) {
+		t.Errorf("expected different clients for different parents and TLS settings")
+	}
+}
@@ -29,9 +29,11 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
@@ -92,6 +94,24 @@ type ParentInfo struct {
 	// Don't use this for anything that includes non-cache (origin) servers.
 	// Hostnames for any servers but caches are not unique and cannot be used as keys.
 	ParentHostFQDNs util.SyncMap[string, string]
+
+	// parentServices maps parent FQDNs to how Traffic Server uses them, from parent.config and strategies.yaml.
+	parentServices util.SyncMap[string, ParentServices]
+
+	// parentServicesByFile are the parent services read from each of parent.config and strategies.yaml,
+	// which are merged into parentServices. Only accessed by the single goroutine reading the files.
+	parentServicesByFile map[string]map[string]ParentServices
+
+	// l7Clients are the HTTP clients used by parent L7 health polls, keyed on their TLS and timeout
+	// settings. They're reused across polls, so connections to parents persist.
+	l7Clients util.SyncMap[string, *http.Client]
+
+	// parentHistories are the recent markdowns, markdown reasons, and probe latencies of parents,
+	// served by the Parent Health Service.
+	parentHistories parentHistories
+
+	// metrics are the markdown, probe, and poll metrics served by the Parent Health Service.
+	metrics metrics
 }
 
 // TOData is the Traffic Ops data needed by various services.
@@ -100,6 +120,12 @@ type TOData struct {
 	TOClient  *toclient.Session
 	Monitors  map[string]struct{} `json:"trafficmonitors,omitempty"` // set[fqdn]
 	Caches    map[string]struct{} `json:"caches,omitempty"`          // set[fqdn]
+
+	// CacheGroups maps cache FQDNs to their Cache Group, for reporting latencies to parent Cache Groups.
+	CacheGroups map[string]string `json:"cachegroups,omitempty"`
+
+	// CacheGroup is the Cache Group of this host, or empty if Traffic Ops doesn't have this host.
+	CacheGroup string `json:"cachegroup,omitempty"`
 }
 
 // Clone copies the TOData into a new object.
@@ -112,15 +138,20 @@ func (td *TOData) Clone() *TOData {
 	for fqdn, _ := range td.Caches {
 		newTD.Caches[fqdn] = struct{}{}
 	}
+	for fqdn, cacheGroup := range td.CacheGroups {
+		newTD.CacheGroups[fqdn] = cacheGroup
+	}
+	newTD.CacheGroup = td.CacheGroup
 	newTD.TOClient = td.TOClient
 	return newTD
 }
 
 func NewTOData(userAgent string) *TOData {
 	return &TOData{
-		UserAgent: userAgent,
-		Monitors:  map[string]struct{}{},
-		Caches:    map[string]struct{}{},
+		UserAgent:   userAgent,
+		Monitors:    map[string]struct{}{},
+		Caches:      map[string]struct{}{},
+		CacheGroups: map[string]string{},
 	}
 }
 
@@ -265,6 +296,7 @@ type Strategy struct {
 	CachePeerResult bool     `yaml:"cache_peer_result,omitempty"`
 	Scheme          string   `yaml:"scheme"`
 	FailOvers       FailOver `yaml:"failover,omitempty"`
+	Groups          [][]Host `yaml:"groups,omitempty"`
 }
 
 // the top level array defintions in a trafficserver 'strategies.yaml'
@@ -478,6 +510,9 @@ func (pi *ParentInfo) readParentConfig() error {
 	}
 	pi.ParentDotConfig.LastModifyTime = finfo.ModTime().UnixNano()
 
+	services := map[string]ParentServices{}
+	// ds is the delivery service of the next parent line, from the comment t3c writes before it.
+	ds := ""
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
 		sbytes := scanner.Bytes()
@@ -486,8 +521,11 @@ func (pi *ParentInfo) readParentConfig() error {
 			continue // skip blank lines
 		}
 		if sbytes[0] == 35 { // skip comment lines, 35 is a '#'.
+			ds = parseParentCommentDS(string(sbytes))
 			continue
 		}
+		lineDS := ds
+		ds = ""
 		// search for the parent list.
 		if i := strings.Index(string(sbytes), "parent="); i > 0 {
 			var plist []string
@@ -505,6 +543,11 @@ func (pi *ParentInfo) readParentConfig() error {
 					if len(parent) == 2 {
 						fqdn := parent[0]
 
+						// parent.config parents are host:port|weight
+						if port, err := strconv.Atoi(strings.Split(parent[1], "|")[0]); err == nil {
+							addParentService(services, strings.TrimSpace(fqdn), ParentEndpoint{Scheme: "http", Port: port}, lineDS)
+						}
+
 						{
 							parentHostName := parseFqdn(fqdn)
 							pi.ParentHostFQDNs.Store(parentHostName, fqdn)
@@ -529,9 +572,27 @@ func (pi *ParentInfo) readParentConfig() error {
 			}
 		}
 	}
+	pi.setParentServices(ParentsFile, services)
 	return nil
 }
 
+// parseParentCommentDS returns the delivery service of a parent.config comment
+// written by t3c, e.g. "# ds 'demo1' topology 'demo-topology'", or the empty string
+// if the comment isn't one.
+func parseParentCommentDS(comment string) string {
+	const prefix = "ds '"
+	comment = strings.TrimSpace(strings.TrimLeft(comment, "#"))
+	if !strings.HasPrefix(comment, prefix) {
+		return ""
+	}
+	comment = comment[len(prefix):]
+	end := strings.Index(comment, "'")
+	if end < 0 {
+		return ""
+	}
+	return comment[:end]
+}
+
 // load the parent hosts from 'strategies.yaml'.
 func (pi *ParentInfo) readStrategies(monitorPeers bool) error {
 	var includes []string
@@ -642,6 +703,26 @@ func (pi *ParentInfo) readStrategies(monitorPeers bool) error {
 			log.Debugf("added Host '%s' from %s to the parents map\n", fqdn, fn)
 		}
 	}
+
+	services := map[string]ParentServices{}
+	for _, strategy := range strategies.Strategy {
+		// t3c names strategies after their delivery service
+		ds := ""
+		if strings.HasPrefix(strategy.Strategy, "strategy-") {
+			ds = strings.TrimPrefix(strategy.Strategy, "strategy-")
+		}
+		for _, group := range strategy.Groups {
+			for _, host := range group {
+				for _, protocol := range host.Protocols {
+					if strategy.Scheme != "" && protocol.Scheme != strategy.Scheme {
+						continue
+					}
+					addParentService(services, strings.TrimSpace(host.HostName), ParentEndpoint{Scheme: protocol.Scheme, Port: protocol.Port}, ds)
+				}
+			}
+		}
+	}
+	pi.setParentServices(StrategiesFile, services)
 	return nil
 }
 
@@ -676,6 +757,8 @@ func (pi *ParentInfo) GetTOData(cfg *config.Cfg) error {
 
 	toData.Monitors = map[string]struct{}{}
 	toData.Caches = map[string]struct{}{}
+	toData.CacheGroups = map[string]string{}
+	toData.CacheGroup = ""
 	for _, sv := range srvs.Response {
 		log.Debugf("GetTOData server '%v' type '%v'\n", *sv.HostName, sv.Type)
 		if sv.HostName == nil {
@@ -694,6 +777,12 @@ func (pi *ParentInfo) GetTOData(cfg *config.Cfg) error {
 		if tc.CacheType(sv.Type) == tc.CacheTypeEdge || tc.CacheType(sv.Type) == tc.CacheTypeMid {
 			fqdn := *sv.HostName + "." + *sv.DomainName
 			toData.Caches[fqdn] = struct{}{}
+			if sv.Cachegroup != nil {
+				toData.CacheGroups[fqdn] = *sv.Cachegroup
+				if *sv.HostName == cfg.HostName {
+					toData.CacheGroup = *sv.Cachegroup
+				}
+			}
 			continue
 		}
 	}
@@ -21,7 +21,9 @@ package tmagent
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/apache/trafficcontrol/v8/tc-health-client/config"
@@ -69,6 +71,56 @@ func TestReadParentDotConfig(t *testing.T) {
 	if numParents != 8 {
 		t.Fatalf("failed readParentConfig(): expected 8 parents got %d\n", numParents)
 	}
+
+	svcs, ok := pi.GetParentServices("cdn-mid-01.bar.net")
+	if !ok {
+		t.Fatalf("failed readParentConfig(): expected services for 'cdn-mid-01.bar.net', got none\n")
+	}
+	expected := ParentServices{Endpoints: []ParentEndpoint{{Scheme: "http", Port: 80}}}
+	if !reflect.DeepEqual(expected, svcs) {
+		t.Errorf("failed readParentConfig(): expected 'cdn-mid-01.bar.net' services %+v got %+v\n", expected, svcs)
+	}
+
+	svcs, ok = pi.GetParentServices("cdn-mid-05.foo.net")
+	if !ok {
+		t.Fatalf("failed readParentConfig(): expected services for 'cdn-mid-05.foo.net', got none\n")
+	}
+	expected = ParentServices{Endpoints: []ParentEndpoint{{Scheme: "http", Port: 8080}}, DeliveryServices: []string{"demo1"}}
+	if !reflect.DeepEqual(expected, svcs) {
+		t.Errorf("failed readParentConfig(): expected 'cdn-mid-05.foo.net' services %+v got %+v\n", expected, svcs)
+	}
+}
+
+func TestReadParentDotConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	pi := ParentInfo{
+		ParentDotConfig:   util.ConfigFile{Filename: filepath.Join(dir, ParentsFile)},
+		StrategiesDotYaml: util.ConfigFile{Filename: filepath.Join(dir, StrategiesFile)},
+	}
+	write := func(parents string) {
+		line := `dest_domain=foo.com port=80 parent="` + parents + `" round_robin=consistent_hash go_direct=false` + "\n"
+		if err := os.WriteFile(pi.ParentDotConfig.Filename, []byte(line), 0644); err != nil {
+			t.Fatalf("writing %s: %s\n", ParentsFile, err.Error())
+		}
+		if err := pi.readParentConfig(); err != nil {
+			t.Fatalf("failed readParentConfig(): %s\n", err.Error())
+		}
+	}
+
+	write("mid-01.foo.net:80|1.0;mid-02.foo.net:80|1.0;")
+	write("mid-01.foo.net:8080|1.0;mid-03.foo.net:80|1.0;")
+
+	svcs, ok := pi.GetParentServices("mid-01.foo.net")
+	expected := ParentServices{Endpoints: []ParentEndpoint{{Scheme: "http", Port: 8080}}}
+	if !ok || !reflect.DeepEqual(expected, svcs) {
+		t.Errorf("failed readParentConfig(): expected reloaded 'mid-01.foo.net' services %+v got %+v\n", expected, svcs)
+	}
+	if svcs, ok := pi.GetParentServices("mid-02.foo.net"); ok {
+		t.Errorf("failed readParentConfig(): expected no services for removed parent 'mid-02.foo.net', got %+v\n", svcs)
+	}
+	if _, ok := pi.GetParentServices("mid-03.foo.net"); !ok {
+		t.Errorf("failed readParentConfig(): expected services for added parent 'mid-03.foo.net', got none\n")
+	}
 }
 
 func TestReadStrategiesDotYaml(t *testing.T) {
@@ -130,6 +182,15 @@ func TestReadStrategiesDotYaml(t *testing.T) {
 		if numParents != 6 {
 			t.Fatalf("failed readStrategies(): expected 6 parents got %d\n", numParents)
 		}
+
+		svcs, ok := pi.GetParentServices("mid-01.cdn.com")
+		if !ok {
+			t.Fatalf("failed readStrategies(): expected services for 'mid-01.cdn.com', got none\n")
+		}
+		expected := []ParentEndpoint{{Scheme: "http", Port: 80}}
+		if !reflect.DeepEqual(expected, svcs.Endpoints) {
+			t.Errorf("failed readStrategies(): expected 'mid-01.cdn.com' endpoints %+v got %+v\n", expected, svcs.Endpoints)
+		}
 	})
 
 	t.Run("Read Strategies with monitoring peers off", func(t *testing.T) {
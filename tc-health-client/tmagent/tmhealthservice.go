@@ -107,6 +107,7 @@ func doPollAndUpdateCacheStatus(pi *ParentInfo, doTrafficOpsReq bool) {
 	now := time.Now() // get the current poll time
 	if err != nil {
 		log.Errorf("poll-status %v\n", err.Error())
+		pi.metrics.incTMPollFailures()
 		if err := pi.GetTOData(cfg); err != nil {
 			log.Errorf("update event=\"could not update the list of trafficmonitors, keeping the old config\": %v", err.Error())
 		} else {
@@ -126,6 +126,10 @@ func (sm *SyncMap[KT, VT]) Store(key KT, val VT) {
 	sm.m.Store(key, val)
 }
 
+func (sm *SyncMap[KT, VT]) Delete(key KT) {
+	sm.m.Delete(key)
+}
+
 func (sm *SyncMap[KT, VT]) Range(f func(key KT, value VT) bool) {
 	sm.m.Range(func(iKey, iVal interface{}) bool {
 		key := iKey.(KT)
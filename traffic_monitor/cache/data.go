@@ -62,6 +62,9 @@ type AvailableStatus struct {
 	// an unavailable cache as available if the stat whose threshold was
 	// reached isn't available on that poller.
 	UnavailableStat string
+	// UnavailableRules are the names of the health rules which have marked
+	// the cache server unavailable, and haven't yet cleared.
+	UnavailableRules []string
 	// Poller is the name of the poller which set this availability status.
 	Poller string
 }
@@ -0,0 +1,233 @@
+package cache
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// stats_type_nginx is the Stats format for nginx caches. nginx doesn't report
+// system stats, so it is produced by a small service alongside nginx, such as
+// traffic_monitor/tools/nginx-stats, which adds them to the JSON output of the
+// nginx-module-vts "vhost traffic status" module, or the output of the
+// ngx_http_stub_status_module status page.
+//
+// Stats are of the form:
+//   {
+//     "proc.net.dev": "eth0: ...",
+//     "proc.loadavg": "0.30 0.12 0.21 803/863 1421",
+//     "not_available": false,
+//     "inf_speed": 10000,
+//     "vts": {"connections": {"active": 1}, "serverZones": {"fully-qualified-domain-name.example.net": {"outBytes": 123}}},
+//     "stub_status": "Active connections: 1 ..."
+//   }
+// Where `vts` and `stub_status` are both optional. Delivery Service stats are
+// only available from `vts`, from its server zones, which should be one per
+// requested host, i.e. `vhost_traffic_status_filter_by_host on`. The
+// `stub_status` may be either the text of the status page, or an object with
+// the same fields as the `vts` connections.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	jsoniter "github.com/json-iterator/go"
+)
+
+func init() {
+	registerDecoder("nginx", nginxParse, remapStatsPrecompute)
+}
+
+// nginxVTSAllZones is the name of the VTS server zone holding the totals of
+// all server zones.
+const nginxVTSAllZones = "*"
+
+// NginxStats holds nginx cache statistics.
+type NginxStats struct {
+	ProcNetDev   string `json:"proc.net.dev"`
+	ProcLoadAvg  string `json:"proc.loadavg"`
+	NotAvailable bool   `json:"not_available"`
+	InfSpeed     int64  `json:"inf_speed"`
+	// VTS is the JSON output of the nginx-module-vts status page, if any.
+	VTS *NginxVTS `json:"vts"`
+	// StubStatus is the output of the ngx_http_stub_status_module status
+	// page, if any, either as a JSON string of its text or a JSON object of
+	// its connection counts.
+	StubStatus jsoniter.RawMessage `json:"stub_status"`
+}
+
+// NginxVTS holds the parts of the nginx-module-vts JSON output used by
+// Traffic Monitor.
+type NginxVTS struct {
+	Connections NginxConnections              `json:"connections"`
+	ServerZones map[string]NginxVTSServerZone `json:"serverZones"`
+}
+
+// NginxConnections holds the connection and request counts of nginx, as
+// reported by both nginx-module-vts and ngx_http_stub_status_module.
+type NginxConnections struct {
+	Active   uint64 `json:"active"`
+	Reading  uint64 `json:"reading"`
+	Writing  uint64 `json:"writing"`
+	Waiting  uint64 `json:"waiting"`
+	Accepted uint64 `json:"accepted"`
+	Handled  uint64 `json:"handled"`
+	Requests uint64 `json:"requests"`
+}
+
+// NginxVTSServerZone holds the totals of the requests nginx has served for a
+// single nginx-module-vts server zone.
+type NginxVTSServerZone struct {
+	RequestCounter uint64            `json:"requestCounter"`
+	InBytes        uint64            `json:"inBytes"`
+	OutBytes       uint64            `json:"outBytes"`
+	Responses      NginxVTSResponses `json:"responses"`
+}
+
+// NginxVTSResponses holds the response counts of a nginx-module-vts server
+// zone, by status class.
+type NginxVTSResponses struct {
+	Status1xx uint64 `json:"1xx"`
+	Status2xx uint64 `json:"2xx"`
+	Status3xx uint64 `json:"3xx"`
+	Status4xx uint64 `json:"4xx"`
+	Status5xx uint64 `json:"5xx"`
+}
+
+func nginxParse(cacheName string, r io.Reader, _ interface{}) (Statistics, map[string]interface{}, error) {
+	var stats Statistics
+
+	if r == nil {
+		log.Warnf("%s handler got nil reader", cacheName)
+		return stats, nil, errors.New("handler got nil reader")
+	}
+
+	var nginxStats NginxStats
+	json := jsoniter.ConfigFastest
+
+	if err := json.NewDecoder(r).Decode(&nginxStats); err != nil {
+		return stats, nil, fmt.Errorf("failed to decode reader data: %w", err)
+	}
+	stats, err := parseSystemStats(nginxStats.ProcNetDev, nginxStats.ProcLoadAvg, nginxStats.NotAvailable, nginxStats.InfSpeed)
+	if err != nil {
+		return stats, nil, err
+	}
+
+	miscStats := map[string]interface{}{}
+	var conns *NginxConnections
+	if len(nginxStats.StubStatus) > 0 && string(nginxStats.StubStatus) != "null" {
+		stubConns, err := nginxParseStubStatus(nginxStats.StubStatus)
+		if err != nil {
+			return stats, nil, fmt.Errorf("failed to read stub_status: %w", err)
+		}
+		conns = &stubConns
+	}
+	if nginxStats.VTS != nil {
+		conns = &nginxStats.VTS.Connections
+		for zone, zoneStats := range nginxStats.VTS.ServerZones {
+			if zone == nginxVTSAllZones {
+				continue
+			}
+			addRemapStats(miscStats, zone, RemapStats{
+				InBytes:   zoneStats.InBytes,
+				OutBytes:  zoneStats.OutBytes,
+				Status2xx: zoneStats.Responses.Status2xx,
+				Status3xx: zoneStats.Responses.Status3xx,
+				Status4xx: zoneStats.Responses.Status4xx,
+				Status5xx: zoneStats.Responses.Status5xx,
+			})
+		}
+	}
+	if conns != nil {
+		miscStats[clientConnectionsStat] = float64(conns.Active)
+		miscStats["nginx.connections.active"] = float64(conns.Active)
+		miscStats["nginx.connections.reading"] = float64(conns.Reading)
+		miscStats["nginx.connections.writing"] = float64(conns.Writing)
+		miscStats["nginx.connections.waiting"] = float64(conns.Waiting)
+		miscStats["nginx.connections.accepted"] = float64(conns.Accepted)
+		miscStats["nginx.connections.handled"] = float64(conns.Handled)
+		miscStats["nginx.connections.requests"] = float64(conns.Requests)
+	}
+
+	return stats, miscStats, nil
+}
+
+// nginxParseStubStatus parses the given stub_status, which is either a JSON
+// object of the connection counts, or a JSON string of the status page, e.g.
+//
+//	Active connections: 291
+//	server accepts handled requests
+//	 16630948 16630948 31070465
+//	Reading: 6 Writing: 179 Waiting: 106
+func nginxParseStubStatus(stubStatus jsoniter.RawMessage) (NginxConnections, error) {
+	json := jsoniter.ConfigFastest
+	conns := NginxConnections{}
+	if strings.HasPrefix(strings.TrimSpace(string(stubStatus)), "{") {
+		if err := json.Unmarshal(stubStatus, &conns); err != nil {
+			return conns, fmt.Errorf("decoding object: %w", err)
+		}
+		return conns, nil
+	}
+
+	text := ""
+	if err := json.Unmarshal(stubStatus, &text); err != nil {
+		return conns, fmt.Errorf("expected an object or a string: %w", err)
+	}
+
+	fields := strings.Fields(text)
+	labeled := map[string]*uint64{
+		"connections:": &conns.Active,
+		"Reading:":     &conns.Reading,
+		"Writing:":     &conns.Writing,
+		"Waiting:":     &conns.Waiting,
+	}
+	found := 0
+	for i := 0; i < len(fields)-1; i++ {
+		// the counters follow the "server accepts handled requests" header
+		if fields[i] == "requests" {
+			if i+3 >= len(fields) {
+				return conns, errors.New("missing accepts, handled, and requests counts")
+			}
+			for j, val := range []*uint64{&conns.Accepted, &conns.Handled, &conns.Requests} {
+				n, err := strconv.ParseUint(fields[i+1+j], 10, 64)
+				if err != nil {
+					return conns, fmt.Errorf("parsing counts: %w", err)
+				}
+				*val = n
+			}
+			found++
+			continue
+		}
+		val, ok := labeled[fields[i]]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[i+1], 10, 64)
+		if err != nil {
+			return conns, fmt.Errorf("parsing %s: %w", fields[i], err)
+		}
+		*val = n
+		found++
+	}
+	if found != len(labeled)+1 {
+		return conns, fmt.Errorf("expected %d values, found %d", len(labeled)+1, found)
+	}
+	return conns, nil
+}
@@ -0,0 +1,152 @@
+package cache
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+var nginxVTSData = `{
+	"proc.net.dev": "eth0:47907832129 14601260    0    0    0     0          0   790726 728207677726 10210700052    0    0    0     0       0          0",
+	"proc.loadavg": "0.30 0.12 0.21 803/863 1421",
+	"not_available": false,
+	"inf_speed": 10000,
+	"vts": {
+		"hostName": "edge",
+		"nginxVersion": "1.24.0",
+		"connections": {"active": 12, "reading": 1, "writing": 3, "waiting": 8, "accepted": 500, "handled": 500, "requests": 900},
+		"serverZones": {
+			"edge.ds1.example.invalid": {
+				"requestCounter": 18,
+				"inBytes": 10,
+				"outBytes": 20,
+				"responses": {"1xx": 0, "2xx": 3, "3xx": 4, "4xx": 5, "5xx": 6, "miss": 2, "hit": 1}
+			},
+			"localhost": {
+				"requestCounter": 1,
+				"inBytes": 1,
+				"outBytes": 1,
+				"responses": {"1xx": 0, "2xx": 1, "3xx": 0, "4xx": 0, "5xx": 0}
+			},
+			"*": {
+				"requestCounter": 19,
+				"inBytes": 11,
+				"outBytes": 21,
+				"responses": {"1xx": 0, "2xx": 4, "3xx": 4, "4xx": 5, "5xx": 6}
+			}
+		}
+	}
+}
+`
+
+func TestNginxParseVTS(t *testing.T) {
+	systemStats, miscStats, err := nginxParse("test", strings.NewReader(nginxVTSData), nil)
+	if err != nil {
+		t.Fatalf("got error %s", err)
+	}
+	load := Loadavg{One: 0.3, Five: 0.12, Fifteen: 0.21, CurrentProcesses: 803, TotalProcesses: 863, LatestPID: 1421}
+	if systemStats.Loadavg != load {
+		t.Errorf("got %v want %v", systemStats.Loadavg, load)
+	}
+	inf := Interface{Speed: 10000, BytesOut: 728207677726, BytesIn: 47907832129}
+	if systemStats.Interfaces["eth0"] != inf {
+		t.Errorf("got %v want %v", systemStats.Interfaces["eth0"], inf)
+	}
+
+	expected := map[string]float64{
+		clientConnectionsStat:                                    12,
+		"nginx.connections.waiting":                              8,
+		"nginx.connections.requests":                             900,
+		"plugin.remap_stats.edge.ds1.example.invalid.in_bytes":   10,
+		"plugin.remap_stats.edge.ds1.example.invalid.out_bytes":  20,
+		"plugin.remap_stats.edge.ds1.example.invalid.status_2xx": 3,
+	}
+	for name, val := range expected {
+		if actual, ok := miscStats[name].(float64); !ok || actual != val {
+			t.Errorf("expected stat %s to be %v, got %v", name, val, miscStats[name])
+		}
+	}
+	if _, ok := miscStats["plugin.remap_stats.*.out_bytes"]; ok {
+		t.Error("expected the totals server zone not to be a stat")
+	}
+}
+
+func TestNginxParseStubStatus(t *testing.T) {
+	expected := NginxConnections{Active: 291, Reading: 6, Writing: 179, Waiting: 106, Accepted: 16630948, Handled: 16630947, Requests: 31070465}
+	for name, stubStatus := range map[string]string{
+		"text":   `"Active connections: 291 \nserver accepts handled requests\n 16630948 16630947 31070465 \nReading: 6 Writing: 179 Waiting: 106 \n"`,
+		"object": `{"active": 291, "reading": 6, "writing": 179, "waiting": 106, "accepted": 16630948, "handled": 16630947, "requests": 31070465}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := `{
+				"proc.net.dev": "eth0:47907832129 14601260    0    0    0     0          0   790726 728207677726 10210700052    0    0    0     0       0          0",
+				"proc.loadavg": "0.30 0.12 0.21 803/863 1421",
+				"inf_speed": 10000,
+				"stub_status": ` + stubStatus + `
+			}`
+			_, miscStats, err := nginxParse("test", strings.NewReader(data), nil)
+			if err != nil {
+				t.Fatalf("got error %s", err)
+			}
+			if actual := miscStats[clientConnectionsStat]; actual != float64(expected.Active) {
+				t.Errorf("expected %d connections, got %v", expected.Active, actual)
+			}
+			if actual := miscStats["nginx.connections.handled"]; actual != float64(expected.Handled) {
+				t.Errorf("expected %d handled, got %v", expected.Handled, actual)
+			}
+			if actual := miscStats["nginx.connections.waiting"]; actual != float64(expected.Waiting) {
+				t.Errorf("expected %d waiting, got %v", expected.Waiting, actual)
+			}
+		})
+	}
+
+	if _, err := nginxParseStubStatus([]byte(`"Active connections: 291"`)); err == nil {
+		t.Error("expected an error parsing an incomplete stub_status, got nil")
+	}
+}
+
+func TestNginxPrecompute(t *testing.T) {
+	toData := getMockTOData(map[tc.DeliveryServiceName]string{"ds1": "edge.ds1.example.invalid"})
+	stats, miscStats, err := nginxParse("test", strings.NewReader(nginxVTSData), nil)
+	if err != nil {
+		t.Fatalf("got error %s", err)
+	}
+
+	prc := remapStatsPrecompute("test", toData, stats, miscStats)
+	if len(prc.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", prc.Errors)
+	}
+	if prc.OutBytes != 728207677726 {
+		t.Errorf("expected OutBytes 728207677726, got %d", prc.OutBytes)
+	}
+	if prc.MaxKbps != 10000000 {
+		t.Errorf("expected MaxKbps 10000000, got %d", prc.MaxKbps)
+	}
+	if len(prc.DeliveryServiceStats) != 1 {
+		t.Fatalf("expected stats for 1 delivery service, got %v", prc.DeliveryServiceStats)
+	}
+	expected := DSStat{InBytes: 10, OutBytes: 20, Status2xx: 3, Status3xx: 4, Status4xx: 5, Status5xx: 6}
+	if dsStat, ok := prc.DeliveryServiceStats["ds1"]; !ok || *dsStat != expected {
+		t.Errorf("expected ds1 stats %+v, got %+v", expected, prc.DeliveryServiceStats["ds1"])
+	}
+}
@@ -0,0 +1,163 @@
+package cache
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// This file holds what the stats formats of non-ATS caches which report the
+// system stats and per-host request counts themselves, vstats and nginx, have
+// in common.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
+)
+
+// remapStatPrefix is the prefix of the names of the per-host stats added by
+// addRemapStats, named as astats names them.
+const remapStatPrefix = "plugin.remap_stats."
+
+// clientConnectionsStat is the name of the stat holding the number of open
+// client connections, named as ATS names it so the connections of non-ATS
+// caches are reported like those of ATS caches.
+const clientConnectionsStat = "proxy.process.http.current_client_connections"
+
+// RemapStats holds the totals of the requests a cache has served for a single
+// host.
+type RemapStats struct {
+	InBytes   uint64 `json:"in_bytes"`
+	OutBytes  uint64 `json:"out_bytes"`
+	Status2xx uint64 `json:"status_2xx"`
+	Status3xx uint64 `json:"status_3xx"`
+	Status4xx uint64 `json:"status_4xx"`
+	Status5xx uint64 `json:"status_5xx"`
+}
+
+// parseSystemStats returns the Statistics of the given system stats, which
+// non-ATS caches report as the line of the polled interface from
+// /proc/net/dev, the contents of /proc/loadavg, and the interface speed.
+func parseSystemStats(procNetDev string, procLoadAvg string, notAvailable bool, infSpeed int64) (Statistics, error) {
+	var stats Statistics
+	if err := stats.AddInterfaceFromRawLine(procNetDev); err != nil {
+		return stats, fmt.Errorf("failed to add interface data %s: %w", procNetDev, err)
+	}
+
+	loadAvg, err := LoadavgFromRawLine(procLoadAvg)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read average load data %s: %w", procLoadAvg, err)
+	}
+	stats.Loadavg = loadAvg
+
+	stats.NotAvailable = notAvailable
+	for name, inf := range stats.Interfaces {
+		inf.Speed = infSpeed
+		stats.Interfaces[name] = inf
+	}
+	return stats, nil
+}
+
+// addRemapStats adds the given stats of the requests for the given host to
+// miscStats, to be added to the stats of the host's Delivery Service by
+// remapStatsPrecompute.
+func addRemapStats(miscStats map[string]interface{}, host string, remapStats RemapStats) {
+	prefix := remapStatPrefix + host + "."
+	miscStats[prefix+"in_bytes"] = float64(remapStats.InBytes)
+	miscStats[prefix+"out_bytes"] = float64(remapStats.OutBytes)
+	miscStats[prefix+"status_2xx"] = float64(remapStats.Status2xx)
+	miscStats[prefix+"status_3xx"] = float64(remapStats.Status3xx)
+	miscStats[prefix+"status_4xx"] = float64(remapStats.Status4xx)
+	miscStats[prefix+"status_5xx"] = float64(remapStats.Status5xx)
+}
+
+func remapStatsPrecompute(cacheName string, data todata.TOData, stats Statistics, miscStats map[string]interface{}) PrecomputedData {
+	dsStats := make(map[string]*DSStat)
+	var precomputed PrecomputedData
+	for _, iface := range stats.Interfaces {
+		precomputed.OutBytes += iface.BytesOut
+		kbps := iface.Speed * 1000
+		if kbps > precomputed.MaxKbps {
+			precomputed.MaxKbps = kbps
+		}
+	}
+
+	for stat, value := range miscStats {
+		if !strings.HasPrefix(stat, remapStatPrefix) {
+			continue
+		}
+		if err := processRemapStat(dsStats, data, stat, value); err != nil {
+			log.Infof("precomputing cache %s stat %s value %v error %v", cacheName, stat, value, err)
+			precomputed.Errors = append(precomputed.Errors, err)
+		}
+	}
+
+	precomputed.DeliveryServiceStats = dsStats
+	return precomputed
+}
+
+// processRemapStat adds the given per-host stat to the stats of the Delivery
+// Service the host belongs to. Non-ATS caches serve requests for any host, so
+// hosts which aren't a Delivery Service, such as those of health checks, are
+// ignored.
+func processRemapStat(dsStats map[string]*DSStat, toData todata.TOData, stat string, value interface{}) error {
+	statParts := strings.Split(strings.TrimPrefix(stat, remapStatPrefix), ".")
+	if len(statParts) < 4 {
+		return nil
+	}
+
+	// the FQDN is `subsubdomain`.`subdomain`.`domain`, as for astats.
+	subsubdomain := statParts[0]
+	subdomain := statParts[1]
+	domain := strings.Join(statParts[2:len(statParts)-1], ".")
+
+	ds, ok := toData.DeliveryServiceRegexes.DeliveryService(domain, subdomain, subsubdomain)
+	if !ok || ds == "" {
+		return nil
+	}
+
+	v, ok := value.(float64)
+	if !ok {
+		return fmt.Errorf("stat '%s' value expected float64 actual '%v' type %T", stat, value, value)
+	}
+
+	dsStat, ok := dsStats[string(ds)]
+	if !ok {
+		dsStat = new(DSStat)
+		dsStats[string(ds)] = dsStat
+	}
+
+	switch statName := statParts[len(statParts)-1]; statName {
+	case "in_bytes":
+		dsStat.InBytes += uint64(v)
+	case "out_bytes":
+		dsStat.OutBytes += uint64(v)
+	case "status_2xx":
+		dsStat.Status2xx += uint64(v)
+	case "status_3xx":
+		dsStat.Status3xx += uint64(v)
+	case "status_4xx":
+		dsStat.Status4xx += uint64(v)
+	case "status_5xx":
+		dsStat.Status5xx += uint64(v)
+	default:
+		return fmt.Errorf("unknown stat '%s'", statName)
+	}
+	return nil
+}
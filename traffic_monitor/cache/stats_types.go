@@ -3,6 +3,9 @@
 // used format is the “stats_over_http” format provided by the plugin of the
 // same name for Apache Traffic Server, followed closely by “astats”  which
 // is the legacy format used by older versions of Apache Traffic Control.
+// Caches other than Apache Traffic Server are supported by “vstats” for
+// Varnish and “nginx” for nginx, which report the same interface,
+// connection, and Delivery Service stats.
 //
 // # Creating A New Stats Type
 //
@@ -19,27 +19,49 @@ package cache
  * under the License.
  */
 
+// stats_type_vstats is the Stats format for Varnish caches. It is produced by
+// a small service alongside Varnish, such as the one in CDN-in-a-Box's
+// infrastructure/cdn-in-a-box/varnish/vstats.go.
+//
+// Stats are of the form:
+//   {
+//     "proc.net.dev": "eth0: ...",
+//     "proc.loadavg": "0.30 0.12 0.21 803/863 1421",
+//     "not_available": false,
+//     "inf_speed": 10000,
+//     "stats": {"counters": {"MAIN.sess_conn": {"value": 123}}},
+//     "remap_stats": {"fully-qualified-domain-name.example.net": {"status_2xx": 123}}
+//   }
+// Where `stats` is the output of `varnishstat -j`, and `remap_stats` holds the
+// stats of each requested host, which are one of:
+//   `in_bytes`, `out_bytes`, `status_2xx`, `status_3xx`, `status_4xx`, `status_5xx`
+
 import (
 	"errors"
 	"fmt"
 	"io"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
-	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
 	jsoniter "github.com/json-iterator/go"
 )
 
 func init() {
-	registerDecoder("vstats", vstatsParse, vstatsPrecompute)
+	registerDecoder("vstats", vstatsParse, remapStatsPrecompute)
 }
 
 // Vstats holds Varnish cache statistics
 type Vstats struct {
-	ProcNetDev   string                 `json:"proc.net.dev"`
-	ProcLoadAvg  string                 `json:"proc.loadavg"`
-	NotAvailable bool                   `json:"not_available"`
-	InfSpeed     int64                  `json:"inf_speed"`
-	Stats        map[string]interface{} `json:"stats"`
+	ProcNetDev   string `json:"proc.net.dev"`
+	ProcLoadAvg  string `json:"proc.loadavg"`
+	NotAvailable bool   `json:"not_available"`
+	InfSpeed     int64  `json:"inf_speed"`
+	// Stats is the output of `varnishstat -j`. Varnish 6.5 and later nest
+	// the counters in a "counters" object; older versions have them at the top
+	// level. Counters may also be given directly as numbers.
+	Stats map[string]interface{} `json:"stats"`
+	// RemapStats holds the stats of the requests for each host, keyed by
+	// host.
+	RemapStats map[string]RemapStats `json:"remap_stats"`
 }
 
 func vstatsParse(cacheName string, r io.Reader, _ interface{}) (Statistics, map[string]interface{}, error) {
@@ -56,24 +78,58 @@ func vstatsParse(cacheName string, r io.Reader, _ interface{}) (Statistics, map[
 	if err := json.NewDecoder(r).Decode(&vstats); err != nil {
 		return stats, nil, fmt.Errorf("failed to decode reader data: %w", err)
 	}
-	if err := stats.AddInterfaceFromRawLine(vstats.ProcNetDev); err != nil {
-		return stats, nil, fmt.Errorf("failed to add interface data %s: %w", vstats.ProcNetDev, err)
+	stats, err := parseSystemStats(vstats.ProcNetDev, vstats.ProcLoadAvg, vstats.NotAvailable, vstats.InfSpeed)
+	if err != nil {
+		return stats, nil, err
 	}
 
-	if loadAvg, err := LoadavgFromRawLine(vstats.ProcLoadAvg); err != nil {
-		return stats, nil, fmt.Errorf("failed to read average load data %s: %w", vstats.ProcLoadAvg, err)
-	} else {
-		stats.Loadavg = loadAvg
+	miscStats := vstatsCounters(vstats.Stats)
+	if conns, ok := vstatsConnections(miscStats); ok {
+		miscStats[clientConnectionsStat] = conns
+	}
+	for host, remapStats := range vstats.RemapStats {
+		addRemapStats(miscStats, host, remapStats)
 	}
 
-	stats.NotAvailable = vstats.NotAvailable
-	inf := stats.Interfaces["eth0"]
-	inf.Speed = vstats.InfSpeed
-	stats.Interfaces["eth0"] = inf
+	return stats, miscStats, nil
+}
+
+// vstatsCounters returns the numeric value of each counter in the given
+// `varnishstat -j` output, keyed by the counter name, e.g. "MAIN.sess_conn".
+func vstatsCounters(varnishstat map[string]interface{}) map[string]interface{} {
+	counters := varnishstat
+	if nested, ok := varnishstat["counters"].(map[string]interface{}); ok {
+		counters = nested
+	}
 
-	return stats, vstats.Stats, nil
+	miscStats := make(map[string]interface{}, len(counters))
+	for name, val := range counters {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			if value, ok := v["value"].(float64); ok {
+				miscStats[name] = value
+			}
+		case float64:
+			miscStats[name] = v
+		}
+	}
+	return miscStats
 }
 
-func vstatsPrecompute(cacheName string, data todata.TOData, stats Statistics, miscStats map[string]interface{}) PrecomputedData {
-	return PrecomputedData{DeliveryServiceStats: map[string]*DSStat{}}
+// vstatsConnections returns the number of open client connections, or false
+// if Varnish didn't report its session counters. Varnish doesn't count open
+// sessions directly, so this is the number of sessions accepted less those
+// closed, which is approximate while sessions are being accepted and closed.
+func vstatsConnections(counters map[string]interface{}) (float64, bool) {
+	accepted, ok := counters["MAIN.sess_conn"].(float64)
+	if !ok {
+		return 0, false
+	}
+	closed, _ := counters["MAIN.sess_closed"].(float64)
+	closedErr, _ := counters["MAIN.sess_closed_err"].(float64)
+	conns := accepted - closed - closedErr
+	if conns < 0 {
+		conns = 0
+	}
+	return conns, true
 }
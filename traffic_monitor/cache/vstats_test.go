@@ -22,6 +22,8 @@ package cache
 import (
 	"strings"
 	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
 )
 
 var vstatsData = `{
@@ -39,7 +41,6 @@ func TestVstatsParse(t *testing.T) {
 	if err != nil {
 		t.Errorf("got error %s", err)
 	}
-	// stats not implemented yet
 	if len(statistics) != 0 {
 		t.Errorf("expected statistics to be empty found %v", statistics)
 	}
@@ -58,3 +59,93 @@ func TestVstatsParse(t *testing.T) {
 		t.Errorf("expected NotAvailable to be false")
 	}
 }
+
+var vstatsCountersData = `{
+	"proc.net.dev": "bond0:47907832129 14601260    0    0    0     0          0   790726 728207677726 10210700052    0    0    0     0       0          0",
+	"proc.loadavg": "0.30 0.12 0.21 803/863 1421",
+	"not_available": false,
+	"inf_speed": 70000,
+	"stats": {
+		"version": 1,
+		"timestamp": "2023-06-01T12:00:00",
+		"counters": {
+			"MAIN.sess_conn": {"description": "Sessions accepted", "flag": "c", "format": "i", "value": 100},
+			"MAIN.sess_closed": {"description": "Session Closed", "flag": "c", "format": "i", "value": 80},
+			"MAIN.sess_closed_err": {"description": "Session Closed with error", "flag": "c", "format": "i", "value": 5},
+			"MAIN.cache_hit": {"description": "Cache hits", "flag": "c", "format": "i", "value": 42}
+		}
+	},
+	"remap_stats": {
+		"edge.ds1.example.invalid": {"in_bytes": 10, "out_bytes": 20, "status_2xx": 3, "status_3xx": 4, "status_4xx": 5, "status_5xx": 6}
+	}
+}
+`
+
+func TestVstatsParseCounters(t *testing.T) {
+	systemStats, miscStats, err := vstatsParse("test", strings.NewReader(vstatsCountersData), nil)
+	if err != nil {
+		t.Fatalf("got error %s", err)
+	}
+	if inf, ok := systemStats.Interfaces["bond0"]; !ok || inf.Speed != 70000 {
+		t.Errorf("expected interface bond0 with speed 70000, got %v", systemStats.Interfaces)
+	}
+
+	expected := map[string]float64{
+		"MAIN.sess_conn":      100,
+		"MAIN.cache_hit":      42,
+		clientConnectionsStat: 15,
+		"plugin.remap_stats.edge.ds1.example.invalid.in_bytes":   10,
+		"plugin.remap_stats.edge.ds1.example.invalid.status_5xx": 6,
+	}
+	for name, val := range expected {
+		if actual, ok := miscStats[name].(float64); !ok || actual != val {
+			t.Errorf("expected stat %s to be %v, got %v", name, val, miscStats[name])
+		}
+	}
+	if _, ok := miscStats["version"]; ok {
+		t.Error("expected varnishstat version not to be a stat")
+	}
+}
+
+func TestVstatsCountersLegacy(t *testing.T) {
+	varnishstat := map[string]interface{}{
+		"timestamp":      "2019-06-01T12:00:00",
+		"MAIN.sess_conn": map[string]interface{}{"description": "Sessions accepted", "value": float64(7)},
+		"MAIN.uptime":    float64(60),
+	}
+	counters := vstatsCounters(varnishstat)
+	if len(counters) != 2 || counters["MAIN.sess_conn"] != float64(7) || counters["MAIN.uptime"] != float64(60) {
+		t.Errorf("expected MAIN.sess_conn 7 and MAIN.uptime 60, got %v", counters)
+	}
+	if conns, ok := vstatsConnections(counters); !ok || conns != 7 {
+		t.Errorf("expected 7 connections, got %v %v", conns, ok)
+	}
+}
+
+func TestVstatsPrecompute(t *testing.T) {
+	toData := getMockTOData(map[tc.DeliveryServiceName]string{"ds1": "edge.ds1.example.invalid"})
+	stats, miscStats, err := vstatsParse("test", strings.NewReader(vstatsCountersData), nil)
+	if err != nil {
+		t.Fatalf("got error %s", err)
+	}
+	miscStats["plugin.remap_stats.localhost.status_2xx"] = float64(1)
+	miscStats["plugin.remap_stats.www.not-a-ds.invalid.status_2xx"] = float64(1)
+
+	prc := remapStatsPrecompute("test", toData, stats, miscStats)
+	if len(prc.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", prc.Errors)
+	}
+	if prc.OutBytes != 728207677726 {
+		t.Errorf("expected OutBytes 728207677726, got %d", prc.OutBytes)
+	}
+	if prc.MaxKbps != 70000000 {
+		t.Errorf("expected MaxKbps 70000000, got %d", prc.MaxKbps)
+	}
+	if len(prc.DeliveryServiceStats) != 1 {
+		t.Fatalf("expected stats for 1 delivery service, got %v", prc.DeliveryServiceStats)
+	}
+	expected := DSStat{InBytes: 10, OutBytes: 20, Status2xx: 3, Status3xx: 4, Status4xx: 5, Status5xx: 6}
+	if dsStat, ok := prc.DeliveryServiceStats["ds1"]; !ok || *dsStat != expected {
+		t.Errorf("expected ds1 stats %+v, got %+v", expected, prc.DeliveryServiceStats["ds1"])
+	}
+}
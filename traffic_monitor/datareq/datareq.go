@@ -144,6 +144,9 @@ func MakeDispatchMap(
 		"/api/crconfig-history": wrap(WrapErr(errorCount, func() ([]byte, error) {
 			return srvAPICRConfigHist(toSession)
 		}, rfc.ApplicationJSON)),
+		"/metrics": wrap(WrapBytes(func() []byte {
+			return srvMetrics(opsConfig, toData, statInfoHistory, statResultHistory, healthHistory, lastHealthDurations, localCacheStatus, statMaxKbpses, dsStats, monitorConfig)
+		}, ContentTypeOpenMetrics)),
 	}
 	return addTrailingSlashEndpoints(dispatchMap)
 }
@@ -0,0 +1,320 @@
+package datareq
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/dsdata"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
+)
+
+// ContentTypeOpenMetrics is the Content-Type of the /metrics endpoint.
+const ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+const metricPrefix = "traffic_monitor_"
+
+const (
+	metricTypeGauge   = "gauge"
+	metricTypeCounter = "counter"
+)
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricLabel is a single label of an OpenMetrics sample.
+type metricLabel struct {
+	Name  string
+	Value string
+}
+
+// metricsWriter writes metric families in the OpenMetrics text format.
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+// family writes the metadata of a metric family. It must be followed by all
+// of the samples of the family, because OpenMetrics doesn't allow families to
+// be interleaved.
+func (w *metricsWriter) family(name string, metricType string, help string) {
+	w.buf.WriteString("# TYPE " + metricPrefix + name + " " + metricType + "\n")
+	w.buf.WriteString("# HELP " + metricPrefix + name + " " + help + "\n")
+}
+
+// sample writes a sample of the given family. The name of samples of counters
+// must have the "_total" suffix.
+func (w *metricsWriter) sample(name string, labels []metricLabel, value float64) {
+	w.buf.WriteString(metricPrefix + name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(label.Name + `="` + metricLabelEscaper.Replace(label.Value) + `"`)
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(formatMetricValue(value))
+	w.buf.WriteByte('\n')
+}
+
+// bytes terminates the exposition and returns it.
+func (w *metricsWriter) bytes() []byte {
+	w.buf.WriteString("# EOF\n")
+	return w.buf.Bytes()
+}
+
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// withLabel returns a copy of the given labels with the given label added.
+func withLabel(labels []metricLabel, name string, value string) []metricLabel {
+	l := make([]metricLabel, len(labels), len(labels)+1)
+	copy(l, labels)
+	return append(l, metricLabel{Name: name, Value: value})
+}
+
+func boolMetricValue(b *bool) float64 {
+	if b != nil && *b {
+		return 1
+	}
+	return 0
+}
+
+func srvMetrics(
+	opsConfig threadsafe.OpsConfig,
+	toData todata.TODataThreadsafe,
+	statInfoHistory threadsafe.ResultInfoHistory,
+	statResultHistory threadsafe.ResultStatHistory,
+	healthHistory threadsafe.ResultHistory,
+	lastHealthDurations threadsafe.DurationMap,
+	localCacheStatus threadsafe.CacheAvailableStatus,
+	statMaxKbpses threadsafe.CacheKbpses,
+	dsStats threadsafe.DSStatsReader,
+	monitorConfig threadsafe.TrafficMonitorConfigMap,
+) []byte {
+	cdn := opsConfig.Get().CdnName
+	tod := toData.Get()
+	servers := monitorConfig.Get().TrafficServer
+	statuses := createCacheStatuses(tod.ServerTypes, statInfoHistory.Get(), statResultHistory, healthHistory.Get(), lastHealthDurations.Get(), localCacheStatus, statMaxKbpses, servers)
+
+	dses := make([]tc.DeliveryServiceName, 0, len(tod.DeliveryServiceTypes))
+	for ds := range tod.DeliveryServiceTypes {
+		dses = append(dses, ds)
+	}
+
+	w := &metricsWriter{}
+	writeCacheMetrics(w, cdn, statuses, servers)
+	writeDSMetrics(w, cdn, dses, dsStats.Get())
+	return w.bytes()
+}
+
+// writeCacheMetrics writes the availability, bandwidth and poll times of each
+// cache server.
+func writeCacheMetrics(w *metricsWriter, cdn string, statuses map[string]CacheStatus, servers map[string]tc.TrafficServer) {
+	names := make([]string, 0, len(statuses))
+	for name := range statuses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	labels := make(map[string][]metricLabel, len(names))
+	for _, name := range names {
+		cacheType := ""
+		if t := statuses[name].Type; t != nil {
+			cacheType = *t
+		}
+		labels[name] = []metricLabel{
+			{Name: "cdn", Value: cdn},
+			{Name: "cachegroup", Value: servers[name].CacheGroup},
+			{Name: "cache", Value: name},
+			{Name: "type", Value: cacheType},
+		}
+	}
+
+	gauges := []struct {
+		name  string
+		help  string
+		value func(CacheStatus) float64
+	}{
+		{"cache_available", "Whether the cache server is available to serve traffic.", func(s CacheStatus) float64 { return boolMetricValue(s.CombinedAvailable) }},
+		{"cache_ipv4_available", "Whether the cache server is available over IPv4.", func(s CacheStatus) float64 { return boolMetricValue(s.IPv4Available) }},
+		{"cache_ipv6_available", "Whether the cache server is available over IPv6.", func(s CacheStatus) float64 { return boolMetricValue(s.IPv6Available) }},
+		{"cache_bandwidth_kbps", "The outgoing bandwidth of the cache server, in kilobits per second.", func(s CacheStatus) float64 { return derefFloat(s.BandwidthKbps) }},
+		{"cache_bandwidth_capacity_kbps", "The maximum outgoing bandwidth of the cache server, in kilobits per second.", func(s CacheStatus) float64 { return derefFloat(s.BandwidthCapacityKbps) }},
+		{"cache_connections", "The number of current client connections to the cache server.", func(s CacheStatus) float64 { return intMetricValue(s.ConnectionCount) }},
+		{"cache_load_average", "The one minute load average of the cache server.", func(s CacheStatus) float64 { return derefFloat(s.LoadAverage) }},
+		{"cache_health_poll_duration_seconds", "The time taken by the latest successful health poll of the cache server.", func(s CacheStatus) float64 { return msToSeconds(s.HealthTimeMilliseconds) }},
+		{"cache_stat_poll_duration_seconds", "The time taken by the latest successful stat poll of the cache server.", func(s CacheStatus) float64 { return msToSeconds(s.StatTimeMilliseconds) }},
+		{"cache_health_query_duration_seconds", "The time taken to poll and process the latest health result of the cache server.", func(s CacheStatus) float64 { return msToSeconds(s.QueryTimeMilliseconds) }},
+	}
+	for _, gauge := range gauges {
+		w.family(gauge.name, metricTypeGauge, gauge.help)
+		for _, name := range names {
+			w.sample(gauge.name, labels[name], gauge.value(statuses[name]))
+		}
+	}
+
+	w.family("cache_interface_available", metricTypeGauge, "Whether the network interface of the cache server is available.")
+	for _, name := range names {
+		forEachInterface(statuses[name], func(inf string, status CacheInterfaceStatus) {
+			w.sample("cache_interface_available", withLabel(labels[name], "interface", inf), boolMetricValue(&status.Available))
+		})
+	}
+	w.family("cache_interface_bandwidth_kbps", metricTypeGauge, "The outgoing bandwidth of the network interface of the cache server, in kilobits per second.")
+	for _, name := range names {
+		forEachInterface(statuses[name], func(inf string, status CacheInterfaceStatus) {
+			w.sample("cache_interface_bandwidth_kbps", withLabel(labels[name], "interface", inf), status.BandwidthKbps)
+		})
+	}
+}
+
+// forEachInterface calls f with each interface of the given cache status, in
+// order of name.
+func forEachInterface(status CacheStatus, f func(string, CacheInterfaceStatus)) {
+	if status.Interfaces == nil {
+		return
+	}
+	infs := make([]string, 0, len(*status.Interfaces))
+	for inf := range *status.Interfaces {
+		infs = append(infs, inf)
+	}
+	sort.Strings(infs)
+	for _, inf := range infs {
+		f(inf, (*status.Interfaces)[inf])
+	}
+}
+
+// writeDSMetrics writes the availability of each of the given Delivery
+// Services, and their traffic in each Cache Group.
+func writeDSMetrics(w *metricsWriter, cdn string, dses []tc.DeliveryServiceName, stats dsdata.StatsReadonly) {
+	sort.Slice(dses, func(i, j int) bool { return dses[i] < dses[j] })
+
+	type dsStat struct {
+		labels      []metricLabel
+		common      dsdata.StatCommonReadonly
+		cacheGroups []tc.CacheGroupName
+		stat        dsdata.StatReadonly
+	}
+	dsStats := make([]dsStat, 0, len(dses))
+	for _, ds := range dses {
+		stat, ok := stats.Get(ds)
+		if !ok {
+			continue
+		}
+		copied := stat.Copy()
+		cacheGroups := make([]tc.CacheGroupName, 0, len(copied.CacheGroups))
+		for cg := range copied.CacheGroups {
+			cacheGroups = append(cacheGroups, cg)
+		}
+		sort.Slice(cacheGroups, func(i, j int) bool { return cacheGroups[i] < cacheGroups[j] })
+		dsStats = append(dsStats, dsStat{
+			labels:      []metricLabel{{Name: "cdn", Value: cdn}, {Name: "deliveryservice", Value: string(ds)}},
+			common:      stat.Common(),
+			cacheGroups: cacheGroups,
+			stat:        stat,
+		})
+	}
+
+	w.family("deliveryservice_available", metricTypeGauge, "Whether the Delivery Service is available.")
+	for _, ds := range dsStats {
+		available := ds.common.Available().Value
+		w.sample("deliveryservice_available", ds.labels, boolMetricValue(&available))
+	}
+	w.family("deliveryservice_caches_available", metricTypeGauge, "The number of available cache servers assigned to the Delivery Service.")
+	for _, ds := range dsStats {
+		w.sample("deliveryservice_caches_available", ds.labels, float64(ds.common.CachesAvailable().Value))
+	}
+	w.family("deliveryservice_caches_configured", metricTypeGauge, "The number of cache servers assigned to the Delivery Service.")
+	for _, ds := range dsStats {
+		w.sample("deliveryservice_caches_configured", ds.labels, float64(ds.common.CachesConfigured().Value))
+	}
+
+	// forEachCacheGroup calls f with the labels and stats of each Cache Group
+	// of each Delivery Service.
+	forEachCacheGroup := func(f func([]metricLabel, *dsdata.StatCacheStats)) {
+		for _, ds := range dsStats {
+			for _, cg := range ds.cacheGroups {
+				stats, ok := ds.stat.CacheGroup(cg)
+				if !ok || stats == nil {
+					continue
+				}
+				f(withLabel(ds.labels, "cachegroup", string(cg)), stats)
+			}
+		}
+	}
+
+	w.family("deliveryservice_kbps", metricTypeGauge, "The outgoing bandwidth of the Delivery Service in the Cache Group, in kilobits per second.")
+	forEachCacheGroup(func(labels []metricLabel, stats *dsdata.StatCacheStats) {
+		w.sample("deliveryservice_kbps", labels, stats.Kbps.Value)
+	})
+	w.family("deliveryservice_tps", metricTypeGauge, "The responses per second of the Delivery Service in the Cache Group, by status code class.")
+	forEachCacheGroup(func(labels []metricLabel, stats *dsdata.StatCacheStats) {
+		w.sample("deliveryservice_tps", withLabel(labels, "status_class", "2xx"), stats.Tps2xx.Value)
+		w.sample("deliveryservice_tps", withLabel(labels, "status_class", "3xx"), stats.Tps3xx.Value)
+		w.sample("deliveryservice_tps", withLabel(labels, "status_class", "4xx"), stats.Tps4xx.Value)
+		w.sample("deliveryservice_tps", withLabel(labels, "status_class", "5xx"), stats.Tps5xx.Value)
+	})
+	w.family("deliveryservice_responses", metricTypeCounter, "The responses of the Delivery Service in the Cache Group, by status code class.")
+	forEachCacheGroup(func(labels []metricLabel, stats *dsdata.StatCacheStats) {
+		w.sample("deliveryservice_responses_total", withLabel(labels, "status_class", "2xx"), float64(stats.Status2xx.Value))
+		w.sample("deliveryservice_responses_total", withLabel(labels, "status_class", "3xx"), float64(stats.Status3xx.Value))
+		w.sample("deliveryservice_responses_total", withLabel(labels, "status_class", "4xx"), float64(stats.Status4xx.Value))
+		w.sample("deliveryservice_responses_total", withLabel(labels, "status_class", "5xx"), float64(stats.Status5xx.Value))
+	})
+	w.family("deliveryservice_out_bytes", metricTypeCounter, "The bytes sent to clients of the Delivery Service in the Cache Group.")
+	forEachCacheGroup(func(labels []metricLabel, stats *dsdata.StatCacheStats) {
+		w.sample("deliveryservice_out_bytes_total", labels, float64(stats.OutBytes.Value))
+	})
+}
+
+func derefFloat(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
+
+func intMetricValue(i *int64) float64 {
+	if i == nil {
+		return 0
+	}
+	return float64(*i)
+}
+
+func msToSeconds(ms *int64) float64 {
+	return intMetricValue(ms) / 1000
+}
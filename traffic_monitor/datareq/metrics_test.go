@@ -0,0 +1,114 @@
+package datareq
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/dsdata"
+)
+
+func TestMetricsWriter(t *testing.T) {
+	w := &metricsWriter{}
+	w.family("test", metricTypeCounter, "A test.")
+	w.sample("test_total", []metricLabel{{Name: "a", Value: "x\"y\\z\n"}, {Name: "b", Value: "c"}}, 1.5)
+	w.sample("test_total", nil, math.Inf(1))
+	expected := "# TYPE traffic_monitor_test counter\n" +
+		"# HELP traffic_monitor_test A test.\n" +
+		`traffic_monitor_test_total{a="x\"y\\z\n",b="c"} 1.5` + "\n" +
+		"traffic_monitor_test_total +Inf\n" +
+		"# EOF\n"
+	if actual := string(w.bytes()); actual != expected {
+		t.Errorf("expected:\n%s\nactual:\n%s", expected, actual)
+	}
+}
+
+func TestWriteCacheMetrics(t *testing.T) {
+	statuses := map[string]CacheStatus{
+		"edge": {
+			Type:                   util.StrPtr("EDGE"),
+			CombinedAvailable:      util.BoolPtr(true),
+			BandwidthKbps:          util.FloatPtr(1000),
+			HealthTimeMilliseconds: util.Int64Ptr(250),
+			Interfaces: &map[string]CacheInterfaceStatus{
+				"eth1": {Available: false, BandwidthKbps: 0},
+				"eth0": {Available: true, BandwidthKbps: 1000},
+			},
+		},
+	}
+	servers := map[string]tc.TrafficServer{"edge": {CacheGroup: "cg"}}
+
+	w := &metricsWriter{}
+	writeCacheMetrics(w, "cdn", statuses, servers)
+	actual := string(w.bytes())
+
+	labels := `{cdn="cdn",cachegroup="cg",cache="edge",type="EDGE"`
+	for _, expected := range []string{
+		"traffic_monitor_cache_available" + labels + "} 1\n",
+		"traffic_monitor_cache_bandwidth_kbps" + labels + "} 1000\n",
+		"traffic_monitor_cache_health_poll_duration_seconds" + labels + "} 0.25\n",
+		"traffic_monitor_cache_interface_available" + labels + `,interface="eth0"} 1` + "\n" +
+			"traffic_monitor_cache_interface_available" + labels + `,interface="eth1"} 0` + "\n",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", expected, actual)
+		}
+	}
+}
+
+func TestWriteDSMetrics(t *testing.T) {
+	stat := dsdata.NewStat()
+	stat.CommonStats.IsAvailable.Value = true
+	stat.CommonStats.CachesAvailableNum.Value = 2
+	stat.CacheGroups["cg"] = &dsdata.StatCacheStats{
+		Kbps:      dsdata.StatFloat{Value: 12.5},
+		Tps2xx:    dsdata.StatFloat{Value: 3},
+		Status4xx: dsdata.StatInt{Value: 7},
+		OutBytes:  dsdata.StatInt{Value: 1024},
+	}
+	stats := dsdata.NewStats(1)
+	stats.DeliveryService["ds"] = stat
+
+	w := &metricsWriter{}
+	writeDSMetrics(w, "cdn", []tc.DeliveryServiceName{"ds", "missing"}, stats)
+	actual := string(w.bytes())
+
+	labels := `{cdn="cdn",deliveryservice="ds"`
+	for _, expected := range []string{
+		"# TYPE traffic_monitor_deliveryservice_responses counter\n",
+		"traffic_monitor_deliveryservice_available" + labels + "} 1\n",
+		"traffic_monitor_deliveryservice_caches_available" + labels + "} 2\n",
+		"traffic_monitor_deliveryservice_kbps" + labels + `,cachegroup="cg"} 12.5` + "\n",
+		"traffic_monitor_deliveryservice_tps" + labels + `,cachegroup="cg",status_class="2xx"} 3` + "\n",
+		"traffic_monitor_deliveryservice_responses_total" + labels + `,cachegroup="cg",status_class="4xx"} 7` + "\n",
+		"traffic_monitor_deliveryservice_out_bytes_total" + labels + `,cachegroup="cg"} 1024` + "\n",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", expected, actual)
+		}
+	}
+	if strings.Contains(actual, "missing") {
+		t.Errorf("expected no metrics for a Delivery Service without stats, got:\n%s", actual)
+	}
+}
@@ -334,10 +334,21 @@ func CalcAvailability(
 		var aggWhyAvailable string
 		var aggUnavailableStat string
 
+		var resultStats *threadsafe.ResultStatValHistory
 		if statResultsVal != nil {
-			aggIsAvailable, aggWhyAvailable, aggUnavailableStat = EvalAggregate(cache.ToInfo(result), &statResultsVal.Stats, &mc)
-		} else {
-			aggIsAvailable, aggWhyAvailable, aggUnavailableStat = EvalAggregate(cache.ToInfo(result), nil, &mc)
+			resultStats = &statResultsVal.Stats
+		}
+		aggIsAvailable, aggWhyAvailable, aggUnavailableStat = EvalAggregate(cache.ToInfo(result), resultStats, &mc)
+
+		unavailableRules, ruleReasons := EvalRules(resultInfo, resultStats, &mc, lastStatus.UnavailableRules)
+		availStatus.UnavailableRules = unavailableRules
+		if len(ruleReasons) > 0 {
+			if aggIsAvailable {
+				aggIsAvailable = false
+				aggWhyAvailable = eventDesc(tc.CacheStatusFromString(serverInfo.ServerStatus), strings.Join(ruleReasons, "; "))
+			} else {
+				reasons = append(reasons, ruleReasons...)
+			}
 		}
 
 		if result.UsingIPv4 {
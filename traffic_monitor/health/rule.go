@@ -0,0 +1,113 @@
+package health
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/cache"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/healthrule"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
+)
+
+// ruleStats provides the stats of a result to health rules: the stats
+// computed by Traffic Monitor, which only have their newest value, and the
+// polled stat history.
+type ruleStats struct {
+	result      cache.ResultInfo
+	serverInfo  tc.TrafficServer
+	profile     tc.TMProfile
+	resultStats *threadsafe.ResultStatValHistory
+	computed    map[string]cache.StatComputeFunc
+}
+
+// History implements healthrule.Stats.
+func (s ruleStats) History(stat string) []tc.ResultStatVal {
+	if computeF, ok := s.computed[stat]; ok {
+		return []tc.ResultStatVal{{Val: computeF(s.result, s.serverInfo, s.profile, dummyCombinedState), Time: s.result.Time, Span: 1}}
+	}
+	if s.resultStats == nil {
+		return nil
+	}
+	return s.resultStats.Load(stat)
+}
+
+// EvalRules evaluates the health rules of the given cache server's profile.
+// It returns the names of the rules which mark the cache server unavailable,
+// and the reasons why, given the rules which already did. The resultStats may
+// be nil, for pollers which don't poll stats, in which case rules using
+// polled stats keep their previous state.
+//
+// Like thresholds, rules don't apply to ONLINE cache servers, or to cache
+// servers which are already unavailable because of their status.
+func EvalRules(result cache.ResultInfo, resultStats *threadsafe.ResultStatValHistory, mc *tc.TrafficMonitorConfigMap, unavailableRules []string) ([]string, []string) {
+	serverInfo, ok := mc.TrafficServer[result.ID]
+	if !ok {
+		return nil, nil
+	}
+	status := tc.CacheStatusFromString(serverInfo.ServerStatus)
+	if status == tc.CacheStatusOnline || status == tc.CacheStatusAdminDown || status == tc.CacheStatusOffline || status == tc.CacheStatusInvalid {
+		return nil, nil
+	}
+	profile, ok := mc.Profile[serverInfo.Profile]
+	if !ok || len(profile.Parameters.Rules) == 0 {
+		return nil, nil
+	}
+
+	wasUnavailable := make(map[string]struct{}, len(unavailableRules))
+	for _, name := range unavailableRules {
+		wasUnavailable[name] = struct{}{}
+	}
+
+	names := make([]string, 0, len(profile.Parameters.Rules))
+	for name := range profile.Parameters.Rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stats := ruleStats{result: result, serverInfo: serverInfo, profile: profile, resultStats: resultStats, computed: cache.ComputedStats()}
+	newUnavailableRules := []string{}
+	reasons := []string{}
+	for _, name := range names {
+		rule, isNew, err := healthrule.Get(name, profile.Parameters.Rules[name])
+		if err != nil {
+			if isNew {
+				log.Errorf("Profile '%s' health rule '%s' is invalid, ignoring: %v", serverInfo.Profile, name, err)
+			}
+			continue
+		}
+		_, prevUnavailable := wasUnavailable[name]
+		unavailable := prevUnavailable
+		if result.Error == nil {
+			unavailable, err = rule.Eval(stats, prevUnavailable)
+			if err != nil && !errors.Is(err, healthrule.ErrNoData) {
+				log.Warnf("Cache %s profile '%s' health rule could not be evaluated, keeping previous state: %v", result.ID, serverInfo.Profile, err)
+			}
+		}
+		if unavailable {
+			newUnavailableRules = append(newUnavailableRules, name)
+			reasons = append(reasons, "rule "+name+": "+rule.Reason)
+		}
+	}
+	return newUnavailableRules, reasons
+}
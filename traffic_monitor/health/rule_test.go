@@ -0,0 +1,157 @@
+package health
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/cache"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/config"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/peer"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/threadsafe"
+	"github.com/apache/trafficcontrol/v8/traffic_monitor/todata"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+func ruleTestMonitorConfig(status tc.CacheStatus) *tc.TrafficMonitorConfigMap {
+	return &tc.TrafficMonitorConfigMap{
+		TrafficServer: map[string]tc.TrafficServer{
+			"myCacheName": {ServerStatus: string(status), Profile: "myProfileName"},
+		},
+		Profile: map[string]tc.TMProfile{
+			"myProfileName": {
+				Name: "myProfileName",
+				Parameters: tc.TMParameters{
+					Rules: map[string]tc.HealthRule{
+						"errors": {
+							Expr:   "rate(errs) / rate(reqs) > 0.05",
+							Clear:  "rate(errs) / rate(reqs) < 0.01",
+							Reason: "too many errors",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ruleTestStats returns a stat history of 1000 requests and the given number of errors in the last second.
+func ruleTestStats(errs int) *threadsafe.ResultStatValHistory {
+	now := time.Now()
+	stats := threadsafe.NewResultStatValHistory()
+	stats.Store("reqs", []tc.ResultStatVal{{Val: 11000, Time: now, Span: 1}, {Val: 10000, Time: now.Add(-time.Second), Span: 1}})
+	stats.Store("errs", []tc.ResultStatVal{{Val: 100 + errs, Time: now, Span: 1}, {Val: 100, Time: now.Add(-time.Second), Span: 1}})
+	return &stats
+}
+
+func TestEvalRules(t *testing.T) {
+	mc := ruleTestMonitorConfig(tc.CacheStatusReported)
+	result := cache.ResultInfo{ID: "myCacheName", Time: time.Now()}
+
+	unavailable, reasons := EvalRules(result, ruleTestStats(100), mc, nil)
+	if len(unavailable) != 1 || unavailable[0] != "errors" {
+		t.Fatalf("EvalRules over the rule expected rule 'errors' unavailable, actual: %v", unavailable)
+	}
+	if len(reasons) != 1 || reasons[0] != "rule errors: too many errors" {
+		t.Errorf("EvalRules over the rule expected reason 'rule errors: too many errors', actual: %v", reasons)
+	}
+
+	// between the expression and the clear expression, the rule keeps its previous state
+	if unavailable, _ := EvalRules(result, ruleTestStats(30), mc, []string{"errors"}); len(unavailable) != 1 {
+		t.Errorf("EvalRules above the clear expression expected rule to stay unavailable, actual: %v", unavailable)
+	}
+	if unavailable, _ := EvalRules(result, ruleTestStats(30), mc, nil); len(unavailable) != 0 {
+		t.Errorf("EvalRules below the expression expected rule to stay available, actual: %v", unavailable)
+	}
+
+	if unavailable, _ := EvalRules(result, ruleTestStats(5), mc, []string{"errors"}); len(unavailable) != 0 {
+		t.Errorf("EvalRules below the clear expression expected rule to clear, actual: %v", unavailable)
+	}
+
+	// pollers without stats can't evaluate the rule, and must not clear it
+	if unavailable, _ := EvalRules(result, nil, mc, []string{"errors"}); len(unavailable) != 1 {
+		t.Errorf("EvalRules without stats expected rule to stay unavailable, actual: %v", unavailable)
+	}
+
+	if unavailable, _ := EvalRules(result, ruleTestStats(100), ruleTestMonitorConfig(tc.CacheStatusOnline), nil); len(unavailable) != 0 {
+		t.Errorf("EvalRules of an ONLINE cache expected no rules, actual: %v", unavailable)
+	}
+
+	mc.Profile["myProfileName"].Parameters.Rules["invalid"] = tc.HealthRule{Expr: "rate(errs) >"}
+	if unavailable, _ := EvalRules(result, ruleTestStats(100), mc, nil); len(unavailable) != 1 || unavailable[0] != "errors" {
+		t.Errorf("EvalRules with an invalid rule expected it to be ignored, actual: %v", unavailable)
+	}
+}
+
+func TestCalcAvailabilityRules(t *testing.T) {
+	mc := ruleTestMonitorConfig(tc.CacheStatusReported)
+	toData := todata.TOData{
+		ServerTypes:            map[tc.CacheName]tc.CacheType{"myCacheName": tc.CacheTypeEdge},
+		DeliveryServiceServers: map[tc.DeliveryServiceName][]tc.CacheName{},
+		ServerCachegroups:      map[tc.CacheName]tc.CacheGroupName{"myCacheName": "myCG"},
+	}
+	localCacheStatusThreadsafe := threadsafe.NewCacheAvailableStatus()
+	localStates := peer.NewCRStatesThreadsafe()
+	events := NewThreadsafeEvents(200)
+	statResultHistory := threadsafe.NewResultStatHistory()
+
+	now := time.Now()
+	poll := func(reqs float64, errs float64, pollTime time.Time) cache.AvailableStatus {
+		result := cache.Result{
+			ID:            "myCacheName",
+			Miscellaneous: map[string]interface{}{"reqs": reqs, "errs": errs},
+			Time:          pollTime,
+			Available:     true,
+			UsingIPv4:     true,
+			PollFinished:  make(chan uint64, 1),
+		}
+		if err := statResultHistory.Add(result, 10); err != nil {
+			t.Fatalf("adding result: %v", err)
+		}
+		CalcAvailability([]cache.Result{result}, "stat", &statResultHistory, *mc, toData, localCacheStatusThreadsafe, localStates, events, config.IPv4Only)
+		return localCacheStatusThreadsafe.Get()["myCacheName"]
+	}
+
+	poll(10000, 100, now)
+	if status := poll(11000, 110, now.Add(time.Second)); !status.ProcessedAvailable {
+		t.Fatalf("CalcAvailability under the rule expected available, actual: unavailable because %s", status.Why)
+	}
+
+	status := poll(12000, 210, now.Add(2*time.Second))
+	if status.ProcessedAvailable {
+		t.Fatalf("CalcAvailability over the rule expected unavailable, actual: available")
+	}
+	if !strings.Contains(status.Why, "rule errors: too many errors") {
+		t.Errorf("CalcAvailability over the rule expected why to contain 'rule errors: too many errors', actual: '%s'", status.Why)
+	}
+	if len(status.UnavailableRules) != 1 || status.UnavailableRules[0] != "errors" {
+		t.Errorf("CalcAvailability over the rule expected unavailable rules [errors], actual: %v", status.UnavailableRules)
+	}
+
+	if status := poll(13000, 240, now.Add(3*time.Second)); status.ProcessedAvailable {
+		t.Errorf("CalcAvailability above the clear expression expected unavailable, actual: available")
+	}
+	if status := poll(14000, 245, now.Add(4*time.Second)); !status.ProcessedAvailable {
+		t.Errorf("CalcAvailability below the clear expression expected available, actual: unavailable because %s", status.Why)
+	}
+}
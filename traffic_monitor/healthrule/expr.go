@@ -0,0 +1,435 @@
+package healthrule
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+// Expr is a compiled rule expression. Expressions are arithmetic over numbers
+// and stats, with the operators `+ - * /`, the comparisons
+// `< <= > >= == !=`, the logical operators `&& || !`, parentheses, and calls
+// of registered functions such as `rate(stat)` and `avg(stat, 5)`.
+//
+// A stat is a name like `proxy.process.http.completed_requests`, whose value
+// is the newest polled value. Stat names with characters other than letters,
+// digits, underscores and periods may be double-quoted, like
+// `"plugin.remap_stats.my-ds.out_bytes"`.
+//
+// Comparisons and logical operators are 1 if true and 0 if false, and any
+// non-zero value is true.
+type Expr struct {
+	src  string
+	root node
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string { return e.src }
+
+// Eval returns the value of the expression over the given stats. If a stat
+// doesn't exist or doesn't have enough history for a function, the returned
+// error wraps ErrNoData.
+func (e *Expr) Eval(stats Stats) (float64, error) {
+	return e.root.eval(stats)
+}
+
+// EvalBool returns whether the value of the expression over the given stats
+// is true, that is, not zero.
+func (e *Expr) EvalBool(stats Stats) (bool, error) {
+	val, err := e.Eval(stats)
+	return val != 0, err
+}
+
+// Parse compiles the given expression. It returns an error if the expression
+// is malformed, or calls a function which isn't registered.
+func Parse(src string) (*Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.typ != tokEOF {
+		return nil, fmt.Errorf("unexpected '%s' at position %d", tok.val, tok.pos)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+type tokType int
+
+const (
+	tokEOF tokType = iota
+	tokNum
+	tokStat
+	tokOp
+)
+
+type token struct {
+	typ tokType
+	val string
+	pos int
+}
+
+// operators are the operator tokens. Two-character operators must be before their one-character prefixes.
+var operators = []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">", "!", "+", "-", "*", "/", "(", ")", ","}
+
+func lex(src string) ([]token, error) {
+	toks := []token{}
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted stat at position %d", i)
+			}
+			toks = append(toks, token{typ: tokStat, val: src[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			toks = append(toks, token{typ: tokNum, val: src[start:i], pos: start})
+		case isStatStart(c):
+			start := i
+			for i < len(src) && isStatChar(rune(src[i])) {
+				i++
+			}
+			toks = append(toks, token{typ: tokStat, val: src[start:i], pos: start})
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character '%c' at position %d", c, i)
+			}
+			toks = append(toks, token{typ: tokOp, val: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{typ: tokEOF, val: "end of expression", pos: len(src)}), nil
+}
+
+func isStatStart(c rune) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isStatChar(c rune) bool {
+	return isStatStart(c) || c == '.' || c >= '0' && c <= '9'
+}
+
+// parser is a recursive descent parser of expressions, with one function per level of precedence, lowest first.
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	tok := p.toks[p.i]
+	if tok.typ != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+// acceptOp consumes and returns the next token if it's one of the given operators.
+func (p *parser) acceptOp(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.typ != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.val == op {
+			p.i++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expectOp(op string) error {
+	if _, ok := p.acceptOp(op); !ok {
+		tok := p.peek()
+		return fmt.Errorf("expected '%s' at position %d, got '%s'", op, tok.pos, tok.val)
+	}
+	return nil
+}
+
+// parseBinary parses a left-associative sequence of operands separated by the given operators.
+func (p *parser) parseBinary(operand func() (node, error), ops ...string) (node, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp(ops...)
+		if !ok {
+			return x, nil
+		}
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = binaryNode{op: op, x: x, y: y}
+	}
+}
+
+func (p *parser) parseOr() (node, error) { return p.parseBinary(p.parseAnd, "||") }
+
+func (p *parser) parseAnd() (node, error) { return p.parseBinary(p.parseCmp, "&&") }
+
+func (p *parser) parseCmp() (node, error) {
+	x, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.acceptOp("<=", ">=", "==", "!=", "<", ">")
+	if !ok {
+		return x, nil
+	}
+	y, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return binaryNode{op: op, x: x, y: y}, nil
+}
+
+func (p *parser) parseSum() (node, error) { return p.parseBinary(p.parseProduct, "+", "-") }
+
+func (p *parser) parseProduct() (node, error) { return p.parseBinary(p.parseUnary, "*", "/") }
+
+func (p *parser) parseUnary() (node, error) {
+	op, ok := p.acceptOp("!", "-")
+	if !ok {
+		return p.parsePrimary()
+	}
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return unaryNode{op: op, x: x}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.typ {
+	case tokNum:
+		val, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed number '%s' at position %d", tok.val, tok.pos)
+		}
+		return numNode(val), nil
+	case tokStat:
+		if _, ok := p.acceptOp("("); !ok {
+			return statNode(tok.val), nil
+		}
+		return p.parseCall(tok)
+	case tokOp:
+		if tok.val == "(" {
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expectOp(")")
+		}
+	}
+	return nil, fmt.Errorf("unexpected '%s' at position %d", tok.val, tok.pos)
+}
+
+// parseCall parses the arguments of a call of the function named by the given token, whose opening parenthesis has been consumed.
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := getFunc(name.val)
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s' at position %d", name.val, name.pos)
+	}
+	call := callNode{name: name.val, fn: fn}
+	if _, ok := p.acceptOp(")"); ok {
+		return call, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if _, ok := p.acceptOp(","); !ok {
+			return call, p.expectOp(")")
+		}
+	}
+}
+
+type node interface {
+	eval(stats Stats) (float64, error)
+}
+
+type numNode float64
+
+func (n numNode) eval(Stats) (float64, error) { return float64(n), nil }
+
+// statNode is the newest value of a stat.
+type statNode string
+
+func (n statNode) eval(stats Stats) (float64, error) {
+	history := stats.History(string(n))
+	if len(history) == 0 {
+		return 0, fmt.Errorf("stat '%s': %w", string(n), ErrNoData)
+	}
+	return statVal(string(n), history[0])
+}
+
+type unaryNode struct {
+	op string
+	x  node
+}
+
+func (n unaryNode) eval(stats Stats) (float64, error) {
+	x, err := n.x.eval(stats)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == "!" {
+		return boolVal(x == 0), nil
+	}
+	return -x, nil
+}
+
+type binaryNode struct {
+	op   string
+	x, y node
+}
+
+func (n binaryNode) eval(stats Stats) (float64, error) {
+	x, err := n.x.eval(stats)
+	if err != nil {
+		return 0, err
+	}
+	// logical operators short-circuit, so e.g. `a > 0 && b / a > 1` doesn't need b if a is 0
+	switch {
+	case n.op == "&&" && x == 0:
+		return 0, nil
+	case n.op == "||" && x != 0:
+		return 1, nil
+	}
+	y, err := n.y.eval(stats)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		if y == 0 {
+			return 0, fmt.Errorf("division by zero: %w", ErrNoData)
+		}
+		return x / y, nil
+	case "<":
+		return boolVal(x < y), nil
+	case "<=":
+		return boolVal(x <= y), nil
+	case ">":
+		return boolVal(x > y), nil
+	case ">=":
+		return boolVal(x >= y), nil
+	case "==":
+		return boolVal(x == y), nil
+	case "!=":
+		return boolVal(x != y), nil
+	case "&&", "||":
+		return boolVal(y != 0), nil
+	}
+	return 0, errors.New("unknown operator '" + n.op + "'") // should never happen, the parser only creates known operators
+}
+
+type callNode struct {
+	name string
+	fn   Func
+	args []node
+}
+
+func (n callNode) eval(stats Stats) (float64, error) {
+	args := make([]Arg, 0, len(n.args))
+	for _, argNode := range n.args {
+		if stat, ok := argNode.(statNode); ok {
+			history := stats.History(string(stat))
+			if len(history) == 0 {
+				return 0, fmt.Errorf("%s: stat '%s': %w", n.name, string(stat), ErrNoData)
+			}
+			val, err := statVal(string(stat), history[0])
+			if err != nil {
+				return 0, fmt.Errorf("%s: %w", n.name, err)
+			}
+			args = append(args, Arg{Stat: string(stat), History: history, Val: val})
+			continue
+		}
+		val, err := argNode.eval(stats)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, Arg{Val: val})
+	}
+	val, err := n.fn(args)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", n.name, err)
+	}
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return 0, fmt.Errorf("%s: result %v is not a finite number", n.name, val)
+	}
+	return val, nil
+}
+
+func boolVal(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// statVal returns the numeric value of the given polled value of the given stat.
+func statVal(stat string, val tc.ResultStatVal) (float64, error) {
+	num, ok := util.ToNumeric(val.Val)
+	if !ok {
+		return 0, fmt.Errorf("stat '%s' value '%v' is not a number", stat, val.Val)
+	}
+	return num, nil
+}
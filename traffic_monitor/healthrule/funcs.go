@@ -0,0 +1,205 @@
+package healthrule
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// Func is a function which may be called in rule expressions.
+type Func func(args []Arg) (float64, error)
+
+// Arg is an argument of a function call.
+type Arg struct {
+	// Stat is the name of the stat, if the argument is a lone stat. Otherwise,
+	// it's empty.
+	Stat string
+	// History is the polled values of the stat, newest first, if the argument
+	// is a lone stat. Consecutive polls with the same value are a single
+	// value, with a Span of the number of polls.
+	History []tc.ResultStatVal
+	// Val is the value of the argument: the newest value of the stat, or the
+	// value of any other expression.
+	Val float64
+}
+
+var funcs = map[string]Func{}
+var funcsM = sync.RWMutex{}
+
+// Register registers a function which may be called in rule expressions by
+// the given name, replacing any function already registered by that name.
+// Functions must be registered before the rules calling them are compiled,
+// typically in an init func.
+func Register(name string, f Func) {
+	funcsM.Lock()
+	defer funcsM.Unlock()
+	funcs[name] = f
+}
+
+func getFunc(name string) (Func, bool) {
+	funcsM.RLock()
+	defer funcsM.RUnlock()
+	f, ok := funcs[name]
+	return f, ok
+}
+
+func init() {
+	Register("rate", rate)
+	Register("delta", delta)
+	Register("avg", avg)
+	Register("min", minOf)
+	Register("max", maxOf)
+	Register("abs", abs)
+}
+
+// rate is `rate(stat)`, the per-second change of a counter stat between its two newest values. If the counter decreased, it's assumed to have been reset to zero, and the newest value is the change. If the newest value was polled more than once, the counter has stopped, and its rate is 0.
+func rate(args []Arg) (float64, error) {
+	if err := checkArgs(args, 1, true); err != nil {
+		return 0, err
+	}
+	if unchanged(args[0]) {
+		return 0, nil
+	}
+	prev, cur, err := lastTwo(args[0])
+	if err != nil {
+		return 0, err
+	}
+	seconds := cur.Time.Sub(prev.Time).Seconds()
+	if seconds <= 0 {
+		return 0, fmt.Errorf("stat '%s' values are not in time order: %w", args[0].Stat, ErrNoData)
+	}
+	change := args[0].Val - mustNum(prev)
+	if change < 0 {
+		change = args[0].Val
+	}
+	return change / seconds, nil
+}
+
+// delta is `delta(stat)`, the change of a stat between its two newest polls, which is 0 if the newest value was polled more than once.
+func delta(args []Arg) (float64, error) {
+	if err := checkArgs(args, 1, true); err != nil {
+		return 0, err
+	}
+	if unchanged(args[0]) {
+		return 0, nil
+	}
+	prev, _, err := lastTwo(args[0])
+	if err != nil {
+		return 0, err
+	}
+	return args[0].Val - mustNum(prev), nil
+}
+
+// avg is `avg(stat, polls)`, the moving average of a stat over the given number of newest polls, or as many as there are.
+func avg(args []Arg) (float64, error) {
+	sum, count := 0.0, 0.0
+	err := eachPoll(args, func(val float64, polls float64) {
+		sum += val * polls
+		count += polls
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sum / count, nil
+}
+
+// minOf is `min(stat, polls)`, the minimum of a stat over the given number of newest polls, or as many as there are.
+func minOf(args []Arg) (float64, error) {
+	lo := math.Inf(1)
+	err := eachPoll(args, func(val float64, _ float64) { lo = math.Min(lo, val) })
+	return lo, err
+}
+
+// maxOf is `max(stat, polls)`, the maximum of a stat over the given number of newest polls, or as many as there are.
+func maxOf(args []Arg) (float64, error) {
+	hi := math.Inf(-1)
+	err := eachPoll(args, func(val float64, _ float64) { hi = math.Max(hi, val) })
+	return hi, err
+}
+
+// abs is `abs(x)`, the absolute value of any expression.
+func abs(args []Arg) (float64, error) {
+	if err := checkArgs(args, 1, false); err != nil {
+		return 0, err
+	}
+	return math.Abs(args[0].Val), nil
+}
+
+// checkArgs returns an error if there aren't n args, or if the first arg must be a stat and isn't.
+func checkArgs(args []Arg, n int, firstIsStat bool) error {
+	if len(args) != n {
+		return fmt.Errorf("expected %d arguments, got %d", n, len(args))
+	}
+	if firstIsStat && args[0].Stat == "" {
+		return errors.New("first argument must be a stat")
+	}
+	return nil
+}
+
+// lastTwo returns the previous and newest values of the stat arg.
+// unchanged returns whether the newest value of the stat arg was polled more than once. Unchanged polls are collapsed into the newest value, with its Span counting them and its Time updated, so the value before it was polled before the stat stopped changing.
+func unchanged(arg Arg) bool {
+	return len(arg.History) > 0 && arg.History[0].Span > 1
+}
+
+func lastTwo(arg Arg) (tc.ResultStatVal, tc.ResultStatVal, error) {
+	if len(arg.History) < 2 {
+		return tc.ResultStatVal{}, tc.ResultStatVal{}, fmt.Errorf("stat '%s' has %d values, needs 2: %w", arg.Stat, len(arg.History), ErrNoData)
+	}
+	if _, err := statVal(arg.Stat, arg.History[1]); err != nil {
+		return tc.ResultStatVal{}, tc.ResultStatVal{}, err
+	}
+	return arg.History[1], arg.History[0], nil
+}
+
+// mustNum returns the numeric value of a stat value which has already been checked to be numeric.
+func mustNum(val tc.ResultStatVal) float64 {
+	num, _ := statVal("", val)
+	return num
+}
+
+// eachPoll calls f with each distinct value of the stat arg over the number of polls in the second arg, newest first, with the number of polls the value was polled.
+func eachPoll(args []Arg, f func(val float64, polls float64)) error {
+	if err := checkArgs(args, 2, true); err != nil {
+		return err
+	}
+	remaining := math.Floor(args[1].Val)
+	if remaining < 1 {
+		return fmt.Errorf("number of polls must be at least 1, got %v", args[1].Val)
+	}
+	for _, histVal := range args[0].History {
+		if remaining <= 0 {
+			break
+		}
+		val, err := statVal(args[0].Stat, histVal)
+		if err != nil {
+			return err
+		}
+		polls := math.Min(math.Max(float64(histVal.Span), 1), remaining)
+		f(val, polls)
+		remaining -= polls
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Package healthrule evaluates health rules: expressions over the polled
+// stats of cache servers, which mark them unavailable when true. Rules may be
+// extended with new functions with Register.
+package healthrule
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// ErrNoData is wrapped by the errors of evaluating expressions which use a
+// stat which doesn't exist or doesn't have enough history, or which divide by
+// zero. This is expected, for example when a cache server was just added, the
+// poller doesn't poll the stat, or a ratio of rates has no traffic, and the
+// state of the rule should be left unchanged.
+var ErrNoData = errors.New("not enough data")
+
+// Stats is the source of the stats rules are evaluated over.
+type Stats interface {
+	// History returns the polled values of the given stat, newest first, or
+	// nil if the stat doesn't exist.
+	History(stat string) []tc.ResultStatVal
+}
+
+// Rule is a compiled health rule.
+type Rule struct {
+	Name string
+	// Expr marks the cache unavailable when true.
+	Expr *Expr
+	// Clear must be true for the cache to be marked available again, once the
+	// rule has marked it unavailable. If nil, the rule clears as soon as Expr
+	// is false.
+	Clear *Expr
+	// Reason is the explanation of why the rule marked the cache unavailable.
+	Reason string
+}
+
+// New compiles the given health rule.
+func New(name string, rule tc.HealthRule) (*Rule, error) {
+	expr, err := Parse(rule.Expr)
+	if err != nil {
+		return nil, errors.New("parsing expression: " + err.Error())
+	}
+	r := &Rule{Name: name, Expr: expr, Reason: rule.Reason}
+	if rule.Clear != "" {
+		if r.Clear, err = Parse(rule.Clear); err != nil {
+			return nil, errors.New("parsing clear expression: " + err.Error())
+		}
+	}
+	if r.Reason == "" {
+		r.Reason = rule.Expr
+	}
+	return r, nil
+}
+
+// compiledRule is a memoized result of New.
+type compiledRule struct {
+	rule *Rule
+	err  error
+}
+
+type ruleKey struct {
+	name string
+	rule tc.HealthRule
+}
+
+// compiled is the memoized rules compiled by Get, since rules are evaluated on every poll of every cache, and change rarely.
+var compiled = sync.Map{} // map[ruleKey]compiledRule
+
+// Get returns the compiled health rule, compiling it with New only the first
+// time a given rule is requested. The bool is true if this call compiled the
+// rule, so callers may report errors only once.
+func Get(name string, rule tc.HealthRule) (*Rule, bool, error) {
+	key := ruleKey{name: name, rule: rule}
+	if c, ok := compiled.Load(key); ok {
+		return c.(compiledRule).rule, false, c.(compiledRule).err
+	}
+	r, err := New(name, rule)
+	compiled.Store(key, compiledRule{rule: r, err: err})
+	return r, true, err
+}
+
+// Eval returns whether the rule marks the cache unavailable, given whether it
+// already did. If the rule hasn't marked the cache unavailable, it does when
+// Expr is true. If it has, it continues to until Clear is true, or if there's
+// no Clear, until Expr is false.
+//
+// If the rule can't be evaluated, the error is returned with the previous
+// state, which callers should keep. If the error wraps ErrNoData, the stats
+// needed to evaluate the rule aren't available.
+func (r *Rule) Eval(stats Stats, unavailable bool) (bool, error) {
+	if !unavailable || r.Clear == nil {
+		trip, err := r.Expr.EvalBool(stats)
+		if err != nil {
+			return unavailable, fmt.Errorf("rule '%s': %w", r.Name, err)
+		}
+		return trip, nil
+	}
+	cleared, err := r.Clear.EvalBool(stats)
+	if err != nil {
+		return unavailable, fmt.Errorf("rule '%s' clear: %w", r.Name, err)
+	}
+	return !cleared, nil
+}
@@ -0,0 +1,243 @@
+package healthrule
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// testStats is a Stats of literal histories.
+type testStats map[string][]tc.ResultStatVal
+
+func (s testStats) History(stat string) []tc.ResultStatVal { return s[stat] }
+
+func testHistory(vals ...interface{}) []tc.ResultStatVal {
+	now := time.Now()
+	history := []tc.ResultStatVal{}
+	for i, val := range vals {
+		history = append(history, tc.ResultStatVal{Val: val, Time: now.Add(time.Duration(-i*10) * time.Second), Span: 1})
+	}
+	return history
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"a >",
+		"(a > 1",
+		"a > 1)",
+		"nosuchfunc(a)",
+		`"unterminated > 1`,
+		"a # 1",
+		"1.2.3 > a",
+	} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse('%s') expected error, actual: nil", src)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	stats := testStats{
+		"proxy.process.http.5xx_responses":   testHistory(float64(50), float64(40)),
+		"plugin.remap_stats.my-ds.out_bytes": testHistory("1000"),
+		"version":                            testHistory("9.1.2"),
+	}
+	tests := []struct {
+		src      string
+		expected float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"-2 * -3", 6},
+		{"1 < 2 && 2 <= 2 && 3 > 2 && 3 >= 3 && 1 == 1 && 1 != 2", 1},
+		{"1 > 2 || !1", 0},
+		{"!0", 1},
+		{"proxy.process.http.5xx_responses", 50},
+		{`"plugin.remap_stats.my-ds.out_bytes" / 10`, 100},
+		{"0 && nostat > 1", 0}, // short-circuits, so the missing stat isn't needed
+		{"1 || nostat > 1", 1},
+	}
+	for _, test := range tests {
+		expr, err := Parse(test.src)
+		if err != nil {
+			t.Errorf("Parse('%s') expected nil error, actual: %v", test.src, err)
+			continue
+		}
+		if val, err := expr.Eval(stats); err != nil {
+			t.Errorf("Eval('%s') expected nil error, actual: %v", test.src, err)
+		} else if val != test.expected {
+			t.Errorf("Eval('%s') expected %v, actual: %v", test.src, test.expected, val)
+		}
+	}
+
+	for _, src := range []string{"nostat > 1", "rate(version) > 1", "1 / (proxy.process.http.5xx_responses - 50)"} {
+		expr, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse('%s') expected nil error, actual: %v", src, err)
+		}
+		if _, err := expr.Eval(stats); err == nil {
+			t.Errorf("Eval('%s') expected error, actual: nil", src)
+		}
+	}
+	expr, _ := Parse("nostat > 1")
+	if _, err := expr.Eval(stats); !errors.Is(err, ErrNoData) {
+		t.Errorf("Eval of a missing stat expected ErrNoData, actual: %v", err)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	now := time.Now()
+	stats := testStats{
+		// 3 polls of 30, and 1 poll each of 20 and 10, 10 seconds apart
+		"load": []tc.ResultStatVal{
+			{Val: float64(30), Time: now, Span: 3},
+			{Val: float64(20), Time: now.Add(-30 * time.Second), Span: 1},
+			{Val: float64(10), Time: now.Add(-40 * time.Second), Span: 1},
+		},
+		"counter": testHistory(float64(1500), float64(1000)),
+		"reset":   testHistory(float64(200), float64(1000)),
+		"new":     testHistory(float64(5)),
+		// a counter which stopped at 1500 over the last 3 polls
+		"stopped": []tc.ResultStatVal{
+			{Val: float64(1500), Time: now, Span: 3},
+			{Val: float64(1000), Time: now.Add(-30 * time.Second), Span: 1},
+		},
+		"stoppedNew": []tc.ResultStatVal{{Val: float64(5), Time: now, Span: 2}},
+	}
+	tests := []struct {
+		src      string
+		expected float64
+	}{
+		{"rate(counter)", 50},
+		{"rate(reset)", 20},
+		{"delta(counter)", 500},
+		{"rate(stopped)", 0},
+		{"delta(stopped)", 0},
+		{"rate(stoppedNew)", 0},
+		{"avg(load, 3)", 30},
+		{"avg(load, 4)", 27.5},
+		{"avg(load, 100)", 24},
+		{"min(load, 4)", 20},
+		{"max(load, 5)", 30},
+		{"min(load, 5)", 10},
+		{"abs(delta(reset))", 800},
+		{"max(load, 1) + rate(counter)", 80},
+	}
+	for _, test := range tests {
+		expr, err := Parse(test.src)
+		if err != nil {
+			t.Errorf("Parse('%s') expected nil error, actual: %v", test.src, err)
+			continue
+		}
+		if val, err := expr.Eval(stats); err != nil {
+			t.Errorf("Eval('%s') expected nil error, actual: %v", test.src, err)
+		} else if val != test.expected {
+			t.Errorf("Eval('%s') expected %v, actual: %v", test.src, test.expected, val)
+		}
+	}
+
+	for _, src := range []string{"rate(new)", "delta(new)"} {
+		expr, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse('%s') expected nil error, actual: %v", src, err)
+		}
+		if _, err := expr.Eval(stats); !errors.Is(err, ErrNoData) {
+			t.Errorf("Eval('%s') with one value expected ErrNoData, actual: %v", src, err)
+		}
+	}
+	for _, src := range []string{"rate(1)", "avg(load)", "avg(load, 0)", "abs(1, 2)"} {
+		expr, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse('%s') expected nil error, actual: %v", src, err)
+		}
+		if _, err := expr.Eval(stats); err == nil {
+			t.Errorf("Eval('%s') expected error, actual: nil", src)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("polls", func(args []Arg) (float64, error) {
+		polls := uint64(0)
+		for _, val := range args[0].History {
+			polls += val.Span
+		}
+		return float64(polls), nil
+	})
+	expr, err := Parse("polls(load) >= 3")
+	if err != nil {
+		t.Fatalf("Parse with registered func expected nil error, actual: %v", err)
+	}
+	stats := testStats{"load": []tc.ResultStatVal{{Val: float64(1), Span: 2}, {Val: float64(2), Span: 1}}}
+	if val, err := expr.EvalBool(stats); err != nil || !val {
+		t.Errorf("Eval with registered func expected true, actual: %v %v", val, err)
+	}
+}
+
+func TestRuleEval(t *testing.T) {
+	rule, err := New("load", tc.HealthRule{Expr: "load > 10", Clear: "load < 5"})
+	if err != nil {
+		t.Fatalf("New expected nil error, actual: %v", err)
+	}
+	if rule.Reason != "load > 10" {
+		t.Errorf("New without reason expected the expression as the reason, actual: '%s'", rule.Reason)
+	}
+
+	tests := []struct {
+		load        float64
+		unavailable bool
+		expected    bool
+	}{
+		{load: 11, unavailable: false, expected: true},
+		{load: 7, unavailable: false, expected: false},
+		{load: 7, unavailable: true, expected: true},
+		{load: 4, unavailable: true, expected: false},
+	}
+	for _, test := range tests {
+		stats := testStats{"load": testHistory(test.load)}
+		if actual, err := rule.Eval(stats, test.unavailable); err != nil {
+			t.Errorf("Eval load %v previously unavailable %v expected nil error, actual: %v", test.load, test.unavailable, err)
+		} else if actual != test.expected {
+			t.Errorf("Eval load %v previously unavailable %v expected %v, actual: %v", test.load, test.unavailable, test.expected, actual)
+		}
+	}
+
+	if actual, err := rule.Eval(testStats{}, true); err == nil || !actual {
+		t.Errorf("Eval without stats expected an error and the previous state, actual: %v %v", actual, err)
+	}
+
+	noClear, err := New("load", tc.HealthRule{Expr: "load > 10"})
+	if err != nil {
+		t.Fatalf("New expected nil error, actual: %v", err)
+	}
+	if actual, _ := noClear.Eval(testStats{"load": testHistory(float64(7))}, true); actual {
+		t.Errorf("Eval without clear expression below the expression expected available, actual: unavailable")
+	}
+
+	if _, err := New("bad", tc.HealthRule{Expr: "load > 10", Clear: "load <"}); err == nil {
+		t.Errorf("New with invalid clear expression expected error, actual: nil")
+	}
+}
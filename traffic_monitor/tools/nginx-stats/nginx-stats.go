@@ -0,0 +1,161 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// nginx-stats is a service run alongside nginx on a cache server, which serves
+// the system stats of the cache server and the stats of nginx in the `nginx`
+// format polled by Traffic Monitor.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nginxStats is the `nginx` Traffic Monitor polling format.
+type nginxStats struct {
+	ProcLoadavg  string          `json:"proc.loadavg"`
+	ProcNetDev   string          `json:"proc.net.dev"`
+	InfSpeed     int64           `json:"inf_speed"`
+	NotAvailable bool            `json:"not_available"`
+	VTS          json.RawMessage `json:"vts,omitempty"`
+	StubStatus   json.RawMessage `json:"stub_status,omitempty"`
+}
+
+// maxStatusBytes is the most read from an nginx status page.
+const maxStatusBytes = 16 << 20
+
+var client = &http.Client{Timeout: 5 * time.Second}
+
+// getStatus returns the body of the given nginx status page.
+func getStatus(url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxStatusBytes))
+}
+
+func getSystemData(inf string) nginxStats {
+	var stats nginxStats
+	loadavg, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		log.Printf("failed to read /proc/loadavg: %s\n", err)
+	}
+	stats.ProcLoadavg = strings.TrimSpace(string(loadavg))
+
+	procNetDev, err := os.ReadFile("/proc/net/dev")
+	if err != nil {
+		log.Printf("failed to read /proc/net/dev: %s\n", err)
+	}
+
+	parts := strings.Split(string(procNetDev), "\n")
+	for _, line := range parts {
+		if strings.HasPrefix(strings.TrimSpace(line), inf+":") {
+			stats.ProcNetDev = strings.TrimSpace(line)
+			break
+		}
+	}
+
+	infSpeedFile := fmt.Sprintf("/sys/class/net/%s/speed", inf)
+	speedStr, err := os.ReadFile(infSpeedFile)
+	if err != nil {
+		log.Printf("failed to read %s: %s\n", infSpeedFile, err)
+	}
+	speed, err := strconv.ParseInt(strings.TrimSpace(string(speedStr)), 10, 64)
+	if err != nil {
+		log.Printf("failed to convert speed '%s' to int: %s\n", speedStr, err)
+	}
+	stats.InfSpeed = speed
+	return stats
+}
+
+func getNginxData(stats *nginxStats, vtsURL string, stubStatusURL string) {
+	reachable := false
+	if vtsURL != "" {
+		vts, err := getStatus(vtsURL)
+		if err != nil {
+			log.Printf("failed to get nginx-module-vts status from %s: %s\n", vtsURL, err)
+		} else if !json.Valid(vts) {
+			log.Printf("nginx-module-vts status from %s is not JSON\n", vtsURL)
+		} else {
+			stats.VTS = vts
+			reachable = true
+		}
+	}
+	if stubStatusURL != "" {
+		stubStatus, err := getStatus(stubStatusURL)
+		if err != nil {
+			log.Printf("failed to get stub_status from %s: %s\n", stubStatusURL, err)
+		} else if stats.StubStatus, err = json.Marshal(string(stubStatus)); err != nil {
+			log.Printf("failed to encode stub_status: %s\n", err)
+		} else {
+			reachable = true
+		}
+	}
+	// nginx serves its own status pages, so if none of them can be fetched,
+	// nginx isn't serving requests.
+	stats.NotAvailable = !reachable
+}
+
+func main() {
+	var port int
+	var vtsURL string
+	var stubStatusURL string
+	flag.IntVar(&port, "port", 2000, "port to run nginx-stats on")
+	flag.StringVar(&vtsURL, "vts-url", "http://127.0.0.1/status/format/json", "URL of the nginx-module-vts JSON status page, or empty to not use nginx-module-vts")
+	flag.StringVar(&stubStatusURL, "stub-status-url", "", "URL of the ngx_http_stub_status_module status page, or empty to not use stub_status")
+	flag.Parse()
+
+	if vtsURL == "" && stubStatusURL == "" {
+		log.Fatalln("at least one of -vts-url and -stub-status-url is required")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		inf := r.URL.Query().Get("inf.name")
+		if inf == "" {
+			inf = "eth0"
+		}
+		inf = strings.ReplaceAll(inf, ".", "")
+		inf = strings.ReplaceAll(inf, "/", "")
+		stats := getSystemData(inf)
+		getNginxData(&stats, vtsURL, stubStatusURL)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			log.Printf("failed to write nginx stats: %s", err)
+		}
+	})
+
+	listenAddress := fmt.Sprintf(":%d", port)
+	if err := http.ListenAndServe(listenAddress, nil); err != nil {
+		log.Printf("server stopped %s", err)
+	}
+}
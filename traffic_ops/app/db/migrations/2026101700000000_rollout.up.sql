@@ -0,0 +1,55 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with this
+ * work for additional information regarding copyright ownership.  The ASF
+ * licenses this file to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+CREATE TABLE IF NOT EXISTS public.rollout (
+  id bigserial NOT NULL,
+  topology text NOT NULL,
+  cdn_id bigint NOT NULL,
+  status text NOT NULL DEFAULT 'running',
+  steps integer[] NOT NULL,
+  wave integer NOT NULL DEFAULT 0,
+  wave_started_at timestamp with time zone NOT NULL DEFAULT now(),
+  bake_time_seconds integer NOT NULL,
+  min_availability numeric NOT NULL,
+  max_error_rate numeric NOT NULL,
+  on_regression text NOT NULL,
+  status_reason text,
+  username text NOT NULL,
+  last_updated timestamp with time zone NOT NULL DEFAULT now(),
+  CONSTRAINT pk_rollout PRIMARY KEY (id),
+  CONSTRAINT fk_rollout_topology FOREIGN KEY (topology) REFERENCES public.topology("name") ON UPDATE CASCADE ON DELETE CASCADE,
+  CONSTRAINT fk_rollout_cdn FOREIGN KEY (cdn_id) REFERENCES public.cdn(id) ON DELETE CASCADE,
+  CONSTRAINT rollout_status_check CHECK (status IN ('running', 'paused', 'completed', 'halted')),
+  CONSTRAINT rollout_on_regression_check CHECK (on_regression IN ('pause', 'halt'))
+);
+
+CREATE TABLE IF NOT EXISTS public.rollout_server (
+  rollout_id bigint NOT NULL,
+  server_id bigint NOT NULL,
+  wave integer NOT NULL,
+  released_at timestamp with time zone,
+  CONSTRAINT pk_rollout_server PRIMARY KEY (rollout_id, server_id),
+  CONSTRAINT fk_rollout_server_rollout FOREIGN KEY (rollout_id) REFERENCES public.rollout(id) ON DELETE CASCADE,
+  CONSTRAINT fk_rollout_server_server FOREIGN KEY (server_id) REFERENCES public.server(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS rollout_status_idx ON public.rollout(status);
+
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.rollout;
+CREATE TRIGGER on_update_current_timestamp
+BEFORE UPDATE ON public.rollout
+FOR EACH ROW EXECUTE PROCEDURE on_update_current_timestamp_last_updated();
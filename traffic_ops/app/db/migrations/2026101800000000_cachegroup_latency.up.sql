@@ -0,0 +1,33 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with this
+ * work for additional information regarding copyright ownership.  The ASF
+ * licenses this file to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+CREATE TABLE IF NOT EXISTS public.cachegroup_latency (
+  cachegroup text NOT NULL,
+  parent_cachegroup text NOT NULL,
+  latency_ms numeric NOT NULL,
+  samples bigint NOT NULL DEFAULT 1,
+  last_updated timestamp with time zone NOT NULL DEFAULT now(),
+  CONSTRAINT pk_cachegroup_latency PRIMARY KEY (cachegroup, parent_cachegroup),
+  CONSTRAINT fk_cachegroup_latency_cachegroup FOREIGN KEY (cachegroup) REFERENCES public.cachegroup("name") ON UPDATE CASCADE ON DELETE CASCADE,
+  CONSTRAINT fk_cachegroup_latency_parent_cachegroup FOREIGN KEY (parent_cachegroup) REFERENCES public.cachegroup("name") ON UPDATE CASCADE ON DELETE CASCADE,
+  CONSTRAINT cachegroup_latency_latency_ms_check CHECK (latency_ms >= 0)
+);
+
+DROP TRIGGER IF EXISTS on_update_current_timestamp ON public.cachegroup_latency;
+CREATE TRIGGER on_update_current_timestamp
+BEFORE UPDATE ON public.cachegroup_latency
+FOR EACH ROW EXECUTE PROCEDURE on_update_current_timestamp_last_updated();
@@ -126,8 +126,12 @@ func main() {
 		tx.Rollback()
 		die("re-encrypting DNSSEC Keys: " + err.Error())
 	}
+	if err = reEncryptSecretVersions(tx, previousKey, newKey); err != nil {
+		tx.Rollback()
+		die("re-encrypting Secret Versions: " + err.Error())
+	}
 
-	fmt.Println("Successfully re-encrypted keys for SSL Keys, URL Sig Keys, URI Signing Keys, and DNSSEC Keys.")
+	fmt.Println("Successfully re-encrypted keys for SSL Keys, URL Sig Keys, URI Signing Keys, DNSSEC Keys, and Secret Versions.")
 }
 
 type Config struct {
@@ -363,6 +367,62 @@ func reEncryptDNSSECKeys(tx *sql.Tx, previousKey []byte, newKey []byte) error {
 	return nil
 }
 
+type secretVersionInfo struct {
+	secretType string
+	name       string
+	version    int64
+	newData    []byte
+}
+
+func reEncryptSecretVersions(tx *sql.Tx, previousKey []byte, newKey []byte) error {
+	rows, err := tx.Query("SELECT secret_type, name, version, data FROM secret_version WHERE data IS NOT NULL")
+	if err != nil {
+		return fmt.Errorf("querying: %w", err)
+	}
+	defer rows.Close()
+
+	var secretVersionInfos []secretVersionInfo
+
+	for rows.Next() {
+		info := secretVersionInfo{}
+		var encryptedData []byte
+		if err = rows.Scan(&info.secretType, &info.name, &info.version, &encryptedData); err != nil {
+			return fmt.Errorf("getting Secret Versions: %w", err)
+		}
+		jsonKeys, err := util.AESDecrypt(encryptedData, previousKey)
+		if err != nil {
+			return fmt.Errorf("reading Secret Versions: %w", err)
+		}
+
+		if !bytes.HasPrefix(jsonKeys, []byte("{")) {
+			return fmt.Errorf("decrypted Secret Version did not have prefix '{' for %s %s version %d", info.secretType, info.name, info.version)
+		}
+
+		info.newData, err = util.AESEncrypt(jsonKeys, newKey)
+		if err != nil {
+			return fmt.Errorf("encrypting Secret Versions with new key: %w", err)
+		}
+
+		secretVersionInfos = append(secretVersionInfos, info)
+	}
+
+	for _, info := range secretVersionInfos {
+		res, err := tx.Exec(`UPDATE secret_version SET data = $1 WHERE secret_type = $2 AND name = $3 AND version = $4`, info.newData, info.secretType, info.name, info.version)
+		if err != nil {
+			return fmt.Errorf("updating Secret Versions for %s %s: %w", info.secretType, info.name, err)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("determining rows affected for reencrypting Secret Versions for %s %s: %w", info.secretType, info.name, err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("no rows updated for reencrypting Secret Versions for %s %s version %d", info.secretType, info.name, info.version)
+		}
+	}
+
+	return nil
+}
+
 func die(message string) {
 	fmt.Fprintln(os.Stderr, message)
 	os.Exit(1)
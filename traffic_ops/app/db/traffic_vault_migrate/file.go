@@ -0,0 +1,403 @@
+package main
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	util "github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+// These are the directories, beneath the configured directory, that the file
+// Traffic Vault backend of Traffic Ops stores each kind of key in.
+const (
+	fileSSLKeysDir        = "sslkeys"
+	fileDNSSecKeysDir     = "dnsseckeys"
+	fileURISignKeysDir    = "urisigningkeys"
+	fileURLSigKeysDir     = "urlsigkeys"
+	fileDirMode           = 0700
+	fileMode              = 0600
+	fileInvalidNameFormat = "%s '%s': name cannot be used as a file name"
+)
+
+// FileConfig represents the configuration options available to the File backend.
+type FileConfig struct {
+	Directory string `json:"directory"`
+	KeyBase64 string `json:"aesKey"`
+	AESKey    []byte
+}
+
+// FileBackend is the local directory implementation of TVBackend.
+type FileBackend struct {
+	sslKeys        []fileRecord
+	dnssecKeys     []fileRecord
+	uriSigningKeys []fileRecord
+	urlSigKeys     []fileRecord
+	cfg            FileConfig
+}
+
+// fileRecord is a single encrypted key file. Name is the Delivery Service
+// XMLID or CDN name that the keys belong to, and Version is only used for SSL
+// keys.
+type fileRecord struct {
+	Name          string
+	Version       string
+	DataEncrypted []byte
+}
+
+// String returns a high level overview of the backend and its keys.
+func (fb *FileBackend) String() string {
+	data := fmt.Sprintf("File directory %s\n", fb.cfg.Directory)
+	data += fmt.Sprintf("\tSSL Keys: %d\n", len(fb.sslKeys))
+	data += fmt.Sprintf("\tDNSSec Keys: %d\n", len(fb.dnssecKeys))
+	data += fmt.Sprintf("\tURI Signing Keys: %d\n", len(fb.uriSigningKeys))
+	data += fmt.Sprintf("\tURL Sig Keys: %d\n", len(fb.urlSigKeys))
+	return data
+}
+
+// Name returns the name for this backend.
+func (fb *FileBackend) Name() string {
+	return "File"
+}
+
+// ReadConfigFile takes in a filename and will read it into the backends config.
+func (fb *FileBackend) ReadConfigFile(configFile string) error {
+	var err error
+	if err = UnmarshalConfig(configFile, &fb.cfg); err != nil {
+		return err
+	}
+
+	if fb.cfg.Directory == "" {
+		return fmt.Errorf("file config '%s' has no directory", configFile)
+	}
+
+	if fb.cfg.AESKey, err = base64.StdEncoding.DecodeString(fb.cfg.KeyBase64); err != nil {
+		return fmt.Errorf("unable to decode File AESKey '%s': %w", fb.cfg.KeyBase64, err)
+	}
+
+	if err = util.ValidateAESKey(fb.cfg.AESKey); err != nil {
+		return fmt.Errorf("unable to validate File AESKey '%s'", fb.cfg.KeyBase64)
+	}
+	return nil
+}
+
+// Insert takes the current keys and writes them into the backend directory.
+func (fb *FileBackend) Insert() error {
+	for _, record := range fb.sslKeys {
+		if err := writeFileRecord(filepath.Join(fb.cfg.Directory, fileSSLKeysDir, record.Name, record.Version), record); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []struct {
+		name    string
+		records []fileRecord
+	}{
+		{fileDNSSecKeysDir, fb.dnssecKeys},
+		{fileURISignKeysDir, fb.uriSigningKeys},
+		{fileURLSigKeysDir, fb.urlSigKeys},
+	} {
+		for _, record := range dir.records {
+			if err := writeFileRecord(filepath.Join(fb.cfg.Directory, dir.name, record.Name), record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Start prepares the backend directory.
+func (fb *FileBackend) Start() error {
+	if err := os.MkdirAll(fb.cfg.Directory, fileDirMode); err != nil {
+		return fmt.Errorf("unable to create File directory '%s': %w", fb.cfg.Directory, err)
+	}
+	fb.sslKeys = []fileRecord{}
+	fb.dnssecKeys = []fileRecord{}
+	fb.uriSigningKeys = []fileRecord{}
+	fb.urlSigKeys = []fileRecord{}
+	return nil
+}
+
+// ValidateKey validates that the keys are valid (in most cases, certain fields are not null).
+func (fb *FileBackend) ValidateKey() []string {
+	var allErrs []string
+	for _, record := range fb.sslKeys {
+		if !validFileName(record.Name) || !validFileName(record.Version) {
+			allErrs = append(allErrs, fmt.Sprintf(fileInvalidNameFormat, "SSL Key", record.Name+"/"+record.Version))
+		} else if record.DataEncrypted == nil {
+			allErrs = append(allErrs, fmt.Sprintf("SSL Key '%s': DataEncrypted is blank!", record.Name))
+		}
+	}
+	for _, kind := range []struct {
+		name    string
+		records []fileRecord
+	}{
+		{"DNSSEC Key CDN", fb.dnssecKeys},
+		{"URI Key DS", fb.uriSigningKeys},
+		{"URL Key DS", fb.urlSigKeys},
+	} {
+		for _, record := range kind.records {
+			if !validFileName(record.Name) {
+				allErrs = append(allErrs, fmt.Sprintf(fileInvalidNameFormat, kind.name, record.Name))
+			} else if record.DataEncrypted == nil {
+				allErrs = append(allErrs, fmt.Sprintf("%s '%s': DataEncrypted is blank!", kind.name, record.Name))
+			}
+		}
+	}
+	return allErrs
+}
+
+// Close does nothing, as the File backend holds no connection.
+func (fb *FileBackend) Close() error {
+	return nil
+}
+
+// Ping checks that the backend directory is accessible.
+func (fb *FileBackend) Ping() error {
+	info, err := os.Stat(fb.cfg.Directory)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", fb.cfg.Directory)
+	}
+	return nil
+}
+
+// Fetch gets all of the keys from the backend directory.
+func (fb *FileBackend) Fetch() error {
+	var err error
+	sslDir := filepath.Join(fb.cfg.Directory, fileSSLKeysDir)
+	dses, err := listFileDir(sslDir)
+	if err != nil {
+		return err
+	}
+	fb.sslKeys = []fileRecord{}
+	for _, ds := range dses {
+		versions, err := listFileDir(filepath.Join(sslDir, ds))
+		if err != nil {
+			return err
+		}
+		for _, version := range versions {
+			data, err := os.ReadFile(filepath.Join(sslDir, ds, version))
+			if err != nil {
+				return fmt.Errorf("unable to read SSL key file: %w", err)
+			}
+			fb.sslKeys = append(fb.sslKeys, fileRecord{Name: ds, Version: version, DataEncrypted: data})
+		}
+	}
+
+	if fb.dnssecKeys, err = readFileRecords(filepath.Join(fb.cfg.Directory, fileDNSSecKeysDir)); err != nil {
+		return err
+	}
+	if fb.uriSigningKeys, err = readFileRecords(filepath.Join(fb.cfg.Directory, fileURISignKeysDir)); err != nil {
+		return err
+	}
+	if fb.urlSigKeys, err = readFileRecords(filepath.Join(fb.cfg.Directory, fileURLSigKeysDir)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetSSLKeys converts the backends internal key representation into the common representation (SSLKey).
+func (fb *FileBackend) GetSSLKeys() ([]SSLKey, error) {
+	keys := make([]SSLKey, len(fb.sslKeys))
+	for i, record := range fb.sslKeys {
+		if err := decryptInto(fb.cfg.AESKey, record.DataEncrypted, &keys[i].DeliveryServiceSSLKeys); err != nil {
+			return nil, fmt.Errorf("unable to decrypt into keys: %w", err)
+		}
+		keys[i].DeliveryService = record.Name
+		keys[i].Version = record.Version
+	}
+	return keys, nil
+}
+
+// SetSSLKeys takes in keys and converts & encrypts the data into the backends internal format.
+func (fb *FileBackend) SetSSLKeys(keys []SSLKey) error {
+	fb.sslKeys = make([]fileRecord, len(keys))
+	// the 'latest' version of a Delivery Service's keys must be encrypted
+	// identically to the numbered version it is a copy of, for Traffic Ops to
+	// recognize that version as the current one
+	encrypted := map[string][]fileRecord{}
+	for i, key := range keys {
+		data, err := json.Marshal(&key.DeliveryServiceSSLKeys)
+		if err != nil {
+			return fmt.Errorf("encrypt issue marshalling keys: %w", err)
+		}
+		var dat []byte
+		for _, record := range encrypted[key.DeliveryService] {
+			if plain, err := decrypt(record.DataEncrypted, fb.cfg.AESKey); err == nil && bytes.Equal(plain, data) {
+				dat = record.DataEncrypted
+				break
+			}
+		}
+		if dat == nil {
+			if dat, err = encrypt(data, fb.cfg.AESKey); err != nil {
+				return fmt.Errorf("encrypt error: %w", err)
+			}
+		}
+		fb.sslKeys[i] = fileRecord{Name: key.DeliveryService, Version: key.Version, DataEncrypted: dat}
+		encrypted[key.DeliveryService] = append(encrypted[key.DeliveryService], fb.sslKeys[i])
+	}
+	return nil
+}
+
+// GetDNSSecKeys converts the backends internal key representation into the common representation (DNSSecKey).
+func (fb *FileBackend) GetDNSSecKeys() ([]DNSSecKey, error) {
+	keys := make([]DNSSecKey, len(fb.dnssecKeys))
+	for i, record := range fb.dnssecKeys {
+		if err := decryptInto(fb.cfg.AESKey, record.DataEncrypted, &keys[i].DNSSECKeysTrafficVault); err != nil {
+			return nil, fmt.Errorf("unable to decrypt into keys: %w", err)
+		}
+		keys[i].CDN = record.Name
+	}
+	return keys, nil
+}
+
+// SetDNSSecKeys takes in keys and converts & encrypts the data into the backends internal format.
+func (fb *FileBackend) SetDNSSecKeys(keys []DNSSecKey) error {
+	fb.dnssecKeys = make([]fileRecord, len(keys))
+	for i, key := range keys {
+		record, err := encryptFileRecord(key.CDN, &key.DNSSECKeysTrafficVault, fb.cfg.AESKey)
+		if err != nil {
+			return err
+		}
+		fb.dnssecKeys[i] = record
+	}
+	return nil
+}
+
+// GetURISignKeys converts the backends internal key representation into the common representation (URISignKey).
+func (fb *FileBackend) GetURISignKeys() ([]URISignKey, error) {
+	keys := make([]URISignKey, len(fb.uriSigningKeys))
+	for i, record := range fb.uriSigningKeys {
+		if err := decryptInto(fb.cfg.AESKey, record.DataEncrypted, &keys[i].Keys); err != nil {
+			return nil, fmt.Errorf("unable to decrypt into keys: %w", err)
+		}
+		keys[i].DeliveryService = record.Name
+	}
+	return keys, nil
+}
+
+// SetURISignKeys takes in keys and converts & encrypts the data into the backends internal format.
+func (fb *FileBackend) SetURISignKeys(keys []URISignKey) error {
+	fb.uriSigningKeys = make([]fileRecord, len(keys))
+	for i, key := range keys {
+		record, err := encryptFileRecord(key.DeliveryService, &key.Keys, fb.cfg.AESKey)
+		if err != nil {
+			return err
+		}
+		fb.uriSigningKeys[i] = record
+	}
+	return nil
+}
+
+// GetURLSigKeys converts the backends internal key representation into the common representation (URLSigKey).
+func (fb *FileBackend) GetURLSigKeys() ([]URLSigKey, error) {
+	keys := make([]URLSigKey, len(fb.urlSigKeys))
+	for i, record := range fb.urlSigKeys {
+		if err := decryptInto(fb.cfg.AESKey, record.DataEncrypted, &keys[i].URLSigKeys); err != nil {
+			return nil, fmt.Errorf("unable to decrypt into keys: %w", err)
+		}
+		keys[i].DeliveryService = record.Name
+	}
+	return keys, nil
+}
+
+// SetURLSigKeys takes in keys and converts & encrypts the data into the backends internal format.
+func (fb *FileBackend) SetURLSigKeys(keys []URLSigKey) error {
+	fb.urlSigKeys = make([]fileRecord, len(keys))
+	for i, key := range keys {
+		record, err := encryptFileRecord(key.DeliveryService, &key.URLSigKeys, fb.cfg.AESKey)
+		if err != nil {
+			return err
+		}
+		fb.urlSigKeys[i] = record
+	}
+	return nil
+}
+
+func encryptFileRecord(name string, keys interface{}, aesKey []byte) (fileRecord, error) {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return fileRecord{}, fmt.Errorf("encrypt issue marshalling keys: %w", err)
+	}
+	dat, err := encrypt(data, aesKey)
+	if err != nil {
+		return fileRecord{}, fmt.Errorf("encrypt error: %w", err)
+	}
+	return fileRecord{Name: name, DataEncrypted: dat}, nil
+}
+
+// validFileName returns whether the given name can be used as a single path
+// element, as the file Traffic Vault backend requires.
+func validFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// listFileDir returns the names of the entries in the given directory, skipping
+// hidden (including temporary) files. A directory that doesn't exist is empty.
+func listFileDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read directory '%s': %w", dir, err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func readFileRecords(dir string) ([]fileRecord, error) {
+	names, err := listFileDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]fileRecord, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key file: %w", err)
+		}
+		records = append(records, fileRecord{Name: name, DataEncrypted: data})
+	}
+	return records, nil
+}
+
+func writeFileRecord(path string, record fileRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), fileDirMode); err != nil {
+		return fmt.Errorf("unable to create directory for '%s': %w", path, err)
+	}
+	if err := os.WriteFile(path, record.DataEncrypted, fileMode); err != nil {
+		return fmt.Errorf("unable to write key file '%s': %w", path, err)
+	}
+	return nil
+}
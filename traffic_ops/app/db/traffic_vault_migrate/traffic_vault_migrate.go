@@ -58,6 +58,7 @@ var (
 	}
 	riakBE RiakBackend = RiakBackend{}
 	pgBE   PGBackend   = PGBackend{}
+	fileBE FileBackend = FileBackend{}
 )
 
 func init() {
@@ -107,7 +108,7 @@ func init() {
 // supportBackends returns the backends available in this tool.
 func supportedBackends() []TVBackend {
 	return []TVBackend{
-		&riakBE, &pgBE,
+		&riakBE, &pgBE, &fileBE,
 	}
 }
 
@@ -200,3 +200,121 @@ func TestPGBackend(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// testFileBackend sets keys of each kind in the given FileBackend, checks that
+// they're read back unchanged, and that they're the same after being written
+// to and fetched from its directory.
+func testFileBackend(t *testing.T, fb *FileBackend) {
+	sslkey := SSLKey{
+		DeliveryServiceSSLKeys: tc.DeliveryServiceSSLKeys{
+			CDN:             "CDN-in-a-Box",
+			DeliveryService: "demo1",
+			Hostname:        "*.demo1.mycdn.ciab.test",
+			Key:             "demo1",
+			Version:         1,
+			Certificate: tc.DeliveryServiceSSLKeysCertificate{
+				Crt: "Y3J0",
+				Key: "a2V5",
+				CSR: "Y3Ny",
+			},
+		},
+		Version: "1",
+	}
+	set := jwk.NewSet()
+	_ = set.Set(`renewal_kid`, "h")
+	key, _ := jwk.New([]byte("foobar"))
+	set.Add(key)
+	uri := URISignKey{
+		DeliveryService: "defaultDS2",
+		Keys:            tc.JWKSMap{"defaultDS2": set},
+	}
+	url := URLSigKey{
+		DeliveryService: "url_sig_defaultDS2.config",
+		URLSigKeys:      map[string]string{"key0": "dvfYTOnrUKFKygadPyKeAy9YAGDHeGit"},
+	}
+	dnssec := DNSSecKey{
+		CDN: "dnssec-gen0",
+		DNSSECKeysTrafficVault: map[string]tc.DNSSECKeySetV11{
+			"defaultDS2": {
+				ZSK: []tc.DNSSECKeyV11{{Name: "defaultds2.test1.host.", TTLSeconds: 60, Status: "new", Public: "cHVibGlj", Private: "cHJpdmF0ZQ=="}},
+				KSK: []tc.DNSSECKeyV11{{Name: "defaultds2.test1.host.", TTLSeconds: 60, Status: "new", Public: "cHVibGlj", Private: "cHJpdmF0ZQ=="}},
+			},
+		},
+	}
+
+	if err := fb.SetSSLKeys([]SSLKey{sslkey}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fb.SetURISignKeys([]URISignKey{uri}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fb.SetURLSigKeys([]URLSigKey{url}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fb.SetDNSSecKeys([]DNSSecKey{dnssec}); err != nil {
+		t.Fatal(err)
+	}
+	if errs := fb.ValidateKey(); len(errs) > 0 {
+		t.Fatalf("expected no validation issues with filled struct got: %v\n", strings.Join(errs, ", "))
+	}
+
+	written := FileBackend{cfg: fb.cfg}
+	if err := fb.Insert(); err != nil {
+		t.Fatal(err)
+	}
+	if err := written.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	for _, backend := range []*FileBackend{fb, &written} {
+		sslKeys, err := backend.GetSSLKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sslKeys) != 1 || !reflect.DeepEqual(sslKeys[0], sslkey) {
+			t.Errorf("expected ssl key %+v, got %+v", sslkey, sslKeys)
+		}
+		uriKeys, err := backend.GetURISignKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(uriKeys) != 1 || !reflect.DeepEqual(uriKeys[0], uri) {
+			t.Errorf("expected uri key %+v, got %+v", uri, uriKeys)
+		}
+		urlKeys, err := backend.GetURLSigKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(urlKeys) != 1 || !reflect.DeepEqual(urlKeys[0], url) {
+			t.Errorf("expected url sig key %+v, got %+v", url, urlKeys)
+		}
+		dnssecKeys, err := backend.GetDNSSecKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dnssecKeys) != 1 || !reflect.DeepEqual(dnssecKeys[0], dnssec) {
+			t.Errorf("expected dnssec key %+v, got %+v", dnssec, dnssecKeys)
+		}
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	data := make([]byte, 32)
+	for i, _ := range data {
+		data[i] = byte('a' + rune(test.RandIntn(26)))
+	}
+	fb := FileBackend{
+		cfg: FileConfig{
+			Directory: t.TempDir(),
+			AESKey:    data,
+		},
+	}
+	if err := fb.Start(); err != nil {
+		t.Fatal(err)
+	}
+	testFileBackend(t, &fb)
+
+	fb.urlSigKeys[0].Name = "../url_sig"
+	if errs := fb.ValidateKey(); len(errs) != 1 {
+		t.Fatalf("expected one validation issue with a key name that isn't a file name, got: %v", errs)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with this
+ * work for additional information regarding copyright ownership.  The ASF
+ * licenses this file to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.  See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+-- data is NULL for SSL keys, whose versions are stored in the sslkey table;
+-- their rows here only record which numbered version is current.
+CREATE TABLE IF NOT EXISTS public.secret_version (
+  secret_type text NOT NULL,
+  name text NOT NULL,
+  version bigint NOT NULL,
+  data bytea,
+  is_current boolean NOT NULL DEFAULT false,
+  last_updated timestamp with time zone NOT NULL DEFAULT now(),
+  CONSTRAINT secret_version_pkey PRIMARY KEY (secret_type, name, version)
+);
+
+ALTER TABLE public.secret_version OWNER TO traffic_vault;
+
+DROP TRIGGER IF EXISTS secret_version_last_updated ON public.secret_version;
+CREATE TRIGGER secret_version_last_updated
+BEFORE UPDATE ON public.secret_version
+FOR EACH ROW EXECUTE PROCEDURE on_update_current_timestamp_last_updated();
+
+-- Until now the numbered SSL key version that was current was only implied by
+-- having been copied into 'latest', so that is the one marked current here.
+-- Keys that have since been re-encrypted no longer match byte-for-byte; none of
+-- their versions is marked current until their keys are next stored or promoted.
+INSERT INTO public.secret_version (secret_type, name, version, data, is_current)
+SELECT 'sslkeys', s.deliveryservice, CAST(s.version AS bigint), NULL, bool_or(l.deliveryservice IS NOT NULL)
+FROM public.sslkey AS s
+LEFT JOIN public.sslkey AS l ON l.deliveryservice = s.deliveryservice AND l.version = 'latest' AND l.data = s.data
+WHERE s.version ~ '^[0-9]{1,18}$'
+GROUP BY s.deliveryservice, s.version
+ON CONFLICT DO NOTHING;
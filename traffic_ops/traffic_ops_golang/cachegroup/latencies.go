@@ -0,0 +1,224 @@
+package cachegroup
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/dbhelpers"
+
+	"github.com/lib/pq"
+)
+
+// LatencySmoothingFactor is the weight of a new latency measurement in the
+// exponentially weighted moving average of a Cache Group's latency to a parent
+// Cache Group. Lower values make the latency less sensitive to outliers, but
+// slower to react to real changes.
+const LatencySmoothingFactor = 0.3
+
+// DefaultLatencyMaxAge is the default maximum age of Cache Group latencies
+// returned by GetLatencies. Older latencies are likely to be from Cache Groups
+// whose servers no longer measure them, and aren't returned so they don't
+// affect parent preference forever.
+const DefaultLatencyMaxAge = 24 * time.Hour
+
+const readLatenciesQuery = `
+SELECT
+  cachegroup,
+  parent_cachegroup,
+  latency_ms,
+  samples,
+  last_updated
+FROM cachegroup_latency
+`
+
+// upsertLatencyQuery inserts a measurement, or smooths it into the existing latency.
+// If the existing latency is older than the max age, it's replaced rather than
+// smoothed, because it no longer reflects the network.
+const upsertLatencyQuery = `
+INSERT INTO cachegroup_latency (cachegroup, parent_cachegroup, latency_ms)
+VALUES ($1, $2, $3)
+ON CONFLICT (cachegroup, parent_cachegroup) DO UPDATE SET
+  latency_ms = CASE
+    WHEN cachegroup_latency.last_updated < now() - make_interval(secs => $5) THEN EXCLUDED.latency_ms
+    ELSE cachegroup_latency.latency_ms * (1 - $4) + EXCLUDED.latency_ms * $4
+  END,
+  samples = CASE
+    WHEN cachegroup_latency.last_updated < now() - make_interval(secs => $5) THEN 1
+    ELSE cachegroup_latency.samples + 1
+  END
+RETURNING cachegroup, parent_cachegroup, latency_ms, samples, last_updated
+`
+
+// GetLatencies is the handler for GET requests to /cachegroup_latencies.
+func GetLatencies(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, []string{"maxAgeSeconds"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	maxAge := DefaultLatencyMaxAge
+	if maxAgeStr, ok := inf.Params["maxAgeSeconds"]; ok {
+		maxAge = time.Duration(inf.IntParams["maxAgeSeconds"]) * time.Second
+		if maxAge <= 0 {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, errors.New("maxAgeSeconds must be positive, got '"+maxAgeStr+"'"), nil)
+			return
+		}
+		delete(inf.Params, "maxAgeSeconds")
+	}
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"cachegroup":       {Column: "cachegroup", Checker: nil},
+		"parentCachegroup": {Column: "parent_cachegroup", Checker: nil},
+	}
+	if _, ok := inf.Params["orderby"]; !ok {
+		inf.Params["orderby"] = "cachegroup"
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+	maxAgeClause := "last_updated > now() - make_interval(secs => :max_age_seconds)"
+	if where == "" {
+		where = "WHERE " + maxAgeClause
+	} else {
+		where += " AND " + maxAgeClause
+	}
+	queryValues["max_age_seconds"] = int64(maxAge / time.Second)
+
+	rows, err := inf.Tx.NamedQuery(readLatenciesQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("querying cachegroup latencies: "+err.Error()))
+		return
+	}
+	defer log.Close(rows, "closing cachegroup latency rows")
+
+	latencies := []tc.CacheGroupLatency{}
+	for rows.Next() {
+		latency := tc.CacheGroupLatency{}
+		if err := rows.Scan(&latency.CacheGroup, &latency.ParentCacheGroup, &latency.LatencyMS, &latency.Samples, &latency.LastUpdated); err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("scanning cachegroup latencies: "+err.Error()))
+			return
+		}
+		latencies = append(latencies, latency)
+	}
+	if err := rows.Err(); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("iterating cachegroup latencies: "+err.Error()))
+		return
+	}
+	api.WriteResp(w, r, latencies)
+}
+
+// validateLatenciesRequest returns a user error if the request is malformed.
+func validateLatenciesRequest(req tc.CacheGroupLatenciesRequest) error {
+	if req.CacheGroup == "" {
+		return errors.New("cachegroup is required")
+	}
+	if len(req.Measurements) == 0 {
+		return errors.New("measurements must not be empty")
+	}
+	errs := []error{}
+	for i, measurement := range req.Measurements {
+		if measurement.ParentCacheGroup == "" {
+			errs = append(errs, fmt.Errorf("measurements[%d]: parentCachegroup is required", i))
+		} else if measurement.ParentCacheGroup == req.CacheGroup {
+			errs = append(errs, fmt.Errorf("measurements[%d]: parentCachegroup must not be the cachegroup itself", i))
+		}
+		if math.IsNaN(measurement.LatencyMS) || math.IsInf(measurement.LatencyMS, 0) || measurement.LatencyMS < 0 {
+			errs = append(errs, fmt.Errorf("measurements[%d]: latencyMs must be a non-negative number", i))
+		}
+	}
+	return util.JoinErrs(errs)
+}
+
+// PostLatencies is the handler for POST requests to /cachegroup_latencies,
+// which cache servers use to report the latencies they measured to their
+// parent Cache Groups.
+func PostLatencies(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	req := tc.CacheGroupLatenciesRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, errors.New("malformed JSON: "+err.Error()), nil)
+		return
+	}
+	if err := validateLatenciesRequest(req); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	names := []string{req.CacheGroup}
+	for _, measurement := range req.Measurements {
+		names = append(names, measurement.ParentCacheGroup)
+	}
+	existing := map[string]struct{}{}
+	rows, err := inf.Tx.Tx.Query(`SELECT name FROM cachegroup WHERE name = ANY($1)`, pq.Array(names))
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("querying cachegroups: "+err.Error()))
+		return
+	}
+	for rows.Next() {
+		name := ""
+		if err := rows.Scan(&name); err != nil {
+			log.Close(rows, "closing cachegroup rows")
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("scanning cachegroups: "+err.Error()))
+			return
+		}
+		existing[name] = struct{}{}
+	}
+	log.Close(rows, "closing cachegroup rows")
+	for _, name := range names {
+		if _, ok := existing[name]; !ok {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, errors.New("cachegroup '"+name+"' does not exist"), nil)
+			return
+		}
+	}
+
+	latencies := []tc.CacheGroupLatency{}
+	for _, measurement := range req.Measurements {
+		latency := tc.CacheGroupLatency{}
+		err := inf.Tx.Tx.QueryRow(upsertLatencyQuery, req.CacheGroup, measurement.ParentCacheGroup, measurement.LatencyMS, LatencySmoothingFactor, int64(DefaultLatencyMaxAge/time.Second)).
+			Scan(&latency.CacheGroup, &latency.ParentCacheGroup, &latency.LatencyMS, &latency.Samples, &latency.LastUpdated)
+		if err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("upserting cachegroup latency: "+err.Error()))
+			return
+		}
+		latencies = append(latencies, latency)
+	}
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "Cache Group '"+req.CacheGroup+"' reported "+strconv.Itoa(len(latencies))+" latencies", latencies)
+}
@@ -0,0 +1,88 @@
+package cachegroup
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+func TestValidateLatenciesRequest(t *testing.T) {
+	testCases := []struct {
+		name        string
+		req         tc.CacheGroupLatenciesRequest
+		errContains string
+	}{
+		{
+			name: "valid",
+			req: tc.CacheGroupLatenciesRequest{CacheGroup: "edge-east", Measurements: []tc.CacheGroupLatencyMeasurement{
+				{ParentCacheGroup: "mid-east", LatencyMS: 4.2},
+				{ParentCacheGroup: "mid-west", LatencyMS: 0},
+			}},
+		},
+		{
+			name:        "missing cachegroup",
+			req:         tc.CacheGroupLatenciesRequest{Measurements: []tc.CacheGroupLatencyMeasurement{{ParentCacheGroup: "mid-east", LatencyMS: 1}}},
+			errContains: "cachegroup is required",
+		},
+		{
+			name:        "no measurements",
+			req:         tc.CacheGroupLatenciesRequest{CacheGroup: "edge-east"},
+			errContains: "measurements must not be empty",
+		},
+		{
+			name:        "missing parent",
+			req:         tc.CacheGroupLatenciesRequest{CacheGroup: "edge-east", Measurements: []tc.CacheGroupLatencyMeasurement{{LatencyMS: 1}}},
+			errContains: "parentCachegroup is required",
+		},
+		{
+			name:        "self parent",
+			req:         tc.CacheGroupLatenciesRequest{CacheGroup: "edge-east", Measurements: []tc.CacheGroupLatencyMeasurement{{ParentCacheGroup: "edge-east", LatencyMS: 1}}},
+			errContains: "must not be the cachegroup itself",
+		},
+		{
+			name:        "negative latency",
+			req:         tc.CacheGroupLatenciesRequest{CacheGroup: "edge-east", Measurements: []tc.CacheGroupLatencyMeasurement{{ParentCacheGroup: "mid-east", LatencyMS: -1}}},
+			errContains: "latencyMs must be a non-negative number",
+		},
+		{
+			name:        "NaN latency",
+			req:         tc.CacheGroupLatenciesRequest{CacheGroup: "edge-east", Measurements: []tc.CacheGroupLatencyMeasurement{{ParentCacheGroup: "mid-east", LatencyMS: math.NaN()}}},
+			errContains: "latencyMs must be a non-negative number",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateLatenciesRequest(tc.req)
+			if tc.errContains == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.errContains) {
+				t.Errorf("expected error containing '%s', got %v", tc.errContains, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,465 @@
+package cdni
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/config"
+
+	"github.com/lib/pq"
+)
+
+const (
+	advertisedServersQuery = `
+SELECT cg.name, co.latitude, co.longitude, s.id,
+	ARRAY(SELECT ssc.server_capability FROM server_server_capability AS ssc WHERE ssc.server = s.id)
+FROM server AS s
+JOIN cachegroup AS cg ON cg.id = s.cachegroup
+JOIN type AS cgt ON cgt.id = cg.type
+JOIN cdn AS c ON c.id = s.cdn_id
+JOIN status AS st ON st.id = s.status
+LEFT JOIN coordinate AS co ON co.id = cg.coordinate
+WHERE c.name = ANY($1) AND cgt.name = $2 AND st.name = ANY($3)`
+
+	advertisedASNsQuery = `SELECT cg.name, a.asn FROM asn AS a JOIN cachegroup AS cg ON cg.id = a.cachegroup`
+
+	advertisedDeliveryServicesQuery = `
+SELECT ds.xml_id, t.name, ds.protocol, ds.topology, COALESCE(ds.required_capabilities, '{}'),
+	ARRAY(SELECT DISTINCT o.protocol::text FROM origin AS o WHERE o.deliveryservice = ds.id),
+	ARRAY(SELECT dss.server FROM deliveryservice_server AS dss WHERE dss.deliveryservice = ds.id)
+FROM deliveryservice AS ds
+JOIN type AS t ON t.id = ds.type
+JOIN cdn AS c ON c.id = ds.cdn_id
+WHERE c.name = ANY($1) AND ds.active = $2`
+
+	topologyCacheGroupsQuery = `SELECT topology, cachegroup FROM topology_cachegroup`
+)
+
+// These are the FCI capability types, defined by RFC 8008, which are generated
+// from Traffic Ops data rather than stored in the cdni_* tables.
+const (
+	FciDeliveryProtocol    SupportedCapabilities = "FCI.DeliveryProtocol"
+	FciAcquisitionProtocol SupportedCapabilities = "FCI.AcquisitionProtocol"
+	FciRedirectionMode     SupportedCapabilities = "FCI.RedirectionMode"
+	FciMetadata            SupportedCapabilities = "FCI.Metadata"
+)
+
+// These are the protocols from the CDNI Protocol Types registry of RFC 8006
+// which Traffic Control caches can use.
+const (
+	ProtocolHTTP  = "http/1.1"
+	ProtocolHTTPS = "https/1.1"
+)
+
+// These are the redirection modes, defined by RFC 7336, which Traffic Router
+// supports. Recursive redirection is not supported.
+const (
+	RedirectionModeDNSIterative  = "DNS-I"
+	RedirectionModeHTTPIterative = "HTTP-I"
+)
+
+// Coordinates is a footprint type that is not registered with IANA, whose
+// values are the "latitude,longitude" locations of the dCDN's cache groups.
+const Coordinates FootprintType = "coordinates"
+
+// DeliveryProtocolCapabilityValue is the value of an FCI.DeliveryProtocol capability.
+type DeliveryProtocolCapabilityValue struct {
+	DeliveryProtocols []string `json:"delivery-protocols"`
+}
+
+// AcquisitionProtocolCapabilityValue is the value of an FCI.AcquisitionProtocol capability.
+type AcquisitionProtocolCapabilityValue struct {
+	AcquisitionProtocols []string `json:"acquisition-protocols"`
+}
+
+// RedirectionModeCapabilityValue is the value of an FCI.RedirectionMode capability.
+type RedirectionModeCapabilityValue struct {
+	RedirectionModes []string `json:"redirection-modes"`
+}
+
+// MetadataCapabilityValue is the value of an FCI.Metadata capability.
+type MetadataCapabilityValue struct {
+	Metadata []string `json:"metadata"`
+}
+
+// advertisedCacheGroup is an edge cache group with servers that can serve
+// content for the advertised CDNs.
+type advertisedCacheGroup struct {
+	Name      string
+	Latitude  *float64
+	Longitude *float64
+	ASNs      []int
+	Servers   map[int]map[string]struct{}
+}
+
+// advertisedDeliveryService is an active Delivery Service of an advertised CDN.
+type advertisedDeliveryService struct {
+	XMLID                string
+	Type                 tc.DSType
+	Protocol             *int
+	Topology             *string
+	RequiredCapabilities []string
+	OriginProtocols      []string
+	AssignedServers      []int
+}
+
+// getAdvertisedCapabilities generates the FCI capabilities of the CDNs given
+// by the configuration from their cache groups, servers and Delivery Services.
+func getAdvertisedCapabilities(tx *sql.Tx, cfg config.CdniAdvertisementConf) ([]Capability, error) {
+	cacheGroups, err := getAdvertisedCacheGroups(tx, cfg.CDNs)
+	if err != nil {
+		return nil, err
+	}
+	dses, err := getAdvertisedDeliveryServices(tx, cfg.CDNs)
+	if err != nil {
+		return nil, err
+	}
+	topologies, err := getTopologyCacheGroups(tx)
+	if err != nil {
+		return nil, err
+	}
+	return buildAdvertisedCapabilities(cacheGroups, dses, topologies, cfg.IncludeCoordinates), nil
+}
+
+func getAdvertisedCacheGroups(tx *sql.Tx, cdns []string) (map[string]*advertisedCacheGroup, error) {
+	rows, err := tx.Query(advertisedServersQuery, pq.Array(cdns), tc.CacheGroupEdgeTypeName, pq.Array([]string{string(tc.CacheStatusOnline), string(tc.CacheStatusReported)}))
+	if err != nil {
+		return nil, fmt.Errorf("querying advertised servers: %w", err)
+	}
+	defer log.Close(rows, "closing advertised servers query")
+
+	cacheGroups := map[string]*advertisedCacheGroup{}
+	for rows.Next() {
+		var name string
+		var lat, lon *float64
+		var serverID int
+		var capabilities []string
+		if err := rows.Scan(&name, &lat, &lon, &serverID, pq.Array(&capabilities)); err != nil {
+			return nil, fmt.Errorf("scanning advertised servers: %w", err)
+		}
+		cg, ok := cacheGroups[name]
+		if !ok {
+			cg = &advertisedCacheGroup{Name: name, Latitude: lat, Longitude: lon, Servers: map[int]map[string]struct{}{}}
+			cacheGroups[name] = cg
+		}
+		cg.Servers[serverID] = map[string]struct{}{}
+		for _, capability := range capabilities {
+			cg.Servers[serverID][capability] = struct{}{}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over advertised servers: %w", err)
+	}
+
+	asnRows, err := tx.Query(advertisedASNsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("querying cache group ASNs: %w", err)
+	}
+	defer log.Close(asnRows, "closing cache group ASNs query")
+	for asnRows.Next() {
+		var name string
+		var asn int
+		if err := asnRows.Scan(&name, &asn); err != nil {
+			return nil, fmt.Errorf("scanning cache group ASNs: %w", err)
+		}
+		if cg, ok := cacheGroups[name]; ok {
+			cg.ASNs = append(cg.ASNs, asn)
+		}
+	}
+	if err := asnRows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over cache group ASNs: %w", err)
+	}
+	return cacheGroups, nil
+}
+
+func getAdvertisedDeliveryServices(tx *sql.Tx, cdns []string) ([]advertisedDeliveryService, error) {
+	rows, err := tx.Query(advertisedDeliveryServicesQuery, pq.Array(cdns), tc.DSActiveStateActive)
+	if err != nil {
+		return nil, fmt.Errorf("querying advertised delivery services: %w", err)
+	}
+	defer log.Close(rows, "closing advertised delivery services query")
+
+	dses := []advertisedDeliveryService{}
+	for rows.Next() {
+		ds := advertisedDeliveryService{}
+		var servers []int64
+		if err := rows.Scan(&ds.XMLID, &ds.Type, &ds.Protocol, &ds.Topology, pq.Array(&ds.RequiredCapabilities), pq.Array(&ds.OriginProtocols), pq.Array(&servers)); err != nil {
+			return nil, fmt.Errorf("scanning advertised delivery services: %w", err)
+		}
+		for _, server := range servers {
+			ds.AssignedServers = append(ds.AssignedServers, int(server))
+		}
+		dses = append(dses, ds)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over advertised delivery services: %w", err)
+	}
+	return dses, nil
+}
+
+func getTopologyCacheGroups(tx *sql.Tx) (map[string][]string, error) {
+	rows, err := tx.Query(topologyCacheGroupsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("querying topology cache groups: %w", err)
+	}
+	defer log.Close(rows, "closing topology cache groups query")
+
+	topologies := map[string][]string{}
+	for rows.Next() {
+		var topology, cacheGroup string
+		if err := rows.Scan(&topology, &cacheGroup); err != nil {
+			return nil, fmt.Errorf("scanning topology cache groups: %w", err)
+		}
+		topologies[topology] = append(topologies[topology], cacheGroup)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating over topology cache groups: %w", err)
+	}
+	return topologies, nil
+}
+
+// buildAdvertisedCapabilities generates the FCI capabilities of the given
+// Delivery Services. The footprint of each capability is made of the cache
+// groups that can serve a Delivery Service using it: the cache groups of its
+// Topology, or of its assigned servers if it has none, that have a server with
+// all of its required capabilities. Values with the same footprint are
+// advertised together.
+func buildAdvertisedCapabilities(cacheGroups map[string]*advertisedCacheGroup, dses []advertisedDeliveryService, topologies map[string][]string, includeCoordinates bool) []Capability {
+	deliveryProtocols := map[string]map[string]struct{}{}
+	acquisitionProtocols := map[string]map[string]struct{}{}
+	redirectionModes := map[string]map[string]struct{}{}
+	for _, ds := range dses {
+		dsCacheGroups := deliveryServiceCacheGroups(ds, cacheGroups, topologies)
+		if len(dsCacheGroups) == 0 {
+			continue
+		}
+		for _, protocol := range deliveryProtocolsOf(ds) {
+			addCacheGroups(deliveryProtocols, protocol, dsCacheGroups)
+		}
+		for _, protocol := range ds.OriginProtocols {
+			switch protocol {
+			case "http":
+				addCacheGroups(acquisitionProtocols, ProtocolHTTP, dsCacheGroups)
+			case "https":
+				addCacheGroups(acquisitionProtocols, ProtocolHTTPS, dsCacheGroups)
+			}
+		}
+		if ds.Type.IsDNS() {
+			addCacheGroups(redirectionModes, RedirectionModeDNSIterative, dsCacheGroups)
+		} else if ds.Type.IsHTTP() {
+			addCacheGroups(redirectionModes, RedirectionModeHTTPIterative, dsCacheGroups)
+		}
+	}
+
+	capabilities := []Capability{}
+	for _, group := range groupByCacheGroups(deliveryProtocols) {
+		capabilities = append(capabilities, Capability{
+			CapabilityType:  FciDeliveryProtocol,
+			CapabilityValue: DeliveryProtocolCapabilityValue{DeliveryProtocols: group.values},
+			Footprints:      footprintsOf(group.cacheGroups, cacheGroups, includeCoordinates),
+		})
+	}
+	for _, group := range groupByCacheGroups(acquisitionProtocols) {
+		capabilities = append(capabilities, Capability{
+			CapabilityType:  FciAcquisitionProtocol,
+			CapabilityValue: AcquisitionProtocolCapabilityValue{AcquisitionProtocols: group.values},
+			Footprints:      footprintsOf(group.cacheGroups, cacheGroups, includeCoordinates),
+		})
+	}
+	for _, group := range groupByCacheGroups(redirectionModes) {
+		capabilities = append(capabilities, Capability{
+			CapabilityType:  FciRedirectionMode,
+			CapabilityValue: RedirectionModeCapabilityValue{RedirectionModes: group.values},
+			Footprints:      footprintsOf(group.cacheGroups, cacheGroups, includeCoordinates),
+		})
+	}
+
+	if len(cacheGroups) > 0 {
+		all := make([]string, 0, len(cacheGroups))
+		for name := range cacheGroups {
+			all = append(all, name)
+		}
+		capabilities = append(capabilities, Capability{
+			CapabilityType:  FciMetadata,
+			CapabilityValue: MetadataCapabilityValue{Metadata: advertisedMetadataTypes()},
+			Footprints:      footprintsOf(all, cacheGroups, includeCoordinates),
+		})
+	}
+	return capabilities
+}
+
+// advertisedMetadataTypes returns the generic metadata types this CDN accepts:
+// the requested capacity limits of capacity requests, and the metadata
+// translated into Delivery Service requests.
+func advertisedMetadataTypes() []string {
+	types := []string{string(MiRequestedCapacityLimits)}
+	for _, metadataType := range translatedMetadataTypes {
+		types = append(types, string(metadataType))
+	}
+	return types
+}
+
+// deliveryServiceCacheGroups returns the names of the advertised cache groups
+// that can serve the given Delivery Service.
+func deliveryServiceCacheGroups(ds advertisedDeliveryService, cacheGroups map[string]*advertisedCacheGroup, topologies map[string][]string) []string {
+	names := []string{}
+	if ds.Topology != nil && *ds.Topology != "" {
+		for _, name := range topologies[*ds.Topology] {
+			cg, ok := cacheGroups[name]
+			if !ok {
+				continue
+			}
+			for _, capabilities := range cg.Servers {
+				if hasCapabilities(capabilities, ds.RequiredCapabilities) {
+					names = append(names, name)
+					break
+				}
+			}
+		}
+		return names
+	}
+
+	assigned := map[int]struct{}{}
+	for _, server := range ds.AssignedServers {
+		assigned[server] = struct{}{}
+	}
+	for name, cg := range cacheGroups {
+		for server, capabilities := range cg.Servers {
+			if _, ok := assigned[server]; ok && hasCapabilities(capabilities, ds.RequiredCapabilities) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+func hasCapabilities(capabilities map[string]struct{}, required []string) bool {
+	for _, capability := range required {
+		if _, ok := capabilities[capability]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func deliveryProtocolsOf(ds advertisedDeliveryService) []string {
+	if ds.Protocol == nil {
+		return []string{ProtocolHTTP}
+	}
+	switch *ds.Protocol {
+	case tc.DSProtocolHTTPS:
+		return []string{ProtocolHTTPS}
+	case tc.DSProtocolHTTPAndHTTPS, tc.DSProtocolHTTPToHTTPS:
+		return []string{ProtocolHTTP, ProtocolHTTPS}
+	}
+	return []string{ProtocolHTTP}
+}
+
+func addCacheGroups(capabilityCacheGroups map[string]map[string]struct{}, value string, cacheGroups []string) {
+	if capabilityCacheGroups[value] == nil {
+		capabilityCacheGroups[value] = map[string]struct{}{}
+	}
+	for _, name := range cacheGroups {
+		capabilityCacheGroups[value][name] = struct{}{}
+	}
+}
+
+type cacheGroupValues struct {
+	cacheGroups []string
+	values      []string
+}
+
+// groupByCacheGroups groups the capability values that can be used in the
+// same cache groups.
+func groupByCacheGroups(capabilityCacheGroups map[string]map[string]struct{}) []cacheGroupValues {
+	values := make([]string, 0, len(capabilityCacheGroups))
+	for value := range capabilityCacheGroups {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	groups := []cacheGroupValues{}
+	groupIndex := map[string]int{}
+	for _, value := range values {
+		names := make([]string, 0, len(capabilityCacheGroups[value]))
+		for name := range capabilityCacheGroups[value] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		key := strings.Join(names, "\x00")
+		if i, ok := groupIndex[key]; ok {
+			groups[i].values = append(groups[i].values, value)
+			continue
+		}
+		groupIndex[key] = len(groups)
+		groups = append(groups, cacheGroupValues{cacheGroups: names, values: []string{value}})
+	}
+	return groups
+}
+
+// footprintsOf returns the footprints of the given cache groups, which are
+// their ASNs and, optionally, their coordinates.
+func footprintsOf(names []string, cacheGroups map[string]*advertisedCacheGroup, includeCoordinates bool) []Footprint {
+	asns := map[int]struct{}{}
+	coordinates := map[string]struct{}{}
+	for _, name := range names {
+		cg, ok := cacheGroups[name]
+		if !ok {
+			continue
+		}
+		for _, asn := range cg.ASNs {
+			asns[asn] = struct{}{}
+		}
+		if cg.Latitude != nil && cg.Longitude != nil {
+			coordinates[strconv.FormatFloat(*cg.Latitude, 'f', -1, 64)+","+strconv.FormatFloat(*cg.Longitude, 'f', -1, 64)] = struct{}{}
+		}
+	}
+
+	footprints := []Footprint{}
+	if len(asns) > 0 {
+		sortedASNs := make([]int, 0, len(asns))
+		for asn := range asns {
+			sortedASNs = append(sortedASNs, asn)
+		}
+		sort.Ints(sortedASNs)
+		values := make([]string, 0, len(sortedASNs))
+		for _, asn := range sortedASNs {
+			values = append(values, "as"+strconv.Itoa(asn))
+		}
+		footprints = append(footprints, Footprint{FootprintType: Asn, FootprintValue: values})
+	}
+	if includeCoordinates && len(coordinates) > 0 {
+		values := make([]string, 0, len(coordinates))
+		for coordinate := range coordinates {
+			values = append(values, coordinate)
+		}
+		sort.Strings(values)
+		footprints = append(footprints, Footprint{FootprintType: Coordinates, FootprintValue: values})
+	}
+	return footprints
+}
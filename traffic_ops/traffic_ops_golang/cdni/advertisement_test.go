@@ -0,0 +1,115 @@
+package cdni
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestBuildAdvertisedCapabilities(t *testing.T) {
+	lat, lon := 40.0, -105.5
+	cacheGroups := map[string]*advertisedCacheGroup{
+		"edge1": {
+			Name:      "edge1",
+			Latitude:  &lat,
+			Longitude: &lon,
+			ASNs:      []int{200, 100},
+			Servers:   map[int]map[string]struct{}{1: {"RAM": {}}},
+		},
+		"edge2": {
+			Name:    "edge2",
+			ASNs:    []int{300},
+			Servers: map[int]map[string]struct{}{2: {}},
+		},
+	}
+	topologies := map[string][]string{"top": {"mid", "edge1", "edge2"}}
+	dses := []advertisedDeliveryService{
+		{
+			XMLID:           "http-topology",
+			Type:            tc.DSTypeHTTP,
+			Protocol:        util.IntPtr(tc.DSProtocolHTTP),
+			Topology:        util.StrPtr("top"),
+			OriginProtocols: []string{"http"},
+		},
+		{
+			XMLID:                "https-ram",
+			Type:                 tc.DSTypeDNS,
+			Protocol:             util.IntPtr(tc.DSProtocolHTTPS),
+			Topology:             util.StrPtr("top"),
+			RequiredCapabilities: []string{"RAM"},
+			OriginProtocols:      []string{"https"},
+		},
+		{
+			XMLID:           "unassigned",
+			Type:            tc.DSTypeHTTP,
+			Protocol:        util.IntPtr(tc.DSProtocolHTTPAndHTTPS),
+			OriginProtocols: []string{"https"},
+		},
+	}
+
+	bothFootprints := []Footprint{{FootprintType: Asn, FootprintValue: []string{"as100", "as200", "as300"}}}
+	edge1Footprints := []Footprint{{FootprintType: Asn, FootprintValue: []string{"as100", "as200"}}}
+	expected := []Capability{
+		{CapabilityType: FciDeliveryProtocol, CapabilityValue: DeliveryProtocolCapabilityValue{DeliveryProtocols: []string{ProtocolHTTP}}, Footprints: bothFootprints},
+		{CapabilityType: FciDeliveryProtocol, CapabilityValue: DeliveryProtocolCapabilityValue{DeliveryProtocols: []string{ProtocolHTTPS}}, Footprints: edge1Footprints},
+		{CapabilityType: FciAcquisitionProtocol, CapabilityValue: AcquisitionProtocolCapabilityValue{AcquisitionProtocols: []string{ProtocolHTTP}}, Footprints: bothFootprints},
+		{CapabilityType: FciAcquisitionProtocol, CapabilityValue: AcquisitionProtocolCapabilityValue{AcquisitionProtocols: []string{ProtocolHTTPS}}, Footprints: edge1Footprints},
+		{CapabilityType: FciRedirectionMode, CapabilityValue: RedirectionModeCapabilityValue{RedirectionModes: []string{RedirectionModeDNSIterative}}, Footprints: edge1Footprints},
+		{CapabilityType: FciRedirectionMode, CapabilityValue: RedirectionModeCapabilityValue{RedirectionModes: []string{RedirectionModeHTTPIterative}}, Footprints: bothFootprints},
+		{CapabilityType: FciMetadata, CapabilityValue: MetadataCapabilityValue{Metadata: []string{
+			"MI.RequestedCapacityLimits", "MI.SourceMetadata", "MI.LocationACL", "MI.TimeWindowACL", "MI.ProtocolACL",
+			"MI.DeliveryAuthorization", "MI.Cache", "MI.CachePolicy", "MI.UriSigning", "MI.Grouping",
+		}}, Footprints: bothFootprints},
+	}
+	actual := buildAdvertisedCapabilities(cacheGroups, dses, topologies, false)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected capabilities %+v, got %+v", expected, actual)
+	}
+
+	actual = buildAdvertisedCapabilities(cacheGroups, dses[:1], topologies, true)
+	if len(actual) != 4 {
+		t.Fatalf("expected 4 capabilities, got %+v", actual)
+	}
+	expectedFootprints := []Footprint{
+		{FootprintType: Asn, FootprintValue: []string{"as100", "as200", "as300"}},
+		{FootprintType: Coordinates, FootprintValue: []string{"40,-105.5"}},
+	}
+	if !reflect.DeepEqual(expectedFootprints, actual[0].Footprints) {
+		t.Errorf("expected footprints %+v, got %+v", expectedFootprints, actual[0].Footprints)
+	}
+}
+
+func TestBuildAdvertisedCapabilitiesGroupsValues(t *testing.T) {
+	cacheGroups := map[string]*advertisedCacheGroup{
+		"edge": {Name: "edge", ASNs: []int{1}, Servers: map[int]map[string]struct{}{1: {}}},
+	}
+	dses := []advertisedDeliveryService{
+		{XMLID: "ds", Type: tc.DSTypeHTTP, Protocol: util.IntPtr(tc.DSProtocolHTTPToHTTPS), AssignedServers: []int{1}},
+	}
+	actual := buildAdvertisedCapabilities(cacheGroups, dses, nil, false)
+	expected := DeliveryProtocolCapabilityValue{DeliveryProtocols: []string{ProtocolHTTP, ProtocolHTTPS}}
+	if len(actual) == 0 || !reflect.DeepEqual(expected, actual[0].CapabilityValue) {
+		t.Errorf("expected the first capability value to be %+v, got %+v", expected, actual)
+	}
+}
@@ -0,0 +1,704 @@
+package cdni
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/deliveryservice"
+	dsrequest "github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/deliveryservice/request"
+)
+
+// These are the RFC 8006 (and SVA) generic metadata types that are translated
+// into Delivery Service requests.
+const (
+	MiSourceMetadata        SupportedGenericMetadataType = "MI.SourceMetadata"
+	MiLocationACL           SupportedGenericMetadataType = "MI.LocationACL"
+	MiTimeWindowACL         SupportedGenericMetadataType = "MI.TimeWindowACL"
+	MiProtocolACL           SupportedGenericMetadataType = "MI.ProtocolACL"
+	MiDeliveryAuthorization SupportedGenericMetadataType = "MI.DeliveryAuthorization"
+	MiCache                 SupportedGenericMetadataType = "MI.Cache"
+	MiCachePolicy           SupportedGenericMetadataType = "MI.CachePolicy"
+	MiURISigning            SupportedGenericMetadataType = "MI.UriSigning"
+	MiGrouping              SupportedGenericMetadataType = "MI.Grouping"
+)
+
+// translatedMetadataTypes are the generic metadata types translated into
+// Delivery Service requests, in the order they're advertised.
+var translatedMetadataTypes = []SupportedGenericMetadataType{
+	MiSourceMetadata,
+	MiLocationACL,
+	MiTimeWindowACL,
+	MiProtocolACL,
+	MiDeliveryAuthorization,
+	MiCache,
+	MiCachePolicy,
+	MiURISigning,
+	MiGrouping,
+}
+
+// These are the actions of the rules of RFC 8006 access control lists.
+const (
+	ACLActionAllow = "allow"
+	ACLActionDeny  = "deny"
+)
+
+// untranslatedError is returned when valid metadata can't be translated into
+// Delivery Service fields.
+type untranslatedError string
+
+func (e untranslatedError) Error() string {
+	return string(e)
+}
+
+// uriSigningKeysNote reminds operators that URI signing needs keys.
+const uriSigningKeysNote = "URI signing keys must be set for the Delivery Service before it can deliver signed content"
+
+// cacheNoCache is the SVA cache policy value that prevents caching.
+const cacheNoCache = "no-cache"
+
+// These mark the part of a header rewrite that was generated from CDNi
+// metadata, so that it can be replaced when the metadata changes.
+const (
+	headerRewriteBegin = "# begin CDNi metadata"
+	headerRewriteEnd   = "# end CDNi metadata"
+	headerRewriteSep   = "__RETURN__"
+)
+
+// PathMatch contains the metadata of the paths matching a pattern.
+type PathMatch struct {
+	PathPattern  PatternMatch `json:"path-pattern"`
+	PathMetadata PathMetadata `json:"path-metadata"`
+}
+
+// PatternMatch is a pattern of paths, where '*' matches any sequence of
+// characters, '?' matches any single character, and '\' escapes them.
+type PatternMatch struct {
+	Pattern       string `json:"pattern"`
+	CaseSensitive bool   `json:"case-sensitive"`
+}
+
+// PathMetadata contains the metadata that apply to a path.
+type PathMetadata struct {
+	Metadata []GenericMetadata `json:"metadata"`
+	Paths    []PathMatch       `json:"paths,omitempty"`
+}
+
+// SourceMetadata contains the sources from which content is acquired.
+type SourceMetadata struct {
+	Sources []Source `json:"sources"`
+}
+
+// Source contains the endpoints and protocol of a content source.
+type Source struct {
+	AcquisitionAuth *GenericMetadata `json:"acquisition-auth,omitempty"`
+	Endpoints       []string         `json:"endpoints"`
+	Protocol        string           `json:"protocol"`
+}
+
+// LocationACL contains the rules that restrict delivery by client location.
+type LocationACL struct {
+	LocationsACL []LocationRule `json:"locations-acl"`
+}
+
+// LocationRule allows or denies delivery to clients in the given footprints.
+type LocationRule struct {
+	Action     string      `json:"action"`
+	Footprints []Footprint `json:"footprints"`
+}
+
+// TimeWindowACL contains the rules that restrict delivery by time.
+type TimeWindowACL struct {
+	TimesACL []TimeWindowRule `json:"times-acl"`
+}
+
+// TimeWindowRule allows or denies delivery during the given time windows.
+type TimeWindowRule struct {
+	Action  string       `json:"action"`
+	Windows []TimeWindow `json:"windows"`
+}
+
+// TimeWindow is a time interval, in seconds since the Unix epoch.
+type TimeWindow struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// ProtocolACL contains the rules that restrict delivery by protocol.
+type ProtocolACL struct {
+	ProtocolACL []ProtocolRule `json:"protocol-acl"`
+}
+
+// ProtocolRule allows or denies delivery over the given protocols.
+type ProtocolRule struct {
+	Action    string   `json:"action"`
+	Protocols []string `json:"protocols"`
+}
+
+// DeliveryAuthorization contains the methods used to authorize delivery.
+type DeliveryAuthorization struct {
+	DeliveryAuthMethods []string `json:"delivery-auth-methods"`
+}
+
+// Cache contains the treatment of query strings in cache keys.
+type Cache struct {
+	ExcludeQueryString  bool     `json:"exclude-query-string"`
+	IncludeQueryStrings []string `json:"include-query-strings"`
+}
+
+// CachePolicy contains the times, in seconds, for which content is cached by
+// the dCDN (internal) and by clients (external). Each is either a number,
+// "no-cache", or "as-is" to keep the caching directives of the source.
+type CachePolicy struct {
+	Internal json.RawMessage `json:"internal,omitempty"`
+	External json.RawMessage `json:"external,omitempty"`
+}
+
+// requestDeliveryService translates the Delivery Service metadata in the
+// given list into a Delivery Service Request for the given host, and returns
+// the remaining metadata, which must be queued as a configuration update, and
+// whether a request was created.
+func requestDeliveryService(inf *api.Info, ucdn string, host string, rawMetadata json.RawMessage, paths []PathMatch) ([]GenericMetadata, tc.Alerts, bool, int, error, error) {
+	alerts := tc.Alerts{}
+	var metadata []GenericMetadata
+	if len(rawMetadata) > 0 {
+		if err := json.Unmarshal(rawMetadata, &metadata); err != nil {
+			return nil, alerts, false, http.StatusBadRequest, fmt.Errorf("metadata must be an array of generic metadata objects: %w", err), nil
+		}
+	}
+
+	remaining := []GenericMetadata{}
+	dsMetadata := []GenericMetadata{}
+	for _, m := range metadata {
+		if m.Type.isValid() {
+			remaining = append(remaining, m)
+		} else {
+			dsMetadata = append(dsMetadata, m)
+		}
+	}
+	if len(dsMetadata) == 0 && len(paths) == 0 {
+		return metadata, alerts, false, http.StatusOK, nil, nil
+	}
+	if host == "" {
+		return nil, alerts, false, http.StatusBadRequest, errors.New("a host is required for Delivery Service metadata"), nil
+	}
+	if !canRequestDeliveryService(inf) {
+		return nil, alerts, false, http.StatusForbidden, errors.New("missing required Permissions: DS-REQUEST:CREATE"), nil
+	}
+
+	xmlID := metadataXMLID(ucdn, host)
+	query := deliveryservice.SelectDeliveryServicesQuery + `WHERE xml_id=:XMLID`
+	existing, userErr, sysErr, errCode := deliveryservice.GetDeliveryServices(query, map[string]interface{}{"XMLID": xmlID}, inf.Tx)
+	if userErr != nil || sysErr != nil {
+		return nil, alerts, false, errCode, userErr, sysErr
+	}
+
+	dsr := tc.DeliveryServiceRequestV5{Status: tc.RequestStatusSubmitted, XMLID: xmlID}
+	var ds tc.DeliveryServiceV5
+	if len(existing) > 0 {
+		dsr.ChangeType = tc.DSRChangeTypeUpdate
+		ds = existing[0].DS
+	} else {
+		templateXMLID := inf.Config.Cdni.DeliveryServiceTemplate
+		if templateXMLID == "" {
+			return nil, alerts, false, http.StatusInternalServerError, nil, errors.New("cdn.conf does not contain a CDNi Delivery Service template")
+		}
+		templates, userErr, sysErr, errCode := deliveryservice.GetDeliveryServices(query, map[string]interface{}{"XMLID": templateXMLID}, inf.Tx)
+		if userErr != nil || sysErr != nil {
+			return nil, alerts, false, errCode, userErr, sysErr
+		}
+		if len(templates) == 0 {
+			return nil, alerts, false, http.StatusInternalServerError, nil, fmt.Errorf("CDNi Delivery Service template '%s' does not exist", templateXMLID)
+		}
+		dsr.ChangeType = tc.DSRChangeTypeCreate
+		ds = newMetadataDeliveryService(templates[0].DS, xmlID, ucdn, host)
+	}
+
+	notes, err := translateMetadata(&ds, host, dsMetadata, paths)
+	if err != nil {
+		return nil, alerts, false, http.StatusBadRequest, err, nil
+	}
+
+	// created as a POST to /deliveryservice_requests would be, so the
+	// translated Delivery Service is validated, and the uCDN's user must be
+	// authorized on its Tenant
+	dsr.Requested = &ds
+	if errCode, userErr, sysErr := dsrequest.Create(&dsr, inf); userErr != nil || sysErr != nil {
+		return nil, alerts, false, errCode, userErr, sysErr
+	}
+
+	msg := fmt.Sprintf("CDNi metadata for host '%s' translated into Delivery Service Request %d of type %s for Delivery Service '%s'.", host, *dsr.ID, dsr.ChangeType, xmlID)
+	api.CreateChangeLogRawTx(api.ApiChange, msg, inf.User, inf.Tx.Tx)
+	alerts.AddNewAlert(tc.SuccessLevel, msg)
+	for _, note := range notes {
+		alerts.AddNewAlert(tc.WarnLevel, note)
+	}
+	return remaining, alerts, true, http.StatusOK, nil, nil
+}
+
+// canRequestDeliveryService returns whether the requesting user may create the
+// Delivery Service Request that Delivery Service metadata is translated into,
+// which POST requests to /deliveryservice_requests require Permission for.
+func canRequestDeliveryService(inf *api.Info) bool {
+	if inf.Version.GreaterThanOrEqualTo(&api.Version{Major: 5}) || inf.Config.RoleBasedPermissions {
+		return inf.User.Can("DS-REQUEST:CREATE")
+	}
+	return inf.User.PrivLevel >= auth.PrivLevelPortal
+}
+
+// metadataXMLID returns the XMLID of the Delivery Service that serves the
+// given uCDN host.
+func metadataXMLID(ucdn string, host string) string {
+	xmlID := []byte(strings.ToLower("cdni-" + ucdn + "-" + host))
+	for i, c := range xmlID {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			xmlID[i] = '-'
+		}
+	}
+	if len(xmlID) <= 48 {
+		return string(xmlID)
+	}
+	h := fnv.New32a()
+	h.Write(xmlID)
+	return fmt.Sprintf("%s-%08x", xmlID[:39], h.Sum32())
+}
+
+// newMetadataDeliveryService returns a new, inactive Delivery Service for the
+// given uCDN host, copied from the given template.
+func newMetadataDeliveryService(template tc.DeliveryServiceV5, xmlID string, ucdn string, host string) tc.DeliveryServiceV5 {
+	ds := template
+	ds.ID = nil
+	ds.XMLID = xmlID
+	ds.DisplayName = host
+	if len(ds.DisplayName) > 48 {
+		ds.DisplayName = xmlID
+	}
+	ds.LongDesc = "Delivers host " + host + " for uCDN " + ucdn + ", from CDNi metadata."
+	ds.Active = tc.DSActiveStateInactive
+	ds.ExampleURLs = nil
+	ds.MatchList = nil
+	return ds
+}
+
+// translateMetadata sets the fields of the given Delivery Service from the
+// given host metadata and paths. It returns notes about the metadata that
+// couldn't be translated, or an error if the metadata is invalid or can't be
+// translated but is mandatory to enforce.
+func translateMetadata(ds *tc.DeliveryServiceV5, host string, metadata []GenericMetadata, paths []PathMatch) ([]string, error) {
+	notes := []string{}
+	var headerRewrites []string
+
+	ds.MatchList = []tc.DeliveryServiceMatch{{Type: tc.DSMatchTypeHostRegex, SetNumber: 1, Pattern: regexp.QuoteMeta(host)}}
+	for _, path := range paths {
+		ds.MatchList = append(ds.MatchList, tc.DeliveryServiceMatch{Type: tc.DSMatchTypePathRegex, SetNumber: 1, Pattern: patternToRegex(path.PathPattern)})
+		if len(path.PathMetadata.Metadata) == 0 && len(path.PathMetadata.Paths) == 0 {
+			continue
+		}
+		for _, m := range path.PathMetadata.Metadata {
+			if m.MandatoryToEnforce {
+				return nil, fmt.Errorf("%s metadata of path pattern '%s' is mandatory to enforce, but metadata can only be applied to whole hosts", m.Type, path.PathPattern.Pattern)
+			}
+		}
+		notes = append(notes, fmt.Sprintf("the metadata of path pattern '%s' was not translated; metadata can only be applied to whole hosts", path.PathPattern.Pattern))
+	}
+
+	for _, m := range metadata {
+		var note string
+		var err error
+		switch m.Type {
+		case MiSourceMetadata:
+			note, err = translateSourceMetadata(ds, m.Value)
+		case MiLocationACL:
+			note, err = translateLocationACL(ds, m.Value)
+		case MiTimeWindowACL:
+			var rewrites []string
+			rewrites, note, err = translateTimeWindowACL(m.Value)
+			headerRewrites = append(headerRewrites, rewrites...)
+		case MiProtocolACL:
+			note, err = translateProtocolACL(ds, m.Value)
+		case MiDeliveryAuthorization:
+			note, err = translateDeliveryAuthorization(ds, m.Value)
+		case MiURISigning:
+			ds.SigningAlgorithm = util.StrPtr(tc.SigningAlgorithmURISigning)
+			note = uriSigningKeysNote
+		case MiCache:
+			note, err = translateCache(ds, m.Value)
+		case MiCachePolicy:
+			var rewrites []string
+			rewrites, err = translateCachePolicy(m.Value)
+			headerRewrites = append(headerRewrites, rewrites...)
+		case MiGrouping:
+		default:
+			err = untranslatedError("the metadata type is not supported")
+		}
+		var untranslated untranslatedError
+		if errors.As(err, &untranslated) {
+			if m.MandatoryToEnforce {
+				return nil, fmt.Errorf("%s is mandatory to enforce, but %s", m.Type, untranslated)
+			}
+			note = string(untranslated) + "; it was not translated"
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Type, err)
+		}
+		if note != "" {
+			notes = append(notes, fmt.Sprintf("%s: %s", m.Type, note))
+		}
+	}
+
+	if ds.Topology != nil && *ds.Topology != "" {
+		ds.FirstHeaderRewrite = replaceHeaderRewrites(ds.FirstHeaderRewrite, headerRewrites)
+	} else {
+		ds.EdgeHeaderRewrite = replaceHeaderRewrites(ds.EdgeHeaderRewrite, headerRewrites)
+	}
+	return notes, nil
+}
+
+// translateSourceMetadata sets the origin of the Delivery Service to the first
+// endpoint of the first source.
+func translateSourceMetadata(ds *tc.DeliveryServiceV5, value json.RawMessage) (string, error) {
+	var sources SourceMetadata
+	if err := json.Unmarshal(value, &sources); err != nil {
+		return "", err
+	}
+	if len(sources.Sources) == 0 || len(sources.Sources[0].Endpoints) == 0 {
+		return "", errors.New("at least one source endpoint is required")
+	}
+	source := sources.Sources[0]
+	var scheme string
+	switch strings.ToLower(source.Protocol) {
+	case ProtocolHTTP, "":
+		scheme = "http://"
+	case ProtocolHTTPS:
+		scheme = "https://"
+	default:
+		return "", fmt.Errorf("unsupported acquisition protocol '%s'", source.Protocol)
+	}
+	ds.OrgServerFQDN = util.StrPtr(scheme + source.Endpoints[0])
+
+	var notes []string
+	if len(sources.Sources) > 1 || len(source.Endpoints) > 1 {
+		notes = append(notes, "only the first endpoint of the first source is used as the origin; other origins must be added to the Delivery Service")
+	}
+	if source.AcquisitionAuth != nil {
+		notes = append(notes, "acquisition authentication was not translated")
+	}
+	return strings.Join(notes, "; "), nil
+}
+
+// translateLocationACL translates a list of rules that allow country codes and
+// then deny all addresses into a Geo Limit on those countries.
+func translateLocationACL(ds *tc.DeliveryServiceV5, value json.RawMessage) (string, error) {
+	var acl LocationACL
+	if err := json.Unmarshal(value, &acl); err != nil {
+		return "", err
+	}
+	countries := []string{}
+	deniesAll := false
+	for _, rule := range acl.LocationsACL {
+		switch rule.Action {
+		case ACLActionAllow:
+			if deniesAll {
+				continue
+			}
+			for _, footprint := range rule.Footprints {
+				if footprint.FootprintType != CountryCode {
+					return "", untranslatedError("only country code footprints can be allowed")
+				}
+				for _, country := range footprint.FootprintValue {
+					countries = append(countries, strings.ToUpper(country))
+				}
+			}
+		case ACLActionDeny:
+			if !deniesAllAddresses(rule.Footprints) {
+				return "", untranslatedError("only denying all addresses (0.0.0.0/0 and ::/0) is supported")
+			}
+			deniesAll = true
+		default:
+			return "", fmt.Errorf("invalid action '%s'", rule.Action)
+		}
+	}
+	if !deniesAll {
+		ds.GeoLimit = 0
+		ds.GeoLimitCountries = nil
+		return "", nil
+	}
+	ds.GeoLimit = 2
+	ds.GeoLimitCountries = countries
+	return "clients in the Coverage Zone File are also allowed by the Geo Limit", nil
+}
+
+func deniesAllAddresses(footprints []Footprint) bool {
+	ipv4, ipv6 := false, false
+	for _, footprint := range footprints {
+		for _, value := range footprint.FootprintValue {
+			switch {
+			case footprint.FootprintType == Ipv4Cidr && value == "0.0.0.0/0":
+				ipv4 = true
+			case footprint.FootprintType == Ipv6Cidr && value == "::/0":
+				ipv6 = true
+			}
+		}
+	}
+	return ipv4 && ipv6
+}
+
+// translateTimeWindowACL translates a list of rules that deny time windows, or
+// a single rule that allows a single time window, into header rewrite rules
+// that reject requests with a 403 status.
+func translateTimeWindowACL(value json.RawMessage) ([]string, string, error) {
+	var acl TimeWindowACL
+	if err := json.Unmarshal(value, &acl); err != nil {
+		return nil, "", err
+	}
+	rewrites := []string{}
+	for _, rule := range acl.TimesACL {
+		for _, window := range rule.Windows {
+			if window.End <= window.Start {
+				return nil, "", fmt.Errorf("time window end %d must be after its start %d", window.End, window.Start)
+			}
+		}
+		switch rule.Action {
+		case ACLActionDeny:
+			for _, window := range rule.Windows {
+				rewrites = append(rewrites,
+					"cond %{NOW} >"+strconv.FormatInt(window.Start-1, 10)+" [AND]",
+					"cond %{NOW} <"+strconv.FormatInt(window.End, 10),
+					"set-status 403",
+				)
+			}
+		case ACLActionAllow:
+			if len(acl.TimesACL) != 1 || len(rule.Windows) != 1 {
+				return nil, "", untranslatedError("only a single allowed time window is supported")
+			}
+			window := rule.Windows[0]
+			rewrites = append(rewrites,
+				"cond %{NOW} <"+strconv.FormatInt(window.Start, 10)+" [OR]",
+				"cond %{NOW} >"+strconv.FormatInt(window.End-1, 10),
+				"set-status 403",
+			)
+		default:
+			return nil, "", fmt.Errorf("invalid action '%s'", rule.Action)
+		}
+	}
+	return rewrites, "", nil
+}
+
+// translateProtocolACL sets the protocol of the Delivery Service to the
+// protocols that the rules allow.
+func translateProtocolACL(ds *tc.DeliveryServiceV5, value json.RawMessage) (string, error) {
+	var acl ProtocolACL
+	if err := json.Unmarshal(value, &acl); err != nil {
+		return "", err
+	}
+	allowed := func(protocol string) bool {
+		for _, rule := range acl.ProtocolACL {
+			for _, p := range rule.Protocols {
+				if strings.EqualFold(p, protocol) {
+					return rule.Action == ACLActionAllow
+				}
+			}
+		}
+		return true
+	}
+	for _, rule := range acl.ProtocolACL {
+		if rule.Action != ACLActionAllow && rule.Action != ACLActionDeny {
+			return "", fmt.Errorf("invalid action '%s'", rule.Action)
+		}
+	}
+
+	allowHTTP, allowHTTPS := allowed(ProtocolHTTP), allowed(ProtocolHTTPS)
+	switch {
+	case allowHTTP && allowHTTPS:
+		if ds.Protocol == nil || (*ds.Protocol != tc.DSProtocolHTTPAndHTTPS && *ds.Protocol != tc.DSProtocolHTTPToHTTPS) {
+			ds.Protocol = util.IntPtr(tc.DSProtocolHTTPAndHTTPS)
+		}
+	case allowHTTPS:
+		ds.Protocol = util.IntPtr(tc.DSProtocolHTTPS)
+	case allowHTTP:
+		ds.Protocol = util.IntPtr(tc.DSProtocolHTTP)
+	default:
+		return "", untranslatedError("the rules deny every supported protocol")
+	}
+	return "", nil
+}
+
+// translateDeliveryAuthorization enables URI signing if it is one of the
+// delivery authorization methods.
+func translateDeliveryAuthorization(ds *tc.DeliveryServiceV5, value json.RawMessage) (string, error) {
+	var auth DeliveryAuthorization
+	if err := json.Unmarshal(value, &auth); err != nil {
+		return "", err
+	}
+	unsupported := []string{}
+	for _, method := range auth.DeliveryAuthMethods {
+		if method == string(MiURISigning) {
+			ds.SigningAlgorithm = util.StrPtr(tc.SigningAlgorithmURISigning)
+		} else {
+			unsupported = append(unsupported, method)
+		}
+	}
+	if len(unsupported) > 0 {
+		return "", untranslatedError("unsupported delivery authorization methods: " + strings.Join(unsupported, ", "))
+	}
+	if ds.SigningAlgorithm != nil && *ds.SigningAlgorithm == tc.SigningAlgorithmURISigning {
+		return uriSigningKeysNote, nil
+	}
+	return "", nil
+}
+
+// translateCache sets whether query strings are part of the cache key.
+func translateCache(ds *tc.DeliveryServiceV5, value json.RawMessage) (string, error) {
+	var cache Cache
+	if err := json.Unmarshal(value, &cache); err != nil {
+		return "", err
+	}
+	if !cache.ExcludeQueryString {
+		ds.QStringIgnore = util.IntPtr(tc.QueryStringIgnoreUseInCacheKeyAndPassUp)
+		return "", nil
+	}
+	ds.QStringIgnore = util.IntPtr(tc.QueryStringIgnoreIgnoreInCacheKeyAndPassUp)
+	if len(cache.IncludeQueryStrings) > 0 {
+		return "query strings that are included in the cache key were not translated; all query strings are excluded", nil
+	}
+	return "", nil
+}
+
+// translateCachePolicy translates the cache policy into header rewrite rules
+// that set the Cache-Control of responses from the source (internal) and to
+// clients (external).
+func translateCachePolicy(value json.RawMessage) ([]string, error) {
+	var policy CachePolicy
+	if err := json.Unmarshal(value, &policy); err != nil {
+		return nil, err
+	}
+	rewrites := []string{}
+	for _, p := range []struct {
+		hook  string
+		value json.RawMessage
+	}{
+		{"%{READ_RESPONSE_HDR_HOOK}", policy.Internal},
+		{"%{SEND_RESPONSE_HDR_HOOK}", policy.External},
+	} {
+		cacheControl, err := cacheControlOf(p.value)
+		if err != nil {
+			return nil, err
+		}
+		if cacheControl != "" {
+			rewrites = append(rewrites, "cond "+p.hook, `set-header Cache-Control "`+cacheControl+`"`)
+		}
+	}
+	return rewrites, nil
+}
+
+// cacheControlOf returns the Cache-Control header for a cache policy value, or
+// an empty string if the source's directives are kept.
+func cacheControlOf(value json.RawMessage) (string, error) {
+	if len(value) == 0 {
+		return "", nil
+	}
+	var seconds int64
+	if err := json.Unmarshal(value, &seconds); err == nil {
+		if seconds < 0 {
+			return "", fmt.Errorf("invalid cache time %d", seconds)
+		}
+		return "max-age=" + strconv.FormatInt(seconds, 10), nil
+	}
+	var policy string
+	if err := json.Unmarshal(value, &policy); err != nil {
+		return "", fmt.Errorf("cache policy must be a number of seconds, \"no-cache\" or \"as-is\": %w", err)
+	}
+	switch policy {
+	case cacheNoCache:
+		return "no-store", nil
+	case "as-is":
+		return "", nil
+	}
+	return "", fmt.Errorf("invalid cache policy '%s'", policy)
+}
+
+// replaceHeaderRewrites replaces the CDNi metadata part of a header rewrite
+// with the given rules.
+func replaceHeaderRewrites(rewrite *string, rules []string) *string {
+	lines := []string{}
+	if rewrite != nil && *rewrite != "" {
+		inBlock := false
+		for _, line := range strings.Split(*rewrite, headerRewriteSep) {
+			switch strings.TrimSpace(line) {
+			case headerRewriteBegin:
+				inBlock = true
+				continue
+			case headerRewriteEnd:
+				inBlock = false
+				continue
+			}
+			if !inBlock {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if len(rules) > 0 {
+		lines = append(lines, headerRewriteBegin)
+		lines = append(lines, rules...)
+		lines = append(lines, headerRewriteEnd)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return util.StrPtr(strings.Join(lines, headerRewriteSep))
+}
+
+// patternToRegex returns the regular expression that matches the same paths
+// as the given pattern.
+func patternToRegex(pattern PatternMatch) string {
+	var b strings.Builder
+	if !pattern.CaseSensitive {
+		b.WriteString("(?i)")
+	}
+	b.WriteRune('^')
+	escaped := false
+	for _, c := range pattern.Pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '*':
+			b.WriteString(".*")
+		case c == '?':
+			b.WriteRune('.')
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteRune('$')
+	return b.String()
+}
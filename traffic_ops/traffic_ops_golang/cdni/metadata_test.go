@@ -0,0 +1,205 @@
+package cdni
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestTranslateMetadata(t *testing.T) {
+	var metadata []GenericMetadata
+	err := json.Unmarshal([]byte(`[
+		{
+			"generic-metadata-type": "MI.SourceMetadata",
+			"generic-metadata-value": {"sources": [{"endpoints": ["origin.example.com:8443"], "protocol": "https/1.1"}]}
+		},
+		{
+			"generic-metadata-type": "MI.LocationACL",
+			"generic-metadata-value": {"locations-acl": [
+				{"action": "allow", "footprints": [{"footprint-type": "countrycode", "footprint-value": ["us", "ca"]}]},
+				{"action": "deny", "footprints": [
+					{"footprint-type": "ipv4cidr", "footprint-value": ["0.0.0.0/0"]},
+					{"footprint-type": "ipv6cidr", "footprint-value": ["::/0"]}
+				]}
+			]}
+		},
+		{
+			"generic-metadata-type": "MI.ProtocolACL",
+			"generic-metadata-value": {"protocol-acl": [{"action": "deny", "protocols": ["http/1.1"]}]}
+		},
+		{
+			"generic-metadata-type": "MI.TimeWindowACL",
+			"generic-metadata-value": {"times-acl": [{"action": "allow", "windows": [{"start": 100, "end": 200}]}]}
+		},
+		{
+			"generic-metadata-type": "MI.Cache",
+			"generic-metadata-value": {"exclude-query-string": true}
+		},
+		{
+			"generic-metadata-type": "MI.CachePolicy",
+			"generic-metadata-value": {"internal": 3600, "external": "no-cache"}
+		},
+		{
+			"generic-metadata-type": "MI.DeliveryAuthorization",
+			"generic-metadata-value": {"delivery-auth-methods": ["MI.UriSigning"]}
+		},
+		{
+			"generic-metadata-type": "MI.ClientIP",
+			"generic-metadata-value": {}
+		}
+	]`), &metadata)
+	if err != nil {
+		t.Fatalf("unmarshalling metadata: %v", err)
+	}
+	paths := []PathMatch{{PathPattern: PatternMatch{Pattern: "/video/*.m3u8", CaseSensitive: true}}}
+
+	ds := tc.DeliveryServiceV5{
+		Protocol:          util.IntPtr(tc.DSProtocolHTTP),
+		EdgeHeaderRewrite: util.StrPtr("set-header X-Existing 1"),
+	}
+	notes, err := translateMetadata(&ds, "www.example.com", metadata, paths)
+	if err != nil {
+		t.Fatalf("translating metadata: %v", err)
+	}
+
+	if ds.OrgServerFQDN == nil || *ds.OrgServerFQDN != "https://origin.example.com:8443" {
+		t.Errorf("expected origin 'https://origin.example.com:8443', got %v", ds.OrgServerFQDN)
+	}
+	if ds.GeoLimit != 2 || !reflect.DeepEqual(ds.GeoLimitCountries, []string{"US", "CA"}) {
+		t.Errorf("expected Geo Limit 2 on US and CA, got %d on %v", ds.GeoLimit, ds.GeoLimitCountries)
+	}
+	if ds.Protocol == nil || *ds.Protocol != tc.DSProtocolHTTPS {
+		t.Errorf("expected protocol %d, got %v", tc.DSProtocolHTTPS, ds.Protocol)
+	}
+	if ds.QStringIgnore == nil || *ds.QStringIgnore != tc.QueryStringIgnoreIgnoreInCacheKeyAndPassUp {
+		t.Errorf("expected query strings to be ignored in the cache key, got %v", ds.QStringIgnore)
+	}
+	if ds.SigningAlgorithm == nil || *ds.SigningAlgorithm != tc.SigningAlgorithmURISigning {
+		t.Errorf("expected URI signing, got %v", ds.SigningAlgorithm)
+	}
+
+	expectedRewrite := strings.Join([]string{
+		"set-header X-Existing 1",
+		headerRewriteBegin,
+		"cond %{NOW} <100 [OR]",
+		"cond %{NOW} >199",
+		"set-status 403",
+		"cond %{READ_RESPONSE_HDR_HOOK}",
+		`set-header Cache-Control "max-age=3600"`,
+		"cond %{SEND_RESPONSE_HDR_HOOK}",
+		`set-header Cache-Control "no-store"`,
+		headerRewriteEnd,
+	}, headerRewriteSep)
+	if ds.EdgeHeaderRewrite == nil || *ds.EdgeHeaderRewrite != expectedRewrite {
+		t.Errorf("expected edge header rewrite %q, got %v", expectedRewrite, ds.EdgeHeaderRewrite)
+	}
+
+	expectedMatches := []tc.DeliveryServiceMatch{
+		{Type: tc.DSMatchTypeHostRegex, SetNumber: 1, Pattern: `www\.example\.com`},
+		{Type: tc.DSMatchTypePathRegex, SetNumber: 1, Pattern: `^/video/.*\.m3u8$`},
+	}
+	if !reflect.DeepEqual(ds.MatchList, expectedMatches) {
+		t.Errorf("expected match list %+v, got %+v", expectedMatches, ds.MatchList)
+	}
+
+	if len(notes) != 3 {
+		t.Errorf("expected notes about the Geo Limit, URI signing keys and the unsupported metadata type, got %v", notes)
+	}
+
+	// Translating again replaces the generated header rewrite rules.
+	if _, err := translateMetadata(&ds, "www.example.com", metadata, paths); err != nil {
+		t.Fatalf("translating metadata again: %v", err)
+	}
+	if *ds.EdgeHeaderRewrite != expectedRewrite {
+		t.Errorf("expected edge header rewrite %q after translating again, got %q", expectedRewrite, *ds.EdgeHeaderRewrite)
+	}
+}
+
+func TestTranslateMetadataMandatoryToEnforce(t *testing.T) {
+	metadata := []GenericMetadata{{
+		Type:               MiLocationACL,
+		Value:              json.RawMessage(`{"locations-acl": [{"action": "deny", "footprints": [{"footprint-type": "countrycode", "footprint-value": ["us"]}]}]}`),
+		MandatoryToEnforce: true,
+	}}
+	ds := tc.DeliveryServiceV5{}
+	if _, err := translateMetadata(&ds, "www.example.com", metadata, nil); err == nil {
+		t.Error("expected an error translating mandatory metadata that can't be translated")
+	}
+
+	metadata[0].MandatoryToEnforce = false
+	notes, err := translateMetadata(&ds, "www.example.com", metadata, nil)
+	if err != nil {
+		t.Fatalf("translating metadata: %v", err)
+	}
+	if len(notes) != 1 || ds.GeoLimit != 0 {
+		t.Errorf("expected a note about the untranslated location ACL and no Geo Limit, got %v and %d", notes, ds.GeoLimit)
+	}
+
+	metadata = []GenericMetadata{{Type: MiSourceMetadata, Value: json.RawMessage(`{"sources": []}`)}}
+	if _, err := translateMetadata(&ds, "www.example.com", metadata, nil); err == nil {
+		t.Error("expected an error translating source metadata without sources")
+	}
+}
+
+func TestMetadataXMLID(t *testing.T) {
+	xmlIDPattern := regexp.MustCompile(`^[a-z0-9-]{1,48}$`)
+	if xmlID := metadataXMLID("uCDN", "www.Example.com"); xmlID != "cdni-ucdn-www-example-com" {
+		t.Errorf("expected XMLID 'cdni-ucdn-www-example-com', got '%s'", xmlID)
+	}
+	long := metadataXMLID("ucdn", strings.Repeat("a", 50)+".example.com")
+	if !xmlIDPattern.MatchString(long) {
+		t.Errorf("expected a valid XMLID for a long host, got '%s'", long)
+	}
+	if other := metadataXMLID("ucdn", strings.Repeat("a", 50)+".example.net"); other == long {
+		t.Errorf("expected different XMLIDs for different long hosts, got '%s' for both", long)
+	}
+}
+
+func TestPatternToRegex(t *testing.T) {
+	tests := []struct {
+		pattern PatternMatch
+		match   []string
+		noMatch []string
+	}{
+		{PatternMatch{Pattern: "/a/*.ts", CaseSensitive: true}, []string{"/a/b.ts", "/a/b/c.ts"}, []string{"/A/b.ts", "/a/b.tsx"}},
+		{PatternMatch{Pattern: "/a/?.ts"}, []string{"/A/b.TS"}, []string{"/a/bc.ts"}},
+		{PatternMatch{Pattern: `/a\*`, CaseSensitive: true}, []string{"/a*"}, []string{"/ab"}},
+	}
+	for _, test := range tests {
+		re := regexp.MustCompile(patternToRegex(test.pattern))
+		for _, path := range test.match {
+			if !re.MatchString(path) {
+				t.Errorf("expected pattern '%s' to match '%s'", test.pattern.Pattern, path)
+			}
+		}
+		for _, path := range test.noMatch {
+			if re.MatchString(path) {
+				t.Errorf("expected pattern '%s' not to match '%s'", test.pattern.Pattern, path)
+			}
+		}
+	}
+}
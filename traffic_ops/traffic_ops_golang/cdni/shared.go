@@ -94,10 +94,20 @@ func GetCapabilities(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	advertised := []Capability{}
+	if adCfg := inf.Config.Cdni.Advertisement; adCfg != nil && len(adCfg.CDNs) > 0 {
+		advertised, err = getAdvertisedCapabilities(inf.Tx.Tx, *adCfg)
+		if err != nil {
+			api.HandleErr(w, r, nil, http.StatusInternalServerError, err, nil)
+			return
+		}
+	}
+
 	fciCaps := Capabilities{}
-	capsList := make([]Capability, 0, len(capacities.Capabilities)+len(telemetries.Capabilities))
+	capsList := make([]Capability, 0, len(capacities.Capabilities)+len(telemetries.Capabilities)+len(advertised))
 	capsList = append(capsList, capacities.Capabilities...)
 	capsList = append(capsList, telemetries.Capabilities...)
+	capsList = append(capsList, advertised...)
 
 	fciCaps.Capabilities = capsList
 
@@ -132,11 +142,6 @@ func PutHostConfiguration(w http.ResponseWriter, r *http.Request) {
 	defer inf.Close()
 
 	host := inf.Params["host"]
-	if errCode, userErr, sysErr := validateHostExists(host, inf.Tx.Tx); userErr != nil || sysErr != nil {
-		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
-		return
-	}
-
 	if inf.Config.Cdni == nil || inf.Config.Secrets[0] == "" || inf.Config.Cdni.DCdnId == "" {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("cdn.conf does not contain CDNi information"))
 		return
@@ -156,6 +161,29 @@ func PutHostConfiguration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	remaining, alerts, requested, errCode, userErr, sysErr := requestDeliveryService(inf, ucdn, host, genericHostRequest.HostMetadata.Metadata, genericHostRequest.HostMetadata.Paths)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	data := genericHostRequest.HostMetadata.Metadata
+	if requested {
+		if len(remaining) == 0 {
+			api.WriteAlerts(w, r, http.StatusOK, alerts)
+			return
+		}
+		if data, err = json.Marshal(remaining); err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("marshalling remaining metadata: %w", err))
+			return
+		}
+	}
+
+	// Capacity limits can only be requested for hosts that have them.
+	if errCode, userErr, sysErr := validateHostExists(host, inf.Tx.Tx); userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+
 	db, err := api.GetDB(r.Context())
 	if err != nil {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("getting async db: %w", err))
@@ -179,8 +207,6 @@ func PutHostConfiguration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := genericHostRequest.HostMetadata.Metadata
-
 	_, err = inf.Tx.Tx.Query(InsertCapabilityUpdateQuery, ucdn, data, asyncStatusId, hostConfigLabel, host)
 	if err != nil {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("inserting capability update request into queue: %w", err))
@@ -190,7 +216,6 @@ func PutHostConfiguration(w http.ResponseWriter, r *http.Request) {
 	msg := "CDNi configuration update request received. Status updates can be found here: " + api.CurrentAsyncEndpoint + strconv.Itoa(asyncStatusId)
 	api.CreateChangeLogRawTx(api.ApiChange, msg, inf.User, logTx)
 
-	var alerts tc.Alerts
 	alerts.AddAlert(tc.Alert{
 		Text:  msg,
 		Level: tc.SuccessLevel.String(),
@@ -228,6 +253,23 @@ func PutConfiguration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	remaining, alerts, requested, errCode, userErr, sysErr := requestDeliveryService(inf, ucdn, genericRequest.Host, genericRequest.Metadata, genericRequest.Paths)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	data := genericRequest.Metadata
+	if requested {
+		if len(remaining) == 0 {
+			api.WriteAlerts(w, r, http.StatusOK, alerts)
+			return
+		}
+		if data, err = json.Marshal(remaining); err != nil {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("marshalling remaining metadata: %w", err))
+			return
+		}
+	}
+
 	db, err := api.GetDB(r.Context())
 	if err != nil {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("getting async db: %w", err))
@@ -251,8 +293,6 @@ func PutConfiguration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := genericRequest.Metadata
-
 	_, err = inf.Tx.Tx.Query(InsertCapabilityUpdateQuery, ucdn, data, asyncStatusId, SupportedGenericMetadataType(genericRequest.Type), genericRequest.Host)
 	if err != nil {
 		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, fmt.Errorf("inserting capability update request into queue: %w", err))
@@ -261,7 +301,6 @@ func PutConfiguration(w http.ResponseWriter, r *http.Request) {
 
 	msg := "CDNi configuration update request received. Status updates can be found here: " + api.CurrentAsyncEndpoint + strconv.Itoa(asyncStatusId)
 	api.CreateChangeLogRawTx(api.ApiChange, msg, inf.User, logTx)
-	var alerts tc.Alerts
 	alerts.AddAlert(tc.Alert{
 		Text:  msg,
 		Level: tc.SuccessLevel.String(),
@@ -788,18 +827,22 @@ type GenericHostMetadata struct {
 type GenericRequestMetadata struct {
 	Type     string          `json:"type"`
 	Metadata json.RawMessage `json:"metadata"`
+	Paths    []PathMatch     `json:"paths,omitempty"`
 	Host     string          `json:"host,omitempty"`
 }
 
 // HostMetadataList contains CDNi metadata for a specific host.
 type HostMetadataList struct {
 	Metadata json.RawMessage `json:"metadata"`
+	Paths    []PathMatch     `json:"paths,omitempty"`
 }
 
 // GenericMetadata contains generic CDNi metadata.
 type GenericMetadata struct {
-	Type  SupportedGenericMetadataType `json:"generic-metadata-type"`
-	Value json.RawMessage              `json:"generic-metadata-value"`
+	Type               SupportedGenericMetadataType `json:"generic-metadata-type"`
+	Value              json.RawMessage              `json:"generic-metadata-value"`
+	MandatoryToEnforce bool                         `json:"mandatory-to-enforce,omitempty"`
+	SafeToRedistribute bool                         `json:"safe-to-redistribute,omitempty"`
 }
 
 // CapacityRequestedLimits contains the requested capacity limits.
@@ -87,7 +87,8 @@ type Config struct {
 	LDAPConfPath                              string `json:"ldap_conf_location"`
 	ConfigInflux                              *ConfigInflux
 	InfluxEnabled                             bool
-	InfluxDBConfPath                          string `json:"influxdb_conf_path"`
+	InfluxDBConfPath                          string          `json:"influxdb_conf_path"`
+	TrafficStatsDB                            *ConfigDatabase `json:"traffic_stats_db"`
 	Version                                   string
 	DisableAutoCertDeletion                   bool                    `json:"disable_auto_cert_deletion"`
 	UseIMS                                    bool                    `json:"use_ims"`
@@ -95,6 +96,8 @@ type Config struct {
 	DefaultCertificateInfo                    *DefaultCertificateInfo `json:"default_certificate_info"`
 	Cdni                                      *CdniConf               `json:"cdni"`
 	ClientCertAuth                            *ClientCertAuth         `json:"client_certificate_authentication"`
+	KeyRotation                               ConfigKeyRotation       `json:"key_rotation"`
+	Rollout                                   ConfigRollout           `json:"rollout"`
 }
 
 // ConfigTrafficOpsGolang carries settings specific to traffic_ops_golang server
@@ -197,6 +200,29 @@ type ConfigAcmeRenewal struct {
 	RenewDaysBeforeExpiration int    `json:"renew_days_before_expiration"`
 }
 
+// ConfigKeyRotation contains configuration information for the automatic
+// rotation of Delivery Service URL signing and URI signing keys.
+type ConfigKeyRotation struct {
+	Enabled bool `json:"enabled"`
+	// CheckIntervalSec is how often Delivery Services are checked for keys
+	// that are due to be rotated.
+	CheckIntervalSec int `json:"check_interval_sec"`
+	// RotationDays is how long keys are used before they are rotated, unless
+	// a Delivery Service's Profile overrides it.
+	RotationDays int `json:"rotation_days"`
+	// User is the name of the user to which automatic rotations are
+	// attributed in the change log.
+	User string `json:"user"`
+}
+
+// ConfigRollout contains configuration information for staged rollouts of
+// cache server configuration.
+type ConfigRollout struct {
+	// CheckIntervalSec is how often running rollouts are checked for waves
+	// which are ready to be released, or whose health checks fail.
+	CheckIntervalSec int `json:"check_interval_sec"`
+}
+
 // ConfigAcmeAccount contains all account information for a single ACME provider to be registered with External Account Binding
 type ConfigAcmeAccount struct {
 	AcmeProvider string `json:"acme_provider"`
@@ -269,7 +295,18 @@ type ConfigInflux struct {
 }
 
 type CdniConf struct {
-	DCdnId string `json:"dcdn_id"`
+	DCdnId        string                 `json:"dcdn_id"`
+	Advertisement *CdniAdvertisementConf `json:"advertisement"`
+	// DeliveryServiceTemplate is the XMLID of the Delivery Service that is
+	// copied to request new Delivery Services for the hosts in uCDN metadata.
+	DeliveryServiceTemplate string `json:"delivery_service_template"`
+}
+
+// CdniAdvertisementConf configures the FCI capabilities that are generated
+// from the cache groups, servers and Delivery Services of the given CDNs.
+type CdniAdvertisementConf struct {
+	CDNs               []string `json:"cdns"`
+	IncludeCoordinates bool     `json:"include_coordinates"`
 }
 
 type ClientCertAuth struct {
@@ -457,6 +494,12 @@ const (
 	DBConnMaxLifetimeSecondsDefault = 60
 )
 
+const (
+	KeyRotationCheckIntervalSecDefault = 3600
+	KeyRotationDaysDefault             = 90
+	RolloutCheckIntervalSecDefault     = 60
+)
+
 // ParseConfig validates required fields, and parses non-JSON types
 func ParseConfig(cfg Config) (Config, error) {
 	missings := ""
@@ -502,6 +545,15 @@ func ParseConfig(cfg Config) (Config, error) {
 	if cfg.ServerUpdateStatusCacheRefreshIntervalSec < 0 {
 		cfg.ServerUpdateStatusCacheRefreshIntervalSec = 0
 	}
+	if cfg.KeyRotation.CheckIntervalSec <= 0 {
+		cfg.KeyRotation.CheckIntervalSec = KeyRotationCheckIntervalSecDefault
+	}
+	if cfg.KeyRotation.RotationDays <= 0 {
+		cfg.KeyRotation.RotationDays = KeyRotationDaysDefault
+	}
+	if cfg.Rollout.CheckIntervalSec <= 0 {
+		cfg.Rollout.CheckIntervalSec = RolloutCheckIntervalSecDefault
+	}
 
 	invalidTOURLStr := ""
 	var err error
@@ -515,6 +567,18 @@ func ParseConfig(cfg Config) (Config, error) {
 	newURL := url.URL{Scheme: "https", Host: cfg.URL.Host}
 	cfg.URL = &newURL
 
+	if cfg.TrafficStatsDB != nil {
+		if cfg.TrafficStatsDB.Hostname == "" {
+			missings += "traffic_stats_db.hostname, "
+		}
+		if cfg.TrafficStatsDB.DBName == "" {
+			missings += "traffic_stats_db.dbname, "
+		}
+		if cfg.TrafficStatsDB.Port == "" {
+			cfg.TrafficStatsDB.Port = DefaultDBPort
+		}
+	}
+
 	if cfg.ConfigTO == nil {
 		missings += "to, "
 	} else {
@@ -0,0 +1,441 @@
+package deliveryservice
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// KeyRotationDaysParameterName is the name of the Parameter which, when
+// assigned to a Delivery Service's Profile, overrides the number of days its
+// URL signing or URI signing keys are used before they are automatically
+// rotated. A value of 0 disables automatic rotation for the Delivery Service.
+const KeyRotationDaysParameterName = "signing_key_rotation_days"
+
+// keyRotationLockID identifies the PostgreSQL advisory lock held while keys are
+// being rotated, so that only one Traffic Ops instance rotates keys at a time.
+const keyRotationLockID = 7238110491
+
+// urlSigKeysPerRotation is the number of the 16 URL signing keys of a Delivery
+// Service which are replaced by each rotation. The other keys are kept, so URLs
+// signed with them remain valid until the next rotation.
+const urlSigKeysPerRotation = 8
+
+var keyRotationOnce sync.Once
+
+// keyRotationDS is a Delivery Service whose signing keys may be rotated.
+type keyRotationDS struct {
+	id               int
+	xmlID            string
+	cdnID            int
+	topology         *string
+	signingAlgorithm string
+	rotationDays     *string
+}
+
+// InitKeyRotation starts a background job which periodically rotates the URL
+// signing and URI signing keys of every Delivery Service whose current keys
+// are older than its rotation period. It does nothing if automatic key
+// rotation is not enabled.
+func InitKeyRotation(cfg config.ConfigKeyRotation, db *sqlx.DB, timeout time.Duration, tv trafficvault.TrafficVault) {
+	keyRotationOnce.Do(func() {
+		if !cfg.Enabled {
+			return
+		}
+		if cfg.User == "" {
+			log.Errorln("automatic signing key rotation is enabled, but no key_rotation user is configured - keys will not be rotated")
+			return
+		}
+		go func() {
+			for {
+				rotateSigningKeys(cfg, db, timeout, tv)
+				time.Sleep(time.Duration(cfg.CheckIntervalSec) * time.Second)
+			}
+		}()
+	})
+}
+
+func rotateSigningKeys(cfg config.ConfigKeyRotation, db *sqlx.DB, timeout time.Duration, tv trafficvault.TrafficVault) {
+	user, userErr, sysErr, _ := auth.GetCurrentUserFromDB(db, cfg.User, timeout)
+	if userErr != nil || sysErr != nil {
+		log.Errorf("rotating signing keys: getting key rotation user '%s': %v", cfg.User, util.JoinErrs([]error{userErr, sysErr}))
+		return
+	}
+
+	lockTx, err := db.Begin()
+	if err != nil {
+		log.Errorln("rotating signing keys: beginning transaction: " + err.Error())
+		return
+	}
+	defer func() {
+		if err := lockTx.Commit(); err != nil {
+			log.Errorln("rotating signing keys: committing transaction: " + err.Error())
+		}
+	}()
+	locked := false
+	if err := lockTx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, keyRotationLockID).Scan(&locked); err != nil {
+		log.Errorln("rotating signing keys: acquiring lock: " + err.Error())
+		return
+	}
+	if !locked {
+		log.Infoln("signing keys are being rotated by another Traffic Ops instance, skipping")
+		return
+	}
+
+	dses, err := getKeyRotationDSes(lockTx)
+	if err != nil {
+		log.Errorln("rotating signing keys: " + err.Error())
+		return
+	}
+
+	rotated := 0
+	now := time.Now()
+	for _, ds := range dses {
+		days := keyRotationDays(ds, cfg.RotationDays)
+		if days == 0 {
+			continue
+		}
+		ok, err := rotateDSSigningKeys(db, timeout, tv, ds, days, &user, now)
+		if err != nil {
+			log.Errorf("rotating signing keys for delivery service '%s': %v", ds.xmlID, err)
+			continue
+		}
+		if ok {
+			rotated++
+		}
+	}
+	log.Infof("rotated signing keys of %d of %d signed delivery services", rotated, len(dses))
+}
+
+// getKeyRotationDSes returns all Delivery Services which use URL signing or URI
+// signing, along with the value of their Profile's KeyRotationDaysParameterName
+// Parameter, if any.
+func getKeyRotationDSes(tx *sql.Tx) ([]keyRotationDS, error) {
+	qry := `
+SELECT
+  ds.id,
+  ds.xml_id,
+  ds.cdn_id,
+  ds.topology,
+  ds.signing_algorithm,
+  (
+    SELECT pa.value
+    FROM parameter pa
+    JOIN profile_parameter pp ON pp.parameter = pa.id
+    WHERE pp.profile = ds.profile AND pa.name = $1
+    LIMIT 1
+  ) AS rotation_days
+FROM deliveryservice ds
+WHERE ds.signing_algorithm IN ($2, $3)
+ORDER BY ds.xml_id
+`
+	rows, err := tx.Query(qry, KeyRotationDaysParameterName, tc.SigningAlgorithmURLSig, tc.SigningAlgorithmURISigning)
+	if err != nil {
+		return nil, errors.New("querying signed delivery services: " + err.Error())
+	}
+	defer log.Close(rows, "closing signed delivery services rows")
+
+	dses := []keyRotationDS{}
+	for rows.Next() {
+		ds := keyRotationDS{}
+		if err := rows.Scan(&ds.id, &ds.xmlID, &ds.cdnID, &ds.topology, &ds.signingAlgorithm, &ds.rotationDays); err != nil {
+			return nil, errors.New("scanning signed delivery services: " + err.Error())
+		}
+		dses = append(dses, ds)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("iterating over signed delivery services: " + err.Error())
+	}
+	return dses, nil
+}
+
+// keyRotationDays returns the number of days the signing keys of the given
+// Delivery Service are used before they are rotated, which is its Profile's
+// Parameter if it has a valid one, and otherwise defaultDays.
+func keyRotationDays(ds keyRotationDS, defaultDays int) int {
+	if ds.rotationDays == nil {
+		return defaultDays
+	}
+	days, err := strconv.Atoi(*ds.rotationDays)
+	if err != nil || days < 0 {
+		log.Warnf("delivery service '%s' has an invalid %s parameter value '%s', using the default of %d days", ds.xmlID, KeyRotationDaysParameterName, *ds.rotationDays, defaultDays)
+		return defaultDays
+	}
+	return days
+}
+
+// keyRotationDue returns whether keys which were stored at lastUpdated are due
+// to be rotated at now, given a rotation period of the given number of days.
+func keyRotationDue(lastUpdated time.Time, days int, now time.Time) bool {
+	return !now.Before(lastUpdated.Add(time.Duration(days) * 24 * time.Hour))
+}
+
+// currentSecretVersion returns the current version in the given versions, or
+// nil if none of them is current.
+func currentSecretVersion(versions []tc.TrafficVaultSecretVersion) *tc.TrafficVaultSecretVersion {
+	for _, v := range versions {
+		if v.Current {
+			return &v
+		}
+	}
+	return nil
+}
+
+// rotateDSSigningKeys rotates the signing keys of the given Delivery Service if
+// they are due to be rotated, and returns whether they were.
+//
+// Keys stored before Traffic Vault kept versions of them have no known age, so
+// they are stored again to start their rotation period, rather than rotated.
+func rotateDSSigningKeys(db *sqlx.DB, timeout time.Duration, tv trafficvault.TrafficVault, ds keyRotationDS, days int, user *auth.CurrentUser, now time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, errors.New("beginning transaction: " + err.Error())
+	}
+	rotated, err := rotateDSSigningKeysTx(tx, ctx, tv, ds, days, user, now)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Errorln("rolling back signing key rotation transaction: " + rollbackErr.Error())
+		}
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, errors.New("committing transaction: " + err.Error())
+	}
+	return rotated, nil
+}
+
+func rotateDSSigningKeysTx(tx *sql.Tx, ctx context.Context, tv trafficvault.TrafficVault, ds keyRotationDS, days int, user *auth.CurrentUser, now time.Time) (bool, error) {
+	secretType := trafficvault.SecretTypeURLSigKeys
+	if ds.signingAlgorithm == tc.SigningAlgorithmURISigning {
+		secretType = trafficvault.SecretTypeURISigningKeys
+	}
+	versions, err := tv.GetSecretVersions(secretType, ds.xmlID, tx, ctx)
+	if err != nil {
+		return false, errors.New("getting key versions from Traffic Vault: " + err.Error())
+	}
+	current := currentSecretVersion(versions)
+	if current != nil && !keyRotationDue(current.LastUpdated, days, now) {
+		return false, nil
+	}
+
+	action := ""
+	switch secretType {
+	case trafficvault.SecretTypeURLSigKeys:
+		keys, ok, err := tv.GetURLSigKeys(ds.xmlID, tx, ctx)
+		if err != nil {
+			return false, errors.New("getting URL sig keys from Traffic Vault: " + err.Error())
+		}
+		if !ok {
+			return false, nil
+		}
+		if current != nil {
+			if keys, err = rotateURLSigKeys(keys, trafficvault.NextSecretVersion(versions)); err != nil {
+				return false, errors.New("rotating URL sig keys: " + err.Error())
+			}
+			action = "Rotated URL sig keys"
+		}
+		if err := tv.PutURLSigKeys(ds.xmlID, keys, tx, ctx); err != nil {
+			return false, errors.New("storing URL sig keys in Traffic Vault: " + err.Error())
+		}
+	case trafficvault.SecretTypeURISigningKeys:
+		keys, ok, err := tv.GetURISigningKeys(ds.xmlID, tx, ctx)
+		if err != nil {
+			return false, errors.New("getting URI signing keys from Traffic Vault: " + err.Error())
+		}
+		if !ok || len(keys) == 0 {
+			return false, nil
+		}
+		if current != nil {
+			if keys, err = rotateURISigningKeys(keys, now); err != nil {
+				return false, errors.New("rotating URI signing keys: " + err.Error())
+			}
+			action = "Rotated URI signing keys"
+		}
+		if err := tv.PutURISigningKeys(ds.xmlID, keys, tx, ctx); err != nil {
+			return false, errors.New("storing URI signing keys in Traffic Vault: " + err.Error())
+		}
+	}
+	if action == "" {
+		log.Infof("started the signing key rotation period of delivery service '%s'", ds.xmlID)
+		return false, nil
+	}
+
+	if err := queueKeyRotationUpdates(tx, ds); err != nil {
+		return false, err
+	}
+	if err := api.CreateChangeLogRawErr(api.ApiChange, "DS: "+ds.xmlID+", ID: "+strconv.Itoa(ds.id)+", ACTION: "+action+" automatically", user, tx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// queueKeyRotationUpdates queues config updates on the caches of the given
+// Delivery Service, so they pick up its rotated keys: the caches of its
+// Topology's Cache Groups in its CDN, or else the caches assigned to it.
+func queueKeyRotationUpdates(tx *sql.Tx, ds keyRotationDS) error {
+	if ds.topology != nil {
+		return dbhelpers.QueueUpdateForServerWithTopologyCDN(tx, tc.TopologyName(*ds.topology), int64(ds.cdnID))
+	}
+
+	rows, err := tx.Query(`SELECT server FROM deliveryservice_server WHERE deliveryservice = $1`, ds.id)
+	if err != nil {
+		return errors.New("querying assigned servers: " + err.Error())
+	}
+	serverIDs := []int64{}
+	for rows.Next() {
+		var serverID int64
+		if err := rows.Scan(&serverID); err != nil {
+			log.Close(rows, "closing assigned server rows")
+			return errors.New("scanning assigned servers: " + err.Error())
+		}
+		serverIDs = append(serverIDs, serverID)
+	}
+	if err := rows.Err(); err != nil {
+		log.Close(rows, "closing assigned server rows")
+		return errors.New("iterating over assigned servers: " + err.Error())
+	}
+	log.Close(rows, "closing assigned server rows")
+
+	for _, serverID := range serverIDs {
+		if err := dbhelpers.QueueUpdateForServer(tx, serverID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotateURLSigKeys returns a copy of the given URL sig keys in which half of the
+// keys are newly generated. Which half is replaced alternates with the parity
+// of the version number the rotated keys will be stored as, so each key stays
+// valid for two rotation periods.
+func rotateURLSigKeys(keys tc.URLSigKeys, version int64) (tc.URLSigKeys, error) {
+	generated, err := GenerateURLSigKeys()
+	if err != nil {
+		return nil, err
+	}
+	rotated := make(tc.URLSigKeys, len(generated))
+	for name, key := range keys {
+		rotated[name] = key
+	}
+	first := int(version%2) * urlSigKeysPerRotation
+	for i := first; i < first+urlSigKeysPerRotation; i++ {
+		name := "key" + strconv.Itoa(i)
+		rotated[name] = generated[name]
+	}
+	return rotated, nil
+}
+
+// rotateURISigningKeys adds a newly generated key to every key set of the given
+// URI signing keys that has a renewal key, and makes it that set's renewal key.
+// The previous renewal key is kept so tokens signed with it remain valid until
+// the next rotation; any older keys in those key sets are removed.
+func rotateURISigningKeys(keysJSON []byte, now time.Time) ([]byte, error) {
+	keySets := tc.JWKSMap{}
+	if err := json.Unmarshal(keysJSON, &keySets); err != nil {
+		return nil, errors.New("unmarshalling keys: " + err.Error())
+	}
+
+	rotatedIssuers := 0
+	for issuer, set := range keySets {
+		renewalKid := tc.GetRenewalKid(set)
+		if renewalKid == nil {
+			continue
+		}
+		renewalKey, ok := set.LookupKeyID(*renewalKid)
+		if !ok {
+			return nil, fmt.Errorf("issuer '%s' has no key with the renewal kid '%s'", issuer, *renewalKid)
+		}
+		if renewalKey.KeyType() != jwa.OctetSeq {
+			return nil, fmt.Errorf("the renewal key of issuer '%s' has key type '%s', only '%s' keys can be rotated", issuer, renewalKey.KeyType(), jwa.OctetSeq)
+		}
+
+		newKey, err := generateURISigningKey(renewalKey.Algorithm(), now)
+		if err != nil {
+			return nil, err
+		}
+		rotated := jwk.NewSet()
+		rotated.Add(renewalKey)
+		rotated.Add(newKey)
+		if err := rotated.Set("renewal_kid", newKey.KeyID()); err != nil {
+			return nil, errors.New("setting renewal kid: " + err.Error())
+		}
+		keySets[issuer] = rotated
+		rotatedIssuers++
+	}
+	if rotatedIssuers == 0 {
+		return nil, errors.New("no key set has a renewal_kid")
+	}
+
+	rotatedJSON, err := json.Marshal(keySets)
+	if err != nil {
+		return nil, errors.New("marshalling keys: " + err.Error())
+	}
+	return rotatedJSON, nil
+}
+
+// generateURISigningKey generates a new symmetric key for the given algorithm,
+// with a key ID derived from the time it was generated.
+func generateURISigningKey(alg string, now time.Time) (jwk.Key, error) {
+	size := 32
+	switch jwa.SignatureAlgorithm(alg) {
+	case jwa.HS384:
+		size = 48
+	case jwa.HS512:
+		size = 64
+	}
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, errors.New("generating key: " + err.Error())
+	}
+	key, err := jwk.New(secret)
+	if err != nil {
+		return nil, errors.New("creating key: " + err.Error())
+	}
+	if err := key.Set(jwk.AlgorithmKey, alg); err != nil {
+		return nil, errors.New("setting key algorithm: " + err.Error())
+	}
+	if err := key.Set(jwk.KeyIDKey, "rotated-"+now.UTC().Format("20060102T150405Z")); err != nil {
+		return nil, errors.New("setting key ID: " + err.Error())
+	}
+	return key, nil
+}
@@ -0,0 +1,259 @@
+package deliveryservice
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+const uriSigningKeysToRotate = `
+{
+  "Kabletown URI Authority 1": {
+    "renewal_kid": "Second Key",
+    "keys": [
+      {
+        "alg": "HS256",
+        "kid": "First Key",
+        "kty": "oct",
+        "k": "Kh_RkUMj-fzbD37qBnDf_3e_RvQ3RP9PaSmVEpE24AM"
+      },
+      {
+        "alg": "HS256",
+        "kid": "Second Key",
+        "kty": "oct",
+        "k": "fZBpDBNbk2GqhwoB_DGBAsBxqQZVix04rIoLJ7p_RlE"
+      }
+    ]
+  },
+  "Kabletown URI Authority 2": {
+    "keys": [
+      {
+        "alg": "HS256",
+        "kid": "Third Key",
+        "kty": "oct",
+        "k": "Kh_RkUMj-fzbD37qBnDf_3e_RvQ3RP9PaSmVEpE24AM"
+      }
+    ]
+  }
+}`
+
+const uriSigningKeysWithTwoRenewalKeys = `
+{
+  "Kabletown URI Authority 1": {
+    "renewal_kid": "First Key",
+    "keys": [
+      {
+        "alg": "HS256",
+        "kid": "First Key",
+        "kty": "oct",
+        "k": "Kh_RkUMj-fzbD37qBnDf_3e_RvQ3RP9PaSmVEpE24AM"
+      }
+    ]
+  },
+  "Kabletown URI Authority 2": {
+    "renewal_kid": "Second Key",
+    "keys": [
+      {
+        "alg": "HS512",
+        "kid": "Second Key",
+        "kty": "oct",
+        "k": "fZBpDBNbk2GqhwoB_DGBAsBxqQZVix04rIoLJ7p_RlE"
+      }
+    ]
+  }
+}`
+
+func TestKeyRotationDays(t *testing.T) {
+	tests := []struct {
+		value    *string
+		expected int
+	}{
+		{nil, 90},
+		{util.StrPtr("30"), 30},
+		{util.StrPtr("0"), 0},
+		{util.StrPtr("-1"), 90},
+		{util.StrPtr("monthly"), 90},
+	}
+	for _, test := range tests {
+		ds := keyRotationDS{xmlID: "ds1", rotationDays: test.value}
+		if actual := keyRotationDays(ds, 90); actual != test.expected {
+			t.Errorf("expected %d rotation days for parameter value %v, got %d", test.expected, test.value, actual)
+		}
+	}
+}
+
+func TestKeyRotationDue(t *testing.T) {
+	lastUpdated := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if keyRotationDue(lastUpdated, 90, lastUpdated.Add(89*24*time.Hour)) {
+		t.Error("expected keys stored 89 days ago not to be due for a 90 day rotation")
+	}
+	if !keyRotationDue(lastUpdated, 90, lastUpdated.Add(90*24*time.Hour)) {
+		t.Error("expected keys stored 90 days ago to be due for a 90 day rotation")
+	}
+}
+
+func TestCurrentSecretVersion(t *testing.T) {
+	if currentSecretVersion(nil) != nil {
+		t.Error("expected no current version of a secret without versions")
+	}
+	versions := []tc.TrafficVaultSecretVersion{{Version: 3}, {Version: 2, Current: true}, {Version: 1}}
+	current := currentSecretVersion(versions)
+	if current == nil || current.Version != 2 {
+		t.Errorf("expected version 2 to be current, got %+v", current)
+	}
+}
+
+func TestRotateURLSigKeys(t *testing.T) {
+	keys, err := GenerateURLSigKeys()
+	if err != nil {
+		t.Fatalf("generating URL sig keys: %v", err)
+	}
+
+	for _, version := range []int64{2, 3} {
+		rotated, err := rotateURLSigKeys(keys, version)
+		if err != nil {
+			t.Fatalf("rotating URL sig keys: %v", err)
+		}
+		if len(rotated) != len(keys) {
+			t.Fatalf("expected %d rotated keys, got %d", len(keys), len(rotated))
+		}
+		first := int(version%2) * urlSigKeysPerRotation
+		for i := 0; i < len(keys); i++ {
+			name := "key" + strconv.Itoa(i)
+			replaced := i >= first && i < first+urlSigKeysPerRotation
+			if replaced && rotated[name] == keys[name] {
+				t.Errorf("expected version %d to replace %s", version, name)
+			} else if !replaced && rotated[name] != keys[name] {
+				t.Errorf("expected version %d to keep %s", version, name)
+			}
+		}
+	}
+}
+
+func TestRotateURISigningKeys(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rotatedJSON, err := rotateURISigningKeys([]byte(uriSigningKeysToRotate), now)
+	if err != nil {
+		t.Fatalf("rotating URI signing keys: %v", err)
+	}
+	keySets := tc.JWKSMap{}
+	if err := json.Unmarshal(rotatedJSON, &keySets); err != nil {
+		t.Fatalf("unmarshalling rotated URI signing keys: %v", err)
+	}
+
+	set := keySets["Kabletown URI Authority 1"]
+	renewalKid := tc.GetRenewalKid(set)
+	if renewalKid == nil || *renewalKid != "rotated-20240101T000000Z" {
+		t.Fatalf("expected the new key to be the renewal key, got %v", renewalKid)
+	}
+	if set.Len() != 2 {
+		t.Errorf("expected the rotated key set to have 2 keys, got %d", set.Len())
+	}
+	if _, ok := set.LookupKeyID("Second Key"); !ok {
+		t.Error("expected the previous renewal key to be kept")
+	}
+	if _, ok := set.LookupKeyID("First Key"); ok {
+		t.Error("expected keys older than the previous renewal key to be removed")
+	}
+	newKey, ok := set.LookupKeyID(*renewalKid)
+	if !ok {
+		t.Fatal("expected the rotated key set to contain the renewal key")
+	}
+	if newKey.Algorithm() != "HS256" {
+		t.Errorf("expected the new key to use the algorithm of the previous renewal key, got %s", newKey.Algorithm())
+	}
+	if other := keySets["Kabletown URI Authority 2"]; other == nil || other.Len() != 1 {
+		t.Error("expected key sets without the renewal key to be unchanged")
+	}
+
+	if _, err := rotateURISigningKeys([]byte(`{"issuer": {"keys": []}}`), now); err == nil {
+		t.Error("expected an error rotating URI signing keys without a renewal key")
+	}
+}
+
+func TestRotateURISigningKeysWithTwoIssuers(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rotatedJSON, err := rotateURISigningKeys([]byte(uriSigningKeysWithTwoRenewalKeys), now)
+	if err != nil {
+		t.Fatalf("rotating URI signing keys: %v", err)
+	}
+	keySets := tc.JWKSMap{}
+	if err := json.Unmarshal(rotatedJSON, &keySets); err != nil {
+		t.Fatalf("unmarshalling rotated URI signing keys: %v", err)
+	}
+
+	for issuer, previousKid := range map[string]string{"Kabletown URI Authority 1": "First Key", "Kabletown URI Authority 2": "Second Key"} {
+		set := keySets[issuer]
+		if set == nil {
+			t.Errorf("expected issuer '%s' to be kept", issuer)
+			continue
+		}
+		renewalKid := tc.GetRenewalKid(set)
+		if renewalKid == nil || *renewalKid != "rotated-20240101T000000Z" {
+			t.Errorf("expected the new key of issuer '%s' to be its renewal key, got %v", issuer, renewalKid)
+		}
+		if set.Len() != 2 {
+			t.Errorf("expected the rotated key set of issuer '%s' to have 2 keys, got %d", issuer, set.Len())
+		}
+		if _, ok := set.LookupKeyID(previousKid); !ok {
+			t.Errorf("expected the previous renewal key of issuer '%s' to be kept", issuer)
+		}
+	}
+}
+
+func TestQueueKeyRotationUpdates(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE public.server").WithArgs("mso-topology", int64(2)).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery("SELECT server FROM deliveryservice_server").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"server"}).AddRow(10).AddRow(11))
+	mock.ExpectExec("UPDATE public.server").WithArgs(int64(10)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE public.server").WithArgs(int64(11)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := mockDB.Begin()
+	if err != nil {
+		t.Fatalf("creating transaction: %v", err)
+	}
+	if err := queueKeyRotationUpdates(tx, keyRotationDS{id: 1, cdnID: 2, topology: util.StrPtr("mso-topology")}); err != nil {
+		t.Errorf("queueing updates for a topology delivery service: %v", err)
+	}
+	if err := queueKeyRotationUpdates(tx, keyRotationDS{id: 1, cdnID: 2}); err != nil {
+		t.Errorf("queueing updates for a delivery service with assigned servers: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("committing transaction: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expected queries were not run: %v", err)
+	}
+}
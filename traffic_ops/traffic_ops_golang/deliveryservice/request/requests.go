@@ -411,43 +411,36 @@ func (d dsrManipulationResult) String() string {
 	return builder.String()
 }
 
-func createV5(w http.ResponseWriter, r *http.Request, inf *api.Info) (result dsrManipulationResult) {
+// Create validates the given new Delivery Service Request, checks that the
+// requesting user is authorized on its Tenant, and creates it, as a POST to
+// /deliveryservice_requests does. It returns - in order - an HTTP status code,
+// a user-facing error, and a system error.
+//
+// Warning: this assumes inf isn't nil, and neither is dsr, inf.Tx or inf.User or inf.Tx.Tx.
+func Create(dsr *tc.DeliveryServiceRequestV5, inf *api.Info) (int, error, error) {
 	tx := inf.Tx.Tx
-	var dsr tc.DeliveryServiceRequestV5
-	if err := json.NewDecoder(r.Body).Decode(&dsr); err != nil {
-		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("decoding: %w", err), nil)
-		return
-	}
-	if userErr, sysErr := validateV5(dsr, tx); userErr != nil || sysErr != nil {
-		api.HandleErr(w, r, tx, http.StatusBadRequest, userErr, sysErr)
-		return
+	if userErr, sysErr := validateV5(*dsr, tx); userErr != nil || sysErr != nil {
+		return http.StatusBadRequest, userErr, sysErr
 	}
 
 	if dsr.Status != tc.RequestStatusDraft && dsr.Status != tc.RequestStatusSubmitted {
 		userErr := fmt.Errorf("invalid initial request status '%s' - must be '%s' or '%s'", dsr.Status, tc.RequestStatusDraft, tc.RequestStatusSubmitted)
-		api.HandleErr(w, r, tx, http.StatusBadRequest, userErr, nil)
-		return
+		return http.StatusBadRequest, userErr, nil
 	}
 
-	ok, err := isTenantAuthorized(dsr, inf)
+	ok, err := isTenantAuthorized(*dsr, inf)
 	if err != nil {
-		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
-		return
+		return http.StatusInternalServerError, nil, err
 	}
 	if !ok {
-		api.HandleErr(w, r, tx, http.StatusForbidden, errors.New("not authorized on this tenant"), nil)
-		return
+		return http.StatusForbidden, errors.New("not authorized on this tenant"), nil
 	}
 
 	dsr.SetXMLID()
 	if ok, err = dbhelpers.DSRExistsWithXMLID(dsr.XMLID, tx); err != nil {
-		err = fmt.Errorf("checking for existence of DSR with xmlid '%s'", dsr.XMLID)
-		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
-		return
+		return http.StatusInternalServerError, nil, fmt.Errorf("checking for existence of DSR with xmlid '%s'", dsr.XMLID)
 	} else if ok {
-		userErr := fmt.Errorf("an open Delivery Service Request for XMLID '%s' already exists", dsr.XMLID)
-		api.HandleErr(w, r, tx, http.StatusBadRequest, userErr, nil)
-		return
+		return http.StatusBadRequest, fmt.Errorf("an open Delivery Service Request for XMLID '%s' already exists", dsr.XMLID), nil
 	}
 	if dsr.Original != nil {
 		if len(dsr.Original.TLSVersions) < 1 {
@@ -459,7 +452,17 @@ func createV5(w http.ResponseWriter, r *http.Request, inf *api.Info) (result dsr
 			dsr.Requested.TLSVersions = nil
 		}
 	}
-	errCode, userErr, sysErr := insert(&dsr, inf)
+	return insert(dsr, inf)
+}
+
+func createV5(w http.ResponseWriter, r *http.Request, inf *api.Info) (result dsrManipulationResult) {
+	tx := inf.Tx.Tx
+	var dsr tc.DeliveryServiceRequestV5
+	if err := json.NewDecoder(r.Body).Decode(&dsr); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("decoding: %w", err), nil)
+		return
+	}
+	errCode, userErr, sysErr := Create(&dsr, inf)
 	if userErr != nil || sysErr != nil {
 		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
 		return
@@ -0,0 +1,196 @@
+package rollout
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/util/monitorhlp"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// evaluatorLockID identifies the PostgreSQL advisory lock held while rollouts
+// are being evaluated, so that only one Traffic Ops instance advances them.
+const evaluatorLockID = 7238110492
+
+var evaluatorOnce sync.Once
+
+// monitorData is the health data of a CDN, as reported by one of its Traffic Monitors.
+type monitorData struct {
+	crStates tc.CRStates
+	stats    tc.Stats
+}
+
+// InitRolloutEvaluator starts a background job which periodically checks the
+// health of every running rollout's released servers, and releases the next
+// wave, completes the rollout, or pauses or rolls it back accordingly.
+func InitRolloutEvaluator(cfg config.ConfigRollout, db *sqlx.DB, timeout time.Duration) {
+	evaluatorOnce.Do(func() {
+		go func() {
+			for {
+				evaluateRollouts(db, timeout)
+				time.Sleep(time.Duration(cfg.CheckIntervalSec) * time.Second)
+			}
+		}()
+	})
+}
+
+func evaluateRollouts(db *sqlx.DB, timeout time.Duration) {
+	lockTx, err := db.Begin()
+	if err != nil {
+		log.Errorln("evaluating rollouts: beginning transaction: " + err.Error())
+		return
+	}
+	defer func() {
+		if err := lockTx.Commit(); err != nil {
+			log.Errorln("evaluating rollouts: committing transaction: " + err.Error())
+		}
+	}()
+	locked := false
+	if err := lockTx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, evaluatorLockID).Scan(&locked); err != nil {
+		log.Errorln("evaluating rollouts: acquiring lock: " + err.Error())
+		return
+	}
+	if !locked {
+		log.Debugln("rollouts are being evaluated by another Traffic Ops instance, skipping")
+		return
+	}
+
+	rollouts, err := getRollouts(lockTx, `WHERE r.status = $1`, tc.RolloutStatusRunning)
+	if err != nil {
+		log.Errorln("evaluating rollouts: " + err.Error())
+		return
+	}
+	if len(rollouts) == 0 {
+		return
+	}
+	monitors, err := monitorhlp.GetURLs(lockTx)
+	if err != nil {
+		log.Errorln("evaluating rollouts: getting monitors: " + err.Error())
+		return
+	}
+	client, err := monitorhlp.GetClient(lockTx)
+	if err != nil {
+		log.Errorln("evaluating rollouts: getting monitor client: " + err.Error())
+		return
+	}
+
+	data := map[tc.CDNName]monitorData{}
+	now := time.Now()
+	for _, rollout := range rollouts {
+		cdnData, ok := data[rollout.CDNName]
+		if !ok {
+			if cdnData, err = getMonitorData(monitors[rollout.CDNName], client); err != nil {
+				// Without health data, no decision can be made either way; the rollout just waits.
+				log.Warnf("evaluating rollout %d: CDN %s: %v", rollout.ID, rollout.CDNName, err)
+				continue
+			}
+			data[rollout.CDNName] = cdnData
+		}
+		d := decide(rollout, cdnData.crStates, cdnData.stats, now)
+		if d.kind == decisionWait {
+			continue
+		}
+		if err := applyDecision(db, timeout, rollout, d); err != nil {
+			log.Errorf("evaluating rollout %d: %v", rollout.ID, err)
+		}
+	}
+}
+
+// getMonitorData gets the cache states and stats from the first of the given
+// Traffic Monitors which responds.
+func getMonitorData(monitorFQDNs []string, client *http.Client) (monitorData, error) {
+	if len(monitorFQDNs) == 0 {
+		return monitorData{}, errors.New("no online monitors")
+	}
+	errs := []error{}
+	for _, fqdn := range monitorFQDNs {
+		crStates, err := monitorhlp.GetCRStates(fqdn, client)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("getting CRStates from %s: %w", fqdn, err))
+			continue
+		}
+		stats, _, err := monitorhlp.GetCacheStatsHistory(fqdn, client, []string{ErrorResponsesStat, RequestsStat}, StatHistoryCount)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("getting cache stats from %s: %w", fqdn, err))
+			continue
+		}
+		return monitorData{crStates: crStates, stats: stats}, nil
+	}
+	return monitorData{}, util.JoinErrs(errs)
+}
+
+// applyDecision carries out the given decision for the given rollout in its
+// own transaction.
+func applyDecision(db *sqlx.DB, timeout time.Duration, rollout tc.Rollout, d decision) error {
+	user, userErr, sysErr, _ := auth.GetCurrentUserFromDB(db, rollout.Username, timeout)
+	if userErr != nil || sysErr != nil {
+		return fmt.Errorf("getting rollout user '%s': %v", rollout.Username, util.JoinErrs([]error{userErr, sysErr}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("beginning transaction: " + err.Error())
+	}
+	action, err := applyDecisionTx(tx, rollout, d)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Errorln("rolling back rollout transaction: " + rollbackErr.Error())
+		}
+		return err
+	}
+	rolloutChangeLog(tx, &user, rollout, action)
+	if err := tx.Commit(); err != nil {
+		return errors.New("committing transaction: " + err.Error())
+	}
+	log.Infof("rollout %d %s", rollout.ID, action)
+	return nil
+}
+
+// applyDecisionTx carries out the given decision, and returns a description of
+// what was done for the change log.
+func applyDecisionTx(tx *sql.Tx, rollout tc.Rollout, d decision) (string, error) {
+	switch d.kind {
+	case decisionAdvance:
+		return fmt.Sprintf("released wave %d of %d", d.wave, len(rollout.Steps)), releaseWave(tx, rollout.ID, d.wave)
+	case decisionComplete:
+		return "completed", setStatus(tx, rollout.ID, tc.RolloutStatusCompleted, "all waves released")
+	case decisionRegress:
+		if rollout.OnRegression == tc.RolloutOnRegressionHalt {
+			return "halted: " + d.reason, halt(tx, rollout.ID, d.reason)
+		}
+		return "paused: " + d.reason, setStatus(tx, rollout.ID, tc.RolloutStatusPaused, d.reason)
+	}
+	return "", fmt.Errorf("unknown decision %d", d.kind)
+}
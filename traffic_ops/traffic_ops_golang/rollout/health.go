@@ -0,0 +1,174 @@
+package rollout
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// The Traffic Monitor cache stats used to calculate the error rate of released servers,
+// named as Traffic Monitor's /publish/CacheStatsNew names them, with the "ats." prefix of ATS stats.
+// Both are counters, so the error rate is calculated from their change over the polled history.
+const (
+	ErrorResponsesStat = "ats.proxy.process.http.5xx_responses"
+	RequestsStat       = "ats.proxy.process.http.incoming_requests"
+)
+
+// StatHistoryCount is the number of polled values of each stat requested from
+// Traffic Monitor when calculating error rates.
+const StatHistoryCount = 30
+
+type decisionKind int
+
+const (
+	decisionWait decisionKind = iota
+	decisionAdvance
+	decisionComplete
+	decisionRegress
+)
+
+// decision is what should be done with a running rollout.
+type decision struct {
+	kind   decisionKind
+	wave   int    // the wave to release, if kind is decisionAdvance
+	reason string // why the rollout regressed, if kind is decisionRegress
+}
+
+// decide returns what should be done with the given running rollout, given the
+// current cache states and stats from Traffic Monitor.
+//
+// A rollout regresses as soon as any released server fails its health checks.
+// Otherwise, the next wave with servers is released once every released server
+// has applied its update, and the current wave has been baking for at least the
+// rollout's bake time. The rollout completes when there is no next wave.
+func decide(rollout tc.Rollout, crStates tc.CRStates, stats tc.Stats, now time.Time) decision {
+	if reason := checkHealth(rollout, crStates, stats); reason != "" {
+		return decision{kind: decisionRegress, reason: reason}
+	}
+
+	waves := map[int]int{}
+	for _, server := range rollout.Servers {
+		waves[server.ID] = server.Wave
+		if server.ReleasedAt != nil && server.UpdatePending {
+			return decision{kind: decisionWait}
+		}
+	}
+	if now.Sub(rollout.WaveStartedAt) < time.Duration(rollout.BakeTimeSeconds)*time.Second {
+		return decision{kind: decisionWait}
+	}
+
+	wave, ok := nextWave(waves, rollout.Wave, len(rollout.Steps)+1)
+	if !ok {
+		return decision{kind: decisionComplete}
+	}
+	return decision{kind: decisionAdvance, wave: wave}
+}
+
+// checkHealth returns why the released servers of the rollout are unhealthy,
+// or an empty string if they are healthy.
+//
+// Only servers which have applied their update are checked against Traffic
+// Monitor, and servers Traffic Monitor has no data for are ignored.
+func checkHealth(rollout tc.Rollout, crStates tc.CRStates, stats tc.Stats) string {
+	checked := 0
+	available := 0
+	errorResponses := 0.0
+	requests := 0.0
+	for _, server := range rollout.Servers {
+		if server.ReleasedAt == nil {
+			continue
+		}
+		if server.UpdateFailed {
+			return fmt.Sprintf("server %s failed to apply its update", server.HostName)
+		}
+		if server.UpdatePending {
+			continue
+		}
+		if avail, ok := crStates.Caches[tc.CacheName(server.HostName)]; ok {
+			checked++
+			if avail.IsAvailable {
+				available++
+			}
+		}
+		serverStats, ok := stats.Caches[server.HostName]
+		if !ok {
+			continue
+		}
+		serverErrors, okErrors := counterDelta(serverStats.Stats[ErrorResponsesStat], *server.ReleasedAt)
+		serverRequests, okRequests := counterDelta(serverStats.Stats[RequestsStat], *server.ReleasedAt)
+		if okErrors && okRequests {
+			errorResponses += serverErrors
+			requests += serverRequests
+		}
+	}
+
+	if checked > 0 && float64(available)/float64(checked) < rollout.MinAvailability {
+		return fmt.Sprintf("%d of %d updated servers are available, below the minimum availability of %g", available, checked, rollout.MinAvailability)
+	}
+	if requests > 0 && errorResponses/requests > rollout.MaxErrorRate {
+		return fmt.Sprintf("updated servers have an error rate of %.4f, above the maximum error rate of %g", errorResponses/requests, rollout.MaxErrorRate)
+	}
+	return ""
+}
+
+// counterDelta returns how much the counter with the given history increased
+// between the oldest and newest values polled since the given time. It returns
+// false if there are fewer than two such values, or the counter was reset.
+func counterDelta(history []tc.ResultStatVal, since time.Time) (float64, bool) {
+	var oldest, newest *tc.ResultStatVal
+	for i := range history {
+		val := &history[i]
+		if val.Time.Before(since) {
+			continue
+		}
+		if oldest == nil || val.Time.Before(oldest.Time) {
+			oldest = val
+		}
+		if newest == nil || val.Time.After(newest.Time) {
+			newest = val
+		}
+	}
+	if oldest == nil || oldest == newest {
+		return 0, false
+	}
+	oldVal, oldOK := statFloat(oldest.Val)
+	newVal, newOK := statFloat(newest.Val)
+	if !oldOK || !newOK || newVal < oldVal {
+		return 0, false
+	}
+	return newVal - oldVal, true
+}
+
+// statFloat returns the numeric value of a stat polled by Traffic Monitor,
+// which may be a JSON number or a string.
+func statFloat(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,565 @@
+package rollout
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc/tovalidate"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/auth"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/dbhelpers"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/lib/pq"
+)
+
+// Defaults for the optional fields of a RolloutRequest.
+const (
+	DefaultBakeTimeSeconds = 300
+	DefaultMinAvailability = 0.9
+	DefaultMaxErrorRate    = 0.05
+	DefaultOnRegression    = tc.RolloutOnRegressionPause
+)
+
+const readQuery = `
+SELECT
+  r.id,
+  r.topology,
+  r.cdn_id,
+  c.name,
+  r.status,
+  r.status_reason,
+  r.steps,
+  r.wave,
+  r.wave_started_at,
+  r.bake_time_seconds,
+  r.min_availability,
+  r.max_error_rate,
+  r.on_regression,
+  r.username,
+  r.last_updated
+FROM rollout r
+JOIN cdn c ON c.id = r.cdn_id
+`
+
+const serversQuery = `
+SELECT
+  rs.rollout_id,
+  s.id,
+  s.host_name,
+  cg.name,
+  rs.wave,
+  rs.released_at,
+  s.config_update_time > s.config_apply_time,
+  s.config_update_failed
+FROM rollout_server rs
+JOIN server s ON s.id = rs.server_id
+JOIN cachegroup cg ON cg.id = s.cachegroup
+WHERE rs.rollout_id = ANY($1)
+ORDER BY rs.wave, s.host_name
+`
+
+const candidatesQuery = `
+SELECT s.id, s.host_name, cg.name
+FROM server s
+JOIN cachegroup cg ON cg.id = s.cachegroup
+JOIN topology_cachegroup tc ON tc.cachegroup = cg.name
+WHERE tc.topology = $1
+AND s.cdn_id = $2
+`
+
+const insertQuery = `
+INSERT INTO rollout (
+  topology,
+  cdn_id,
+  steps,
+  bake_time_seconds,
+  min_availability,
+  max_error_rate,
+  on_regression,
+  username
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id
+`
+
+// Get is the handler for GET requests to /rollouts.
+func Get(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	cols := map[string]dbhelpers.WhereColumnInfo{
+		"id":       {Column: "r.id", Checker: api.IsInt},
+		"topology": {Column: "r.topology", Checker: nil},
+		"cdnId":    {Column: "r.cdn_id", Checker: api.IsInt},
+		"status":   {Column: "r.status", Checker: nil},
+	}
+	if _, ok := inf.Params["orderby"]; !ok {
+		inf.Params["orderby"] = "id"
+	}
+	where, orderBy, pagination, queryValues, errs := dbhelpers.BuildWhereAndOrderByAndPagination(inf.Params, cols)
+	if len(errs) > 0 {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusBadRequest, util.JoinErrs(errs), nil)
+		return
+	}
+
+	rows, err := inf.Tx.NamedQuery(readQuery+where+orderBy+pagination, queryValues)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("querying rollouts: "+err.Error()))
+		return
+	}
+	rollouts, err := scanRollouts(rows.Rows)
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if err := addServers(inf.Tx.Tx, rollouts); err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	api.WriteResp(w, r, rollouts)
+}
+
+// Create is the handler for POST requests to /rollouts. It starts a rollout,
+// and immediately queues updates for its canary servers.
+func Create(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, nil, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	req := tc.RolloutRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("malformed JSON: "+err.Error()), nil)
+		return
+	}
+	setDefaults(&req)
+	if err := validate(req, tx); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("invalid rollout: %w", err), nil)
+		return
+	}
+
+	cdnName, _, err := dbhelpers.GetCDNNameFromID(tx, int64(req.CDNID))
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("getting CDN name from ID %d: %w", req.CDNID, err))
+		return
+	}
+	userErr, sysErr, errCode = dbhelpers.CheckIfCurrentUserHasCdnLock(tx, string(cdnName), inf.User.UserName)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+
+	active := false
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM rollout WHERE topology = $1 AND cdn_id = $2 AND status IN ($3, $4))`, req.Topology, req.CDNID, tc.RolloutStatusRunning, tc.RolloutStatusPaused).Scan(&active); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("checking for active rollouts: "+err.Error()))
+		return
+	}
+	if active {
+		api.HandleErr(w, r, tx, http.StatusConflict, fmt.Errorf("topology %s already has a running or paused rollout in CDN %s", req.Topology, cdnName), nil)
+		return
+	}
+
+	candidates, err := getCandidates(tx, req.Topology, req.CDNID)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if len(candidates) == 0 {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("topology %s has no servers in CDN %s", req.Topology, cdnName), nil)
+		return
+	}
+	waves, err := assignWaves(candidates, req.CanaryServerIDs, req.Steps)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	id := 0
+	if err := tx.QueryRow(insertQuery, req.Topology, req.CDNID, pq.Array(req.Steps), *req.BakeTimeSeconds, *req.MinAvailability, *req.MaxErrorRate, *req.OnRegression, inf.User.UserName).Scan(&id); err != nil {
+		userErr, sysErr, errCode := api.ParseDBError(err)
+		api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+		return
+	}
+	serverIDs := make([]int64, 0, len(waves))
+	serverWaves := make([]int64, 0, len(waves))
+	for serverID, wave := range waves {
+		serverIDs = append(serverIDs, int64(serverID))
+		serverWaves = append(serverWaves, int64(wave))
+	}
+	if _, err := tx.Exec(`INSERT INTO rollout_server (rollout_id, server_id, wave) SELECT $1, UNNEST($2::bigint[]), UNNEST($3::integer[])`, id, pq.Array(serverIDs), pq.Array(serverWaves)); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, errors.New("inserting rollout servers: "+err.Error()))
+		return
+	}
+	if err := releaseWave(tx, id, CanaryWave); err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+
+	rollout, ok, err := getRollout(tx, id)
+	if err != nil || !ok {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("reading created rollout %d: %v", id, err))
+		return
+	}
+	api.CreateChangeLogRawTx(api.ApiChange, fmt.Sprintf("TOPOLOGY: %s, CDN: %s, ACTION: Rollout %d started, canary server updates queued", req.Topology, cdnName, id), inf.User, tx)
+	api.WriteAlertsObj(w, r, http.StatusCreated, tc.CreateAlerts(tc.SuccessLevel, fmt.Sprintf("Rollout %d started", id)), rollout)
+}
+
+// Action is the handler for POST requests to /rollouts/{id}/action, which
+// pause, resume, advance, or halt a rollout.
+func Action(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"id"}, []string{"id"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+	tx := inf.Tx.Tx
+
+	req := tc.RolloutActionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("malformed JSON: "+err.Error()), nil)
+		return
+	}
+
+	id := inf.IntParams["id"]
+	rollout, ok, err := getRollout(tx, id)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	if !ok {
+		api.HandleErr(w, r, tx, http.StatusNotFound, fmt.Errorf("no rollout exists with id %d", id), nil)
+		return
+	}
+	if rollout.Status != tc.RolloutStatusRunning && rollout.Status != tc.RolloutStatusPaused {
+		api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("rollout %d is already %s", id, rollout.Status), nil)
+		return
+	}
+
+	// Pausing and halting only ever stop updates, so they're allowed without the CDN lock.
+	if req.Action == tc.RolloutActionResume || req.Action == tc.RolloutActionAdvance {
+		userErr, sysErr, errCode := dbhelpers.CheckIfCurrentUserHasCdnLock(tx, string(rollout.CDNName), inf.User.UserName)
+		if userErr != nil || sysErr != nil {
+			api.HandleErr(w, r, tx, errCode, userErr, sysErr)
+			return
+		}
+	}
+
+	reason := fmt.Sprintf("%sd by %s", req.Action, inf.User.UserName)
+	switch req.Action {
+	case tc.RolloutActionPause:
+		if rollout.Status != tc.RolloutStatusRunning {
+			api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("rollout %d is not running", id), nil)
+			return
+		}
+		err = setStatus(tx, id, tc.RolloutStatusPaused, reason)
+	case tc.RolloutActionResume:
+		if rollout.Status != tc.RolloutStatusPaused {
+			api.HandleErr(w, r, tx, http.StatusBadRequest, fmt.Errorf("rollout %d is not paused", id), nil)
+			return
+		}
+		if err = setStatus(tx, id, tc.RolloutStatusRunning, reason); err == nil {
+			_, err = tx.Exec(`UPDATE rollout SET wave_started_at = now() WHERE id = $1`, id) // restart the bake time of the current wave
+		}
+	case tc.RolloutActionAdvance:
+		err = advance(tx, rollout)
+	case tc.RolloutActionHalt:
+		err = halt(tx, id, reason)
+	default:
+		api.HandleErr(w, r, tx, http.StatusBadRequest, errors.New("action must be 'pause', 'resume', 'advance', or 'halt'"), nil)
+		return
+	}
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, fmt.Errorf("performing rollout %d action %s: %w", id, req.Action, err))
+		return
+	}
+
+	rollout, _, err = getRollout(tx, id)
+	if err != nil {
+		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
+		return
+	}
+	rolloutChangeLog(tx, inf.User, rollout, req.Action)
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, fmt.Sprintf("Rollout %d is %s", id, rollout.Status), rollout)
+}
+
+func setDefaults(req *tc.RolloutRequest) {
+	if req.BakeTimeSeconds == nil {
+		req.BakeTimeSeconds = util.IntPtr(DefaultBakeTimeSeconds)
+	}
+	if req.MinAvailability == nil {
+		req.MinAvailability = util.FloatPtr(DefaultMinAvailability)
+	}
+	if req.MaxErrorRate == nil {
+		req.MaxErrorRate = util.FloatPtr(DefaultMaxErrorRate)
+	}
+	if req.OnRegression == nil {
+		req.OnRegression = util.StrPtr(DefaultOnRegression)
+	}
+}
+
+func validate(req tc.RolloutRequest, tx *sql.Tx) error {
+	errorMap := validation.Errors{}
+	if err := validateSteps(req.Steps); err != nil {
+		errorMap["steps"] = err
+	}
+	if len(req.CanaryServerIDs) == 0 {
+		errorMap["canaryServerIds"] = errors.New("at least one canary server is required")
+	}
+	if *req.BakeTimeSeconds < 0 {
+		errorMap["bakeTimeSeconds"] = errors.New("must not be negative")
+	}
+	if *req.MinAvailability < 0 || *req.MinAvailability > 1 {
+		errorMap["minAvailability"] = errors.New("must be between 0 and 1")
+	}
+	if *req.MaxErrorRate < 0 || *req.MaxErrorRate > 1 {
+		errorMap["maxErrorRate"] = errors.New("must be between 0 and 1")
+	}
+	if *req.OnRegression != tc.RolloutOnRegressionPause && *req.OnRegression != tc.RolloutOnRegressionHalt {
+		errorMap["onRegression"] = errors.New("must be 'pause' or 'halt'")
+	}
+
+	if _, cdnExists, err := dbhelpers.GetCDNNameFromID(tx, int64(req.CDNID)); err != nil {
+		errorMap["cdnId"] = fmt.Errorf("could not check whether cdn exists for id %d", req.CDNID)
+	} else if !cdnExists {
+		errorMap["cdnId"] = fmt.Errorf("no cdn exists with id %d", req.CDNID)
+	}
+	if topologyExists, err := dbhelpers.TopologyExists(tx, string(req.Topology)); err != nil {
+		errorMap["topology"] = fmt.Errorf("could not check whether topology %s exists", req.Topology)
+	} else if !topologyExists {
+		errorMap["topology"] = fmt.Errorf("no topology exists by the name of %s", req.Topology)
+	}
+
+	return util.JoinErrs(tovalidate.ToErrors(errorMap))
+}
+
+func scanRollouts(rows *sql.Rows) ([]tc.Rollout, error) {
+	defer log.Close(rows, "closing rollout rows")
+	rollouts := []tc.Rollout{}
+	for rows.Next() {
+		rollout := tc.Rollout{Servers: []tc.RolloutServer{}}
+		steps := []int64{}
+		if err := rows.Scan(
+			&rollout.ID,
+			&rollout.Topology,
+			&rollout.CDNID,
+			&rollout.CDNName,
+			&rollout.Status,
+			&rollout.StatusReason,
+			pq.Array(&steps),
+			&rollout.Wave,
+			&rollout.WaveStartedAt,
+			&rollout.BakeTimeSeconds,
+			&rollout.MinAvailability,
+			&rollout.MaxErrorRate,
+			&rollout.OnRegression,
+			&rollout.Username,
+			&rollout.LastUpdated,
+		); err != nil {
+			return nil, errors.New("scanning rollouts: " + err.Error())
+		}
+		for _, step := range steps {
+			rollout.Steps = append(rollout.Steps, int(step))
+		}
+		rollouts = append(rollouts, rollout)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New("iterating rollouts: " + err.Error())
+	}
+	return rollouts, nil
+}
+
+// addServers sets the Servers of each of the given rollouts.
+func addServers(tx *sql.Tx, rollouts []tc.Rollout) error {
+	if len(rollouts) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(rollouts))
+	idx := make(map[int]int, len(rollouts))
+	for i, rollout := range rollouts {
+		ids = append(ids, int64(rollout.ID))
+		idx[rollout.ID] = i
+	}
+	rows, err := tx.Query(serversQuery, pq.Array(ids))
+	if err != nil {
+		return errors.New("querying rollout servers: " + err.Error())
+	}
+	defer log.Close(rows, "closing rollout server rows")
+	for rows.Next() {
+		rolloutID := 0
+		server := tc.RolloutServer{}
+		if err := rows.Scan(&rolloutID, &server.ID, &server.HostName, &server.CacheGroup, &server.Wave, &server.ReleasedAt, &server.UpdatePending, &server.UpdateFailed); err != nil {
+			return errors.New("scanning rollout servers: " + err.Error())
+		}
+		i := idx[rolloutID]
+		rollouts[i].Servers = append(rollouts[i].Servers, server)
+	}
+	return rows.Err()
+}
+
+// getRollout returns the rollout with the given ID, and whether it exists.
+func getRollout(tx *sql.Tx, id int) (tc.Rollout, bool, error) {
+	rollouts, err := getRollouts(tx, `WHERE r.id = $1`, id)
+	if err != nil || len(rollouts) == 0 {
+		return tc.Rollout{}, false, err
+	}
+	return rollouts[0], true, nil
+}
+
+// getRollouts returns the rollouts matching the given WHERE clause, with their servers.
+func getRollouts(tx *sql.Tx, where string, args ...interface{}) ([]tc.Rollout, error) {
+	rows, err := tx.Query(readQuery+where+` ORDER BY r.id`, args...)
+	if err != nil {
+		return nil, errors.New("querying rollouts: " + err.Error())
+	}
+	rollouts, err := scanRollouts(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := addServers(tx, rollouts); err != nil {
+		return nil, err
+	}
+	return rollouts, nil
+}
+
+// getCandidates returns the servers in the Cache Groups of the given Topology
+// in the given CDN, which are the servers queued by the Topology's queue_update.
+func getCandidates(tx *sql.Tx, topology tc.TopologyName, cdnID int) ([]candidate, error) {
+	rows, err := tx.Query(candidatesQuery, topology, cdnID)
+	if err != nil {
+		return nil, errors.New("querying topology servers: " + err.Error())
+	}
+	defer log.Close(rows, "closing topology server rows")
+	candidates := []candidate{}
+	for rows.Next() {
+		c := candidate{}
+		if err := rows.Scan(&c.id, &c.hostName, &c.cacheGroup); err != nil {
+			return nil, errors.New("scanning topology servers: " + err.Error())
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// releaseWave queues updates for the servers of the given wave, and makes it
+// the rollout's current wave.
+func releaseWave(tx *sql.Tx, id int, wave int) error {
+	serverIDs, err := getServerIDs(tx, `SELECT server_id FROM rollout_server WHERE rollout_id = $1 AND wave = $2`, id, wave)
+	if err != nil {
+		return fmt.Errorf("getting servers of wave %d: %w", wave, err)
+	}
+	for _, serverID := range serverIDs {
+		if err := dbhelpers.QueueUpdateForServer(tx, serverID); err != nil {
+			return fmt.Errorf("queueing updates for wave %d: %w", wave, err)
+		}
+	}
+	if _, err := tx.Exec(`UPDATE rollout_server SET released_at = now() WHERE rollout_id = $1 AND wave = $2`, id, wave); err != nil {
+		return fmt.Errorf("releasing wave %d: %w", wave, err)
+	}
+	if _, err := tx.Exec(`UPDATE rollout SET wave = $2, wave_started_at = now() WHERE id = $1`, id, wave); err != nil {
+		return fmt.Errorf("setting current wave to %d: %w", wave, err)
+	}
+	return nil
+}
+
+// getServerIDs returns the server IDs selected by the given query.
+func getServerIDs(tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer log.Close(rows, "closing rollout server rows")
+	serverIDs := []int64{}
+	for rows.Next() {
+		var serverID int64
+		if err := rows.Scan(&serverID); err != nil {
+			return nil, err
+		}
+		serverIDs = append(serverIDs, serverID)
+	}
+	return serverIDs, rows.Err()
+}
+
+// advance releases the rollout's next wave with servers, or completes the
+// rollout if there is none.
+func advance(tx *sql.Tx, rollout tc.Rollout) error {
+	waves := make(map[int]int, len(rollout.Servers))
+	for _, server := range rollout.Servers {
+		waves[server.ID] = server.Wave
+	}
+	wave, ok := nextWave(waves, rollout.Wave, len(rollout.Steps)+1)
+	if !ok {
+		return setStatus(tx, rollout.ID, tc.RolloutStatusCompleted, "all waves released")
+	}
+	return releaseWave(tx, rollout.ID, wave)
+}
+
+// halt stops the rollout, and dequeues the pending updates of all of its
+// servers. It is not a rollback: servers which already applied their update
+// keep it, because Traffic Ops cannot regenerate their previous configuration.
+// The data change which caused the regression must be reverted and updates
+// queued for them again, or their previous configuration restored with
+// t3c-rollback. Nor does a rollout keep servers outside of its released waves
+// from picking up the change when updates are queued for them by other means.
+func halt(tx *sql.Tx, id int, reason string) error {
+	serverIDs, err := getServerIDs(tx, `
+SELECT rs.server_id
+FROM rollout_server rs
+JOIN server s ON s.id = rs.server_id
+WHERE rs.rollout_id = $1
+AND s.config_update_time > s.config_apply_time`, id)
+	if err != nil {
+		return errors.New("getting servers with pending updates: " + err.Error())
+	}
+	for _, serverID := range serverIDs {
+		if err := dbhelpers.DequeueUpdateForServer(tx, serverID); err != nil {
+			return errors.New("dequeueing updates: " + err.Error())
+		}
+	}
+	return setStatus(tx, id, tc.RolloutStatusHalted, reason)
+}
+
+func setStatus(tx *sql.Tx, id int, status tc.RolloutStatus, reason string) error {
+	if _, err := tx.Exec(`UPDATE rollout SET status = $2, status_reason = $3 WHERE id = $1`, id, status, reason); err != nil {
+		return fmt.Errorf("setting status to %s: %w", status, err)
+	}
+	return nil
+}
+
+// rolloutChangeLog writes a change log message about a rollout, attributed to the given user.
+func rolloutChangeLog(tx *sql.Tx, user *auth.CurrentUser, rollout tc.Rollout, action string) {
+	api.CreateChangeLogRawTx(api.ApiChange, "TOPOLOGY: "+string(rollout.Topology)+", CDN: "+string(rollout.CDNName)+", ACTION: Rollout "+strconv.Itoa(rollout.ID)+" "+action, user, tx)
+}
@@ -0,0 +1,235 @@
+package rollout
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+)
+
+func TestValidateSteps(t *testing.T) {
+	valid := [][]int{{100}, {10, 50, 100}, {1, 2, 100}}
+	for _, steps := range valid {
+		if err := validateSteps(steps); err != nil {
+			t.Errorf("expected steps %v to be valid, got error: %v", steps, err)
+		}
+	}
+	invalid := [][]int{nil, {}, {50}, {50, 50, 100}, {60, 40, 100}, {0, 100}, {50, 150}}
+	for _, steps := range invalid {
+		if err := validateSteps(steps); err == nil {
+			t.Errorf("expected steps %v to be invalid, got no error", steps)
+		}
+	}
+}
+
+func TestAssignWaves(t *testing.T) {
+	candidates := []candidate{}
+	for i := 1; i <= 10; i++ {
+		candidates = append(candidates, candidate{id: i, hostName: "edge", cacheGroup: "cg1"})
+	}
+	for i := 11; i <= 14; i++ {
+		candidates = append(candidates, candidate{id: i, hostName: "mid", cacheGroup: "cg2"})
+	}
+
+	waves, err := assignWaves(candidates, []int{1, 11}, []int{25, 50, 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(waves) != len(candidates) {
+		t.Fatalf("expected %d servers to be assigned waves, got %d", len(candidates), len(waves))
+	}
+	// cg1: canary 1, then ceil(25% of 10) = 3 updated by wave 1, 5 by wave 2, 10 by wave 3.
+	// cg2: canary 11, then 1 updated by wave 1 (so none added), 2 by wave 2, 4 by wave 3.
+	expected := map[int]int{
+		1: 0, 2: 1, 3: 1, 4: 2, 5: 2, 6: 3, 7: 3, 8: 3, 9: 3, 10: 3,
+		11: 0, 12: 2, 13: 3, 14: 3,
+	}
+	for id, wave := range expected {
+		if waves[id] != wave {
+			t.Errorf("expected server %d to be in wave %d, got %d", id, wave, waves[id])
+		}
+	}
+
+	if _, err := assignWaves(candidates, []int{99}, []int{100}); err == nil {
+		t.Error("expected an error for a canary server which is not in the topology, got none")
+	}
+}
+
+func TestNextWave(t *testing.T) {
+	waves := map[int]int{1: 0, 2: 2, 3: 3}
+	tests := []struct {
+		wave     int
+		expected int
+		ok       bool
+	}{
+		{0, 2, true},
+		{2, 3, true},
+		{3, 0, false},
+	}
+	for _, test := range tests {
+		next, ok := nextWave(waves, test.wave, 4)
+		if ok != test.ok || (ok && next != test.expected) {
+			t.Errorf("nextWave after %d: expected (%d, %t), got (%d, %t)", test.wave, test.expected, test.ok, next, ok)
+		}
+	}
+}
+
+func TestCounterDelta(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []tc.ResultStatVal{
+		{Time: start.Add(3 * time.Minute), Val: "150"},
+		{Time: start.Add(2 * time.Minute), Val: float64(120)},
+		{Time: start.Add(-time.Minute), Val: float64(10)},
+	}
+	delta, ok := counterDelta(history, start)
+	if !ok || delta != 30 {
+		t.Errorf("expected delta of 30, got %g (ok: %t)", delta, ok)
+	}
+	if _, ok := counterDelta(history, start.Add(150*time.Second)); ok {
+		t.Error("expected no delta with a single value since the start time")
+	}
+	reset := []tc.ResultStatVal{
+		{Time: start.Add(time.Minute), Val: float64(100)},
+		{Time: start.Add(2 * time.Minute), Val: float64(5)},
+	}
+	if _, ok := counterDelta(reset, start); ok {
+		t.Error("expected no delta for a counter which was reset")
+	}
+}
+
+func testRollout(now time.Time) tc.Rollout {
+	released := now.Add(-10 * time.Minute)
+	return tc.Rollout{
+		ID:              1,
+		Steps:           []int{50, 100},
+		Wave:            0,
+		WaveStartedAt:   released,
+		BakeTimeSeconds: 300,
+		MinAvailability: 0.9,
+		MaxErrorRate:    0.05,
+		OnRegression:    tc.RolloutOnRegressionPause,
+		Servers: []tc.RolloutServer{
+			{ID: 1, HostName: "edge1", Wave: 0, ReleasedAt: &released},
+			{ID: 2, HostName: "edge2", Wave: 1},
+			{ID: 3, HostName: "edge3", Wave: 2},
+		},
+	}
+}
+
+// cacheStatsNew is a Traffic Monitor /publish/CacheStatsNew response, captured
+// from an ATS cache, with its times and request and error counts replaced by
+// format verbs.
+const cacheStatsNew = `{
+	"pp": "",
+	"date": "Sat Oct 17 12:00:00 UTC 2026",
+	"caches": {
+		"edge1": {
+			"interfaces": {
+				"eth0": {
+					"bandwidth": [{"value": "1285.26", "time": %[2]d, "span": 1}]
+				}
+			},
+			"stats": {
+				"ats.proxy.process.http.current_client_connections": [{"value": "12", "time": %[2]d, "span": 10}],
+				"ats.proxy.process.http.incoming_requests": [
+					{"value": "%[3]v", "time": %[2]d, "span": 1},
+					{"value": "1000", "time": %[1]d, "span": 1}
+				],
+				"ats.proxy.process.http.5xx_responses": [
+					{"value": "%[4]v", "time": %[2]d, "span": 1},
+					{"value": "10", "time": %[1]d, "span": 1}
+				],
+				"system.proc.loadavg": [{"value": "0.12", "time": %[2]d, "span": 1}]
+			}
+		}
+	}
+}`
+
+func testStats(t *testing.T, now time.Time, requests, errorResponses float64) tc.Stats {
+	firstPoll := now.Add(-9 * time.Minute)
+	payload := fmt.Sprintf(cacheStatsNew, firstPoll.UnixMilli(), now.UnixMilli(), 1000+requests, 10+errorResponses)
+	stats := tc.Stats{}
+	if err := json.Unmarshal([]byte(payload), &stats); err != nil {
+		t.Fatalf("unmarshalling CacheStatsNew: %v", err)
+	}
+	return stats
+}
+
+func TestDecide(t *testing.T) {
+	now := time.Now()
+	available := tc.CRStates{Caches: map[tc.CacheName]tc.IsAvailable{"edge1": {IsAvailable: true}}}
+	unavailable := tc.CRStates{Caches: map[tc.CacheName]tc.IsAvailable{"edge1": {IsAvailable: false}}}
+
+	d := decide(testRollout(now), available, testStats(t, now, 1000, 10), now)
+	if d.kind != decisionAdvance || d.wave != 1 {
+		t.Errorf("expected healthy baked rollout to advance to wave 1, got %+v", d)
+	}
+
+	rollout := testRollout(now)
+	rollout.WaveStartedAt = now.Add(-time.Minute)
+	if d := decide(rollout, available, testStats(t, now, 1000, 10), now); d.kind != decisionWait {
+		t.Errorf("expected rollout within its bake time to wait, got %+v", d)
+	}
+
+	rollout = testRollout(now)
+	rollout.Servers[0].UpdatePending = true
+	if d := decide(rollout, unavailable, testStats(t, now, 1000, 1000), now); d.kind != decisionWait {
+		t.Errorf("expected rollout with a pending released server to wait, got %+v", d)
+	}
+
+	if d := decide(testRollout(now), unavailable, testStats(t, now, 1000, 10), now); d.kind != decisionRegress || d.reason == "" {
+		t.Errorf("expected rollout with unavailable servers to regress, got %+v", d)
+	}
+
+	if d := decide(testRollout(now), available, testStats(t, now, 1000, 100), now); d.kind != decisionRegress || d.reason == "" {
+		t.Errorf("expected rollout with a 10%% error rate to regress, got %+v", d)
+	}
+
+	rollout = testRollout(now)
+	rollout.Servers[0].UpdateFailed = true
+	if d := decide(rollout, available, testStats(t, now, 1000, 10), now); d.kind != decisionRegress {
+		t.Errorf("expected rollout with a failed update to regress, got %+v", d)
+	}
+
+	rollout = testRollout(now)
+	rollout.Wave = 2
+	if d := decide(rollout, available, tc.Stats{}, now); d.kind != decisionComplete {
+		t.Errorf("expected rollout in its last wave to complete, got %+v", d)
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+	req := tc.RolloutRequest{OnRegression: util.StrPtr(tc.RolloutOnRegressionHalt)}
+	setDefaults(&req)
+	if req.BakeTimeSeconds == nil || *req.BakeTimeSeconds != DefaultBakeTimeSeconds {
+		t.Errorf("expected default bake time %d, got %v", DefaultBakeTimeSeconds, req.BakeTimeSeconds)
+	}
+	if req.MinAvailability == nil || req.MaxErrorRate == nil {
+		t.Error("expected default health thresholds to be set")
+	}
+	if *req.OnRegression != tc.RolloutOnRegressionHalt {
+		t.Errorf("expected on regression to be kept as %s, got %s", tc.RolloutOnRegressionHalt, *req.OnRegression)
+	}
+}
@@ -0,0 +1,122 @@
+// Package rollout implements staged rollouts of cache server configuration,
+// which queue updates for the servers of a Topology in waves, and release each
+// wave only once the previous one is healthy according to Traffic Monitor.
+package rollout
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// CanaryWave is the wave of the canary servers, which are released first.
+const CanaryWave = 0
+
+// candidate is a server which may be included in a rollout.
+type candidate struct {
+	id         int
+	hostName   string
+	cacheGroup string
+}
+
+// validateSteps returns an error if steps are not strictly increasing
+// percentages ending with 100.
+func validateSteps(steps []int) error {
+	if len(steps) == 0 {
+		return errors.New("at least one step is required")
+	}
+	prev := 0
+	for _, step := range steps {
+		if step <= prev || step > 100 {
+			return fmt.Errorf("steps must be strictly increasing percentages between 1 and 100, got %v", steps)
+		}
+		prev = step
+	}
+	if prev != 100 {
+		return errors.New("the last step must be 100")
+	}
+	return nil
+}
+
+// assignWaves returns the wave of each candidate server, by server ID.
+//
+// The canary servers are in wave 0. Each step i is wave i+1, which includes
+// enough servers of each Cache Group that, with all previous waves, the given
+// percentage of the Cache Group's servers is updated. Servers are assigned in
+// order of ID within each Cache Group, so the assignment is deterministic.
+func assignWaves(candidates []candidate, canaryIDs []int, steps []int) (map[int]int, error) {
+	waves := make(map[int]int, len(candidates))
+	byCG := map[string][]candidate{}
+	for _, c := range candidates {
+		byCG[c.cacheGroup] = append(byCG[c.cacheGroup], c)
+	}
+
+	canaries := map[int]struct{}{}
+	for _, id := range canaryIDs {
+		canaries[id] = struct{}{}
+	}
+	for _, c := range candidates {
+		if _, ok := canaries[c.id]; ok {
+			waves[c.id] = CanaryWave
+		}
+	}
+	if len(waves) != len(canaries) {
+		return nil, errors.New("all canary servers must be in the Topology and CDN of the rollout")
+	}
+
+	for _, servers := range byCG {
+		sort.Slice(servers, func(i, j int) bool { return servers[i].id < servers[j].id })
+		assigned := 0
+		for _, s := range servers {
+			if _, ok := canaries[s.id]; ok {
+				assigned++
+			}
+		}
+		next := 0
+		for i, step := range steps {
+			target := (len(servers)*step + 99) / 100 // round up, so small Cache Groups aren't skipped until the last step
+			for ; assigned < target && next < len(servers); next++ {
+				if _, ok := canaries[servers[next].id]; ok {
+					continue
+				}
+				waves[servers[next].id] = i + 1
+				assigned++
+			}
+		}
+	}
+	return waves, nil
+}
+
+// nextWave returns the first wave after the given wave which has servers, and
+// whether there is one.
+func nextWave(waves map[int]int, wave int, numWaves int) (int, bool) {
+	hasServers := map[int]bool{}
+	for _, w := range waves {
+		hasServers[w] = true
+	}
+	for w := wave + 1; w < numWaves; w++ {
+		if hasServers[w] {
+			return w, true
+		}
+	}
+	return 0, false
+}
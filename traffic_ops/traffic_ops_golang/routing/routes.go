@@ -74,6 +74,7 @@ import (
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/profileparameter"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/region"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/role"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/rollout"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/server"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/servercapability"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/servercheck"
@@ -182,6 +183,10 @@ func Routes(d ServerData) ([]Route, http.Handler, error) {
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `cachegroups/?$`, Handler: cachegroup.CreateCacheGroup, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"CACHE-GROUP:CREATE", "CACHE-GROUP:READ", "TYPE:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 4298266531},
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodDelete, Path: `cachegroups/{id}$`, Handler: cachegroup.DeleteCacheGroup, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"CACHE-GROUP:DELETE", "CACHE-GROUP:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 42786936531},
 
+		// Cache Group latencies, for latency-aware parent preference
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodGet, Path: `cachegroup_latencies/?$`, Handler: cachegroup.GetLatencies, RequiredPrivLevel: auth.PrivLevelReadOnly, RequiredPermissions: []string{"CACHE-GROUP:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 472958110141},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `cachegroup_latencies/?$`, Handler: cachegroup.PostLatencies, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"CACHE-GROUP:UPDATE", "CACHE-GROUP:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 472958110151},
+
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `cachegroups/{id}/queue_update$`, Handler: cachegroup.QueueUpdates, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"CACHE-GROUP:READ", "CDN:READ", "SERVER:READ", "SERVER:QUEUE"}, Authenticated: Authenticated, Middlewares: nil, ID: 407164411031},
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `cachegroups/{id}/deliveryservices/?$`, Handler: cachegroup.DSPostHandlerV40, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"CACHE-GROUP:UPDATE", "DELIVERY-SERVICE:UPDATE", "CACHE-GROUP:READ", "DELIVERY-SERVICE:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 452024043131},
 
@@ -264,6 +269,9 @@ func Routes(d ServerData) ([]Route, http.Handler, error) {
 		//Ping
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodGet, Path: `ping$`, Handler: ping.Handler, RequiredPrivLevel: auth.PrivLevelUnauthenticated, RequiredPermissions: nil, Authenticated: NoAuth, Middlewares: nil, ID: 455566159731},
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodGet, Path: `vault/ping/?$`, Handler: ping.Vault, RequiredPrivLevel: auth.PrivLevelReadOnly, RequiredPermissions: []string{"TRAFFIC-VAULT:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 488401211431},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodGet, Path: `vault/secrets/{type}/{name}/versions/?$`, Handler: vault.GetSecretVersions, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"TRAFFIC-VAULT:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 488401211441},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `vault/secrets/{type}/{name}/versions/{version}/promote/?$`, Handler: vault.PromoteSecretVersion, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"TRAFFIC-VAULT:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 488401211451},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `vault/secrets/{type}/{name}/rollback/?$`, Handler: vault.RollbackSecret, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"TRAFFIC-VAULT:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 488401211461},
 
 		//Profile: CRUD
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodGet, Path: `profiles/?$`, Handler: profile.Read, RequiredPrivLevel: auth.PrivLevelReadOnly, RequiredPermissions: []string{"PROFILE:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 46875858931},
@@ -290,6 +298,11 @@ func Routes(d ServerData) ([]Route, http.Handler, error) {
 
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `topologies/{name}/queue_update$`, Handler: topology.QueueUpdateHandler, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"SERVER:QUEUE", "TOPOLOGY:READ", "SERVER:READ", "CACHE-GROUP:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 42053517481},
 
+		// Staged rollouts of cache config updates
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodGet, Path: `rollouts/?$`, Handler: rollout.Get, RequiredPrivLevel: auth.PrivLevelReadOnly, RequiredPermissions: []string{"SERVER:READ", "TOPOLOGY:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 472958110111},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `rollouts/?$`, Handler: rollout.Create, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"SERVER:QUEUE", "SERVER:READ", "TOPOLOGY:READ", "CDN:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 472958110121},
+		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodPost, Path: `rollouts/{id}/action$`, Handler: rollout.Action, RequiredPrivLevel: auth.PrivLevelOperations, RequiredPermissions: []string{"SERVER:QUEUE", "SERVER:READ", "TOPOLOGY:READ", "CDN:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 472958110131},
+
 		// get all edge servers associated with a delivery service (from deliveryservice_server table)
 
 		{Version: api.Version{Major: 5, Minor: 0}, Method: http.MethodGet, Path: `deliveryserviceserver/?$`, Handler: dsserver.ReadDSSHandler, RequiredPrivLevel: auth.PrivLevelReadOnly, RequiredPermissions: []string{"SERVER:READ", "DELIVERY-SERVICE:READ"}, Authenticated: Authenticated, Middlewares: nil, ID: 494614503331},
@@ -39,7 +39,9 @@ import (
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/about"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/auth"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/deliveryservice"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/plugin"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/rollout"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/routing"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/server"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault"
@@ -150,6 +152,10 @@ func main() {
 	server.InitServerUpdateStatusCache(time.Duration(cfg.ServerUpdateStatusCacheRefreshIntervalSec)*time.Second, db.DB, time.Duration(cfg.DBQueryTimeoutSeconds)*time.Second)
 
 	trafficVault := setupTrafficVault(*riakConfigFileName, &cfg)
+	if cfg.TrafficVaultEnabled {
+		deliveryservice.InitKeyRotation(cfg.KeyRotation, db, time.Duration(cfg.DBQueryTimeoutSeconds)*time.Second, trafficVault)
+	}
+	rollout.InitRolloutEvaluator(cfg.Rollout, db, time.Duration(cfg.DBQueryTimeoutSeconds)*time.Second)
 
 	// TODO combine
 	plugins := plugin.Get(cfg)
@@ -114,16 +114,16 @@ func GetCacheStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client, err := inf.CreateInfluxClient()
+	storage, err := getStatsStorage(inf)
 	if err != nil {
 		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
 		return
-	} else if client == nil {
+	} else if storage == nil {
 		sysErr = errors.New("Traffic Stats is not configured, but Cache stats were requested")
 		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, sysErr)
 		return
 	}
-	defer (*client).Close()
+	defer storage.Close()
 
 	resp := struct {
 		Response tc.TrafficStatsResponse `json:"response"`
@@ -135,10 +135,10 @@ func GetCacheStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !c.ExcludeSummary {
-		summary, err := getCacheSummary(client, &c, inf.Config.ConfigInflux.CacheDBName)
+		summary, err := storage.cacheSummary(&c)
 
 		if err != nil {
-			sysErr = fmt.Errorf("Getting summary response from Influx: %v", err)
+			sysErr = fmt.Errorf("Getting summary response from Traffic Stats storage: %v", err)
 			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, sysErr)
 			return
 		}
@@ -152,10 +152,10 @@ func GetCacheStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !c.ExcludeSeries {
-		series, err := getCacheSeries(client, &c, inf.Config.ConfigInflux.CacheDBName)
+		series, err := storage.cacheSeries(&c)
 
 		if err != nil {
-			sysErr = fmt.Errorf("Getting summary response from Influx: %v", err)
+			sysErr = fmt.Errorf("Getting series response from Traffic Stats storage: %v", err)
 			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, sysErr)
 			return
 		}
@@ -187,7 +187,8 @@ func GetCacheStats(w http.ResponseWriter, r *http.Request) {
 	api.WriteAndLogErr(w, r, append(respBts, '\n'))
 }
 
-func getCacheSummary(client *influx.Client, conf *tc.TrafficCacheStatsConfig, db string) (*tc.TrafficStatsSummary, error) {
+func (s influxStorage) cacheSummary(conf *tc.TrafficCacheStatsConfig) (*tc.TrafficStatsSummary, error) {
+	db := s.config.CacheDBName
 	qStr := fmt.Sprintf(cSummaryQuery, db, conf.MetricType)
 	q := influx.NewQueryWithParameters(qStr,
 		db,
@@ -197,10 +198,11 @@ func getCacheSummary(client *influx.Client, conf *tc.TrafficCacheStatsConfig, db
 			"start":    conf.Start,
 			"end":      conf.End,
 		})
-	return getSummary(db, q, client)
+	return getSummary(db, q, s.client)
 }
 
-func getCacheSeries(client *influx.Client, conf *tc.TrafficCacheStatsConfig, db string) (*tc.TrafficStatsSeries, error) {
+func (s influxStorage) cacheSeries(conf *tc.TrafficCacheStatsConfig) (*tc.TrafficStatsSeries, error) {
+	db := s.config.CacheDBName
 	extraClauses := buildExtraClauses(&conf.TrafficStatsConfig)
 	qStr := fmt.Sprintf(cSeriesQuery, db, conf.MetricType, conf.Interval, conf.TrafficStatsConfig.OffsetString(), extraClauses)
 	q := influx.NewQueryWithParameters(qStr,
@@ -212,5 +214,5 @@ func getCacheSeries(client *influx.Client, conf *tc.TrafficCacheStatsConfig, db
 			"end":      conf.End,
 		})
 
-	return getSeries(db, q, client)
+	return getSeries(db, q, s.client)
 }
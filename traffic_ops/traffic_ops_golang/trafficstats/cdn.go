@@ -52,16 +52,16 @@ func GetCurrentStats(w http.ResponseWriter, r *http.Request) {
 	}
 	defer inf.Close()
 
-	client, err := inf.CreateInfluxClient()
+	storage, err := getStatsStorage(inf)
 	if err != nil {
 		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, err)
 		return
-	} else if client == nil {
+	} else if storage == nil {
 		sysErr = errors.New("Traffic Stats is not configured and 'current_stats' was requested.")
 		api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, sysErr)
 		return
 	}
-	defer (*client).Close()
+	defer storage.Close()
 
 	currentStats := []interface{}{}
 
@@ -79,7 +79,7 @@ func GetCurrentStats(w http.ResponseWriter, r *http.Request) {
 		cdnStats := tc.TrafficStatsCDNStats{
 			CDN: cdn,
 		}
-		bw, err := getCDNStat(client, cdn, bwMetricName, inf.Config.ConfigInflux.CacheDBName)
+		bw, err := storage.cdnStat(cdn, bwMetricName)
 		if err != nil {
 			sysErr = fmt.Errorf("getting bandwidth from cdn %v: %v", cdn, err)
 			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, sysErr)
@@ -96,7 +96,7 @@ func GetCurrentStats(w http.ResponseWriter, r *http.Request) {
 			cdnStats.Bandwidth = util.FloatPtr(*bw / 1000000)
 		}
 
-		con, err := getCDNStat(client, cdn, connMetricName, inf.Config.ConfigInflux.CacheDBName)
+		con, err := storage.cdnStat(cdn, connMetricName)
 		if err != nil {
 			sysErr = fmt.Errorf("getting connections from cdn %v: %v", cdn, err)
 			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, sysErr)
@@ -110,7 +110,7 @@ func GetCurrentStats(w http.ResponseWriter, r *http.Request) {
 		}
 		cdnStats.Connnections = con
 
-		cap, err := getCDNStat(client, cdn, kbpsMetricName, inf.Config.ConfigInflux.CacheDBName)
+		cap, err := storage.cdnStat(cdn, kbpsMetricName)
 		if err != nil {
 			sysErr = fmt.Errorf("getting maxkbps from cdn %v: %v", cdn, err)
 			api.HandleErr(w, r, tx, http.StatusInternalServerError, nil, sysErr)
@@ -137,7 +137,8 @@ func GetCurrentStats(w http.ResponseWriter, r *http.Request) {
 	api.WriteResp(w, r, resp)
 }
 
-func getCDNStat(client *influx.Client, cdnName, metricName, db string) (*float64, error) {
+func (s influxStorage) cdnStat(cdnName, metricName string) (*float64, error) {
+	db := s.config.CacheDBName
 	qStr := fmt.Sprintf(cdnStatsQuery, db, metricName)
 	q := influx.NewQueryWithParameters(qStr,
 		db,
@@ -145,7 +146,7 @@ func getCDNStat(client *influx.Client, cdnName, metricName, db string) (*float64
 		map[string]interface{}{
 			"cdn": cdnName,
 		})
-	series, err := getSeries(db, q, client)
+	series, err := getSeries(db, q, s.client)
 	if err != nil {
 		return nil, err
 	}
@@ -131,19 +131,19 @@ func GetDSStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client, err := inf.CreateInfluxClient()
+	storage, err := getStatsStorage(inf)
 	if err != nil {
 		errCode = http.StatusInternalServerError
 		sysErr = err
 		api.HandleErr(w, r, tx, errCode, nil, sysErr)
 		return
-	} else if client == nil {
+	} else if storage == nil {
 		sysErr = errors.New("Traffic Stats is not configured, but DS stats were requested")
 		errCode = http.StatusInternalServerError
 		api.HandleErr(w, r, tx, errCode, nil, sysErr)
 		return
 	}
-	defer (*client).Close()
+	defer storage.Close()
 
 	exists, dsTenant, err := dsTenantIDFromXMLID(c.DeliveryService, tx)
 	if err != nil {
@@ -175,19 +175,19 @@ func GetDSStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	handleRequest(w, r, client, c, inf)
+	handleRequest(w, r, storage, c, inf)
 }
 
-func handleRequest(w http.ResponseWriter, r *http.Request, client *influx.Client, cfg tc.TrafficDSStatsConfig, inf *api.Info) {
+func handleRequest(w http.ResponseWriter, r *http.Request, storage statsStorage, cfg tc.TrafficDSStatsConfig, inf *api.Info) {
 	// TODO: as above, this could be done on TO itself, thus sending only one synchronous request
 	// per hit on this endpoint, rather than the current two. Not sure if that's worth it for large
 	// data sets, though.
 	var resp tc.TrafficDSStatsResponse
 	if !cfg.ExcludeSummary {
-		summary, kBs, txns, err := getDSSummary(client, &cfg, inf.Config.ConfigInflux.DSDBName)
+		summary, kBs, txns, err := getDSSummary(storage, &cfg)
 
 		if err != nil {
-			sysErr := fmt.Errorf("Getting summary response from Influx: %v", err)
+			sysErr := fmt.Errorf("Getting summary response from Traffic Stats storage: %v", err)
 			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, sysErr)
 			return
 		}
@@ -206,10 +206,10 @@ func handleRequest(w http.ResponseWriter, r *http.Request, client *influx.Client
 	}
 
 	if !cfg.ExcludeSeries {
-		series, err := getDSSeries(client, &cfg, inf.Config.ConfigInflux.DSDBName)
+		series, err := storage.dsSeries(&cfg)
 
 		if err != nil {
-			sysErr := fmt.Errorf("Getting summary response from Influx: %v", err)
+			sysErr := fmt.Errorf("Getting series response from Traffic Stats storage: %v", err)
 			api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, sysErr)
 			return
 		}
@@ -246,18 +246,8 @@ func handleRequest(w http.ResponseWriter, r *http.Request, client *influx.Client
 	api.WriteAndLogErr(w, r, append(respBts, '\n'))
 }
 
-func getDSSummary(client *influx.Client, conf *tc.TrafficDSStatsConfig, db string) (*tc.TrafficStatsSummary, *float64, *float64, error) {
-	qStr := fmt.Sprintf(dsSummaryQuery, db, conf.MetricType)
-	q := influx.NewQueryWithParameters(qStr,
-		db,
-		"rfc3339", //this doesn't actually seem to have any effect...
-		map[string]interface{}{
-			"xmlid":    conf.DeliveryService,
-			"start":    conf.Start,
-			"end":      conf.End,
-			"interval": string(conf.Interval),
-		})
-	ts, err := getSummary(db, q, client)
+func getDSSummary(storage statsStorage, conf *tc.TrafficDSStatsConfig) (*tc.TrafficStatsSummary, *float64, *float64, error) {
+	ts, err := storage.dsSummary(conf)
 	if err != nil || ts == nil {
 		return nil, nil, nil, err
 	}
@@ -276,6 +266,21 @@ func getDSSummary(client *influx.Client, conf *tc.TrafficDSStatsConfig, db strin
 	return ts, totalKB, totalTXN, nil
 }
 
+func (s influxStorage) dsSummary(conf *tc.TrafficDSStatsConfig) (*tc.TrafficStatsSummary, error) {
+	db := s.config.DSDBName
+	qStr := fmt.Sprintf(dsSummaryQuery, db, conf.MetricType)
+	q := influx.NewQueryWithParameters(qStr,
+		db,
+		"rfc3339", //this doesn't actually seem to have any effect...
+		map[string]interface{}{
+			"xmlid":    conf.DeliveryService,
+			"start":    conf.Start,
+			"end":      conf.End,
+			"interval": string(conf.Interval),
+		})
+	return getSummary(db, q, s.client)
+}
+
 func dsTenantIDFromXMLID(xmlid string, tx *sql.Tx) (bool, uint, error) {
 	row := tx.QueryRow(dsTenantIDFromXMLIDQuery, xmlid)
 	var tid uint
@@ -296,7 +301,8 @@ func getXMLIDFromID(id uint64, tx *sql.Tx) (bool, string, error) {
 	return true, xmlid, err
 }
 
-func getDSSeries(client *influx.Client, conf *tc.TrafficDSStatsConfig, db string) (*tc.TrafficStatsSeries, error) {
+func (s influxStorage) dsSeries(conf *tc.TrafficDSStatsConfig) (*tc.TrafficStatsSeries, error) {
+	db := s.config.DSDBName
 	extraClauses := buildExtraClauses(&conf.TrafficStatsConfig)
 	qStr := fmt.Sprintf(dsSeriesQuery, db, conf.MetricType, conf.Interval, conf.TrafficStatsConfig.OffsetString(), extraClauses)
 	q := influx.NewQueryWithParameters(qStr,
@@ -307,7 +313,7 @@ func getDSSeries(client *influx.Client, conf *tc.TrafficDSStatsConfig, db string
 			"start": conf.Start,
 			"end":   conf.End,
 		})
-	return getSeries(db, q, client)
+	return getSeries(db, q, s.client)
 }
 
 func findMetric(slice []string, val string) (int, bool) {
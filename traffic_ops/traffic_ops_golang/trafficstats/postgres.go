@@ -0,0 +1,276 @@
+package trafficstats
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/config"
+)
+
+// The queries of the tables that Traffic Stats writes to a Postgres or
+// TimescaleDB database. They emulate the 1 minute continuous queries of
+// InfluxDB, by averaging the stats of each minute before aggregating them.
+const (
+	// dsMinutesQuery is the 1 minute mean of a stat of a Delivery Service,
+	// like "kbps.ds.1min" in InfluxDB.
+	dsMinutesQuery = `
+WITH minutes AS (
+	SELECT to_timestamp(floor(extract(epoch FROM time) / 60) * 60) AS minute, avg(value) AS value
+	FROM deliveryservice_stats
+	WHERE deliveryservice = $1
+	AND stat = $2
+	AND cachegroup = 'total'
+	AND time >= $3
+	AND time <= $4
+	GROUP BY minute
+)`
+
+	// cdnMinutesQuery is the sum of the 1 minute means of a stat of each
+	// cache of a CDN, like "bandwidth.cdn.1min" in InfluxDB.
+	cdnMinutesQuery = `
+WITH cache_minutes AS (
+	SELECT to_timestamp(floor(extract(epoch FROM time) / 60) * 60) AS minute, hostname, avg(value) AS value
+	FROM cache_stats
+	WHERE cdn = $1
+	AND stat = $2
+	AND time > $3
+	AND time < $4
+	GROUP BY minute, hostname
+), minutes AS (
+	SELECT minute, sum(value) AS value
+	FROM cache_minutes
+	GROUP BY minute
+)`
+
+	summaryFromMinutesQuery = `
+SELECT count(value),
+	avg(value),
+	percentile_disc(0.05) WITHIN GROUP (ORDER BY value),
+	percentile_disc(0.95) WITHIN GROUP (ORDER BY value),
+	percentile_disc(0.98) WITHIN GROUP (ORDER BY value),
+	min(value),
+	max(value)
+FROM minutes`
+
+	// seriesFromMinutesQuery groups the minutes into intervals of $6 seconds
+	// that start at $3, like "GROUP BY time(interval, offset)" in InfluxDB.
+	seriesFromMinutesQuery = `
+SELECT to_timestamp(extract(epoch FROM $3::timestamptz)::double precision + floor((extract(epoch FROM minute)::double precision - extract(epoch FROM $3::timestamptz)::double precision) / $5) * $5) AS time,
+	avg(value)
+FROM minutes
+GROUP BY 1`
+
+	cdnLastMinuteQuery = `
+WITH latest AS (
+	SELECT to_timestamp(floor(extract(epoch FROM max(time)) / 60) * 60) AS minute
+	FROM cache_stats
+	WHERE cdn = $1
+	AND stat = $2
+), cache_values AS (
+	SELECT hostname, avg(value) AS value
+	FROM cache_stats, latest
+	WHERE cdn = $1
+	AND stat = $2
+	AND time >= latest.minute
+	GROUP BY hostname
+)
+SELECT sum(value) FROM cache_values`
+)
+
+// cacheStatNames maps the metric types of the cache stats endpoints to the
+// names of the stats that Traffic Stats stores.
+var cacheStatNames = map[string]string{
+	"bandwidth":   "bandwidth",
+	"connections": "ats.proxy.process.http.current_client_connections",
+	"maxkbps":     "maxKbps",
+}
+
+var (
+	// statsDB is shared by all requests, so that they use the same pool of
+	// connections. It's replaced if the configuration changes.
+	statsDB       *sql.DB
+	statsDBConfig config.ConfigDatabase
+	statsDBMutex  sync.Mutex
+)
+
+func getStatsDB(cfg config.ConfigDatabase) (*sql.DB, error) {
+	statsDBMutex.Lock()
+	defer statsDBMutex.Unlock()
+	if statsDB != nil && statsDBConfig == cfg {
+		return statsDB, nil
+	}
+
+	sslMode := "disable"
+	if cfg.SSL {
+		sslMode = "require"
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     cfg.Hostname + ":" + cfg.Port,
+		Path:     cfg.DBName,
+		RawQuery: url.Values{"sslmode": {sslMode}, "fallback_application_name": {"trafficops"}}.Encode(),
+	}
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		return nil, fmt.Errorf("opening Traffic Stats database: %w", err)
+	}
+	if statsDB != nil {
+		statsDB.Close()
+	}
+	statsDB, statsDBConfig = db, cfg
+	return db, nil
+}
+
+// postgresStorage queries the tables that Traffic Stats writes to a Postgres
+// or TimescaleDB database.
+type postgresStorage struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// Close does nothing, because the pool of connections is shared.
+func (s postgresStorage) Close() error {
+	return nil
+}
+
+func (s postgresStorage) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.timeout)
+}
+
+func (s postgresStorage) dsSummary(conf *tc.TrafficDSStatsConfig) (*tc.TrafficStatsSummary, error) {
+	return s.summary(dsMinutesQuery, conf.DeliveryService, conf.MetricType, conf.Start, conf.End)
+}
+
+func (s postgresStorage) dsSeries(conf *tc.TrafficDSStatsConfig) (*tc.TrafficStatsSeries, error) {
+	series, err := s.series(dsMinutesQuery, &conf.TrafficStatsConfig, conf.DeliveryService, conf.MetricType)
+	if series != nil {
+		series.Name = conf.MetricType + ".ds.1min"
+		series.Tags = map[string]string{"cachegroup": "total"}
+		series.Columns = []string{"time", "mean"}
+	}
+	return series, err
+}
+
+func (s postgresStorage) cacheSummary(conf *tc.TrafficCacheStatsConfig) (*tc.TrafficStatsSummary, error) {
+	return s.summary(cdnMinutesQuery, conf.CDN, cacheStatNames[conf.MetricType], conf.Start, conf.End)
+}
+
+func (s postgresStorage) cacheSeries(conf *tc.TrafficCacheStatsConfig) (*tc.TrafficStatsSeries, error) {
+	series, err := s.series(cdnMinutesQuery, &conf.TrafficStatsConfig, conf.CDN, cacheStatNames[conf.MetricType])
+	if series != nil {
+		series.Name = conf.MetricType + ".cdn.1min"
+		series.Tags = map[string]string{"cdn": conf.CDN}
+		series.Columns = []string{"time", "sum_count"}
+	}
+	return series, err
+}
+
+func (s postgresStorage) cdnStat(cdnName, metricName string) (*float64, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	var value sql.NullFloat64
+	stat := cacheStatNames[strings.TrimSuffix(metricName, ".cdn.1min")]
+	if err := s.db.QueryRowContext(ctx, cdnLastMinuteQuery, cdnName, stat).Scan(&value); err != nil {
+		return nil, fmt.Errorf("querying the last value of %s: %w", metricName, err)
+	}
+	if !value.Valid {
+		return nil, nil
+	}
+	return &value.Float64, nil
+}
+
+// summary summarizes the minutes of the minutesQuery, whose parameters are
+// the name of the Delivery Service or CDN, the stat, and the start and end
+// times.
+func (s postgresStorage) summary(minutesQuery string, name string, stat string, start time.Time, end time.Time) (*tc.TrafficStatsSummary, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	var count uint
+	var average, fifth, ninetyFifth, ninetyEighth, min, max sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, minutesQuery+summaryFromMinutesQuery, name, stat, start, end).Scan(&count, &average, &fifth, &ninetyFifth, &ninetyEighth, &min, &max)
+	if err != nil {
+		return nil, fmt.Errorf("querying summary: %w", err)
+	}
+	// Like InfluxDB, no data is not an error.
+	if count == 0 {
+		return nil, nil
+	}
+	return &tc.TrafficStatsSummary{
+		Average:                average.Float64,
+		Count:                  count,
+		FifthPercentile:        fifth.Float64,
+		Max:                    max.Float64,
+		Min:                    min.Float64,
+		NinetyEighthPercentile: ninetyEighth.Float64,
+		NinetyFifthPercentile:  ninetyFifth.Float64,
+	}, nil
+}
+
+// series groups the minutes of the minutesQuery into the intervals of the
+// request. The values of the series are Unix timestamps in nanoseconds and
+// the means of the intervals.
+func (s postgresStorage) series(minutesQuery string, conf *tc.TrafficStatsConfig, name string, stat string) (*tc.TrafficStatsSeries, error) {
+	interval, err := tc.DurationLiteralToSeconds(conf.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("parsing interval: %w", err)
+	}
+	extraClauses := buildExtraClauses(conf)
+	if conf.OrderBy == nil {
+		extraClauses = " ORDER BY time" + extraClauses
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, minutesQuery+seriesFromMinutesQuery+extraClauses, name, stat, conf.Start, conf.End, interval)
+	if err != nil {
+		return nil, fmt.Errorf("querying series: %w", err)
+	}
+	defer rows.Close()
+
+	values := [][]interface{}{}
+	for rows.Next() {
+		var t time.Time
+		var value float64
+		if err := rows.Scan(&t, &value); err != nil {
+			return nil, fmt.Errorf("scanning series: %w", err)
+		}
+		values = append(values, []interface{}{t.UnixNano(), value})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading series: %w", err)
+	}
+	// Like InfluxDB, no data is not an error.
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return &tc.TrafficStatsSeries{
+		Values: values,
+		Count:  uint(len(values)),
+	}, nil
+}
@@ -0,0 +1,96 @@
+package trafficstats
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestPostgresStorageDSSummary(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%v' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := postgresStorage{db: db, timeout: time.Second}
+
+	conf := tc.TrafficDSStatsConfig{DeliveryService: "ds", TrafficStatsConfig: tc.TrafficStatsConfig{MetricType: "kbps", Start: time.Unix(0, 0), End: time.Unix(3600, 0)}}
+	rows := sqlmock.NewRows([]string{"count", "avg", "p5", "p95", "p98", "min", "max"}).AddRow(60, 10.5, 1, 20, 30, 0.5, 40)
+	mock.ExpectQuery("FROM deliveryservice_stats").WithArgs("ds", "kbps", conf.Start, conf.End).WillReturnRows(rows)
+
+	summary, err := s.dsSummary(&conf)
+	if err != nil {
+		t.Fatalf("getting summary: %v", err)
+	}
+	expected := tc.TrafficStatsSummary{Average: 10.5, Count: 60, FifthPercentile: 1, Max: 40, Min: 0.5, NinetyEighthPercentile: 30, NinetyFifthPercentile: 20}
+	if summary == nil || *summary != expected {
+		t.Errorf("expected summary %+v, got %+v", expected, summary)
+	}
+
+	rows = sqlmock.NewRows([]string{"count", "avg", "p5", "p95", "p98", "min", "max"}).AddRow(0, nil, nil, nil, nil, nil, nil)
+	mock.ExpectQuery("FROM deliveryservice_stats").WillReturnRows(rows)
+	if summary, err = s.dsSummary(&conf); err != nil || summary != nil {
+		t.Errorf("expected no summary and no error without data, got %+v, %v", summary, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPostgresStorageCacheSeries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%v' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	s := postgresStorage{db: db, timeout: time.Second}
+
+	limit := uint64(2)
+	conf := tc.TrafficCacheStatsConfig{CDN: "cdn", TrafficStatsConfig: tc.TrafficStatsConfig{MetricType: "connections", Interval: "5m", Limit: &limit, Start: time.Unix(0, 0), End: time.Unix(3600, 0)}}
+	rows := sqlmock.NewRows([]string{"time", "avg"}).AddRow(time.Unix(0, 0), 1.0).AddRow(time.Unix(300, 0), 2.0)
+	mock.ExpectQuery(`FROM cache_stats(.|\n)*GROUP BY 1 ORDER BY time LIMIT 2$`).
+		WithArgs("cdn", "ats.proxy.process.http.current_client_connections", conf.Start, conf.End, int64(300)).
+		WillReturnRows(rows)
+
+	series, err := s.cacheSeries(&conf)
+	if err != nil {
+		t.Fatalf("getting series: %v", err)
+	}
+	if series == nil {
+		t.Fatal("expected a series, got nil")
+	}
+	if series.Name != "connections.cdn.1min" || series.Tags["cdn"] != "cdn" || series.Count != 2 {
+		t.Errorf("unexpected series %+v", series)
+	}
+	if len(series.Values) != 2 || series.Values[1][0] != int64(300*time.Second) || series.Values[1][1] != 2.0 {
+		t.Errorf("unexpected values %v", series.Values)
+	}
+	if err := series.FormatTimestamps(); err != nil || series.Values[1][0] != time.Unix(300, 0).Format(time.RFC3339) {
+		t.Errorf("expected timestamps to be formatted, got %v, %v", series.Values, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package trafficstats
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/config"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+)
+
+// statsStorage is the database in which Traffic Stats stores the stats of
+// caches and Delivery Services. A summary or series of nil, with no error,
+// means that there is no data.
+type statsStorage interface {
+	dsSummary(conf *tc.TrafficDSStatsConfig) (*tc.TrafficStatsSummary, error)
+	dsSeries(conf *tc.TrafficDSStatsConfig) (*tc.TrafficStatsSeries, error)
+	cacheSummary(conf *tc.TrafficCacheStatsConfig) (*tc.TrafficStatsSummary, error)
+	cacheSeries(conf *tc.TrafficCacheStatsConfig) (*tc.TrafficStatsSeries, error)
+	// cdnStat returns the last value of one of the per-CDN 1 minute metrics,
+	// e.g. "bandwidth.cdn.1min", or nil if it has no value.
+	cdnStat(cdnName, metricName string) (*float64, error)
+	Close() error
+}
+
+// influxStorage queries the InfluxDB databases of Traffic Stats.
+type influxStorage struct {
+	client *influx.Client
+	config *config.ConfigInflux
+}
+
+func (s influxStorage) Close() error {
+	return (*s.client).Close()
+}
+
+// getStatsStorage returns the Postgres database of Traffic Stats if one is
+// configured, or else the InfluxDB databases, if enabled. If neither is
+// configured, it returns nil - but also no error. The error this returns
+// should not be exposed to the user.
+func getStatsStorage(inf *api.Info) (statsStorage, error) {
+	if inf.Config.TrafficStatsDB != nil {
+		db, err := getStatsDB(*inf.Config.TrafficStatsDB)
+		if err != nil {
+			return nil, err
+		}
+		return postgresStorage{db: db, timeout: time.Duration(inf.Config.DBQueryTimeoutSeconds) * time.Second}, nil
+	}
+
+	client, err := inf.CreateInfluxClient()
+	if err != nil || client == nil {
+		return nil, err
+	}
+	return influxStorage{client: client, config: inf.Config.ConfigInflux}, nil
+}
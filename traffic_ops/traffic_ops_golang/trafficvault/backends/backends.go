@@ -21,5 +21,6 @@ package backends
  */
 
 import (
+	_ "github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault/backends/file"
 	_ "github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault/backends/postgres"
 )
@@ -28,6 +28,7 @@ import (
 	"database/sql"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault"
 )
 
 type Error string
@@ -107,3 +108,15 @@ func (d *Disabled) Ping(tx *sql.Tx, ctx context.Context) (tc.TrafficVaultPing, e
 func (d *Disabled) GetBucketKey(bucket string, key string, tx *sql.Tx) ([]byte, bool, error) {
 	return nil, false, disabledErr
 }
+
+func (d *Disabled) GetSecretVersions(secretType trafficvault.SecretType, name string, tx *sql.Tx, ctx context.Context) ([]tc.TrafficVaultSecretVersion, error) {
+	return nil, disabledErr
+}
+
+func (d *Disabled) PromoteSecretVersion(secretType trafficvault.SecretType, name string, version int64, tx *sql.Tx, ctx context.Context) error {
+	return disabledErr
+}
+
+func (d *Disabled) RollbackSecret(secretType trafficvault.SecretType, name string, tx *sql.Tx, ctx context.Context) (int64, error) {
+	return 0, disabledErr
+}
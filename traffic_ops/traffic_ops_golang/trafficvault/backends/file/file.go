@@ -0,0 +1,689 @@
+// Package file provides a TrafficVault implementation which stores encrypted
+// secrets in a directory tree on the local filesystem. It is intended for
+// air-gapped and test deployments with a single Traffic Ops instance.
+package file
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"crypto/aes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc/tovalidate"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/deliveryservice"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+const (
+	notImplementedErr = Error("this Traffic Vault functionality is not implemented for the file backend")
+
+	fileBackendName = "file"
+
+	latestVersion = "latest"
+
+	// versionsDir is the directory that holds the retained versions of every
+	// secret except SSL keys, which are versioned in their own directory.
+	versionsDir = "versions"
+
+	dirMode  = 0700
+	fileMode = 0600
+)
+
+type Config struct {
+	Directory         string `json:"directory"`
+	AesKeyLocation    string `json:"aes_key_location"`
+	MaxSecretVersions int    `json:"max_secret_versions"`
+}
+
+// File is a TrafficVault which stores each secret as an AES-encrypted file
+// beneath the configured directory:
+//
+//	sslkeys/<xmlID>/<version>
+//	dnsseckeys/<cdn>
+//	urlsigkeys/<xmlID>
+//	urisigningkeys/<xmlID>
+//	versions/<secret type>/<name>
+//
+// Files are only guarded against concurrent access from within a single
+// process, so the directory must not be shared by multiple Traffic Ops
+// instances.
+type File struct {
+	cfg    Config
+	aesKey []byte
+	mu     sync.RWMutex
+}
+
+// validName returns an error if the given name can't safely be used as a
+// single path element beneath the Traffic Vault directory.
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("invalid Traffic Vault file name '%s'", name)
+	}
+	return nil
+}
+
+// path returns the path of the file identified by the given elements beneath
+// the Traffic Vault directory.
+func (f *File) path(elems ...string) (string, error) {
+	for _, elem := range elems {
+		if err := validName(elem); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(append([]string{f.cfg.Directory}, elems...)...), nil
+}
+
+// readFile returns the raw (encrypted) contents of the given file, and whether
+// it exists.
+func readFile(path string) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, errors.New("reading Traffic Vault file: " + err.Error())
+	}
+	return data, true, nil
+}
+
+// writeFile atomically replaces the contents of the given file with the given
+// raw (encrypted) data, creating its parent directories if necessary.
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return errors.New("creating Traffic Vault directory: " + err.Error())
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.New("creating temporary Traffic Vault file: " + err.Error())
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.New("writing temporary Traffic Vault file: " + err.Error())
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.New("syncing temporary Traffic Vault file: " + err.Error())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.New("closing temporary Traffic Vault file: " + err.Error())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.New("renaming temporary Traffic Vault file: " + err.Error())
+	}
+	return nil
+}
+
+// removeFile removes the given file, if it exists.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.New("removing Traffic Vault file: " + err.Error())
+	}
+	return nil
+}
+
+// read returns the decrypted contents of the given file, and whether it exists.
+func (f *File) read(path string) ([]byte, bool, error) {
+	data, exists, err := readFile(path)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	decrypted, err := util.AESDecrypt(data, f.aesKey)
+	if err != nil {
+		return nil, false, errors.New("decrypting Traffic Vault file: " + err.Error())
+	}
+	return decrypted, true, nil
+}
+
+// write encrypts the given data and atomically stores it in the given file.
+func (f *File) write(path string, data []byte) error {
+	encrypted, err := util.AESEncrypt(data, f.aesKey)
+	if err != nil {
+		return errors.New("encrypting keys: " + err.Error())
+	}
+	return writeFile(path, encrypted)
+}
+
+// listDir returns the names of the entries in the given directory, ignoring
+// temporary files. A directory that doesn't exist has no entries.
+func listDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.New("reading Traffic Vault directory: " + err.Error())
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// GetDeliveryServiceSSLKeys retrieves the SSL keys of the given version for
+// the delivery service identified by the given xmlID. If version is empty,
+// the implementation should return the latest version.
+func (f *File) GetDeliveryServiceSSLKeys(xmlID string, version string, tx *sql.Tx, ctx context.Context) (tc.DeliveryServiceSSLKeysV15, bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if version == "" {
+		version = latestVersion
+	}
+	path, err := f.path(string(trafficvault.SecretTypeSSLKeys), xmlID, version)
+	if err != nil {
+		return tc.DeliveryServiceSSLKeysV15{}, false, err
+	}
+	jsonKeys, exists, err := f.read(path)
+	if err != nil || !exists {
+		return tc.DeliveryServiceSSLKeysV15{}, false, err
+	}
+	sslKey := tc.DeliveryServiceSSLKeysV15{}
+	if err := json.Unmarshal(jsonKeys, &sslKey); err != nil {
+		return tc.DeliveryServiceSSLKeysV15{}, false, errors.New("unmarshalling ssl keys: " + err.Error())
+	}
+	return sslKey, true, nil
+}
+
+// latestSSLKeys returns the latest SSL keys of every delivery service that has
+// them. Keys that can't be read are logged and skipped.
+func (f *File) latestSSLKeys() ([]tc.DeliveryServiceSSLKeys, error) {
+	xmlIDs, err := listDir(filepath.Join(f.cfg.Directory, string(trafficvault.SecretTypeSSLKeys)))
+	if err != nil {
+		return nil, err
+	}
+	keys := []tc.DeliveryServiceSSLKeys{}
+	for _, xmlID := range xmlIDs {
+		path, err := f.path(string(trafficvault.SecretTypeSSLKeys), xmlID, latestVersion)
+		if err != nil {
+			log.Errorf("Traffic Vault file: %v", err)
+			continue
+		}
+		jsonKey, exists, err := f.read(path)
+		if err != nil {
+			log.Errorf("couldn't read SSL keys for delivery service '%s': %v", xmlID, err)
+			continue
+		}
+		if !exists {
+			continue
+		}
+		key := tc.DeliveryServiceSSLKeys{}
+		if err := json.Unmarshal(jsonKey, &key); err != nil {
+			log.Errorf("couldn't unmarshal json key for delivery service '%s': %v", xmlID, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// GetExpirationInformation returns the expiration information for all SSL Keys.
+func (f *File) GetExpirationInformation(tx *sql.Tx, ctx context.Context, days int) ([]tc.SSLKeyExpirationInformation, error) {
+	fedMap := map[string]bool{}
+	fedRows, err := tx.Query("SELECT DISTINCT(ds.xml_id) FROM federation_deliveryservice AS fd JOIN deliveryservice AS ds ON ds.id = fd.deliveryservice")
+	if err != nil {
+		return []tc.SSLKeyExpirationInformation{}, err
+	}
+	defer fedRows.Close()
+	for fedRows.Next() {
+		var fedString string
+		if err = fedRows.Scan(&fedString); err != nil {
+			return []tc.SSLKeyExpirationInformation{}, err
+		}
+		fedMap[fedString] = true
+	}
+
+	iaRows, err := tx.Query("SELECT xml_id FROM deliveryservice WHERE active = 'INACTIVE' OR active = 'PRIMED'")
+	if err != nil {
+		return []tc.SSLKeyExpirationInformation{}, err
+	}
+	defer iaRows.Close()
+	inactiveList := map[string]bool{}
+	for iaRows.Next() {
+		var inactiveXmlId string
+		if err = iaRows.Scan(&inactiveXmlId); err != nil {
+			return []tc.SSLKeyExpirationInformation{}, err
+		}
+		inactiveList[inactiveXmlId] = true
+	}
+
+	f.mu.RLock()
+	keys, err := f.latestSSLKeys()
+	f.mu.RUnlock()
+	if err != nil {
+		return []tc.SSLKeyExpirationInformation{}, err
+	}
+
+	expirationInfos := []tc.SSLKeyExpirationInformation{}
+	cutoff := time.Now().AddDate(0, 0, days)
+	for _, key := range keys {
+		if inactiveList[key.DeliveryService] {
+			continue
+		}
+		if err := deliveryservice.Base64DecodeCertificate(&key.Certificate); err != nil {
+			log.Errorf("decoding SSL keys for delivery service '%s': %v", key.DeliveryService, err)
+			continue
+		}
+		expiration, _, err := deliveryservice.ParseExpirationAndSansFromCert([]byte(key.Certificate.Crt), key.Hostname)
+		if err != nil {
+			log.Errorf("parsing expiration from certificate for delivery service '%s': %v", key.DeliveryService, err)
+			continue
+		}
+		if days != 0 && expiration.After(cutoff) {
+			continue
+		}
+		expirationInfos = append(expirationInfos, tc.SSLKeyExpirationInformation{
+			DeliveryService: key.DeliveryService,
+			CDN:             key.CDN,
+			Provider:        key.AuthType,
+			Expiration:      expiration,
+			Federated:       fedMap[key.DeliveryService],
+		})
+	}
+	return expirationInfos, nil
+}
+
+// PutDeliveryServiceSSLKeys stores the given SSL keys for a delivery service.
+func (f *File) PutDeliveryServiceSSLKeys(key tc.DeliveryServiceSSLKeys, tx *sql.Tx, ctx context.Context) error {
+	keyJSON, err := json.Marshal(&key)
+	if err != nil {
+		return errors.New("marshalling keys: " + err.Error())
+	}
+	versionPath, err := f.path(string(trafficvault.SecretTypeSSLKeys), key.DeliveryService, strconv.FormatInt(int64(key.Version), 10))
+	if err != nil {
+		return err
+	}
+	latestPath, err := f.path(string(trafficvault.SecretTypeSSLKeys), key.DeliveryService, latestVersion)
+	if err != nil {
+		return err
+	}
+	// the same encrypted data is written to both files, so that the current
+	// version can be identified by comparing it with 'latest'
+	encryptedKey, err := util.AESEncrypt(keyJSON, f.aesKey)
+	if err != nil {
+		return fmt.Errorf("encrypting keys: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := writeFile(versionPath, encryptedKey); err != nil {
+		return err
+	}
+	if err := writeFile(latestPath, encryptedKey); err != nil {
+		return err
+	}
+	return f.pruneSSLKeyVersions(key.DeliveryService)
+}
+
+// DeleteDeliveryServiceSSLKeys removes the SSL keys of the given version (or latest
+// if version is empty) for the delivery service identified by the given xmlID.
+func (f *File) DeleteDeliveryServiceSSLKeys(xmlID string, version string, tx *sql.Tx, ctx context.Context) error {
+	if version == "" {
+		version = latestVersion
+	}
+	path, err := f.path(string(trafficvault.SecretTypeSSLKeys), xmlID, version)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := removeFile(path); err != nil {
+		return err
+	}
+	// only succeeds once no version is left
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// DeleteOldDeliveryServiceSSLKeys takes a set of existingXMLIDs as input and will remove
+// all SSL keys for delivery services in the CDN identified by the given cdnName that
+// do not contain an xmlID in the given set of existingXMLIDs. This method is called
+// during a snapshot operation in order to delete SSL keys for delivery services that
+// no longer exist.
+func (f *File) DeleteOldDeliveryServiceSSLKeys(existingXMLIDs map[string]struct{}, cdnName string, tx *sql.Tx, ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sslDir := filepath.Join(f.cfg.Directory, string(trafficvault.SecretTypeSSLKeys))
+	xmlIDs, err := listDir(sslDir)
+	if err != nil {
+		return err
+	}
+	for _, xmlID := range xmlIDs {
+		if _, ok := existingXMLIDs[xmlID]; ok {
+			continue
+		}
+		dsDir := filepath.Join(sslDir, xmlID)
+		versions, err := listDir(dsDir)
+		if err != nil {
+			return err
+		}
+		for _, version := range versions {
+			path := filepath.Join(dsDir, version)
+			jsonKey, exists, err := f.read(path)
+			if err != nil {
+				log.Errorf("couldn't read SSL keys for delivery service '%s': %v", xmlID, err)
+				continue
+			}
+			if !exists {
+				continue
+			}
+			key := tc.DeliveryServiceSSLKeys{}
+			if err := json.Unmarshal(jsonKey, &key); err != nil {
+				log.Errorf("couldn't unmarshal json key for delivery service '%s': %v", xmlID, err)
+				continue
+			}
+			if key.CDN != cdnName {
+				continue
+			}
+			if err := removeFile(path); err != nil {
+				return err
+			}
+		}
+		os.Remove(dsDir)
+	}
+	return nil
+}
+
+// GetCDNSSLKeys retrieves all the SSL keys for delivery services in the CDN identified
+// by the given cdnName.
+func (f *File) GetCDNSSLKeys(cdnName string, tx *sql.Tx, ctx context.Context) ([]tc.CDNSSLKey, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	var keys []tc.CDNSSLKey
+	latest, err := f.latestSSLKeys()
+	if err != nil {
+		return keys, err
+	}
+	for _, dsKey := range latest {
+		if dsKey.CDN != cdnName {
+			continue
+		}
+		keys = append(keys, tc.CDNSSLKey{
+			DeliveryService: dsKey.DeliveryService,
+			HostName:        dsKey.Hostname,
+			Certificate: tc.CDNSSLKeyCert{
+				Crt: dsKey.Certificate.Crt,
+				Key: dsKey.Certificate.Key,
+			},
+		})
+	}
+	return keys, nil
+}
+
+// getSecret returns the decrypted contents of the secret of the given type
+// identified by the given name. It must not be used for SSL keys.
+func (f *File) getSecret(secretType trafficvault.SecretType, name string) ([]byte, bool, error) {
+	path, err := f.path(string(secretType), name)
+	if err != nil {
+		return nil, false, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.read(path)
+}
+
+// putSecret stores the given data as the secret of the given type identified
+// by the given name, and retains it as that secret's new, current version.
+func (f *File) putSecret(secretType trafficvault.SecretType, name string, data []byte) error {
+	path, err := f.path(string(secretType), name)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.write(path, data); err != nil {
+		return err
+	}
+	return f.recordSecretVersion(secretType, name, data)
+}
+
+// deleteSecret removes the secret of the given type identified by the given
+// name along with all of its retained versions.
+func (f *File) deleteSecret(secretType trafficvault.SecretType, name string) error {
+	path, err := f.path(string(secretType), name)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := removeFile(path); err != nil {
+		return err
+	}
+	return f.deleteSecretVersions(secretType, name)
+}
+
+func (f *File) GetDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Context) (tc.DNSSECKeysTrafficVault, bool, error) {
+	dnssecJSON, exists, err := f.getSecret(trafficvault.SecretTypeDNSSECKeys, cdnName)
+	if err != nil || !exists {
+		return tc.DNSSECKeysTrafficVault{}, false, err
+	}
+	dnssecKeys := tc.DNSSECKeysTrafficVault{}
+	if err := json.Unmarshal(dnssecJSON, &dnssecKeys); err != nil {
+		return tc.DNSSECKeysTrafficVault{}, false, errors.New("unmarshalling DNSSEC keys: " + err.Error())
+	}
+	return dnssecKeys, true, nil
+}
+
+func (f *File) PutDNSSECKeys(cdnName string, keys tc.DNSSECKeysTrafficVault, tx *sql.Tx, ctx context.Context) error {
+	dnssecJSON, err := json.Marshal(&keys)
+	if err != nil {
+		return errors.New("marshalling DNSSEC keys: " + err.Error())
+	}
+	return f.putSecret(trafficvault.SecretTypeDNSSECKeys, cdnName, dnssecJSON)
+}
+
+func (f *File) DeleteDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Context) error {
+	return f.deleteSecret(trafficvault.SecretTypeDNSSECKeys, cdnName)
+}
+
+func (f *File) GetURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) (tc.URLSigKeys, bool, error) {
+	keyJSON, exists, err := f.getSecret(trafficvault.SecretTypeURLSigKeys, xmlID)
+	if err != nil || !exists {
+		return tc.URLSigKeys{}, false, err
+	}
+	keys := tc.URLSigKeys{}
+	if err := json.Unmarshal(keyJSON, &keys); err != nil {
+		return tc.URLSigKeys{}, false, errors.New("unmarshalling URL sig keys: " + err.Error())
+	}
+	return keys, true, nil
+}
+
+func (f *File) PutURLSigKeys(xmlID string, keys tc.URLSigKeys, tx *sql.Tx, ctx context.Context) error {
+	keyJSON, err := json.Marshal(&keys)
+	if err != nil {
+		return errors.New("marshalling keys: " + err.Error())
+	}
+	return f.putSecret(trafficvault.SecretTypeURLSigKeys, xmlID, keyJSON)
+}
+
+func (f *File) DeleteURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
+	return f.deleteSecret(trafficvault.SecretTypeURLSigKeys, xmlID)
+}
+
+func (f *File) GetURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) ([]byte, bool, error) {
+	keysJSON, exists, err := f.getSecret(trafficvault.SecretTypeURISigningKeys, xmlID)
+	if err != nil || !exists {
+		return []byte{}, false, err
+	}
+	return keysJSON, true, nil
+}
+
+func (f *File) PutURISigningKeys(xmlID string, keysJson []byte, tx *sql.Tx, ctx context.Context) error {
+	return f.putSecret(trafficvault.SecretTypeURISigningKeys, xmlID, keysJson)
+}
+
+func (f *File) DeleteURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
+	return f.deleteSecret(trafficvault.SecretTypeURISigningKeys, xmlID)
+}
+
+func (f *File) Ping(tx *sql.Tx, ctx context.Context) (tc.TrafficVaultPing, error) {
+	info, err := os.Stat(f.cfg.Directory)
+	if err != nil {
+		return tc.TrafficVaultPing{}, errors.New("Traffic Vault file: checking directory: " + err.Error())
+	}
+	if !info.IsDir() {
+		return tc.TrafficVaultPing{}, fmt.Errorf("Traffic Vault file: '%s' is not a directory", f.cfg.Directory)
+	}
+	return tc.TrafficVaultPing{Status: "OK", Server: f.cfg.Directory}, nil
+}
+
+func (f *File) GetBucketKey(bucket string, key string, tx *sql.Tx) ([]byte, bool, error) {
+	return nil, false, notImplementedErr
+}
+
+// GetSecretVersions lists the retained versions of the secret of the given
+// type identified by the given name, newest first.
+func (f *File) GetSecretVersions(secretType trafficvault.SecretType, name string, tx *sql.Tx, ctx context.Context) ([]tc.TrafficVaultSecretVersion, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if secretType == trafficvault.SecretTypeSSLKeys {
+		return f.getSSLKeyVersions(name)
+	}
+	history, err := f.readSecretHistory(secretType, name)
+	if err != nil {
+		return nil, err
+	}
+	return history.SecretVersions(), nil
+}
+
+// PromoteSecretVersion makes the given retained version the current value of
+// the secret of the given type identified by the given name.
+func (f *File) PromoteSecretVersion(secretType trafficvault.SecretType, name string, version int64, tx *sql.Tx, ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if secretType == trafficvault.SecretTypeSSLKeys {
+		return f.promoteSSLKeyVersion(name, version)
+	}
+	return f.promoteSecretVersion(secretType, name, version)
+}
+
+// RollbackSecret makes the newest retained version that is older than the
+// current version of the given secret the current value.
+func (f *File) RollbackSecret(secretType trafficvault.SecretType, name string, tx *sql.Tx, ctx context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var versions []tc.TrafficVaultSecretVersion
+	if secretType == trafficvault.SecretTypeSSLKeys {
+		var err error
+		if versions, err = f.getSSLKeyVersions(name); err != nil {
+			return 0, err
+		}
+	} else {
+		history, err := f.readSecretHistory(secretType, name)
+		if err != nil {
+			return 0, err
+		}
+		versions = history.SecretVersions()
+	}
+	version, err := trafficvault.RollbackSecretVersion(versions)
+	if err != nil {
+		return 0, err
+	}
+	if secretType == trafficvault.SecretTypeSSLKeys {
+		err = f.promoteSSLKeyVersion(name, version)
+	} else {
+		err = f.promoteSecretVersion(secretType, name, version)
+	}
+	return version, err
+}
+
+func init() {
+	trafficvault.AddBackend(fileBackendName, fileLoad)
+}
+
+func fileLoad(b json.RawMessage) (trafficvault.TrafficVault, error) {
+	fileCfg := Config{}
+	if err := json.Unmarshal(b, &fileCfg); err != nil {
+		return nil, errors.New("unmarshalling file config: " + err.Error())
+	}
+	if err := validateConfig(fileCfg); err != nil {
+		return nil, errors.New("validating file config: " + err.Error())
+	}
+	if fileCfg.MaxSecretVersions == 0 {
+		fileCfg.MaxSecretVersions = trafficvault.DefaultMaxSecretVersions
+	}
+	if err := os.MkdirAll(fileCfg.Directory, dirMode); err != nil {
+		return nil, errors.New("creating Traffic Vault directory: " + err.Error())
+	}
+
+	aesKey, err := readKey(fileCfg.AesKeyLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{cfg: fileCfg, aesKey: aesKey}, nil
+}
+
+// readKey reads the AES key (encoded in base64) used for encryption/decryption
+// from the given on-disk file.
+func readKey(location string) ([]byte, error) {
+	keyBase64, err := os.ReadFile(location)
+	if err != nil {
+		return []byte{}, errors.New("reading file '" + location + "':" + err.Error())
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(keyBase64)))
+	if err != nil {
+		return []byte{}, errors.New("AES key cannot be decoded from base64")
+	}
+
+	// verify the key works
+	if _, err = aes.NewCipher(key); err != nil {
+		return []byte{}, err
+	}
+	return key, nil
+}
+
+func validateConfig(cfg Config) error {
+	errs := tovalidate.ToErrors(validation.Errors{
+		"directory":           validation.Validate(cfg.Directory, validation.Required),
+		"aes_key_location":    validation.Validate(cfg.AesKeyLocation, validation.Required),
+		"max_secret_versions": validation.Validate(cfg.MaxSecretVersions, validation.Min(0)),
+	})
+	if len(errs) == 0 {
+		return nil
+	}
+	return util.JoinErrs(errs)
+}
@@ -0,0 +1,223 @@
+package file
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault"
+)
+
+func newTestFile(t *testing.T) *File {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "aes.key")
+	if err := os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{'k'}, 32))+"\n"), 0600); err != nil {
+		t.Fatalf("writing AES key: %v", err)
+	}
+	cfg, err := json.Marshal(Config{Directory: filepath.Join(dir, "tv"), AesKeyLocation: keyFile, MaxSecretVersions: 2})
+	if err != nil {
+		t.Fatalf("marshalling config: %v", err)
+	}
+	tv, err := fileLoad(cfg)
+	if err != nil {
+		t.Fatalf("loading file Traffic Vault: %v", err)
+	}
+	return tv.(*File)
+}
+
+func TestFileLoad(t *testing.T) {
+	if _, err := fileLoad([]byte(`{"directory": "/tmp/tv"}`)); err == nil {
+		t.Error("expected an error loading a config without aes_key_location")
+	}
+	if _, err := fileLoad([]byte(`{"aes_key_location": "/tmp/aes.key"}`)); err == nil {
+		t.Error("expected an error loading a config without directory")
+	}
+	f := newTestFile(t)
+	if f.cfg.MaxSecretVersions != 2 {
+		t.Errorf("expected max_secret_versions 2, got %d", f.cfg.MaxSecretVersions)
+	}
+	if _, err := f.Ping(nil, context.Background()); err != nil {
+		t.Errorf("pinging file Traffic Vault: %v", err)
+	}
+}
+
+func TestFileURLSigKeys(t *testing.T) {
+	f := newTestFile(t)
+	ctx := context.Background()
+
+	if _, exists, err := f.GetURLSigKeys("ds1", nil, ctx); err != nil || exists {
+		t.Fatalf("expected no URL sig keys before storing any, got exists %t, error %v", exists, err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := f.PutURLSigKeys("ds1", tc.URLSigKeys{"key0": key}, nil, ctx); err != nil {
+			t.Fatalf("putting URL sig keys: %v", err)
+		}
+	}
+	keys, exists, err := f.GetURLSigKeys("ds1", nil, ctx)
+	if err != nil || !exists || keys["key0"] != "c" {
+		t.Fatalf("expected the latest URL sig keys, got %v, exists %t, error %v", keys, exists, err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(f.cfg.Directory, "urlsigkeys", "ds1"))
+	if err != nil {
+		t.Fatalf("reading URL sig keys file: %v", err)
+	}
+	if bytes.Contains(raw, []byte("key0")) {
+		t.Error("expected the URL sig keys file to be encrypted")
+	}
+
+	versions, err := f.GetSecretVersions(trafficvault.SecretTypeURLSigKeys, "ds1", nil, ctx)
+	if err != nil {
+		t.Fatalf("getting URL sig key versions: %v", err)
+	}
+	if len(versions) != 3 || versions[0].Version != 3 || !versions[0].Current {
+		t.Fatalf("expected versions 3 (current), 2 and 1, got %+v", versions)
+	}
+
+	version, err := f.RollbackSecret(trafficvault.SecretTypeURLSigKeys, "ds1", nil, ctx)
+	if err != nil || version != 2 {
+		t.Fatalf("expected to roll back to version 2, got %d, error %v", version, err)
+	}
+	if keys, _, _ := f.GetURLSigKeys("ds1", nil, ctx); keys["key0"] != "b" {
+		t.Errorf("expected the rolled back URL sig keys, got %v", keys)
+	}
+	if err := f.PromoteSecretVersion(trafficvault.SecretTypeURLSigKeys, "ds1", 5, nil, ctx); err != trafficvault.ErrSecretVersionNotFound {
+		t.Errorf("expected promoting a missing version to fail with %v, got %v", trafficvault.ErrSecretVersionNotFound, err)
+	}
+
+	if err := f.DeleteURLSigKeys("ds1", nil, ctx); err != nil {
+		t.Fatalf("deleting URL sig keys: %v", err)
+	}
+	if _, exists, _ := f.GetURLSigKeys("ds1", nil, ctx); exists {
+		t.Error("expected no URL sig keys after deleting them")
+	}
+	if versions, _ := f.GetSecretVersions(trafficvault.SecretTypeURLSigKeys, "ds1", nil, ctx); len(versions) != 0 {
+		t.Errorf("expected no URL sig key versions after deleting the keys, got %+v", versions)
+	}
+}
+
+func TestFileURISigningAndDNSSECKeys(t *testing.T) {
+	f := newTestFile(t)
+	ctx := context.Background()
+
+	uriKeys := []byte(`{"issuer":{"renewal_kid":"a","keys":[]}}`)
+	if err := f.PutURISigningKeys("ds1", uriKeys, nil, ctx); err != nil {
+		t.Fatalf("putting URI signing keys: %v", err)
+	}
+	if keys, exists, err := f.GetURISigningKeys("ds1", nil, ctx); err != nil || !exists || !bytes.Equal(keys, uriKeys) {
+		t.Errorf("expected the stored URI signing keys, got %s, exists %t, error %v", keys, exists, err)
+	}
+
+	dnssecKeys := tc.DNSSECKeysTrafficVault{"cdn1": tc.DNSSECKeySetV11{}}
+	if err := f.PutDNSSECKeys("cdn1", dnssecKeys, nil, ctx); err != nil {
+		t.Fatalf("putting DNSSEC keys: %v", err)
+	}
+	if keys, exists, err := f.GetDNSSECKeys("cdn1", nil, ctx); err != nil || !exists || len(keys) != 1 {
+		t.Errorf("expected the stored DNSSEC keys, got %v, exists %t, error %v", keys, exists, err)
+	}
+	if err := f.DeleteDNSSECKeys("cdn1", nil, ctx); err != nil {
+		t.Fatalf("deleting DNSSEC keys: %v", err)
+	}
+	if _, exists, _ := f.GetDNSSECKeys("cdn1", nil, ctx); exists {
+		t.Error("expected no DNSSEC keys after deleting them")
+	}
+
+	if err := f.PutURISigningKeys("../ds1", uriKeys, nil, ctx); err == nil {
+		t.Error("expected an error storing keys for a name containing a path separator")
+	}
+}
+
+func TestFileSSLKeys(t *testing.T) {
+	f := newTestFile(t)
+	ctx := context.Background()
+
+	for _, key := range []tc.DeliveryServiceSSLKeys{
+		{DeliveryService: "ds1", CDN: "cdn1", Hostname: "one.example", Version: util.JSONIntStr(1)},
+		{DeliveryService: "ds1", CDN: "cdn1", Hostname: "two.example", Version: util.JSONIntStr(2)},
+		{DeliveryService: "ds2", CDN: "cdn1", Hostname: "ds2.example", Version: util.JSONIntStr(1)},
+		{DeliveryService: "ds3", CDN: "cdn2", Hostname: "ds3.example", Version: util.JSONIntStr(1)},
+	} {
+		if err := f.PutDeliveryServiceSSLKeys(key, nil, ctx); err != nil {
+			t.Fatalf("putting SSL keys: %v", err)
+		}
+	}
+
+	key, exists, err := f.GetDeliveryServiceSSLKeys("ds1", "", nil, ctx)
+	if err != nil || !exists || key.Hostname != "two.example" {
+		t.Fatalf("expected the latest SSL keys, got %+v, exists %t, error %v", key, exists, err)
+	}
+	if key, _, _ := f.GetDeliveryServiceSSLKeys("ds1", "1", nil, ctx); key.Hostname != "one.example" {
+		t.Errorf("expected SSL keys version 1, got %+v", key)
+	}
+
+	versions, err := f.GetSecretVersions(trafficvault.SecretTypeSSLKeys, "ds1", nil, ctx)
+	if err != nil || len(versions) != 2 || !versions[0].Current || versions[1].Current {
+		t.Fatalf("expected versions 2 (current) and 1, got %+v, error %v", versions, err)
+	}
+	if version, err := f.RollbackSecret(trafficvault.SecretTypeSSLKeys, "ds1", nil, ctx); err != nil || version != 1 {
+		t.Fatalf("expected to roll back to version 1, got %d, error %v", version, err)
+	}
+	if key, _, _ := f.GetDeliveryServiceSSLKeys("ds1", "", nil, ctx); key.Hostname != "one.example" {
+		t.Errorf("expected the rolled back SSL keys, got %+v", key)
+	}
+
+	for _, version := range []int{3, 4} {
+		if err := f.PutDeliveryServiceSSLKeys(tc.DeliveryServiceSSLKeys{DeliveryService: "ds1", CDN: "cdn1", Hostname: "ds1.example", Version: util.JSONIntStr(version)}, nil, ctx); err != nil {
+			t.Fatalf("putting SSL keys: %v", err)
+		}
+	}
+	versions, err = f.GetSecretVersions(trafficvault.SecretTypeSSLKeys, "ds1", nil, ctx)
+	if err != nil || len(versions) != 3 || versions[0].Version != 4 || versions[2].Version != 2 {
+		t.Fatalf("expected versions 4 (current), 3 and 2 to be retained, got %+v, error %v", versions, err)
+	}
+	if _, exists, _ := f.GetDeliveryServiceSSLKeys("ds1", "1", nil, ctx); exists {
+		t.Error("expected SSL keys version 1 to be pruned")
+	}
+
+	cdnKeys, err := f.GetCDNSSLKeys("cdn1", nil, ctx)
+	if err != nil || len(cdnKeys) != 2 {
+		t.Fatalf("expected 2 SSL keys for cdn1, got %+v, error %v", cdnKeys, err)
+	}
+
+	if err := f.DeleteOldDeliveryServiceSSLKeys(map[string]struct{}{"ds1": {}}, "cdn1", nil, ctx); err != nil {
+		t.Fatalf("deleting old SSL keys: %v", err)
+	}
+	if _, exists, _ := f.GetDeliveryServiceSSLKeys("ds2", "", nil, ctx); exists {
+		t.Error("expected the SSL keys of a delivery service that no longer exists to be deleted")
+	}
+	if _, exists, _ := f.GetDeliveryServiceSSLKeys("ds3", "", nil, ctx); !exists {
+		t.Error("expected the SSL keys of a delivery service in another CDN to be kept")
+	}
+
+	if err := f.DeleteDeliveryServiceSSLKeys("ds1", "", nil, ctx); err != nil {
+		t.Fatalf("deleting SSL keys: %v", err)
+	}
+	if _, exists, _ := f.GetDeliveryServiceSSLKeys("ds1", "", nil, ctx); exists {
+		t.Error("expected no latest SSL keys after deleting them")
+	}
+}
@@ -0,0 +1,186 @@
+package file
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault"
+)
+
+// The methods in this file expect the caller to hold the File's lock.
+
+func (f *File) readSecretHistory(secretType trafficvault.SecretType, name string) (trafficvault.SecretHistory, error) {
+	history := trafficvault.SecretHistory{}
+	path, err := f.path(versionsDir, string(secretType), name)
+	if err != nil {
+		return history, err
+	}
+	historyJSON, exists, err := f.read(path)
+	if err != nil || !exists {
+		return history, err
+	}
+	if err := json.Unmarshal(historyJSON, &history); err != nil {
+		return history, errors.New("unmarshalling secret versions: " + err.Error())
+	}
+	return history, nil
+}
+
+func (f *File) writeSecretHistory(secretType trafficvault.SecretType, name string, history trafficvault.SecretHistory) error {
+	path, err := f.path(versionsDir, string(secretType), name)
+	if err != nil {
+		return err
+	}
+	historyJSON, err := json.Marshal(&history)
+	if err != nil {
+		return errors.New("marshalling secret versions: " + err.Error())
+	}
+	return f.write(path, historyJSON)
+}
+
+// recordSecretVersion retains the given secret data as the new, current version
+// of the secret of the given type identified by the given name.
+func (f *File) recordSecretVersion(secretType trafficvault.SecretType, name string, data []byte) error {
+	history, err := f.readSecretHistory(secretType, name)
+	if err != nil {
+		return err
+	}
+	history.Add(data, f.cfg.MaxSecretVersions, time.Now())
+	return f.writeSecretHistory(secretType, name, history)
+}
+
+func (f *File) deleteSecretVersions(secretType trafficvault.SecretType, name string) error {
+	path, err := f.path(versionsDir, string(secretType), name)
+	if err != nil {
+		return err
+	}
+	return removeFile(path)
+}
+
+// promoteSecretVersion replaces the value of a secret with one of its retained
+// versions. SSL keys aren't versioned in the versions directory; see
+// promoteSSLKeyVersion.
+func (f *File) promoteSecretVersion(secretType trafficvault.SecretType, name string, version int64) error {
+	history, err := f.readSecretHistory(secretType, name)
+	if err != nil {
+		return err
+	}
+	data, err := history.Promote(version, time.Now())
+	if err != nil {
+		return err
+	}
+	path, err := f.path(string(secretType), name)
+	if err != nil {
+		return err
+	}
+	if err := f.write(path, data); err != nil {
+		return err
+	}
+	return f.writeSecretHistory(secretType, name, history)
+}
+
+// getSSLKeyVersions lists the numbered versions of the SSL keys for the given
+// delivery service. The current version is the one whose file was copied to
+// the 'latest' file.
+func (f *File) getSSLKeyVersions(xmlID string) ([]tc.TrafficVaultSecretVersion, error) {
+	dir, err := f.path(string(trafficvault.SecretTypeSSLKeys), xmlID)
+	if err != nil {
+		return nil, err
+	}
+	names, err := listDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	latestData, _, err := readFile(filepath.Join(dir, latestVersion))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []tc.TrafficVaultSecretVersion{}
+	for _, versionStr := range names {
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			continue // versions that aren't numbered can't be promoted
+		}
+		path := filepath.Join(dir, versionStr)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errors.New("checking Traffic Vault file: " + err.Error())
+		}
+		data, _, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, tc.TrafficVaultSecretVersion{
+			Version:     version,
+			Current:     latestData != nil && bytes.Equal(data, latestData),
+			LastUpdated: info.ModTime(),
+		})
+	}
+	trafficvault.SortSecretVersions(versions)
+	return versions, nil
+}
+
+// pruneSSLKeyVersions removes the numbered SSL key versions of the given
+// delivery service that fall outside of the retention window.
+func (f *File) pruneSSLKeyVersions(xmlID string) error {
+	versions, err := f.getSSLKeyVersions(xmlID)
+	if err != nil {
+		return err
+	}
+	for _, version := range trafficvault.ExpiredSecretVersions(versions, f.cfg.MaxSecretVersions) {
+		path, err := f.path(string(trafficvault.SecretTypeSSLKeys), xmlID, strconv.FormatInt(version, 10))
+		if err != nil {
+			return err
+		}
+		if err := removeFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promoteSSLKeyVersion replaces the 'latest' SSL keys of the given delivery
+// service with the given numbered version.
+func (f *File) promoteSSLKeyVersion(xmlID string, version int64) error {
+	versionPath, err := f.path(string(trafficvault.SecretTypeSSLKeys), xmlID, strconv.FormatInt(version, 10))
+	if err != nil {
+		return err
+	}
+	data, exists, err := readFile(versionPath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return trafficvault.ErrSecretVersionNotFound
+	}
+	latestPath, err := f.path(string(trafficvault.SecretTypeSSLKeys), xmlID, latestVersion)
+	if err != nil {
+		return err
+	}
+	return writeFile(latestPath, data)
+}
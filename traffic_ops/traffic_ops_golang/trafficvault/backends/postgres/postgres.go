@@ -74,6 +74,7 @@ type Config struct {
 	MaxIdleConnections     int             `json:"max_idle_connections"`
 	ConnMaxLifetimeSeconds int             `json:"conn_max_lifetime_seconds"`
 	QueryTimeoutSeconds    int             `json:"query_timeout_seconds"`
+	MaxSecretVersions      int             `json:"max_secret_versions"`
 	AesKeyLocation         string          `json:"aes_key_location"`
 	HashiCorpVault         *HashiCorpVault `json:"hashicorp_vault"`
 }
@@ -271,7 +272,10 @@ func (p *Postgres) PutDeliveryServiceSSLKeys(key tc.DeliveryServiceSSLKeys, tx *
 	} else if rowsAffected == 0 {
 		return errors.New("SSL Key: no keys were inserted")
 	}
-	return nil
+	if err := markCurrentSSLKeyVersion(key.DeliveryService, int64(key.Version), tvTx, ctx); err != nil {
+		return err
+	}
+	return pruneSSLKeyVersions(key.DeliveryService, p.cfg.MaxSecretVersions, tvTx, ctx)
 }
 
 // DeleteDeliveryServiceSSLKeys removes the SSL keys of the given version (or latest
@@ -291,6 +295,9 @@ func (p *Postgres) DeleteDeliveryServiceSSLKeys(xmlID string, version string, tx
 		e := checkErrWithContext("Traffic Vault PostgreSQL: executing DELETE SSL Key query", err, ctx.Err())
 		return e
 	}
+	if _, err := tvTx.Exec("DELETE FROM secret_version WHERE secret_type = $1 AND name = $2 AND CAST(version AS text) = $3", trafficvault.SecretTypeSSLKeys, xmlID, version); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing DELETE SSL key version marker query", err, ctx.Err())
+	}
 	return nil
 }
 
@@ -399,13 +406,20 @@ func (p *Postgres) PutDNSSECKeys(cdnName string, keys tc.DNSSECKeysTrafficVault,
 	if err != nil {
 		return errors.New("marshalling DNSSEC keys: " + err.Error())
 	}
-	_, err = tvTx.Exec("DELETE FROM dnssec WHERE cdn = $1", cdnName)
+	if err := putDNSSECKeys(cdnName, tvTx, dnssecJSON, ctx, p.aesKey); err != nil {
+		return err
+	}
+	return recordSecretVersion(trafficvault.SecretTypeDNSSECKeys, cdnName, dnssecJSON, p.cfg.MaxSecretVersions, tvTx, ctx, p.aesKey)
+}
+
+func putDNSSECKeys(cdnName string, tvTx *sqlx.Tx, dnssecJSON []byte, ctx context.Context, aesKey []byte) error {
+	_, err := tvTx.Exec("DELETE FROM dnssec WHERE cdn = $1", cdnName)
 	if err != nil {
 		e := checkErrWithContext("Traffic Vault PostgreSQL: executing DELETE DNSSEC keys query prior to INSERT", err, ctx.Err())
 		return e
 	}
 
-	encryptedKey, err := util.AESEncrypt(dnssecJSON, p.aesKey)
+	encryptedKey, err := util.AESEncrypt(dnssecJSON, aesKey)
 	if err != nil {
 		return errors.New("encrypting keys: " + err.Error())
 	}
@@ -434,7 +448,7 @@ func (p *Postgres) DeleteDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Cont
 		e := checkErrWithContext("Traffic Vault PostgreSQL: executing DELETE DNSSEC keys query", err, ctx.Err())
 		return e
 	}
-	return nil
+	return deleteSecretVersions(trafficvault.SecretTypeDNSSECKeys, cdnName, tvTx, ctx)
 }
 
 func (p *Postgres) GetURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) (tc.URLSigKeys, bool, error) {
@@ -453,7 +467,14 @@ func (p *Postgres) PutURLSigKeys(xmlID string, keys tc.URLSigKeys, tx *sql.Tx, c
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
 
-	return putURLSigKeys(xmlID, tvTx, keys, ctx, p.aesKey)
+	if err := putURLSigKeys(xmlID, tvTx, keys, ctx, p.aesKey); err != nil {
+		return err
+	}
+	keyJSON, err := json.Marshal(&keys)
+	if err != nil {
+		return errors.New("marshalling keys: " + err.Error())
+	}
+	return recordSecretVersion(trafficvault.SecretTypeURLSigKeys, xmlID, keyJSON, p.cfg.MaxSecretVersions, tvTx, ctx, p.aesKey)
 }
 
 func (p *Postgres) DeleteURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
@@ -463,7 +484,10 @@ func (p *Postgres) DeleteURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Contex
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
 
-	return deleteURLSigKeys(xmlID, tvTx, ctx)
+	if err := deleteURLSigKeys(xmlID, tvTx, ctx); err != nil {
+		return err
+	}
+	return deleteSecretVersions(trafficvault.SecretTypeURLSigKeys, xmlID, tvTx, ctx)
 }
 
 func (p *Postgres) GetURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) ([]byte, bool, error) {
@@ -482,7 +506,10 @@ func (p *Postgres) PutURISigningKeys(xmlID string, keysJson []byte, tx *sql.Tx,
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
 
-	return putURISigningKeys(xmlID, tvTx, keysJson, ctx, p.aesKey)
+	if err := putURISigningKeys(xmlID, tvTx, keysJson, ctx, p.aesKey); err != nil {
+		return err
+	}
+	return recordSecretVersion(trafficvault.SecretTypeURISigningKeys, xmlID, keysJson, p.cfg.MaxSecretVersions, tvTx, ctx, p.aesKey)
 }
 
 func (p *Postgres) DeleteURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
@@ -492,7 +519,10 @@ func (p *Postgres) DeleteURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Co
 	}
 	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
 
-	return deleteURISigningKeys(xmlID, tvTx, ctx)
+	if err := deleteURISigningKeys(xmlID, tvTx, ctx); err != nil {
+		return err
+	}
+	return deleteSecretVersions(trafficvault.SecretTypeURISigningKeys, xmlID, tvTx, ctx)
 }
 
 func (p *Postgres) Ping(tx *sql.Tx, ctx context.Context) (tc.TrafficVaultPing, error) {
@@ -516,6 +546,64 @@ func (p *Postgres) GetBucketKey(bucket string, key string, tx *sql.Tx) ([]byte,
 	return nil, false, notImplementedErr
 }
 
+// GetSecretVersions lists the retained versions of the secret of the given
+// type identified by the given name, newest first.
+func (p *Postgres) GetSecretVersions(secretType trafficvault.SecretType, name string, tx *sql.Tx, ctx context.Context) ([]tc.TrafficVaultSecretVersion, error) {
+	tvTx, dbCtx, cancelFunc, err := p.beginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
+	if secretType == trafficvault.SecretTypeSSLKeys {
+		return getSSLKeyVersions(name, tvTx, ctx)
+	}
+	return getSecretVersions(secretType, name, tvTx, ctx)
+}
+
+// PromoteSecretVersion makes the given retained version the current value of
+// the secret of the given type identified by the given name.
+func (p *Postgres) PromoteSecretVersion(secretType trafficvault.SecretType, name string, version int64, tx *sql.Tx, ctx context.Context) error {
+	tvTx, dbCtx, cancelFunc, err := p.beginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
+	if secretType == trafficvault.SecretTypeSSLKeys {
+		return promoteSSLKeyVersion(name, version, tvTx, ctx)
+	}
+	return promoteSecretVersion(secretType, name, version, tvTx, ctx, p.aesKey)
+}
+
+// RollbackSecret makes the newest retained version that is older than the
+// current version of the given secret the current value.
+func (p *Postgres) RollbackSecret(secretType trafficvault.SecretType, name string, tx *sql.Tx, ctx context.Context) (int64, error) {
+	tvTx, dbCtx, cancelFunc, err := p.beginTransaction(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer p.commitTransaction(tvTx, dbCtx, cancelFunc)
+
+	var versions []tc.TrafficVaultSecretVersion
+	if secretType == trafficvault.SecretTypeSSLKeys {
+		versions, err = getSSLKeyVersions(name, tvTx, ctx)
+	} else {
+		versions, err = getSecretVersions(secretType, name, tvTx, ctx)
+	}
+	if err != nil {
+		return 0, err
+	}
+	version, err := trafficvault.RollbackSecretVersion(versions)
+	if err != nil {
+		return 0, err
+	}
+	if secretType == trafficvault.SecretTypeSSLKeys {
+		err = promoteSSLKeyVersion(name, version, tvTx, ctx)
+	} else {
+		err = promoteSecretVersion(secretType, name, version, tvTx, ctx, p.aesKey)
+	}
+	return version, err
+}
+
 func init() {
 	trafficvault.AddBackend(postgresBackendName, postgresLoad)
 }
@@ -537,6 +625,9 @@ func postgresLoad(b json.RawMessage) (trafficvault.TrafficVault, error) {
 	if pgCfg.QueryTimeoutSeconds == 0 {
 		pgCfg.QueryTimeoutSeconds = defaultDBQueryTimeoutSecs
 	}
+	if pgCfg.MaxSecretVersions == 0 {
+		pgCfg.MaxSecretVersions = trafficvault.DefaultMaxSecretVersions
+	}
 	if pgCfg.HashiCorpVault != nil {
 		if pgCfg.HashiCorpVault.LoginPath == "" {
 			pgCfg.HashiCorpVault.LoginPath = defaultHashiCorpVaultLoginPath
@@ -584,6 +675,7 @@ func validateConfig(cfg Config) error {
 		"port":                  validation.Validate(cfg.Port, validation.By(tovalidate.IsValidPortNumber)),
 		"max_connections":       validation.Validate(cfg.MaxConnections, validation.Min(0)),
 		"query_timeout_seconds": validation.Validate(cfg.QueryTimeoutSeconds, validation.Min(0)),
+		"max_secret_versions":   validation.Validate(cfg.MaxSecretVersions, validation.Min(0)),
 	})
 	aesKeyLocSet := cfg.AesKeyLocation != ""
 	hashiCorpVaultSet := cfg.HashiCorpVault != nil && *cfg.HashiCorpVault != HashiCorpVault{}
@@ -0,0 +1,232 @@
+package postgres
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// recordSecretVersion stores the given (unencrypted) secret data as a new
+// version of the secret, marks it as the current version, and removes any
+// versions that fall outside of the retention window of maxVersions.
+func recordSecretVersion(secretType trafficvault.SecretType, name string, data []byte, maxVersions int, tvTx *sqlx.Tx, ctx context.Context, aesKey []byte) error {
+	versions, err := getSecretVersions(secretType, name, tvTx, ctx)
+	if err != nil {
+		return err
+	}
+	encryptedData, err := util.AESEncrypt(data, aesKey)
+	if err != nil {
+		return errors.New("encrypting secret version: " + err.Error())
+	}
+	if _, err := tvTx.Exec("UPDATE secret_version SET is_current = false WHERE secret_type = $1 AND name = $2 AND is_current", secretType, name); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing UPDATE secret version query", err, ctx.Err())
+	}
+	version := trafficvault.NextSecretVersion(versions)
+	if _, err := tvTx.Exec("INSERT INTO secret_version (secret_type, name, version, data, is_current) VALUES ($1, $2, $3, $4, true)", secretType, name, version, encryptedData); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing INSERT secret version query", err, ctx.Err())
+	}
+
+	for i := range versions {
+		versions[i].Current = false
+	}
+	versions = append(versions, tc.TrafficVaultSecretVersion{Version: version, Current: true})
+	expired := trafficvault.ExpiredSecretVersions(versions, maxVersions)
+	if len(expired) == 0 {
+		return nil
+	}
+	if _, err := tvTx.Exec("DELETE FROM secret_version WHERE secret_type = $1 AND name = $2 AND version = ANY($3)", secretType, name, pq.Array(expired)); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing DELETE expired secret versions query", err, ctx.Err())
+	}
+	return nil
+}
+
+func getSecretVersions(secretType trafficvault.SecretType, name string, tvTx *sqlx.Tx, ctx context.Context) ([]tc.TrafficVaultSecretVersion, error) {
+	rows, err := tvTx.Query("SELECT version, is_current, last_updated FROM secret_version WHERE secret_type = $1 AND name = $2 ORDER BY version DESC", secretType, name)
+	if err != nil {
+		return nil, checkErrWithContext("Traffic Vault PostgreSQL: executing SELECT secret versions query", err, ctx.Err())
+	}
+	defer rows.Close()
+	versions := []tc.TrafficVaultSecretVersion{}
+	for rows.Next() {
+		v := tc.TrafficVaultSecretVersion{}
+		if err := rows.Scan(&v.Version, &v.Current, &v.LastUpdated); err != nil {
+			return nil, checkErrWithContext("Traffic Vault PostgreSQL: scanning secret versions", err, ctx.Err())
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func deleteSecretVersions(secretType trafficvault.SecretType, name string, tvTx *sqlx.Tx, ctx context.Context) error {
+	if _, err := tvTx.Exec("DELETE FROM secret_version WHERE secret_type = $1 AND name = $2", secretType, name); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing DELETE secret versions query", err, ctx.Err())
+	}
+	return nil
+}
+
+// promoteSecretVersion restores the given retained version of a secret as its
+// current value. This does not apply to SSL keys, which are versioned in the
+// sslkey table itself; see promoteSSLKeyVersion.
+func promoteSecretVersion(secretType trafficvault.SecretType, name string, version int64, tvTx *sqlx.Tx, ctx context.Context, aesKey []byte) error {
+	var encryptedData []byte
+	if err := tvTx.QueryRow("SELECT data FROM secret_version WHERE secret_type = $1 AND name = $2 AND version = $3", secretType, name, version).Scan(&encryptedData); err != nil {
+		if err == sql.ErrNoRows {
+			return trafficvault.ErrSecretVersionNotFound
+		}
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing SELECT secret version query", err, ctx.Err())
+	}
+	data, err := util.AESDecrypt(encryptedData, aesKey)
+	if err != nil {
+		return errors.New("decrypting secret version: " + err.Error())
+	}
+
+	switch secretType {
+	case trafficvault.SecretTypeURLSigKeys:
+		keys := tc.URLSigKeys{}
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return errors.New("unmarshalling URL sig keys version: " + err.Error())
+		}
+		err = putURLSigKeys(name, tvTx, keys, ctx, aesKey)
+	case trafficvault.SecretTypeURISigningKeys:
+		err = putURISigningKeys(name, tvTx, data, ctx, aesKey)
+	case trafficvault.SecretTypeDNSSECKeys:
+		err = putDNSSECKeys(name, tvTx, data, ctx, aesKey)
+	default:
+		return errors.New("secret type '" + string(secretType) + "' is not versioned in the secret_version table")
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tvTx.Exec("UPDATE secret_version SET is_current = (version = $3) WHERE secret_type = $1 AND name = $2 AND (is_current OR version = $3)", secretType, name, version); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing UPDATE secret version query", err, ctx.Err())
+	}
+	return nil
+}
+
+// getSSLKeyVersions lists the numbered versions of the SSL keys for the given
+// delivery service. Which of them is current is recorded in the secret_version
+// table by markCurrentSSLKeyVersion.
+func getSSLKeyVersions(xmlID string, tvTx *sqlx.Tx, ctx context.Context) ([]tc.TrafficVaultSecretVersion, error) {
+	q := `
+SELECT s.version, COALESCE(v.is_current, false), s.last_updated
+FROM sslkey AS s
+LEFT JOIN secret_version AS v ON v.secret_type = $2 AND v.name = s.deliveryservice AND CAST(v.version AS text) = s.version
+WHERE s.deliveryservice = $1 AND s.version <> $3
+`
+	rows, err := tvTx.Query(q, xmlID, trafficvault.SecretTypeSSLKeys, latestVersion)
+	if err != nil {
+		return nil, checkErrWithContext("Traffic Vault PostgreSQL: executing SELECT SSL key versions query", err, ctx.Err())
+	}
+	defer rows.Close()
+
+	versions := []tc.TrafficVaultSecretVersion{}
+	for rows.Next() {
+		var versionStr string
+		v := tc.TrafficVaultSecretVersion{}
+		if err := rows.Scan(&versionStr, &v.Current, &v.LastUpdated); err != nil {
+			return nil, checkErrWithContext("Traffic Vault PostgreSQL: scanning SSL key versions", err, ctx.Err())
+		}
+		if v.Version, err = strconv.ParseInt(versionStr, 10, 64); err != nil {
+			continue // versions that aren't numbered can't be promoted
+		}
+		versions = append(versions, v)
+	}
+	trafficvault.SortSecretVersions(versions)
+	return versions, nil
+}
+
+// markCurrentSSLKeyVersion records the given numbered version as the one that
+// was copied into the 'latest' SSL keys of the given delivery service. The
+// keys themselves stay in the sslkey table, so these rows carry no data.
+func markCurrentSSLKeyVersion(xmlID string, version int64, tvTx *sqlx.Tx, ctx context.Context) error {
+	if _, err := tvTx.Exec("UPDATE secret_version SET is_current = false WHERE secret_type = $1 AND name = $2 AND is_current AND version <> $3", trafficvault.SecretTypeSSLKeys, xmlID, version); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing UPDATE SSL key version query", err, ctx.Err())
+	}
+	q := `
+INSERT INTO secret_version (secret_type, name, version, data, is_current)
+VALUES ($1, $2, $3, NULL, true)
+ON CONFLICT (secret_type, name, version) DO UPDATE SET is_current = true
+`
+	if _, err := tvTx.Exec(q, trafficvault.SecretTypeSSLKeys, xmlID, version); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing INSERT SSL key version query", err, ctx.Err())
+	}
+	return nil
+}
+
+// pruneSSLKeyVersions removes the numbered SSL key versions of the given
+// delivery service that fall outside of the retention window of maxVersions.
+func pruneSSLKeyVersions(xmlID string, maxVersions int, tvTx *sqlx.Tx, ctx context.Context) error {
+	versions, err := getSSLKeyVersions(xmlID, tvTx, ctx)
+	if err != nil {
+		return err
+	}
+	expired := []string{}
+	for _, version := range trafficvault.ExpiredSecretVersions(versions, maxVersions) {
+		expired = append(expired, strconv.FormatInt(version, 10))
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	if _, err := tvTx.Exec("DELETE FROM sslkey WHERE deliveryservice = $1 AND version = ANY($2)", xmlID, pq.Array(expired)); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing DELETE expired SSL key versions query", err, ctx.Err())
+	}
+	if _, err := tvTx.Exec("DELETE FROM secret_version WHERE secret_type = $1 AND name = $2 AND CAST(version AS text) = ANY($3)", trafficvault.SecretTypeSSLKeys, xmlID, pq.Array(expired)); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing DELETE expired SSL key version markers query", err, ctx.Err())
+	}
+	return nil
+}
+
+// promoteSSLKeyVersion replaces the 'latest' SSL keys of the given delivery
+// service with the given numbered version.
+func promoteSSLKeyVersion(xmlID string, version int64, tvTx *sqlx.Tx, ctx context.Context) error {
+	versionStr := strconv.FormatInt(version, 10)
+	exists := false
+	if err := tvTx.QueryRow("SELECT EXISTS(SELECT 1 FROM sslkey WHERE deliveryservice = $1 AND version = $2)", xmlID, versionStr).Scan(&exists); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing SELECT SSL key version query", err, ctx.Err())
+	}
+	if !exists {
+		return trafficvault.ErrSecretVersionNotFound
+	}
+	if _, err := tvTx.Exec("DELETE FROM sslkey WHERE deliveryservice = $1 AND version = $2", xmlID, latestVersion); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing DELETE SSL Key query for promotion", err, ctx.Err())
+	}
+	q := `
+INSERT INTO sslkey (deliveryservice, data, cdn, version, provider, expiration)
+SELECT deliveryservice, data, cdn, $3, provider, expiration
+FROM sslkey
+WHERE deliveryservice = $1 AND version = $2
+`
+	if _, err := tvTx.Exec(q, xmlID, versionStr, latestVersion); err != nil {
+		return checkErrWithContext("Traffic Vault PostgreSQL: executing INSERT SSL Key query for promotion", err, ctx.Err())
+	}
+	return markCurrentSSLKeyVersion(xmlID, version, tvTx, ctx)
+}
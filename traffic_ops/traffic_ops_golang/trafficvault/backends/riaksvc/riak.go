@@ -49,7 +49,10 @@ func (r *Riak) GetExpirationInformation(tx *sql.Tx, ctx context.Context, days in
 }
 
 func (r *Riak) PutDeliveryServiceSSLKeys(key tc.DeliveryServiceSSLKeys, tx *sql.Tx, ctx context.Context) error {
-	return putDeliveryServiceSSLKeysObj(key, tx, &r.cfg.AuthOptions, &r.cfg.Port)
+	if err := putDeliveryServiceSSLKeysObj(key, tx, &r.cfg.AuthOptions, &r.cfg.Port); err != nil {
+		return err
+	}
+	return pruneSSLKeyVersions(tx, &r.cfg.AuthOptions, &r.cfg.Port, key.DeliveryService, r.cfg.MaxSecretVersions)
 }
 
 func (r *Riak) DeleteDeliveryServiceSSLKeys(xmlID string, version string, tx *sql.Tx, ctx context.Context) error {
@@ -70,11 +73,21 @@ func (r *Riak) GetDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Context) (t
 }
 
 func (r *Riak) PutDNSSECKeys(cdnName string, keys tc.DNSSECKeysTrafficVault, tx *sql.Tx, ctx context.Context) error {
-	return putDNSSECKeys(tc.DNSSECKeysRiak(keys), cdnName, tx, &r.cfg.AuthOptions, &r.cfg.Port)
+	if err := putDNSSECKeys(tc.DNSSECKeysRiak(keys), cdnName, tx, &r.cfg.AuthOptions, &r.cfg.Port); err != nil {
+		return err
+	}
+	keyJSON, err := json.Marshal(&keys)
+	if err != nil {
+		return errors.New("marshalling keys: " + err.Error())
+	}
+	return recordSecretVersion(tx, &r.cfg.AuthOptions, &r.cfg.Port, trafficvault.SecretTypeDNSSECKeys, cdnName, keyJSON, r.cfg.MaxSecretVersions)
 }
 
 func (r *Riak) DeleteDNSSECKeys(cdnName string, tx *sql.Tx, ctx context.Context) error {
-	return deleteDNSSECKeys(cdnName, tx, &r.cfg.AuthOptions, &r.cfg.Port)
+	if err := deleteDNSSECKeys(cdnName, tx, &r.cfg.AuthOptions, &r.cfg.Port); err != nil {
+		return err
+	}
+	return deleteSecretVersions(tx, &r.cfg.AuthOptions, &r.cfg.Port, trafficvault.SecretTypeDNSSECKeys, cdnName)
 }
 
 func (r *Riak) GetURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) (tc.URLSigKeys, bool, error) {
@@ -82,11 +95,21 @@ func (r *Riak) GetURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) (tc.
 }
 
 func (r *Riak) PutURLSigKeys(xmlID string, keys tc.URLSigKeys, tx *sql.Tx, ctx context.Context) error {
-	return putURLSigKeys(tx, &r.cfg.AuthOptions, &r.cfg.Port, tc.DeliveryServiceName(xmlID), keys)
+	if err := putURLSigKeys(tx, &r.cfg.AuthOptions, &r.cfg.Port, tc.DeliveryServiceName(xmlID), keys); err != nil {
+		return err
+	}
+	keyJSON, err := json.Marshal(&keys)
+	if err != nil {
+		return errors.New("marshalling keys: " + err.Error())
+	}
+	return recordSecretVersion(tx, &r.cfg.AuthOptions, &r.cfg.Port, trafficvault.SecretTypeURLSigKeys, xmlID, keyJSON, r.cfg.MaxSecretVersions)
 }
 
 func (r *Riak) DeleteURLSigKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
-	return deleteURLSigningKeys(tx, &r.cfg.AuthOptions, &r.cfg.Port, tc.DeliveryServiceName(xmlID))
+	if err := deleteURLSigningKeys(tx, &r.cfg.AuthOptions, &r.cfg.Port, tc.DeliveryServiceName(xmlID)); err != nil {
+		return err
+	}
+	return deleteSecretVersions(tx, &r.cfg.AuthOptions, &r.cfg.Port, trafficvault.SecretTypeURLSigKeys, xmlID)
 }
 
 func (r *Riak) GetURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) ([]byte, bool, error) {
@@ -94,11 +117,17 @@ func (r *Riak) GetURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context)
 }
 
 func (r *Riak) PutURISigningKeys(xmlID string, keysJson []byte, tx *sql.Tx, ctx context.Context) error {
-	return putURISigningKeys(tx, &r.cfg.AuthOptions, &r.cfg.Port, xmlID, keysJson)
+	if err := putURISigningKeys(tx, &r.cfg.AuthOptions, &r.cfg.Port, xmlID, keysJson); err != nil {
+		return err
+	}
+	return recordSecretVersion(tx, &r.cfg.AuthOptions, &r.cfg.Port, trafficvault.SecretTypeURISigningKeys, xmlID, keysJson, r.cfg.MaxSecretVersions)
 }
 
 func (r *Riak) DeleteURISigningKeys(xmlID string, tx *sql.Tx, ctx context.Context) error {
-	return deleteURISigningKeys(tx, &r.cfg.AuthOptions, &r.cfg.Port, xmlID)
+	if err := deleteURISigningKeys(tx, &r.cfg.AuthOptions, &r.cfg.Port, xmlID); err != nil {
+		return err
+	}
+	return deleteSecretVersions(tx, &r.cfg.AuthOptions, &r.cfg.Port, trafficvault.SecretTypeURISigningKeys, xmlID)
 }
 
 func (r *Riak) Ping(tx *sql.Tx, ctx context.Context) (tc.TrafficVaultPing, error) {
@@ -110,6 +139,26 @@ func (r *Riak) GetBucketKey(bucket string, key string, tx *sql.Tx) ([]byte, bool
 	return getBucketKey(tx, &r.cfg.AuthOptions, &r.cfg.Port, bucket, key)
 }
 
+func (r *Riak) GetSecretVersions(secretType trafficvault.SecretType, name string, tx *sql.Tx, ctx context.Context) ([]tc.TrafficVaultSecretVersion, error) {
+	return getSecretVersions(tx, &r.cfg.AuthOptions, &r.cfg.Port, secretType, name)
+}
+
+func (r *Riak) PromoteSecretVersion(secretType trafficvault.SecretType, name string, version int64, tx *sql.Tx, ctx context.Context) error {
+	return promoteSecretVersion(tx, &r.cfg.AuthOptions, &r.cfg.Port, secretType, name, version)
+}
+
+func (r *Riak) RollbackSecret(secretType trafficvault.SecretType, name string, tx *sql.Tx, ctx context.Context) (int64, error) {
+	versions, err := getSecretVersions(tx, &r.cfg.AuthOptions, &r.cfg.Port, secretType, name)
+	if err != nil {
+		return 0, err
+	}
+	version, err := trafficvault.RollbackSecretVersion(versions)
+	if err != nil {
+		return 0, err
+	}
+	return version, promoteSecretVersion(tx, &r.cfg.AuthOptions, &r.cfg.Port, secretType, name, version)
+}
+
 func init() {
 	trafficvault.AddBackend(RiakBackendName, riakConfigLoad)
 }
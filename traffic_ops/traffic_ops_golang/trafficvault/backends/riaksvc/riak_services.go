@@ -34,6 +34,7 @@ import (
 
 	"github.com/apache/trafficcontrol/v8/lib/go-log"
 	"github.com/apache/trafficcontrol/v8/lib/go-util"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault"
 
 	"github.com/basho/riak-go-client"
 )
@@ -111,7 +112,8 @@ func setMaxTLSVersion(riakConfig *TOAuthOptions) error {
 
 type Config struct {
 	riak.AuthOptions
-	Port uint
+	Port              uint
+	MaxSecretVersions int
 }
 
 func unmarshalRiakConfig(riakConfBytes json.RawMessage) (Config, error) {
@@ -127,7 +129,8 @@ func unmarshalRiakConfig(riakConfBytes json.RawMessage) (Config, error) {
 	}
 
 	type config struct {
-		Hci string `json:"HealthCheckInterval"`
+		Hci               string `json:"HealthCheckInterval"`
+		MaxSecretVersions int    `json:"MaxSecretVersions"`
 	}
 
 	var checkconfig config
@@ -153,6 +156,10 @@ func unmarshalRiakConfig(riakConfBytes json.RawMessage) (Config, error) {
 	if conf.Port == 0 {
 		conf.Port = defaultRiakPort
 	}
+	conf.MaxSecretVersions = checkconfig.MaxSecretVersions
+	if conf.MaxSecretVersions <= 0 {
+		conf.MaxSecretVersions = trafficvault.DefaultMaxSecretVersions
+	}
 	return conf, nil
 }
 
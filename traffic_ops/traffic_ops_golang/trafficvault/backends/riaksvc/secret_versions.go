@@ -0,0 +1,252 @@
+package riaksvc
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault"
+
+	"github.com/basho/riak-go-client"
+)
+
+// secretVersionsBucket is the bucket that holds the retained versions of
+// every secret except SSL keys, which are versioned in their own bucket.
+const secretVersionsBucket = "secret_versions"
+
+func makeSecretHistoryKey(secretType trafficvault.SecretType, name string) string {
+	return string(secretType) + "-" + name
+}
+
+func fetchSecretHistory(secretType trafficvault.SecretType, name string, cluster StorageCluster) (trafficvault.SecretHistory, error) {
+	history := trafficvault.SecretHistory{}
+	ro, err := fetchObjectValues(makeSecretHistoryKey(secretType, name), secretVersionsBucket, cluster)
+	if err != nil {
+		return history, err
+	}
+	if len(ro) == 0 {
+		return history, nil
+	}
+	if err := json.Unmarshal(ro[0].Value, &history); err != nil {
+		return history, errors.New("unmarshalling Riak secret versions: " + err.Error())
+	}
+	return history, nil
+}
+
+func saveSecretHistory(secretType trafficvault.SecretType, name string, history trafficvault.SecretHistory, cluster StorageCluster) error {
+	historyJSON, err := json.Marshal(&history)
+	if err != nil {
+		return errors.New("marshalling secret versions: " + err.Error())
+	}
+	obj := &riak.Object{
+		ContentType:     rfc.ApplicationJSON,
+		Charset:         "utf-8",
+		ContentEncoding: "utf-8",
+		Key:             makeSecretHistoryKey(secretType, name),
+		Value:           historyJSON,
+	}
+	if err := saveObject(obj, secretVersionsBucket, cluster); err != nil {
+		return errors.New("saving Riak object: " + err.Error())
+	}
+	return nil
+}
+
+// recordSecretVersion retains the given secret data as the new, current version
+// of the secret of the given type identified by the given name.
+func recordSecretVersion(tx *sql.Tx, authOpts *riak.AuthOptions, riakPort *uint, secretType trafficvault.SecretType, name string, data []byte, maxVersions int) error {
+	return withCluster(tx, authOpts, riakPort, func(cluster StorageCluster) error {
+		history, err := fetchSecretHistory(secretType, name, cluster)
+		if err != nil {
+			return err
+		}
+		history.Add(data, maxVersions, time.Now())
+		return saveSecretHistory(secretType, name, history, cluster)
+	})
+}
+
+func deleteSecretVersions(tx *sql.Tx, authOpts *riak.AuthOptions, riakPort *uint, secretType trafficvault.SecretType, name string) error {
+	cluster, err := getPooledCluster(tx, authOpts, riakPort)
+	if err != nil {
+		return errors.New("getting pooled Riak cluster: " + err.Error())
+	}
+	if err := deleteObject(makeSecretHistoryKey(secretType, name), secretVersionsBucket, cluster); err != nil {
+		return errors.New("deleting secret versions: " + err.Error())
+	}
+	return nil
+}
+
+func getSecretVersions(tx *sql.Tx, authOpts *riak.AuthOptions, riakPort *uint, secretType trafficvault.SecretType, name string) ([]tc.TrafficVaultSecretVersion, error) {
+	if secretType == trafficvault.SecretTypeSSLKeys {
+		return getSSLKeyVersions(tx, authOpts, riakPort, name)
+	}
+	versions := []tc.TrafficVaultSecretVersion{}
+	err := withCluster(tx, authOpts, riakPort, func(cluster StorageCluster) error {
+		history, err := fetchSecretHistory(secretType, name, cluster)
+		if err != nil {
+			return err
+		}
+		versions = history.SecretVersions()
+		return nil
+	})
+	return versions, err
+}
+
+func promoteSecretVersion(tx *sql.Tx, authOpts *riak.AuthOptions, riakPort *uint, secretType trafficvault.SecretType, name string, version int64) error {
+	if secretType == trafficvault.SecretTypeSSLKeys {
+		return promoteSSLKeyVersion(tx, authOpts, riakPort, name, version)
+	}
+	var history trafficvault.SecretHistory
+	var data []byte
+	err := withCluster(tx, authOpts, riakPort, func(cluster StorageCluster) error {
+		var err error
+		if history, err = fetchSecretHistory(secretType, name, cluster); err != nil {
+			return err
+		}
+		data, err = history.Promote(version, time.Now())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	switch secretType {
+	case trafficvault.SecretTypeURLSigKeys:
+		keys := tc.URLSigKeys{}
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return errors.New("unmarshalling URL sig keys version: " + err.Error())
+		}
+		err = putURLSigKeys(tx, authOpts, riakPort, tc.DeliveryServiceName(name), keys)
+	case trafficvault.SecretTypeURISigningKeys:
+		err = putURISigningKeys(tx, authOpts, riakPort, name, data)
+	case trafficvault.SecretTypeDNSSECKeys:
+		keys := tc.DNSSECKeysRiak{}
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return errors.New("unmarshalling DNSSEC keys version: " + err.Error())
+		}
+		err = putDNSSECKeys(keys, name, tx, authOpts, riakPort)
+	default:
+		return errors.New("secret type '" + string(secretType) + "' is not versioned in the " + secretVersionsBucket + " bucket")
+	}
+	if err != nil {
+		return err
+	}
+	return withCluster(tx, authOpts, riakPort, func(cluster StorageCluster) error {
+		return saveSecretHistory(secretType, name, history, cluster)
+	})
+}
+
+// getSSLKeyVersions lists the numbered versions of the SSL keys for the given
+// delivery service. The current version is the one whose value was copied to
+// the 'latest' key.
+func getSSLKeyVersions(tx *sql.Tx, authOpts *riak.AuthOptions, riakPort *uint, xmlID string) ([]tc.TrafficVaultSecretVersion, error) {
+	versions := []tc.TrafficVaultSecretVersion{}
+	err := withCluster(tx, authOpts, riakPort, func(cluster StorageCluster) error {
+		query := `deliveryservice:` + xmlID
+		fields := []string{"_yz_rk"} // '_yz_rk' is the magic Riak field that populates the key.
+		searchDocs, err := search(cluster, sslKeysIndex, query, "", cdnSSLKeysLimit, fields)
+		if err != nil {
+			return errors.New("riak search error: " + err.Error())
+		}
+
+		latest, err := fetchObjectValues(makeDSSSLKeyKey(xmlID, dsSSLKeyVersionLatest), deliveryServiceSSLKeysBucket, cluster)
+		if err != nil {
+			return err
+		}
+		for _, doc := range searchDocs {
+			versionStr := strings.TrimPrefix(doc.Key, xmlID+"-")
+			if versionStr == doc.Key || versionStr == dsSSLKeyVersionLatest {
+				continue
+			}
+			version, err := strconv.ParseInt(versionStr, 10, 64)
+			if err != nil {
+				continue // versions that aren't numbered can't be promoted
+			}
+			ro, err := fetchObjectValues(doc.Key, deliveryServiceSSLKeysBucket, cluster)
+			if err != nil {
+				return err
+			}
+			if len(ro) == 0 {
+				continue
+			}
+			versions = append(versions, tc.TrafficVaultSecretVersion{
+				Version:     version,
+				Current:     len(latest) > 0 && bytes.Equal(latest[0].Value, ro[0].Value),
+				LastUpdated: ro[0].LastModified,
+			})
+		}
+		return nil
+	})
+	trafficvault.SortSecretVersions(versions)
+	return versions, err
+}
+
+// pruneSSLKeyVersions removes the numbered SSL key versions of the given
+// delivery service that fall outside of the retention window of maxVersions.
+func pruneSSLKeyVersions(tx *sql.Tx, authOpts *riak.AuthOptions, riakPort *uint, xmlID string, maxVersions int) error {
+	versions, err := getSSLKeyVersions(tx, authOpts, riakPort, xmlID)
+	if err != nil {
+		return err
+	}
+	expired := trafficvault.ExpiredSecretVersions(versions, maxVersions)
+	if len(expired) == 0 {
+		return nil
+	}
+	return withCluster(tx, authOpts, riakPort, func(cluster StorageCluster) error {
+		for _, version := range expired {
+			if err := deleteObject(makeDSSSLKeyKey(xmlID, strconv.FormatInt(version, 10)), deliveryServiceSSLKeysBucket, cluster); err != nil {
+				return errors.New("deleting expired SSL key version: " + err.Error())
+			}
+		}
+		return nil
+	})
+}
+
+// promoteSSLKeyVersion replaces the 'latest' SSL keys of the given delivery
+// service with the given numbered version.
+func promoteSSLKeyVersion(tx *sql.Tx, authOpts *riak.AuthOptions, riakPort *uint, xmlID string, version int64) error {
+	return withCluster(tx, authOpts, riakPort, func(cluster StorageCluster) error {
+		ro, err := fetchObjectValues(makeDSSSLKeyKey(xmlID, strconv.FormatInt(version, 10)), deliveryServiceSSLKeysBucket, cluster)
+		if err != nil {
+			return err
+		}
+		if len(ro) == 0 {
+			return trafficvault.ErrSecretVersionNotFound
+		}
+		obj := &riak.Object{
+			ContentType:     rfc.ApplicationJSON,
+			Charset:         "utf-8",
+			ContentEncoding: "utf-8",
+			Key:             makeDSSSLKeyKey(xmlID, dsSSLKeyVersionLatest),
+			Value:           ro[0].Value,
+		}
+		if err := saveObject(obj, deliveryServiceSSLKeysBucket, cluster); err != nil {
+			return errors.New("saving Riak object: " + err.Error())
+		}
+		return nil
+	})
+}
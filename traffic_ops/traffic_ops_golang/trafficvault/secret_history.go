@@ -0,0 +1,98 @@
+package trafficvault
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+// SecretHistory retains the versions of one secret, for backends which store
+// the history of a secret as a single object.
+type SecretHistory struct {
+	Versions []SecretHistoryVersion `json:"versions"`
+}
+
+// SecretHistoryVersion is one retained version of a secret, along with the
+// secret's data at that version.
+type SecretHistoryVersion struct {
+	tc.TrafficVaultSecretVersion
+	Data json.RawMessage `json:"data"`
+}
+
+// Add stores the given data as a new, current version, and drops the versions
+// that fall outside of the retention window of maxVersions.
+func (h *SecretHistory) Add(data []byte, maxVersions int, now time.Time) {
+	versions := h.SecretVersions()
+	for i := range h.Versions {
+		h.Versions[i].Current = false
+	}
+	newVersion := tc.TrafficVaultSecretVersion{
+		Version:     NextSecretVersion(versions),
+		Current:     true,
+		LastUpdated: now,
+	}
+	h.Versions = append(h.Versions, SecretHistoryVersion{TrafficVaultSecretVersion: newVersion, Data: data})
+
+	expired := map[int64]struct{}{}
+	for _, v := range ExpiredSecretVersions(h.SecretVersions(), maxVersions) {
+		expired[v] = struct{}{}
+	}
+	retained := make([]SecretHistoryVersion, 0, len(h.Versions))
+	for _, v := range h.Versions {
+		if _, ok := expired[v.Version]; !ok {
+			retained = append(retained, v)
+		}
+	}
+	h.Versions = retained
+}
+
+// Promote marks the given version as current and returns its data. If the
+// version is not retained, ErrSecretVersionNotFound is returned.
+func (h *SecretHistory) Promote(version int64, now time.Time) ([]byte, error) {
+	idx := -1
+	for i, v := range h.Versions {
+		if v.Version == version {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, ErrSecretVersionNotFound
+	}
+	for i := range h.Versions {
+		h.Versions[i].Current = i == idx
+	}
+	h.Versions[idx].LastUpdated = now
+	return h.Versions[idx].Data, nil
+}
+
+// SecretVersions returns the retained versions, newest first, without their
+// data.
+func (h *SecretHistory) SecretVersions() []tc.TrafficVaultSecretVersion {
+	versions := make([]tc.TrafficVaultSecretVersion, 0, len(h.Versions))
+	for _, v := range h.Versions {
+		versions = append(versions, v.TrafficVaultSecretVersion)
+	}
+	SortSecretVersions(versions)
+	return versions
+}
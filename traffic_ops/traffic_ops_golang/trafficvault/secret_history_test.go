@@ -0,0 +1,73 @@
+package trafficvault
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSecretHistory(t *testing.T) {
+	history := SecretHistory{}
+	now := time.Now()
+	for _, data := range []string{`{"v":1}`, `{"v":2}`, `{"v":3}`, `{"v":4}`} {
+		history.Add([]byte(data), 2, now)
+	}
+	versions := history.SecretVersions()
+	if len(versions) != 3 {
+		t.Fatalf("expected 3 retained versions, got %d", len(versions))
+	}
+	if versions[0].Version != 4 || !versions[0].Current {
+		t.Errorf("expected the newest version 4 to be current, got %+v", versions[0])
+	}
+	if versions[2].Version != 2 {
+		t.Errorf("expected the oldest retained version to be 2, got %d", versions[2].Version)
+	}
+
+	promoted := now.Add(time.Hour)
+	data, err := history.Promote(3, promoted)
+	if err != nil {
+		t.Fatalf("unexpected error promoting version 3: %v", err)
+	}
+	if string(data) != `{"v":3}` {
+		t.Errorf("expected the data of version 3, got %s", data)
+	}
+	for _, v := range history.SecretVersions() {
+		if v.Current != (v.Version == 3) {
+			t.Errorf("expected only version 3 to be current, got %+v", v)
+		}
+		if v.Version == 3 && !v.LastUpdated.Equal(promoted) {
+			t.Errorf("expected promoting version 3 to update its last updated time to %v, got %v", promoted, v.LastUpdated)
+		}
+	}
+
+	if _, err := history.Promote(1, now); err != ErrSecretVersionNotFound {
+		t.Errorf("expected ErrSecretVersionNotFound promoting an expired version, got %v", err)
+	}
+
+	history.Add([]byte(`{"v":5}`), 2, now)
+	versions = history.SecretVersions()
+	if versions[0].Version != 5 || !versions[0].Current {
+		t.Errorf("expected the new version 5 to be current, got %+v", versions[0])
+	}
+	if len(versions) != 3 {
+		t.Errorf("expected 3 retained versions, got %d", len(versions))
+	}
+}
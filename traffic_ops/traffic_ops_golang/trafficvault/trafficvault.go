@@ -25,7 +25,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/apache/trafficcontrol/v8/lib/go-tc"
 )
@@ -90,6 +92,116 @@ type TrafficVault interface {
 	// apply to every Traffic Vault backend implementation.
 	// Deprecated: this method and associated API routes will be removed in the future.
 	GetBucketKey(bucket string, key string, tx *sql.Tx) ([]byte, bool, error)
+	// GetSecretVersions lists the retained versions of the secret of the given
+	// type identified by the given name (a delivery service xmlID, or a CDN name
+	// for DNSSEC keys), newest first. Exactly one of the returned versions is
+	// marked as current, unless the secret does not exist, in which case the
+	// returned slice is empty.
+	GetSecretVersions(secretType SecretType, name string, tx *sql.Tx, ctx context.Context) ([]tc.TrafficVaultSecretVersion, error)
+	// PromoteSecretVersion makes the given retained version the current value of
+	// the secret of the given type identified by the given name. Newer versions
+	// are kept, so a promotion can be reverted by promoting another version.
+	PromoteSecretVersion(secretType SecretType, name string, version int64, tx *sql.Tx, ctx context.Context) error
+	// RollbackSecret makes the newest retained version that is older than the
+	// current version of the secret of the given type identified by the given
+	// name the current value, and returns that version.
+	RollbackSecret(secretType SecretType, name string, tx *sql.Tx, ctx context.Context) (int64, error)
+}
+
+// SecretType identifies one of the kinds of secrets stored in Traffic Vault.
+type SecretType string
+
+const (
+	SecretTypeSSLKeys        = SecretType("sslkeys")
+	SecretTypeDNSSECKeys     = SecretType("dnsseckeys")
+	SecretTypeURLSigKeys     = SecretType("urlsigkeys")
+	SecretTypeURISigningKeys = SecretType("urisigningkeys")
+)
+
+// DefaultMaxSecretVersions is the number of prior versions of each secret that
+// a Traffic Vault backend retains if it is not configured otherwise.
+const DefaultMaxSecretVersions = 5
+
+// ErrSecretVersionNotFound is returned by the secret versioning methods of a
+// TrafficVault when the requested version, or a version to roll back to, does
+// not exist.
+var ErrSecretVersionNotFound = errors.New("secret version not found")
+
+// SecretTypeFromString returns the SecretType represented by the given string,
+// or an error if it does not name a known SecretType.
+func SecretTypeFromString(s string) (SecretType, error) {
+	switch t := SecretType(s); t {
+	case SecretTypeSSLKeys, SecretTypeDNSSECKeys, SecretTypeURLSigKeys, SecretTypeURISigningKeys:
+		return t, nil
+	}
+	return "", fmt.Errorf("invalid secret type '%s', must be one of %s, %s, %s or %s", s, SecretTypeSSLKeys, SecretTypeDNSSECKeys, SecretTypeURLSigKeys, SecretTypeURISigningKeys)
+}
+
+// IsDeliveryServiceSecret returns whether secrets of this type belong to a
+// delivery service, as opposed to a CDN.
+func (t SecretType) IsDeliveryServiceSecret() bool {
+	return t != SecretTypeDNSSECKeys
+}
+
+// SortSecretVersions sorts the given versions newest first.
+func SortSecretVersions(versions []tc.TrafficVaultSecretVersion) {
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version > versions[j].Version
+	})
+}
+
+// NextSecretVersion returns the version number that a newly stored value
+// should be given, given the versions that are already retained.
+func NextSecretVersion(versions []tc.TrafficVaultSecretVersion) int64 {
+	next := int64(1)
+	for _, v := range versions {
+		if v.Version >= next {
+			next = v.Version + 1
+		}
+	}
+	return next
+}
+
+// RollbackSecretVersion returns the newest of the given versions that is older
+// than the current one, which is the version a rollback should promote. If no
+// such version exists, ErrSecretVersionNotFound is returned.
+func RollbackSecretVersion(versions []tc.TrafficVaultSecretVersion) (int64, error) {
+	current := int64(-1)
+	for _, v := range versions {
+		if v.Current {
+			current = v.Version
+			break
+		}
+	}
+	if current < 0 {
+		return 0, ErrSecretVersionNotFound
+	}
+	prev := int64(-1)
+	for _, v := range versions {
+		if v.Version < current && v.Version > prev {
+			prev = v.Version
+		}
+	}
+	if prev < 0 {
+		return 0, ErrSecretVersionNotFound
+	}
+	return prev, nil
+}
+
+// ExpiredSecretVersions returns the versions that fall outside of the retention
+// window of maxPrior versions in addition to the newest one. The current version
+// is never considered expired, even after an older version has been promoted.
+func ExpiredSecretVersions(versions []tc.TrafficVaultSecretVersion, maxPrior int) []int64 {
+	sorted := make([]tc.TrafficVaultSecretVersion, len(versions))
+	copy(sorted, versions)
+	SortSecretVersions(sorted)
+	expired := []int64{}
+	for i, v := range sorted {
+		if i > maxPrior && !v.Current {
+			expired = append(expired, v.Version)
+		}
+	}
+	return expired
 }
 
 var backends = make(map[string]LoadFunc)
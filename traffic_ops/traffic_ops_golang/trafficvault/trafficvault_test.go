@@ -0,0 +1,85 @@
+package trafficvault
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+)
+
+func TestSecretTypeFromString(t *testing.T) {
+	for _, s := range []string{"sslkeys", "dnsseckeys", "urlsigkeys", "urisigningkeys"} {
+		if typ, err := SecretTypeFromString(s); err != nil {
+			t.Errorf("expected '%s' to be a valid secret type, got error: %v", s, err)
+		} else if string(typ) != s {
+			t.Errorf("expected secret type '%s', got '%s'", s, typ)
+		}
+	}
+	if _, err := SecretTypeFromString("riak"); err == nil {
+		t.Error("expected an error for an invalid secret type, got nil")
+	}
+}
+
+func TestNextSecretVersion(t *testing.T) {
+	if v := NextSecretVersion(nil); v != 1 {
+		t.Errorf("expected the first version to be 1, got %d", v)
+	}
+	versions := []tc.TrafficVaultSecretVersion{{Version: 3}, {Version: 7, Current: true}, {Version: 5}}
+	if v := NextSecretVersion(versions); v != 8 {
+		t.Errorf("expected next version 8, got %d", v)
+	}
+}
+
+func TestRollbackSecretVersion(t *testing.T) {
+	versions := []tc.TrafficVaultSecretVersion{{Version: 4}, {Version: 3, Current: true}, {Version: 1}, {Version: 2}}
+	if v, err := RollbackSecretVersion(versions); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if v != 2 {
+		t.Errorf("expected rollback to version 2, got %d", v)
+	}
+
+	versions = []tc.TrafficVaultSecretVersion{{Version: 2}, {Version: 1, Current: true}}
+	if _, err := RollbackSecretVersion(versions); err != ErrSecretVersionNotFound {
+		t.Errorf("expected ErrSecretVersionNotFound when rolling back the oldest version, got %v", err)
+	}
+	if _, err := RollbackSecretVersion(nil); err != ErrSecretVersionNotFound {
+		t.Errorf("expected ErrSecretVersionNotFound when there are no versions, got %v", err)
+	}
+}
+
+func TestExpiredSecretVersions(t *testing.T) {
+	versions := []tc.TrafficVaultSecretVersion{{Version: 1}, {Version: 2}, {Version: 3}, {Version: 4}, {Version: 5, Current: true}}
+	expired := ExpiredSecretVersions(versions, 2)
+	if expected := []int64{2, 1}; !reflect.DeepEqual(expected, expired) {
+		t.Errorf("expected expired versions %v, got %v", expected, expired)
+	}
+
+	versions = []tc.TrafficVaultSecretVersion{{Version: 1, Current: true}, {Version: 2}, {Version: 3}, {Version: 4}}
+	expired = ExpiredSecretVersions(versions, 1)
+	if expected := []int64{2}; !reflect.DeepEqual(expected, expired) {
+		t.Errorf("expected the promoted current version to be retained, and expired versions %v, got %v", expected, expired)
+	}
+
+	if expired = ExpiredSecretVersions(versions, 5); len(expired) != 0 {
+		t.Errorf("expected no expired versions, got %v", expired)
+	}
+}
@@ -144,7 +144,13 @@ func GetCRConfig(monitorFQDN string, client *http.Client) (tc.CRConfig, error) {
 // GetCacheStats gets the cache stats from the given monitor. The stats parameters is which stats to get;
 // if stats is empty or nil, all stats are fetched.
 func GetCacheStats(monitorFQDN string, client *http.Client, stats []string) (tc.Stats, string, error) {
-	path := TrafficMonitorCacheStatsPath + "?hc=1"
+	return GetCacheStatsHistory(monitorFQDN, client, stats, 1)
+}
+
+// GetCacheStatsHistory is like GetCacheStats, but gets up to historyCount
+// polled values of each stat, rather than only the latest.
+func GetCacheStatsHistory(monitorFQDN string, client *http.Client, stats []string, historyCount int) (tc.Stats, string, error) {
+	path := TrafficMonitorCacheStatsPath + "?hc=" + strconv.Itoa(historyCount)
 	if len(stats) > 0 {
 		path += `&stats=` + strings.Join(stats, `,`)
 	}
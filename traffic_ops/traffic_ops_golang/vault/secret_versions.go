@@ -0,0 +1,210 @@
+package vault
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-log"
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/api"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/dbhelpers"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/tenant"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/traffic_ops_golang/trafficvault"
+)
+
+// GetSecretVersions lists the retained versions of the secret identified by
+// the 'type' and 'name' path parameters.
+func GetSecretVersions(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"type", "name"}, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	secretType, userErr, sysErr, errCode := checkSecretAccess(inf, false)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+
+	versions, err := inf.Vault.GetSecretVersions(secretType, inf.Params["name"], inf.Tx.Tx, r.Context())
+	if err != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("getting secret versions from Traffic Vault: "+err.Error()))
+		return
+	}
+	api.WriteResp(w, r, versions)
+}
+
+// PromoteSecretVersion makes the version identified by the 'version' path
+// parameter the current value of the secret identified by the 'type' and
+// 'name' path parameters.
+func PromoteSecretVersion(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"type", "name", "version"}, []string{"version"})
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	secretType, userErr, sysErr, errCode := checkSecretAccess(inf, true)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+
+	name := inf.Params["name"]
+	version := int64(inf.IntParams["version"])
+	if err := inf.Vault.PromoteSecretVersion(secretType, name, version, inf.Tx.Tx, r.Context()); err != nil {
+		if errors.Is(err, trafficvault.ErrSecretVersionNotFound) {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusNotFound, errors.New("version "+inf.Params["version"]+" of "+string(secretType)+" for '"+name+"' not found"), nil)
+			return
+		}
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("promoting secret version in Traffic Vault: "+err.Error()))
+		return
+	}
+	api.CreateChangeLogRawTx(api.ApiChange, secretChangeLogSubject(secretType, name)+", ACTION: Promoted "+string(secretType)+" version "+inf.Params["version"], inf.User, inf.Tx.Tx)
+	api.WriteRespAlert(w, r, tc.SuccessLevel, "Successfully promoted "+string(secretType)+" version "+inf.Params["version"]+" for '"+name+"'")
+}
+
+// RollbackSecret makes the version preceding the current one the current value
+// of the secret identified by the 'type' and 'name' path parameters.
+func RollbackSecret(w http.ResponseWriter, r *http.Request) {
+	inf, userErr, sysErr, errCode := api.NewInfo(r, []string{"type", "name"}, nil)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+	defer inf.Close()
+
+	secretType, userErr, sysErr, errCode := checkSecretAccess(inf, true)
+	if userErr != nil || sysErr != nil {
+		api.HandleErr(w, r, inf.Tx.Tx, errCode, userErr, sysErr)
+		return
+	}
+
+	name := inf.Params["name"]
+	version, err := inf.Vault.RollbackSecret(secretType, name, inf.Tx.Tx, r.Context())
+	if err != nil {
+		if errors.Is(err, trafficvault.ErrSecretVersionNotFound) {
+			api.HandleErr(w, r, inf.Tx.Tx, http.StatusNotFound, errors.New("no prior version of "+string(secretType)+" for '"+name+"' to roll back to"), nil)
+			return
+		}
+		api.HandleErr(w, r, inf.Tx.Tx, http.StatusInternalServerError, nil, errors.New("rolling back secret in Traffic Vault: "+err.Error()))
+		return
+	}
+	versionStr := strconv.FormatInt(version, 10)
+	api.CreateChangeLogRawTx(api.ApiChange, secretChangeLogSubject(secretType, name)+", ACTION: Rolled back "+string(secretType)+" to version "+versionStr, inf.User, inf.Tx.Tx)
+
+	current := tc.TrafficVaultSecretVersion{Version: version, Current: true}
+	if versions, err := inf.Vault.GetSecretVersions(secretType, name, inf.Tx.Tx, r.Context()); err != nil {
+		log.Errorln("getting secret versions from Traffic Vault after rollback: " + err.Error())
+	} else {
+		for _, v := range versions {
+			if v.Version == version {
+				current = v
+				break
+			}
+		}
+	}
+	api.WriteRespAlertObj(w, r, tc.SuccessLevel, "Successfully rolled back "+string(secretType)+" for '"+name+"' to version "+versionStr, current)
+}
+
+// checkSecretAccess verifies that Traffic Vault is enabled, that the secret
+// type in the request path is valid, and that the user may access (or, if
+// modify is true, change) the delivery service or CDN that the secret
+// belongs to.
+func checkSecretAccess(inf *api.Info, modify bool) (trafficvault.SecretType, error, error, int) {
+	if !inf.Config.TrafficVaultEnabled {
+		return "", nil, errors.New("accessing secret versions: Traffic Vault is not configured"), http.StatusInternalServerError
+	}
+	secretType, err := trafficvault.SecretTypeFromString(inf.Params["type"])
+	if err != nil {
+		return "", err, nil, http.StatusBadRequest
+	}
+	if missing := inf.User.MissingPermissions(secretPermissions(secretType, modify)...); len(missing) > 0 {
+		return "", errors.New("missing required Permissions: " + strings.Join(missing, ", ")), nil, http.StatusForbidden
+	}
+
+	name := inf.Params["name"]
+	cdnName := name
+	if secretType.IsDeliveryServiceSecret() {
+		dsTenantID, ok, err := dbhelpers.GetDSTenantIDFromXMLID(inf.Tx.Tx, name)
+		if err != nil {
+			return "", nil, errors.New("checking tenant: " + err.Error()), http.StatusInternalServerError
+		}
+		if !ok {
+			return "", errors.New("delivery service " + name + " not found"), nil, http.StatusNotFound
+		}
+		if authorized, err := tenant.IsResourceAuthorizedToUserTx(dsTenantID, inf.User, inf.Tx.Tx); err != nil {
+			return "", nil, errors.New("checking tenant: " + err.Error()), http.StatusInternalServerError
+		} else if !authorized {
+			return "", errors.New("not authorized on this tenant"), nil, http.StatusForbidden
+		}
+		if !modify {
+			return secretType, nil, nil, http.StatusOK
+		}
+		if cdnName, err = dbhelpers.GetCDNNameFromDSXMLID(inf.Tx.Tx, name); err != nil {
+			return "", nil, errors.New("getting CDN name from delivery service: " + err.Error()), http.StatusInternalServerError
+		}
+	} else {
+		if ok, err := dbhelpers.CDNExists(name, inf.Tx.Tx); err != nil {
+			return "", nil, errors.New("checking CDN existence: " + err.Error()), http.StatusInternalServerError
+		} else if !ok {
+			return "", errors.New("CDN " + name + " not found"), nil, http.StatusNotFound
+		}
+		if !modify {
+			return secretType, nil, nil, http.StatusOK
+		}
+	}
+
+	userErr, sysErr, errCode := dbhelpers.CheckIfCurrentUserCanModifyCDN(inf.Tx.Tx, cdnName, inf.User.UserName)
+	if userErr != nil || sysErr != nil {
+		return "", userErr, sysErr, errCode
+	}
+	return secretType, nil, nil, http.StatusOK
+}
+
+// secretPermissions returns the Permissions needed to view (or, if modify is
+// true, change) the versions of secrets of the given type, which are the same
+// as those needed by the endpoints that manage the secrets themselves.
+func secretPermissions(secretType trafficvault.SecretType, modify bool) []string {
+	if secretType == trafficvault.SecretTypeDNSSECKeys {
+		if modify {
+			return []string{"DNS-SEC:UPDATE", "CDN:UPDATE", "CDN:READ"}
+		}
+		return []string{"DNS-SEC:READ", "CDN:READ"}
+	}
+	if modify {
+		return []string{"DS-SECURITY-KEY:UPDATE", "DELIVERY-SERVICE:READ"}
+	}
+	return []string{"DS-SECURITY-KEY:READ", "DELIVERY-SERVICE:READ"}
+}
+
+func secretChangeLogSubject(secretType trafficvault.SecretType, name string) string {
+	if secretType.IsDeliveryServiceSecret() {
+		return "DS: " + name
+	}
+	return "CDN: " + name
+}
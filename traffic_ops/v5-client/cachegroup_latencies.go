@@ -0,0 +1,41 @@
+package client
+
+/*
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/toclientlib"
+)
+
+// apiCacheGroupLatencies is the API version-relative path for the
+// /cachegroup_latencies API endpoint.
+const apiCacheGroupLatencies = "/cachegroup_latencies"
+
+// GetCacheGroupLatencies retrieves the smoothed latencies between Cache Groups
+// and their parent Cache Groups.
+func (to *Session) GetCacheGroupLatencies(opts RequestOptions) (tc.CacheGroupLatenciesResponse, toclientlib.ReqInf, error) {
+	var data tc.CacheGroupLatenciesResponse
+	reqInf, err := to.get(apiCacheGroupLatencies, opts, &data)
+	return data, reqInf, err
+}
+
+// ReportCacheGroupLatencies reports the latencies measured by a cache server
+// to its parent Cache Groups.
+func (to *Session) ReportCacheGroupLatencies(latencies tc.CacheGroupLatenciesRequest, opts RequestOptions) (tc.CacheGroupLatenciesResponse, toclientlib.ReqInf, error) {
+	var response tc.CacheGroupLatenciesResponse
+	reqInf, err := to.post(apiCacheGroupLatencies, opts, latencies, &response)
+	return response, reqInf, err
+}
@@ -0,0 +1,53 @@
+package client
+
+/*
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+import (
+	"fmt"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-tc"
+	"github.com/apache/trafficcontrol/v8/traffic_ops/toclientlib"
+)
+
+// apiRollouts is the API version-relative path for the /rollouts API endpoint.
+const apiRollouts = "/rollouts"
+
+// apiRolloutAction is the API version-relative path for the
+// /rollouts/{{ID}}/action API endpoint.
+const apiRolloutAction = apiRollouts + "/%d/action"
+
+// CreateRollout starts a staged rollout of cache config updates across a
+// Topology.
+func (to *Session) CreateRollout(rollout tc.RolloutRequest, opts RequestOptions) (tc.RolloutResponse, toclientlib.ReqInf, error) {
+	var response tc.RolloutResponse
+	reqInf, err := to.post(apiRollouts, opts, rollout, &response)
+	return response, reqInf, err
+}
+
+// GetRollouts retrieves staged rollouts.
+func (to *Session) GetRollouts(opts RequestOptions) (tc.RolloutsResponse, toclientlib.ReqInf, error) {
+	var data tc.RolloutsResponse
+	reqInf, err := to.get(apiRollouts, opts, &data)
+	return data, reqInf, err
+}
+
+// RolloutAction pauses, resumes, advances, or rolls back the rollout with the
+// given ID.
+func (to *Session) RolloutAction(id int, action string, opts RequestOptions) (tc.RolloutResponse, toclientlib.ReqInf, error) {
+	var response tc.RolloutResponse
+	reqInf, err := to.post(fmt.Sprintf(apiRolloutAction, id), opts, tc.RolloutActionRequest{Action: action}, &response)
+	return response, reqInf, err
+}
@@ -15,6 +15,9 @@ package client
 */
 
 import (
+	"fmt"
+	"net/url"
+
 	"github.com/apache/trafficcontrol/v8/lib/go-tc"
 	"github.com/apache/trafficcontrol/v8/traffic_ops/toclientlib"
 )
@@ -22,6 +25,20 @@ import (
 const (
 	// apiVaultPing is the partial path (excluding the /api/<version> prefix) to the /vault/ping API endpoint.
 	apiVaultPing = "/vault/ping"
+
+	// apiVaultSecretVersions is the partial path (excluding the /api/<version> prefix) to the
+	// /vault/secrets/{{type}}/{{name}}/versions API endpoint. It is intended to be used with
+	// fmt.Sprintf to insert its required path parameters (namely the secret type and name).
+	apiVaultSecretVersions = "/vault/secrets/%s/%s/versions"
+	// apiVaultSecretVersionPromote is the partial path (excluding the /api/<version> prefix) to
+	// the /vault/secrets/{{type}}/{{name}}/versions/{{version}}/promote API endpoint. It is
+	// intended to be used with fmt.Sprintf to insert its required path parameters (namely the
+	// secret type, name and version).
+	apiVaultSecretVersionPromote = apiVaultSecretVersions + "/%d/promote"
+	// apiVaultSecretRollback is the partial path (excluding the /api/<version> prefix) to the
+	// /vault/secrets/{{type}}/{{name}}/rollback API endpoint. It is intended to be used with
+	// fmt.Sprintf to insert its required path parameters (namely the secret type and name).
+	apiVaultSecretRollback = "/vault/secrets/%s/%s/rollback"
 )
 
 // TrafficVaultPing returns a response indicating whether or not Traffic Vault is responsive.
@@ -30,3 +47,31 @@ func (to *Session) TrafficVaultPing(opts RequestOptions) (tc.TrafficVaultPingRes
 	reqInf, err := to.get(apiVaultPing, opts, &data)
 	return data, reqInf, err
 }
+
+// GetTrafficVaultSecretVersions returns the versions that Traffic Vault retains of the secret of
+// the given type (e.g. "urlsigkeys") that belongs to the Delivery Service or CDN with the given
+// name.
+func (to *Session) GetTrafficVaultSecretVersions(secretType, name string, opts RequestOptions) (tc.TrafficVaultSecretVersionsResponse, toclientlib.ReqInf, error) {
+	route := fmt.Sprintf(apiVaultSecretVersions, url.PathEscape(secretType), url.PathEscape(name))
+	var data tc.TrafficVaultSecretVersionsResponse
+	reqInf, err := to.get(route, opts, &data)
+	return data, reqInf, err
+}
+
+// PromoteTrafficVaultSecretVersion makes the given retained version of the secret of the given
+// type that belongs to the Delivery Service or CDN with the given name its current value.
+func (to *Session) PromoteTrafficVaultSecretVersion(secretType, name string, version int64, opts RequestOptions) (tc.Alerts, toclientlib.ReqInf, error) {
+	route := fmt.Sprintf(apiVaultSecretVersionPromote, url.PathEscape(secretType), url.PathEscape(name), version)
+	var alerts tc.Alerts
+	reqInf, err := to.post(route, opts, nil, &alerts)
+	return alerts, reqInf, err
+}
+
+// RollbackTrafficVaultSecret makes the version preceding the current one the current value of
+// the secret of the given type that belongs to the Delivery Service or CDN with the given name.
+func (to *Session) RollbackTrafficVaultSecret(secretType, name string, opts RequestOptions) (tc.TrafficVaultSecretRollbackResponse, toclientlib.ReqInf, error) {
+	route := fmt.Sprintf(apiVaultSecretRollback, url.PathEscape(secretType), url.PathEscape(name))
+	var data tc.TrafficVaultSecretRollbackResponse
+	reqInf, err := to.post(route, opts, nil, &data)
+	return data, reqInf, err
+}
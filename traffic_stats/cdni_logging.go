@@ -0,0 +1,571 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/v8/lib/go-rfc"
+
+	"github.com/google/uuid"
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+const (
+	defaultCDNILoggingPeriod    = 300
+	defaultCDNILoggingRetention = 168
+
+	// cdniLogRecordType is the type of the records of the CDNI Logging files.
+	// Traffic Stats only has the stats Traffic Monitor aggregates, not the
+	// individual requests the cdni_http_request_v1 records of RFC 7937
+	// describe, so the records summarize the traffic of a Delivery Service in
+	// a period instead.
+	cdniLogRecordType = "trafficcontrol_ds_summary_v1"
+	cdniLogMediaType  = "application/cdni; ptype=logging-file"
+	cdniLogFileExt    = ".log"
+	cdniLogTimeFormat = "20060102T150405Z"
+	cdniLogPathPrefix = "/cdni/logging/"
+	cdniLogFeedName   = "feed"
+)
+
+// cdniLogStats are the Delivery Service stats that are summarized in CDNI
+// Logging files.
+var cdniLogStats = []string{"out_bytes", "status_2xx", "status_3xx", "status_4xx", "status_5xx"}
+
+// cdniLogFields are the names of the fields of the CDNI Logging records, in
+// the order of their values. The fields that aren't defined by RFC 7937 have
+// the "x-tc-" prefix.
+var cdniLogFields = []string{"date", "time", "x-tc-period-seconds", "s-ccid", "sc-total-bytes", "x-tc-responses-2xx", "x-tc-responses-3xx", "x-tc-responses-4xx", "x-tc-responses-5xx"}
+
+// CDNILoggingConfig configures the export of Delivery Service stats as CDNI
+// Logging (RFC 7937) files for each uCDN.
+type CDNILoggingConfig struct {
+	Enable        bool   `json:"enable"`
+	Directory     string `json:"directory"`
+	ListenAddress string `json:"listenAddress"`
+	BaseURL       string `json:"baseUrl"`
+	ClaimedOrigin string `json:"claimedOrigin"`
+	// JWTSecret is the secret with which the bearer tokens of the uCDNs are
+	// signed, which is the first of the secrets of Traffic Ops.
+	JWTSecret string `json:"jwtSecret"`
+	// DCDNID is the ID of this CDN, which must be the audience of the
+	// bearer tokens of the uCDNs.
+	DCDNID string `json:"dcdnId"`
+	// PeriodSeconds is the length of the period covered by each file.
+	PeriodSeconds int `json:"periodSeconds"`
+	// RetentionHours is how long files are kept.
+	RetentionHours int `json:"retentionHours"`
+	// DeliveryServices maps the XMLIDs of the logged Delivery Services to
+	// the names of their uCDNs.
+	DeliveryServices map[string]string `json:"deliveryServices"`
+}
+
+// CDNILogger is a DataExporter that writes CDNI Logging files of the stats of
+// the Delivery Services of each uCDN, and serves them as an ATOM feed.
+type CDNILogger struct {
+	mutex sync.Mutex
+	// last holds the last value of each cumulative stat of each Delivery Service.
+	last map[string]map[string]float64
+	// periods holds the summaries of each period that hasn't been written
+	// yet, by the Unix time of its start.
+	periods map[int64]map[string]*cdniLogSummary
+	config  CDNILoggingConfig
+}
+
+// cdniLogSummary holds the increase of each stat of a Delivery Service in a
+// period, in the order of cdniLogStats.
+type cdniLogSummary [5]float64
+
+// cdniLogFile is a CDNI Logging file of a uCDN.
+type cdniLogFile struct {
+	Name    string
+	UUID    string
+	Updated time.Time
+}
+
+func newCDNILogger(config CDNILoggingConfig) *CDNILogger {
+	return &CDNILogger{
+		last:    map[string]map[string]float64{},
+		periods: map[int64]map[string]*cdniLogSummary{},
+		config:  config,
+	}
+}
+
+// validateCDNILoggingConfig sets the defaults of the CDNI Logging config and
+// checks that it can be used.
+func validateCDNILoggingConfig(config *CDNILoggingConfig) error {
+	if !config.Enable {
+		return nil
+	}
+	if config.Directory == "" {
+		return errors.New("cdniLogging.directory is required when CDNI logging is enabled")
+	}
+	if config.PeriodSeconds <= 0 {
+		config.PeriodSeconds = defaultCDNILoggingPeriod
+	}
+	if config.RetentionHours <= 0 {
+		config.RetentionHours = defaultCDNILoggingRetention
+	}
+	if config.ListenAddress != "" && (config.JWTSecret == "" || config.DCDNID == "") {
+		return errors.New("cdniLogging.jwtSecret and cdniLogging.dcdnId are required to serve CDNI logging files")
+	}
+	for ds, ucdn := range config.DeliveryServices {
+		if ucdn == "" || ucdn == "." || ucdn == ".." || strings.ContainsAny(ucdn, `/\`) {
+			return fmt.Errorf("cdniLogging.deliveryServices: invalid uCDN name '%s' for Delivery Service '%s'", ucdn, ds)
+		}
+	}
+	return nil
+}
+
+// ExportData adds the Delivery Service stats to the summaries of their
+// periods, and writes the files of the periods that have ended.
+func (l *CDNILogger) ExportData(config StartupConfig, bps influx.BatchPoints, retry bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.config = config.CDNILoggingConfig
+	if !l.config.Enable {
+		return
+	}
+	if bps.Database() == "deliveryservice_stats" {
+		l.addPoints(bps.Points())
+	}
+	grace := time.Duration(2*config.PublishingInterval) * time.Second
+	l.writeEndedPeriods(time.Now().Add(-grace))
+	if err := l.removeExpiredFiles(time.Now()); err != nil {
+		errorf("removing expired CDNI logging files: %v", err)
+	}
+}
+
+// addPoints adds the increases of the cumulative stats of the logged Delivery
+// Services to the summaries of the periods in which they were sampled.
+func (l *CDNILogger) addPoints(points []*influx.Point) {
+	period := int64(l.config.PeriodSeconds)
+	for _, pt := range points {
+		tags := pt.Tags()
+		ds := tags["deliveryservice"]
+		if tags["cachegroup"] != "total" {
+			continue
+		}
+		if _, ok := l.config.DeliveryServices[ds]; !ok {
+			continue
+		}
+		statIndex := -1
+		for i, stat := range cdniLogStats {
+			if pt.Name() == stat {
+				statIndex = i
+			}
+		}
+		if statIndex < 0 {
+			continue
+		}
+		fields, err := pt.Fields()
+		if err != nil {
+			continue
+		}
+		value, ok := fields["value"].(float64)
+		if !ok {
+			continue
+		}
+
+		if l.last[ds] == nil {
+			l.last[ds] = map[string]float64{}
+		}
+		last, seen := l.last[ds][pt.Name()]
+		l.last[ds][pt.Name()] = value
+		if !seen {
+			continue
+		}
+		increase := value - last
+		if increase < 0 {
+			// the counter was reset, e.g. by a restart of the cache servers
+			increase = value
+		}
+
+		start := pt.Time().Unix() - pt.Time().Unix()%period
+		if l.periods[start] == nil {
+			l.periods[start] = map[string]*cdniLogSummary{}
+		}
+		if l.periods[start][ds] == nil {
+			l.periods[start][ds] = &cdniLogSummary{}
+		}
+		l.periods[start][ds][statIndex] += increase
+	}
+}
+
+// writeEndedPeriods writes a file for each uCDN for each period that ended
+// before the given time. Periods are only written once, even if writing fails.
+func (l *CDNILogger) writeEndedPeriods(before time.Time) {
+	period := int64(l.config.PeriodSeconds)
+	for start, summaries := range l.periods {
+		if start+period > before.Unix() {
+			continue
+		}
+		byUCDN := map[string]map[string]*cdniLogSummary{}
+		for ds, summary := range summaries {
+			ucdn, ok := l.config.DeliveryServices[ds]
+			if !ok {
+				continue
+			}
+			if byUCDN[ucdn] == nil {
+				byUCDN[ucdn] = map[string]*cdniLogSummary{}
+			}
+			byUCDN[ucdn][ds] = summary
+		}
+		for ucdn, ucdnSummaries := range byUCDN {
+			if err := l.writeFile(ucdn, time.Unix(start, 0), ucdnSummaries); err != nil {
+				errorf("writing CDNI logging file of uCDN %s: %v", ucdn, err)
+			}
+		}
+		delete(l.periods, start)
+	}
+}
+
+func (l *CDNILogger) writeFile(ucdn string, start time.Time, summaries map[string]*cdniLogSummary) error {
+	dir := filepath.Join(l.config.Directory, ucdn)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	id := uuid.New().String()
+
+	name := start.UTC().Format(cdniLogTimeFormat) + "_" + id + cdniLogFileExt
+	tmp := filepath.Join(dir, "."+name)
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+	err = writeCDNILogFile(file, id, l.config.ClaimedOrigin, start, l.config.PeriodSeconds, summaries)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing file: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("renaming file: %w", err)
+	}
+	infof("wrote CDNI logging file %s for uCDN %s", name, ucdn)
+	return nil
+}
+
+// writeCDNILogFile writes a CDNI Logging file, in the format defined by RFC
+// 7937, with a record of the traffic of each of the given Delivery Services in
+// the period that starts at the given time. Each record has the start of the
+// period, its length, the Delivery Service, the bytes it sent to clients, and
+// its number of responses of each status class. Delivery Services without
+// traffic in the period have no record.
+func writeCDNILogFile(w io.Writer, id string, claimedOrigin string, start time.Time, periodSeconds int, summaries map[string]*cdniLogSummary) error {
+	hash := sha256.New()
+	buf := bufio.NewWriter(io.MultiWriter(w, hash))
+	buf.WriteString("#version:\tcdni/1.0\n")
+	buf.WriteString("#UUID:\turn:uuid:" + id + "\n")
+	if claimedOrigin != "" {
+		buf.WriteString("#claimed-origin:\t" + claimedOrigin + "\n")
+	}
+	buf.WriteString("#record-type:\t" + cdniLogRecordType + "\n")
+	buf.WriteString("#fields:\t" + strings.Join(cdniLogFields, "\t") + "\n")
+
+	dses := make([]string, 0, len(summaries))
+	for ds := range summaries {
+		dses = append(dses, ds)
+	}
+	sort.Strings(dses)
+	start = start.UTC()
+	for _, ds := range dses {
+		summary := summaries[ds]
+		if *summary == (cdniLogSummary{}) {
+			continue
+		}
+		record := []string{start.Format("2006-01-02"), start.Format("15:04:05"), strconv.Itoa(periodSeconds), strconv.Quote(ds)}
+		for _, value := range summary {
+			record = append(record, strconv.FormatUint(uint64(value), 10))
+		}
+		buf.WriteString(strings.Join(record, "\t") + "\n")
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "#SHA256-hash:\t"+hex.EncodeToString(hash.Sum(nil))+"\n")
+	return err
+}
+
+// listCDNILogFiles returns the CDNI Logging files of the given uCDN, newest
+// first.
+func listCDNILogFiles(directory string, ucdn string) ([]cdniLogFile, error) {
+	entries, err := os.ReadDir(filepath.Join(directory, ucdn))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []cdniLogFile{}, nil
+		}
+		return nil, err
+	}
+	files := []cdniLogFile{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, cdniLogFileExt) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSuffix(name, cdniLogFileExt), "_", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, cdniLogFile{Name: name, UUID: parts[1], Updated: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name > files[j].Name })
+	return files, nil
+}
+
+func (l *CDNILogger) removeExpiredFiles(now time.Time) error {
+	ucdns := map[string]struct{}{}
+	for _, ucdn := range l.config.DeliveryServices {
+		ucdns[ucdn] = struct{}{}
+	}
+	expiry := now.Add(-time.Duration(l.config.RetentionHours) * time.Hour)
+	for ucdn := range ucdns {
+		files, err := listCDNILogFiles(l.config.Directory, ucdn)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if file.Updated.Before(expiry) {
+				if err := os.Remove(filepath.Join(l.config.Directory, ucdn, file.Name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Src  string `xml:"src,attr"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Content atomContent `xml:"content"`
+}
+
+// cdniLogFeed returns the CDNI Logging feed, as defined by RFC 7937, of the
+// given files of a uCDN. Links are relative to the given base URL.
+func cdniLogFeed(ucdn string, author string, baseURL string, files []cdniLogFile) atomFeed {
+	base := strings.TrimSuffix(baseURL, "/") + cdniLogPathPrefix + ucdn + "/"
+	if author == "" {
+		author = UserAgent
+	}
+	feed := atomFeed{
+		ID:      "urn:uuid:" + uuid.NewSHA1(uuid.NameSpaceURL, []byte(base)).String(),
+		Title:   "CDNI Logging Files for " + ucdn,
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: author},
+		Link:    atomLink{Rel: "self", Href: base + cdniLogFeedName},
+		Entries: []atomEntry{},
+	}
+	for i, file := range files {
+		updated := file.Updated.UTC().Format(time.RFC3339)
+		if i == 0 {
+			feed.Updated = updated
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "urn:uuid:" + file.UUID,
+			Title:   file.Name,
+			Updated: updated,
+			Link:    atomLink{Rel: "alternate", Type: cdniLogMediaType, Href: base + file.Name},
+			Content: atomContent{Type: cdniLogMediaType, Src: base + file.Name},
+		})
+	}
+	return feed
+}
+
+// ServeHTTP serves the feed of each uCDN at /cdni/logging/{{ucdn}}/feed, and
+// its files at /cdni/logging/{{ucdn}}/{{file}}.
+func (l *CDNILogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mutex.Lock()
+	config := l.config
+	l.mutex.Unlock()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	tokenUCDN, err := checkCDNIBearerToken(getCDNIBearerToken(r), config)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, cdniLogPathPrefix), "/")
+	if !strings.HasPrefix(r.URL.Path, cdniLogPathPrefix) || len(parts) != 2 || !isLoggedUCDN(config, parts[0]) {
+		http.NotFound(w, r)
+		return
+	}
+	ucdn, name := parts[0], parts[1]
+	if ucdn != tokenUCDN {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	files, err := listCDNILogFiles(config.Directory, ucdn)
+	if err != nil {
+		errorf("listing CDNI logging files of uCDN %s: %v", ucdn, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if name == cdniLogFeedName {
+		baseURL := config.BaseURL
+		if baseURL == "" {
+			scheme := "http"
+			if r.TLS != nil {
+				scheme = "https"
+			}
+			baseURL = scheme + "://" + r.Host
+		}
+		feed, err := xml.MarshalIndent(cdniLogFeed(ucdn, config.ClaimedOrigin, baseURL, files), "", "\t")
+		if err != nil {
+			errorf("encoding CDNI logging feed of uCDN %s: %v", ucdn, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/atom+xml")
+		w.Write([]byte(xml.Header))
+		w.Write(feed)
+		return
+	}
+
+	for _, file := range files {
+		if file.Name == name {
+			w.Header().Set("Content-Type", cdniLogMediaType)
+			http.ServeFile(w, r, filepath.Join(config.Directory, ucdn, name))
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// getCDNIBearerToken returns the bearer token of the request, from its
+// Authorization header or its access_token cookie, as Traffic Ops reads the
+// tokens of uCDNs.
+func getCDNIBearerToken(r *http.Request) string {
+	if authorization := r.Header.Get(rfc.Authorization); strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	}
+	if cookie, err := r.Cookie(rfc.AccessToken); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// checkCDNIBearerToken returns the uCDN of the given bearer token, after
+// checking it as Traffic Ops checks the tokens of its CDNI endpoints: it must
+// be signed with the secret of Traffic Ops, unexpired, for this dCDN, and
+// issued by a uCDN.
+func checkCDNIBearerToken(bearerToken string, config CDNILoggingConfig) (string, error) {
+	if bearerToken == "" {
+		return "", errors.New("bearer token is required")
+	}
+	token, err := jwt.Parse([]byte(bearerToken), jwt.WithVerify(jwa.HS256, []byte(config.JWTSecret)))
+	if err != nil {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
+	if token.Expiration().Unix() < time.Now().Unix() {
+		return "", errors.New("token is expired")
+	}
+	if len(token.Audience()) == 0 || token.Audience()[0] != config.DCDNID {
+		return "", errors.New("invalid token - incorrect dcdn")
+	}
+	if token.Issuer() == "" {
+		return "", errors.New("invalid token - empty ucdn field")
+	}
+	return token.Issuer(), nil
+}
+
+func isLoggedUCDN(config CDNILoggingConfig, ucdn string) bool {
+	for _, loggedUCDN := range config.DeliveryServices {
+		if loggedUCDN == ucdn {
+			return true
+		}
+	}
+	return false
+}
+
+// serveCDNILogs serves the CDNI Logging feeds until the server fails.
+func serveCDNILogs(config CDNILoggingConfig, logger *CDNILogger) {
+	if config.ListenAddress == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle(cdniLogPathPrefix, logger)
+	server := &http.Server{
+		Addr:              config.ListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	infof("serving CDNI logging feeds on %s", config.ListenAddress)
+	if err := server.ListenAndServe(); err != nil {
+		errorf("serving CDNI logging feeds: %v", err)
+	}
+}
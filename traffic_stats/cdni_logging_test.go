@@ -0,0 +1,228 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+func newDSPoint(t *testing.T, ds string, cachegroup string, stat string, value float64, sampleTime time.Time) *influx.Point {
+	pt, err := influx.NewPoint(stat, map[string]string{"deliveryservice": ds, "cachegroup": cachegroup, "cdn": "cdn"}, map[string]interface{}{"value": value}, sampleTime)
+	if err != nil {
+		t.Fatalf("creating point: %v", err)
+	}
+	return pt
+}
+
+func TestCDNILoggerExportData(t *testing.T) {
+	cfg := CDNILoggingConfig{
+		Enable:           true,
+		Directory:        t.TempDir(),
+		ClaimedOrigin:    "dcdn.example.com",
+		DeliveryServices: map[string]string{"ds1": "ucdn1", "ds2": "ucdn1", "ds3": "ucdn2"},
+	}
+	if err := validateCDNILoggingConfig(&cfg); err != nil {
+		t.Fatalf("validating config: %v", err)
+	}
+	if cfg.PeriodSeconds != defaultCDNILoggingPeriod || cfg.RetentionHours != defaultCDNILoggingRetention {
+		t.Errorf("expected default period and retention, got %d and %d", cfg.PeriodSeconds, cfg.RetentionHours)
+	}
+	logger := newCDNILogger(cfg)
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Duration(cfg.PeriodSeconds) * time.Second)
+	bps, _ := influx.NewBatchPoints(influx.BatchPointsConfig{Database: "deliveryservice_stats"})
+	bps.AddPoints([]*influx.Point{
+		newDSPoint(t, "ds1", "total", "out_bytes", 1000, start.Add(time.Second)),
+		newDSPoint(t, "ds1", "total", "out_bytes", 1500, start.Add(10*time.Second)),
+		newDSPoint(t, "ds1", "total", "out_bytes", 200, start.Add(20*time.Second)),
+		newDSPoint(t, "ds1", "total", "status_2xx", 10, start.Add(time.Second)),
+		newDSPoint(t, "ds1", "total", "status_2xx", 14, start.Add(10*time.Second)),
+		newDSPoint(t, "ds1", "cg1", "out_bytes", 9000, start.Add(time.Second)),
+		newDSPoint(t, "ds1", "cg1", "out_bytes", 9900, start.Add(10*time.Second)),
+		newDSPoint(t, "ds4", "total", "out_bytes", 1, start.Add(time.Second)),
+		newDSPoint(t, "ds4", "total", "out_bytes", 2, start.Add(10*time.Second)),
+	})
+	logger.ExportData(StartupConfig{PublishingInterval: 30, CDNILoggingConfig: cfg}, bps, false)
+
+	files, err := listCDNILogFiles(cfg.Directory, "ucdn1")
+	if err != nil {
+		t.Fatalf("listing files: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected one file for ucdn1, got %+v", files)
+	}
+	if files, _ := listCDNILogFiles(cfg.Directory, "ucdn2"); len(files) != 0 {
+		t.Errorf("expected no files for a uCDN without stats, got %+v", files)
+	}
+
+	data, err := os.ReadFile(cfg.Directory + "/ucdn1/" + files[0].Name)
+	if err != nil {
+		t.Fatalf("reading file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("expected 5 directives, 1 record and the hash, got %q", lines)
+	}
+	if lines[0] != "#version:\tcdni/1.0" || lines[1] != "#UUID:\turn:uuid:"+files[0].UUID || lines[2] != "#claimed-origin:\tdcdn.example.com" || lines[3] != "#record-type:\ttrafficcontrol_ds_summary_v1" {
+		t.Errorf("unexpected directives %q", lines[:5])
+	}
+	if lines[4] != "#fields:\tdate\ttime\tx-tc-period-seconds\ts-ccid\tsc-total-bytes\tx-tc-responses-2xx\tx-tc-responses-3xx\tx-tc-responses-4xx\tx-tc-responses-5xx" {
+		t.Errorf("unexpected fields directive %q", lines[4])
+	}
+	utcStart := start.UTC()
+	expected := []string{utcStart.Format("2006-01-02"), utcStart.Format("15:04:05"), "300", `"ds1"`, "700", "4", "0", "0", "0"}
+	if record := strings.Split(lines[5], "\t"); !reflect.DeepEqual(expected, record) {
+		t.Errorf("expected record %q, got %q", expected, record)
+	}
+	hash := sha256.Sum256([]byte(strings.Join(lines[:6], "\n") + "\n"))
+	if lines[6] != "#SHA256-hash:\t"+hex.EncodeToString(hash[:]) {
+		t.Errorf("expected a hash of the rest of the file, got %q", lines[6])
+	}
+}
+
+func TestWriteCDNILogFile(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	buf := &bytes.Buffer{}
+	summaries := map[string]*cdniLogSummary{
+		"ds2": {10, 1, 1, 0, 1},
+		"ds1": {5, 0, 0, 1, 0},
+		"ds3": {0, 0, 0, 0, 0},
+	}
+	if err := writeCDNILogFile(buf, "id", "", start, 60, summaries); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+	records := [][]string{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		records = append(records, strings.Split(line, "\t"))
+	}
+	expected := [][]string{
+		{"2020-01-02", "03:04:05", "60", `"ds1"`, "5", "0", "0", "1", "0"},
+		{"2020-01-02", "03:04:05", "60", `"ds2"`, "10", "1", "1", "0", "1"},
+	}
+	if !reflect.DeepEqual(expected, records) {
+		t.Errorf("expected records %q, got %q", expected, records)
+	}
+}
+
+// newCDNIToken returns a bearer token of the given uCDN for the given dCDN,
+// signed with the given secret, which expires after the given duration.
+func newCDNIToken(t *testing.T, secret string, ucdn string, dcdn string, expiresIn time.Duration) string {
+	token := jwt.New()
+	token.Set(jwt.IssuerKey, ucdn)
+	token.Set(jwt.AudienceKey, dcdn)
+	token.Set(jwt.ExpirationKey, time.Now().Add(expiresIn).Unix())
+	signed, err := jwt.Sign(token, jwa.HS256, []byte(secret))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return string(signed)
+}
+
+func TestCDNILoggerServeHTTP(t *testing.T) {
+	cfg := CDNILoggingConfig{
+		Enable:           true,
+		Directory:        t.TempDir(),
+		ListenAddress:    ":0",
+		BaseURL:          "https://ts.example.com/",
+		JWTSecret:        "secret",
+		DCDNID:           "dcdn",
+		DeliveryServices: map[string]string{"ds1": "ucdn1", "ds2": "ucdn2"},
+	}
+	if err := validateCDNILoggingConfig(&cfg); err != nil {
+		t.Fatalf("validating config: %v", err)
+	}
+	logger := newCDNILogger(cfg)
+	if err := logger.writeFile("ucdn1", time.Unix(0, 0), map[string]*cdniLogSummary{"ds1": {1, 2, 3, 4, 5}}); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+
+	authorization := "Bearer " + newCDNIToken(t, cfg.JWTSecret, "ucdn1", cfg.DCDNID, time.Hour)
+	get := func(path string, authorization string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		logger.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/cdni/logging/ucdn1/feed", authorization)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected feed status 200, got %d", w.Code)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("decoding feed: %v", err)
+	}
+	if len(feed.Entries) != 1 {
+		t.Fatalf("expected one feed entry, got %+v", feed.Entries)
+	}
+	href := feed.Entries[0].Content.Src
+	if !strings.HasPrefix(href, "https://ts.example.com/cdni/logging/ucdn1/19700101T000000Z_") {
+		t.Errorf("unexpected file link %s", href)
+	}
+
+	w = get(strings.TrimPrefix(href, "https://ts.example.com"), authorization)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "#version:\tcdni/1.0") {
+		t.Errorf("expected the file, got status %d and body %q", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{"/cdni/logging/ucdn3/feed", "/cdni/logging/ucdn1/missing.log", "/cdni/logging/ucdn1/../ucdn1/feed"} {
+		if w = get(path, authorization); w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 for %s, got %d", path, w.Code)
+		}
+	}
+	if w = get("/cdni/logging/ucdn2/feed", authorization); w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for the feed of another uCDN, got %d", w.Code)
+	}
+	for name, authorization := range map[string]string{
+		"no token":      "",
+		"wrong secret":  "Bearer " + newCDNIToken(t, "other", "ucdn1", cfg.DCDNID, time.Hour),
+		"wrong dcdn":    "Bearer " + newCDNIToken(t, cfg.JWTSecret, "ucdn1", "other", time.Hour),
+		"expired token": "Bearer " + newCDNIToken(t, cfg.JWTSecret, "ucdn1", cfg.DCDNID, -time.Hour),
+	} {
+		if w = get("/cdni/logging/ucdn1/feed", authorization); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401 with %s, got %d", name, w.Code)
+		}
+	}
+
+	cfg.JWTSecret = ""
+	if err := validateCDNILoggingConfig(&cfg); err == nil {
+		t.Error("expected an error serving files without a JWT secret")
+	}
+}
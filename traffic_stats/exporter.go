@@ -0,0 +1,433 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+)
+
+const (
+	defaultExporterMaxBatchSize  = 10000
+	defaultExporterMaxRetries    = 3
+	defaultExporterRetryInterval = 5
+	defaultExporterQueueSize     = 100
+	defaultExporterTimeout       = 10
+)
+
+// metricNamePrefix is the prefix of the names of the metrics sent by the
+// exporters in the registry.
+const metricNamePrefix = "trafficstats_"
+
+// metricDatabaseNames are the names that the metrics of each InfluxDB database
+// are grouped under by the exporters in the registry.
+var metricDatabaseNames = map[string]string{
+	"cache_stats":           "cache",
+	"deliveryservice_stats": "deliveryservice",
+	"daily_stats":           "daily",
+}
+
+// Metric is a single sample of a stat, independent of the InfluxDB schema
+// that the stats are collected in.
+type Metric struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	Time   time.Time
+}
+
+// MetricExporter sends metrics to a time-series database. Implementations
+// don't need to batch or retry, which is done for them by an exporterQueue.
+type MetricExporter interface {
+	Export(metrics []Metric) error
+}
+
+// ExporterFactory creates a DataExporter from the Traffic Stats configuration
+// and its own. The DataExporter may implement io.Closer, to release its
+// resources when it's replaced or Traffic Stats stops.
+type ExporterFactory func(config StartupConfig, exporterConfig ExporterConfig) (DataExporter, error)
+
+// MetricExporterFactory creates a MetricExporter from the options of its
+// configuration.
+type MetricExporterFactory func(options json.RawMessage) (MetricExporter, error)
+
+// exporterFactories are the registered types of exporters, by name.
+var exporterFactories = map[string]ExporterFactory{}
+
+// registerExporter registers a type of exporter, so it can be used by the
+// exporters configuration. It must be called from an init function.
+func registerExporter(exporterType string, factory ExporterFactory) {
+	if _, ok := exporterFactories[exporterType]; ok {
+		panic("exporter type '" + exporterType + "' registered twice")
+	}
+	exporterFactories[exporterType] = factory
+}
+
+// registerMetricExporter registers a type of exporter which sends Metrics, and
+// is batched and retried by an exporterQueue. It must be called from an init
+// function.
+func registerMetricExporter(exporterType string, factory MetricExporterFactory) {
+	registerExporter(exporterType, func(_ StartupConfig, exporterConfig ExporterConfig) (DataExporter, error) {
+		exporter, err := factory(exporterConfig.Options)
+		if err != nil {
+			return nil, err
+		}
+		q := newExporterQueue(exporterConfig, exporter)
+		go q.run()
+		return q, nil
+	})
+}
+
+// ExporterConfig is the configuration of an exporter in the registry.
+type ExporterConfig struct {
+	// Name identifies the exporter in logs. Defaults to its Type.
+	Name string `json:"name"`
+	// Type is the registered type of the exporter.
+	Type   string `json:"type"`
+	Enable bool   `json:"enable"`
+	// MaxBatchSize is the maximum number of metrics sent in one request.
+	MaxBatchSize int `json:"maxBatchSize"`
+	// MaxRetries is the number of times a failed batch is retried before
+	// it's dropped.
+	MaxRetries int `json:"maxRetries"`
+	// RetryIntervalSeconds is the time before the first retry of a failed
+	// batch, which doubles with each retry.
+	RetryIntervalSeconds int `json:"retryIntervalSeconds"`
+	// QueueSize is the number of sets of stats that can wait to be exported
+	// before new ones are dropped.
+	QueueSize int `json:"queueSize"`
+	// Options are specific to the Type of the exporter.
+	Options json.RawMessage `json:"options"`
+}
+
+// validateExporterConfigs checks that the types of the enabled exporters are
+// registered, and sets the defaults of their options.
+func validateExporterConfigs(configs []ExporterConfig) error {
+	names := map[string]struct{}{}
+	for i := range configs {
+		cfg := &configs[i]
+		if !cfg.Enable {
+			continue
+		}
+		if _, ok := exporterFactories[cfg.Type]; !ok {
+			return fmt.Errorf("exporters[%d]: unknown exporter type '%s'", i, cfg.Type)
+		}
+		if cfg.Name == "" {
+			cfg.Name = cfg.Type
+		}
+		if _, ok := names[cfg.Name]; ok {
+			return fmt.Errorf("exporters[%d]: duplicate exporter name '%s'", i, cfg.Name)
+		}
+		names[cfg.Name] = struct{}{}
+		if cfg.MaxBatchSize <= 0 {
+			cfg.MaxBatchSize = defaultExporterMaxBatchSize
+		}
+		if cfg.MaxRetries < 0 {
+			cfg.MaxRetries = 0
+		} else if cfg.MaxRetries == 0 {
+			cfg.MaxRetries = defaultExporterMaxRetries
+		}
+		if cfg.RetryIntervalSeconds <= 0 {
+			cfg.RetryIntervalSeconds = defaultExporterRetryInterval
+		}
+		if cfg.QueueSize <= 0 {
+			cfg.QueueSize = defaultExporterQueueSize
+		}
+	}
+	return nil
+}
+
+// newExporters creates the enabled exporters of the registry, and starts
+// their queues. Exporters that can't be created are logged and skipped, so
+// they don't stop the others.
+func newExporters(config StartupConfig) []DataExporter {
+	exporters := []DataExporter{}
+	for _, cfg := range config.Exporters {
+		if !cfg.Enable {
+			continue
+		}
+		exporter, err := exporterFactories[cfg.Type](config, cfg)
+		if err != nil {
+			errorf("creating exporter '%s': %v", cfg.Name, err)
+			continue
+		}
+		exporters = append(exporters, exporter)
+		infof("Started exporter '%s' of type '%s'", cfg.Name, cfg.Type)
+	}
+	return exporters
+}
+
+// closeExporters closes the given exporters that hold resources. Stats
+// already queued by an exporter are still sent.
+func closeExporters(exporters []DataExporter) {
+	for _, exporter := range exporters {
+		closer, ok := exporter.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			errorf("closing exporter: %v", err)
+		}
+	}
+}
+
+// exporterQueue is a DataExporter that batches the stats for a MetricExporter,
+// and retries failed batches, independently of the other exporters.
+type exporterQueue struct {
+	config   ExporterConfig
+	exporter MetricExporter
+	queue    chan []Metric
+	// sleep is replaced by tests.
+	sleep func(time.Duration)
+	// mutex guards closing the queue, which may happen while stats are
+	// being queued.
+	mutex  sync.RWMutex
+	closed bool
+}
+
+func newExporterQueue(config ExporterConfig, exporter MetricExporter) *exporterQueue {
+	return &exporterQueue{
+		config:   config,
+		exporter: exporter,
+		queue:    make(chan []Metric, config.QueueSize),
+		sleep:    time.Sleep,
+	}
+}
+
+// ExportData queues the stats to be exported. If retry is false, which is the
+// case on shutdown, the stats are instead exported immediately, without
+// retries.
+func (q *exporterQueue) ExportData(config StartupConfig, bps influx.BatchPoints, retry bool) {
+	metrics := metricsFromBatchPoints(bps)
+	if len(metrics) == 0 {
+		return
+	}
+	if !retry {
+		for _, batch := range q.batches(metrics) {
+			if err := q.exporter.Export(batch); err != nil {
+				errorf("exporter '%s': dropping %d metrics: %v", q.config.Name, len(batch), err)
+			}
+		}
+		return
+	}
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	if q.closed {
+		errorf("exporter '%s': closed, dropping %d metrics", q.config.Name, len(metrics))
+		return
+	}
+	select {
+	case q.queue <- metrics:
+	default:
+		errorf("exporter '%s': queue full, dropping %d metrics", q.config.Name, len(metrics))
+	}
+}
+
+// Close stops the queue from accepting stats. The stats already queued are
+// still sent.
+func (q *exporterQueue) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	return nil
+}
+
+func (q *exporterQueue) run() {
+	for metrics := range q.queue {
+		for _, batch := range q.batches(metrics) {
+			q.send(batch)
+		}
+	}
+}
+
+func (q *exporterQueue) batches(metrics []Metric) [][]Metric {
+	batches := make([][]Metric, 0, len(metrics)/q.config.MaxBatchSize+1)
+	for len(metrics) > 0 {
+		n := intMin(q.config.MaxBatchSize, len(metrics))
+		batches = append(batches, metrics[:n])
+		metrics = metrics[n:]
+	}
+	return batches
+}
+
+// send exports a batch, retrying it with an exponential backoff unless the
+// error is permanent.
+func (q *exporterQueue) send(batch []Metric) {
+	interval := time.Duration(q.config.RetryIntervalSeconds) * time.Second
+	for attempt := 0; ; attempt++ {
+		err := q.exporter.Export(batch)
+		if err == nil {
+			debugf("exporter '%s': sent %d metrics", q.config.Name, len(batch))
+			return
+		}
+		permanent := permanentExportError{}
+		if errors.As(err, &permanent) || attempt >= q.config.MaxRetries {
+			errorf("exporter '%s': dropping %d metrics after %d attempts: %v", q.config.Name, len(batch), attempt+1, err)
+			return
+		}
+		warnf("exporter '%s': sending %d metrics, retrying in %v: %v", q.config.Name, len(batch), interval, err)
+		q.sleep(interval)
+		interval *= 2
+	}
+}
+
+// permanentExportError is an error exporting metrics that retrying won't fix,
+// e.g. the rejection of a malformed request.
+type permanentExportError struct {
+	error
+}
+
+// metricsFromBatchPoints converts InfluxDB points to Metrics. The name of each
+// Metric is made of its database and measurement, and its labels are the
+// point's tags. Points without a numeric "value" field are skipped.
+func metricsFromBatchPoints(bps influx.BatchPoints) []Metric {
+	prefix, ok := metricDatabaseNames[bps.Database()]
+	if !ok {
+		prefix = bps.Database()
+	}
+	prefix = metricNamePrefix + sanitizeMetricName(prefix) + "_"
+
+	metrics := make([]Metric, 0, len(bps.Points()))
+	for _, pt := range bps.Points() {
+		fields, err := pt.Fields()
+		if err != nil {
+			continue
+		}
+		var value float64
+		switch v := fields["value"].(type) {
+		case float64:
+			value = v
+		case int64:
+			value = float64(v)
+		case json.Number:
+			if value, err = v.Float64(); err != nil {
+				continue
+			}
+		default:
+			continue
+		}
+		labels := make(map[string]string, len(pt.Tags()))
+		for k, v := range pt.Tags() {
+			labels[sanitizeMetricName(k)] = v
+		}
+		metrics = append(metrics, Metric{
+			Name:   prefix + sanitizeMetricName(pt.Name()),
+			Labels: labels,
+			Value:  value,
+			Time:   pt.Time(),
+		})
+	}
+	return metrics
+}
+
+// sanitizeMetricName replaces the characters that aren't allowed in the names
+// of Prometheus metrics and labels with underscores.
+func sanitizeMetricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// sortedLabelNames returns the names of the given labels in order.
+func sortedLabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HTTPExporterOptions are the options common to exporters that send metrics
+// over HTTP.
+type HTTPExporterOptions struct {
+	URL                string            `json:"url"`
+	Headers            map[string]string `json:"headers"`
+	TimeoutSeconds     int               `json:"timeoutSeconds"`
+	InsecureSkipVerify bool              `json:"insecureSkipVerify"`
+}
+
+// httpExporter sends request bodies built by the exporters that use HTTP.
+type httpExporter struct {
+	options HTTPExporterOptions
+	client  *http.Client
+}
+
+func newHTTPExporter(options HTTPExporterOptions) (httpExporter, error) {
+	if options.URL == "" {
+		return httpExporter{}, errors.New("url is required")
+	}
+	if options.TimeoutSeconds <= 0 {
+		options.TimeoutSeconds = defaultExporterTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: options.InsecureSkipVerify}
+	return httpExporter{
+		options: options,
+		client:  &http.Client{Timeout: time.Duration(options.TimeoutSeconds) * time.Second, Transport: transport},
+	}, nil
+}
+
+// post sends the body with the given headers. Client errors, other than 429
+// Too Many Requests, are permanent.
+func (e httpExporter) post(body []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, e.options.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentExportError{fmt.Errorf("creating request: %w", err)}
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range e.options.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("%s responded with status %d: %s", e.options.URL, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return permanentExportError{err}
+	}
+	return err
+}
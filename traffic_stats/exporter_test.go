@@ -0,0 +1,256 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	influx "github.com/influxdata/influxdb/client/v2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestMetricsFromBatchPoints(t *testing.T) {
+	sampleTime := time.Unix(1700000000, 0)
+	bps, _ := influx.NewBatchPoints(influx.BatchPointsConfig{Database: "cache_stats"})
+	pt, _ := influx.NewPoint("ats.proxy.process.http.current_client_connections", map[string]string{"hostname": "edge", "cdn": "cdn"}, map[string]interface{}{"value": 12.0}, sampleTime)
+	bps.AddPoint(pt)
+	pt, _ = influx.NewPoint("no_value", map[string]string{}, map[string]interface{}{"other": 1.0}, sampleTime)
+	bps.AddPoint(pt)
+
+	expected := []Metric{{
+		Name:   "trafficstats_cache_ats_proxy_process_http_current_client_connections",
+		Labels: map[string]string{"hostname": "edge", "cdn": "cdn"},
+		Value:  12,
+		Time:   sampleTime,
+	}}
+	if actual := metricsFromBatchPoints(bps); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestValidateExporterConfigs(t *testing.T) {
+	configs := []ExporterConfig{
+		{Type: otlpType, Enable: true, MaxRetries: -1},
+		{Type: "unknown"},
+	}
+	if err := validateExporterConfigs(configs); err != nil {
+		t.Fatalf("validating exporters: %v", err)
+	}
+	if configs[0].Name != otlpType || configs[0].MaxBatchSize != defaultExporterMaxBatchSize || configs[0].MaxRetries != 0 {
+		t.Errorf("expected defaults and no retries, got %+v", configs[0])
+	}
+
+	configs[1].Enable = true
+	if err := validateExporterConfigs(configs); err == nil {
+		t.Error("expected an error for an unknown exporter type")
+	}
+	configs = []ExporterConfig{{Type: otlpType, Enable: true}, {Type: otlpType, Enable: true}}
+	if err := validateExporterConfigs(configs); err == nil {
+		t.Error("expected an error for duplicate exporter names")
+	}
+}
+
+func TestAddLegacyExporters(t *testing.T) {
+	config := StartupConfig{KafkaConfig: KafkaConfig{Enable: true, Brokers: "kafka.invalid:9092", Topic: "stats"}}
+	if err := addLegacyExporters(&config); err != nil {
+		t.Fatalf("adding legacy exporters: %v", err)
+	}
+	if len(config.Exporters) != 2 || config.Exporters[0].Type != kafkaExporterType || config.Exporters[1].Type != influxDBExporterType {
+		t.Fatalf("expected kafka and influxdb exporters, got %+v", config.Exporters)
+	}
+	kafkaConfig := KafkaConfig{}
+	if err := json.Unmarshal(config.Exporters[0].Options, &kafkaConfig); err != nil || kafkaConfig.Topic != "stats" {
+		t.Errorf("expected the kafka exporter options to be kafkaConfig, got %s", config.Exporters[0].Options)
+	}
+
+	config = StartupConfig{DisableInflux: true, Exporters: []ExporterConfig{{Type: kafkaExporterType, Enable: false}}}
+	config.KafkaConfig.Enable = true
+	if err := addLegacyExporters(&config); err != nil {
+		t.Fatalf("adding legacy exporters: %v", err)
+	}
+	if len(config.Exporters) != 1 || config.Exporters[0].Enable {
+		t.Errorf("expected only the configured kafka exporter, got %+v", config.Exporters)
+	}
+	if err := validateExporterConfigs(config.Exporters); err != nil {
+		t.Errorf("expected the kafka exporter type to be registered: %v", err)
+	}
+}
+
+type fakeMetricExporter struct {
+	errs    []error
+	batches [][]Metric
+}
+
+func (e *fakeMetricExporter) Export(metrics []Metric) error {
+	e.batches = append(e.batches, metrics)
+	if len(e.errs) == 0 {
+		return nil
+	}
+	err := e.errs[0]
+	e.errs = e.errs[1:]
+	return err
+}
+
+func TestExporterQueueSend(t *testing.T) {
+	exporter := &fakeMetricExporter{errs: []error{errors.New("unavailable"), errors.New("unavailable")}}
+	q := newExporterQueue(ExporterConfig{Name: "test", MaxBatchSize: 2, MaxRetries: 3, RetryIntervalSeconds: 1, QueueSize: 1}, exporter)
+	sleeps := []time.Duration{}
+	q.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	metrics := []Metric{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	for _, batch := range q.batches(metrics) {
+		q.send(batch)
+	}
+	if len(exporter.batches) != 4 || len(exporter.batches[0]) != 2 || len(exporter.batches[3]) != 1 {
+		t.Errorf("expected the first batch of 2 to be sent 3 times and then a batch of 1, got %+v", exporter.batches)
+	}
+	if !reflect.DeepEqual(sleeps, []time.Duration{time.Second, 2 * time.Second}) {
+		t.Errorf("expected exponential backoff, got %v", sleeps)
+	}
+
+	exporter = &fakeMetricExporter{errs: []error{permanentExportError{errors.New("bad request")}}}
+	q.exporter = exporter
+	q.send(metrics)
+	if len(exporter.batches) != 1 {
+		t.Errorf("expected a permanent error not to be retried, got %d attempts", len(exporter.batches))
+	}
+}
+
+func TestPrometheusRemoteWriteExporter(t *testing.T) {
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		compressed, _ := io.ReadAll(r.Body)
+		body, _ = snappy.Decode(nil, compressed)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	exporter, err := newPrometheusRemoteWriteExporter(json.RawMessage(`{"url": "` + srv.URL + `", "bearerToken": "secret"}`))
+	if err != nil {
+		t.Fatalf("creating exporter: %v", err)
+	}
+	metrics := []Metric{
+		{Name: "m", Labels: map[string]string{"cdn": "cdn"}, Value: 2, Time: time.UnixMilli(2000)},
+		{Name: "m", Labels: map[string]string{"cdn": "cdn"}, Value: 1, Time: time.UnixMilli(1000)},
+	}
+	if err := exporter.Export(metrics); err != nil {
+		t.Fatalf("exporting: %v", err)
+	}
+	if header.Get("Content-Encoding") != "snappy" || header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("unexpected headers %v", header)
+	}
+
+	// The request has one time series, with the labels in order, and the
+	// samples in order of time.
+	num, typ, n := protowire.ConsumeTag(body)
+	if num != remoteWriteRequestTimeseries || typ != protowire.BytesType {
+		t.Fatalf("expected a time series, got field %d of type %d", num, typ)
+	}
+	ts, m := protowire.ConsumeBytes(body[n:])
+	if n+m != len(body) {
+		t.Fatalf("expected exactly one time series")
+	}
+	labels := [][2]string{}
+	values := []float64{}
+	times := []uint64{}
+	for len(ts) > 0 {
+		num, _, n := protowire.ConsumeTag(ts)
+		msg, m := protowire.ConsumeBytes(ts[n:])
+		ts = ts[n+m:]
+		switch num {
+		case remoteWriteSeriesLabels:
+			_, _, n := protowire.ConsumeTag(msg)
+			name, m := protowire.ConsumeString(msg[n:])
+			msg = msg[n+m:]
+			_, _, n = protowire.ConsumeTag(msg)
+			value, _ := protowire.ConsumeString(msg[n:])
+			labels = append(labels, [2]string{name, value})
+		case remoteWriteSeriesSamples:
+			_, _, n := protowire.ConsumeTag(msg)
+			value, m := protowire.ConsumeFixed64(msg[n:])
+			msg = msg[n+m:]
+			_, _, n = protowire.ConsumeTag(msg)
+			timestamp, _ := protowire.ConsumeVarint(msg[n:])
+			values = append(values, math.Float64frombits(value))
+			times = append(times, timestamp)
+		}
+	}
+	if !reflect.DeepEqual(labels, [][2]string{{"__name__", "m"}, {"cdn", "cdn"}}) {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if !reflect.DeepEqual(values, []float64{1, 2}) || !reflect.DeepEqual(times, []uint64{1000, 2000}) {
+		t.Errorf("unexpected samples %v at %v", values, times)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	status := http.StatusOK
+	var req otlpMetricsRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	exporter, err := newOTLPExporter(json.RawMessage(`{"url": "` + srv.URL + `", "resourceAttributes": {"deployment.environment": "test"}}`))
+	if err != nil {
+		t.Fatalf("creating exporter: %v", err)
+	}
+	metrics := []Metric{
+		{Name: "a", Labels: map[string]string{"cdn": "cdn"}, Value: 1.5, Time: time.Unix(0, 1)},
+		{Name: "b", Value: math.NaN()},
+		{Name: "a", Labels: map[string]string{"cdn": "other"}, Value: 3, Time: time.Unix(0, 2)},
+	}
+	if err := exporter.Export(metrics); err != nil {
+		t.Fatalf("exporting: %v", err)
+	}
+	if len(req.ResourceMetrics) != 1 || len(req.ResourceMetrics[0].Resource.Attributes) != 2 {
+		t.Fatalf("expected one resource with two attributes, got %+v", req.ResourceMetrics)
+	}
+	sent := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(sent) != 1 || sent[0].Name != "a" || len(sent[0].Gauge.DataPoints) != 2 {
+		t.Fatalf("expected metric 'a' with two data points, got %+v", sent)
+	}
+	if dp := sent[0].Gauge.DataPoints[0]; dp.TimeUnixNano != "1" || dp.AsDouble != 1.5 {
+		t.Errorf("unexpected data point %+v", dp)
+	}
+
+	status = http.StatusBadRequest
+	if err := exporter.Export(metrics); !errors.As(err, &permanentExportError{}) {
+		t.Errorf("expected a permanent error for status 400, got %v", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := exporter.Export(metrics); err == nil || errors.As(err, &permanentExportError{}) {
+		t.Errorf("expected a temporary error for status 503, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const otlpType = "otlp"
+
+// otlpScopeName is the name of the instrumentation scope of the exported
+// metrics, and the default service.name resource attribute.
+const otlpScopeName = "traffic_stats"
+
+func init() {
+	registerMetricExporter(otlpType, newOTLPExporter)
+}
+
+// OTLPOptions are the options of an otlp exporter.
+type OTLPOptions struct {
+	HTTPExporterOptions
+	// ResourceAttributes are added to the resource of the exported metrics.
+	ResourceAttributes map[string]string `json:"resourceAttributes"`
+}
+
+// otlpExporter sends metrics to an OpenTelemetry collector, or any other
+// OTLP/HTTP receiver, using the JSON encoding of OTLP.
+type otlpExporter struct {
+	httpExporter
+	resource otlpResource
+}
+
+// The types of the JSON encoding of an OTLP ExportMetricsServiceRequest.
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name  string    `json:"name"`
+	Gauge otlpGauge `json:"gauge"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	Attributes []otlpAttribute `json:"attributes,omitempty"`
+	// TimeUnixNano is a string, because the JSON encoding of OTLP encodes
+	// 64 bit integers as strings.
+	TimeUnixNano string  `json:"timeUnixNano"`
+	AsDouble     float64 `json:"asDouble"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func newOTLPExporter(rawOptions json.RawMessage) (MetricExporter, error) {
+	options := OTLPOptions{}
+	if err := json.Unmarshal(rawOptions, &options); err != nil {
+		return nil, fmt.Errorf("parsing options: %w", err)
+	}
+	e, err := newHTTPExporter(options.HTTPExporterOptions)
+	if err != nil {
+		return nil, err
+	}
+	attributes := map[string]string{"service.name": otlpScopeName}
+	for k, v := range options.ResourceAttributes {
+		attributes[k] = v
+	}
+	return &otlpExporter{httpExporter: e, resource: otlpResource{Attributes: otlpAttributes(attributes)}}, nil
+}
+
+func (e *otlpExporter) Export(metrics []Metric) error {
+	body, err := json.Marshal(buildOTLPMetricsRequest(e.resource, metrics))
+	if err != nil {
+		return permanentExportError{fmt.Errorf("encoding metrics: %w", err)}
+	}
+	return e.post(body, map[string]string{"Content-Type": "application/json"})
+}
+
+// buildOTLPMetricsRequest groups the metrics by name. All metrics are gauges,
+// because the stats of Traffic Monitor don't say which are cumulative.
+func buildOTLPMetricsRequest(resource otlpResource, metrics []Metric) otlpMetricsRequest {
+	byName := map[string]int{}
+	otlpMetrics := []otlpMetric{}
+	for _, m := range metrics {
+		// JSON can't encode NaN and infinite values.
+		if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+			continue
+		}
+		i, ok := byName[m.Name]
+		if !ok {
+			i = len(otlpMetrics)
+			byName[m.Name] = i
+			otlpMetrics = append(otlpMetrics, otlpMetric{Name: m.Name})
+		}
+		otlpMetrics[i].Gauge.DataPoints = append(otlpMetrics[i].Gauge.DataPoints, otlpDataPoint{
+			Attributes:   otlpAttributes(m.Labels),
+			TimeUnixNano: strconv.FormatInt(m.Time.UnixNano(), 10),
+			AsDouble:     m.Value,
+		})
+	}
+	return otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     resource,
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: otlpScopeName}, Metrics: otlpMetrics}},
+	}}}
+}
+
+func otlpAttributes(labels map[string]string) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(labels))
+	for _, name := range sortedLabelNames(labels) {
+		attributes = append(attributes, otlpAttribute{Key: name, Value: otlpAttributeValue{StringValue: labels[name]}})
+	}
+	return attributes
+}
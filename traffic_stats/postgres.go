@@ -0,0 +1,407 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"github.com/lib/pq"
+)
+
+const defaultPostgresPort = 5432
+
+// PostgresConfig configures the storage of stats in a PostgreSQL database,
+// optionally with the TimescaleDB extension, instead of or alongside InfluxDB.
+type PostgresConfig struct {
+	Enable   bool   `json:"enable"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	DBName   string `json:"dbName"`
+	SSLMode  string `json:"sslMode"`
+	// RetentionDays is how long cache and Delivery Service stats are kept.
+	// Daily stats are kept indefinitely. 0 keeps all stats.
+	RetentionDays int `json:"retentionDays"`
+}
+
+// postgresTable describes the table of the stats of the InfluxDB database of
+// the same name. Each tag is a text column, and the name and value of each
+// point are the stat and value columns.
+type postgresTable struct {
+	name  string
+	tags  []string
+	index []string
+	// expires is whether the RetentionDays of the config apply.
+	expires bool
+}
+
+var postgresTables = []postgresTable{
+	{
+		name:    "cache_stats",
+		tags:    []string{"cdn", "cachegroup", "hostname", "type"},
+		index:   []string{"cdn", "stat"},
+		expires: true,
+	},
+	{
+		name:    "deliveryservice_stats",
+		tags:    []string{"cdn", "cachegroup", "deliveryservice", "type"},
+		index:   []string{"deliveryservice", "stat", "cachegroup"},
+		expires: true,
+	},
+	{
+		name:  "daily_stats",
+		tags:  []string{"cdn", "deliveryservice"},
+		index: []string{"cdn", "stat"},
+	},
+}
+
+const (
+	timescaleDBInstalledQuery = `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')`
+	createHypertableQuery     = `SELECT create_hypertable($1::regclass, 'time', if_not_exists => TRUE)`
+	addRetentionPolicyQuery   = `SELECT add_retention_policy($1::regclass, $2::interval, if_not_exists => TRUE)`
+)
+
+// cdnMinutesQuery emulates the "bandwidth.cdn.1min" continuous query of
+// InfluxDB: the sum of the 1 minute means of the bandwidth of each cache of
+// each CDN.
+const cdnMinutesQuery = `
+WITH cache_minutes AS (
+	SELECT to_timestamp(floor(extract(epoch FROM time) / 60) * 60) AS minute, cdn, hostname, avg(value) AS value
+	FROM cache_stats
+	WHERE stat = 'bandwidth'
+	AND time > $1
+	AND time < $2
+	GROUP BY minute, cdn, hostname
+), cdn_minutes AS (
+	SELECT minute, cdn, sum(value) AS value
+	FROM cache_minutes
+	GROUP BY minute, cdn
+)`
+
+const (
+	dailyMaxGbpsQuery = cdnMinutesQuery + `
+SELECT DISTINCT ON (cdn) cdn, minute, value
+FROM cdn_minutes
+ORDER BY cdn, value DESC`
+
+	dailyBytesServedQuery = cdnMinutesQuery + `
+SELECT cdn, sum(value)
+FROM cdn_minutes
+GROUP BY cdn`
+)
+
+// PostgresStorage is a DataExporter that writes stats to the tables of
+// postgresTables, and calculates the daily summaries from them.
+type PostgresStorage struct {
+	db *sql.DB
+	// timescaleDB is whether the tables are TimescaleDB hypertables, which
+	// expire stats by themselves.
+	timescaleDB bool
+	// mutex serializes the creation of the schema.
+	mutex       sync.Mutex
+	initialized bool
+}
+
+// validatePostgresConfig sets the defaults of the Postgres config and checks
+// that it can be used.
+func validatePostgresConfig(config *PostgresConfig) error {
+	if !config.Enable {
+		return nil
+	}
+	if config.Host == "" || config.DBName == "" {
+		return errors.New("postgres.host and postgres.dbName are required when Postgres is enabled")
+	}
+	if config.Port <= 0 {
+		config.Port = defaultPostgresPort
+	}
+	if config.SSLMode == "" {
+		config.SSLMode = "disable"
+	}
+	if config.RetentionDays < 0 {
+		return errors.New("postgres.retentionDays must not be negative")
+	}
+	return nil
+}
+
+// postgresConnectionString builds the URL of the database in the config.
+func postgresConnectionString(config PostgresConfig) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.User, config.Password),
+		Host:     config.Host + ":" + strconv.Itoa(config.Port),
+		Path:     config.DBName,
+		RawQuery: url.Values{"sslmode": {config.SSLMode}, "fallback_application_name": {UserAgent}}.Encode(),
+	}
+	return u.String()
+}
+
+func newPostgresStorage(config PostgresConfig) (*PostgresStorage, error) {
+	db, err := sql.Open("postgres", postgresConnectionString(config))
+	if err != nil {
+		return nil, fmt.Errorf("opening Postgres database: %w", err)
+	}
+	return &PostgresStorage{db: db}, nil
+}
+
+// init creates the tables, their indexes and - if TimescaleDB is installed -
+// hypertables and retention policies, unless they already exist. It's retried
+// on each export until it succeeds, so that Traffic Stats can start before
+// the database.
+func (s *PostgresStorage) init(config PostgresConfig) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.initialized {
+		return nil
+	}
+
+	if err := s.db.QueryRow(timescaleDBInstalledQuery).Scan(&s.timescaleDB); err != nil {
+		return fmt.Errorf("checking for the TimescaleDB extension: %w", err)
+	}
+	for _, table := range postgresTables {
+		for _, stmt := range table.schema() {
+			if _, err := s.db.Exec(stmt); err != nil {
+				return fmt.Errorf("creating table %s: %w", table.name, err)
+			}
+		}
+		if !s.timescaleDB {
+			continue
+		}
+		if _, err := s.db.Exec(createHypertableQuery, table.name); err != nil {
+			return fmt.Errorf("creating hypertable %s: %w", table.name, err)
+		}
+		if table.expires && config.RetentionDays > 0 {
+			if _, err := s.db.Exec(addRetentionPolicyQuery, table.name, fmt.Sprintf("%d days", config.RetentionDays)); err != nil {
+				return fmt.Errorf("adding a retention policy to %s: %w", table.name, err)
+			}
+		}
+	}
+	s.initialized = true
+	return nil
+}
+
+// postgresTableOf returns the table of the stats of an InfluxDB database.
+func postgresTableOf(database string) (postgresTable, bool) {
+	for _, table := range postgresTables {
+		if table.name == database {
+			return table, true
+		}
+	}
+	return postgresTable{}, false
+}
+
+// schema returns the statements that create the table and its index.
+func (t postgresTable) schema() []string {
+	columns := []string{"time timestamptz NOT NULL"}
+	for _, tag := range t.tags {
+		columns = append(columns, tag+" text NOT NULL DEFAULT ''")
+	}
+	columns = append(columns, "stat text NOT NULL", "value double precision NOT NULL")
+	return []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", t.name, strings.Join(columns, ",\n\t")),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_%s_time_idx ON %s (%s, time DESC)", t.name, strings.Join(t.index, "_"), t.name, strings.Join(t.index, ", ")),
+	}
+}
+
+// columns returns the columns of the table in the order that points are
+// copied into it.
+func (t postgresTable) columns() []string {
+	columns := append([]string{"time"}, t.tags...)
+	return append(columns, "stat", "value")
+}
+
+// ExportData copies the points into the table of their database. Points that
+// have no numeric value are skipped.
+func (s *PostgresStorage) ExportData(config StartupConfig, bps influx.BatchPoints, retry bool) {
+	if !config.PostgresConfig.Enable {
+		return
+	}
+	table, ok := postgresTableOf(bps.Database())
+	if !ok {
+		return
+	}
+	if err := s.init(config.PostgresConfig); err != nil {
+		if retry {
+			config.BpsChan <- bps
+		}
+		errorf("sending metrics to Postgres: %v", err)
+		return
+	}
+	count, err := s.write(table, bps.Points())
+	if err != nil {
+		if retry {
+			config.BpsChan <- bps
+		}
+		errorf("sending metrics to Postgres: %v", err)
+		return
+	}
+	info(fmt.Sprintf("Sent %v stats for %v to Postgres", count, table.name))
+}
+
+func (s *PostgresStorage) write(table postgresTable, points []*influx.Point) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(pq.CopyIn(table.name, table.columns()...))
+	if err != nil {
+		return 0, fmt.Errorf("preparing copy into %s: %w", table.name, err)
+	}
+	count := 0
+	for _, pt := range points {
+		fields, err := pt.Fields()
+		if err != nil {
+			continue
+		}
+		value, ok := pointValue(fields)
+		if !ok {
+			continue
+		}
+		tags := pt.Tags()
+		args := []interface{}{pt.Time()}
+		for _, tag := range table.tags {
+			args = append(args, tags[tag])
+		}
+		args = append(args, pt.Name(), value)
+		if _, err := stmt.Exec(args...); err != nil {
+			stmt.Close()
+			return 0, fmt.Errorf("copying into %s: %w", table.name, err)
+		}
+		count++
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return 0, fmt.Errorf("copying into %s: %w", table.name, err)
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, fmt.Errorf("closing copy into %s: %w", table.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing copy into %s: %w", table.name, err)
+	}
+	return count, nil
+}
+
+// pointValue returns the "value" field of a point as a float.
+func pointValue(fields map[string]interface{}) (float64, bool) {
+	switch v := fields["value"].(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// deleteExpired deletes the stats that are older than the retention period.
+// With TimescaleDB, retention policies do this instead.
+func (s *PostgresStorage) deleteExpired(now time.Time, config PostgresConfig) {
+	if s.timescaleDB || config.RetentionDays <= 0 {
+		return
+	}
+	before := now.AddDate(0, 0, -config.RetentionDays)
+	for _, table := range postgresTables {
+		if !table.expires {
+			continue
+		}
+		res, err := s.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE time < $1", table.name), before)
+		if err != nil {
+			errorf("deleting expired stats from %s: %v", table.name, err)
+			continue
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			infof("deleted %d expired stats from %s", n, table.name)
+		}
+	}
+}
+
+// calcDailyMaxGbps calculates the highest bandwidth of each CDN in any minute
+// of the day, like calcDailyMaxGbps does with InfluxDB.
+func (s *PostgresStorage) calcDailyMaxGbps(bp influx.BatchPoints, startTime time.Time, endTime time.Time, config StartupConfig) {
+	kilobitsToGigabits := 1000000.00
+	rows, err := s.db.Query(dailyMaxGbpsQuery, startTime, endTime)
+	if err != nil {
+		errorf("An error occured getting max bandwidth from Postgres! %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cdn string
+		var statTime time.Time
+		var value float64
+		if err := rows.Scan(&cdn, &statTime, &value); err != nil {
+			errorf("scanning max bandwidth from Postgres: %v", err)
+			return
+		}
+		value = value / kilobitsToGigabits
+		infof("max gbps for cdn %v = %v", cdn, value)
+		addDailyStat(config, bp, cdn, "daily_maxgbps", value, statTime)
+	}
+	if err := rows.Err(); err != nil {
+		errorf("reading max bandwidth from Postgres: %v", err)
+		return
+	}
+	config.BpsChan <- bp
+}
+
+// calcDailyBytesServed calculates the terabytes served by each CDN in the day,
+// like calcDailyBytesServed does with InfluxDB.
+func (s *PostgresStorage) calcDailyBytesServed(bp influx.BatchPoints, startTime time.Time, endTime time.Time, config StartupConfig) {
+	bytesToTerabytes := 1000000000.00
+	sampleTimeSecs := 60.00
+	bitsTobytes := 8.00
+	rows, err := s.db.Query(dailyBytesServedQuery, startTime, endTime)
+	if err != nil {
+		errorln("An error occured getting bytes served from Postgres: ", err)
+		return
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		var cdn string
+		var kbps float64
+		if err := rows.Scan(&cdn, &kbps); err != nil {
+			errorf("scanning bytes served from Postgres: %v", err)
+			return
+		}
+		bytesServedTB := kbps * sampleTimeSecs / bitsTobytes / bytesToTerabytes
+		infof("TBytes served for cdn %v = %v", cdn, bytesServedTB)
+		addDailyStat(config, bp, cdn, "daily_bytesserved", bytesServedTB, startTime)
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		errorf("reading bytes served from Postgres: %v", err)
+		return
+	}
+	if count > 0 {
+		config.BpsChan <- bp
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	influx "github.com/influxdata/influxdb/client/v2"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestValidatePostgresConfig(t *testing.T) {
+	config := PostgresConfig{Enable: true, Host: "db", DBName: "traffic_stats"}
+	if err := validatePostgresConfig(&config); err != nil {
+		t.Fatalf("validating config: %v", err)
+	}
+	if config.Port != defaultPostgresPort || config.SSLMode != "disable" {
+		t.Errorf("expected default port and SSL mode, got %+v", config)
+	}
+	expected := "postgres://ts:p%40ss@db:5432/traffic_stats?fallback_application_name=traffic-stats&sslmode=disable"
+	config.User, config.Password = "ts", "p@ss"
+	if actual := postgresConnectionString(config); actual != expected {
+		t.Errorf("expected connection string %s, got %s", expected, actual)
+	}
+	if err := validatePostgresConfig(&PostgresConfig{Enable: true}); err == nil {
+		t.Error("expected an error for a config without a host")
+	}
+}
+
+func TestPostgresTableSchema(t *testing.T) {
+	table, ok := postgresTableOf("cache_stats")
+	if !ok {
+		t.Fatal("expected a table for cache_stats")
+	}
+	schema := table.schema()
+	if len(schema) != 2 {
+		t.Fatalf("expected a table and an index, got %v", schema)
+	}
+	for _, expected := range []string{"CREATE TABLE IF NOT EXISTS cache_stats (", "hostname text NOT NULL DEFAULT ''", "value double precision NOT NULL"} {
+		if !strings.Contains(schema[0], expected) {
+			t.Errorf("expected %q in %s", expected, schema[0])
+		}
+	}
+	if expected := "CREATE INDEX IF NOT EXISTS cache_stats_cdn_stat_time_idx ON cache_stats (cdn, stat, time DESC)"; schema[1] != expected {
+		t.Errorf("expected %s, got %s", expected, schema[1])
+	}
+	if _, ok := postgresTableOf("unknown"); ok {
+		t.Error("expected no table for an unknown database")
+	}
+}
+
+func TestPostgresStorageWrite(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("creating mock database: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresStorage{db: db}
+
+	sampleTime := time.Unix(1700000000, 0)
+	bps, _ := influx.NewBatchPoints(influx.BatchPointsConfig{Database: "deliveryservice_stats"})
+	pt, _ := influx.NewPoint("kbps", map[string]string{"deliveryservice": "ds", "cdn": "cdn", "cachegroup": "total"}, map[string]interface{}{"value": 12.5}, sampleTime)
+	bps.AddPoint(pt)
+	pt, _ = influx.NewPoint("kbps", map[string]string{}, map[string]interface{}{"other": 1.0}, sampleTime)
+	bps.AddPoint(pt)
+
+	mock.ExpectBegin()
+	copyIn := mock.ExpectPrepare(regexp.QuoteMeta(`COPY "deliveryservice_stats" ("time", "cdn", "cachegroup", "deliveryservice", "type", "stat", "value") FROM STDIN`))
+	copyIn.ExpectExec().WithArgs(sampleTime, "cdn", "total", "ds", "", "kbps", 12.5).WillReturnResult(sqlmock.NewResult(0, 1))
+	copyIn.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	table, _ := postgresTableOf(bps.Database())
+	count, err := s.write(table, bps.Points())
+	if err != nil {
+		t.Fatalf("writing points: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected the point without a value to be skipped, got %d points", count)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
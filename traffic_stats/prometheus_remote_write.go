@@ -0,0 +1,151 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const prometheusRemoteWriteType = "prometheus-remote-write"
+
+// The field numbers of the Prometheus remote write 1.0 protobuf messages.
+const (
+	remoteWriteRequestTimeseries = 1
+	remoteWriteSeriesLabels      = 1
+	remoteWriteSeriesSamples     = 2
+	remoteWriteLabelName         = 1
+	remoteWriteLabelValue        = 2
+	remoteWriteSampleValue       = 1
+	remoteWriteSampleTimestamp   = 2
+)
+
+func init() {
+	registerMetricExporter(prometheusRemoteWriteType, newPrometheusRemoteWriteExporter)
+}
+
+// PrometheusRemoteWriteOptions are the options of a prometheus-remote-write
+// exporter.
+type PrometheusRemoteWriteOptions struct {
+	HTTPExporterOptions
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	BearerToken string `json:"bearerToken"`
+}
+
+// prometheusRemoteWriteExporter sends metrics to a Prometheus remote write
+// endpoint, e.g. Prometheus, Cortex, Mimir, Thanos or VictoriaMetrics.
+type prometheusRemoteWriteExporter struct {
+	httpExporter
+	headers map[string]string
+}
+
+func newPrometheusRemoteWriteExporter(rawOptions json.RawMessage) (MetricExporter, error) {
+	options := PrometheusRemoteWriteOptions{}
+	if err := json.Unmarshal(rawOptions, &options); err != nil {
+		return nil, fmt.Errorf("parsing options: %w", err)
+	}
+	e, err := newHTTPExporter(options.HTTPExporterOptions)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	}
+	if options.BearerToken != "" {
+		headers["Authorization"] = "Bearer " + options.BearerToken
+	} else if options.Username != "" {
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(options.Username+":"+options.Password))
+	}
+	return &prometheusRemoteWriteExporter{httpExporter: e, headers: headers}, nil
+}
+
+func (e *prometheusRemoteWriteExporter) Export(metrics []Metric) error {
+	return e.post(snappy.Encode(nil, encodeRemoteWriteRequest(metrics)), e.headers)
+}
+
+// remoteWriteSeries is a time series of a remote write request.
+type remoteWriteSeries struct {
+	labelNames  []string
+	labelValues []string
+	metrics     []Metric
+}
+
+// encodeRemoteWriteRequest encodes the metrics as a remote write WriteRequest,
+// with one time series per combination of name and labels.
+func encodeRemoteWriteRequest(metrics []Metric) []byte {
+	series := map[string]*remoteWriteSeries{}
+	keys := []string{}
+	for _, m := range metrics {
+		labels := make(map[string]string, len(m.Labels)+1)
+		for k, v := range m.Labels {
+			labels[k] = v
+		}
+		labels["__name__"] = m.Name
+		names := sortedLabelNames(labels)
+		values := make([]string, len(names))
+		for i, name := range names {
+			values[i] = labels[name]
+		}
+		key := strings.Join(names, "\xff") + "\xfe" + strings.Join(values, "\xff")
+		s, ok := series[key]
+		if !ok {
+			s = &remoteWriteSeries{labelNames: names, labelValues: values}
+			series[key] = s
+			keys = append(keys, key)
+		}
+		s.metrics = append(s.metrics, m)
+	}
+
+	req := []byte{}
+	for _, key := range keys {
+		s := series[key]
+		sort.SliceStable(s.metrics, func(i, j int) bool { return s.metrics[i].Time.Before(s.metrics[j].Time) })
+		ts := []byte{}
+		for i, name := range s.labelNames {
+			label := protowire.AppendTag(nil, remoteWriteLabelName, protowire.BytesType)
+			label = protowire.AppendString(label, name)
+			label = protowire.AppendTag(label, remoteWriteLabelValue, protowire.BytesType)
+			label = protowire.AppendString(label, s.labelValues[i])
+			ts = protowire.AppendTag(ts, remoteWriteSeriesLabels, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+		for _, m := range s.metrics {
+			sample := protowire.AppendTag(nil, remoteWriteSampleValue, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(m.Value))
+			sample = protowire.AppendTag(sample, remoteWriteSampleTimestamp, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(m.Time.UnixMilli()))
+			ts = protowire.AppendTag(ts, remoteWriteSeriesSamples, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, sample)
+		}
+		req = protowire.AppendTag(req, remoteWriteRequestTimeseries, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
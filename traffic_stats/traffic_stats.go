@@ -34,6 +34,7 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -113,7 +114,10 @@ type StartupConfig struct {
 	DailySummaryRetentionPolicy string   `json:"dailySummaryRetentionPolicy"`
 	BpsChan                     chan influx.BatchPoints
 	InfluxDBs                   []*InfluxDBProps
-	KafkaConfig                 KafkaConfig `json:"kafkaConfig"`
+	KafkaConfig                 KafkaConfig       `json:"kafkaConfig"`
+	CDNILoggingConfig           CDNILoggingConfig `json:"cdniLogging"`
+	Exporters                   []ExporterConfig  `json:"exporters"`
+	PostgresConfig              PostgresConfig    `json:"postgres"`
 }
 
 type KafkaConfig struct {
@@ -130,6 +134,10 @@ type KafkaConfig struct {
 type KafkaCluster struct {
 	producer *sarama.AsyncProducer
 	client   *sarama.Client
+	topic    string
+	// mutex guards closing the cluster, which may happen while stats are
+	// being published.
+	mutex sync.RWMutex
 }
 
 type KafkaJSON struct {
@@ -144,12 +152,28 @@ type DataExporter interface {
 }
 
 func (c *KafkaCluster) ExportData(config StartupConfig, bps influx.BatchPoints, retry bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.producer == nil {
+		log.Errorln("Unable to export to Kafka: the producer is closed")
+		return
+	}
 	err := publishToKafka(config, bps, c)
 	if err != nil {
 		log.Errorln("Unable to export to Kafka", err)
 	}
 }
 
+// Close closes the producer and client of the cluster.
+func (c *KafkaCluster) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.producer != nil {
+		startShutdown(c)
+	}
+	return nil
+}
+
 func (influx InfluxClient) ExportData(config StartupConfig, bps influx.BatchPoints, retry bool) {
 	sendMetrics(config, bps, retry)
 }
@@ -157,6 +181,67 @@ func (influx InfluxClient) ExportData(config StartupConfig, bps influx.BatchPoin
 type InfluxClient struct {
 }
 
+// These are the types of the exporters of the registry that export to the
+// InfluxDB servers of influxUrls, and to Kafka.
+const (
+	influxDBExporterType = "influxdb"
+	kafkaExporterType    = "kafka"
+)
+
+func init() {
+	registerExporter(influxDBExporterType, newInfluxDBExporter)
+	registerExporter(kafkaExporterType, newKafkaExporter)
+}
+
+// newInfluxDBExporter creates an exporter that writes stats to the InfluxDB
+// servers of influxUrls, in the schema of the InfluxDB databases.
+func newInfluxDBExporter(config StartupConfig, _ ExporterConfig) (DataExporter, error) {
+	if config.DisableInflux {
+		return nil, errors.New("InfluxDB is disabled by disableInflux")
+	}
+	return InfluxClient{}, nil
+}
+
+// newKafkaExporter creates an exporter that publishes stats to Kafka. Its
+// options are those of kafkaConfig.
+func newKafkaExporter(_ StartupConfig, exporterConfig ExporterConfig) (DataExporter, error) {
+	kafkaConfig := KafkaConfig{}
+	if len(exporterConfig.Options) > 0 {
+		if err := json.Unmarshal(exporterConfig.Options, &kafkaConfig); err != nil {
+			return nil, fmt.Errorf("parsing options: %w", err)
+		}
+	}
+	kafkaConfig.Enable = true
+	c := newKakfaCluster(kafkaConfig)
+	if c == nil {
+		return nil, errors.New("could not connect to the Kafka brokers '" + kafkaConfig.Brokers + "'")
+	}
+	return c, nil
+}
+
+// addLegacyExporters adds the exporters configured by influxUrls and
+// kafkaConfig to the exporters of the registry, unless exporters of their
+// types are already configured.
+func addLegacyExporters(config *StartupConfig) error {
+	types := map[string]struct{}{}
+	for _, exporter := range config.Exporters {
+		types[exporter.Type] = struct{}{}
+	}
+	legacy := []ExporterConfig{}
+	if _, ok := types[kafkaExporterType]; !ok && config.KafkaConfig.Enable {
+		options, err := json.Marshal(config.KafkaConfig)
+		if err != nil {
+			return fmt.Errorf("encoding kafkaConfig: %w", err)
+		}
+		legacy = append(legacy, ExporterConfig{Type: kafkaExporterType, Enable: true, Options: options})
+	}
+	if _, ok := types[influxDBExporterType]; !ok && !config.DisableInflux {
+		legacy = append(legacy, ExporterConfig{Type: influxDBExporterType, Enable: true})
+	}
+	config.Exporters = append(legacy, config.Exporters...)
+	return nil
+}
+
 var useSeelog bool = true
 
 // RunningConfig is used to store runtime configuration for Traffic Stats.  This includes information
@@ -268,8 +353,6 @@ func main() {
 	go getToData(config, true, configChan)
 	runningConfig := <-configChan
 
-	c := newKakfaCluster(config.KafkaConfig)
-
 	tickers = setTimers(config)
 
 	termChan := make(chan os.Signal, 1)
@@ -278,17 +361,28 @@ func main() {
 	hupChan := make(chan os.Signal, 1)
 	signal.Notify(hupChan, syscall.SIGHUP)
 
+	// the exporters that aren't in the registry, which aren't rebuilt when
+	// the config is reloaded.
 	dataExporters := []DataExporter{}
 
-	if config.KafkaConfig.Enable && c != nil {
-		dataExporters = append(dataExporters, c)
+	if config.CDNILoggingConfig.Enable {
+		cdniLogger := newCDNILogger(config.CDNILoggingConfig)
+		dataExporters = append(dataExporters, cdniLogger)
+		go serveCDNILogs(config.CDNILoggingConfig, cdniLogger)
 	}
 
-	if !config.DisableInflux {
-		influx := InfluxClient{}
-		dataExporters = append(dataExporters, influx)
+	var postgresStorage *PostgresStorage
+	if config.PostgresConfig.Enable {
+		postgresStorage, err = newPostgresStorage(config.PostgresConfig)
+		if err != nil {
+			errorln(err)
+			panic(err)
+		}
+		dataExporters = append(dataExporters, postgresStorage)
 	}
 
+	registryExporters := newExporters(config)
+
 	for {
 		select {
 		case <-hupChan:
@@ -298,20 +392,22 @@ func main() {
 				errorf("could not load startup config: %v", err)
 			} else {
 				config = newConfig
+				closeExporters(registryExporters)
+				registryExporters = newExporters(config)
 				tickers = setTimers(config)
 			}
 		case <-termChan:
 			info("Shutdown Request Received - Sending stored metrics then quitting")
 			for _, val := range Bps {
-				for _, dataExporter := range dataExporters {
+				for _, dataExporter := range append(dataExporters, registryExporters...) {
 					dataExporter.ExportData(config, val, false)
 				}
 			}
-			startShutdown(c)
+			closeExporters(registryExporters)
 			os.Exit(0)
 		case <-tickers.Publish:
 			for key, val := range Bps {
-				for _, dataExporter := range dataExporters {
+				for _, dataExporter := range append(dataExporters, registryExporters...) {
 					go dataExporter.ExportData(config, val, true)
 				}
 				delete(Bps, key)
@@ -327,7 +423,7 @@ func main() {
 				}
 			}
 		case now := <-tickers.DailySummary:
-			go calcDailySummary(now, config, runningConfig)
+			go calcDailySummary(now, config, runningConfig, postgresStorage)
 		case batchPoints := <-config.BpsChan:
 			debug("Received ", len(batchPoints.Points()), " stats")
 			key := fmt.Sprintf("%s%s", batchPoints.Database(), batchPoints.RetentionPolicy())
@@ -437,6 +533,7 @@ func newKakfaCluster(config KafkaConfig) *KafkaCluster {
 	c := &KafkaCluster{
 		producer: &p,
 		client:   &cl,
+		topic:    config.Topic,
 	}
 
 	return c
@@ -460,7 +557,7 @@ func publishToKafka(config StartupConfig, bps influx.BatchPoints, c *KafkaCluste
 			return err
 		}
 
-		topic := config.KafkaConfig.Topic
+		topic := c.topic
 
 		input <- &sarama.ProducerMessage{
 			Topic: topic,
@@ -521,6 +618,22 @@ func loadStartupConfig(configFile string, oldConfig StartupConfig) (StartupConfi
 		config.ToRequestTimeoutSeconds = defaultTrafficOpsRequestTimeout
 	}
 
+	if err = validateCDNILoggingConfig(&config.CDNILoggingConfig); err != nil {
+		return config, err
+	}
+
+	if err = addLegacyExporters(&config); err != nil {
+		return config, err
+	}
+
+	if err = validateExporterConfigs(config.Exporters); err != nil {
+		return config, err
+	}
+
+	if err = validatePostgresConfig(&config.PostgresConfig); err != nil {
+		return config, err
+	}
+
 	if config.LogConfig != nil {
 		if err = log.InitCfg(config.LogConfig); err != nil {
 			return config, fmt.Errorf("initializing logging configuration: %w", err)
@@ -569,9 +682,12 @@ func loadStartupConfig(configFile string, oldConfig StartupConfig) (StartupConfi
 	return config, nil
 }
 
-func calcDailySummary(now time.Time, config StartupConfig, runningConfig RunningConfig) {
-	if config.DisableInflux {
-		info("Skipping daily stats since InfluxDB is not enabled")
+// calcDailySummary calculates the daily stats from InfluxDB or, if InfluxDB
+// is disabled, from Postgres.
+func calcDailySummary(now time.Time, config StartupConfig, runningConfig RunningConfig, postgresStorage *PostgresStorage) {
+	usePostgres := config.DisableInflux && config.PostgresConfig.Enable && postgresStorage != nil
+	if config.DisableInflux && !usePostgres {
+		info("Skipping daily stats since neither InfluxDB nor Postgres is enabled")
 		return
 	}
 	infof("lastSummaryTime is %v", runningConfig.LastSummaryTime)
@@ -580,6 +696,20 @@ func calcDailySummary(now time.Time, config StartupConfig, runningConfig Running
 		endTime := startTime.Add(24 * time.Hour)
 		info("Summarizing from ", startTime, " (", startTime.Unix(), ") to ", endTime, " (", endTime.Unix(), ")")
 
+		bp, _ := influx.NewBatchPoints(influx.BatchPointsConfig{
+			Database:        "daily_stats",
+			Precision:       "s",
+			RetentionPolicy: config.DailySummaryRetentionPolicy,
+		})
+
+		if usePostgres {
+			postgresStorage.calcDailyMaxGbps(bp, startTime, endTime, config)
+			postgresStorage.calcDailyBytesServed(bp, startTime, endTime, config)
+			postgresStorage.deleteExpired(now, config.PostgresConfig)
+			info("Collected daily stats @ ", now)
+			return
+		}
+
 		// influx connection
 		influxClient, err := influxConnect(config)
 		if err != nil {
@@ -587,18 +717,41 @@ func calcDailySummary(now time.Time, config StartupConfig, runningConfig Running
 			return
 		}
 
-		bp, _ := influx.NewBatchPoints(influx.BatchPointsConfig{
-			Database:        "daily_stats",
-			Precision:       "s",
-			RetentionPolicy: config.DailySummaryRetentionPolicy,
-		})
-
 		calcDailyMaxGbps(influxClient, bp, startTime, endTime, config)
 		calcDailyBytesServed(influxClient, bp, startTime, endTime, config)
 		info("Collected daily stats @ ", now)
 	}
 }
 
+// addDailyStat writes a daily stat of a CDN to Traffic Ops, and adds it to the
+// batch of points of the daily_stats database.
+func addDailyStat(config StartupConfig, bp influx.BatchPoints, cdn string, statName string, value float64, statTime time.Time) {
+	var statsSummary tc.StatsSummaryV5
+	statsSummary.CDNName = util.StrPtr(cdn)
+	statsSummary.DeliveryService = util.StrPtr("all")
+	statsSummary.StatName = util.StrPtr(statName)
+	statsSummary.StatValue = util.FloatPtr(value)
+	statsSummary.SummaryTime = time.Now()
+	statsSummary.StatDate = &statTime
+	go writeSummaryStats(config, statsSummary)
+
+	tags := map[string]string{"cdn": cdn, "deliveryservice": "all"}
+	fields := map[string]interface{}{
+		"value": value,
+	}
+	pt, err := influx.NewPoint(
+		statName,
+		tags,
+		fields,
+		statTime,
+	)
+	if err != nil {
+		errorf("error creating data point for %s: %v", statName, err)
+		return
+	}
+	bp.AddPoint(pt)
+}
+
 func calcDailyMaxGbps(client influx.Client, bp influx.BatchPoints, startTime time.Time, endTime time.Time, config StartupConfig) {
 	kilobitsToGigabits := 1000000.00
 	queryString := fmt.Sprintf(`select time, cdn, max(value) from "monthly"."bandwidth.cdn.1min" where time > '%s' and time < '%s' group by cdn`, startTime.Format(time.RFC3339), endTime.Format(time.RFC3339))
@@ -622,31 +775,7 @@ func calcDailyMaxGbps(client influx.Client, bp influx.BatchPoints, startTime tim
 					value = value / kilobitsToGigabits
 					statTime, _ := time.Parse(time.RFC3339, t)
 					infof("max gbps for cdn %v = %v", cdn, value)
-					var statsSummary tc.StatsSummaryV5
-					statsSummary.CDNName = util.StrPtr(cdn)
-					statsSummary.DeliveryService = util.StrPtr("all")
-					statsSummary.StatName = util.StrPtr("daily_maxgbps")
-					statsSummary.StatValue = util.FloatPtr(value)
-					statsSummary.SummaryTime = time.Now()
-					statsSummary.StatDate = &statTime
-					go writeSummaryStats(config, statsSummary)
-
-					//write to influxdb
-					tags := map[string]string{"cdn": cdn, "deliveryservice": "all"}
-					fields := map[string]interface{}{
-						"value": value,
-					}
-					pt, err := influx.NewPoint(
-						"daily_maxgbps",
-						tags,
-						fields,
-						statTime,
-					)
-					if err != nil {
-						errorf("error adding data point for max Gbps...%v\n", err)
-						continue
-					}
-					bp.AddPoint(pt)
+					addDailyStat(config, bp, cdn, "daily_maxgbps", value, statTime)
 				}
 			}
 		}
@@ -681,31 +810,7 @@ func calcDailyBytesServed(client influx.Client, bp influx.BatchPoints, startTime
 			}
 			bytesServedTB := bytesServed / bytesToTerabytes
 			infof("TBytes served for cdn %v = %v", cdn, bytesServedTB)
-			//write to Traffic Ops
-			var statsSummary tc.StatsSummaryV5
-			statsSummary.CDNName = util.StrPtr(cdn)
-			statsSummary.DeliveryService = util.StrPtr("all")
-			statsSummary.StatName = util.StrPtr("daily_bytesserved")
-			statsSummary.StatValue = util.FloatPtr(bytesServedTB)
-			statsSummary.SummaryTime = time.Now()
-			statsSummary.StatDate = &startTime
-			go writeSummaryStats(config, statsSummary)
-			//write to Influxdb
-			tags := map[string]string{"cdn": cdn, "deliveryservice": "all"}
-			fields := map[string]interface{}{
-				"value": bytesServedTB, //converted to TB
-			}
-			pt, err := influx.NewPoint(
-				"daily_bytesserved",
-				tags,
-				fields,
-				startTime,
-			)
-			if err != nil {
-				errorf("error adding creating data point for max Gbps...%v\n", err)
-				continue
-			}
-			bp.AddPoint(pt)
+			addDailyStat(config, bp, cdn, "daily_bytesserved", bytesServedTB, startTime)
 		}
 		config.BpsChan <- bp
 	}